@@ -19,7 +19,7 @@ type aiExecutionDecision struct {
 	Message      string
 }
 
-func evaluateAIResolution(intent router.Intent, cfg config.Config, resolution provider.Resolution) aiExecutionDecision {
+func evaluateAIResolution(intent router.Intent, cfg config.Config, providerName string, resolution provider.Resolution) aiExecutionDecision {
 	action := strings.ToLower(strings.TrimSpace(resolution.Action))
 	switch action {
 	case "run", "suggest", "ask":
@@ -67,14 +67,20 @@ func evaluateAIResolution(intent router.Intent, cfg config.Config, resolution pr
 		}
 	}
 
-	confidence := clampConfidence(resolution.Confidence)
+	// The threshold is checked against the calibrated confidence, so a
+	// provider whose confident answers often fail here runs less on its own.
+	confidence := clampConfidence(calibratedConfidence(cfg, providerName, resolution.Confidence))
 	minConfidence := confidenceThresholdForIntent(cfg, intent)
 
 	if confidence < minConfidence {
+		message := fmt.Sprintf("provider confidence %.2f is below threshold %.2f", confidence, minConfidence)
+		if stated := clampConfidence(resolution.Confidence); stated != confidence {
+			message = fmt.Sprintf("provider confidence %.2f (stated %.2f, calibrated from your past outcomes) is below threshold %.2f", confidence, stated, minConfidence)
+		}
 		return aiExecutionDecision{
 			Allowed: false,
 			Command: normalized,
-			Message: fmt.Sprintf("provider confidence %.2f is below threshold %.2f", confidence, minConfidence),
+			Message: message,
 		}
 	}
 
@@ -86,6 +92,19 @@ func evaluateAIResolution(intent router.Intent, cfg config.Config, resolution pr
 		}
 	}
 
+	// A provider's max_risk caps what it can run on its own, judged by the
+	// same policy that rates the command at execution time, so a cheap model
+	// calling rm -rf "low" does not get past it.
+	if ceiling := cfg.Providers[providerName].MaxRisk; ceiling != "" {
+		if _, risk := applyExecutionRiskPolicy(cfg, "suggest", normalized, resolution.Risk); riskRank(risk.Level) > riskRank(ceiling) {
+			return aiExecutionDecision{
+				Allowed: false,
+				Command: normalized,
+				Message: fmt.Sprintf("%s may only run commands up to %s risk; this one is %s", providerName, ceiling, risk),
+			}
+		}
+	}
+
 	reason := strings.TrimSpace(resolution.Reason)
 	if reason == "" {
 		reason = "provider suggestion"
@@ -140,3 +159,14 @@ func normalizeRiskHint(risk string) string {
 		return "low"
 	}
 }
+
+func riskRank(risk string) int {
+	switch normalizeRiskHint(risk) {
+	case "high":
+		return 2
+	case "medium":
+		return 1
+	default:
+		return 0
+	}
+}
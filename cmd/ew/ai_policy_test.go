@@ -17,7 +17,7 @@ func TestEvaluateAIResolutionAskNeverExecutes(t *testing.T) {
 		Reason:     "need your review",
 	}
 
-	decision := evaluateAIResolution(router.IntentFix, cfg, resolution)
+	decision := evaluateAIResolution(router.IntentFix, cfg, "codex", resolution)
 	if decision.Allowed {
 		t.Fatalf("expected ask action to be blocked")
 	}
@@ -31,7 +31,7 @@ func TestEvaluateAIResolutionAskWithoutCommandIsHandled(t *testing.T) {
 		Reason:     "need your review",
 	}
 
-	decision := evaluateAIResolution(router.IntentFix, cfg, resolution)
+	decision := evaluateAIResolution(router.IntentFix, cfg, "codex", resolution)
 	if decision.Allowed {
 		t.Fatalf("expected ask action without command to be blocked")
 	}
@@ -49,7 +49,7 @@ func TestEvaluateAIResolutionSuggestBlockedByDefault(t *testing.T) {
 		Reason:     "safe check",
 	}
 
-	decision := evaluateAIResolution(router.IntentRun, cfg, resolution)
+	decision := evaluateAIResolution(router.IntentRun, cfg, "codex", resolution)
 	if decision.Allowed {
 		t.Fatalf("expected suggest action to be blocked when allow_suggest_execution=false")
 	}
@@ -65,7 +65,7 @@ func TestEvaluateAIResolutionRunBlockedByConfidence(t *testing.T) {
 		Reason:     "low confidence",
 	}
 
-	decision := evaluateAIResolution(router.IntentFix, cfg, resolution)
+	decision := evaluateAIResolution(router.IntentFix, cfg, "codex", resolution)
 	if decision.Allowed {
 		t.Fatalf("expected run action below confidence threshold to be blocked")
 	}
@@ -83,7 +83,7 @@ func TestEvaluateAIResolutionNeedsConfirmationForcesConfirm(t *testing.T) {
 		NeedsConfirmation: true,
 	}
 
-	decision := evaluateAIResolution(router.IntentRun, cfg, resolution)
+	decision := evaluateAIResolution(router.IntentRun, cfg, "codex", resolution)
 	if !decision.Allowed {
 		t.Fatalf("expected decision to be allowed")
 	}
@@ -94,3 +94,30 @@ func TestEvaluateAIResolutionNeedsConfirmationForcesConfirm(t *testing.T) {
 		t.Fatalf("expected normalized command, got %q", decision.Command)
 	}
 }
+
+func TestEvaluateAIResolutionProviderRiskCeiling(t *testing.T) {
+	cfg := config.Default()
+	cheap := cfg.Providers["codex"]
+	cheap.MaxRisk = "low"
+	cfg.Providers["codex"] = cheap
+	resolution := provider.Resolution{
+		Action:     "run",
+		Command:    "git reset --hard HEAD~1",
+		Risk:       "low",
+		Confidence: 0.95,
+		Reason:     "undo the commit",
+	}
+
+	decision := evaluateAIResolution(router.IntentFix, cfg, "codex", resolution)
+	if decision.Allowed || decision.Command != "git reset --hard HEAD~1" {
+		t.Fatalf("expected a command above the provider's ceiling to be suggested only, got %+v", decision)
+	}
+	if decision := evaluateAIResolution(router.IntentFix, cfg, "claude", resolution); !decision.Allowed {
+		t.Fatalf("expected a provider without a ceiling to keep the usual policy, got %+v", decision)
+	}
+
+	resolution.Command = "git status"
+	if decision := evaluateAIResolution(router.IntentFix, cfg, "codex", resolution); !decision.Allowed {
+		t.Fatalf("expected a low-risk command within the ceiling, got %+v", decision)
+	}
+}
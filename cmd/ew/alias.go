@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ashwch/ew/internal/alias"
+	"github.com/ashwch/ew/internal/appdirs"
+	"github.com/ashwch/ew/internal/memory"
+	"github.com/ashwch/ew/internal/router"
+	"github.com/ashwch/ew/internal/session"
+)
+
+var (
+	reAliasMake   = regexp.MustCompile(`(?i)^(?:make|create|add)\s+(?:an?\s+)?alias\s+(?:(?:called|named)\s+)?(?:(\S+)\s+)?for\s+(.+)$`)
+	reAliasThis   = regexp.MustCompile(`(?i)^(?:make|turn)\s+(?:this|that|it)\s+(?:into\s+)?an?\s+alias(?:\s+(?:called|named)\s+(\S+))?$`)
+	reAliasList   = regexp.MustCompile(`(?i)^(?:list|show)\s+(?:my\s+)?(?:ew\s+)?aliases$`)
+	reAliasRemove = regexp.MustCompile(`(?i)^(?:remove|delete|drop)\s+(?:the\s+)?alias\s+(\S+)$`)
+)
+
+type aliasActionKind string
+
+const (
+	aliasActionMake   aliasActionKind = "make"
+	aliasActionList   aliasActionKind = "list"
+	aliasActionRemove aliasActionKind = "remove"
+)
+
+// aliasAction is a parsed alias prompt. An empty Query on a make means the
+// last command ew suggested or ran ("make this an alias").
+type aliasAction struct {
+	Kind  aliasActionKind
+	Name  string
+	Query string
+}
+
+// aliasJournalWindow is how far back "make this an alias" looks for the
+// command it means.
+const aliasJournalWindow = 20
+
+// askAddAlias is swapped out in tests.
+var askAddAlias = promptAddAlias
+
+func parseAliasPrompt(prompt string) (aliasAction, bool) {
+	trimmed := strings.TrimRight(strings.Join(strings.Fields(prompt), " "), ".!?")
+	if matches := reAliasMake.FindStringSubmatch(trimmed); matches != nil {
+		return aliasAction{Kind: aliasActionMake, Name: matches[1], Query: strings.TrimSpace(matches[2])}, true
+	}
+	if matches := reAliasThis.FindStringSubmatch(trimmed); matches != nil {
+		return aliasAction{Kind: aliasActionMake, Name: matches[1]}, true
+	}
+	if reAliasList.MatchString(trimmed) {
+		return aliasAction{Kind: aliasActionList}, true
+	}
+	if matches := reAliasRemove.FindStringSubmatch(trimmed); matches != nil {
+		return aliasAction{Kind: aliasActionRemove, Name: matches[1]}, true
+	}
+	return aliasAction{}, false
+}
+
+// maybeHandleAliasPrompt answers `make alias for <query>`, `make this an
+// alias`, `list aliases`, and `remove alias <name>`.
+func maybeHandleAliasPrompt(prompt string, opts options) bool {
+	action, ok := parseAliasPrompt(prompt)
+	if !ok {
+		return false
+	}
+	switch action.Kind {
+	case aliasActionMake:
+		handleMakeAlias(action, opts)
+	case aliasActionList:
+		handleListAliases(opts)
+	case aliasActionRemove:
+		handleRemoveAlias(action.Name, opts)
+	}
+	return true
+}
+
+// handleMakeAlias turns a memory entry, or the last command, into a shell
+// alias. It shows the definition and asks before writing it; --yes skips
+// the question, and without a terminal nothing is written.
+func handleMakeAlias(action aliasAction, opts options) {
+	fail := func(message string, suggestions ...string) {
+		printAliasResponse(response{Message: message, Suggestions: suggestions}, opts)
+	}
+	command, query, err := aliasSource(action.Query)
+	if err != nil {
+		fail(err.Error())
+		return
+	}
+	if command == "" {
+		if action.Query == "" {
+			fail("no recent command to make an alias of", "ew make alias for <query> uses a command memory has learned")
+		} else {
+			fail(fmt.Sprintf("memory has nothing for %q", action.Query), fmt.Sprintf("teach it first: ew remember %s means <command>", action.Query))
+		}
+		return
+	}
+
+	shell := detectShell()
+	fileName, err := alias.FileName(shell)
+	if err != nil {
+		fail(fmt.Sprintf("%v; your shell is %s", err, shell))
+		return
+	}
+	dir, err := appdirs.ConfigDir()
+	if err != nil {
+		fail(fmt.Sprintf("could not find the config directory: %v", err))
+		return
+	}
+	path := filepath.Join(dir, fileName)
+
+	store, err := alias.Load()
+	if err != nil {
+		fail(fmt.Sprintf("alias load failed: %v", err))
+		return
+	}
+	name := action.Name
+	if name == "" {
+		name = alias.Suggest(query, func(candidate string) bool {
+			if entry, ok := store.Find(candidate); ok {
+				return entry.Command != command
+			}
+			_, err := exec.LookPath(candidate)
+			return err == nil
+		})
+	}
+	if !alias.ValidName(name) {
+		fail(fmt.Sprintf("%q cannot be an alias name: use letters, digits, _, ., and -, starting with a letter", name))
+		return
+	}
+	line, _ := alias.Definition(shell, name, command)
+	warning := ""
+	if found, err := exec.LookPath(name); err == nil {
+		warning = fmt.Sprintf("%s will hide %s in new shells", name, found)
+	}
+	entry := alias.Entry{Name: name, Command: command, Query: query, Shell: shell, File: path, Line: line}
+
+	if !opts.Yes {
+		if opts.JSON || !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+			printAliasResponse(response{
+				Message:     fmt.Sprintf("alias not added; rerun with --yes to add it to %s", displayPath(path)),
+				Results:     entry,
+				Warning:     warning,
+				Suggestions: []string{line},
+			}, opts)
+			return
+		}
+		printLabeled("warning: ", warning)
+		printLabeled("for: ", query)
+		fmt.Println(line)
+		if !askAddAlias(displayPath(path)) {
+			exitIfInterrupted()
+			fmt.Println("Alias not added.")
+			return
+		}
+	}
+
+	if previous, replaced := store.Put(entry); replaced {
+		if _, err := alias.RemoveLine(previous.File, previous.Line); err != nil {
+			fail(err.Error())
+			return
+		}
+	}
+	if err := alias.AppendLine(path, line); err != nil {
+		fail(err.Error())
+		return
+	}
+	if err := alias.Save(store); err != nil {
+		fail(err.Error())
+		return
+	}
+	source, _ := alias.SourceLine(shell, path)
+	printAliasResponse(response{
+		Message:     fmt.Sprintf("added alias %s to %s", name, displayPath(path)),
+		Results:     entry,
+		Suggestions: []string{"new shells with the ew hooks load it; in this one, or without hooks, run: " + source},
+	}, opts)
+}
+
+// printAliasResponse prints an alias answer. The entry in Results is only
+// for --json; the text form says the same in its message.
+func printAliasResponse(payload response, opts options) {
+	payload.Intent = string(router.IntentAlias)
+	if !opts.JSON {
+		printLabeled("warning: ", payload.Warning)
+		payload.Results = nil
+	}
+	printResponse(payload, opts.JSON)
+}
+
+// aliasSource finds the command an alias should run: the best memory entry
+// for query, or, with no query, the last command ew suggested or ran in
+// this shell session. It returns an empty command when there is none.
+func aliasSource(query string) (string, string, error) {
+	if query == "" {
+		interactions, err := session.Recent(aliasJournalWindow)
+		if err != nil {
+			return "", "", err
+		}
+		sessionID := strings.TrimSpace(os.Getenv("EW_SESSION_ID"))
+		for idx := len(interactions) - 1; idx >= 0; idx-- {
+			item := interactions[idx]
+			if item.Command == "" || sessionID != "" && item.SessionID != "" && item.SessionID != sessionID {
+				continue
+			}
+			return item.Command, item.Query, nil
+		}
+		return "", "", nil
+	}
+	store, _, err := memory.Load()
+	if err != nil {
+		return "", "", fmt.Errorf("memory load failed: %w", err)
+	}
+	matches := store.Search(query, 1)
+	if len(matches) == 0 {
+		return "", query, nil
+	}
+	return matches[0].Command, matches[0].Query, nil
+}
+
+func handleListAliases(opts options) {
+	store, err := alias.Load()
+	if err != nil {
+		printAliasResponse(response{Message: fmt.Sprintf("alias load failed: %v", err)}, opts)
+		return
+	}
+	if opts.JSON {
+		printAliasResponse(response{Message: fmt.Sprintf("%d aliases", len(store.Aliases)), Results: store.Aliases}, opts)
+		return
+	}
+	if len(store.Aliases) == 0 {
+		fmt.Println("ew has not made any aliases yet; try ew make alias for <query>")
+		return
+	}
+	for _, entry := range store.Aliases {
+		printCommand(entry.Name+" = ", entry.Command)
+		if entry.Query != "" {
+			printLabeled("   query: ", entry.Query)
+		}
+		fmt.Printf("   %s, in %s\n", entry.Shell, displayPath(entry.File))
+	}
+}
+
+func handleRemoveAlias(name string, opts options) {
+	store, err := alias.Load()
+	if err != nil {
+		printAliasResponse(response{Message: fmt.Sprintf("alias load failed: %v", err)}, opts)
+		return
+	}
+	entry, ok := store.Remove(name)
+	if !ok {
+		printAliasResponse(response{
+			Message:     fmt.Sprintf("ew did not make an alias called %s", name),
+			Suggestions: []string{"ew list aliases shows the ones it made"},
+		}, opts)
+		return
+	}
+	found, err := alias.RemoveLine(entry.File, entry.Line)
+	if err == nil {
+		err = alias.Save(store)
+	}
+	if err != nil {
+		printAliasResponse(response{Message: err.Error()}, opts)
+		return
+	}
+	payload := response{
+		Message: fmt.Sprintf("removed alias %s from %s", name, displayPath(entry.File)),
+		Results: entry,
+	}
+	if !found {
+		payload.Warning = fmt.Sprintf("%s was no longer in %s", name, displayPath(entry.File))
+	}
+	unset := "unalias " + name
+	if entry.Shell == "fish" {
+		unset = "functions --erase " + name
+	}
+	payload.Suggestions = []string{"shells already open keep it until you run: " + unset}
+	printAliasResponse(payload, opts)
+}
+
+func promptAddAlias(path string) bool {
+	fmt.Printf("Add it to %s? [y/N]: ", path)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes"
+}
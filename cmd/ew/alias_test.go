@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ashwch/ew/internal/alias"
+	"github.com/ashwch/ew/internal/memory"
+)
+
+func TestParseAliasPrompt(t *testing.T) {
+	cases := map[string]aliasAction{
+		"make alias for push current branch":          {Kind: aliasActionMake, Query: "push current branch"},
+		"make an alias called gp for push the branch": {Kind: aliasActionMake, Name: "gp", Query: "push the branch"},
+		"create alias gp for deploy for staging":      {Kind: aliasActionMake, Name: "gp", Query: "deploy for staging"},
+		"make this an alias":                          {Kind: aliasActionMake},
+		"turn that into an alias named up":            {Kind: aliasActionMake, Name: "up"},
+		"list aliases":                                {Kind: aliasActionList},
+		"show my ew aliases":                          {Kind: aliasActionList},
+		"remove alias gp":                             {Kind: aliasActionRemove, Name: "gp"},
+	}
+	for prompt, want := range cases {
+		got, ok := parseAliasPrompt(prompt)
+		if !ok || got != want {
+			t.Fatalf("%q: expected %+v, got %+v %v", prompt, want, got, ok)
+		}
+	}
+	for _, prompt := range []string{"alias for git log", "how do I make a bash alias", "remove aliases from zshrc"} {
+		if _, ok := parseAliasPrompt(prompt); ok {
+			t.Fatalf("expected %q to stay a search", prompt)
+		}
+	}
+}
+
+func TestMakeListAndRemoveAliasFromMemory(t *testing.T) {
+	isolateConfig(t)
+	t.Setenv("SHELL", "/bin/bash")
+	t.Setenv("PATH", t.TempDir())
+	store := memory.Store{}
+	if err := store.Remember("push current branch", "git push origin HEAD"); err != nil {
+		t.Fatal(err)
+	}
+	if err := memory.Save(store); err != nil {
+		t.Fatal(err)
+	}
+
+	out := captureStdout(t, func() { maybeHandleAliasPrompt("make alias for push current branch", options{JSON: true}) })
+	if !strings.Contains(out, "alias not added") {
+		t.Fatalf("expected nothing written without --yes, got %q", out)
+	}
+
+	out = captureStdout(t, func() { maybeHandleAliasPrompt("make alias for push current branch", options{JSON: true, Yes: true}) })
+	var payload struct {
+		Message string      `json:"message"`
+		Results alias.Entry `json:"results"`
+	}
+	if err := json.Unmarshal([]byte(out), &payload); err != nil {
+		t.Fatalf("expected json output, got %q: %v", out, err)
+	}
+	entry := payload.Results
+	if entry.Name != "pcb" || entry.Line != "alias pcb='git push origin HEAD'" || filepath.Base(entry.File) != "aliases.sh" {
+		t.Fatalf("unexpected alias %+v", entry)
+	}
+	data, err := os.ReadFile(entry.File)
+	if err != nil || !strings.Contains(string(data), entry.Line+"\n") {
+		t.Fatalf("expected the alias in %s, got %q %v", entry.File, data, err)
+	}
+
+	out = captureStdout(t, func() { maybeHandleAliasPrompt("list aliases", options{JSON: true}) })
+	if !strings.Contains(out, `"name": "pcb"`) {
+		t.Fatalf("expected the alias listed, got %q", out)
+	}
+
+	captureStdout(t, func() { maybeHandleAliasPrompt("remove alias pcb", options{JSON: true}) })
+	data, _ = os.ReadFile(entry.File)
+	if strings.Contains(string(data), "pcb") {
+		t.Fatalf("expected the alias removed from the file, got %q", data)
+	}
+	if loaded, _ := alias.Load(); len(loaded.Aliases) != 0 {
+		t.Fatalf("expected the alias forgotten, got %+v", loaded.Aliases)
+	}
+}
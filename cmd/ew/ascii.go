@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/ashwch/ew/internal/ui"
+)
+
+// marshalOutput indents v for stdout. In ASCII mode non-ASCII characters
+// are written as \u escapes, which JSON readers decode back unchanged.
+func marshalOutput(v any) ([]byte, error) {
+	encoded, err := json.MarshalIndent(v, "", "  ")
+	if err != nil || !ui.ASCIIOnly() {
+		return encoded, err
+	}
+	return escapeNonASCIIJSON(encoded), nil
+}
+
+// escapeNonASCIIJSON rewrites every non-ASCII character in encoded JSON as
+// a \u escape. Outside strings JSON is ASCII already, so this is safe to do
+// byte by byte.
+func escapeNonASCIIJSON(encoded []byte) []byte {
+	out := make([]byte, 0, len(encoded))
+	for len(encoded) > 0 {
+		r, size := utf8.DecodeRune(encoded)
+		encoded = encoded[size:]
+		switch {
+		case r < utf8.RuneSelf:
+			out = append(out, byte(r))
+		case r > 0xFFFF:
+			r -= 0x10000
+			out = fmt.Appendf(out, `\u%04x\u%04x`, 0xD800+(r>>10), 0xDC00+(r&0x3FF))
+		default:
+			out = fmt.Appendf(out, `\u%04x`, r)
+		}
+	}
+	return out
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ashwch/ew/internal/ui"
+)
+
+func TestMarshalOutputEscapesNonASCIIInASCIIMode(t *testing.T) {
+	payload := response{Message: "नमस्ते → 🚀", Command: "ls"}
+	ui.SetASCIIOnly(true)
+	t.Cleanup(func() { ui.SetASCIIOnly(false) })
+
+	encoded, err := marshalOutput(payload)
+	if err != nil {
+		t.Fatalf("marshalOutput failed: %v", err)
+	}
+	for _, b := range encoded {
+		if b >= 0x80 {
+			t.Fatalf("expected pure ASCII JSON, got %s", encoded)
+		}
+	}
+	var decoded response
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("escaped JSON does not decode: %v", err)
+	}
+	if decoded.Message != payload.Message {
+		t.Fatalf("expected the message to round-trip, got %q", decoded.Message)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ashwch/ew/internal/cache"
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/provider"
+	"github.com/ashwch/ew/internal/router"
+	"github.com/ashwch/ew/internal/session"
+)
+
+// resolveCached asks service for req, reusing an answer given to an
+// identical request within ai.cache_ttl_seconds. --no-cache skips the
+// lookup but still stores the fresh answer; --no-record and
+// journal.privacy off skip the store.
+func resolveCached(ctx context.Context, service *provider.Service, cfg config.Config, opts options, req provider.Request) (provider.Resolution, string, error) {
+	preferred := strings.TrimSpace(opts.Provider)
+	ttl := time.Duration(cfg.AI.CacheTTLSeconds) * time.Second
+	if ttl <= 0 {
+		return service.Resolve(ctx, cfg, req, preferred)
+	}
+	key := cache.Key(req, preferred)
+	if !opts.NoCache {
+		if resolution, ok := cache.Lookup(key, ttl, time.Now()); ok {
+			return resolution, resolution.Provider, nil
+		}
+	}
+	resolution, providerName, err := service.Resolve(ctx, cfg, req, preferred)
+	if err == nil && session.Privacy() != session.PrivacyOff {
+		_ = cache.Store(key, resolution, time.Now())
+	}
+	return resolution, providerName, err
+}
+
+// handleCacheClear empties the provider answer cache.
+func handleCacheClear(opts options) {
+	payload := response{Intent: string(router.IntentCacheClear)}
+	count, err := cache.Clear()
+	if err != nil {
+		payload.Message = fmt.Sprintf("could not clear provider cache: %v", err)
+		printResponse(payload, opts.JSON)
+		exitWith(exitFailure)
+	}
+	payload.Message = fmt.Sprintf("cleared %d cached provider answers", count)
+	printResponse(payload, opts.JSON)
+}
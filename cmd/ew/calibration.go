@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/hook"
+	"github.com/ashwch/ew/internal/session"
+	"github.com/ashwch/ew/internal/usage"
+)
+
+// providerCalibration is built from the session journal and hook events
+// the first time a provider answer needs it.
+var providerCalibration = sync.OnceValue(func() usage.Calibration {
+	events, _ := hook.RecentEvents(topEventWindow)
+	interactions, _ := session.Recent(0)
+	return usage.Calibrate(interactions, events)
+})
+
+// calibratedConfidence is confidence as providerName's past answers bear
+// it out, when ai.calibrate_confidence is on.
+func calibratedConfidence(cfg config.Config, providerName string, confidence float64) float64 {
+	if !cfg.AI.CalibrateConfidence || providerName == "" {
+		return confidence
+	}
+	return providerCalibration().Adjust(providerName, confidence)
+}
+
+// confidenceLabel renders a provider's confidence for --verbose, with the
+// stated value when calibration changed it.
+func confidenceLabel(cfg config.Config, providerName string, confidence float64) string {
+	calibrated := calibratedConfidence(cfg, providerName, confidence)
+	if fmt.Sprintf("%.2f", calibrated) == fmt.Sprintf("%.2f", confidence) {
+		return fmt.Sprintf("%.2f", confidence)
+	}
+	return fmt.Sprintf("%.2f (%s said %.2f)", calibrated, providerName, confidence)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/provider"
+	"github.com/ashwch/ew/internal/router"
+	"github.com/ashwch/ew/internal/usage"
+)
+
+func TestCalibratedConfidenceGatesAutoRun(t *testing.T) {
+	original := providerCalibration
+	t.Cleanup(func() { providerCalibration = original })
+	providerCalibration = func() usage.Calibration {
+		return usage.Calibration{Providers: []usage.ProviderCalibration{{
+			Provider: "codex",
+			Answers:  10,
+			Bands:    []usage.CalibrationBand{{Low: 0.85, High: 0.95, Answers: 10, Accepted: 6, Succeeded: 2, Stated: 0.9, Calibrated: 0.45}},
+		}}}
+	}
+	cfg := config.Default()
+	cfg.AI.AllowSuggestExecution = true
+	resolution := provider.Resolution{Action: "run", Command: "make deploy", Confidence: 0.9, Reason: "deploy"}
+
+	decision := evaluateAIResolution(router.IntentFix, cfg, "codex", resolution)
+	if decision.Allowed || !strings.Contains(decision.Message, "stated 0.90, calibrated") {
+		t.Fatalf("expected the calibrated confidence to fall below the threshold, got %+v", decision)
+	}
+	if got := confidenceLabel(cfg, "codex", 0.9); got != "0.45 (codex said 0.90)" {
+		t.Fatalf("unexpected confidence label %q", got)
+	}
+	if !evaluateAIResolution(router.IntentFix, cfg, "claude", resolution).Allowed {
+		t.Fatalf("expected a provider without history to keep its stated confidence")
+	}
+
+	cfg.AI.CalibrateConfidence = false
+	if !evaluateAIResolution(router.IntentFix, cfg, "codex", resolution).Allowed {
+		t.Fatalf("expected ai.calibrate_confidence=false to use the stated confidence")
+	}
+}
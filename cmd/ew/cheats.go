@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ashwch/ew/internal/cheats"
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/history"
+	"github.com/ashwch/ew/internal/router"
+	"github.com/ashwch/ew/internal/ui"
+)
+
+const cheatSourcePrefix = "cheat:"
+
+var (
+	cheatEntriesOnce sync.Once
+	cheatEntries     []cheats.Entry
+)
+
+// loadCheatEntries is swapped out in tests.
+var loadCheatEntries = func() []cheats.Entry {
+	dir, err := cheats.Dir()
+	if err != nil {
+		return nil
+	}
+	entries, _ := cheats.Load(dir)
+	return entries
+}
+
+// askPlaceholderValues is swapped out in tests.
+var askPlaceholderValues = promptPlaceholderValues
+
+func importedCheats() []cheats.Entry {
+	cheatEntriesOnce.Do(func() {
+		cheatEntries = loadCheatEntries()
+	})
+	return cheatEntries
+}
+
+// mergeCheatMatches adds imported cheat entries for query to the history
+// matches, ranked together by score and trimmed to limit. Cheat commands go
+// through the same destructive-query filter as history, with placeholders
+// read as plain words rather than redirections.
+func mergeCheatMatches(query string, matches []history.Match, limit int) []history.Match {
+	found := cheats.Search(importedCheats(), query, limit)
+	if len(found) == 0 {
+		return matches
+	}
+	merged := append([]history.Match{}, matches...)
+	for _, match := range found {
+		if !commandAllowedForQuery(query, withPlaceholderNames(match.Command)) {
+			continue
+		}
+		merged = append(merged, history.Match{
+			Command: match.Command,
+			Score:   match.Score,
+			Source:  cheatSourcePrefix + match.File,
+		})
+	}
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].Score > merged[j].Score })
+	if limit > 0 && len(merged) > limit {
+		merged = merged[:limit]
+	}
+	return merged
+}
+
+func withPlaceholderNames(command string) string {
+	values := map[string]string{}
+	for _, name := range cheats.Placeholders(command) {
+		values[name] = name
+	}
+	return cheats.Fill(command, values)
+}
+
+func isCheatSource(source string) bool {
+	return strings.HasPrefix(source, cheatSourcePrefix)
+}
+
+// cheatSuggestion describes the top match when it came from a cheat file, so
+// find can offer it the way it offers a provider answer.
+func cheatSuggestion(matches []history.Match) (string, string, bool) {
+	if len(matches) == 0 || !isCheatSource(matches[0].Source) {
+		return "", "", false
+	}
+	top := matches[0]
+	entry, _ := cheatEntryFor(top.Command)
+	reason := fmt.Sprintf("%s example", strings.TrimPrefix(top.Source, cheatSourcePrefix))
+	if entry.Description != "" {
+		reason = fmt.Sprintf("%s: %s", reason, entry.Description)
+	}
+	return top.Command, reason, true
+}
+
+// unfilledCheat reports a cheat command that still has placeholders; memory
+// must not learn it, or a later memory hit would print the template.
+func unfilledCheat(command string, source string) bool {
+	return isCheatSource(source) && len(cheats.Placeholders(command)) > 0
+}
+
+func cheatEntryFor(command string) (cheats.Entry, bool) {
+	for _, entry := range importedCheats() {
+		if entry.Command == command {
+			return entry, true
+		}
+	}
+	return cheats.Entry{}, false
+}
+
+func canPromptForPlaceholders(opts options) bool {
+	return !opts.JSON && !opts.Quiet && isTerminal(os.Stdin)
+}
+
+// fillSelectedCheat fills the <name> placeholders of a cheat command picked
+// in find. Commands from other sources, and any command when nobody can be
+// asked, come back unchanged. ok is false when the person cancelled.
+func fillSelectedCheat(command string, source string, cfg config.Config, opts options) (string, bool) {
+	if !isCheatSource(source) || !canPromptForPlaceholders(opts) {
+		return command, true
+	}
+	return fillCheatPlaceholders(command, cfg, opts)
+}
+
+// fillCheatPlaceholders asks for every <name> in command, showing the cheat
+// file's variable command as a hint. ok is false when the person cancelled.
+func fillCheatPlaceholders(command string, cfg config.Config, opts options) (string, bool) {
+	names := cheats.Placeholders(command)
+	if len(names) == 0 {
+		return command, true
+	}
+	entry, _ := cheatEntryFor(command)
+	placeholders := make([]ui.Placeholder, 0, len(names))
+	for _, name := range names {
+		placeholders = append(placeholders, ui.Placeholder{Name: name, Hint: entry.Variables[name]})
+	}
+	values, ok := askPlaceholderValues(command, placeholders, effectiveUIBackend(cfg, opts), opts)
+	if !ok {
+		return "", false
+	}
+	return cheats.Fill(command, values), true
+}
+
+func promptPlaceholderValues(command string, placeholders []ui.Placeholder, backend string, opts options) (map[string]string, bool) {
+	if canUseInteractiveUI(opts, backend) {
+		values, used, err := ui.FillPlaceholders(backend, command, placeholders)
+		exitIfInterrupted()
+		if err == nil && used {
+			return values, values != nil
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ew: ui form failed (%v); falling back to plain prompts\n", err)
+		}
+	}
+	fmt.Printf("Fill in: %s\n", command)
+	reader := bufio.NewReader(os.Stdin)
+	values := make(map[string]string, len(placeholders))
+	for _, placeholder := range placeholders {
+		if placeholder.Hint != "" {
+			fmt.Printf("  (values from: %s)\n", placeholder.Hint)
+		}
+		fmt.Printf("%s: ", placeholder.Name)
+		line, err := reader.ReadString('\n')
+		value := strings.TrimSpace(line)
+		if err != nil || value == "" {
+			return nil, false
+		}
+		values[placeholder.Name] = value
+	}
+	return values, true
+}
+
+// handleCheatImport copies navi-style cheat files into the config dir, where
+// find picks them up on the next call.
+func handleCheatImport(src string, opts options) {
+	dir, err := cheats.Dir()
+	if err != nil {
+		printResponse(response{Intent: string(router.IntentCheatImport), Message: fmt.Sprintf("could not locate cheats dir: %v", err)}, opts.JSON)
+		exitWith(1)
+	}
+	result, err := cheats.Import(strings.TrimSpace(src), dir)
+	if err != nil {
+		printResponse(response{Intent: string(router.IntentCheatImport), Message: err.Error()}, opts.JSON)
+		exitWith(1)
+	}
+	message := fmt.Sprintf("imported %d cheat entries from %d files into %s", result.Entries, result.Files, result.Dir)
+	printResponse(response{Intent: string(router.IntentCheatImport), Message: message}, opts.JSON)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/ashwch/ew/internal/cheats"
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/history"
+	"github.com/ashwch/ew/internal/ui"
+)
+
+const dockerCheat = `% docker
+
+# Show logs of a container
+docker logs -f <container>
+
+# Remove unused containers and images
+docker system prune -af
+
+$ container: docker ps --format '{{.Names}}'
+`
+
+func useCheatEntries(t *testing.T, entries ...cheats.Entry) {
+	t.Helper()
+	originalLoad := loadCheatEntries
+	loadCheatEntries = func() []cheats.Entry { return entries }
+	cheatEntriesOnce = sync.Once{}
+	t.Cleanup(func() {
+		loadCheatEntries = originalLoad
+		cheatEntriesOnce = sync.Once{}
+		cheatEntries = nil
+	})
+}
+
+func TestMergeCheatMatchesRanksWithHistoryAndFiltersDestructive(t *testing.T) {
+	useCheatEntries(t, cheats.Parse("docker.cheat", dockerCheat)...)
+
+	history := []history.Match{{Command: "docker logs web", Score: 4, Source: "zsh"}}
+	merged := mergeCheatMatches("show docker container logs", history, 5)
+	if len(merged) != 2 || merged[0].Source != "cheat:docker.cheat" || merged[0].Command != "docker logs -f <container>" {
+		t.Fatalf("expected the cheat entry ranked first, got %+v", merged)
+	}
+	for _, match := range mergeCheatMatches("list docker containers", nil, 5) {
+		if match.Command == "docker system prune -af" {
+			t.Fatalf("expected prune to be filtered for a read-only query, got %+v", match)
+		}
+	}
+
+	command, reason, ok := cheatSuggestion(merged)
+	if !ok || command != "docker logs -f <container>" || reason != "docker.cheat example: Show logs of a container" {
+		t.Fatalf("unexpected cheat suggestion %q %q %v", command, reason, ok)
+	}
+}
+
+func TestFillCheatPlaceholdersPassesVariableHints(t *testing.T) {
+	useCheatEntries(t, cheats.Parse("docker.cheat", dockerCheat)...)
+	original := askPlaceholderValues
+	t.Cleanup(func() { askPlaceholderValues = original })
+
+	var asked []ui.Placeholder
+	askPlaceholderValues = func(_ string, placeholders []ui.Placeholder, _ string, _ options) (map[string]string, bool) {
+		asked = placeholders
+		return map[string]string{"container": "web"}, true
+	}
+	filled, ok := fillCheatPlaceholders("docker logs -f <container>", config.Default(), options{})
+	if !ok || filled != "docker logs -f web" {
+		t.Fatalf("unexpected fill %q %v", filled, ok)
+	}
+	if len(asked) != 1 || asked[0].Hint != "docker ps --format '{{.Names}}'" {
+		t.Fatalf("expected the variable command as a hint, got %+v", asked)
+	}
+
+	askPlaceholderValues = func(string, []ui.Placeholder, string, options) (map[string]string, bool) { return nil, false }
+	if _, ok := fillCheatPlaceholders("docker logs -f <container>", config.Default(), options{}); ok {
+		t.Fatalf("expected cancel to report false")
+	}
+}
+
+func TestUnfilledCheatIsNotLearned(t *testing.T) {
+	if shouldPersistFindSuggestion("docker logs", "docker logs -f <container>", "cheat:docker.cheat", "low") {
+		t.Fatalf("expected an unfilled cheat template not to be learned")
+	}
+	if !shouldPersistFindSuggestion("docker logs", "docker logs -f web", "cheat:docker.cheat", "low") {
+		t.Fatalf("expected a filled cheat command to be learned")
+	}
+}
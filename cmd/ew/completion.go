@@ -0,0 +1,375 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	ewrt "github.com/ashwch/ew/internal/runtime"
+	"github.com/ashwch/ew/internal/tips"
+	"github.com/ashwch/ew/internal/ui"
+)
+
+// completionShells are the shells `ew completion` writes a script for.
+var completionShells = []string{"zsh", "bash", "fish"}
+
+// memoryVerbs start the memory prompts parseMemoryPromptAction knows; the
+// query in `show memory for`, `forget memory for`, `prefer ... for` and
+// `demote ... for` completes from the memory store.
+var memoryVerbs = []string{"remember", "show", "forget", "prefer", "demote", "memory"}
+
+// queryVerbs start the prompts whose last "for" is followed by a memory
+// query: the memory verbs that take one, and `make alias for`.
+var queryVerbs = []string{"show", "forget", "prefer", "demote", "make"}
+
+// completionSubcommand recognises `ew completion <shell>`. Anything else,
+// such as `ew completion for kubectl`, is an ordinary request.
+func completionSubcommand(args []string) (string, bool) {
+	if len(args) != 2 || args[0] != "completion" || !slices.Contains(completionShells, args[1]) {
+		return "", false
+	}
+	return args[1], true
+}
+
+// completionFlag is one ew flag as the completion scripts see it.
+type completionFlag struct {
+	Name    string
+	Summary string
+	// TakesValue is set for flags that are not booleans, so the scripts
+	// skip their value when working out which words came before.
+	TakesValue bool
+	// Values are offered after the flag; Internal names an `ew internal`
+	// subcommand that prints more, one per line, and Files completes a
+	// file name instead.
+	Values   []string
+	Internal string
+	Files    bool
+}
+
+// completionFlags reads the flags off the same flag set parseArgs uses.
+func completionFlags() []completionFlag {
+	values := map[string]completionFlag{
+		"provider":      {Values: []string{"auto"}, Internal: "provider-names"},
+		"mode":          {Values: []string{"suggest", "confirm", "yolo"}},
+		"ui":            {Values: []string{ui.BackendAuto, ui.BackendBubbleTea, ui.BackendHuh, ui.BackendTView, ui.BackendPlain}},
+		"intent":        {Values: []string{"fix", "find"}},
+		"locale":        {Values: []string{"auto", "en", "en-US", "hi", "hi-IN"}},
+		"dismiss-tip":   {Values: append(slices.Clone(tips.IDs), "all")},
+		"context-file":  {Files: true},
+		"import-cheats": {Files: true},
+	}
+	var opts options
+	flags := []completionFlag{}
+	newFlagSet(&opts).VisitAll(func(f *flag.Flag) {
+		entry := values[f.Name]
+		entry.Name = f.Name
+		entry.Summary = flagSummary(f.Usage)
+		boolean, ok := f.Value.(interface{ IsBoolFlag() bool })
+		entry.TakesValue = !ok || !boolean.IsBoolFlag()
+		flags = append(flags, entry)
+	})
+	return flags
+}
+
+// flagSummary keeps a flag's usage up to its first aside, short enough for
+// a completion menu.
+func flagSummary(usage string) string {
+	for _, sep := range []string{" (", "; "} {
+		if idx := strings.Index(usage, sep); idx > 0 {
+			usage = usage[:idx]
+		}
+	}
+	return strings.TrimSpace(usage)
+}
+
+// firstWords are offered for the first word after any flags.
+func firstWords() []string {
+	return append([]string{"config", "run", "session", "locale", "completion"}, memoryVerbs...)
+}
+
+// writeCompletion prints the completion script for shell.
+func writeCompletion(stdout io.Writer, shell string) error {
+	flags := completionFlags()
+	switch shell {
+	case "zsh":
+		_, err := io.WriteString(stdout, zshCompletion(flags))
+		return err
+	case "bash":
+		_, err := io.WriteString(stdout, bashCompletion(flags))
+		return err
+	case "fish":
+		_, err := io.WriteString(stdout, fishCompletion(flags))
+		return err
+	}
+	return fmt.Errorf("unsupported shell: %s", shell)
+}
+
+// valueFlagPattern is a shell case pattern matching every flag that takes
+// a value.
+func valueFlagPattern(flags []completionFlag) string {
+	names := []string{}
+	for _, f := range flags {
+		if f.TakesValue {
+			names = append(names, "--"+f.Name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+func zshCompletion(flags []completionFlag) string {
+	var b strings.Builder
+	b.WriteString(`#compdef ew
+# zsh completion for ew. Load it from ~/.zshrc, after compinit, with
+#   eval "$(ew completion zsh)"
+# or save it as _ew_complete in a directory on $fpath.
+
+_ew_memory_queries() {
+  compadd -- ${(f)"$(command ew internal memory-queries 2>/dev/null)"}
+}
+
+_ew_complete() {
+  local -a flags args
+  local i
+  flags=(
+`)
+	for _, f := range flags {
+		fmt.Fprintf(&b, "    %s\n", ewrt.ShellQuote("--"+f.Name+":"+f.Summary))
+	}
+	b.WriteString("  )\n  case ${words[CURRENT-1]} in\n")
+	for _, f := range flags {
+		switch {
+		case f.Files:
+			fmt.Fprintf(&b, "    (--%s) _files; return ;;\n", f.Name)
+		case f.Internal != "":
+			fmt.Fprintf(&b, "    (--%s) compadd -- %s ${(f)\"$(command ew internal %s 2>/dev/null)\"}; return ;;\n", f.Name, strings.Join(f.Values, " "), f.Internal)
+		case len(f.Values) > 0:
+			fmt.Fprintf(&b, "    (--%s) compadd -- %s; return ;;\n", f.Name, strings.Join(f.Values, " "))
+		}
+	}
+	fmt.Fprintf(&b, `    (%s) return ;;
+  esac
+  if [[ ${words[CURRENT]} == -* ]]; then
+    _describe -t flags 'ew flag' flags
+    return
+  fi
+  for (( i = 2; i < CURRENT; i++ )); do
+    case ${words[i]} in
+      (%s) (( i++ )) ;;
+      (-*) ;;
+      (*) args+=(${words[i]}) ;;
+    esac
+  done
+  case ${args[1]} in
+    (config)
+      if (( $#args == 1 )); then
+        compadd -- %s
+      elif (( $#args == 2 )) && [[ ${args[2]} == (set|get|unset|list) ]]; then
+        compadd -- ${(f)"$(command ew internal config-keys 2>/dev/null)"}
+      fi
+      return ;;
+    (completion)
+      (( $#args == 1 )) && compadd -- %s
+      return ;;
+    (session)
+      case $#args in
+        (1) compadd -- %s ;;
+        (2) [[ ${args[2]} == replay ]] && _files ;;
+      esac
+      return ;;
+    (locale)
+      case $#args in
+        (1) compadd -- %s ;;
+        (2) [[ ${args[2]} == check ]] && _files ;;
+      esac
+      return ;;
+    (show|forget)
+      case $#args in
+        (1) compadd -- memory ;;
+        (2) compadd -- for ;;
+        (*) [[ ${args[-1]} == for ]] && _ew_memory_queries ;;
+      esac
+      return ;;
+    (%s)
+      if [[ ${args[-1]} == for ]]; then
+        _ew_memory_queries
+        return
+      fi ;;
+  esac
+  (( $#args == 0 )) && compadd -- %s
+  _default
+}
+
+if [[ ${funcstack[1]} == _ew_complete ]]; then
+  _ew_complete "$@"
+else
+  (( $+functions[compdef] )) && compdef _ew_complete ew
+fi
+`, valueFlagPattern(flags), valueFlagPattern(flags), strings.Join(configVerbs, " "), strings.Join(completionShells, " "), strings.Join(sessionVerbs, " "), strings.Join(localeVerbs, " "), strings.Join(queryVerbs[2:], "|"), strings.Join(firstWords(), " "))
+	return b.String()
+}
+
+func bashCompletion(flags []completionFlag) string {
+	var b strings.Builder
+	names := make([]string, 0, len(flags))
+	for _, f := range flags {
+		names = append(names, "--"+f.Name)
+	}
+	b.WriteString(`# bash completion for ew. Load it from ~/.bashrc with
+#   eval "$(ew completion bash)"
+# or save it as ew in your bash-completion completions directory.
+
+_ew_memory_queries() {
+  local cur=${COMP_WORDS[COMP_CWORD]//\\/} query
+  while IFS= read -r query; do
+    case "$query" in
+      "$cur"*) COMPREPLY+=("$(printf '%q' "$query")") ;;
+    esac
+  done < <(command ew internal memory-queries 2>/dev/null)
+}
+
+_ew_complete() {
+  COMPREPLY=()
+  local cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]} words="" i
+  local args=()
+  case "$prev" in
+`)
+	for _, f := range flags {
+		switch {
+		case f.Internal != "":
+			fmt.Fprintf(&b, "    --%s) COMPREPLY=($(compgen -W \"%s $(command ew internal %s 2>/dev/null)\" -- \"$cur\")); return ;;\n", f.Name, strings.Join(f.Values, " "), f.Internal)
+		case len(f.Values) > 0:
+			fmt.Fprintf(&b, "    --%s) COMPREPLY=($(compgen -W \"%s\" -- \"$cur\")); return ;;\n", f.Name, strings.Join(f.Values, " "))
+		}
+	}
+	fmt.Fprintf(&b, `    %s) return ;;
+  esac
+  if [[ $cur == -* ]]; then
+    COMPREPLY=($(compgen -W "%s" -- "$cur"))
+    return
+  fi
+  for (( i = 1; i < COMP_CWORD; i++ )); do
+    case "${COMP_WORDS[i]}" in
+      %s) (( i++ )) ;;
+      -*) ;;
+      *) args+=("${COMP_WORDS[i]}") ;;
+    esac
+  done
+  case "${args[0]}" in
+    config)
+      if [ ${#args[@]} -eq 1 ]; then
+        words="%s"
+      elif [ ${#args[@]} -eq 2 ]; then
+        case "${args[1]}" in
+          set|get|unset|list) words=$(command ew internal config-keys 2>/dev/null) ;;
+        esac
+      fi ;;
+    completion)
+      [ ${#args[@]} -eq 1 ] && words="%s" ;;
+    session)
+      [ ${#args[@]} -eq 1 ] && words="%s" ;;
+    locale)
+      [ ${#args[@]} -eq 1 ] && words="%s" ;;
+    %s)
+      if [ "${args[${#args[@]}-1]}" = for ]; then
+        _ew_memory_queries
+        return
+      fi
+      case "${args[0]} ${#args[@]}" in
+        "show 1"|"forget 1") words=memory ;;
+        "show 2"|"forget 2") words=for ;;
+      esac ;;
+    "")
+      words="%s" ;;
+  esac
+  [ -n "$words" ] && COMPREPLY=($(compgen -W "$words" -- "$cur"))
+}
+
+complete -o default -F _ew_complete ew
+`, valueFlagPattern(flags), strings.Join(names, " "), valueFlagPattern(flags), strings.Join(configVerbs, " "), strings.Join(completionShells, " "), strings.Join(sessionVerbs, " "), strings.Join(localeVerbs, " "), strings.Join(queryVerbs, "|"), strings.Join(firstWords(), " "))
+	return b.String()
+}
+
+func fishCompletion(flags []completionFlag) string {
+	var b strings.Builder
+	valueNames := []string{}
+	for _, f := range flags {
+		if f.TakesValue {
+			valueNames = append(valueNames, "--"+f.Name)
+		}
+	}
+	fmt.Fprintf(&b, `# fish completion for ew. Load it from config.fish with
+#   ew completion fish | source
+# or save it as ~/.config/fish/completions/ew.fish.
+
+# __ew_args prints the words typed after ew, leaving out flags and their
+# values.
+function __ew_args
+  set -l tokens (commandline -opc)
+  set -e tokens[1]
+  set -l skip 0
+  for token in $tokens
+    if test $skip = 1
+      set skip 0
+      continue
+    end
+    switch $token
+      case %s
+        set skip 1
+      case '-*'
+      case '*'
+        echo $token
+    end
+  end
+end
+
+# __ew_args_are succeeds when the words typed so far are exactly its
+# arguments.
+function __ew_args_are
+  set -l args (__ew_args)
+  test (count $args) -eq (count $argv); or return 1
+  test (count $argv) -eq 0; and return 0
+  for i in (seq (count $argv))
+    test "$args[$i]" = "$argv[$i]"; or return 1
+  end
+end
+
+function __ew_wants_memory_query
+  set -l args (__ew_args)
+  contains -- "$args[1]" %s; and test "$args[-1]" = for
+end
+
+`, strings.Join(valueNames, " "), strings.Join(queryVerbs, " "))
+	for _, f := range flags {
+		line := "complete -c ew -l " + f.Name
+		switch {
+		case f.Files:
+			line += " -r -F"
+		case f.Internal != "":
+			line += " -x -a " + ewrt.FishQuote(strings.Join(f.Values, " ")+" (command ew internal "+f.Internal+" 2>/dev/null)")
+		case len(f.Values) > 0:
+			line += " -x -a " + ewrt.FishQuote(strings.Join(f.Values, " "))
+		case f.TakesValue:
+			line += " -x"
+		}
+		fmt.Fprintf(&b, "%s -d %s\n", line, ewrt.FishQuote(f.Summary))
+	}
+	configKeyVerbs := []string{}
+	for _, verb := range []string{"set", "get", "unset", "list"} {
+		configKeyVerbs = append(configKeyVerbs, "__ew_args_are config "+verb)
+	}
+	fmt.Fprintf(&b, `complete -c ew -n __ew_args_are -a %s
+complete -c ew -f -n '__ew_args_are config' -a %s
+complete -c ew -f -n %s -a '(command ew internal config-keys 2>/dev/null)'
+complete -c ew -f -n '__ew_args_are completion' -a %s
+complete -c ew -f -n '__ew_args_are session' -a %s
+complete -c ew -F -n '__ew_args_are session replay'
+complete -c ew -f -n '__ew_args_are locale' -a %s
+complete -c ew -F -n '__ew_args_are locale check'
+complete -c ew -f -n '__ew_args_are show; or __ew_args_are forget' -a memory
+complete -c ew -f -n '__ew_args_are show memory; or __ew_args_are forget memory' -a for
+complete -c ew -f -n __ew_wants_memory_query -a '(command ew internal memory-queries 2>/dev/null)'
+`, ewrt.FishQuote(strings.Join(firstWords(), " ")), ewrt.FishQuote(strings.Join(configVerbs, " ")), ewrt.FishQuote(strings.Join(configKeyVerbs, "; or ")), ewrt.FishQuote(strings.Join(completionShells, " ")), ewrt.FishQuote(strings.Join(sessionVerbs, " ")), ewrt.FishQuote(strings.Join(localeVerbs, " ")))
+	return b.String()
+}
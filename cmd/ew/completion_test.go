@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	ewrt "github.com/ashwch/ew/internal/runtime"
+)
+
+func TestCompletionSubcommandOnlyTakesKnownShells(t *testing.T) {
+	if shell, ok := completionSubcommand([]string{"completion", "fish"}); !ok || shell != "fish" {
+		t.Fatalf("expected fish, got %q %v", shell, ok)
+	}
+	for _, args := range [][]string{{"completion"}, {"completion", "tcsh"}, {"completion", "for", "kubectl"}} {
+		if _, ok := completionSubcommand(args); ok {
+			t.Fatalf("expected %q to stay a request", args)
+		}
+	}
+}
+
+func TestCompletionScriptsCoverFlagsAndDynamicWords(t *testing.T) {
+	for _, shell := range completionShells {
+		var out bytes.Buffer
+		if err := writeCompletion(&out, shell); err != nil {
+			t.Fatalf("%s: %v", shell, err)
+		}
+		script := out.String()
+		for _, want := range []string{"bootstrap-memory", "yolo-for", "ew internal config-keys", "ew internal provider-names", "ew internal memory-queries", "suggest confirm yolo"} {
+			if !strings.Contains(script, want) {
+				t.Fatalf("%s script is missing %q", shell, want)
+			}
+		}
+	}
+	flags := completionFlags()
+	for _, f := range flags {
+		if f.Name == "json" && f.TakesValue || f.Name == "provider" && !f.TakesValue {
+			t.Fatalf("wrong value kind for --%s", f.Name)
+		}
+	}
+}
+
+func TestBashCompletionCompletesMemoryQueriesAndConfigKeys(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not on PATH")
+	}
+	bin := t.TempDir()
+	fake := "#!/bin/sh\ncase \"$2\" in\n  memory-queries) printf 'push current branch\\nlist pods\\n' ;;\n  config-keys) printf 'mode\\nlocale\\n' ;;\n  provider-names) printf 'claude\\ncodex\\n' ;;\nesac\n"
+	if err := os.WriteFile(filepath.Join(bin, "ew"), []byte(fake), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	var script bytes.Buffer
+	if err := writeCompletion(&script, "bash"); err != nil {
+		t.Fatal(err)
+	}
+	complete := func(line string) string {
+		t.Helper()
+		words := strings.Fields(line)
+		if strings.HasSuffix(line, " ") {
+			words = append(words, "")
+		}
+		quoted := make([]string, 0, len(words))
+		for _, word := range words {
+			quoted = append(quoted, ewrt.ShellQuote(word))
+		}
+		program := script.String() + "\nCOMP_WORDS=(" + strings.Join(quoted, " ") + ")\nCOMP_CWORD=" + strconv.Itoa(len(words)-1) +
+			"\n_ew_complete\nprintf '%s\\n' \"${COMPREPLY[@]}\"\n"
+		cmd := exec.Command(bash, "--norc", "-c", program)
+		cmd.Env = append(os.Environ(), "PATH="+bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("bash failed: %v\n%s", err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	if got := complete("ew forget memory for pu"); got != `push\ current\ branch` {
+		t.Fatalf("expected the memory query, got %q", got)
+	}
+	if got := complete("ew --json config get m"); got != "mode" {
+		t.Fatalf("expected the config key, got %q", got)
+	}
+	if got := complete("ew --provider c"); got != "claude\ncodex" {
+		t.Fatalf("expected provider names, got %q", got)
+	}
+	if got := complete("ew make alias for li"); got != `list\ pods` {
+		t.Fatalf("expected the memory query for an alias, got %q", got)
+	}
+	if got := complete("ew --mode yolo sh"); got != "show" {
+		t.Fatalf("expected the memory verb after a flag value, got %q", got)
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	goruntime "runtime"
+	"strings"
+
+	"github.com/ashwch/ew/internal/config"
+)
+
+// configVerbs are the words `ew config` takes. Anything else after
+// "config" is an ordinary request, so `ew config file for git` still finds.
+var configVerbs = []string{"set", "get", "unset", "list", "edit", "diff"}
+
+// configSubcommand recognises `ew config <verb> ...` and returns the verb
+// and its arguments. `ew diff-config` is `ew config diff`.
+func configSubcommand(args []string) ([]string, bool) {
+	if len(args) > 0 && args[0] == "diff-config" {
+		return append([]string{"diff"}, args[1:]...), true
+	}
+	if len(args) < 2 || args[0] != "config" {
+		return nil, false
+	}
+	for _, verb := range configVerbs {
+		if args[1] == verb {
+			return args[1:], true
+		}
+	}
+	return nil, false
+}
+
+// runConfigCommand runs one `ew config` verb and returns the exit code.
+// Changes go to config.toml only; includes and project files are left
+// alone, as with --save.
+func runConfigCommand(args []string, stdout io.Writer) int {
+	verb := args[0]
+	fs := flag.NewFlagSet("ew config "+verb, flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: ew config set <key> <value> | get <key> | unset <key> | list [prefix] | edit | diff")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return exitUsage
+	}
+	rest := fs.Args()
+
+	var err error
+	switch verb {
+	case "set":
+		if len(rest) < 2 {
+			err = usageError("ew config set needs a key and a value, e.g. ew config set mode confirm")
+			break
+		}
+		err = configSet(stdout, rest[0], strings.Join(rest[1:], " "))
+	case "get":
+		if len(rest) != 1 {
+			err = usageError("ew config get needs one key, e.g. ew config get mode")
+			break
+		}
+		err = configGet(stdout, rest[0], *asJSON)
+	case "unset":
+		if len(rest) != 1 {
+			err = usageError("ew config unset needs one key, e.g. ew config unset mode")
+			break
+		}
+		err = configUnset(stdout, rest[0])
+	case "list":
+		prefix := ""
+		if len(rest) > 0 {
+			prefix = strings.ToLower(rest[0])
+		}
+		err = configList(stdout, prefix, *asJSON)
+	case "edit":
+		err = configEdit(stdout)
+	case "diff":
+		err = configDiff(stdout, *asJSON)
+	}
+	if err == nil {
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "ew: %v\n", err)
+	if hint := errorHint(err); hint != "" {
+		fmt.Fprintf(os.Stderr, "ew: %s\n", hint)
+	}
+	var usage usageError
+	if errors.As(err, &usage) {
+		return exitUsage
+	}
+	return exitCodeFor(err)
+}
+
+// usageError is a malformed `ew config` line.
+type usageError string
+
+func (e usageError) Error() string { return string(e) }
+
+func configSet(stdout io.Writer, key, value string) error {
+	cfg, path, err := config.LoadFile()
+	if err != nil {
+		return err
+	}
+	if err := cfg.Set(key, value); err != nil {
+		return err
+	}
+	if err := config.Save(path, cfg); err != nil {
+		return fmt.Errorf("could not save config: %w", err)
+	}
+	saved, _ := cfg.Get(key)
+	fmt.Fprintf(stdout, "saved %s = %s\n", strings.ToLower(strings.TrimSpace(key)), saved)
+	return nil
+}
+
+func configGet(stdout io.Writer, key string, asJSON bool) error {
+	cfg, _, err := config.LoadOrCreate()
+	if err != nil {
+		return err
+	}
+	value, err := cfg.Get(key)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		return writeJSON(stdout, map[string]string{strings.ToLower(strings.TrimSpace(key)): value})
+	}
+	fmt.Fprintln(stdout, value)
+	return nil
+}
+
+func configUnset(stdout io.Writer, key string) error {
+	cfg, path, err := config.LoadFile()
+	if err != nil {
+		return err
+	}
+	if err := cfg.Unset(key); err != nil {
+		return err
+	}
+	if err := config.Save(path, cfg); err != nil {
+		return fmt.Errorf("could not save config: %w", err)
+	}
+	key = strings.ToLower(strings.TrimSpace(key))
+	if value, err := cfg.Get(key); err == nil && value != "none" {
+		fmt.Fprintf(stdout, "reset %s to its default: %s\n", key, value)
+	} else {
+		fmt.Fprintf(stdout, "removed %s\n", key)
+	}
+	return nil
+}
+
+// configList prints the effective value of every key, includes applied,
+// optionally only those starting with prefix.
+func configList(stdout io.Writer, prefix string, asJSON bool) error {
+	cfg, _, err := config.LoadOrCreate()
+	if err != nil {
+		return err
+	}
+	values := map[string]string{}
+	keys := []string{}
+	for _, key := range config.Keys(cfg) {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		value, err := cfg.Get(key)
+		if err != nil {
+			continue
+		}
+		values[key] = value
+		keys = append(keys, key)
+	}
+	if asJSON {
+		return writeJSON(stdout, values)
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("%w: nothing starts with %q", config.ErrUnknownKey, prefix)
+	}
+	for _, key := range keys {
+		fmt.Fprintf(stdout, "%s = %s\n", key, values[key])
+	}
+	return nil
+}
+
+// configDiff prints the effective settings, includes applied, that differ
+// from the defaults: a TOML fragment that can be pasted into a config file
+// or a bug report, or a key-value map with asJSON.
+func configDiff(stdout io.Writer, asJSON bool) error {
+	cfg, _, err := config.LoadOrCreate()
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		changed, _, err := config.Diff(cfg)
+		if err != nil {
+			return err
+		}
+		return writeJSON(stdout, changed)
+	}
+	fragment, _, err := config.DiffTOML(cfg)
+	if err != nil {
+		return err
+	}
+	if fragment == "" {
+		fragment = "# all settings match the defaults\n"
+	}
+	_, err = io.WriteString(stdout, fragment)
+	return err
+}
+
+// runEditor opens path in the editor and waits for it; tests replace it.
+var runEditor = func(editor []string, path string) error {
+	cmd := exec.Command(editor[0], append(editor[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// configEdit opens config.toml in $VISUAL or $EDITOR and checks the result.
+// An edit that does not validate is offered back to the editor; declining,
+// or having no terminal to ask on, restores the file as it was.
+func configEdit(stdout io.Writer) error {
+	_, path, err := config.LoadFile()
+	if err != nil {
+		return err
+	}
+	original, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read config file: %w", err)
+	}
+	editor := editorCommand()
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		if err := runEditor(editor, path); err != nil {
+			return fmt.Errorf("could not run %s: %w", editor[0], err)
+		}
+		edited, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("could not read config file: %w", err)
+		}
+		if bytes.Equal(edited, original) {
+			fmt.Fprintln(stdout, "Config unchanged.")
+			return nil
+		}
+		problem := config.Validate(edited)
+		if problem == nil {
+			fmt.Fprintf(stdout, "Config saved: %s\n", displayPath(path))
+			return nil
+		}
+		fmt.Fprintf(stdout, "%s has problems:\n", displayPath(path))
+		for _, line := range strings.Split(problem.Error(), "\n") {
+			fmt.Fprintf(stdout, "  %s\n", line)
+		}
+		if isTerminal(os.Stdin) {
+			fmt.Fprint(stdout, "Edit again? [Y/n] ")
+			answer, err := reader.ReadString('\n')
+			if answer = strings.ToLower(strings.TrimSpace(answer)); err == nil && (answer == "" || answer == "y" || answer == "yes") {
+				continue
+			}
+		}
+		if err := os.WriteFile(path, original, 0o600); err != nil {
+			return fmt.Errorf("could not restore config file: %w", err)
+		}
+		return errors.New("edit discarded; the previous config was restored")
+	}
+}
+
+// editorCommand is $VISUAL, else $EDITOR, split into words, else the
+// platform's basic editor.
+func editorCommand() []string {
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if fields := strings.Fields(os.Getenv(name)); len(fields) > 0 {
+			return fields
+		}
+	}
+	if goruntime.GOOS == "windows" {
+		return []string{"notepad"}
+	}
+	return []string{"vi"}
+}
+
+func writeJSON(stdout io.Writer, value any) error {
+	payload, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, string(payload))
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ashwch/ew/internal/config"
+)
+
+func isolateConfig(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, ".local", "state"))
+	_, path, err := config.LoadFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigSubcommandOnlyTakesKnownVerbs(t *testing.T) {
+	if args, ok := configSubcommand([]string{"config", "set", "mode", "yolo"}); !ok || strings.Join(args, " ") != "set mode yolo" {
+		t.Fatalf("expected the set verb, got %v %v", args, ok)
+	}
+	if args, ok := configSubcommand([]string{"diff-config", "--json"}); !ok || strings.Join(args, " ") != "diff --json" {
+		t.Fatalf("expected diff-config to be config diff, got %v %v", args, ok)
+	}
+	for _, args := range [][]string{{"config"}, {"config", "file", "for", "git"}, {"find", "config", "set"}} {
+		if _, ok := configSubcommand(args); ok {
+			t.Fatalf("expected %q to stay a request", args)
+		}
+	}
+}
+
+func TestConfigCommandSetGetUnsetAndList(t *testing.T) {
+	isolateConfig(t)
+	var out bytes.Buffer
+	if code := runConfigCommand([]string{"set", "find.max_results", "3"}, &out); code != 0 {
+		t.Fatalf("set exited %d", code)
+	}
+	out.Reset()
+	if code := runConfigCommand([]string{"get", "find.max_results"}, &out); code != 0 || out.String() != "3\n" {
+		t.Fatalf("expected 3, got %q (exit %d)", out.String(), code)
+	}
+	out.Reset()
+	if code := runConfigCommand([]string{"list", "find."}, &out); code != 0 || !strings.Contains(out.String(), "find.max_results = 3\n") || strings.Contains(out.String(), "mode =") {
+		t.Fatalf("expected only find keys, got %q", out.String())
+	}
+	out.Reset()
+	if code := runConfigCommand([]string{"unset", "find.max_results"}, &out); code != 0 || !strings.Contains(out.String(), "default: 8") {
+		t.Fatalf("expected the default restored, got %q (exit %d)", out.String(), code)
+	}
+
+	if code := runConfigCommand([]string{"set", "mode", "sometimes"}, &out); code != exitUsage {
+		t.Fatalf("expected an invalid value to exit %d, got %d", exitUsage, code)
+	}
+	if code := runConfigCommand([]string{"get", "find.colour"}, &out); code != exitUsage {
+		t.Fatalf("expected an unknown key to exit %d, got %d", exitUsage, code)
+	}
+	if code := runConfigCommand([]string{"set", "mode"}, &out); code != exitUsage {
+		t.Fatalf("expected a missing value to exit %d, got %d", exitUsage, code)
+	}
+}
+
+func TestConfigDiffPrintsOnlyChangedSettings(t *testing.T) {
+	isolateConfig(t)
+	var out bytes.Buffer
+	if code := runConfigCommand([]string{"diff"}, &out); code != 0 || out.String() != "# all settings match the defaults\n" {
+		t.Fatalf("expected no differences, got %q (exit %d)", out.String(), code)
+	}
+	if code := runConfigCommand([]string{"set", "find.max_results", "3"}, &out); code != 0 {
+		t.Fatalf("set exited %d", code)
+	}
+	out.Reset()
+	if code := runConfigCommand([]string{"diff"}, &out); code != 0 || !strings.Contains(out.String(), "max_results = 3") || strings.Contains(out.String(), "mode =") {
+		t.Fatalf("expected only the changed key, got %q (exit %d)", out.String(), code)
+	}
+	out.Reset()
+	if code := runConfigCommand([]string{"diff", "--json"}, &out); code != 0 || !strings.Contains(out.String(), `"max_results": 3`) {
+		t.Fatalf("expected the changed key as JSON, got %q (exit %d)", out.String(), code)
+	}
+}
+
+func TestConfigEditRestoresAnEditThatDoesNotValidate(t *testing.T) {
+	path := isolateConfig(t)
+	original, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("EDITOR", "fake-editor --wait")
+	previous := runEditor
+	t.Cleanup(func() { runEditor = previous })
+	var gotEditor []string
+	edit := func(from, to string) {
+		runEditor = func(editor []string, target string) error {
+			gotEditor = editor
+			data, err := os.ReadFile(target)
+			if err != nil {
+				return err
+			}
+			return os.WriteFile(target, bytes.Replace(data, []byte(from), []byte(to), 1), 0o600)
+		}
+	}
+
+	edit(`mode = 'confirm'`, `mode = 'sometimes'`)
+	var out bytes.Buffer
+	if code := runConfigCommand([]string{"edit"}, &out); code == 0 || !strings.Contains(out.String(), "has problems") {
+		t.Fatalf("expected the invalid edit reported, got %q (exit %d)", out.String(), code)
+	}
+	if strings.Join(gotEditor, " ") != "fake-editor --wait" {
+		t.Fatalf("expected $EDITOR split into words, got %v", gotEditor)
+	}
+	if restored, _ := os.ReadFile(path); !bytes.Equal(restored, original) {
+		t.Fatalf("expected the previous config restored")
+	}
+
+	edit(`mode = 'confirm'`, `mode = 'yolo'`)
+	out.Reset()
+	if code := runConfigCommand([]string{"edit"}, &out); code != 0 || !strings.Contains(out.String(), "Config saved") {
+		t.Fatalf("expected a valid edit kept, got %q (exit %d)", out.String(), code)
+	}
+	if cfg, _, _ := config.LoadFile(); cfg.Mode != "yolo" {
+		t.Fatalf("expected the edit saved, got mode %q", cfg.Mode)
+	}
+}
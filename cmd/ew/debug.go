@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/history"
+	ewrt "github.com/ashwch/ew/internal/runtime"
+	"github.com/ashwch/ew/internal/safety"
+	"github.com/ashwch/ew/internal/ui"
+)
+
+// debugUsage lists the commands of `ew internal debug`, the maintainers'
+// REPL for answering "why did ew do X" without writing a test.
+const debugUsage = `commands:
+  route [--execute] <prompt>     the route ew takes for prompt and why (as --trace-plan)
+  score <query>                  memory and history candidates after find's filters and boosts
+  score <query> => <command>     one command's score for query and which filter keeps or drops it
+  risk <command>                 risk checks, the safety.toml rule, and the outcome in each mode
+  prompt find|fix|explain <text> the task prompt a provider would get (fix: <command> [:: <error>])
+  fix <command>                  the learned and built-in fixes for a failed command
+  help, quit`
+
+// debugSubcommand reports whether args are `ew internal debug [command]`.
+func debugSubcommand(args []string) ([]string, bool) {
+	if len(args) < 2 || args[0] != "internal" || args[1] != "debug" {
+		return nil, false
+	}
+	return args[2:], true
+}
+
+// debugSession holds what every REPL command shares. Nothing it does asks
+// a provider, runs a command, or learns anything.
+type debugSession struct {
+	cfg  config.Config
+	opts options
+	out  io.Writer
+}
+
+// runDebug runs the command in args, or, without one, reads commands from
+// in until quit or end of input. It uses config.toml without project
+// settings.
+func runDebug(args []string, in io.Reader, out io.Writer) int {
+	cfg, _, err := config.LoadFile()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ew: could not load config: %v\n", err)
+		return 1
+	}
+	if release := useStateBackend(cfg); release != nil {
+		defer release()
+	}
+	history.SetSkipSecrets(cfg.History.Secrets == "skip")
+	history.SetViaEW(cfg.History.ViaEW)
+	session := debugSession{cfg: cfg, opts: options{JSON: true, NoCache: true, NoRecord: true}, out: out}
+	useEmbeddings(cfg, session.opts)
+
+	if len(args) > 0 {
+		if err := session.run(strings.Join(args, " ")); err != nil {
+			fmt.Fprintf(os.Stderr, "ew: %v\n", err)
+			return exitUsage
+		}
+		return 0
+	}
+	fmt.Fprintln(out, "ew debug REPL; help lists the commands")
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, "ew> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return 0
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "quit" || line == "exit" {
+			return 0
+		}
+		if err := session.run(line); err != nil {
+			fmt.Fprintf(out, "error: %v\n", err)
+		}
+	}
+}
+
+func (s debugSession) run(line string) error {
+	verb, rest, _ := strings.Cut(strings.TrimSpace(line), " ")
+	rest = strings.TrimSpace(rest)
+	switch verb {
+	case "":
+		return nil
+	case "help", "?":
+		fmt.Fprintln(s.out, debugUsage)
+		return nil
+	case "route":
+		opts := s.opts
+		if after, ok := strings.CutPrefix(rest, "--execute"); ok {
+			opts.Execute = true
+			rest = strings.TrimSpace(after)
+		}
+		return s.print(buildTracePlan(rest, s.cfg, opts))
+	case "score":
+		if rest == "" {
+			return fmt.Errorf("score needs a query")
+		}
+		if query, command, ok := strings.Cut(rest, "=>"); ok {
+			return s.print(debugScoreCommand(s.cfg, strings.TrimSpace(query), strings.TrimSpace(command)))
+		}
+		plan := traceFind(tracePlan{Query: rest}, rest, s.cfg, s.opts)
+		return s.print(map[string]any{"memory": plan.Memory, "memory_answers": plan.MemoryAnswers, "history": plan.History, "ai": plan.AI})
+	case "risk":
+		if rest == "" {
+			return fmt.Errorf("risk needs a command")
+		}
+		return s.print(debugRiskOf(rest, s.cfg))
+	case "prompt":
+		kind, text, _ := strings.Cut(rest, " ")
+		prompt, err := s.prompt(kind, strings.TrimSpace(text))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(s.out, prompt)
+		fmt.Fprintf(s.out, "(%d bytes, about %d tokens)\n", len(prompt), (len(prompt)+promptBytesPerToken-1)/promptBytesPerToken)
+		return nil
+	case "fix":
+		if rest == "" {
+			return fmt.Errorf("fix needs a failed command")
+		}
+		learned, learnedReason := learnedFix(rest, false)
+		rule, ruleReason := ewrt.SuggestFix(rest)
+		return s.print(map[string]string{"learned": learned, "learned_reason": learnedReason, "rule": rule, "rule_reason": ruleReason})
+	}
+	return fmt.Errorf("unknown command %q; help lists them", verb)
+}
+
+func (s debugSession) prompt(kind, text string) (string, error) {
+	if text == "" {
+		return "", fmt.Errorf("prompt %s needs some text", kind)
+	}
+	switch kind {
+	case "find":
+		plan := traceFind(tracePlan{Query: text}, text, s.cfg, s.opts)
+		return buildFindPrompt(s.cfg, text, plan.History), nil
+	case "fix":
+		command, errorText, _ := strings.Cut(text, "::")
+		cwd, _ := os.Getwd()
+		return buildFixPrompt(s.cfg, strings.TrimSpace(command), 1, cwd, strings.TrimSpace(errorText), "", nil), nil
+	case "explain":
+		return buildExplainPrompt(s.cfg, text), nil
+	}
+	return "", fmt.Errorf("prompt takes find, fix, or explain, not %q", kind)
+}
+
+func (s debugSession) print(value any) error {
+	payload, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode result: %w", err)
+	}
+	fmt.Fprintln(s.out, string(payload))
+	return nil
+}
+
+// debugScore is how find judges one history command for a query.
+type debugScore struct {
+	Query        string   `json:"query"`
+	Command      string   `json:"command"`
+	QueryTokens  []string `json:"query_tokens"`
+	TextScore    float64  `json:"text_score"`
+	MinScore     float64  `json:"min_score"`
+	ReadOnly     bool     `json:"read_only_query"`
+	Mutating     bool     `json:"mutating"`
+	HighRisk     bool     `json:"high_risk"`
+	Destructive  bool     `json:"destructive"`
+	AllowsRisk   bool     `json:"query_allows_high_risk"`
+	AllowsDelete bool     `json:"query_allows_destructive"`
+	Kept         bool     `json:"kept"`
+}
+
+func debugScoreCommand(cfg config.Config, query, command string) debugScore {
+	score := debugScore{
+		Query:        query,
+		Command:      command,
+		QueryTokens:  queryRelevanceTokens(query),
+		TextScore:    history.ScoreCommand(query, command),
+		MinScore:     minimumHistoryMatchScore(query),
+		ReadOnly:     queryPrefersReadOnly(query),
+		Mutating:     isMutatingCommand(command),
+		HighRisk:     ewrt.HighRisk(command),
+		Destructive:  isDestructiveCommand(command),
+		AllowsRisk:   queryAllowsHighRisk(query),
+		AllowsDelete: queryAllowsDestructive(query),
+	}
+	score.Kept = len(filterFindMatches(cfg, query, []history.Match{{Command: command, Score: score.TextScore}})) > 0
+	return score
+}
+
+// debugRisk is what the execution policy sees in a command.
+type debugRisk struct {
+	Command     string                 `json:"command"`
+	HighRisk    bool                   `json:"high_risk"`
+	Destructive bool                   `json:"destructive"`
+	Mutating    bool                   `json:"mutating"`
+	Shape       ewrt.CommandShape      `json:"shape"`
+	OverLimits  bool                   `json:"over_auto_limits"`
+	PolicyRule  *safety.Rule           `json:"policy_rule,omitempty"`
+	Target      string                 `json:"target,omitempty"`
+	Modes       map[string]debugPolicy `json:"modes"`
+	// Reasons are why the policy rates the command as it does.
+	Reasons []ui.RiskReason `json:"reasons,omitempty"`
+}
+
+// debugPolicy is the mode and risk applyExecutionRiskPolicy settles on,
+// for a low-risk hint.
+type debugPolicy struct {
+	Mode string `json:"mode"`
+	Risk string `json:"risk"`
+}
+
+func debugRiskOf(command string, cfg config.Config) debugRisk {
+	result := debugRisk{
+		Command:     command,
+		HighRisk:    ewrt.HighRisk(command),
+		Destructive: isDestructiveCommand(command),
+		Mutating:    isMutatingCommand(command),
+		Shape:       ewrt.MeasureCommand(command),
+		OverLimits:  exceedsAutoExecutionLimits(cfg, command),
+		Target:      cfg.Execution.Target,
+		Modes:       map[string]debugPolicy{},
+	}
+	if rule, ok := safetyPolicyRule(command); ok {
+		result.PolicyRule = &rule
+	}
+	for _, mode := range []string{"suggest", "confirm", "yolo"} {
+		effective, risk := applyExecutionRiskPolicy(cfg, mode, command, "low")
+		result.Modes[mode] = debugPolicy{Mode: effective, Risk: risk.Level}
+		result.Reasons = risk.Reasons
+	}
+	return result
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDebugSubcommandIsHiddenUnderInternal(t *testing.T) {
+	if args, ok := debugSubcommand([]string{"internal", "debug", "risk", "ls"}); !ok || strings.Join(args, " ") != "risk ls" {
+		t.Fatalf("expected the debug command, got %q %v", args, ok)
+	}
+	for _, args := range [][]string{{"debug"}, {"internal", "hook-record"}} {
+		if _, ok := debugSubcommand(args); ok {
+			t.Fatalf("expected %q not to open the REPL", args)
+		}
+	}
+}
+
+func TestDebugREPLPrintsInternals(t *testing.T) {
+	isolateConfig(t)
+	var out bytes.Buffer
+	in := strings.NewReader("risk rm -rf build\nscore show git log => git log --oneline\nbogus\nquit\nrisk ls\n")
+	if code := runDebug(nil, in, &out); code != 0 {
+		t.Fatalf("expected exit 0, got %d", code)
+	}
+	text := out.String()
+	if !strings.Contains(text, `"destructive": true`) || !strings.Contains(text, `"yolo": {`) {
+		t.Fatalf("expected the risk breakdown, got %s", text)
+	}
+	if !strings.Contains(text, `"text_score"`) || !strings.Contains(text, `"kept": true`) {
+		t.Fatalf("expected the score breakdown, got %s", text)
+	}
+	if !strings.Contains(text, `error: unknown command "bogus"`) || strings.Contains(text, `"command": "ls"`) {
+		t.Fatalf("expected an error line and nothing after quit, got %s", text)
+	}
+
+	out.Reset()
+	if code := runDebug([]string{"risk", "git", "status"}, nil, &out); code != 0 {
+		t.Fatalf("expected a one-shot command to succeed, got %d", code)
+	}
+	var risk debugRisk
+	if err := json.Unmarshal(out.Bytes(), &risk); err != nil || risk.Command != "git status" || risk.Modes["yolo"].Mode != "yolo" {
+		t.Fatalf("expected git status to run in yolo, got %+v %v", risk, err)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ashwch/ew/internal/appdirs"
+	"github.com/ashwch/ew/internal/state"
+)
+
+// stateReadOnly is set when the state directory cannot be written. ew then
+// keeps memory, sessions, and other state in memory for the run instead of
+// failing or warning on every save.
+var stateReadOnly bool
+
+// checkWritableDirs looks once, at startup, for a config or state directory
+// that cannot be written, and says so in a single line. The config dir
+// only matters while there is no config file to read.
+func checkWritableDirs(opts options) {
+	stateReadOnly = false
+	notes := []string{}
+	if cfgPath, err := appdirs.ConfigFilePath(); err == nil {
+		if _, statErr := os.Stat(cfgPath); statErr != nil {
+			if err := appdirs.CheckWritable(filepath.Dir(cfgPath)); err != nil {
+				notes = append(notes, fmt.Sprintf("config not saved (%v); using defaults", err))
+			}
+		}
+	}
+	if dir, err := appdirs.StateDir(); err == nil {
+		if err := appdirs.CheckWritable(dir); err != nil {
+			stateReadOnly = true
+			notes = append(notes, fmt.Sprintf("state not saved (%v); memory, sessions, and undo last for this run only", err))
+		}
+	}
+	for _, note := range notes {
+		skippedCapabilities = append(skippedCapabilities, note)
+		if !opts.JSON && !opts.Quiet {
+			fmt.Fprintf(os.Stderr, "ew: %s\n", note)
+		}
+	}
+}
+
+// useVolatileState keeps state in memory over what the files backend can
+// still read.
+func useVolatileState() func() {
+	dir, err := appdirs.StateDir()
+	var base state.Backend
+	if err == nil {
+		base = state.NewFiles(dir)
+	}
+	state.Use(state.NewVolatile(base))
+	return func() { state.Use(nil) }
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ashwch/ew/internal/memory"
+	"github.com/ashwch/ew/internal/state"
+)
+
+func TestReadOnlyStateKeepsMemoryForTheRun(t *testing.T) {
+	resetSkippedCapabilities(t)
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	blocker := filepath.Join(home, ".local")
+	t.Setenv("XDG_STATE_HOME", filepath.Join(blocker, "state"))
+	if err := os.WriteFile(blocker, []byte("not a dir"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { stateReadOnly = false })
+
+	checkWritableDirs(options{JSON: true})
+	if !stateReadOnly {
+		t.Fatalf("expected the state dir to be reported read-only")
+	}
+	if len(skippedCapabilities) != 1 || !strings.Contains(skippedCapabilities[0], blocker) {
+		t.Fatalf("expected one note naming %s, got %v", blocker, skippedCapabilities)
+	}
+
+	closeState := useVolatileState()
+	defer closeState()
+	store, _, err := memory.Load()
+	if err != nil {
+		t.Fatalf("memory load failed: %v", err)
+	}
+	if err := store.Learn("list ports", "lsof -i -P", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := memory.Save(store); err != nil {
+		t.Fatalf("expected save to succeed in memory, got %v", err)
+	}
+	reloaded, _, err := memory.Load()
+	if err != nil || len(reloaded.Entries) != 1 {
+		t.Fatalf("expected the entry to last for the run, got %+v (%v)", reloaded.Entries, err)
+	}
+	backend, err := state.Current()
+	if err != nil || !strings.HasSuffix(backend.Location("memory.json"), "(in memory)") {
+		t.Fatalf("expected an in-memory location, got %v (%v)", backend, err)
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ashwch/ew/internal/config"
+	ewrt "github.com/ashwch/ew/internal/runtime"
+)
+
+// editedReason notes that the user rewrote original before using it.
+func editedReason(reason, original string) string {
+	return strings.TrimSpace(reason + " (edited from: " + strings.TrimSpace(original) + ")")
+}
+
+// checkEditedCommand puts a command the user rewrote in the picker through
+// the checks a suggestion gets: it is normalized, and its risk is weighed
+// again since the source's rating was for the command before the edit. ok is
+// false when the rewrite cannot be used; the reason has been printed.
+func checkEditedCommand(command string, cfg config.Config) (string, string, bool) {
+	normalized, err := ewrt.NormalizeCommand(command)
+	if err != nil {
+		fmt.Printf("command rejected: %v\n", err)
+		return "", "", false
+	}
+	_, risk := applyExecutionRiskPolicy(cfg, cfg.Mode, normalized, "")
+	return normalized, risk.Level, true
+}
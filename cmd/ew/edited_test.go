@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ashwch/ew/internal/config"
+)
+
+func TestCheckEditedCommandNormalizesAndWeighsTheRewrite(t *testing.T) {
+	command, risk, ok := checkEditedCommand("$ git log -5", config.Default())
+	if !ok || command != "git log -5" || risk != "low" {
+		t.Fatalf("expected the prompt stripped and low risk, got %q %q %v", command, risk, ok)
+	}
+	if _, risk, _ := checkEditedCommand("rm -rf ./build", config.Default()); risk == "low" {
+		t.Fatalf("expected the rewrite's own risk, got %q", risk)
+	}
+	if _, _, ok := checkEditedCommand("  ", config.Default()); ok {
+		t.Fatalf("expected an empty rewrite to be rejected")
+	}
+	if got := editedReason("from memory", "git log"); got != "from memory (edited from: git log)" {
+		t.Fatalf("unexpected reason %q", got)
+	}
+	if !shouldPersistFindSuggestion("show recent commits", "git log -5", "edited", "low") {
+		t.Fatalf("expected an edited memory pick to be learned under the query")
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/history"
+	"github.com/ashwch/ew/internal/provider"
+)
+
+const (
+	exitFailure = 1
+	// exitUsage covers bad flags and config changes that name an unknown
+	// key or an invalid value: the command line needs fixing, not the
+	// machine.
+	exitUsage = 2
+)
+
+// errorHint says what to do about an error from an internal package; it is
+// empty for errors without a known remedy.
+func errorHint(err error) string {
+	var invalid *config.InvalidValueError
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, config.ErrUnknownKey):
+		return "run ew config list to see every config key"
+	case errors.As(err, &invalid):
+		return "run ew config get " + invalid.Key + " to see its current value"
+	case errors.Is(err, history.ErrNoHistory):
+		return "no shell history was found; run ew --setup-hooks so commands get recorded"
+	case errors.Is(err, provider.ErrTimedOut):
+		return "raise ai.timeout_seconds, or providers.<name>.timeout_seconds for one provider; ew --doctor checks that each provider is installed"
+	case errors.Is(err, provider.ErrNoneHealthy):
+		return "install the codex or claude CLI, or run ew --doctor to see which provider is failing"
+	}
+	return ""
+}
+
+// errorSuggestions is err's message followed by its hint, for response
+// payloads.
+func errorSuggestions(err error) []string {
+	suggestions := []string{err.Error()}
+	if hint := errorHint(err); hint != "" {
+		suggestions = append(suggestions, hint)
+	}
+	return suggestions
+}
+
+func exitCodeFor(err error) int {
+	var invalid *config.InvalidValueError
+	if errors.Is(err, config.ErrUnknownKey) || errors.As(err, &invalid) {
+		return exitUsage
+	}
+	return exitFailure
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/history"
+	"github.com/ashwch/ew/internal/provider"
+)
+
+func TestErrorHintsAndExitCodesFollowTypedErrors(t *testing.T) {
+	cfg := config.Default()
+	unknown := cfg.Set("no.such_key", "x")
+	invalid := cfg.Set("find.max_results", "-1")
+
+	cases := []struct {
+		name string
+		err  error
+		hint string
+		code int
+	}{
+		{"unknown key", unknown, "ew config list", exitUsage},
+		{"invalid value", invalid, "ew config get find.max_results", exitUsage},
+		{"no history", fmt.Errorf("could not search: %w", history.ErrNoHistory), "ew --setup-hooks", exitFailure},
+		{"no provider", fmt.Errorf("%w: none enabled", provider.ErrNoneHealthy), "ew --doctor", exitFailure},
+		{"timed out", fmt.Errorf("%w after 1m30s", provider.ErrTimedOut), "ai.timeout_seconds", exitFailure},
+		{"other", fmt.Errorf("disk full"), "", exitFailure},
+	}
+	for _, tc := range cases {
+		hint := errorHint(tc.err)
+		if tc.hint == "" && hint != "" || !strings.Contains(hint, tc.hint) {
+			t.Fatalf("%s: unexpected hint %q", tc.name, hint)
+		}
+		if got := exitCodeFor(tc.err); got != tc.code {
+			t.Fatalf("%s: expected exit %d, got %d", tc.name, tc.code, got)
+		}
+	}
+
+	suggestions := errorSuggestions(fmt.Errorf("%w: none enabled", provider.ErrNoneHealthy))
+	if len(suggestions) != 2 || suggestions[0] != "no healthy provider: none enabled" {
+		t.Fatalf("expected message then hint, got %v", suggestions)
+	}
+}
@@ -43,7 +43,7 @@ func TestExecuteSuggestedJSONConfirmDoesNotPrompt(t *testing.T) {
 
 	var outcome executionOutcome
 	out := captureStdout(t, func() {
-		outcome = executeSuggested("echo hi", "test reason", "low", cfg, opts, router.IntentRun)
+		outcome = executeSuggested("echo hi", "", "test reason", "low", cfg, opts, router.IntentRun)
 	})
 
 	if strings.Contains(out, "Run this command? [y/N]:") {
@@ -64,3 +64,59 @@ func TestExecuteSuggestedJSONConfirmDoesNotPrompt(t *testing.T) {
 		t.Fatalf("expected no execution outcome, got %+v", outcome)
 	}
 }
+
+func TestExecuteSuggestedRefusesRedactedCommand(t *testing.T) {
+	cfg := config.Default()
+	opts := options{JSON: true, Yes: true, Mode: "yolo"}
+
+	var outcome executionOutcome
+	out := captureStdout(t, func() {
+		outcome = executeSuggested("curl -H 'Authorization: Bearer <redacted>' https://api.example.com", "", "selected from history", "low", cfg, opts, router.IntentRun)
+	})
+
+	var payload response
+	if err := json.Unmarshal([]byte(out), &payload); err != nil {
+		t.Fatalf("expected valid json output, got error %v with payload %q", err, out)
+	}
+	if outcome.Executed || payload.Executed || !strings.Contains(payload.Message, "<redacted>") {
+		t.Fatalf("expected a redacted command to be refused, got %+v %+v", outcome, payload)
+	}
+}
+
+func TestSaferSuggestionIsConfirmedWithTheRawCommandAsAlternative(t *testing.T) {
+	previous := runtimeSystemTools
+	runtimeSystemTools = []string{"trash"}
+	t.Cleanup(func() { runtimeSystemTools = previous })
+
+	cfg := config.Default()
+	command, alternative := saferSuggestion(cfg, "$ rm -rf build")
+	if command != "trash build" || alternative != "rm -rf build" {
+		t.Fatalf("expected the trash rewrite with rm as the alternative, got %q / %q", command, alternative)
+	}
+	out := captureStdout(t, func() {
+		executeSuggested(command, alternative, "selected from history", "", cfg, options{JSON: true, DryRun: true}, router.IntentRun)
+	})
+	var payload response
+	if err := json.Unmarshal([]byte(out), &payload); err != nil {
+		t.Fatalf("invalid JSON %q: %v", out, err)
+	}
+	if payload.Command != "trash build" || payload.Alternative != "rm -rf build" || payload.Message != "selected from history" {
+		t.Fatalf("expected the rewrite shown with its alternative, got %+v", payload)
+	}
+
+	out = captureStdout(t, func() {
+		executeSuggested("rm -rf build", "", "selected from history", "", cfg, options{JSON: true, DryRun: true}, router.IntentRun)
+	})
+	var raw response
+	if err := json.Unmarshal([]byte(out), &raw); err != nil {
+		t.Fatalf("invalid JSON %q: %v", out, err)
+	}
+	if raw.Command != "rm -rf build" || raw.Alternative != "" {
+		t.Fatalf("expected execution not to rewrite on its own, got %+v", raw)
+	}
+
+	cfg.Execution.Target = "ssh:deploy@web1"
+	if command, alternative := saferSuggestion(cfg, "rm -rf build"); command != "rm -rf build" || alternative != "" {
+		t.Fatalf("expected remote targets left alone, got %q / %q", command, alternative)
+	}
+}
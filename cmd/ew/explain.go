@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/knowledge"
+	"github.com/ashwch/ew/internal/provider"
+	"github.com/ashwch/ew/internal/router"
+	"github.com/ashwch/ew/internal/ui"
+)
+
+// maxExplainPartWidth caps the part column; longer parts get a line of
+// their own above the meaning.
+const maxExplainPartWidth = 24
+
+var explainPromptPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?is)^explain\s+(.+)$`),
+	regexp.MustCompile(`(?is)^what\s+does\s+(.+?)\s+do\??$`),
+}
+
+// explainLookPath is swapped out in tests.
+var explainLookPath = exec.LookPath
+
+// parseExplainPrompt returns the command an "explain tar -xzvf a.tgz" or
+// "what does `git rebase -i` do" prompt asks about. It only takes commands
+// quoted in backticks or starting with a program on PATH, so "explain why
+// the build is slow" is still a find.
+func parseExplainPrompt(prompt string) (string, bool) {
+	for _, pattern := range explainPromptPatterns {
+		m := pattern.FindStringSubmatch(strings.TrimSpace(prompt))
+		if m == nil {
+			continue
+		}
+		subject := strings.TrimSpace(m[1])
+		if unquoted, ok := strings.CutPrefix(subject, "`"); ok && strings.HasSuffix(unquoted, "`") {
+			if command := strings.TrimSpace(strings.TrimSuffix(unquoted, "`")); command != "" {
+				return command, true
+			}
+			continue
+		}
+		if fields := strings.Fields(subject); len(fields) > 0 {
+			if _, err := explainLookPath(fields[0]); err == nil {
+				return subject, true
+			}
+		}
+	}
+	return "", false
+}
+
+// buildExplainPrompt asks for the command behind query, which may already
+// be a command, and a breakdown of every part of it.
+func buildExplainPrompt(cfg config.Config, query string) string {
+	base := fmt.Sprintf("Return only JSON matching schema. Explain this shell command part by part: %q. "+
+		"If it is a plain-English request rather than a command, first pick the best command for it. "+
+		"Set command to the command explained, unchanged if one was given. "+
+		"Fill explanation with one entry per flag, argument, operator, or redirection in order, each with the exact text as part and what it does here as meaning. "+
+		"Use reason for anything the user should know before running it, such as what it changes. Never set action to run.", query)
+	return wrapWithSelfKnowledge(cfg, knowledge.ScopeFind, base+tldrGrounding(query))
+}
+
+// handleExplain answers --explain. It never runs anything.
+func handleExplain(query string, cfg config.Config, opts options) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		payload := response{Intent: string(router.IntentExplain), Message: "add a command or request to explain, e.g. ew --explain tar -xzvf backup.tgz"}
+		printResponse(payload, opts.JSON)
+		return
+	}
+	if !providerAvailability(cfg, opts).allows(capabilityAIExplain, opts) {
+		payload := response{Intent: string(router.IntentExplain), Message: "explaining a command needs a provider"}
+		printResponse(payload, opts.JSON)
+		return
+	}
+
+	resolution, providerName, err := resolveProviderWithLoader(invocationCtx, cfg, opts, provider.IntentExplain, buildExplainPrompt(cfg, query), "breaking the command down")
+	if err != nil {
+		payload := response{Intent: string(router.IntentExplain), Message: fmt.Sprintf("could not explain: %v", err)}
+		printResponse(payload, opts.JSON)
+		return
+	}
+	command := strings.TrimSpace(resolution.Command)
+	if command == "" || len(resolution.Explanation) == 0 {
+		payload := response{Intent: string(router.IntentExplain), Message: "the provider returned no explanation", Command: command}
+		printResponse(payload, opts.JSON)
+		return
+	}
+
+	if opts.JSON {
+		noteSessionSuggestion(command, resolution.Reason, providerName)
+		printResponse(response{
+			Intent:      string(router.IntentExplain),
+			Message:     resolution.Reason,
+			Command:     command,
+			Risk:        normalizeRiskHint(resolution.Risk),
+			Explanation: resolution.Explanation,
+		}, true)
+		return
+	}
+
+	backend := effectiveUIBackend(cfg, opts)
+	if canUseInteractiveUI(opts, backend) {
+		used, uiErr := ui.ShowExplanation(backend, explanationPages(command, resolution))
+		if uiErr != nil {
+			fmt.Fprintf(os.Stderr, "ew: explain ui failed (%v); printing it instead\n", uiErr)
+		}
+		if used {
+			writeSuggestedCommandBlock(command, reasonForDisplay(resolution.Reason, opts), providerName, "", cfg, opts)
+			return
+		}
+	}
+	writeSuggestedCommandBlock(command, reasonForDisplay(resolution.Reason, opts), providerName, "", cfg, opts)
+	if opts.Quiet {
+		return
+	}
+	fmt.Println("explanation:")
+	for _, line := range explanationLines(resolution.Explanation, outputWidth()) {
+		fmt.Println(line)
+	}
+}
+
+// explanationLines lays parts out in a column with their meanings wrapped
+// beside them, indented under an "explanation:" heading.
+func explanationLines(parts []provider.ExplainPart, width int) []string {
+	column := 0
+	for _, part := range parts {
+		if n := len([]rune(strings.TrimSpace(part.Part))); n > column && n <= maxExplainPartWidth {
+			column = n
+		}
+	}
+	blank := "  " + strings.Repeat(" ", column) + "  "
+	var lines []string
+	for _, part := range parts {
+		text := ui.ASCII(strings.TrimSpace(part.Part))
+		meaning := wrapLabeled(blank, part.Meaning, width)
+		if len(meaning) == 0 {
+			meaning = []string{blank}
+		}
+		if len([]rune(text)) > column {
+			lines = append(lines, "  "+text)
+			lines = append(lines, meaning...)
+			continue
+		}
+		meaning[0] = fmt.Sprintf("  %-*s  ", column, text) + strings.TrimPrefix(meaning[0], blank)
+		lines = append(lines, meaning...)
+	}
+	return lines
+}
+
+// explanationPages is the breakdown as pager pages: the parts first, then
+// the provider's notes when it has any.
+func explanationPages(command string, resolution provider.Resolution) []ui.ReplayStep {
+	pages := []ui.ReplayStep{{Title: command, Lines: explanationLines(resolution.Explanation, outputWidth())}}
+	if reason := strings.TrimSpace(resolution.Reason); reason != "" {
+		pages = append(pages, ui.ReplayStep{
+			Title: "before you run it",
+			Lines: append(wrapLabeled("  ", reason, outputWidth()), "  risk: "+normalizeRiskHint(resolution.Risk)),
+		})
+	}
+	return pages
+}
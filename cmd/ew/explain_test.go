@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/provider"
+)
+
+func TestParseExplainPrompt(t *testing.T) {
+	original := explainLookPath
+	t.Cleanup(func() { explainLookPath = original })
+	explainLookPath = func(name string) (string, error) {
+		if name == "tar" || name == "git" {
+			return "/usr/bin/" + name, nil
+		}
+		return "", errors.New("not found")
+	}
+
+	cases := []struct {
+		prompt string
+		want   string
+		ok     bool
+	}{
+		{"explain tar -xzvf backup.tgz", "tar -xzvf backup.tgz", true},
+		{"Explain `kubectl get pods -A`", "kubectl get pods -A", true},
+		{"what does git rebase -i HEAD~3 do?", "git rebase -i HEAD~3", true},
+		{"explain why the build is slow", "", false},
+		{"explain", "", false},
+		{"tar -xzvf backup.tgz", "", false},
+	}
+	for _, tc := range cases {
+		got, ok := parseExplainPrompt(tc.prompt)
+		if got != tc.want || ok != tc.ok {
+			t.Fatalf("parseExplainPrompt(%q) = %q %v, want %q %v", tc.prompt, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestExplanationLinesAlignsAndWraps(t *testing.T) {
+	parts := []provider.ExplainPart{
+		{Part: "tar", Meaning: "archive tool"},
+		{Part: "-xzvf", Meaning: "extract, gunzip, list each file, read from the named file"},
+		{Part: "--exclude=node_modules/very/long/path", Meaning: "skip that path"},
+	}
+	lines := explanationLines(parts, 50)
+	if lines[0] != "  tar    archive tool" {
+		t.Fatalf("unexpected first line %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "  -xzvf  extract,") || !strings.HasPrefix(lines[2], "         ") {
+		t.Fatalf("expected the meaning to wrap under its column, got %q", lines)
+	}
+	last := lines[len(lines)-2:]
+	if last[0] != "  --exclude=node_modules/very/long/path" || strings.TrimSpace(last[1]) != "skip that path" {
+		t.Fatalf("expected a long part on its own line, got %q", last)
+	}
+}
+
+func TestParseArgsRejectsExplainWithExecute(t *testing.T) {
+	if _, _, err := parseArgs([]string{"--explain", "--execute", "tar", "-x"}); err == nil {
+		t.Fatalf("expected --explain --execute to fail")
+	}
+	opts, prompt, err := parseArgs([]string{"--explain", "tar", "-x"})
+	if err != nil || !opts.Explain || prompt != "tar -x" {
+		t.Fatalf("unexpected parse result %+v %q %v", opts, prompt, err)
+	}
+}
+
+func TestHandleExplainJSONCarriesBreakdown(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script test is not portable on windows")
+	}
+	resetSkippedCapabilities(t)
+	original := healthyProviders
+	t.Cleanup(func() { healthyProviders = original })
+	healthyProviders = func(config.Config, string) []string { return []string{"stub"} }
+
+	script := filepath.Join(t.TempDir(), "provider.sh")
+	body := `#!/bin/sh
+echo '{"action":"run","command":"tar -xzf a.tgz","reason":"writes files into the current directory","risk":"low","confidence":0.9,"needs_confirmation":false,"explanation":[{"part":"tar","meaning":"archive tool"},{"part":"-xzf","meaning":"extract a gzip archive"},{"part":"a.tgz","meaning":"the archive"}]}'
+`
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatalf("write script failed: %v", err)
+	}
+	enabled := true
+	cfg := config.Default()
+	cfg.Providers = map[string]config.ProviderConfig{
+		"stub": {Type: "command", Command: script, Model: "stub-model", Enabled: &enabled, Args: []string{"{prompt}"}},
+	}
+
+	out := captureStdout(t, func() {
+		handleExplain("tar -xzf a.tgz", cfg, options{JSON: true, Provider: "stub"})
+	})
+	var payload response
+	if err := json.Unmarshal([]byte(out), &payload); err != nil {
+		t.Fatalf("expected JSON, got %q: %v", out, err)
+	}
+	if payload.Intent != "explain" || payload.Command != "tar -xzf a.tgz" || payload.Executed {
+		t.Fatalf("unexpected payload %+v", payload)
+	}
+	if len(payload.Explanation) != 3 || payload.Explanation[1].Part != "-xzf" {
+		t.Fatalf("expected the breakdown in the payload, got %+v", payload.Explanation)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/knowledge"
+	"github.com/ashwch/ew/internal/provider"
+	"github.com/ashwch/ew/internal/ui"
+)
+
+// compareFindCandidate lets the find picker ask the provider how the
+// highlighted candidate differs from the recommended command. It is nil,
+// and the picker offers no compare key, when no provider can answer.
+func compareFindCandidate(query, recommended string, cfg config.Config, opts options) ui.CompareFunc {
+	if providerAvailability(cfg, opts).reason != "" {
+		return nil
+	}
+	return func(ctx context.Context, candidate string) (string, error) {
+		resolution, _, err := askProvider(ctx, cfg, opts, provider.IntentFind, buildComparePrompt(cfg, query, recommended, candidate))
+		if err != nil {
+			return "", err
+		}
+		return resolution.Reason, nil
+	}
+}
+
+// buildComparePrompt asks for a short difference between two commands that
+// both look like answers to query.
+func buildComparePrompt(cfg config.Config, query, recommended, candidate string) string {
+	base := fmt.Sprintf("Return only JSON matching schema. The user asked for: %q. Two commands look like answers. "+
+		"Recommended: %q. Candidate: %q. "+
+		"In reason, say in at most two short sentences what the candidate does differently and which of the two fits the request better. "+
+		"Set command to that command, unchanged. Set action to suggest.",
+		strings.TrimSpace(query), recommended, candidate)
+	return wrapWithSelfKnowledge(cfg, knowledge.ScopeFind, base)
+}
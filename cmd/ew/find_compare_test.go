@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ashwch/ew/internal/config"
+)
+
+func TestCompareFindCandidateNeedsAProvider(t *testing.T) {
+	resetSkippedCapabilities(t)
+	original := healthyProviders
+	t.Cleanup(func() { healthyProviders = original })
+	healthyProviders = func(config.Config, string) []string { return nil }
+
+	if compareFindCandidate("show commits", "git log --oneline", config.Default(), options{}) != nil {
+		t.Fatalf("expected no compare key without a healthy provider")
+	}
+	if len(skippedCapabilities) != 0 {
+		t.Fatalf("expected the missing compare key not to be announced, got %v", skippedCapabilities)
+	}
+	healthyProviders = func(config.Config, string) []string { return []string{"stub"} }
+	if compareFindCandidate("show commits", "git log --oneline", config.Default(), options{Offline: true}) != nil {
+		t.Fatalf("expected no compare key offline")
+	}
+	if compareFindCandidate("show commits", "git log --oneline", config.Default(), options{}) == nil {
+		t.Fatalf("expected a compare key with a provider")
+	}
+}
+
+func TestBuildComparePromptNamesBothCommands(t *testing.T) {
+	prompt := buildComparePrompt(config.Default(), "show commits", "git log --oneline", "git log --graph")
+	for _, want := range []string{`"show commits"`, `Recommended: "git log --oneline"`, `Candidate: "git log --graph"`} {
+		if !strings.Contains(prompt, want) {
+			t.Fatalf("expected %s in the prompt", want)
+		}
+	}
+}
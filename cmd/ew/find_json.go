@@ -0,0 +1,254 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/history"
+	"github.com/ashwch/ew/internal/memory"
+	"github.com/ashwch/ew/internal/provider"
+	"github.com/ashwch/ew/internal/router"
+)
+
+// Section statuses in the --json find payload.
+const (
+	sectionOK      = "ok"
+	sectionEmpty   = "empty"
+	sectionSkipped = "skipped"
+	sectionFailed  = "failed"
+)
+
+const filteredProviderNote = "provider suggestion was filtered as destructive for a non-destructive query"
+
+// findSources is every answer a --json find considered, so a script can
+// apply its own policy instead of taking the one ew picked. Selected names
+// the section the top-level command came from.
+type findSources struct {
+	Selected string      `json:"selected,omitempty"`
+	Memory   findSection `json:"memory"`
+	History  findSection `json:"history"`
+	AI       findSection `json:"ai"`
+}
+
+type findSection struct {
+	Status     string          `json:"status"`
+	Note       string          `json:"note,omitempty"`
+	Candidates []findCandidate `json:"candidates"`
+	// NextOffset is the --offset of the next page of history matches; it
+	// is omitted on the last page.
+	NextOffset int `json:"next_offset,omitempty"`
+}
+
+// findCandidate is one ranked answer. Score is the section's own ranking
+// score, so scores are only comparable within a section.
+type findCandidate struct {
+	Rank       int     `json:"rank"`
+	Command    string  `json:"command"`
+	Score      float64 `json:"score,omitempty"`
+	Source     string  `json:"source,omitempty"`
+	Reason     string  `json:"reason,omitempty"`
+	Risk       string  `json:"risk,omitempty"`
+	Confidence float64 `json:"confidence,omitempty"`
+	// StatedConfidence is the provider's own confidence, when calibration
+	// changed it.
+	StatedConfidence float64            `json:"stated_confidence,omitempty"`
+	Uses             int                `json:"uses,omitempty"`
+	Signals          map[string]float64 `json:"signals,omitempty"`
+	Rejected         bool               `json:"rejected,omitempty"`
+	Model            string             `json:"model,omitempty"`
+	// Reranked says which history match a provider's pick displaced, as in
+	// "AI promoted #3 over #1".
+	Reranked string `json:"reranked,omitempty"`
+}
+
+func memorySection(query string, matches []memory.Match) findSection {
+	section := findSection{Status: sectionEmpty, Candidates: []findCandidate{}}
+	for _, match := range matches {
+		if !commandAllowedForQuery(query, match.Command) {
+			continue
+		}
+		section.Candidates = append(section.Candidates, findCandidate{
+			Rank:     len(section.Candidates) + 1,
+			Command:  match.Command,
+			Score:    match.Score,
+			Source:   "memory",
+			Reason:   fmt.Sprintf("learned from memory for %q (uses: %d)", match.Query, match.Uses),
+			Uses:     match.Uses,
+			Rejected: match.Rejected,
+		})
+	}
+	if len(section.Candidates) > 0 {
+		section.Status = sectionOK
+	}
+	return section
+}
+
+func historySection(matches []history.Match, err error) findSection {
+	section := findSection{Status: sectionEmpty, Candidates: []findCandidate{}}
+	switch {
+	case errors.Is(err, history.ErrNoHistory):
+		section.Note = errorHint(err)
+	case err != nil:
+		section.Status = sectionFailed
+		section.Note = err.Error()
+		return section
+	}
+	for idx, match := range matches {
+		section.Candidates = append(section.Candidates, findCandidate{
+			Rank:    idx + 1,
+			Command: match.Command,
+			Score:   match.Score,
+			Source:  match.Source,
+			Signals: match.Signals,
+		})
+	}
+	if len(section.Candidates) > 0 {
+		section.Status = sectionOK
+	}
+	return section
+}
+
+func aiSection(cfg config.Config, resolution provider.Resolution, providerName string) findSection {
+	candidate := findCandidate{
+		Rank:       1,
+		Command:    resolution.Command,
+		Source:     providerName,
+		Reason:     resolution.Reason,
+		Risk:       resolution.Risk,
+		Confidence: calibratedConfidence(cfg, providerName, resolution.Confidence),
+		Model:      resolution.Model,
+	}
+	if candidate.Confidence != resolution.Confidence {
+		candidate.StatedConfidence = resolution.Confidence
+	}
+	return findSection{Status: sectionOK, Candidates: []findCandidate{candidate}}
+}
+
+// handleFindJSON is find for --json. It gathers memory, history, and the
+// provider answer side by side; the top-level command follows the same
+// precedence as the interactive path so existing consumers see no change.
+func handleFindJSON(query string, cfg config.Config, opts options) {
+	rejections := loadRejections()
+	now := time.Now().UTC()
+	memoryMatches, _ := searchMemoryWithLoader(query, cfg.Find.MaxResults, opts, "checking what you've used before")
+	memoryMatches = rejections.Downrank(query, memoryMatches, now)
+	memoryPick, memoryOK := preferredMemoryMatch(query, memoryMatches)
+
+	page, historyErr := searchHistoryPageWithLoader(query, history.Page{Offset: opts.Offset, Limit: cfg.Find.MaxResults}, opts, "scouting your history")
+	matches := page.Matches
+	if historyErr == nil || errors.Is(historyErr, history.ErrNoHistory) {
+		matches = applyRepoBoost(applyTemporalBoost(filterFindMatches(cfg, query, matches), cfg, time.Now()))
+		matches = downrankRejectedHistory(query, matches, rejections, now)
+		matches = mergeCheatMatches(query, matches, cfg.Find.MaxResults)
+	}
+	matches = mergeRunbookMatches(query, matches, cfg.Find.MaxResults)
+	book, bookOK := runbookPick(query)
+
+	sources := &findSources{
+		Memory:  memorySection(query, memoryMatches),
+		History: historySection(matches, historyErr),
+		AI:      findSection{Status: sectionSkipped, Candidates: []findCandidate{}},
+	}
+	sources.History.NextOffset = page.Next
+
+	var (
+		resolution   provider.Resolution
+		providerName string
+		resolveErr   error
+		aiOK         bool
+		aiFiltered   bool
+	)
+	wantAI := (len(matches) == 0 && !memoryOK) || shouldAIRerank(aiRerankMode(cfg, opts), matches)
+	availability := providerAvailability(cfg, opts)
+	switch {
+	case bookOK && !memoryOK:
+		sources.AI.Note = "answered " + book.Reason
+	case !wantAI:
+		sources.AI.Note = "local matches were strong enough"
+	case !availability.allows(capabilityProviderFallback, opts):
+		sources.AI.Note = availability.reason
+	default:
+		resolution, providerName, resolveErr = resolveProviderWithLoader(
+			invocationCtx,
+			cfg,
+			opts,
+			provider.IntentFind,
+			buildFindPrompt(cfg, query, matches),
+			"thinking of a command that fits",
+		)
+		switch {
+		case resolveErr != nil:
+			sources.AI = findSection{Status: sectionFailed, Note: strings.Join(errorSuggestions(resolveErr), "; "), Candidates: []findCandidate{}}
+		case strings.TrimSpace(resolution.Command) == "":
+			sources.AI = findSection{Status: sectionEmpty, Note: resolution.Reason, Candidates: []findCandidate{}}
+		case !commandAllowedForQuery(query, resolution.Command):
+			sources.AI = findSection{Status: sectionEmpty, Note: filteredProviderNote, Candidates: []findCandidate{}}
+			aiFiltered = true
+		default:
+			sources.AI = aiSection(cfg, resolution, providerName)
+			sources.AI.Candidates[0].Reranked = rerankNote(resolution.Command, matches, opts.Offset)
+			aiOK = true
+		}
+	}
+
+	payload := response{Intent: string(router.IntentFind), Sources: sources}
+	source := ""
+	switch {
+	case memoryOK:
+		sources.Selected = "memory"
+		payload.Message = "memory match"
+		source = "memory"
+		payload.Command = memoryPick.Command
+		payload.Risk = "low"
+		payload.Suggestions = []string{fmt.Sprintf("learned from memory for %q (uses: %d)", memoryPick.Query, memoryPick.Uses)}
+	case historyErr != nil && !errors.Is(historyErr, history.ErrNoHistory):
+		payload.Message = fmt.Sprintf("search failed: %v", historyErr)
+	case bookOK:
+		sources.Selected = "history"
+		payload.Message = "runbook match"
+		source = book.Source
+		payload.Command = book.Command
+		payload.Suggestions = []string{book.Reason}
+		payload.Results = matches
+	case len(matches) > 0:
+		sources.Selected = "history"
+		payload.Message = "top history matches"
+		payload.Results = matches
+	case aiOK:
+		sources.Selected = "ai"
+		payload.Message = providerFallbackMessage(resolution.Action, providerName)
+		source = providerName
+		payload.Command = resolution.Command
+		payload.Risk = resolution.Risk
+		payload.Suggestions = []string{resolution.Reason}
+		persistFindSuggestionMemory(query, resolution.Command, providerName, resolution.Risk)
+	default:
+		if tldrPayload, ok := tldrFallback(query); ok {
+			tldrPayload.Sources = sources
+			sources.Selected = "tldr"
+			source = "tldr"
+			payload = tldrPayload
+			break
+		}
+		switch {
+		case aiFiltered:
+			payload.Message = "no safe suggestion found for this query"
+			payload.Suggestions = []string{filteredProviderNote}
+		case resolveErr != nil:
+			payload.Message = "no local history match and provider fallback failed"
+			payload.Suggestions = errorSuggestions(resolveErr)
+		default:
+			payload.Message = "no safe matching history entries found"
+			if hint := errorHint(historyErr); hint != "" {
+				payload.Suggestions = []string{hint}
+			}
+		}
+	}
+	if payload.Command != "" && len(payload.Suggestions) > 0 {
+		noteSessionSuggestion(payload.Command, payload.Suggestions[0], source)
+	}
+	printResponse(payload, true)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/history"
+	"github.com/ashwch/ew/internal/memory"
+	"github.com/ashwch/ew/internal/provider"
+)
+
+func TestFindSectionsRankEachSourceSeparately(t *testing.T) {
+	mem := memorySection("list docker containers", []memory.Match{
+		{Query: "list containers", Command: "docker ps", Score: 30, Uses: 3},
+		{Query: "clean docker", Command: "docker system prune -af", Score: 12, Uses: 1},
+	})
+	if mem.Status != sectionOK || len(mem.Candidates) != 1 || mem.Candidates[0].Rank != 1 || mem.Candidates[0].Uses != 3 {
+		t.Fatalf("expected the destructive memory entry filtered and the rest ranked, got %+v", mem)
+	}
+
+	hist := historySection([]history.Match{
+		{Command: "docker ps -a", Score: 12, Source: "zsh"},
+		{Command: "docker ps", Score: 9, Source: "cheat:docker.cheat"},
+	}, nil)
+	if hist.Status != sectionOK || len(hist.Candidates) != 2 || hist.Candidates[1].Rank != 2 || hist.Candidates[1].Source != "cheat:docker.cheat" {
+		t.Fatalf("unexpected history section %+v", hist)
+	}
+
+	missing := historySection(nil, history.ErrNoHistory)
+	if missing.Status != sectionEmpty || !strings.Contains(missing.Note, "--setup-hooks") {
+		t.Fatalf("expected no history to be empty with a hint, got %+v", missing)
+	}
+	if failed := historySection(nil, errors.New("permission denied")); failed.Status != sectionFailed {
+		t.Fatalf("expected a search error to mark the section failed, got %+v", failed)
+	}
+
+	ai := aiSection(config.Default(), provider.Resolution{Command: "docker ps", Reason: "lists running containers", Risk: "low", Confidence: 0.8}, "codex")
+	encoded, err := json.Marshal(findSources{Selected: "memory", Memory: mem, History: hist, AI: ai})
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	for _, want := range []string{`"selected":"memory"`, `"ai":{"status":"ok"`, `"confidence":0.8`, `"source":"codex"`} {
+		if !strings.Contains(string(encoded), want) {
+			t.Fatalf("expected %s in %s", want, encoded)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ashwch/ew/internal/safety"
+)
+
+const (
+	// maxContextFileBytes is how much of one --context-file goes into the
+	// fix prompt, and maxContextBytes how much of all of them together.
+	maxContextFileBytes = 4000
+	maxContextBytes     = 12000
+	// contextTailBytes is how much of the end of a large file is read.
+	contextTailBytes = 256 * 1024
+	// maxContextErrorLines bounds the error-looking lines kept from the
+	// part of a file that was cut.
+	maxContextErrorLines = 10
+)
+
+// contextErrorWords mark a line worth keeping from the cut part of a file.
+var contextErrorWords = []string{"error", "fatal", "fail", "panic", "exception", "traceback", "denied", "not found", "cannot", "undefined"}
+
+// contextFile is a file attached to a fix request, trimmed and redacted for
+// the prompt.
+type contextFile struct {
+	Path    string
+	Content string
+}
+
+// fixContextFiles reads the files attached to a fix request: each
+// --context-file, plus files the request itself points at, as in "fix it,
+// the error is in ./build.log". Dotfiles and key files are never attached.
+// A --context-file that cannot be read or is refused is an error; a word of
+// the request that is not a file is just a word.
+func fixContextFiles(userContext string, opts options) ([]contextFile, error) {
+	paths := make([]string, 0, len(opts.ContextFiles))
+	for _, path := range opts.ContextFiles {
+		if path = strings.TrimSpace(path); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	explicit := len(paths)
+	paths = append(paths, promptContextPaths(userContext)...)
+
+	files := make([]contextFile, 0, len(paths))
+	seen := make(map[string]bool, len(paths))
+	budget := maxContextBytes
+	for idx, path := range paths {
+		key := path
+		if abs, err := filepath.Abs(expandHome(path)); err == nil {
+			key = abs
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if budget <= 0 {
+			break
+		}
+		if why, secret := secretContextPath(path); secret {
+			if idx >= explicit {
+				continue
+			}
+			return nil, fmt.Errorf("context file %s is not sent to a provider: %s", path, why)
+		}
+		content, err := readContextFile(path)
+		if err != nil {
+			if idx >= explicit {
+				continue
+			}
+			return nil, err
+		}
+		content = clipContextText(content, min(maxContextFileBytes, budget))
+		budget -= len(content)
+		files = append(files, contextFile{Path: path, Content: content})
+	}
+	return files, nil
+}
+
+// promptFileCue is the phrasing that attaches a file named in a fix
+// request: "the error is in ./build.log", "output is at out.txt", "see
+// logs/test.out". A path mentioned any other way, as in "fix ssh using
+// ~/.ssh/id_ed25519", is only a word.
+var promptFileCue = regexp.MustCompile(`(?i)\b(?:(?:error|errors|output|log|logs|failure|trace|traceback|details)\s+(?:is|are)\s+(?:in|at)|see|attached)\s+(\S+)`)
+
+// promptContextPaths picks the files a fix request points at with
+// promptFileCue: ./build.log, logs/test.out, ~/err.txt, or build.log.
+func promptContextPaths(userContext string) []string {
+	var paths []string
+	for _, match := range promptFileCue.FindAllStringSubmatch(userContext, -1) {
+		word := strings.Trim(match[1], "\"'`()[]<>,;:!?")
+		word = strings.TrimRight(word, ".")
+		if word == "" || !strings.ContainsAny(word, "./~") || strings.Contains(word, "://") {
+			continue
+		}
+		if _, secret := secretContextPath(word); secret {
+			continue
+		}
+		if info, err := os.Stat(expandHome(word)); err == nil && info.Mode().IsRegular() {
+			paths = append(paths, word)
+		}
+	}
+	return paths
+}
+
+// secretKeyPrefixes and secretKeyExts are file names and extensions that hold keys or
+// credentials rather than anything that explains a failure.
+var (
+	secretKeyPrefixes = []string{"id_rsa", "id_dsa", "id_ecdsa", "id_ed25519"}
+	secretKeyExts     = map[string]bool{".pem": true, ".key": true, ".p12": true, ".pfx": true, ".jks": true, ".keystore": true, ".kdbx": true, ".ppk": true, ".gpg": true, ".asc": true}
+)
+
+// secretContextPath reports whether path is never sent to a provider, and
+// why: a dotfile or a file in a dot directory (.env files, ~/.ssh, ~/.aws),
+// or a key file.
+func secretContextPath(path string) (string, bool) {
+	for _, part := range strings.Split(filepath.ToSlash(filepath.Clean(expandHome(path))), "/") {
+		if strings.HasPrefix(part, ".") && part != "." && part != ".." {
+			return "it is a dotfile or sits in a dot directory", true
+		}
+	}
+	base := strings.ToLower(filepath.Base(path))
+	for _, prefix := range secretKeyPrefixes {
+		if strings.HasPrefix(base, prefix) {
+			return "it looks like a key file", true
+		}
+	}
+	if secretKeyExts[filepath.Ext(base)] || base == "credentials" {
+		return "it looks like a key file", true
+	}
+	return "", false
+}
+
+// announceContextFiles says which files go to the provider before the fix
+// request is sent.
+func announceContextFiles(files []contextFile) {
+	for _, file := range files {
+		fmt.Fprintf(os.Stderr, "ew: attaching %s (redacted) to the fix request\n", file.Path)
+	}
+}
+
+// readContextFile reads the end of path, redacted. Binary files are
+// refused.
+func readContextFile(path string) (string, error) {
+	f, err := os.Open(expandHome(path))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("context file %s does not exist", path)
+		}
+		return "", fmt.Errorf("could not open context file: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", fmt.Errorf("could not read context file: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("context file %s is not a regular file", path)
+	}
+	offset := max(info.Size()-contextTailBytes, 0)
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return "", fmt.Errorf("could not read context file: %w", err)
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return "", fmt.Errorf("could not read context file: %w", err)
+	}
+	if strings.ContainsRune(string(data), 0) {
+		return "", fmt.Errorf("context file %s is not a text file", path)
+	}
+	text := string(data)
+	if offset > 0 {
+		// The first line was cut by the seek.
+		if _, rest, ok := strings.Cut(text, "\n"); ok {
+			text = rest
+		}
+		text = fmt.Sprintf("[first %d bytes not read]\n", offset) + text
+	}
+	return safety.RedactText(strings.TrimSpace(text)), nil
+}
+
+// clipContextText keeps text under limit bytes. The end of a log usually
+// carries the failure, so that is kept, along with a few error-looking lines
+// from the part that was cut.
+func clipContextText(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	var tail []string
+	size := 0
+	for i := len(lines) - 1; i >= 0; i-- {
+		if size+len(lines[i])+1 > limit*3/4 {
+			break
+		}
+		size += len(lines[i]) + 1
+		tail = append([]string{lines[i]}, tail...)
+	}
+	if len(tail) == 0 {
+		// One long last line: keep its end.
+		last := lines[len(lines)-1]
+		tail = []string{last[len(last)-limit*3/4:]}
+		size = len(tail[0])
+	}
+	cut := lines[:len(lines)-len(tail)]
+
+	var errorLines []string
+	for _, line := range cut {
+		if len(errorLines) == maxContextErrorLines {
+			break
+		}
+		if size+len(line)+1 > limit-64 {
+			continue
+		}
+		lower := strings.ToLower(line)
+		for _, word := range contextErrorWords {
+			if strings.Contains(lower, word) {
+				errorLines = append(errorLines, line)
+				size += len(line) + 1
+				break
+			}
+		}
+	}
+
+	var b strings.Builder
+	if len(errorLines) > 0 {
+		b.WriteString("[error lines from the omitted part]\n")
+		b.WriteString(strings.Join(errorLines, "\n"))
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "[%d earlier lines omitted]\n", len(cut))
+	b.WriteString(strings.Join(tail, "\n"))
+	return b.String()
+}
+
+// contextFilesPrompt is the part of the fix prompt that carries attached
+// files.
+func contextFilesPrompt(files []contextFile) string {
+	if len(files) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for _, file := range files {
+		fmt.Fprintf(&b, " Contents of %q, attached by the user: %q.", file.Path, file.Content)
+	}
+	return b.String()
+}
+
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ashwch/ew/internal/router"
+	"github.com/ashwch/ew/internal/safety"
+	"github.com/ashwch/ew/internal/session"
+)
+
+// fixLoopScan is how far back the session journal is read for earlier fix
+// attempts.
+const fixLoopScan = 50
+
+// recentInteractions is swapped out in tests.
+var recentInteractions = session.Recent
+
+// fixLoop returns the fixes ew already offered in this shell session for
+// failure, oldest first. It follows the journal back through consecutive
+// fix interactions linked to this failure, either by fixing the same
+// command or by suggesting the command that has now failed, and stops at
+// anything else, at a fix that ran successfully, or at a gap longer than
+// fixRetryWindow.
+func fixLoop(failure, sessionID string, now time.Time) []session.Interaction {
+	if sessionID == "" {
+		return nil
+	}
+	items, err := recentInteractions(fixLoopScan)
+	if err != nil {
+		return nil
+	}
+	related := map[string]bool{journalComparable(failure): true}
+	var loop []session.Interaction
+	newer := now
+	for i := len(items) - 1; i >= 0; i-- {
+		item := items[i]
+		if item.SessionID != sessionID {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, item.Timestamp)
+		if err != nil || newer.Sub(at) > fixRetryWindow {
+			break
+		}
+		newer = at
+		failureKey, commandKey := journalComparable(item.Failure), journalComparable(item.Command)
+		if item.Intent != string(router.IntentFix) || item.Decision == session.DecisionExecuted || !(related[failureKey] || related[commandKey]) {
+			break
+		}
+		loop = append(loop, item)
+		for _, key := range []string{failureKey, commandKey} {
+			if key != "" {
+				related[key] = true
+			}
+		}
+	}
+	for i, j := 0, len(loop)-1; i < j; i, j = i+1, j-1 {
+		loop[i], loop[j] = loop[j], loop[i]
+	}
+	return loop
+}
+
+// journalComparable compares commands the way the journal stores them,
+// with secrets redacted.
+func journalComparable(command string) string {
+	return comparableCommand(safety.RedactText(strings.TrimSpace(command)))
+}
+
+// loopCommands lists the distinct commands ew suggested during loop.
+func loopCommands(loop []session.Interaction) []string {
+	var commands []string
+	for _, item := range loop {
+		if item.Command != "" && !repeatsFailedAttempt(item.Command, commands) {
+			commands = append(commands, item.Command)
+		}
+	}
+	return commands
+}
+
+// doNotRepeatPrompt tells the provider which fixes it already gave for
+// this failure, so it does not go round in circles.
+func doNotRepeatPrompt(prompt string, commands []string) string {
+	if len(commands) == 0 {
+		return prompt
+	}
+	lines := make([]string, 0, len(commands))
+	for idx, command := range commands {
+		lines = append(lines, fmt.Sprintf("%d) %s", idx+1, command))
+	}
+	return prompt + "\nYou already suggested these fixes in this session and the problem is still there. Do not repeat them:\n" + strings.Join(lines, "\n")
+}
+
+// fixFillerWords say nothing new about a failure: "fix it again" asks the
+// same question as plain `ew`.
+var fixFillerWords = map[string]bool{
+	"fix": true, "it": true, "this": true, "that": true, "the": true, "my": true,
+	"last": true, "failed": true, "command": true, "again": true, "please": true,
+}
+
+// addsFixContext reports whether userContext gives the provider something
+// new to go on.
+func addsFixContext(userContext string) bool {
+	for _, word := range strings.Fields(strings.ToLower(userContext)) {
+		if word = strings.Trim(word, ".,:;!?"); word != "" && !fixFillerWords[word] {
+			return true
+		}
+	}
+	return false
+}
+
+// fixLoopSummary is what ew says instead of asking the provider again once
+// fix.max_attempts fixes in a row have not helped.
+func fixLoopSummary(failure string, loop []session.Interaction) response {
+	payload := response{
+		Intent:  string(router.IntentFix),
+		Message: fmt.Sprintf("stopped after %d fix attempts for this failure in this session", len(loop)),
+		Suggestions: []string{
+			fmt.Sprintf("Failed command: %s", failure),
+		},
+	}
+	for idx, item := range loop {
+		command := item.Command
+		if command == "" {
+			command = "(no suggestion)"
+		}
+		payload.Suggestions = append(payload.Suggestions, fmt.Sprintf("Attempt %d: %s (%s)", idx+1, command, fixAttemptOutcome(item.Decision)))
+	}
+	payload.Suggestions = append(payload.Suggestions,
+		"Add what you know to ask again, e.g. `ew fix it needs the staging profile`",
+		"Or raise `[fix] max_attempts` in config.toml",
+	)
+	return payload
+}
+
+func fixAttemptOutcome(decision string) string {
+	switch decision {
+	case session.DecisionFailed:
+		return "failed when ew ran it"
+	case session.DecisionNotExecuted:
+		return "not run"
+	case session.DecisionInterrupted:
+		return "interrupted"
+	case session.DecisionNone:
+		return "no suggestion"
+	}
+	return "suggested"
+}
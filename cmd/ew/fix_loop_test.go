@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ashwch/ew/internal/session"
+)
+
+func TestFixLoopFollowsSuggestionsThatFailedInTheSameSession(t *testing.T) {
+	previous := recentInteractions
+	t.Cleanup(func() { recentInteractions = previous })
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) string { return now.Add(-time.Duration(minutes) * time.Minute).Format(time.RFC3339) }
+	recentInteractions = func(int) ([]session.Interaction, error) {
+		return []session.Interaction{
+			{Timestamp: at(30), Intent: "fix", SessionID: "s1", Failure: "npm start", Command: "npm ci", Decision: session.DecisionSuggested},
+			{Timestamp: at(9), Intent: "find", SessionID: "s1", Query: "list files", Command: "ls"},
+			{Timestamp: at(8), Intent: "fix", SessionID: "s1", Failure: "npm start", Command: "npm install", Decision: session.DecisionFailed},
+			{Timestamp: at(6), Intent: "fix", SessionID: "s2", Failure: "npm start", Command: "yarn", Decision: session.DecisionSuggested},
+			{Timestamp: at(5), Intent: "fix", SessionID: "s1", Failure: "npm install", Command: "npm install --legacy-peer-deps", Decision: session.DecisionSuggested},
+			{Timestamp: at(2), Intent: "fix", SessionID: "s1", Failure: "npm install --legacy-peer-deps", Command: "npm install", Decision: session.DecisionSuggested},
+		}, nil
+	}
+
+	loop := fixLoop("npm install", "s1", now)
+	if got := strings.Join(loopCommands(loop), "|"); got != "npm install|npm install --legacy-peer-deps" {
+		t.Fatalf("expected the same-session chain back to the find interaction, got %q", got)
+	}
+	if len(loop) != 3 {
+		t.Fatalf("expected three attempts in the loop, got %d", len(loop))
+	}
+	if got := fixLoop("cargo build", "s1", now); len(got) != 0 {
+		t.Fatalf("expected an unrelated failure to start a fresh loop, got %+v", got)
+	}
+	if got := fixLoop("npm install", "", now); got != nil {
+		t.Fatalf("expected no loop without a shell session id")
+	}
+
+	summary := fixLoopSummary("npm install", loop)
+	if !strings.Contains(summary.Message, "stopped after 3 fix attempts") || !strings.Contains(strings.Join(summary.Suggestions, "\n"), "Attempt 1: npm install (failed when ew ran it)") {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+	prompt := doNotRepeatPrompt("TASK", loopCommands(loop))
+	if !strings.Contains(prompt, "Do not repeat them:\n1) npm install\n2) npm install --legacy-peer-deps") {
+		t.Fatalf("expected earlier fixes in the prompt, got %q", prompt)
+	}
+}
+
+func TestAddsFixContextIgnoresFillerWords(t *testing.T) {
+	for _, context := range []string{"", "fix it", "fix: the last failed command again"} {
+		if addsFixContext(context) {
+			t.Fatalf("expected %q to add nothing new", context)
+		}
+	}
+	if !addsFixContext("fix it, I am behind the corporate proxy") {
+		t.Fatalf("expected real context to count")
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/provider"
+	"github.com/ashwch/ew/internal/router"
+)
+
+// runFixPlan carries out a fix of several commands. Each step goes through
+// executeSuggested on its own, with the usual policy gates and its own
+// confirmation, and the plan stops at the first step that fails or is
+// declined. When nothing would run (suggest mode, --dry-run, or --json
+// without --yes) the whole plan is printed instead.
+func runFixPlan(steps []provider.PlanStep, reason, riskHint string, cfg config.Config, opts options) executionOutcome {
+	if !fixPlanRuns(cfg, opts) {
+		printFixPlan(steps, reason, riskHint, opts)
+		return executionOutcome{Command: planCommands(steps)[0]}
+	}
+	// The plan is listed, and each step confirmed, as it will run.
+	steps = append([]provider.PlanStep(nil), steps...)
+	alternatives := make([]string, len(steps))
+	for idx := range steps {
+		steps[idx].Command, alternatives[idx] = saferSuggestion(cfg, steps[idx].Command)
+	}
+	commands := planCommands(steps)
+	if !opts.JSON {
+		fmt.Printf("Fix plan (%d steps):\n", len(steps))
+		for _, line := range fixPlanLines(commands, 0) {
+			fmt.Println(line)
+		}
+	}
+
+	var outcome executionOutcome
+	for idx, step := range steps {
+		stepOpts := opts
+		stepOpts.PlanSteps = commands
+		stepOpts.PlanStep = idx + 1
+		stepReason := step.Reason
+		if stepReason == "" {
+			stepReason = reason
+		}
+		outcome = executeSuggested(step.Command, alternatives[idx], fmt.Sprintf("step %d of %d: %s", idx+1, len(steps), stepReason), riskHint, cfg, stepOpts, router.IntentFix)
+		if outcome.Executed && outcome.Success {
+			continue
+		}
+		if remaining := commands[idx+1:]; len(remaining) > 0 {
+			payload := response{
+				Intent:  string(router.IntentFix),
+				Message: fmt.Sprintf("stopped at step %d of %d; the rest of the plan was not run", idx+1, len(steps)),
+				Steps:   remaining,
+			}
+			if !opts.JSON {
+				payload.Suggestions = remaining
+				payload.Steps = nil
+			}
+			printResponse(payload, opts.JSON)
+		}
+		return outcome
+	}
+	return outcome
+}
+
+// fixPlanRuns reports whether runFixPlan would run steps rather than only
+// show them, following the mode executeSuggestedCommand picks.
+func fixPlanRuns(cfg config.Config, opts options) bool {
+	mode := cfg.Mode
+	if strings.TrimSpace(opts.Mode) != "" {
+		mode = strings.TrimSpace(opts.Mode)
+	}
+	switch {
+	case opts.DryRun:
+		return false
+	case strings.EqualFold(mode, "suggest") && len(opts.YoloFor) == 0:
+		return false
+	case opts.JSON && isConfirmMode(mode) && !opts.Yes:
+		return false
+	}
+	return true
+}
+
+// printFixPlan shows a multi-step fix without running it.
+func printFixPlan(steps []provider.PlanStep, reason, riskHint string, opts options) {
+	commands := planCommands(steps)
+	for _, command := range commands {
+		noteSessionSuggestion(command, reason, "")
+	}
+	if opts.JSON {
+		printResponse(response{
+			Intent:  string(router.IntentFix),
+			Message: reason,
+			Command: commands[0],
+			Steps:   commands,
+			Risk:    normalizeRiskHint(riskHint),
+		}, true)
+		return
+	}
+	if opts.Quiet {
+		fmt.Println(strings.Join(commands, "\n"))
+		return
+	}
+	fmt.Printf("Suggested fix, %d commands in order:\n", len(steps))
+	for idx, step := range steps {
+		printCommand(fmt.Sprintf("%d. ", idx+1), step.Command)
+		if step.Reason != "" {
+			fmt.Printf("   %s\n", step.Reason)
+		}
+	}
+	for _, line := range renderReasonLines(reason, outputWidth(), reasonStylingEnabled()) {
+		fmt.Println(line)
+	}
+}
+
+// fixPlanLines lists a plan's commands, marking step current (1-based; 0
+// marks none).
+func fixPlanLines(commands []string, current int) []string {
+	lines := make([]string, 0, len(commands))
+	for idx, command := range commands {
+		marker := "  "
+		if idx+1 == current {
+			marker = "> "
+		}
+		lines = append(lines, fmt.Sprintf("%s%d. %s", marker, idx+1, command))
+	}
+	return lines
+}
+
+// planStepLines is what the confirmation of one step of a plan shows about
+// the rest of it.
+func planStepLines(opts options) []string {
+	if len(opts.PlanSteps) == 0 {
+		return nil
+	}
+	return append([]string{fmt.Sprintf("plan (step %d of %d):", opts.PlanStep, len(opts.PlanSteps))}, fixPlanLines(opts.PlanSteps, opts.PlanStep)...)
+}
+
+func planCommands(steps []provider.PlanStep) []string {
+	commands := make([]string, 0, len(steps))
+	for _, step := range steps {
+		commands = append(commands, step.Command)
+	}
+	return commands
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/provider"
+)
+
+func TestRunFixPlanStopsAtTheFirstFailedStep(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "marker")
+	steps := []provider.PlanStep{
+		{Command: "true", Reason: "first"},
+		{Command: "false", Reason: "second"},
+		{Command: "touch " + marker, Reason: "third"},
+	}
+
+	var outcome executionOutcome
+	out := captureStdout(t, func() {
+		outcome = runFixPlan(steps, "plan reason", "low", config.Default(), options{JSON: true, Yes: true, Mode: "yolo"})
+	})
+	if outcome.Command != "false" || !outcome.Executed || outcome.Success {
+		t.Fatalf("expected the plan to end on the failed second step, got %+v", outcome)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Fatalf("expected the step after the failure not to run")
+	}
+	var results []response
+	decoder := json.NewDecoder(strings.NewReader(out))
+	for decoder.More() {
+		var payload response
+		if err := decoder.Decode(&payload); err != nil {
+			t.Fatalf("expected a JSON result per step, got %q", out)
+		}
+		results = append(results, payload)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected two step results and the stop notice, got %q", out)
+	}
+	stopped := results[2]
+	if !strings.Contains(stopped.Message, "stopped at step 2 of 3") || len(stopped.Steps) != 1 || stopped.Steps[0] != "touch "+marker {
+		t.Fatalf("expected the rest of the plan to be reported, got %+v", stopped)
+	}
+}
+
+func TestRunFixPlanOnlyShowsThePlanWhenNothingWouldRun(t *testing.T) {
+	steps := []provider.PlanStep{{Command: "git fetch origin"}, {Command: "git rebase origin/main"}}
+	cfg := config.Default()
+	if fixPlanRuns(cfg, options{Mode: "suggest"}) || fixPlanRuns(cfg, options{DryRun: true, Mode: "yolo"}) || fixPlanRuns(cfg, options{JSON: true, Mode: "confirm"}) {
+		t.Fatalf("expected suggest mode, --dry-run, and --json without --yes to only show the plan")
+	}
+	if !fixPlanRuns(cfg, options{Mode: "confirm"}) || !fixPlanRuns(cfg, options{JSON: true, Yes: true}) {
+		t.Fatalf("expected confirm mode and --json --yes to run the plan")
+	}
+
+	out := captureStdout(t, func() {
+		runFixPlan(steps, "rebase onto the updated branch", "low", cfg, options{JSON: true})
+	})
+	var payload response
+	if err := json.Unmarshal([]byte(out), &payload); err != nil {
+		t.Fatalf("expected one JSON plan, got %q", out)
+	}
+	if payload.Command != "git fetch origin" || strings.Join(payload.Steps, "|") != "git fetch origin|git rebase origin/main" || payload.Executed {
+		t.Fatalf("unexpected plan payload %+v", payload)
+	}
+}
+
+func TestPlanStepLinesMarkTheCurrentStep(t *testing.T) {
+	got := strings.Join(planStepLines(options{PlanSteps: []string{"git fetch", "git rebase"}, PlanStep: 2}), "\n")
+	if got != "plan (step 2 of 2):\n  1. git fetch\n> 2. git rebase" {
+		t.Fatalf("unexpected plan lines:\n%s", got)
+	}
+	if planStepLines(options{}) != nil {
+		t.Fatalf("expected no plan lines outside a plan")
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/hook"
+	"github.com/ashwch/ew/internal/provider"
+	ewrt "github.com/ashwch/ew/internal/runtime"
+)
+
+// Failures in the same shell session this close to the captured one count as
+// retries of the same problem.
+const (
+	fixRetryWindow      = 15 * time.Minute
+	maxFixRetryAttempts = 5
+)
+
+// failedAttempts lists the captured failure plus recent failed retries from
+// the same session, newest first, without duplicates.
+func failedAttempts(ev hook.Event) []string {
+	attempts := []string{}
+	seen := map[string]bool{}
+	add := func(command string) {
+		key := comparableCommand(command)
+		if key == "" || seen[key] {
+			return
+		}
+		seen[key] = true
+		attempts = append(attempts, strings.TrimSpace(command))
+	}
+	add(ev.Command)
+
+	sessionID := strings.TrimSpace(ev.SessionID)
+	if sessionID == "" {
+		return attempts
+	}
+	failures, err := hook.RecentFailures(sessionID, maxFixRetryAttempts)
+	if err != nil {
+		return attempts
+	}
+	anchor, anchorErr := time.Parse(time.RFC3339, strings.TrimSpace(ev.Timestamp))
+	for _, failure := range failures {
+		if anchorErr == nil {
+			at, err := time.Parse(time.RFC3339, strings.TrimSpace(failure.Timestamp))
+			if err != nil || anchor.Sub(at) > fixRetryWindow || at.Sub(anchor) > fixRetryWindow {
+				continue
+			}
+		}
+		add(failure.Command)
+	}
+	return attempts
+}
+
+// comparableCommand strips fences and prompt markers from a provider
+// answer, then normalizes it the way normalizeComparableCommand does.
+func comparableCommand(command string) string {
+	normalized, err := ewrt.NormalizeCommand(command)
+	if err != nil {
+		return ""
+	}
+	return normalizeComparableCommand(normalized)
+}
+
+func repeatsFailedAttempt(command string, attempts []string) bool {
+	key := comparableCommand(command)
+	if key == "" {
+		return false
+	}
+	for _, attempt := range attempts {
+		if comparableCommand(attempt) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveDistinctFix asks the provider for a fix and, when the answer is one
+// of the commands that just failed, asks once more for a different approach.
+// If the provider still insists, the repeat is kept but flagged in the reason
+// and never auto-executed.
+func resolveDistinctFix(
+	cfg config.Config,
+	opts options,
+	prompt string,
+	label string,
+	attempts []string,
+) (provider.Resolution, string, error) {
+	resolution, providerName, err := resolveProviderWithLoader(invocationCtx, cfg, opts, provider.IntentFix, prompt, label)
+	if err != nil || !repeatsFailedAttempt(resolution.Command, attempts) {
+		return resolution, providerName, err
+	}
+
+	retry, retryProvider, retryErr := resolveProviderWithLoader(
+		invocationCtx,
+		cfg,
+		opts,
+		provider.IntentFix,
+		distinctFixPrompt(prompt, attempts),
+		"looking for a different approach",
+	)
+	if retryErr == nil {
+		if !repeatsFailedAttempt(retry.Command, attempts) {
+			return retry, retryProvider, nil
+		}
+		resolution, providerName = retry, retryProvider
+	}
+	resolution.NeedsConfirmation = true
+	resolution.Reason = repeatedFixReason(resolution.Reason)
+	return resolution, providerName, nil
+}
+
+func distinctFixPrompt(prompt string, attempts []string) string {
+	lines := make([]string, 0, len(attempts))
+	for idx, attempt := range attempts {
+		lines = append(lines, fmt.Sprintf("%d) %s", idx+1, attempt))
+	}
+	return prompt + "\nThese commands already failed:\n" + strings.Join(lines, "\n") +
+		"\nSuggest a different approach. Only return one of them again if retrying unchanged is genuinely correct (for example a transient network error), and say why in reason."
+}
+
+func repeatedFixReason(reason string) string {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return "This is the command that just failed; the provider found no different approach."
+	}
+	return "Retrying the command that just failed: " + reason
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ashwch/ew/internal/hook"
+)
+
+func TestRepeatsFailedAttemptNormalizesWhitespaceAndFences(t *testing.T) {
+	attempts := []string{"git push origin main"}
+	for _, candidate := range []string{"git  push origin main", "$ git push origin main;", "```sh\ngit push origin main\n```", "Git push origin main \\"} {
+		if !repeatsFailedAttempt(candidate, attempts) {
+			t.Fatalf("expected %q to match the failed command", candidate)
+		}
+	}
+	if repeatsFailedAttempt("git push -u origin main", attempts) {
+		t.Fatalf("expected a changed command not to count as a repeat")
+	}
+}
+
+func TestFailedAttemptsIncludesRecentRetriesFromSameSession(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", "")
+
+	now := time.Now().UTC()
+	record := func(command, session string, at time.Time) {
+		t.Helper()
+		if err := hook.RecordEvent(hook.Event{Command: command, ExitCode: 1, Shell: "zsh", SessionID: session, Timestamp: at.Format(time.RFC3339)}); err != nil {
+			t.Fatalf("RecordEvent failed: %v", err)
+		}
+	}
+	record("npm run biuld", "1.1", now.Add(-time.Hour))
+	record("npm run buld", "1.1", now.Add(-2*time.Minute))
+	record("npm run bld", "2.2", now.Add(-time.Minute))
+	record("npm run biuld", "1.1", now)
+
+	ev := hook.Event{Command: "npm run biuld", ExitCode: 1, SessionID: "1.1", Timestamp: now.Format(time.RFC3339)}
+	got := strings.Join(failedAttempts(ev), "|")
+	if got != "npm run biuld|npm run buld" {
+		t.Fatalf("expected captured failure plus same-session retry, got %q", got)
+	}
+}
+
+func TestDistinctFixPromptListsFailedAttempts(t *testing.T) {
+	prompt := distinctFixPrompt("TASK", []string{"make", "make all"})
+	if !strings.Contains(prompt, "1) make\n2) make all") || !strings.Contains(prompt, "different approach") {
+		t.Fatalf("expected failed attempts and instruction in prompt, got %q", prompt)
+	}
+	if got := repeatedFixReason(""); !strings.Contains(got, "no different approach") {
+		t.Fatalf("expected fallback explanation, got %q", got)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+
+	"github.com/ashwch/ew/internal/appdirs"
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/i18n"
+)
+
+// expandFlagAliases replaces the locale's flag aliases at the start of args
+// with the flags they stand for, so "ew chalaao --yes restart nginx" parses
+// as "ew --execute --yes restart nginx". Only words where a flag could go
+// are read: up to the first word of the request, and never after --.
+func expandFlagAliases(fs *flag.FlagSet, args []string, aliases []i18n.FlagAlias) []string {
+	if len(aliases) == 0 {
+		return args
+	}
+	out := make([]string, 0, len(args))
+	for i := 0; i < len(args); {
+		arg := args[i]
+		if arg == "--" {
+			return append(out, args[i:]...)
+		}
+		if strings.HasPrefix(arg, "-") && arg != "-" {
+			out = append(out, arg)
+			i++
+			if name := strings.TrimLeft(arg, "-"); !strings.Contains(name, "=") && flagTakesValue(fs, name) && i < len(args) {
+				out = append(out, args[i])
+				i++
+			}
+			continue
+		}
+		name, used := matchFlagAlias(args[i:], aliases)
+		if used == 0 {
+			return append(out, args[i:]...)
+		}
+		out = append(out, "--"+name)
+		i += used
+	}
+	return out
+}
+
+// matchFlagAlias returns the flag the alias at the start of args stands
+// for, and how many args it spans.
+func matchFlagAlias(args []string, aliases []i18n.FlagAlias) (string, int) {
+	for _, alias := range aliases {
+		if len(alias.Words) > len(args) {
+			continue
+		}
+		matched := true
+		for idx, word := range alias.Words {
+			if !strings.EqualFold(args[idx], word) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return alias.Flag, len(alias.Words)
+		}
+	}
+	return "", 0
+}
+
+func flagTakesValue(fs *flag.FlagSet, name string) bool {
+	f := fs.Lookup(name)
+	if f == nil {
+		return false
+	}
+	if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+		return false
+	}
+	return true
+}
+
+// flagAliasLocale is the locale whose flag aliases apply, known before the
+// flags are parsed: --locale, else the configured locale, else the
+// environment's. The config file is only read when it exists and there is
+// a word that could be an alias.
+func flagAliasLocale(args []string) string {
+	for idx, arg := range args {
+		if arg == "--" {
+			break
+		}
+		for _, prefix := range []string{"--locale", "-locale"} {
+			value, ok := strings.CutPrefix(arg, prefix+"=")
+			if !ok && arg == prefix && idx+1 < len(args) {
+				value, ok = args[idx+1], true
+			}
+			if ok {
+				if strings.EqualFold(value, "auto") {
+					return ""
+				}
+				return value
+			}
+		}
+	}
+	hasWord := false
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			hasWord = true
+			break
+		}
+	}
+	if !hasWord {
+		return ""
+	}
+	path, err := appdirs.ConfigFilePath()
+	if err != nil {
+		return ""
+	}
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	cfg, _, err := config.LoadOrCreate()
+	if err != nil || strings.EqualFold(strings.TrimSpace(cfg.Locale), "auto") {
+		return ""
+	}
+	return strings.TrimSpace(cfg.Locale)
+}
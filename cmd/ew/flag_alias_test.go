@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ashwch/ew/internal/i18n"
+)
+
+func TestParseArgsExpandsLocaleFlagAliases(t *testing.T) {
+	original := localeCatalog
+	t.Cleanup(func() { localeCatalog = original })
+	localeCatalog = i18n.LoadCatalog("hi")
+
+	opts, prompt, err := parseArgs([]string{"chalaao", "--mode", "confirm", "haan", "restart", "nginx"})
+	if err != nil {
+		t.Fatalf("parseArgs failed: %v", err)
+	}
+	if !opts.Execute || !opts.Yes || opts.Mode != "confirm" || prompt != "restart nginx" {
+		t.Fatalf("expected chalaao and haan to be --execute and --yes, got %+v %q", opts, prompt)
+	}
+
+	opts, prompt, err = parseArgs([]string{"copy", "karo", "git", "status", "chalaao"})
+	if err != nil || !opts.Copy || opts.Execute || prompt != "git status chalaao" {
+		t.Fatalf("expected only leading words to be read as flags, got %+v %q (err=%v)", opts, prompt, err)
+	}
+
+	opts, prompt, err = parseArgs([]string{"--execute", "--", "chalaao"})
+	if err != nil || !opts.Execute || prompt != "chalaao" {
+		t.Fatalf("expected words after -- left alone, got %+v %q (err=%v)", opts, prompt, err)
+	}
+
+	localeCatalog = i18n.LoadCatalog("en")
+	if opts, prompt, _ := parseArgs([]string{"chalaao", "tests"}); opts.Execute || prompt != "chalaao tests" {
+		t.Fatalf("expected no aliases in English, got %+v %q", opts, prompt)
+	}
+}
+
+func TestFlagAliasLocalePrefersTheFlag(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--locale", "hi", "chalaao", "x"}, "hi"},
+		{[]string{"-locale=hi-IN", "x"}, "hi-IN"},
+		{[]string{"--locale", "auto", "x"}, ""},
+		{[]string{"--json"}, ""},
+	} {
+		if got := flagAliasLocale(tc.args); got != tc.want {
+			t.Fatalf("flagAliasLocale(%v) = %q, want %q", tc.args, got, tc.want)
+		}
+	}
+}
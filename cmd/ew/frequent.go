@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/history"
+	"github.com/ashwch/ew/internal/hook"
+	"github.com/ashwch/ew/internal/router"
+	"github.com/ashwch/ew/internal/ui"
+	"github.com/ashwch/ew/internal/usage"
+)
+
+const frequentEventWindow = 5000
+
+// emptyQueryWords carry no search intent on their own, so a query made only
+// of them ("ew --execute something", "ew show me commands") gets the frequent
+// command list instead of a search.
+var emptyQueryWords = map[string]struct{}{
+	"a": {}, "an": {}, "the": {}, "me": {}, "my": {}, "i": {}, "some": {}, "something": {}, "anything": {},
+	"what": {}, "should": {}, "do": {}, "can": {}, "show": {}, "find": {}, "run": {}, "execute": {},
+	"command": {}, "commands": {}, "usual": {}, "again": {}, "common": {}, "frequent": {}, "recent": {},
+	"suggest": {}, "suggestion": {}, "suggestions": {}, "please": {}, "help": {},
+}
+
+func meaninglessQuery(query string) bool {
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		word = strings.Trim(word, `"'.,!?;:()[]{}<>`)
+		if word == "" {
+			continue
+		}
+		if _, ok := emptyQueryWords[word]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// handleFrequentCommands offers the user's most reused commands for the
+// current directory and time of day. It returns false when the hook store has
+// nothing to offer, so callers can fall back to their usual message.
+func handleFrequentCommands(intent router.Intent, cfg config.Config, opts options) bool {
+	events, err := hook.RecentEvents(frequentEventWindow)
+	if err != nil || len(events) == 0 {
+		return false
+	}
+	cwd, _ := os.Getwd()
+	commands, scope := usage.FrequentHere(events, cwd, time.Now(), cfg.Find.MaxResults)
+	if len(commands) == 0 {
+		return false
+	}
+
+	if opts.JSON {
+		payload := response{Intent: string(intent), Message: "most reused commands for " + scope, Results: commands}
+		printResponse(payload, true)
+		return true
+	}
+	if opts.Quiet {
+		fmt.Println(commands[0].Command)
+		return true
+	}
+
+	backend := effectiveUIBackend(cfg, opts)
+	if len(commands) > 1 && canUseInteractiveUI(opts, backend) {
+		top := commands[0]
+		rest := make([]history.Match, 0, len(commands)-1)
+		for _, item := range commands[1:] {
+			rest = append(rest, history.Match{Command: item.Command, Score: float64(item.Count), Source: "frequent"})
+		}
+		selected, used, selectErr := ui.SelectSuggestedCommand(backend, "most reused in "+scope, ui.Selection{
+			Command: top.Command,
+			Reason:  frequentReason(top, scope),
+			Source:  "frequent",
+		}, rest)
+		exitIfInterrupted()
+		if selectErr == nil && used {
+			if strings.TrimSpace(selected.Command) == "" {
+				fmt.Println("Cancelled.")
+				return true
+			}
+			reason := selected.Reason
+			for _, item := range commands {
+				if item.Command == selected.Command {
+					reason = frequentReason(item, scope)
+				}
+			}
+			if selected.EditedFrom != "" {
+				reason = editedReason(reason, selected.EditedFrom)
+			}
+			if intent == router.IntentRun {
+				executeSuggested(selected.Command, "", reason, "", cfg, opts, intent)
+				return true
+			}
+			writeSuggestedCommandBlock(selected.Command, reason, "frequent", "", cfg, opts)
+			return true
+		}
+		if selectErr != nil {
+			fmt.Fprintf(os.Stderr, "ew: ui picker failed (%v); falling back to plain output\n", selectErr)
+		}
+	}
+
+	fmt.Printf("Most reused commands in %s:\n", scope)
+	for idx, item := range commands {
+		fmt.Printf("%d. %s (%dx)\n", idx+1, item.Command, item.Count)
+	}
+	fmt.Println("Tip: describe what you want, e.g. `ew --execute run the tests`")
+	return true
+}
+
+func frequentReason(item usage.CommandCount, scope string) string {
+	return fmt.Sprintf("used %d times in %s", item.Count, scope)
+}
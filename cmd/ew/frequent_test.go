@@ -0,0 +1,16 @@
+package main
+
+import "testing"
+
+func TestMeaninglessQuery(t *testing.T) {
+	for _, query := range []string{"", "something", "show me commands", "what should I run?"} {
+		if !meaninglessQuery(query) {
+			t.Fatalf("expected %q to be treated as an empty query", query)
+		}
+	}
+	for _, query := range []string{"ls", "run tests", "find large files"} {
+		if meaninglessQuery(query) {
+			t.Fatalf("expected %q to be searched normally", query)
+		}
+	}
+}
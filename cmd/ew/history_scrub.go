@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/history"
+	"github.com/ashwch/ew/internal/workspace"
+)
+
+// maxScrubSiblings bounds how many sibling project directories are read
+// for names to scrub.
+const maxScrubSiblings = 200
+
+// historySubcommand reports whether args are `ew history scrub ...`.
+func historySubcommand(args []string) ([]string, bool) {
+	if len(args) < 2 || args[0] != "history" || args[1] != "scrub" {
+		return nil, false
+	}
+	return args[2:], true
+}
+
+// scrubbedSearch is a history search with everything that identifies the
+// user replaced by placeholders, for attaching to a bug report.
+type scrubbedSearch struct {
+	Version string          `json:"version"`
+	OS      string          `json:"os"`
+	Query   string          `json:"query"`
+	Total   int             `json:"total"`
+	Matches []scrubbedMatch `json:"matches"`
+}
+
+// scrubbedMatch is one ranked history match. Kept says whether find's
+// filters (risk, read-only queries, minimum score) let it through.
+type scrubbedMatch struct {
+	Rank    int                `json:"rank"`
+	Command string             `json:"command"`
+	Score   float64            `json:"score"`
+	Source  string             `json:"source"`
+	Kept    bool               `json:"kept"`
+	Signals map[string]float64 `json:"signals,omitempty"`
+}
+
+// runHistoryScrub runs a search against the real history, as find would,
+// and prints the matches with user names, hosts, project names, and paths
+// replaced by stable placeholders. Scores and order are the real ones.
+func runHistoryScrub(args []string, stdout io.Writer) int {
+	fs := flag.NewFlagSet("ew history scrub", flag.ContinueOnError)
+	query := fs.String("query", "", "the search to reproduce")
+	limit := fs.Int("limit", 0, "how many matches to show (default find.max_results)")
+	names := fs.String("names", "", "more comma-separated names to scrub, such as a company or client")
+	asJSON := fs.Bool("json", false, "print JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), `usage: ew history scrub --query "..." [--limit n] [--names a,b] [--json]`)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return exitUsage
+	}
+	if strings.TrimSpace(*query) == "" {
+		fmt.Fprintln(os.Stderr, `ew: ew history scrub needs a query, e.g. ew history scrub --query "restart the api"`)
+		return exitUsage
+	}
+
+	cfg, _, err := config.LoadFile()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ew: could not load config: %v\n", err)
+		return 1
+	}
+	if release := useStateBackend(cfg); release != nil {
+		defer release()
+	}
+	history.SetSkipSecrets(cfg.History.Secrets == "skip")
+	history.SetViaEW(cfg.History.ViaEW)
+	useEmbeddings(cfg, options{})
+	if *limit <= 0 {
+		*limit = cfg.Find.MaxResults
+	}
+
+	results, err := history.SearchPage(invocationCtx, *query, history.Page{Limit: *limit})
+	if err != nil && !errors.Is(err, history.ErrNoHistory) {
+		fmt.Fprintf(os.Stderr, "ew: search failed: %v\n", err)
+		return 1
+	}
+	cwd, _ := os.Getwd()
+	extra := strings.Split(*names, ",")
+	host, _ := os.Hostname()
+	scrubber := history.NewScrubber(scrubUserNames(), []string{host}, append(scrubProjectNames(cwd), extra...))
+	search := buildScrubbedSearch(cfg, *query, results, scrubber)
+
+	if *asJSON {
+		if err := writeJSON(stdout, search); err != nil {
+			fmt.Fprintf(os.Stderr, "ew: could not encode result: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+	fmt.Fprintf(stdout, "ew %s on %s\n", search.Version, search.OS)
+	fmt.Fprintf(stdout, "History matches for %q (%d in all):\n", search.Query, search.Total)
+	for _, match := range search.Matches {
+		verdict := "kept"
+		if !match.Kept {
+			verdict = "dropped by find's filters"
+		}
+		fmt.Fprintf(stdout, "%d. %s\n   score: %.2f | source: %s | %s\n", match.Rank, match.Command, match.Score, match.Source, verdict)
+	}
+	fmt.Fprintln(stdout, "User names, hosts, project names, paths, and secrets are replaced; read it over before you share it.")
+	return 0
+}
+
+func buildScrubbedSearch(cfg config.Config, query string, results history.Results, scrubber *history.Scrubber) scrubbedSearch {
+	search := scrubbedSearch{
+		Version: version,
+		OS:      runtime.GOOS + "/" + runtime.GOARCH,
+		Query:   scrubber.Scrub(query),
+		Total:   results.Total,
+		Matches: []scrubbedMatch{},
+	}
+	for idx, match := range results.Matches {
+		search.Matches = append(search.Matches, scrubbedMatch{
+			Rank:    idx + 1,
+			Command: scrubber.Scrub(match.Command),
+			Score:   match.Score,
+			Source:  match.Source,
+			Kept:    len(filterFindMatches(cfg, query, []history.Match{match})) > 0,
+			Signals: match.Signals,
+		})
+	}
+	return search
+}
+
+// scrubUserNames are the account name, $USER, and the home directory's
+// name, which usually agree but need not.
+func scrubUserNames() []string {
+	names := []string{os.Getenv("USER")}
+	if current, err := user.Current(); err == nil {
+		// Windows names are DOMAIN\user.
+		names = append(names, current.Username[strings.LastIndex(current.Username, `\`)+1:])
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		names = append(names, filepath.Base(home))
+	}
+	return names
+}
+
+// scrubProjectNames are the names of the repository dir belongs to and of
+// dir itself, and, when the repository sits in a folder of projects such
+// as ~/src, the names of the other projects there.
+func scrubProjectNames(dir string) []string {
+	if dir == "" {
+		return nil
+	}
+	names := []string{filepath.Base(dir)}
+	root := dir
+	if repo, ok := workspace.DetectRepo(dir); ok {
+		root = repo.Root
+		names = append(names, filepath.Base(root))
+	}
+	parent := filepath.Dir(root)
+	if home, err := os.UserHomeDir(); err == nil && parent == filepath.Clean(home) || parent == root {
+		return names
+	}
+	entries, err := os.ReadDir(parent)
+	if err != nil {
+		return names
+	}
+	for _, entry := range entries[:min(len(entries), maxScrubSiblings)] {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			names = append(names, entry.Name())
+		}
+	}
+	return names
+}
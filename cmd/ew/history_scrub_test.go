@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHistorySubcommandOnlyTakesScrub(t *testing.T) {
+	if args, ok := historySubcommand([]string{"history", "scrub", "--query", "x"}); !ok || strings.Join(args, " ") != "--query x" {
+		t.Fatalf("expected the scrub verb, got %q %v", args, ok)
+	}
+	for _, args := range [][]string{{"history"}, {"history", "of", "git"}, {"show", "history"}} {
+		if _, ok := historySubcommand(args); ok {
+			t.Fatalf("expected %q to stay a request", args)
+		}
+	}
+}
+
+func TestHistoryScrubHidesNamesButKeepsScores(t *testing.T) {
+	isolateConfig(t)
+	home := os.Getenv("HOME")
+	history := "ssh deploy@db1.acme.internal 'systemctl restart api'\ncd /srv/acme-billing && ./restart-api.sh\n"
+	if err := os.WriteFile(filepath.Join(home, ".bash_history"), []byte(history), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if code := runHistoryScrub([]string{"--query", "restart api", "--names", "acme-billing", "--json"}, &out); code != 0 {
+		t.Fatalf("expected exit 0, got %d", code)
+	}
+	if text := out.String(); strings.Contains(text, "acme") || strings.Contains(text, "deploy@") {
+		t.Fatalf("expected names to be scrubbed, got %s", text)
+	}
+	var search scrubbedSearch
+	if err := json.Unmarshal(out.Bytes(), &search); err != nil {
+		t.Fatal(err)
+	}
+	if search.Query != "restart api" || len(search.Matches) != 2 || search.Matches[0].Rank != 1 || search.Matches[0].Score <= 0 {
+		t.Fatalf("expected two ranked matches, got %+v", search)
+	}
+
+	if code := runHistoryScrub(nil, &out); code != exitUsage {
+		t.Fatalf("expected a missing query to be a usage error, got %d", code)
+	}
+}
+
+func TestScrubProjectNamesReadsSiblingProjects(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	src := t.TempDir()
+	for _, name := range []string{"acme-billing", "acme-web", ".cache"} {
+		if err := os.Mkdir(filepath.Join(src, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names := strings.Join(scrubProjectNames(filepath.Join(src, "acme-billing")), " ")
+	if !strings.Contains(names, "acme-web") || strings.Contains(names, ".cache") {
+		t.Fatalf("expected sibling projects but not dot directories, got %q", names)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/history"
+	"github.com/ashwch/ew/internal/hook"
+	ewrt "github.com/ashwch/ew/internal/runtime"
+	"github.com/ashwch/ew/internal/safety"
+	"github.com/ashwch/ew/internal/session"
+)
+
+// Swapped out in tests.
+var (
+	appendShellHistory = history.Append
+	queueShellHistory  = hook.QueueHistory
+)
+
+// writeBackHistory adds a command ew just ran to the shell's own history
+// when history.write_back is on. A session whose hooks drain the queue
+// gets it in memory through `ew internal history-take`; anything else has it
+// appended to the history file. Remote runs are left out, since the
+// command never ran in this shell, and so are commands carrying a secret.
+// --no-record and journal.privacy off keep it out as well.
+func writeBackHistory(cfg config.Config, backend ewrt.Backend, command string) {
+	command = strings.TrimSpace(command)
+	if !cfg.History.WriteBack || command == "" || backend.Remote() || session.Privacy() == session.PrivacyOff {
+		return
+	}
+	if safety.RedactText(command) != command {
+		return
+	}
+
+	shell := history.ShellName(interactiveShell())
+	command = watermarkHistory(cfg, shell, command)
+	sessionID := strings.TrimSpace(os.Getenv("EW_SESSION_ID"))
+	if historyHookActive(shell, sessionID) && !strings.Contains(command, "\n") {
+		if err := queueShellHistory(sessionID, command); err == nil {
+			return
+		}
+	}
+	if _, err := appendShellHistory(shell, command, time.Now()); err != nil {
+		fmt.Fprintf(os.Stderr, "ew: could not add the command to your shell history: %v\n", err)
+	}
+}
+
+// watermarkHistory ends command with history.Watermark when
+// history.watermark is on and shell will read the mark back as a comment:
+// bash, fish, nushell, and PowerShell always do, zsh only with
+// interactive_comments set.
+func watermarkHistory(cfg config.Config, shell, command string) string {
+	if !cfg.History.Watermark {
+		return command
+	}
+	switch shell {
+	case "bash", "fish", "nu", "powershell":
+	case "zsh":
+		if os.Getenv(hook.InteractiveCommentsEnv) != "1" {
+			return command
+		}
+	default:
+		return command
+	}
+	return command + "  " + history.Watermark
+}
+
+// historyHookActive reports whether the shell's hook snippet takes queued
+// history after each `ew` command. fish reads its history file back with
+// `history merge`, and nushell cannot add to its history at all, so both
+// are always written directly.
+func historyHookActive(shell, sessionID string) bool {
+	if sessionID == "" || hook.IsSyntheticSessionID(sessionID) || os.Getenv(hook.HistoryHookEnv) != "1" {
+		return false
+	}
+	return shell == "zsh" || shell == "bash" || shell == "powershell"
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ashwch/ew/internal/config"
+	ewrt "github.com/ashwch/ew/internal/runtime"
+)
+
+func TestWriteBackHistoryQueuesForHookedSessionsAndAppendsOtherwise(t *testing.T) {
+	previousShell := interactiveShell
+	previousAppend, previousQueue := appendShellHistory, queueShellHistory
+	t.Cleanup(func() {
+		interactiveShell = previousShell
+		appendShellHistory, queueShellHistory = previousAppend, previousQueue
+	})
+
+	var appended, queued []string
+	appendShellHistory = func(shell, command string, _ time.Time) (string, error) {
+		appended = append(appended, shell+": "+command)
+		return "", nil
+	}
+	queueShellHistory = func(sessionID, command string) error {
+		queued = append(queued, sessionID+": "+command)
+		return nil
+	}
+	interactiveShell = func() string { return "zsh" }
+	local, _ := ewrt.ParseTarget("local")
+	remote, err := ewrt.ParseTarget("ssh:web")
+	if err != nil {
+		t.Fatalf("ParseTarget failed: %v", err)
+	}
+
+	cfg := config.Default()
+	writeBackHistory(cfg, local, "git status")
+	if len(appended)+len(queued) != 0 {
+		t.Fatalf("expected nothing written while history.write_back is off")
+	}
+
+	cfg.History.WriteBack = true
+	t.Setenv("EW_SESSION_ID", "123.456")
+	t.Setenv("EW_HISTORY_HOOK", "1")
+	writeBackHistory(cfg, local, "git status")
+	writeBackHistory(cfg, local, "for f in *; do\necho $f\ndone")
+	writeBackHistory(cfg, remote, "uptime")
+	writeBackHistory(cfg, local, "export GITHUB_TOKEN=abc123")
+
+	t.Setenv("EW_HISTORY_HOOK", "")
+	writeBackHistory(cfg, local, "make test")
+
+	if len(queued) != 1 || queued[0] != "123.456: git status" {
+		t.Fatalf("expected only the single-line local command queued, got %v", queued)
+	}
+	if len(appended) != 2 || appended[0] != "zsh: for f in *; do\necho $f\ndone" || appended[1] != "zsh: make test" {
+		t.Fatalf("expected multi-line and unhooked commands appended to the file, got %v", appended)
+	}
+}
+
+func TestWriteBackHistoryWatermarksWhereTheShellReadsComments(t *testing.T) {
+	previousShell := interactiveShell
+	previousAppend := appendShellHistory
+	t.Cleanup(func() {
+		interactiveShell = previousShell
+		appendShellHistory = previousAppend
+	})
+
+	var appended []string
+	appendShellHistory = func(shell, command string, _ time.Time) (string, error) {
+		appended = append(appended, shell+": "+command)
+		return "", nil
+	}
+	local, _ := ewrt.ParseTarget("local")
+	cfg := config.Default()
+	cfg.History.WriteBack = true
+	t.Setenv("EW_SESSION_ID", "")
+	t.Setenv("EW_INTERACTIVE_COMMENTS", "")
+
+	for _, shell := range []string{"bash", "fish", "pwsh", "zsh"} {
+		interactiveShell = func() string { return shell }
+		writeBackHistory(cfg, local, "git status")
+	}
+	t.Setenv("EW_INTERACTIVE_COMMENTS", "1")
+	writeBackHistory(cfg, local, "git status")
+	cfg.History.Watermark = false
+	interactiveShell = func() string { return "bash" }
+	writeBackHistory(cfg, local, "git status")
+
+	want := []string{
+		"bash: git status  # via ew",
+		"fish: git status  # via ew",
+		"powershell: git status  # via ew",
+		"zsh: git status",
+		"zsh: git status  # via ew",
+		"bash: git status",
+	}
+	if strings.Join(appended, "|") != strings.Join(want, "|") {
+		t.Fatalf("expected %q, got %q", want, appended)
+	}
+}
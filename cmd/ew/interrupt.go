@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"golang.org/x/term"
+
+	"github.com/ashwch/ew/internal/config"
+	ewrt "github.com/ashwch/ew/internal/runtime"
+	"github.com/ashwch/ew/internal/session"
+)
+
+// Exit codes for an invocation stopped by a signal follow the shell
+// convention of 128 plus the signal number.
+const (
+	exitInterrupted = 130
+	exitTerminated  = 143
+)
+
+// invocationCtx is cancelled on the first SIGINT or SIGTERM, so history
+// scans, the system profile capture, provider subprocesses, and plan
+// previews stop instead of running on. Commands ew executes for the user get
+// the signal from the terminal themselves and are not tied to it.
+var invocationCtx = context.Background()
+
+var (
+	interruptMu   sync.Mutex
+	interruptCode int
+	exitHooks     []func()
+	exitOnce      sync.Once
+	// terminalState is stdin's mode before any UI put it in raw mode.
+	terminalState *term.State
+	// softInterrupt, while set, takes the next Ctrl-C instead of the
+	// invocation: it stops one slow step and ew carries on without it.
+	softInterrupt atomic.Pointer[func()]
+)
+
+// exitProcess is swapped out in tests.
+var exitProcess = os.Exit
+
+// watchInterrupt installs invocationCtx. The first signal only cancels it:
+// the step in progress returns and main exits through exitIfInterrupted,
+// so the exit hooks never race the code still writing state. After it the
+// default behaviour is restored, so a second Ctrl-C kills ew outright, for
+// a step that ignores invocationCtx such as a plain y/N prompt.
+func watchInterrupt() func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	invocationCtx = ctx
+	if isTerminal(os.Stdin) {
+		terminalState, _ = term.GetState(int(os.Stdin.Fd()))
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		var sig os.Signal
+		for sig == nil {
+			select {
+			case received := <-signals:
+				if received == os.Interrupt && takeSoftInterrupt() {
+					continue
+				}
+				sig = received
+			case <-ctx.Done():
+				return
+			}
+		}
+		signal.Stop(signals)
+		interruptMu.Lock()
+		interruptCode = exitCodeForSignal(sig)
+		interruptMu.Unlock()
+		cancel()
+	}()
+	return func() {
+		signal.Stop(signals)
+		cancel()
+	}
+}
+
+// takeSoftInterrupt runs and clears softInterrupt, and reports whether
+// there was one.
+func takeSoftInterrupt() bool {
+	stop := softInterrupt.Swap(nil)
+	if stop == nil {
+		return false
+	}
+	(*stop)()
+	return true
+}
+
+func exitCodeForSignal(sig os.Signal) int {
+	if sig == syscall.SIGTERM {
+		return exitTerminated
+	}
+	return exitInterrupted
+}
+
+// atExit registers a step that must run however the invocation ends: main
+// runs the steps when it returns, and exitIfInterrupted runs them before
+// exiting. They run newest first, like defers.
+func atExit(hook func()) {
+	if hook == nil {
+		return
+	}
+	interruptMu.Lock()
+	defer interruptMu.Unlock()
+	exitHooks = append(exitHooks, hook)
+}
+
+// runExitHooks runs each registered step once.
+func runExitHooks() {
+	interruptMu.Lock()
+	hooks := exitHooks
+	exitHooks = nil
+	interruptMu.Unlock()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i]()
+	}
+}
+
+// exitWith ends the invocation with code after running the exit hooks.
+// Handlers that stop early call it instead of os.Exit, which would skip
+// main's deferred runExitHooks and with it the session journal, the state
+// backend, and the provider temp dirs.
+func exitWith(code int) {
+	runExitHooks()
+	exitProcess(code)
+}
+
+// exitIfInterrupted ends the invocation after a cancelled step instead of
+// reporting "context canceled" as if the step had failed. The session
+// journal and the state backend are flushed and the terminal is put back
+// before exiting, so a signal never leaves half-written state or a raw,
+// alternate-screen terminal behind. It runs on the main goroutine only:
+// after a cancelled step, and deferred in main for a step that finished
+// without noticing.
+func exitIfInterrupted() {
+	interruptMu.Lock()
+	code := interruptCode
+	interruptMu.Unlock()
+	if code == 0 {
+		return
+	}
+	exitOnce.Do(func() {
+		if runtimeInteraction != nil {
+			runtimeInteraction.Decision = session.DecisionInterrupted
+		}
+		runExitHooks()
+		restoreTerminal()
+		fmt.Fprintln(os.Stderr, "ew: interrupted")
+		exitProcess(code)
+	})
+}
+
+// runUserCommand runs the command the user approved. A signal during it is
+// the command's to handle; ew carries on, or stops, once it returns.
+func runUserCommand(cfg config.Config, backend ewrt.Backend, command string) error {
+	defer writeBackHistory(cfg, backend, command)
+	noteUndoBefore(backend, command)
+	return ewrt.RunCommandOn(backend, command)
+}
+
+// restoreTerminal leaves the alternate screen, shows the cursor, and puts
+// stdin back in the mode it had at startup.
+func restoreTerminal() {
+	if !isTerminal(os.Stderr) {
+		return
+	}
+	fmt.Fprint(os.Stderr, "\x1b[?1049l\x1b[?25h")
+	if terminalState != nil {
+		_ = term.Restore(int(os.Stdin.Fd()), terminalState)
+	}
+}
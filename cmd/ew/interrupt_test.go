@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ashwch/ew/internal/router"
+	"github.com/ashwch/ew/internal/session"
+)
+
+func TestExitIfInterruptedFlushesBeforeExiting(t *testing.T) {
+	originalExit := exitProcess
+	t.Cleanup(func() {
+		exitProcess = originalExit
+		interruptCode = 0
+		exitHooks = nil
+		exitOnce = sync.Once{}
+		runtimeInteraction = nil
+	})
+
+	exitCode := -1
+	exitProcess = func(code int) { exitCode = code }
+	var order []string
+	atExit(func() { order = append(order, "temp dirs") })
+	atExit(func() { order = append(order, "session") })
+
+	exitIfInterrupted()
+	if exitCode != -1 || len(order) != 0 {
+		t.Fatalf("expected no exit without a signal, got code %d and hooks %v", exitCode, order)
+	}
+
+	beginSessionInteraction("list files", router.IntentFind)
+	interaction := runtimeInteraction
+	interruptCode = exitCodeForSignal(syscall.SIGTERM)
+	exitIfInterrupted()
+	if exitCode != exitTerminated {
+		t.Fatalf("expected exit %d for SIGTERM, got %d", exitTerminated, exitCode)
+	}
+	if len(order) != 2 || order[0] != "session" || order[1] != "temp dirs" {
+		t.Fatalf("expected hooks to run newest first, got %v", order)
+	}
+	if interaction.Decision != session.DecisionInterrupted {
+		t.Fatalf("expected the interaction to be marked interrupted, got %q", interaction.Decision)
+	}
+
+	exitIfInterrupted()
+	if len(order) != 2 {
+		t.Fatalf("expected hooks to run once, got %v", order)
+	}
+}
+
+func TestSoftInterruptTakesOneCtrlC(t *testing.T) {
+	t.Cleanup(func() { softInterrupt.Store(nil) })
+	if takeSoftInterrupt() {
+		t.Fatalf("expected no soft interrupt to be armed")
+	}
+	stopped := 0
+	stop := func() { stopped++ }
+	softInterrupt.Store(&stop)
+	if !takeSoftInterrupt() || stopped != 1 {
+		t.Fatalf("expected the armed step to be stopped once, got %d", stopped)
+	}
+	if takeSoftInterrupt() || stopped != 1 {
+		t.Fatalf("expected the next Ctrl-C to go to the invocation, stopped %d times", stopped)
+	}
+}
+
+func TestExitWithRunsExitHooksFirst(t *testing.T) {
+	originalExit := exitProcess
+	t.Cleanup(func() {
+		exitProcess = originalExit
+		exitHooks = nil
+	})
+
+	var order []string
+	exitProcess = func(code int) { order = append(order, fmt.Sprintf("exit %d", code)) }
+	atExit(func() { order = append(order, "session") })
+
+	exitWith(exitFailure)
+	if strings.Join(order, ", ") != "session, exit 1" {
+		t.Fatalf("expected the hooks to run before exiting, got %v", order)
+	}
+}
+
+func TestFirstSignalOnlyCancelsTheInvocation(t *testing.T) {
+	originalExit := exitProcess
+	t.Cleanup(func() {
+		exitProcess = originalExit
+		interruptCode = 0
+		exitHooks = nil
+		invocationCtx = context.Background()
+	})
+
+	var exited atomic.Bool
+	exitProcess = func(int) { exited.Store(true) }
+	hookRan := false
+	atExit(func() { hookRan = true })
+
+	stop := watchInterrupt()
+	defer stop()
+	ctx := invocationCtx
+	self, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := self.Signal(syscall.SIGTERM); err != nil {
+		t.Fatalf("signal failed: %v", err)
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the signal to cancel the invocation")
+	}
+
+	interruptMu.Lock()
+	code := interruptCode
+	interruptMu.Unlock()
+	if code != exitTerminated {
+		t.Fatalf("expected exit code %d to be recorded, got %d", exitTerminated, code)
+	}
+	// Main exits through exitIfInterrupted; the signal goroutine must not
+	// run the hooks while main may still be writing.
+	time.Sleep(50 * time.Millisecond)
+	if exited.Load() || hookRan {
+		t.Fatalf("expected the signal goroutine to leave exiting to main, exited=%v hooks=%v", exited.Load(), hookRan)
+	}
+}
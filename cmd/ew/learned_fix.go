@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ashwch/ew/internal/hook"
+	"github.com/ashwch/ew/internal/memory"
+)
+
+// learnedFix returns the command that fixed failed before, learned from
+// hook events where a failure was soon followed by a similar command that
+// succeeded. It first pairs up the events it has not seen yet, saving what
+// it learns unless save is false (--trace-plan).
+func learnedFix(failed string, save bool) (string, string) {
+	fixes, err := memory.LoadFixes()
+	if err != nil {
+		return "", ""
+	}
+	if events, err := hook.RecentEvents(frequentEventWindow); err == nil {
+		if fixes.Learn(events, time.Now().UTC()) && save {
+			_ = memory.SaveFixes(fixes)
+		}
+	}
+	command, entry, ok := fixes.Lookup(failed)
+	if !ok {
+		return "", ""
+	}
+	times := "once"
+	if entry.Count > 1 {
+		times = fmt.Sprintf("%d times", entry.Count)
+	}
+	return command, fmt.Sprintf("learned fix: you ran %s after %s failed (%s)", entry.Command, entry.Failed, times)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ashwch/ew/internal/hook"
+	"github.com/ashwch/ew/internal/memory"
+)
+
+func TestLearnedFixComesFromHookEventsAndTracingDoesNotSaveIt(t *testing.T) {
+	isolateConfig(t)
+	at := time.Now().UTC().Add(-time.Hour)
+	for idx, ev := range []hook.Event{
+		{Command: "pyhton app.py", ExitCode: 127},
+		{Command: "python app.py", ExitCode: 0},
+	} {
+		ev.CWD, ev.Shell, ev.SessionID = "/repo", "zsh", "s1"
+		ev.Timestamp = at.Add(time.Duration(idx) * 10 * time.Second).Format(time.RFC3339)
+		if err := hook.RecordEvent(ev); err != nil {
+			t.Fatalf("RecordEvent failed: %v", err)
+		}
+	}
+
+	command, reason := learnedFix("pyhton app.py", false)
+	if command != "python app.py" || !strings.Contains(reason, "learned fix") {
+		t.Fatalf("expected the learned fix, got %q (%s)", command, reason)
+	}
+	if fixes, err := memory.LoadFixes(); err != nil || len(fixes.Entries) != 0 {
+		t.Fatalf("expected nothing saved while tracing, got %+v %v", fixes, err)
+	}
+
+	learnedFix("pyhton app.py", true)
+	fixes, err := memory.LoadFixes()
+	if err != nil || len(fixes.Entries) != 1 || fixes.ScannedUntil == "" {
+		t.Fatalf("expected the pair to be saved once, got %+v %v", fixes, err)
+	}
+}
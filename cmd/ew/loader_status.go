@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/ashwch/ew/internal/ui"
+	"golang.org/x/term"
+)
+
+// loaderStopping replaces the loader message once Ctrl-C has cancelled the
+// step, while the provider is being stopped.
+const loaderStopping = "stopping..."
+
+// loaderStatus is what a streaming step has reported to the loader so far.
+type loaderStatus struct {
+	mu      sync.Mutex
+	partial string
+	// started and hint are set once a slow step offers a way out: the
+	// loader then shows how long it has been waiting and the hint in place
+	// of the step's output.
+	started time.Time
+	hint    string
+	// provisional is the command the caller falls back on, shown as
+	// "for now" until the step ends.
+	provisional string
+}
+
+func (s *loaderStatus) setPartial(partial string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.partial = partial
+}
+
+// offerSkip switches the loader to counting the time since started and
+// showing hint.
+func (s *loaderStatus) offerSkip(started time.Time, hint string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.started, s.hint = started, hint
+}
+
+// line is one frame of the loader: the animation, the message, and the
+// step's latest output, cut to fit in width columns so the line never
+// wraps and \r can redraw it. width 0 means no limit.
+func (s *loaderStatus) line(frame, message string, stopping bool, width int) string {
+	if stopping {
+		message = loaderStopping
+	}
+	line := frame + " " + ui.ASCII(message)
+	if s != nil && !stopping {
+		s.mu.Lock()
+		partial, started, hint := s.partial, s.started, s.hint
+		s.mu.Unlock()
+		if hint != "" {
+			line += fmt.Sprintf(" (%ds)", int(time.Since(started).Seconds()))
+		}
+		if s.provisional != "" {
+			line += " | for now: " + ui.ASCII(loaderPartialText(s.provisional))
+		}
+		if hint != "" {
+			line += " | " + ui.ASCII(hint)
+		} else if partial = loaderPartialText(partial); partial != "" {
+			line += " | " + ui.ASCII(partial)
+		}
+	}
+	if width <= 0 {
+		return line
+	}
+	return clipRunes(line, width-1)
+}
+
+// loaderPartialText makes a provider's output line safe to draw in place:
+// escape sequences and control characters are dropped and runs of spaces
+// collapsed.
+func loaderPartialText(text string) string {
+	var b strings.Builder
+	inEscape := false
+	for _, r := range text {
+		switch {
+		case inEscape:
+			// CSI sequences end with a letter; anything else after ESC is
+			// a two-character sequence.
+			if unicode.IsLetter(r) || r == '~' {
+				inEscape = false
+			}
+		case r == '\x1b':
+			inEscape = true
+		case unicode.IsControl(r):
+			b.WriteRune(' ')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// clipRunes cuts text to at most limit runes, marking the cut with "...".
+func clipRunes(text string, limit int) string {
+	runes := []rune(text)
+	if limit <= 0 || len(runes) <= limit {
+		return text
+	}
+	if limit <= 3 {
+		return string(runes[:limit])
+	}
+	return string(runes[:limit-3]) + "..."
+}
+
+// loaderWidth is the width of the terminal the loader draws on, or 0 when
+// it is unknown.
+func loaderWidth() int {
+	if width, _, err := term.GetSize(int(os.Stderr.Fd())); err == nil && width > 0 {
+		return width
+	}
+	return 0
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoaderStatusLineShowsLatestOutput(t *testing.T) {
+	status := &loaderStatus{}
+	if got := status.line("ew   ", "thinking", false, 0); got != "ew    thinking" {
+		t.Fatalf("unexpected line before any output %q", got)
+	}
+
+	status.setPartial("\x1b[2mreading\x1b[0m   the\tdocs")
+	if got := status.line("ew   ", "thinking", false, 0); got != "ew    thinking | reading the docs" {
+		t.Fatalf("expected cleaned partial output, got %q", got)
+	}
+	if got := status.line("ew   ", "thinking", false, 20); got != "ew    thinking |..." || len([]rune(got)) != 19 {
+		t.Fatalf("expected the line cut to the terminal width, got %q", got)
+	}
+	if got := status.line("ew   ", "thinking", true, 0); got != "ew    "+loaderStopping {
+		t.Fatalf("expected the stopping message after Ctrl-C, got %q", got)
+	}
+}
+
+func TestLoaderStatusLineOffersSkipOnceSlow(t *testing.T) {
+	status := &loaderStatus{}
+	status.setPartial("reading the docs")
+	status.offerSkip(time.Now().Add(-6*time.Second), providerSkipHint)
+	got := status.line("ew   ", "ranking", false, 0)
+	if !strings.HasPrefix(got, "ew    ranking (6s) | ") || !strings.HasSuffix(got, providerSkipHint) {
+		t.Fatalf("expected the elapsed time and hint in place of the output, got %q", got)
+	}
+	if got := status.line("ew   ", "ranking", true, 0); got != "ew    "+loaderStopping {
+		t.Fatalf("expected the stopping message to win over the hint, got %q", got)
+	}
+}
+
+func TestLoaderStatusLineShowsTheProvisionalPick(t *testing.T) {
+	status := &loaderStatus{provisional: "git log --oneline"}
+	status.setPartial("reading")
+	if got := status.line("ew   ", "ranking", false, 0); got != "ew    ranking | for now: git log --oneline | reading" {
+		t.Fatalf("expected the provisional pick before the output, got %q", got)
+	}
+	status.offerSkip(time.Now().Add(-6*time.Second), providerSkipHint)
+	if got := status.line("ew   ", "ranking", false, 0); got != "ew    ranking (6s) | for now: git log --oneline | "+providerSkipHint {
+		t.Fatalf("expected the elapsed time, the pick, and the hint, got %q", got)
+	}
+}
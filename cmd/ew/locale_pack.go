@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/ashwch/ew/internal/i18n"
+)
+
+// localeVerbs are the words `ew locale` takes.
+var localeVerbs = []string{"scaffold", "check"}
+
+// localeSubcommand recognises `ew locale scaffold|check ...` and returns the
+// verb and its arguments. Anything else after "locale" is an ordinary
+// request, so `ew locale settings on linux` still finds.
+func localeSubcommand(args []string) ([]string, bool) {
+	if len(args) < 2 || args[0] != "locale" || !slices.Contains(localeVerbs, args[1]) {
+		return nil, false
+	}
+	return args[1:], true
+}
+
+// runLocaleCommand starts or validates a community locale pack and returns
+// the exit code. Neither verb reads the config.
+func runLocaleCommand(args []string, stdout io.Writer) int {
+	verb := args[0]
+	fs := flag.NewFlagSet("ew locale "+verb, flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "check: print the report as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: ew locale scaffold <code> | check [--json] <file>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return exitUsage
+	}
+
+	switch verb {
+	case "scaffold":
+		if fs.NArg() != 1 || i18n.NormalizeLocale(fs.Arg(0)) == "" {
+			fmt.Fprintln(os.Stderr, "ew: ew locale scaffold needs a locale code such as es or pt-BR")
+			return exitUsage
+		}
+		return scaffoldLocale(fs.Arg(0), stdout)
+	default:
+		if fs.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, "ew: ew locale check needs a pack, e.g. ew locale check ~/.config/ew/locales/es-ES.json")
+			return exitUsage
+		}
+		return checkLocale(fs.Arg(0), *asJSON, stdout)
+	}
+}
+
+// scaffoldLocale prints a starter community pack for locale, ready to
+// redirect into the config dir's locales/ directory.
+func scaffoldLocale(locale string, stdout io.Writer) int {
+	payload, err := i18n.Scaffold(locale)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ew: %v\n", err)
+		return 1
+	}
+	_, _ = stdout.Write(payload)
+	return 0
+}
+
+// checkLocale validates a community pack and loads it back the way ew
+// would. It exits 1 when the pack has errors, so it can gate a PR.
+func checkLocale(path string, asJSON bool, stdout io.Writer) int {
+	report, err := i18n.CheckPack(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ew: %v\n", err)
+		return 1
+	}
+	code := 0
+	if !report.OK() {
+		code = 1
+	}
+	if asJSON {
+		if err := writeJSON(stdout, report); err != nil {
+			fmt.Fprintf(os.Stderr, "ew: could not encode report: %v\n", err)
+			return 1
+		}
+		return code
+	}
+	if report.OK() {
+		fmt.Fprintf(stdout, "locale pack %s: %d phrases, loads cleanly\n", report.Locale, report.Phrases)
+	} else {
+		fmt.Fprintf(stdout, "locale pack %s has %d errors\n", path, len(report.Errors))
+	}
+	for _, problem := range report.Errors {
+		fmt.Fprintln(stdout, "error: "+problem)
+	}
+	for _, problem := range report.Warnings {
+		fmt.Fprintln(stdout, "warning: "+problem)
+	}
+	return code
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocaleSubcommandOnlyTakesKnownVerbs(t *testing.T) {
+	if args, ok := localeSubcommand([]string{"locale", "scaffold", "es-ES"}); !ok || strings.Join(args, " ") != "scaffold es-ES" {
+		t.Fatalf("expected the scaffold verb, got %v %v", args, ok)
+	}
+	for _, args := range [][]string{{"locale"}, {"locale", "settings", "on", "linux"}, {"set", "locale", "check"}} {
+		if _, ok := localeSubcommand(args); ok {
+			t.Fatalf("expected %q to stay a request", args)
+		}
+	}
+}
+
+func TestLocaleScaffoldAndCheckRoundTrip(t *testing.T) {
+	var out bytes.Buffer
+	if code := runLocaleCommand([]string{"scaffold", "spanish please"}, &out); code != exitUsage {
+		t.Fatalf("expected an invalid locale code to exit %d, got %d", exitUsage, code)
+	}
+	if code := runLocaleCommand([]string{"scaffold", "es-ES"}, &out); code != 0 || !strings.Contains(out.String(), `"locale"`) {
+		t.Fatalf("expected a pack, got %q (exit %d)", out.String(), code)
+	}
+
+	path := filepath.Join(t.TempDir(), "es-ES.json")
+	if err := os.WriteFile(path, out.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if code := runLocaleCommand([]string{"check", path}, &out); code != 0 || !strings.Contains(out.String(), "loads cleanly") {
+		t.Fatalf("expected the scaffold to check cleanly, got %q (exit %d)", out.String(), code)
+	}
+	if code := runLocaleCommand([]string{"check"}, &out); code != exitUsage {
+		t.Fatalf("expected a missing pack to exit %d, got %d", exitUsage, code)
+	}
+}
@@ -3,10 +3,10 @@ package main
 import (
 	"bufio"
 	"context"
-	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -17,9 +17,13 @@ import (
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
-	"github.com/ashwch/ew/internal/appdirs"
+	"github.com/ashwch/ew/internal/analysis"
+	"github.com/ashwch/ew/internal/cheats"
 	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/doctor"
+	"github.com/ashwch/ew/internal/helper"
 	"github.com/ashwch/ew/internal/history"
 	"github.com/ashwch/ew/internal/hook"
 	"github.com/ashwch/ew/internal/i18n"
@@ -29,17 +33,22 @@ import (
 	"github.com/ashwch/ew/internal/router"
 	ewrt "github.com/ashwch/ew/internal/runtime"
 	"github.com/ashwch/ew/internal/safety"
+	"github.com/ashwch/ew/internal/state"
 	"github.com/ashwch/ew/internal/systemprofile"
+	"github.com/ashwch/ew/internal/tips"
 	"github.com/ashwch/ew/internal/ui"
+	"github.com/ashwch/ew/internal/workspace"
 )
 
 var version = "dev"
 
-const maxFixFailureAge = 60 * time.Minute
 const maxInferredHistoryAge = 90 * time.Second
+const orphanedTempDirAge = time.Hour
 
 var localeCatalog = i18n.LoadCatalog("")
 var runtimeSystemContext = ""
+var runtimeSystemTools []string
+var runtimeSystemShell = ""
 
 type options struct {
 	Model      string
@@ -48,30 +57,108 @@ type options struct {
 	Locale     string
 	Mode       string
 	UI         string
+	Target     string
 	Intent     string
 	Save       bool
 	Yes        bool
 	JSON       bool
 	DryRun     bool
 	Offline    bool
+	NoRecord   bool
+	NoCache    bool
 	Version    bool
 	Copy       bool
 	Quiet      bool
+	Verbose    bool
 	Execute    bool
 	ShowConfig bool
 	Doctor     bool
+	Probe      bool
+	UpdateTLDR bool
 	SetupHooks bool
+
+	ImportCheats string
+	// DismissTip hides a usage tip (or all of them) for good.
+	DismissTip string
+
+	EditMemory bool
+	// BootstrapMemory proposes memory entries for the commands that come up
+	// most in shell history.
+	BootstrapMemory bool
+	Top             bool
+
+	FailedCommand string
+	ErrorText     string
+	ExitCode      int
+
+	// Passthrough is set by `ew run -- <cmd>`: the prompt is the literal
+	// command to run.
+	Passthrough bool
+	// Explain breaks the command for the prompt down part by part instead
+	// of suggesting it.
+	Explain bool
+	// Preview shows the command's side effects before it runs, and asks
+	// even in yolo mode.
+	Preview bool
+	// TracePlan shows how the prompt would be handled without asking a
+	// provider or running anything.
+	TracePlan bool
+	// ContextFiles are attached to a fix request's prompt.
+	ContextFiles []string
+	// PlanSteps and PlanStep are set while one step of a multi-step fix
+	// runs: all of the plan's commands, and which of them (1-based) this is.
+	PlanSteps []string
+	PlanStep  int
+	// YoloFor lets this invocation auto-execute only commands matching one
+	// of these patterns; anything else is confirmed.
+	YoloFor []string
+
+	Offset int
+	// NoRerank keeps the history ranking for this invocation instead of
+	// letting a provider rerank it.
+	NoRerank bool
+	// IncludeRaw adds the provider's raw response and parse path to --json
+	// output.
+	IncludeRaw bool
 }
 
 type response struct {
-	Intent      string      `json:"intent"`
-	Message     string      `json:"message,omitempty"`
-	Command     string      `json:"command,omitempty"`
-	Results     interface{} `json:"results,omitempty"`
-	Risk        string      `json:"risk,omitempty"`
-	Executed    bool        `json:"executed,omitempty"`
-	ConfigPath  string      `json:"config_path,omitempty"`
-	Suggestions []string    `json:"suggestions,omitempty"`
+	Intent      string            `json:"intent"`
+	Message     string            `json:"message,omitempty"`
+	Command     string            `json:"command,omitempty"`
+	Results     interface{}       `json:"results,omitempty"`
+	Risk        string            `json:"risk,omitempty"`
+	Executed    bool              `json:"executed,omitempty"`
+	Target      string            `json:"target,omitempty"`
+	ConfigPath  string            `json:"config_path,omitempty"`
+	Suggestions []string          `json:"suggestions,omitempty"`
+	Workspace   *workspace.Status `json:"workspace,omitempty"`
+	Warning     string            `json:"warning,omitempty"`
+	// Alternative is the command as suggested when Command is ew's safer
+	// rewrite of it (a trash tool for rm, the project's package manager).
+	Alternative string            `json:"alternative,omitempty"`
+	Plan        *ewrt.PlanSummary `json:"plan,omitempty"`
+	Degraded    []string          `json:"degraded,omitempty"`
+	Sources     *findSources      `json:"sources,omitempty"`
+	// Effects is --preview's reading of what Command would touch.
+	Effects *analysis.Effects `json:"effects,omitempty"`
+	// RiskReasons say why Command, not yet run, is rated Risk.
+	RiskReasons []ui.RiskReason `json:"risk_reasons,omitempty"`
+	// Steps are the commands of a multi-step fix, in order; Command is the
+	// first of them.
+	Steps []string `json:"steps,omitempty"`
+	// Explanation is --explain's part-by-part breakdown of Command.
+	Explanation []provider.ExplainPart `json:"explanation,omitempty"`
+	// Provider and Model name the provider and concrete model that produced
+	// Command, when one did.
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+	// FallbackFrom lists the providers that failed, in order, before
+	// Provider answered.
+	FallbackFrom []string `json:"fallback_from,omitempty"`
+	// ProviderRaw is the last provider answer as received, with
+	// --include-raw.
+	ProviderRaw *providerRaw `json:"provider_raw,omitempty"`
 }
 
 type selfPromptActionKind string
@@ -82,6 +169,7 @@ const (
 	selfActionSetupHooks selfPromptActionKind = "setup_hooks"
 	selfActionDiagnose   selfPromptActionKind = "diagnose"
 	selfActionConfigSet  selfPromptActionKind = "config_set"
+	selfActionCacheClear selfPromptActionKind = "cache_clear"
 )
 
 type selfPromptAction struct {
@@ -99,64 +187,163 @@ const (
 	memoryActionForget memoryPromptActionKind = "forget"
 	memoryActionBoost  memoryPromptActionKind = "promote"
 	memoryActionDrop   memoryPromptActionKind = "demote"
+	memoryActionUndo   memoryPromptActionKind = "undo"
+	memoryActionSeed   memoryPromptActionKind = "bootstrap"
+)
+
+// Where "remember here ..." and "remember everywhere ..." put an entry. A
+// plain remember keeps the scope the entry already has.
+const (
+	memoryScopeKeep   = ""
+	memoryScopeHere   = "here"
+	memoryScopeGlobal = "global"
 )
 
 type memoryPromptAction struct {
 	Kind    memoryPromptActionKind
 	Query   string
 	Command string
+	Scope   string
 }
 
 type executionOutcome struct {
 	Command  string
 	Executed bool
 	Success  bool
+	// Cancelled means the user declined the confirmation prompt.
+	Cancelled bool
 }
 
 func main() {
+	if sub, ok := internalSubcommand(os.Args[1:]); ok {
+		os.Exit(helper.Run("ew internal", version, sub))
+	}
+	if args, ok := debugSubcommand(os.Args[1:]); ok {
+		os.Exit(runDebug(args, os.Stdin, os.Stdout))
+	}
+	if args, ok := configSubcommand(os.Args[1:]); ok {
+		os.Exit(runConfigCommand(args, os.Stdout))
+	}
+	if args, ok := historySubcommand(os.Args[1:]); ok {
+		os.Exit(runHistoryScrub(args, os.Stdout))
+	}
+	if args, ok := sessionSubcommand(os.Args[1:]); ok {
+		os.Exit(runSessionCommand(args, os.Stdout))
+	}
+	if args, ok := localeSubcommand(os.Args[1:]); ok {
+		os.Exit(runLocaleCommand(args, os.Stdout))
+	}
+	if shell, ok := completionSubcommand(os.Args[1:]); ok {
+		if err := writeCompletion(os.Stdout, shell); err != nil {
+			fmt.Fprintf(os.Stderr, "ew: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	stopInterrupt := watchInterrupt()
+	defer stopInterrupt()
+	defer runExitHooks()
+	defer exitIfInterrupted()
+
+	if locale := flagAliasLocale(os.Args[1:]); locale != "" {
+		localeCatalog = i18n.LoadCatalog(locale)
+	}
 	opts, prompt, err := parseArgs(os.Args[1:])
 	if err != nil {
 		if errors.Is(err, flag.ErrHelp) {
-			os.Exit(0)
+			exitWith(0)
 		}
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(2)
+		exitWith(2)
 	}
 	if opts.Version || isVersionPrompt(prompt) {
 		fmt.Println(version)
 		return
 	}
 
+	checkWritableDirs(opts)
 	cfg, cfgPath, err := config.LoadOrCreate()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "ew: could not load config: %v\n", err)
-		os.Exit(1)
+		exitWith(1)
 	}
+	provider.SweepOrphanedTempDirs(orphanedTempDirAge)
+	atExit(provider.CleanupTempDirs)
 
 	changes := map[string]string{}
 	trimmedPrompt := strings.TrimSpace(prompt)
 	targetIntent := flagOverrideIntent(trimmedPrompt, opts.Execute)
+	if opts.FailedCommand != "" {
+		targetIntent = router.IntentFix
+	}
 	mergeFlagOverrides(opts, changes, targetIntent)
 
 	if len(changes) > 0 {
 		for key, value := range changes {
 			if err := cfg.Set(key, value); err != nil {
 				fmt.Fprintf(os.Stderr, "ew: invalid config change %s=%s: %v\n", key, value, err)
-				os.Exit(1)
+				if hint := errorHint(err); hint != "" {
+					fmt.Fprintf(os.Stderr, "ew: %s\n", hint)
+				}
+				exitWith(exitCodeFor(err))
 			}
 		}
 	}
 
 	persist := opts.Save
 	if persist && len(changes) > 0 {
-		if err := config.Save(cfgPath, cfg); err != nil {
+		if err := saveGlobalConfigChanges(cfgPath, changes); err != nil {
 			fmt.Fprintf(os.Stderr, "ew: could not save config: %v\n", err)
-			os.Exit(1)
+			exitWith(1)
+		}
+	}
+
+	// State is per user, so pick the backend before a project config can
+	// overlay settings.
+	if stateReadOnly {
+		atExit(useVolatileState())
+	} else {
+		atExit(useStateBackend(cfg))
+	}
+	atExit(openTrace(opts))
+
+	runtimeWorkspace = applyWorkspaceConfig(&cfg, opts)
+	runtimeRepo = detectRuntimeRepo()
+	if runtimeWorkspace != nil && runtimeWorkspace.Applied {
+		// Flags still win over project settings.
+		for key, value := range changes {
+			_ = cfg.Set(key, value)
 		}
 	}
+	runtimeTLDREnabled = cfg.TLDR.Enabled
+	runtimeRunbookDir = runbookDir(cfg, cfgPath)
+	runtimeIncludeRaw = opts.IncludeRaw && opts.JSON
+	configureRecording(cfg, opts)
+	ui.SetASCIIOnly(cfg.UI.ASCIIOnly || ui.DumbTerminal())
+	history.SetSkipSecrets(cfg.History.Secrets == "skip")
+	history.SetViaEW(cfg.History.ViaEW)
+	useEmbeddings(cfg, opts)
 
 	applyRuntimeLocale(cfg, opts)
+	if opts.EditMemory || isMemoryManagerPrompt(trimmedPrompt) && !opts.Execute {
+		handleMemoryEdit(cfg, opts)
+		return
+	}
+	if opts.BootstrapMemory {
+		handleMemoryBootstrap(cfg, opts)
+		return
+	}
+	if isProviderStatsPrompt(trimmedPrompt) && !opts.Execute {
+		handleProviderStats(cfg, opts)
+		return
+	}
+	if opts.Top || isStatsPrompt(trimmedPrompt) && !opts.Execute {
+		handleTop(cfg, opts)
+		return
+	}
 	initializeSystemProfileContext(&cfg, cfgPath, opts)
+	ewrt.UseShell(interactiveShell())
 
 	if opts.ShowConfig {
 		handleConfigShow(cfg, cfgPath, opts)
@@ -166,6 +353,14 @@ func main() {
 		handleDiagnose(cfg, opts)
 		return
 	}
+	if opts.UpdateTLDR {
+		handleTLDRUpdate(cfg, cfgPath, opts)
+		return
+	}
+	if strings.TrimSpace(opts.ImportCheats) != "" {
+		handleCheatImport(opts.ImportCheats, opts)
+		return
+	}
 	if opts.SetupHooks {
 		handleSetupHooks(opts)
 		return
@@ -176,68 +371,214 @@ func main() {
 		return
 	}
 
+	if opts.DismissTip != "" {
+		handleDismissTip(opts.DismissTip, opts)
+		return
+	}
+
 	prompt = trimmedPrompt
+	if opts.TracePlan {
+		handleTracePlan(prompt, cfg, opts)
+		return
+	}
+	atExit(showTip(cfg, opts))
+	atExit(flushSessionInteraction)
+	if opts.Passthrough {
+		beginSessionInteraction(prompt, router.IntentRun)
+		handlePassthrough(prompt, cfg, opts)
+		return
+	}
+	if opts.FailedCommand != "" {
+		beginSessionInteraction(prompt, router.IntentFix)
+		handleExplicitFix(prompt, cfg, opts)
+		return
+	}
+	if subject, ok := parseExplainPrompt(prompt); opts.Explain || (ok && !opts.Execute) {
+		if !opts.Explain {
+			prompt = subject
+		}
+		beginSessionInteraction(prompt, router.IntentExplain)
+		handleExplain(prompt, cfg, opts)
+		return
+	}
 	if prompt == "" {
 		if opts.Execute {
+			beginSessionInteraction("", router.IntentRun)
+			if handleFrequentCommands(router.IntentRun, cfg, opts) {
+				return
+			}
 			payload := response{Intent: string(router.IntentRun), Message: "add a query to execute, e.g. ew --execute clear aws vault"}
 			printResponse(payload, opts.JSON)
 			return
 		}
+		beginSessionInteraction("", router.IntentFix)
 		handleFix("", cfg, opts)
 		return
 	}
+	if handled := maybeHandleQuickCommand(prompt, cfg, opts); handled {
+		return
+	}
+	if isUndoPrompt(prompt) {
+		beginSessionInteraction(prompt, router.IntentUndo)
+		handleUndo(cfg, opts)
+		return
+	}
 	if !opts.Execute {
-		if handled := maybeHandleMemoryPrompt(prompt, opts); handled {
+		if handled := maybeHandleAliasPrompt(prompt, opts); handled {
+			return
+		}
+		if handled := maybeHandleMemoryPrompt(prompt, cfg, opts); handled {
 			return
 		}
 		if handled := maybeHandleSelfAwarePrompt(prompt, cfg, cfgPath, opts); handled {
 			return
 		}
 	}
+	if handled := maybeHandleSwitchPrompt(prompt, cfg, opts); handled {
+		return
+	}
 	if !opts.Execute && isFixPrompt(prompt) {
+		beginSessionInteraction(prompt, router.IntentFix)
 		handleFix(prompt, cfg, opts)
 		return
 	}
 	if opts.Execute {
+		beginSessionInteraction(prompt, router.IntentRun)
+		if meaninglessQuery(prompt) && handleFrequentCommands(router.IntentRun, cfg, opts) {
+			return
+		}
 		handleRun(prompt, cfg, opts)
 		return
 	}
+	beginSessionInteraction(prompt, router.IntentFind)
+	if meaninglessQuery(prompt) && handleFrequentCommands(router.IntentFind, cfg, opts) {
+		return
+	}
 	handleFind(prompt, cfg, opts)
+	offerRunAfterFind(prompt, cfg, opts)
 }
 
-func parseArgs(args []string) (options, string, error) {
+// newFlagSet defines every ew flag on a fresh flag set that parses into
+// opts. Shell completion reads the same set, so it never offers a flag ew
+// does not take.
+func newFlagSet(opts *options) *flag.FlagSet {
 	fs := flag.NewFlagSet("ew", flag.ContinueOnError)
 	fs.SetOutput(os.Stderr)
 
-	var opts options
 	fs.StringVar(&opts.Model, "model", "", "override model for this invocation")
 	fs.StringVar(&opts.Thinking, "thinking", "", "override thinking level")
 	fs.StringVar(&opts.Provider, "provider", "", "override provider: auto|codex|claude")
 	fs.StringVar(&opts.Locale, "locale", "", "override locale: auto|en|en-US|hi|hi-IN")
 	fs.StringVar(&opts.Mode, "mode", "", "override mode: suggest|confirm|yolo")
 	fs.StringVar(&opts.UI, "ui", "", "override ui backend: auto|bubbletea|huh|tview|plain")
+	fs.StringVar(&opts.Target, "target", "", "execution target: local|ssh:<host>|docker:<container>|kubectl:[ns/]pod[/container]")
 	fs.StringVar(&opts.Intent, "intent", "", "target config for --model/--thinking: fix|find")
 	fs.BoolVar(&opts.Save, "save", false, "persist overrides")
 	fs.BoolVar(&opts.Yes, "yes", false, "auto-confirm execution prompts")
 	fs.BoolVar(&opts.JSON, "json", false, "output JSON")
 	fs.BoolVar(&opts.DryRun, "dry-run", false, "do not execute commands")
+	fs.BoolVar(&opts.Preview, "preview", false, "before running a command, show what it would touch (files written or deleted, network destinations, package managers, sudo) and ask, even in yolo mode")
 	fs.BoolVar(&opts.Offline, "offline", false, "skip AI provider fallback")
+	fs.BoolVar(&opts.NoCache, "no-cache", false, "ask the provider even if an identical request was answered recently")
+	fs.BoolVar(&opts.NoRecord, "no-record", false, "keep this invocation out of the session journal and feedback dataset")
 	fs.BoolVar(&opts.Version, "version", false, "print version")
 	fs.BoolVar(&opts.Copy, "copy", false, "copy suggested command to clipboard when possible")
 	fs.BoolVar(&opts.Quiet, "quiet", false, "print only the suggested command")
+	fs.BoolVar(&opts.Verbose, "verbose", false, "show the full suggestion reason instead of a one-line summary")
 	fs.BoolVar(&opts.Execute, "execute", false, "execute selected command instead of only suggesting")
 	fs.BoolVar(&opts.ShowConfig, "show-config", false, "show effective settings and exit")
 	fs.BoolVar(&opts.Doctor, "doctor", false, "run diagnostic checks and exit")
+	fs.BoolVar(&opts.UpdateTLDR, "update-tldr", false, "download tldr pages for offline find examples, enable tldr.enabled, and exit")
+	fs.StringVar(&opts.ImportCheats, "import-cheats", "", "copy a navi-style .cheat file or directory of them into the config dir for find results, and exit")
+	fs.StringVar(&opts.DismissTip, "dismiss-tip", "", "stop showing a usage tip (hooks|memory|confirm, or all), and exit")
+	fs.BoolVar(&opts.Probe, "probe", false, "with --doctor: also record and read back a throwaway failure through ew internal to test the shell hook round trip")
 	fs.BoolVar(&opts.SetupHooks, "setup-hooks", false, "print shell hook snippet and exit")
+	fs.StringVar(&opts.FailedCommand, "command", "", "fix this failed command instead of the captured one (\"-\" reads it from stdin)")
+	fs.StringVar(&opts.ErrorText, "error", "", "error output for --command (\"-\" reads it from stdin)")
+	fs.IntVar(&opts.ExitCode, "exit-code", 1, "exit code for --command")
+	fs.BoolVar(&opts.Top, "top", false, "same as ew top: show the read-only usage dashboard (JSON with --json) and exit")
+	fs.BoolVar(&opts.EditMemory, "edit-memory", false, "same as ew memory edit: open the interactive memory manager (search, edit, promote/demote/delete) and exit")
+	fs.BoolVar(&opts.BootstrapMemory, "bootstrap-memory", false, "same as ew memory bootstrap: propose memory entries for your most frequent shell history commands, name them, and learn the ones you accept")
+	fs.IntVar(&opts.Offset, "offset", 0, "find: skip the first N ranked history matches, to page past them")
+	fs.BoolVar(&opts.NoRerank, "no-rerank", false, "find and run: keep the history ranking instead of letting a provider rerank it")
+	fs.BoolVar(&opts.IncludeRaw, "include-raw", false, "with --json, add the provider's raw response (redacted) and how ew parsed it")
+	fs.BoolVar(&opts.Explain, "explain", false, "explain a command, or the command for a request, flag by flag")
+	fs.BoolVar(&opts.TracePlan, "trace-plan", false, "show how ew would handle the prompt (intent, memory and history candidates, whether a provider would be asked) without asking a provider or running anything, and exit")
+	fs.Func("context-file", "fix: attach this file (a log, a config) to the prompt, trimmed to its end and redacted (repeatable)", func(value string) error {
+		opts.ContextFiles = append(opts.ContextFiles, value)
+		return nil
+	})
+	fs.Func("yolo-for", "auto-execute only commands matching this glob (or re:<regexp>) for this invocation; confirm the rest (repeatable)", func(value string) error {
+		opts.YoloFor = append(opts.YoloFor, value)
+		return nil
+	})
+	return fs
+}
 
-	if err := fs.Parse(args); err != nil {
+func parseArgs(args []string) (options, string, error) {
+	var opts options
+	fs := newFlagSet(&opts)
+	if err := fs.Parse(expandFlagAliases(fs, args, localeCatalog.FlagAliases())); err != nil {
+		return options{}, "", err
+	}
+	words := fs.Args()
+	passthrough, err := parsePassthrough(fs)
+	if err != nil {
 		return options{}, "", err
 	}
+	if passthrough != nil {
+		if len(passthrough) == 0 {
+			return options{}, "", fmt.Errorf("ew run -- needs a command, e.g. ew run -- git push")
+		}
+		opts.Passthrough = true
+		opts.Execute = true
+	}
+	if opts.Probe {
+		opts.Doctor = true
+	}
+	opts.FailedCommand = strings.TrimSpace(opts.FailedCommand)
+	opts.ErrorText = strings.TrimSpace(opts.ErrorText)
+	if opts.FailedCommand == "" && opts.ErrorText != "" {
+		return options{}, "", fmt.Errorf("--error requires --command")
+	}
+	if opts.FailedCommand == "-" && opts.ErrorText == "-" {
+		return options{}, "", fmt.Errorf("only one of --command and --error can read from stdin")
+	}
+	if opts.FailedCommand != "" && opts.Passthrough {
+		return options{}, "", fmt.Errorf("--command cannot be combined with ew run --")
+	}
+	if opts.FailedCommand != "" && opts.Execute {
+		return options{}, "", fmt.Errorf("--command cannot be combined with --execute; use --mode/--yes to run the fix")
+	}
+	if opts.Explain && (opts.Execute || opts.FailedCommand != "") {
+		return options{}, "", fmt.Errorf("--explain cannot be combined with --execute, --command, or ew run --")
+	}
+	if len(opts.YoloFor) > 0 {
+		if strings.TrimSpace(opts.Mode) != "" || opts.Save {
+			return options{}, "", fmt.Errorf("--yolo-for only applies to this invocation and cannot be combined with --mode or --save")
+		}
+		if _, err := compileYoloScope(opts.YoloFor); err != nil {
+			return options{}, "", err
+		}
+	}
+	if opts.DismissTip != "" {
+		id, err := tips.CheckID(opts.DismissTip)
+		if err != nil {
+			return options{}, "", fmt.Errorf("--dismiss-tip: %w", err)
+		}
+		opts.DismissTip = id
+	}
+	if opts.Offset < 0 {
+		return options{}, "", fmt.Errorf("--offset cannot be negative")
+	}
 	opts.Intent = strings.ToLower(strings.TrimSpace(opts.Intent))
 	if opts.Intent != "" && opts.Intent != "fix" && opts.Intent != "find" {
 		return options{}, "", fmt.Errorf("--intent must be one of: fix, find")
 	}
-	prompt := strings.TrimSpace(strings.Join(fs.Args(), " "))
+	if opts.Passthrough {
+		return opts, passthroughCommand(passthrough), nil
+	}
+	prompt := strings.TrimSpace(strings.Join(words, " "))
 	return opts, prompt, nil
 }
 
@@ -285,6 +626,9 @@ func mergeFlagOverrides(opts options, changes map[string]string, intent router.I
 	if strings.TrimSpace(opts.UI) != "" {
 		changes["ui.backend"] = strings.TrimSpace(opts.UI)
 	}
+	if strings.TrimSpace(opts.Target) != "" {
+		changes["execution.target"] = strings.TrimSpace(opts.Target)
+	}
 	if strings.TrimSpace(opts.Model) != "" {
 		changes[target+".model"] = strings.TrimSpace(opts.Model)
 	}
@@ -312,17 +656,25 @@ func applyRuntimeLocale(cfg config.Config, opts options) {
 	if strings.EqualFold(locale, "auto") {
 		locale = ""
 	}
-	localeCatalog = i18n.LoadCatalog(locale)
+	if ui.ASCIIOnly() {
+		// Other catalogs are not written in ASCII.
+		locale = "en"
+	}
+	localeCatalog = i18n.LoadCatalog(locale, workspacePackDirs()...)
 }
 
 func initializeSystemProfileContext(cfg *config.Config, cfgPath string, opts options) {
 	runtimeSystemContext = ""
+	runtimeSystemTools = nil
+	runtimeSystemShell = ""
 	if cfg == nil {
 		return
 	}
 
 	options := systemprofile.Options{
-		AutoTrain:    cfg.System.AutoTrain,
+		// A profile that cannot be saved would be captured again on every
+		// run, so only read one that is already there.
+		AutoTrain:    cfg.System.AutoTrain && !stateReadOnly,
 		RefreshHours: cfg.System.RefreshHours,
 	}
 
@@ -332,7 +684,7 @@ func initializeSystemProfileContext(cfg *config.Config, cfgPath string, opts opt
 		err     error
 	)
 	withEWLoader(opts, "learning your system", func() {
-		profile, status, err = systemprofile.Ensure(options)
+		profile, status, err = systemprofile.Ensure(invocationCtx, options)
 	})
 	if err != nil {
 		if !opts.JSON {
@@ -341,6 +693,8 @@ func initializeSystemProfileContext(cfg *config.Config, cfgPath string, opts opt
 		return
 	}
 
+	runtimeSystemTools = profile.Tools
+	runtimeSystemShell = profile.Shell
 	if status.Created {
 		confirmFirstRunSystemProfile(cfg, cfgPath, &profile, opts)
 	}
@@ -348,7 +702,17 @@ func initializeSystemProfileContext(cfg *config.Config, cfgPath string, opts opt
 	if !cfg.System.EnableContext {
 		return
 	}
-	runtimeSystemContext = profile.PromptContext(cfg.System.MaxPromptItems)
+	runtimeSystemContext = profile.Shared(systemShare(*cfg)).PromptContext(cfg.System.MaxPromptItems)
+}
+
+// systemShare is [system.share]: the profile parts providers may see.
+func systemShare(cfg config.Config) systemprofile.Share {
+	return systemprofile.Share{
+		Tools:       cfg.System.Share.Tools,
+		ConfigFiles: cfg.System.Share.ConfigFiles,
+		GitIgnore:   cfg.System.Share.GitIgnore,
+		UserNote:    cfg.System.Share.UserNote,
+	}
 }
 
 func confirmFirstRunSystemProfile(cfg *config.Config, cfgPath string, profile *systemprofile.Profile, opts options) {
@@ -362,7 +726,8 @@ func confirmFirstRunSystemProfile(cfg *config.Config, cfgPath string, profile *s
 		return
 	}
 
-	summary := strings.TrimSpace(profile.HumanSummary(cfg.System.MaxPromptItems))
+	// Show only what providers will be sent.
+	summary := strings.TrimSpace(profile.Shared(systemShare(*cfg)).HumanSummary(cfg.System.MaxPromptItems))
 	if summary == "" {
 		return
 	}
@@ -420,7 +785,7 @@ func applySystemProfileDecision(cfg *config.Config, cfgPath string, profile *sys
 
 	if decision.DisableContext {
 		cfg.System.EnableContext = false
-		if err := config.Save(cfgPath, *cfg); err != nil {
+		if err := saveGlobalConfigChanges(cfgPath, map[string]string{"system.enable_context": "false"}); err != nil {
 			fmt.Fprintf(os.Stderr, "ew: could not save system context preference: %v\n", err)
 			return
 		}
@@ -458,6 +823,9 @@ func maybeHandleSelfAwarePrompt(prompt string, cfg config.Config, cfgPath string
 	case selfActionDiagnose:
 		handleDiagnose(cfg, opts)
 		return true
+	case selfActionCacheClear:
+		handleCacheClear(opts)
+		return true
 	case selfActionConfigSet:
 		if len(action.Changes) == 0 {
 			return false
@@ -475,16 +843,20 @@ func maybeHandleSelfAwarePrompt(prompt string, cfg config.Config, cfgPath string
 		}
 		for key, value := range action.Changes {
 			if err := cfg.Set(key, value); err != nil {
+				suggestions := sortedChangeSuggestions(action.Changes)
+				if hint := errorHint(err); hint != "" {
+					suggestions = append(suggestions, hint)
+				}
 				payload := response{
 					Intent:      string(router.IntentConfigSet),
 					Message:     fmt.Sprintf("invalid self-config change %s=%s: %v", key, value, err),
-					Suggestions: sortedChangeSuggestions(action.Changes),
+					Suggestions: suggestions,
 				}
 				printResponse(payload, opts.JSON)
 				return true
 			}
 		}
-		if err := config.Save(cfgPath, cfg); err != nil {
+		if err := saveGlobalConfigChanges(cfgPath, action.Changes); err != nil {
 			payload := response{
 				Intent:      string(router.IntentConfigSet),
 				Message:     fmt.Sprintf("could not save self-config changes: %v", err),
@@ -517,6 +889,8 @@ func parseSelfPromptAction(prompt string) (selfPromptAction, bool) {
 		return selfPromptAction{Kind: selfActionSetupHooks}, true
 	case matchesSelfUtilityPrompt(low, catalog.Self.Diagnose, selfReferenced):
 		return selfPromptAction{Kind: selfActionDiagnose}, true
+	case matchesSelfUtilityPrompt(low, catalog.Self.ClearCache, selfReferenced):
+		return selfPromptAction{Kind: selfActionCacheClear}, true
 	}
 	if !implicitConfigAllowed {
 		return selfPromptAction{}, false
@@ -594,12 +968,17 @@ func parseSelfPromptAction(prompt string) (selfPromptAction, bool) {
 	if thinking := extractPromptThinking(low); thinking != "" {
 		changes[intentTarget+".thinking"] = thinking
 	}
+	toolPreference := false
+	if avoid, prefer, ok := parsePromptToolPreference(low); ok {
+		changes["tools.prefer."+avoid] = prefer
+		toolPreference = true
+	}
 
 	if len(changes) == 0 {
 		return selfPromptAction{}, false
 	}
 	persist := containsAny(low, catalog.Self.Persist...)
-	if !persist && !questionLike && containsAny(low, catalog.Self.Imperative...) {
+	if !persist && !questionLike && (toolPreference || containsAny(low, catalog.Self.Imperative...)) {
 		persist = true
 	}
 	return selfPromptAction{
@@ -821,11 +1200,13 @@ func isQuestionLikePrompt(low string, patterns []string) bool {
 }
 
 var (
-	reMemoryRemember = regexp.MustCompile(`(?i)^(?:remember|learn)\s+(?:that\s+)?(.+?)\s+(?:=>|->|as|means|is)\s+(.+)$`)
+	reMemoryRemember = regexp.MustCompile(`(?i)^(?:remember|learn)\s+(?:(here|in this (?:project|repo|directory)|everywhere|globally)\s+)?(?:that\s+)?(.+?)\s+(?:=>|->|as|means|is)\s+(.+)$`)
 	reMemoryPrefer   = regexp.MustCompile(`(?i)^(?:prefer|promote|boost)\s+(.+?)\s+(?:for|when i say)\s+(.+)$`)
 	reMemoryDemote   = regexp.MustCompile(`(?i)^(?:demote|downrank|deprioritize)\s+(.+?)\s+(?:for|when i say)\s+(.+)$`)
 	reMemoryForget   = regexp.MustCompile(`(?i)^(?:forget|remove)\s+(?:memory|memories)\s+for\s+(.+)$`)
 	reMemoryShowFor  = regexp.MustCompile(`(?i)^(?:show|list)\s+(?:memory|memories)(?:\s+for\s+(.+))?$`)
+	reMemoryUndo     = regexp.MustCompile(`(?i)^(?:memory\s+undo|undo\s+(?:the\s+)?(?:last\s+)?(?:memory(?:\s+change)?|forget))$`)
+	reMemorySeed     = regexp.MustCompile(`(?i)^(?:memory\s+bootstrap|bootstrap\s+(?:my\s+)?memory(?:\s+from\s+(?:my\s+)?(?:shell\s+)?history)?)$`)
 	reDigits         = regexp.MustCompile(`\d+`)
 )
 
@@ -836,11 +1217,26 @@ func parseMemoryPromptAction(prompt string) (memoryPromptAction, bool) {
 		return memoryPromptAction{}, false
 	}
 
-	if matches := reMemoryRemember.FindStringSubmatch(trimmed); len(matches) >= 3 {
+	if reMemoryUndo.MatchString(trimmed) {
+		return memoryPromptAction{Kind: memoryActionUndo}, true
+	}
+	if reMemorySeed.MatchString(trimmed) {
+		return memoryPromptAction{Kind: memoryActionSeed}, true
+	}
+	if matches := reMemoryRemember.FindStringSubmatch(trimmed); len(matches) >= 4 {
+		scope := memoryScopeKeep
+		switch strings.ToLower(matches[1]) {
+		case "":
+		case "everywhere", "globally":
+			scope = memoryScopeGlobal
+		default:
+			scope = memoryScopeHere
+		}
 		return memoryPromptAction{
 			Kind:    memoryActionSave,
-			Query:   strings.TrimSpace(matches[1]),
-			Command: strings.TrimSpace(matches[2]),
+			Query:   strings.TrimSpace(matches[2]),
+			Command: strings.TrimSpace(matches[3]),
+			Scope:   scope,
 		}, true
 	}
 	if matches := reMemoryPrefer.FindStringSubmatch(trimmed); len(matches) >= 3 {
@@ -960,13 +1356,17 @@ func stripLeadingMemoryVerb(query string) string {
 	return trimmed
 }
 
-func maybeHandleMemoryPrompt(prompt string, opts options) bool {
+func maybeHandleMemoryPrompt(prompt string, cfg config.Config, opts options) bool {
 	action, ok := parseMemoryPromptAction(prompt)
 	if !ok || action.Kind == memoryActionNone {
 		return false
 	}
+	if action.Kind == memoryActionSeed {
+		handleMemoryBootstrap(cfg, opts)
+		return true
+	}
 
-	store, path, err := memory.Load()
+	store, _, err := memory.Load()
 	if err != nil {
 		payload := response{
 			Intent:      string(router.IntentFind),
@@ -983,7 +1383,7 @@ func maybeHandleMemoryPrompt(prompt string, opts options) bool {
 		if strings.TrimSpace(action.Query) == "" {
 			matches = store.Top(8)
 		} else {
-			matches = store.Search(action.Query, 8)
+			matches = store.SearchFrom(action.Query, 8, memoryOrigin())
 		}
 		if opts.JSON {
 			payload := response{
@@ -1004,21 +1404,44 @@ func maybeHandleMemoryPrompt(prompt string, opts options) bool {
 			fmt.Println("Top memory entries:")
 		}
 		for idx, match := range matches {
-			fmt.Printf("%d. %s\n", idx+1, match.Command)
-			fmt.Printf("   query: %s\n", match.Query)
+			printCommand(fmt.Sprintf("%d. ", idx+1), match.Command)
+			printLabeled("   query: ", match.Query)
+			if match.CWD != "" {
+				printLabeled("   learned in: ", displayPath(match.CWD))
+			}
+			if match.Scope != "" {
+				printLabeled("   only in: ", displayPath(match.Scope))
+			}
 			fmt.Printf("   score: %.2f | uses: %d\n", match.Score, match.Uses)
 		}
 		return true
 
 	case memoryActionSave:
-		if err := store.Remember(action.Query, action.Command); err != nil {
+		origin := memoryOrigin()
+		message := "saved memory"
+		store.Describe("remember " + action.Query)
+		switch action.Scope {
+		case memoryScopeHere:
+			if origin.Namespace() == "" {
+				err = fmt.Errorf("could not tell which project this is")
+			} else {
+				err = store.RememberIn(action.Query, action.Command, origin, origin.Namespace())
+				message = "saved memory for " + displayPath(origin.Namespace())
+			}
+		case memoryScopeGlobal:
+			err = store.RememberIn(action.Query, action.Command, origin, "")
+			message = "saved memory for every project"
+		default:
+			err = store.RememberAt(action.Query, action.Command, origin)
+		}
+		if err != nil {
 			printResponse(response{
 				Intent:  string(router.IntentFind),
 				Message: fmt.Sprintf("memory update failed: %v", err),
 			}, opts.JSON)
 			return true
 		}
-		if err := memory.Save(path, store); err != nil {
+		if err := memory.Save(store); err != nil {
 			printResponse(response{
 				Intent:  string(router.IntentFind),
 				Message: fmt.Sprintf("memory save failed: %v", err),
@@ -1027,13 +1450,14 @@ func maybeHandleMemoryPrompt(prompt string, opts options) bool {
 		}
 		printResponse(response{
 			Intent:      string(router.IntentFind),
-			Message:     "saved memory",
+			Message:     message,
 			Command:     action.Command,
 			Suggestions: []string{fmt.Sprintf("query=%s", action.Query)},
 		}, opts.JSON)
 		return true
 
 	case memoryActionBoost:
+		store.Describe("prefer " + action.Command + " for " + action.Query)
 		if err := store.Promote(action.Query, action.Command); err != nil {
 			printResponse(response{
 				Intent:  string(router.IntentFind),
@@ -1041,7 +1465,7 @@ func maybeHandleMemoryPrompt(prompt string, opts options) bool {
 			}, opts.JSON)
 			return true
 		}
-		if err := memory.Save(path, store); err != nil {
+		if err := memory.Save(store); err != nil {
 			printResponse(response{
 				Intent:  string(router.IntentFind),
 				Message: fmt.Sprintf("memory save failed: %v", err),
@@ -1057,6 +1481,7 @@ func maybeHandleMemoryPrompt(prompt string, opts options) bool {
 		return true
 
 	case memoryActionDrop:
+		store.Describe("demote " + action.Command + " for " + action.Query)
 		if err := store.Demote(action.Query, action.Command); err != nil {
 			printResponse(response{
 				Intent:  string(router.IntentFind),
@@ -1064,7 +1489,7 @@ func maybeHandleMemoryPrompt(prompt string, opts options) bool {
 			}, opts.JSON)
 			return true
 		}
-		if err := memory.Save(path, store); err != nil {
+		if err := memory.Save(store); err != nil {
 			printResponse(response{
 				Intent:  string(router.IntentFind),
 				Message: fmt.Sprintf("memory save failed: %v", err),
@@ -1080,8 +1505,9 @@ func maybeHandleMemoryPrompt(prompt string, opts options) bool {
 		return true
 
 	case memoryActionForget:
+		store.Describe("forget memory for " + action.Query)
 		removed := store.ForgetQuery(action.Query)
-		if err := memory.Save(path, store); err != nil {
+		if err := memory.Save(store); err != nil {
 			printResponse(response{
 				Intent:  string(router.IntentFind),
 				Message: fmt.Sprintf("memory save failed: %v", err),
@@ -1092,6 +1518,9 @@ func maybeHandleMemoryPrompt(prompt string, opts options) bool {
 		if removed > 0 {
 			msg = fmt.Sprintf("removed %d memory entrie(s)", removed)
 		}
+		if removed > 0 {
+			msg += "; `ew memory undo` puts them back"
+		}
 		printResponse(response{
 			Intent:      string(router.IntentFind),
 			Message:     msg,
@@ -1099,6 +1528,35 @@ func maybeHandleMemoryPrompt(prompt string, opts options) bool {
 		}, opts.JSON)
 		return true
 
+	case memoryActionUndo:
+		revision, ok := store.Undo()
+		if !ok {
+			printResponse(response{
+				Intent:  string(router.IntentFind),
+				Message: "no memory changes to undo",
+			}, opts.JSON)
+			return true
+		}
+		if err := memory.Save(store); err != nil {
+			printResponse(response{
+				Intent:  string(router.IntentFind),
+				Message: fmt.Sprintf("memory save failed: %v", err),
+			}, opts.JSON)
+			return true
+		}
+		payload := response{
+			Intent:  string(router.IntentFind),
+			Message: fmt.Sprintf("undid %q from %s: restored %d memory entrie(s), dropped %d", revision.Action, revision.At, len(revision.Before), len(revision.Added)),
+		}
+		if opts.JSON {
+			payload.Results = revision
+		}
+		for _, entry := range revision.Before[:min(len(revision.Before), 5)] {
+			payload.Suggestions = append(payload.Suggestions, entry.Query+" => "+entry.Command)
+		}
+		printResponse(payload, opts.JSON)
+		return true
+
 	default:
 		return false
 	}
@@ -1111,6 +1569,12 @@ func handleConfigShow(cfg config.Config, cfgPath string, opts options) {
 		Results:    cfg,
 		ConfigPath: cfgPath,
 	}
+	if runtimeWorkspace != nil {
+		payload.Workspace = runtimeWorkspace
+		if !opts.JSON {
+			payload.Suggestions = append(payload.Suggestions, describeWorkspaceStatus(*runtimeWorkspace))
+		}
+	}
 	printResponse(payload, opts.JSON)
 }
 
@@ -1136,200 +1600,88 @@ func handleConfigSet(cfgPath string, changes map[string]string, opts options) {
 }
 
 func handleDiagnose(cfg config.Config, opts options) {
-	output, err := runInternal("doctor")
-	if err != nil {
-		output, err = fallbackDoctorOutput(cfg)
-		if err != nil {
-			payload := response{Intent: string(router.IntentDiagnose), Message: "doctor check failed", Suggestions: []string{string(output)}}
-			if strings.TrimSpace(err.Error()) != "" {
-				payload.Suggestions = append(payload.Suggestions, err.Error())
-			}
-			printResponse(payload, opts.JSON)
-			return
-		}
+	report := doctor.Run(cfg, nil)
+	internalBin := ""
+	if candidates := internalBinaryCandidates(); len(candidates) > 0 {
+		internalBin = candidates[0]
 	}
-
-	if opts.JSON {
-		fmt.Println(string(output))
-		return
-	}
-	fmt.Println("doctor checks:")
-	fmt.Println(string(output))
-}
-
-func fallbackDoctorOutput(cfg config.Config) ([]byte, error) {
-	type check struct {
-		Key    string `json:"key"`
-		Value  string `json:"value"`
-		Status string `json:"status"`
-	}
-
-	cfgPath, err := appdirs.ConfigFilePath()
-	if err != nil {
-		return nil, err
-	}
-	statePath, err := appdirs.StateDir()
-	if err != nil {
-		return nil, err
-	}
-
-	checks := []check{
-		{Key: "os", Value: goruntime.GOOS, Status: "ok"},
-		{Key: "config_path", Value: cfgPath, Status: statusFile(cfgPath)},
-		{Key: "state_dir", Value: statePath, Status: statusDir(statePath)},
-		{Key: "codex", Value: pathOrMissing("codex"), Status: statusBinary("codex")},
-		{Key: "claude", Value: pathOrMissing("claude"), Status: statusBinary("claude")},
+	budget := time.Duration(cfg.Doctor.BudgetMs) * time.Millisecond
+	checks := append(report.Checks, doctor.InternalBinary(internalBin, version, budget))
+	if opts.Probe {
+		checks = append(checks, doctor.HookProbe(helperCommand(), detectShell(), os.Getenv("EW_SESSION_ID"), budget)...)
 	}
-
-	registry := provider.NewRegistry()
-	issues := registry.Validate(cfg)
-	if len(issues) == 0 {
-		checks = append(checks, check{Key: "providers", Value: fmt.Sprintf("%d configured", len(cfg.Providers)), Status: "ok"})
+	report = doctor.NewReport(checks)
+	if opts.JSON {
+		encoded, err := marshalOutput(report)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ew: could not encode doctor report: %v\n", err)
+			exitWith(1)
+		}
+		fmt.Println(string(encoded))
 	} else {
-		checks = append(checks, check{Key: "providers", Value: fmt.Sprintf("%d issue(s)", len(issues)), Status: "error"})
-		for _, issue := range issues {
-			checks = append(checks, check{Key: "provider_issue", Value: issue.Error(), Status: "error"})
+		fmt.Println("doctor checks:")
+		for _, line := range formatDoctorReport(report, outputWidth()) {
+			fmt.Println(line)
 		}
 	}
-
-	names := cfg.ProviderNames()
-	sort.Strings(names)
-	for _, name := range names {
-		providerCfg := cfg.Providers[name]
-		status := "ok"
-		if providerCfg.Enabled != nil && !*providerCfg.Enabled {
-			status = "disabled"
-		}
-		checks = append(checks, check{
-			Key:    "provider." + name,
-			Value:  fmt.Sprintf("type=%s command=%s model=%s", providerCfg.Type, providerCfg.Command, providerCfg.Model),
-			Status: status,
-		})
+	if code := report.ExitCode(); code != 0 {
+		exitWith(code)
 	}
-
-	return json.MarshalIndent(checks, "", "  ")
 }
 
-func statusFile(path string) string {
-	if _, err := os.Stat(path); err != nil {
-		if os.IsNotExist(err) {
-			return "missing"
+// formatDoctorReport lays checks out in aligned columns; values and hints
+// that do not fit in lineWidth wrap under the value column.
+func formatDoctorReport(report doctor.Report, lineWidth int) []string {
+	width := 0
+	for _, check := range report.Checks {
+		if len(check.ID) > width {
+			width = len(check.ID)
 		}
-		return "error"
 	}
-	return "ok"
-}
-
-func statusDir(path string) string {
-	if _, err := os.Stat(path); err != nil {
-		if os.IsNotExist(err) {
-			return "missing"
+	blank := fmt.Sprintf("  %-5s %-*s  ", "", width, "")
+	lines := make([]string, 0, len(report.Checks)+1)
+	for _, check := range report.Checks {
+		value := wrapLabeled(blank, check.Value, lineWidth)
+		if len(value) == 0 {
+			value = []string{blank}
+		}
+		value[0] = fmt.Sprintf("  %-5s %-*s  ", check.Severity, width, check.ID) + strings.TrimPrefix(value[0], blank)
+		lines = append(lines, value...)
+		if check.Hint != "" {
+			lines = append(lines, wrapLabeled(blank+"hint: ", check.Hint, lineWidth)...)
 		}
-		return "error"
-	}
-	return "ok"
-}
-
-func statusBinary(name string) string {
-	if _, err := exec.LookPath(name); err != nil {
-		return "missing"
-	}
-	return "ok"
-}
-
-func pathOrMissing(name string) string {
-	path, err := exec.LookPath(name)
-	if err != nil {
-		return "not found"
 	}
-	return path
+	lines = append(lines, fmt.Sprintf("overall: %s (%d ok, %d warn, %d error)", report.Status, report.Summary.OK, report.Summary.Warn, report.Summary.Error))
+	return lines
 }
 
 func handleSetupHooks(opts options) {
 	shell := detectShell()
-	output, err := runInternal("hook-snippet", "--shell", shell)
+	snippet, err := helper.HookSnippet(shell)
 	if err != nil {
-		if fallback := fallbackHookSnippet(shell); fallback != "" {
-			output = []byte(fallback)
-		} else {
-			payload := response{
-				Intent:  string(router.IntentSetupHooks),
-				Message: "could not generate hook snippet",
-				Suggestions: []string{
-					"Build _ew and ensure it is available in PATH",
-					"Then run: _ew hook-snippet --shell zsh|bash|fish",
-				},
-			}
-			printResponse(payload, opts.JSON)
-			return
+		payload := response{
+			Intent:  string(router.IntentSetupHooks),
+			Message: fmt.Sprintf("could not generate hook snippet: %v", err),
+			Suggestions: []string{
+				"Set SHELL to zsh, bash, fish, nu, or pwsh and run ew --setup-hooks again",
+			},
 		}
+		printResponse(payload, opts.JSON)
+		return
 	}
 
 	if opts.JSON {
 		payload := response{
 			Intent:  string(router.IntentSetupHooks),
 			Message: "hook snippet generated",
-			Results: map[string]string{"shell": shell, "snippet": string(output)},
+			Results: map[string]string{"shell": shell, "snippet": snippet},
 		}
 		printResponse(payload, true)
 		return
 	}
 
 	fmt.Printf("Add this %s snippet to your shell rc file:\n\n", shell)
-	fmt.Println(string(output))
-}
-
-func fallbackHookSnippet(shell string) string {
-	switch strings.ToLower(strings.TrimSpace(shell)) {
-	case "zsh":
-		return `export EW_SESSION_ID=${EW_SESSION_ID:-"$$.$(date +%s)"}
-function _ew_preexec() {
-  EW_LAST_COMMAND="$1"
-}
-function _ew_precmd() {
-  local exit_code=$?
-  if [ -n "$EW_LAST_COMMAND" ]; then
-    _ew hook-record --command "$EW_LAST_COMMAND" --exit-code "$exit_code" --cwd "$PWD" --shell "zsh" --session-id "$EW_SESSION_ID" >/dev/null 2>&1
-    EW_LAST_COMMAND=""
-  fi
-}
-autoload -Uz add-zsh-hook
-add-zsh-hook preexec _ew_preexec
-add-zsh-hook precmd _ew_precmd`
-	case "bash":
-		return `export EW_SESSION_ID=${EW_SESSION_ID:-"$$.$(date +%s)"}
-_EW_LAST_HISTCMD="$HISTCMD"
-_ew_prompt() {
-  local exit_code=$?
-  if [ "$HISTCMD" = "$_EW_LAST_HISTCMD" ]; then
-    return
-  fi
-  _EW_LAST_HISTCMD="$HISTCMD"
-  local last_command
-  last_command=$(fc -ln -1 2>/dev/null)
-  if [ -n "$last_command" ]; then
-    _ew hook-record --command "$last_command" --exit-code "$exit_code" --cwd "$PWD" --shell "bash" --session-id "$EW_SESSION_ID" >/dev/null 2>&1
-  fi
-}
-case ";$PROMPT_COMMAND;" in
-  *";_ew_prompt;"*) ;;
-  *) PROMPT_COMMAND="_ew_prompt${PROMPT_COMMAND:+;$PROMPT_COMMAND}" ;;
-esac`
-	case "fish":
-		return `set -q EW_SESSION_ID; or set -gx EW_SESSION_ID "$fish_pid".(date +%s)
-function __ew_preexec --on-event fish_preexec
-  set -g EW_LAST_COMMAND $argv[1]
-end
-function __ew_postexec --on-event fish_postexec
-  set -l exit_code $status
-  if test -n "$EW_LAST_COMMAND"
-    _ew hook-record --command "$EW_LAST_COMMAND" --exit-code "$exit_code" --cwd "$PWD" --shell "fish" --session-id "$EW_SESSION_ID" >/dev/null 2>&1
-    set -e EW_LAST_COMMAND
-  end
-end`
-	default:
-		return ""
-	}
+	fmt.Println(snippet)
 }
 
 func handleFind(query string, cfg config.Config, opts options) {
@@ -1339,43 +1691,50 @@ func handleFind(query string, cfg config.Config, opts options) {
 		printResponse(payload, opts.JSON)
 		return
 	}
+	if opts.JSON {
+		handleFindJSON(query, cfg, opts)
+		return
+	}
 
+	rejections := loadRejections()
+	now := time.Now().UTC()
 	memoryMatches, _ := searchMemoryWithLoader(query, cfg.Find.MaxResults, opts, "checking what you've used before")
+	memoryMatches = rejections.Downrank(query, memoryMatches, now)
 	if top, ok := preferredMemoryMatch(query, memoryMatches); ok {
-		reason := compactReason(fmt.Sprintf("learned from memory for %q (uses: %d)", top.Query, top.Uses), 120)
-		if opts.JSON {
-			payload := response{
-				Intent:      string(router.IntentFind),
-				Message:     "memory match",
-				Command:     top.Command,
-				Risk:        "low",
-				Executed:    false,
-				Suggestions: []string{reason},
-			}
-			printResponse(payload, true)
-			return
-		}
-		printSuggestedCommandBlock(top.Command, reason, "memory", opts)
+		reason := reasonForDisplay(fmt.Sprintf("learned from memory for %q (uses: %d)", top.Query, top.Uses), opts)
+		printSuggestedCommandBlock(top.Command, reason, "memory", cfg, opts)
 		return
 	}
 
-	matches, err := searchHistoryWithLoader(query, cfg.Find.MaxResults, opts, "scouting your history")
-	if err != nil {
+	// Missing shell history is not fatal: cheats, tldr, and the providers
+	// can still answer, and the hint is shown if they come up empty too.
+	page, err := searchHistoryPageWithLoader(query, history.Page{Offset: opts.Offset, Limit: cfg.Find.MaxResults}, opts, "scouting your history")
+	matches := page.Matches
+	if err != nil && !errors.Is(err, history.ErrNoHistory) {
 		payload := response{Intent: string(router.IntentFind), Message: fmt.Sprintf("search failed: %v", err)}
 		printResponse(payload, opts.JSON)
 		return
 	}
-	matches = filterFindMatches(query, matches)
+	matches = applyRepoBoost(applyTemporalBoost(filterFindMatches(cfg, query, matches), cfg, time.Now()))
+	matches = downrankRejectedHistory(query, matches, rejections, now)
+	matches = mergeCheatMatches(query, matches, cfg.Find.MaxResults)
+	matches = mergeRunbookMatches(query, matches, cfg.Find.MaxResults)
 	if len(matches) == 0 {
-		if opts.Offline {
+		if !providerAvailability(cfg, opts).allows(capabilityProviderFallback, opts) {
+			if suggestFromTLDR(query, cfg, opts) {
+				return
+			}
 			payload := response{Intent: string(router.IntentFind), Message: "no safe matching history entries found"}
+			if hint := errorHint(err); hint != "" {
+				payload.Suggestions = []string{hint}
+			}
 			printResponse(payload, opts.JSON)
 			return
 		}
 
-		prompt := buildFindPrompt(query, nil)
+		prompt := buildFindPrompt(cfg, query, nil)
 		resolution, providerName, resolveErr := resolveProviderWithLoader(
-			context.Background(),
+			invocationCtx,
 			cfg,
 			opts,
 			provider.IntentFind,
@@ -1383,12 +1742,13 @@ func handleFind(query string, cfg config.Config, opts options) {
 			"thinking of a command that fits",
 		)
 		if resolveErr != nil {
+			if suggestFromTLDR(query, cfg, opts) {
+				return
+			}
 			payload := response{
-				Intent:  string(router.IntentFind),
-				Message: "no local history match and provider fallback failed",
-				Suggestions: []string{
-					resolveErr.Error(),
-				},
+				Intent:      string(router.IntentFind),
+				Message:     "no local history match and provider fallback failed",
+				Suggestions: errorSuggestions(resolveErr),
 			}
 			printResponse(payload, opts.JSON)
 			return
@@ -1398,84 +1758,82 @@ func handleFind(query string, cfg config.Config, opts options) {
 				Intent:  string(router.IntentFind),
 				Message: "no safe suggestion found for this query",
 			}
-			if opts.JSON {
-				payload.Suggestions = []string{
-					"provider suggestion was filtered as destructive for a non-destructive query",
-				}
-			}
 			printResponse(payload, opts.JSON)
 			return
 		}
-		if !opts.JSON {
-			printSuggestedCommandBlock(resolution.Command, compactReason(resolution.Reason, 120), providerName, opts)
-			persistFindSuggestionMemory(query, resolution.Command, providerName, resolution.Risk)
-			return
-		}
-
-		payload := response{
-			Intent:  string(router.IntentFind),
-			Message: providerFallbackMessage(resolution.Action, providerName),
-			Command: resolution.Command,
-			Risk:    resolution.Risk,
-			Suggestions: []string{
-				resolution.Reason,
-			},
-		}
-		printResponse(payload, opts.JSON)
+		printSuggestedCommandBlock(resolution.Command, reasonForDisplay(resolution.Reason, opts), providerName, cfg, opts)
 		persistFindSuggestionMemory(query, resolution.Command, providerName, resolution.Risk)
 		return
 	}
 
-	if opts.JSON {
-		payload := response{Intent: string(router.IntentFind), Message: "top history matches", Results: matches}
-		printResponse(payload, true)
-		return
-	}
-
 	aiCommand := ""
 	aiReason := ""
 	aiSource := ""
 	aiRisk := ""
+	aiRerank := ""
 	if len(memoryMatches) > 0 {
 		top := memoryMatches[0]
-		if commandAllowedForQuery(query, top.Command) && memoryQueryCompatible(query, top.Query) {
+		if !top.Rejected && commandAllowedForQuery(query, top.Command) && (memoryQueryCompatible(query, top.Query) || semanticallyCompatible(query, top)) {
 			aiCommand = strings.TrimSpace(top.Command)
 			aiReason = fmt.Sprintf("learned from memory for %q (uses: %d)", top.Query, top.Uses)
 			aiSource = "memory"
 			aiRisk = "low"
 		}
 	}
-	if shouldAIRerank(cfg.Find.AIRerank, matches) && !opts.Offline {
-		prompt := buildFindPrompt(query, matches)
-		if resolution, providerName, err := resolveProviderWithLoader(
-			context.Background(),
+	// With a picker that can update in place, the rerank runs while the
+	// picker shows the best local pick; see warmRerank.
+	var warm *warmRerank
+	findSelection := func(pick findPick) ui.Selection {
+		displayCommand, rawAlternative := preferSaferSuggestion(pick.Command, true)
+		return ui.Selection{
+			Command:     displayCommand,
+			Reason:      withRejectionNote(reasonForDisplay(pick.Reason, opts), pick.Command != "" && rejections.Rejected(query, pick.Command, now)),
+			Source:      pick.Source,
+			Alternative: rawAlternative,
+			Rerank:      pick.Rerank,
+		}
+	}
+	// A matching runbook section is the team's own answer, so it is not put
+	// to a provider.
+	book, fromRunbook := runbookPick(query)
+	if fromRunbook && aiCommand == "" {
+		aiCommand, aiReason, aiSource = book.Command, book.Reason, book.Source
+	}
+	if !fromRunbook && shouldAIRerank(aiRerankMode(cfg, opts), matches) && providerAvailability(cfg, opts).allows(capabilityAIRerank, opts) {
+		prompt := buildFindPrompt(cfg, query, matches)
+		local := provisionalPick(findPick{Command: aiCommand, Reason: aiReason, Source: aiSource, Risk: aiRisk}, matches)
+		if backend := effectiveUIBackend(cfg, opts); canUseInteractiveUI(opts, backend) && ui.LiveUpdates(backend) {
+			warm = startWarmRerank(query, prompt, matches, cfg, opts, findSelection)
+			aiCommand, aiReason, aiSource, aiRisk = local.Command, local.Reason, local.Source, local.Risk
+		} else if resolution, providerName, err := resolveProviderOrHistory(
 			cfg,
 			opts,
 			provider.IntentFind,
 			prompt,
 			"ranking the best command",
-		); err == nil && strings.TrimSpace(resolution.Command) != "" {
-			if commandAllowedForQuery(query, resolution.Command) {
-				aiCommand = strings.TrimSpace(resolution.Command)
-				aiReason = strings.TrimSpace(resolution.Reason)
-				aiSource = providerName
-				aiRisk = strings.TrimSpace(resolution.Risk)
-				if aiReason == "" {
-					aiReason = fmt.Sprintf("suggested by %s", providerName)
-				}
+			local.Command,
+		); err == nil {
+			if pick, ok := rerankPick(query, matches, opts.Offset, resolution, providerName); ok {
+				aiCommand, aiReason, aiSource, aiRisk, aiRerank = pick.Command, pick.Reason, pick.Source, pick.Risk, pick.Rerank
 			}
 		}
 	}
-	aiReason = compactReason(aiReason, 120)
-
-	if lowSignalFindQuery(query) && aiCommand != "" {
-		printSuggestedCommandBlock(aiCommand, aiReason, aiSource, opts)
-		persistFindSuggestionMemory(query, aiCommand, aiSource, aiRisk)
-		return
+	if aiCommand == "" {
+		if command, reason, ok := cheatSuggestion(matches); ok {
+			aiCommand, aiReason, aiSource, aiRisk = command, reason, matches[0].Source, "low"
+		}
 	}
-	if aiSuggestionMatchesTopHistory(aiCommand, matches) {
-		printSuggestedCommandBlock(aiCommand, aiReason, aiSource, opts)
-		persistFindSuggestionMemory(query, aiCommand, aiSource, aiRisk)
+	aiReason = reasonForDisplay(aiReason, opts)
+	aiRejected := aiCommand != "" && rejections.Rejected(query, aiCommand, now)
+
+	if warm == nil && (lowSignalFindQuery(query) && aiCommand != "" || aiSuggestionMatchesTopHistory(aiCommand, matches)) {
+		command, ok := fillSelectedCheat(aiCommand, aiSource, cfg, opts)
+		if !ok {
+			fmt.Println("Cancelled.")
+			return
+		}
+		printSuggestedCommandBlock(command, aiReason, aiSource, cfg, opts)
+		persistFindSuggestionMemory(query, command, aiSource, aiRisk)
 		return
 	}
 	if opts.Quiet {
@@ -1494,44 +1852,97 @@ func handleFind(query string, cfg config.Config, opts options) {
 		if matches == nil {
 			matches = []history.Match{}
 		}
+		displayCommand, rawAlternative := preferSaferSuggestion(aiCommand, true)
 		backend := effectiveUIBackend(cfg, opts)
 		if canUseInteractiveUI(opts, backend) {
-			selected, used, selectErr := ui.SelectSuggestedCommand(backend, query, ui.Selection{
-				Command: aiCommand,
-				Reason:  aiReason,
-				Source:  aiSource,
-			}, matches)
+			var revision *ui.Revision
+			if warm != nil {
+				revision = warm.revision
+			}
+			selected, used, selectErr := ui.SelectSuggestedCommandPaged(backend, query, ui.Selection{
+				Command:     displayCommand,
+				Reason:      withRejectionNote(aiReason, aiRejected),
+				Source:      aiSource,
+				Alternative: rawAlternative,
+				Rerank:      aiRerank,
+				Provisional: warm != nil,
+			}, matches, moreFindMatches(query, page.Next, cfg, opts), compareFindCandidate(query, displayCommand, cfg, opts), revision)
+			exitIfInterrupted()
+			if warm != nil && used {
+				if pick, ok := warm.stop(); ok {
+					aiCommand, aiRisk = pick.Command, pick.Risk
+				}
+				warm = nil
+			}
 			if selectErr == nil && used {
 				if strings.TrimSpace(selected.Command) == "" {
+					rememberRejection(query, displayCommand)
 					fmt.Println("Cancelled.")
 					return
 				}
-				printSuggestedCommandBlock(selected.Command, compactReason(selected.Reason, 120), selected.Source, opts)
 				selectedRisk := ""
-				if normalizeComparableCommand(selected.Command) == normalizeComparableCommand(aiCommand) {
+				learnedFrom := selected.Source
+				if selected.EditedFrom != "" {
+					edited, risk, ok := checkEditedCommand(selected.Command, cfg)
+					if !ok {
+						fmt.Println("Cancelled.")
+						return
+					}
+					selected.Command, selectedRisk = edited, risk
+					selected.Reason = editedReason(selected.Reason, selected.EditedFrom)
+					// The rewrite is the user's own, so it is learned even
+					// when it started as a memory match.
+					learnedFrom = "edited"
+				} else if normalizeComparableCommand(selected.Command) == normalizeComparableCommand(aiCommand) {
 					selectedRisk = aiRisk
 				}
-				persistFindSuggestionMemory(query, selected.Command, selected.Source, selectedRisk)
+				command, ok := fillSelectedCheat(selected.Command, selected.Source, cfg, opts)
+				if !ok {
+					fmt.Println("Cancelled.")
+					return
+				}
+				writeSuggestedCommandBlock(command, reasonForDisplay(selected.Reason, opts), selected.Source, selected.Alternative, cfg, opts)
+				persistFindSuggestionMemory(query, command, learnedFrom, selectedRisk)
 				return
 			}
 			if selectErr != nil {
 				fmt.Fprintf(os.Stderr, "ew: ui picker failed (%v); falling back to plain output\n", selectErr)
 			}
 		}
+		if warm != nil {
+			if pick, ok := warm.wait(opts, "ranking the best command"); ok {
+				aiCommand, aiSource, aiRisk, aiRerank = pick.Command, pick.Source, pick.Risk, pick.Rerank
+				aiReason = reasonForDisplay(pick.Reason, opts)
+				aiRejected = rejections.Rejected(query, aiCommand, now)
+				displayCommand, rawAlternative = preferSaferSuggestion(aiCommand, true)
+			}
+		}
 
-		fmt.Println("Suggested command:")
-		fmt.Println(aiCommand)
-		if aiReason != "" {
-			fmt.Printf("reason: %s\n", aiReason)
+		displayCommand, ok := fillSelectedCheat(displayCommand, aiSource, cfg, opts)
+		if !ok {
+			fmt.Println("Cancelled.")
+			return
 		}
-		if aiSource != "" {
-			fmt.Printf("source: %s\n", aiSource)
+		if isCheatSource(aiSource) {
+			aiCommand = displayCommand
 		}
+		noteSessionSuggestion(displayCommand, aiReason, aiSource)
+		fmt.Println("Suggested command:")
+		printCommand("", displayCommand)
+		printLabeled(reasonLabel, aiReason)
+		if aiRejected {
+			printLabeled("note: ", rejectedBeforeNote)
+		}
+		printLabeled("source: ", aiSource)
+		printLabeled("alternative: ", rawAlternative)
+		printLabeled("reranked: ", aiRerank)
 		persistFindSuggestionMemory(query, aiCommand, aiSource, aiRisk)
-		if copySuggestedCommand(aiCommand, opts) {
+		if copySuggestedCommand(displayCommand, opts) {
 			fmt.Println("copied: yes")
 		}
-		if len(matches) > 0 {
+		if aiRerank != "" {
+			fmt.Printf("History order: %s\n", historyOrderHint(query))
+		} else if len(matches) > 0 {
 			fmt.Println("Tip: add `--json` to inspect ranked history matches")
 		}
 		return
@@ -1539,9 +1950,17 @@ func handleFind(query string, cfg config.Config, opts options) {
 
 	fmt.Printf("Top matches for: %q\n", query)
 	for idx, match := range matches {
-		fmt.Printf("%d. %s\n", idx+1, match.Command)
+		rank := opts.Offset + idx + 1
+		lines := wrapCommand(fmt.Sprintf("%d. ", rank), match.Command, commandWrapWidth())
+		if breakdown := match.Breakdown(); breakdown != "" {
+			lines[len(lines)-1] += "  [" + breakdown + "]"
+		}
+		fmt.Println(strings.Join(lines, "\n"))
 	}
 	fmt.Println("Tip: use `ew --execute <query>` to execute the top match")
+	if page.Next != 0 {
+		fmt.Printf("More: ew --offset %d %s\n", page.Next, query)
+	}
 }
 
 func handleRun(query string, cfg config.Config, opts options) {
@@ -1554,28 +1973,36 @@ func handleRun(query string, cfg config.Config, opts options) {
 
 	memoryMatches, _ := searchMemoryWithLoader(query, cfg.Find.MaxResults, opts, "checking what you've used before")
 	if top, ok := preferredMemoryMatch(query, memoryMatches); ok {
-		outcome := executeSuggested(top.Command, fmt.Sprintf("learned from memory for %q (uses: %d)", top.Query, top.Uses), "", cfg, opts, router.IntentRun)
+		command, alternative := saferSuggestion(cfg, top.Command)
+		outcome := executeSuggested(command, alternative, fmt.Sprintf("learned from memory for %q (uses: %d)", top.Query, top.Uses), "", cfg, opts, router.IntentRun)
 		persistExecutionMemory(query, outcome)
 		return
 	}
 
+	// Missing shell history is not fatal: cheats, tldr, and the providers
+	// can still answer, and the hint is shown if they come up empty too.
 	matches, err := searchHistoryWithLoader(query, cfg.Find.MaxResults, opts, "scouting your history")
-	if err != nil {
+	if err != nil && !errors.Is(err, history.ErrNoHistory) {
 		payload := response{Intent: string(router.IntentRun), Message: fmt.Sprintf("search failed: %v", err)}
 		printResponse(payload, opts.JSON)
 		return
 	}
-	matches = filterFindMatches(query, matches)
+	matches = applyRepoBoost(applyTemporalBoost(filterFindMatches(cfg, query, matches), cfg, time.Now()))
+	matches = mergeCheatMatches(query, matches, cfg.Find.MaxResults)
+	matches = mergeRunbookMatches(query, matches, cfg.Find.MaxResults)
 	if len(matches) == 0 {
-		if opts.Offline {
+		if !providerAvailability(cfg, opts).allows(capabilityProviderFallback, opts) {
 			payload := response{Intent: string(router.IntentRun), Message: "no safe matching history entries found"}
+			if hint := errorHint(err); hint != "" {
+				payload.Suggestions = []string{hint}
+			}
 			printResponse(payload, opts.JSON)
 			return
 		}
 
-		prompt := buildFindPrompt(query, nil)
+		prompt := buildFindPrompt(cfg, query, nil)
 		resolution, providerName, resolveErr := resolveProviderWithLoader(
-			context.Background(),
+			invocationCtx,
 			cfg,
 			opts,
 			provider.IntentFind,
@@ -1584,22 +2011,20 @@ func handleRun(query string, cfg config.Config, opts options) {
 		)
 		if resolveErr != nil {
 			payload := response{
-				Intent:  string(router.IntentRun),
-				Message: "no local history match and provider fallback failed",
-				Suggestions: []string{
-					resolveErr.Error(),
-				},
+				Intent:      string(router.IntentRun),
+				Message:     "no local history match and provider fallback failed",
+				Suggestions: errorSuggestions(resolveErr),
 			}
 			printResponse(payload, opts.JSON)
 			return
 		}
-		decision := evaluateAIResolution(router.IntentRun, cfg, resolution)
+		decision := evaluateAIResolution(router.IntentRun, cfg, providerName, resolution)
 		if !decision.Allowed {
 			if !opts.JSON && strings.TrimSpace(decision.Command) != "" && commandAllowedForQuery(query, decision.Command) {
 				if strings.TrimSpace(decision.Message) != "" {
 					fmt.Printf("Not executed automatically: %s\n", decision.Message)
 				}
-				printSuggestedCommandBlock(decision.Command, compactReason(resolution.Reason, 120), providerName, opts)
+				printSuggestedCommandBlock(decision.Command, reasonForDisplay(resolution.Reason, opts), providerName, cfg, opts)
 				return
 			}
 			payload := response{
@@ -1612,6 +2037,9 @@ func handleRun(query string, cfg config.Config, opts options) {
 			if strings.TrimSpace(resolution.Reason) != "" {
 				payload.Suggestions = []string{resolution.Reason}
 			}
+			if commandAllowedForQuery(query, decision.Command) {
+				noteSessionSuggestion(strings.TrimSpace(decision.Command), resolution.Reason, providerName)
+			}
 			printResponse(payload, opts.JSON)
 			return
 		}
@@ -1629,34 +2057,62 @@ func handleRun(query string, cfg config.Config, opts options) {
 			printResponse(payload, opts.JSON)
 			return
 		}
-		outcome := executeSuggested(decision.Command, decision.Reason, decision.RiskHint, cfg, opts, router.IntentRun)
+		command, alternative := saferSuggestion(cfg, decision.Command)
+		outcome := executeSuggested(command, alternative, decision.Reason, decision.RiskHint, cfg, opts, router.IntentRun)
 		persistExecutionMemory(query, outcome)
 		return
 	}
 
 	command := matches[0].Command
 	reason := "selected from history"
-	if shouldAIRerank(cfg.Find.AIRerank, matches) && !opts.Offline {
-		prompt := buildFindPrompt(query, matches)
-		if resolution, providerName, err := resolveProviderWithLoader(
-			context.Background(),
+	if book, ok := runbookPick(query); ok {
+		command, reason = book.Command, book.Reason
+	} else if shouldAIRerank(aiRerankMode(cfg, opts), matches) && providerAvailability(cfg, opts).allows(capabilityAIRerank, opts) {
+		prompt := buildFindPrompt(cfg, query, matches)
+		if resolution, providerName, err := resolveProviderOrHistory(
 			cfg,
 			opts,
 			provider.IntentFind,
 			prompt,
 			"ranking the safest executable command",
+			matches[0].Command,
 		); err == nil && strings.TrimSpace(resolution.Command) != "" {
-			decision := evaluateAIResolution(router.IntentRun, cfg, resolution)
+			decision := evaluateAIResolution(router.IntentRun, cfg, providerName, resolution)
 			if decision.Allowed && commandAllowedForQuery(query, decision.Command) {
 				command = decision.Command
 				reason = fmt.Sprintf("%s (via %s)", decision.Reason, providerName)
+				if note := rerankNote(command, matches, 0); note != "" {
+					reason = fmt.Sprintf("%s; %s, see %s", reason, note, historyOrderHint(query))
+				}
 				if decision.ModeOverride != "" {
 					opts.Mode = decision.ModeOverride
 				}
 			}
 		}
 	}
-	outcome := executeSuggested(command, reason, "", cfg, opts, router.IntentRun)
+	if command == matches[0].Command && isCheatSource(matches[0].Source) {
+		reason = fmt.Sprintf("selected from %s", strings.TrimPrefix(matches[0].Source, cheatSourcePrefix))
+		if len(cheats.Placeholders(command)) > 0 {
+			if !canPromptForPlaceholders(opts) {
+				payload := response{
+					Intent:  string(router.IntentRun),
+					Message: "cheat command needs values for its placeholders; run it in a terminal or use ew --find",
+					Command: command,
+				}
+				noteSessionSuggestion(command, reason, matches[0].Source)
+				printResponse(payload, opts.JSON)
+				return
+			}
+			filled, ok := fillCheatPlaceholders(command, cfg, opts)
+			if !ok {
+				fmt.Println("Cancelled.")
+				return
+			}
+			command = filled
+		}
+	}
+	command, alternative := saferSuggestion(cfg, command)
+	outcome := executeSuggested(command, alternative, reason, "", cfg, opts, router.IntentRun)
 	persistExecutionMemory(query, outcome)
 }
 
@@ -1683,9 +2139,22 @@ func handleFix(userContext string, cfg config.Config, opts options) {
 		return
 	}
 
-	suggested, reason := ewrt.SuggestFix(ev.Command)
+	fixFailedCommand(*ev, ev.Output, userContext, cfg, opts)
+}
+
+// fixFailedCommand runs the fix pipeline for one failed command, whether it
+// came from hook capture or was passed explicitly with --command. errorText
+// is its error output: --error, or the stderr the hooks captured.
+func fixFailedCommand(ev hook.Event, errorText string, userContext string, cfg config.Config, opts options) {
+	if runtimeInteraction != nil {
+		runtimeInteraction.Failure = ev.Command
+	}
+	suggested, reason := learnedFix(ev.Command, true)
+	if suggested == "" {
+		suggested, reason = ewrt.SuggestFix(ev.Command)
+	}
 	if suggested == "" {
-		if opts.Offline {
+		if !providerAvailability(cfg, opts).allows(capabilityAIFix, opts) {
 			payload := response{
 				Intent:  string(router.IntentFix),
 				Message: "no deterministic fix found yet",
@@ -1697,34 +2166,60 @@ func handleFix(userContext string, cfg config.Config, opts options) {
 			return
 		}
 
-		prompt := buildFixPrompt(ev.Command, ev.ExitCode, ev.CWD, userContext)
-		resolution, providerName, resolveErr := resolveProviderWithLoader(
-			context.Background(),
+		// Fixes ew already offered for this failure are passed on as "do not
+		// repeat"; after fix.max_attempts of them ew stops asking unless the
+		// user adds something new to go on.
+		files, err := fixContextFiles(userContext, opts)
+		if err != nil {
+			printResponse(response{Intent: string(router.IntentFix), Message: err.Error()}, opts.JSON)
+			return
+		}
+		announceContextFiles(files)
+		loop := fixLoop(ev.Command, strings.TrimSpace(os.Getenv("EW_SESSION_ID")), time.Now().UTC())
+		if len(loop) >= cfg.Fix.MaxAttempts && !addsFixContext(userContext) && len(files) == 0 {
+			printResponse(fixLoopSummary(ev.Command, loop), opts.JSON)
+			return
+		}
+		attempts := failedAttempts(ev)
+		for _, command := range loopCommands(loop) {
+			if !repeatsFailedAttempt(command, attempts) {
+				attempts = append(attempts, command)
+			}
+		}
+
+		prompt := doNotRepeatPrompt(buildFixPrompt(cfg, ev.Command, ev.ExitCode, ev.CWD, errorText, userContext, files), loopCommands(loop))
+		resolution, providerName, resolveErr := resolveDistinctFix(
 			cfg,
 			opts,
-			provider.IntentFix,
 			prompt,
 			"debugging the failed command",
+			attempts,
 		)
 		if resolveErr != nil {
 			payload := response{
 				Intent:  string(router.IntentFix),
 				Message: "no deterministic fix found and provider fallback failed",
-				Suggestions: []string{
+				Suggestions: append([]string{
 					fmt.Sprintf("Failed command: %s", ev.Command),
-					resolveErr.Error(),
-				},
+				}, errorSuggestions(resolveErr)...),
 			}
 			printResponse(payload, opts.JSON)
 			return
 		}
-		decision := evaluateAIResolution(router.IntentFix, cfg, resolution)
+		decision := evaluateAIResolution(router.IntentFix, cfg, providerName, resolution)
+		if !decision.Allowed && len(resolution.Steps) > 0 && strings.TrimSpace(decision.Command) != "" {
+			if !opts.JSON && strings.TrimSpace(decision.Message) != "" {
+				fmt.Printf("Not executed automatically: %s\n", decision.Message)
+			}
+			printFixPlan(resolution.Steps, reasonForDisplay(resolution.Reason, opts), resolution.Risk, opts)
+			return
+		}
 		if !decision.Allowed {
 			if !opts.JSON && strings.TrimSpace(decision.Command) != "" {
 				if strings.TrimSpace(decision.Message) != "" {
 					fmt.Printf("Not executed automatically: %s\n", decision.Message)
 				}
-				printSuggestedCommandBlock(decision.Command, compactReason(resolution.Reason, 120), providerName, opts)
+				printSuggestedCommandBlock(decision.Command, reasonForDisplay(resolution.Reason, opts), providerName, cfg, opts)
 				return
 			}
 			payload := response{
@@ -1737,17 +2232,89 @@ func handleFix(userContext string, cfg config.Config, opts options) {
 			if strings.TrimSpace(resolution.Reason) != "" {
 				payload.Suggestions = []string{resolution.Reason}
 			}
+			noteSessionSuggestion(strings.TrimSpace(decision.Command), resolution.Reason, providerName)
 			printResponse(payload, opts.JSON)
 			return
 		}
 		if decision.ModeOverride != "" {
 			opts.Mode = decision.ModeOverride
 		}
-		executeSuggested(decision.Command, decision.Reason, decision.RiskHint, cfg, opts, router.IntentFix)
+		if len(resolution.Steps) > 0 {
+			runFixPlan(resolution.Steps, decision.Reason, decision.RiskHint, cfg, opts)
+			return
+		}
+		command, alternative := saferSuggestion(cfg, decision.Command)
+		executeSuggested(command, alternative, decision.Reason, decision.RiskHint, cfg, opts, router.IntentFix)
+		return
+	}
+
+	command, alternative := saferSuggestion(cfg, suggested)
+	executeSuggested(command, alternative, reason, "", cfg, opts, router.IntentFix)
+}
+
+// handleExplicitFix fixes a command passed with --command, skipping hook
+// capture and history inference entirely.
+func handleExplicitFix(userContext string, cfg config.Config, opts options) {
+	input, err := readExplicitFixInput(opts, os.Stdin)
+	if err != nil {
+		payload := response{Intent: string(router.IntentFix), Message: err.Error()}
+		printResponse(payload, opts.JSON)
 		return
 	}
+	fixFailedCommand(input.Event, input.ErrorText, userContext, cfg, opts)
+}
+
+type explicitFixInput struct {
+	Event     hook.Event
+	ErrorText string
+}
+
+const maxExplicitErrorText = 4000
+
+func readExplicitFixInput(opts options, stdin io.Reader) (explicitFixInput, error) {
+	command := opts.FailedCommand
+	errorText := opts.ErrorText
+	if command == "-" || errorText == "-" {
+		raw, err := io.ReadAll(io.LimitReader(stdin, 64*1024))
+		if err != nil {
+			return explicitFixInput{}, fmt.Errorf("could not read stdin: %w", err)
+		}
+		if command == "-" {
+			command = strings.TrimSpace(strings.SplitN(string(raw), "\n", 2)[0])
+		} else {
+			errorText = string(raw)
+		}
+	}
+	command = strings.TrimSpace(command)
+	if command == "" {
+		return explicitFixInput{}, fmt.Errorf("--command needs a non-empty command")
+	}
 
-	executeSuggested(suggested, reason, "", cfg, opts, router.IntentFix)
+	// Secrets are scrubbed later with the rest of the prompt (safety.redact_secrets).
+	errorText = strings.TrimSpace(errorText)
+	if len(errorText) > maxExplicitErrorText {
+		// The tail of an error log usually carries the actual failure. Cut
+		// on a rune boundary so no half character reaches the provider.
+		start := len(errorText) - maxExplicitErrorText
+		for start < len(errorText) && !utf8.RuneStart(errorText[start]) {
+			start++
+		}
+		errorText = "..." + errorText[start:]
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil || strings.TrimSpace(cwd) == "" {
+		cwd = "."
+	}
+	return explicitFixInput{
+		Event: hook.Event{
+			Command:   command,
+			ExitCode:  opts.ExitCode,
+			CWD:       cwd,
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		},
+		ErrorText: errorText,
+	}, nil
 }
 
 func printNoCapturedFailureMessage(opts options, detail string) {
@@ -1774,26 +2341,7 @@ func printNoCapturedFailureMessage(opts options, detail string) {
 }
 
 func staleFailureDetail(ev *hook.Event, now time.Time) (bool, string) {
-	if ev == nil {
-		return true, "no captured failure event"
-	}
-	ts, err := time.Parse(time.RFC3339, strings.TrimSpace(ev.Timestamp))
-	if err != nil {
-		detail := "captured failure has invalid timestamp"
-		if strings.TrimSpace(ev.Command) != "" {
-			detail += fmt.Sprintf(": %s", strings.TrimSpace(ev.Command))
-		}
-		return true, detail
-	}
-	age := now.Sub(ts)
-	if age <= maxFixFailureAge {
-		return false, ""
-	}
-	detail := fmt.Sprintf("captured %s ago: %s", age.Round(time.Minute), strings.TrimSpace(ev.Command))
-	if strings.TrimSpace(ev.SessionID) != "" {
-		detail += fmt.Sprintf(" (session: %s)", strings.TrimSpace(ev.SessionID))
-	}
-	return true, detail
+	return hook.StaleFailure(ev, now)
 }
 
 func tryInferredFixFromRecentHistory(userContext string, cfg config.Config, opts options) bool {
@@ -1806,17 +2354,22 @@ func tryInferredFixFromRecentHistory(userContext string, cfg config.Config, opts
 		return false
 	}
 
-	if suggested, reason := ewrt.SuggestFix(failedCommand); suggested != "" {
+	suggested, reason := learnedFix(failedCommand, true)
+	if suggested == "" {
+		suggested, reason = ewrt.SuggestFix(failedCommand)
+	}
+	if suggested != "" {
 		printSuggestedCommandBlock(
 			suggested,
-			compactReason("inferred from your latest shell command; "+reason, 120),
+			reasonForDisplay("inferred from your latest shell command; "+reason, opts),
 			"ew",
+			cfg,
 			opts,
 		)
 		return true
 	}
 
-	if opts.Offline {
+	if !providerAvailability(cfg, opts).allows(capabilityAIFix, opts) {
 		return false
 	}
 
@@ -1824,26 +2377,33 @@ func tryInferredFixFromRecentHistory(userContext string, cfg config.Config, opts
 	if cwdErr != nil || strings.TrimSpace(cwd) == "" {
 		cwd = "."
 	}
+	files, filesErr := fixContextFiles(userContext, opts)
+	if filesErr != nil {
+		return false
+	}
+	announceContextFiles(files)
 
 	prompt := buildFixPrompt(
+		cfg,
 		failedCommand,
 		1,
 		cwd,
+		"",
 		fallbackFixContext(userContext),
+		files,
 	)
-	resolution, providerName, resolveErr := resolveProviderWithLoader(
-		context.Background(),
+	resolution, providerName, resolveErr := resolveDistinctFix(
 		cfg,
 		opts,
-		provider.IntentFix,
 		prompt,
 		"inferring intent from your latest command",
+		[]string{failedCommand},
 	)
 	if resolveErr != nil {
 		return false
 	}
 
-	decision := evaluateAIResolution(router.IntentFix, cfg, resolution)
+	decision := evaluateAIResolution(router.IntentFix, cfg, providerName, resolution)
 	command := strings.TrimSpace(decision.Command)
 	if command == "" {
 		command = strings.TrimSpace(resolution.Command)
@@ -1860,7 +2420,7 @@ func tryInferredFixFromRecentHistory(userContext string, cfg config.Config, opts
 		return false
 	}
 
-	reason := strings.TrimSpace(resolution.Reason)
+	reason = strings.TrimSpace(resolution.Reason)
 	if reason == "" {
 		reason = strings.TrimSpace(decision.Reason)
 	}
@@ -1869,7 +2429,7 @@ func tryInferredFixFromRecentHistory(userContext string, cfg config.Config, opts
 	} else {
 		reason = "inferred from your latest shell command; " + reason
 	}
-	reason = compactReason(reason, 120)
+	reason = reasonForDisplay(reason, opts)
 
 	if opts.JSON {
 		payload := response{
@@ -1883,11 +2443,12 @@ func tryInferredFixFromRecentHistory(userContext string, cfg config.Config, opts
 				reason,
 			},
 		}
+		noteSessionSuggestion(normalized, reason, providerName)
 		printResponse(payload, true)
 		return true
 	}
 
-	printSuggestedCommandBlock(normalized, reason, providerName, opts)
+	printSuggestedCommandBlock(normalized, reason, providerName, cfg, opts)
 	return true
 }
 
@@ -1925,7 +2486,22 @@ func isCleanInferredCommand(command string) bool {
 	return true
 }
 
-func executeSuggested(command, reason, riskHint string, cfg config.Config, opts options, intent router.Intent) executionOutcome {
+// executeSuggested runs command through the policy gates and records what
+// happened. alternative is the command as first suggested when command is
+// the safer rewrite saferSuggestion made of it, and "" otherwise.
+func executeSuggested(command, alternative, reason, riskHint string, cfg config.Config, opts options, intent router.Intent) executionOutcome {
+	outcome := executeSuggestedCommand(command, alternative, reason, riskHint, cfg, opts, intent)
+	noteSessionOutcome(outcome, reason)
+	if outcome.Executed && outcome.Success && intent != router.IntentUndo {
+		recordUndo(outcome.Command, cfg)
+	}
+	if outcome.Cancelled {
+		rememberRejection(currentQuery(), outcome.Command)
+	}
+	return outcome
+}
+
+func executeSuggestedCommand(command, alternative, reason, riskHint string, cfg config.Config, opts options, intent router.Intent) executionOutcome {
 	normalizedCommand, normalizeErr := ewrt.NormalizeCommand(command)
 	if normalizeErr != nil {
 		payload := response{
@@ -1939,47 +2515,129 @@ func executeSuggested(command, reason, riskHint string, cfg config.Config, opts
 		return executionOutcome{Command: strings.TrimSpace(command), Executed: false, Success: false}
 	}
 	command = normalizedCommand
+	if strings.Contains(command, safety.RedactedMarker) {
+		// A history match with its secret scrubbed cannot run as it is.
+		payload := response{
+			Intent:   string(intent),
+			Message:  fmt.Sprintf("command rejected: replace %s with the real value and run it yourself", safety.RedactedMarker),
+			Command:  command,
+			Executed: false,
+		}
+		printResponse(payload, opts.JSON)
+		return executionOutcome{Command: command, Executed: false, Success: false}
+	}
+	rule, ruled := safetyPolicyRule(command)
+	if ruled && rule.Action == safety.ActionDeny {
+		payload := response{
+			Intent:   string(intent),
+			Message:  fmt.Sprintf("command blocked by safety policy: %s", rule.Describe()),
+			Command:  command,
+			Risk:     "high",
+			Executed: false,
+		}
+		printResponse(payload, opts.JSON)
+		return executionOutcome{Command: command, Executed: false, Success: false}
+	}
+	backend, targetErr := ewrt.ParseTarget(cfg.Execution.Target)
+	if targetErr != nil {
+		payload := response{Intent: string(intent), Message: fmt.Sprintf("invalid execution target: %v", targetErr), Command: command}
+		printResponse(payload, opts.JSON)
+		return executionOutcome{Command: command, Executed: false, Success: false}
+	}
+	target := ""
+	if backend.Remote() {
+		target = backend.Target()
+	}
+	if strings.TrimSpace(alternative) == command {
+		alternative = ""
+	}
 
 	mode := cfg.Mode
 	if strings.TrimSpace(opts.Mode) != "" {
 		mode = strings.TrimSpace(opts.Mode)
+	} else if len(opts.YoloFor) > 0 {
+		mode = scopedYoloMode(opts.YoloFor, command)
+	}
+
+	mode, assessment := applyExecutionRiskPolicy(cfg, mode, command, riskHint)
+	reasons := assessment.Reasons
+	branchWarning := protectedBranchWarning(cfg, command)
+	mode, risk := applyProtectedBranchPolicy(cfg, mode, assessment.Level, branchWarning)
+	warning := joinWarnings(branchWarning, packageManagerWarning(cfg, command))
+	if ruled && rule.Action == safety.ActionConfirm {
+		warning = joinWarnings("safety policy: "+rule.Describe(), warning)
+	}
+
+	// --preview reads the command's side effects and makes sure there is a
+	// confirmation to show them in.
+	var effects *analysis.Effects
+	if opts.Preview {
+		found := analysis.Analyze(command)
+		effects = &found
+		if !isConfirmMode(mode) && !strings.EqualFold(mode, "suggest") {
+			mode = "confirm"
+		}
 	}
 
-	mode, risk := applyExecutionRiskPolicy(cfg, mode, command, riskHint)
+	var plan *ewrt.PlanSummary
+	if opts.DryRun || (isConfirmMode(mode) && !opts.Yes) {
+		plan = previewApplyPlan(cfg, backend, command, !opts.DryRun && !opts.JSON)
+		if plan != nil && plan.Destroy > 0 {
+			risk = "high"
+			reasons = append(reasons, ui.RiskReason{Why: fmt.Sprintf("the plan destroys %d resource(s)", plan.Destroy)})
+		}
+	}
 
 	if opts.DryRun {
-		payload := response{Intent: string(intent), Message: reason, Command: command, Risk: risk, Executed: false}
+		payload := response{Intent: string(intent), Message: reason, Command: command, Risk: risk, RiskReasons: reasons, Target: target, Effects: effects, Warning: warning, Alternative: alternative, Plan: plan, Executed: false}
 		printResponse(payload, opts.JSON)
 		return executionOutcome{Command: command, Executed: false, Success: false}
 	}
 
 	if opts.JSON && isConfirmMode(mode) && !opts.Yes {
 		payload := response{
-			Intent:   string(intent),
-			Message:  "confirmation required; rerun with --yes or --mode yolo",
-			Command:  command,
-			Risk:     risk,
-			Executed: false,
+			Intent:      string(intent),
+			Message:     "confirmation required; rerun with --yes or --mode yolo",
+			Command:     command,
+			Risk:        risk,
+			RiskReasons: reasons,
+			Target:      target,
+			Effects:     effects,
+			Warning:     warning,
+			Alternative: alternative,
+			Plan:        plan,
+			Executed:    false,
 		}
 		printResponse(payload, true)
 		return executionOutcome{Command: command, Executed: false, Success: false}
 	}
 
 	if isConfirmMode(mode) && !opts.Yes && !opts.JSON {
-		backend := effectiveUIBackend(cfg, opts)
-		if canUseInteractiveUI(opts, backend) {
-			approved, used, uiErr := ui.ConfirmExecution(backend, command, risk)
+		uiBackend := effectiveUIBackend(cfg, opts)
+		if canUseInteractiveUI(opts, uiBackend) {
+			details := confirmDetailLines(opts, plan, effects)
+			if alternative != "" {
+				details = append([]string{"alternative: " + alternative}, details...)
+			}
+			explanation := renderReasonLines(reasonForDisplay(reason, opts), outputWidth(), false)
+			decision, used, uiErr := ui.ConfirmExecution(uiBackend, command, explanation, riskLabelWithWarning(riskLabelForTarget(risk, target), warning), reasons, details)
+			exitIfInterrupted()
 			if uiErr == nil && used {
-				if !approved {
+				if decision.Edited != "" {
+					// The rewrite is a new command: normalize it, weigh its
+					// risk, and confirm it from the start.
+					return executeSuggestedCommand(decision.Edited, "", editedReason(reason, command), "", cfg, opts, intent)
+				}
+				if !decision.Approved {
 					printConfirmCancelled(command, risk)
-					return executionOutcome{Command: command, Executed: false, Success: false}
+					return executionOutcome{Command: command, Executed: false, Success: false, Cancelled: true}
 				}
-				if err := ewrt.RunCommand(command); err != nil {
-					payload := response{Intent: string(intent), Message: fmt.Sprintf("execution failed: %v", err), Command: command, Risk: risk, Executed: true}
+				if err := runUserCommand(cfg, backend, command); err != nil {
+					payload := response{Intent: string(intent), Message: fmt.Sprintf("execution failed: %v", err), Command: command, Risk: risk, Target: target, Effects: effects, Executed: true}
 					printResponse(payload, opts.JSON)
 					return executionOutcome{Command: command, Executed: true, Success: false}
 				}
-				payload := response{Intent: string(intent), Message: reason, Command: command, Risk: risk, Executed: true}
+				payload := response{Intent: string(intent), Message: reason, Command: command, Alternative: alternative, Risk: risk, Target: target, Effects: effects, Executed: true}
 				printResponse(payload, opts.JSON)
 				return executionOutcome{Command: command, Executed: true, Success: true}
 			}
@@ -1990,11 +2648,42 @@ func executeSuggested(command, reason, riskHint string, cfg config.Config, opts
 
 		fmt.Println("Command to run:")
 		fmt.Println(command)
+		for _, line := range renderReasonLines(reasonForDisplay(reason, opts), outputWidth(), reasonStylingEnabled()) {
+			fmt.Println(line)
+		}
+		if alternative != "" {
+			fmt.Printf("alternative: %s\n", alternative)
+		}
+		if rejectedBefore(currentQuery(), command) {
+			fmt.Printf("note: %s\n", rejectedBeforeNote)
+		}
+		if target != "" {
+			fmt.Printf("target: %s\n", target)
+		}
+		if warning != "" {
+			fmt.Printf("warning: %s\n", warning)
+		}
+		if len(reasons) > 0 {
+			fmt.Printf("risk: %s\n", risk)
+			for _, reason := range reasons {
+				fmt.Printf("  why: %s\n", reason.Why)
+			}
+		}
+		for _, line := range confirmDetailLines(opts, plan, effects) {
+			fmt.Println(line)
+		}
+	}
+
+	if effects != nil && opts.Yes && !opts.JSON {
+		// --yes skips the question, not the preview.
+		for _, line := range effects.Lines() {
+			fmt.Println(line)
+		}
 	}
 
 	shouldRun, err := ewrt.ShouldExecute(mode, opts.Yes)
 	if err != nil {
-		payload := response{Intent: string(intent), Message: err.Error(), Command: command, Risk: risk}
+		payload := response{Intent: string(intent), Message: err.Error(), Command: command, Risk: risk, Target: target}
 		printResponse(payload, opts.JSON)
 		return executionOutcome{Command: command, Executed: false, Success: false}
 	}
@@ -2002,20 +2691,20 @@ func executeSuggested(command, reason, riskHint string, cfg config.Config, opts
 	if !shouldRun {
 		if isConfirmMode(mode) && !opts.Yes && !opts.JSON {
 			printConfirmCancelled(command, risk)
-			return executionOutcome{Command: command, Executed: false, Success: false}
+			return executionOutcome{Command: command, Executed: false, Success: false, Cancelled: true}
 		}
-		payload := response{Intent: string(intent), Message: reason, Command: command, Risk: risk, Executed: false}
+		payload := response{Intent: string(intent), Message: reason, Command: command, Alternative: alternative, Risk: risk, Target: target, Effects: effects, Executed: false}
 		printResponse(payload, opts.JSON)
 		return executionOutcome{Command: command, Executed: false, Success: false}
 	}
 
-	if err := ewrt.RunCommand(command); err != nil {
-		payload := response{Intent: string(intent), Message: fmt.Sprintf("execution failed: %v", err), Command: command, Risk: risk, Executed: true}
+	if err := runUserCommand(cfg, backend, command); err != nil {
+		payload := response{Intent: string(intent), Message: fmt.Sprintf("execution failed: %v", err), Command: command, Risk: risk, Target: target, Effects: effects, Executed: true}
 		printResponse(payload, opts.JSON)
 		return executionOutcome{Command: command, Executed: true, Success: false}
 	}
 
-	payload := response{Intent: string(intent), Message: reason, Command: command, Risk: risk, Executed: true}
+	payload := response{Intent: string(intent), Message: reason, Command: command, Alternative: alternative, Risk: risk, Target: target, Effects: effects, Executed: true}
 	printResponse(payload, opts.JSON)
 	return executionOutcome{Command: command, Executed: true, Success: true}
 }
@@ -2030,26 +2719,47 @@ func printConfirmCancelled(command string, risk string) {
 
 func printResponse(payload response, asJSON bool) {
 	if asJSON {
-		encoded, _ := json.MarshalIndent(payload, "", "  ")
+		if payload.Degraded == nil {
+			payload.Degraded = skippedCapabilities
+		}
+		if payload.Provider == "" {
+			payload.Provider, payload.Model = suggestionModel(payload.Command)
+			payload.FallbackFrom = suggestionFallbacks(payload.Command)
+		}
+		if runtimeIncludeRaw && payload.ProviderRaw == nil {
+			payload.ProviderRaw = runtimeProviderRaw
+		}
+		encoded, _ := marshalOutput(payload)
 		fmt.Println(string(encoded))
 		return
 	}
 	if payload.Message != "" {
-		fmt.Println(payload.Message)
+		fmt.Println(ui.ASCII(payload.Message))
 	}
 	if payload.Command != "" {
 		fmt.Printf("command: %s\n", payload.Command)
 	}
+	if payload.Alternative != "" {
+		fmt.Printf("alternative: %s\n", payload.Alternative)
+	}
 	if payload.Risk != "" {
 		fmt.Printf("risk: %s\n", payload.Risk)
 	}
+	if payload.Target != "" {
+		fmt.Printf("target: %s\n", payload.Target)
+	}
+	if payload.Effects != nil && !payload.Executed {
+		for _, line := range payload.Effects.Lines() {
+			fmt.Println(line)
+		}
+	}
 	if len(payload.Suggestions) > 0 {
 		for _, suggestion := range payload.Suggestions {
-			fmt.Printf("- %s\n", suggestion)
+			fmt.Printf("- %s\n", ui.ASCII(suggestion))
 		}
 	}
 	if payload.Results != nil {
-		encoded, _ := json.MarshalIndent(payload.Results, "", "  ")
+		encoded, _ := marshalOutput(payload.Results)
 		fmt.Println(string(encoded))
 	}
 	if payload.ConfigPath != "" {
@@ -2070,7 +2780,28 @@ func init() {
 	_, _ = knowledge.CorePrompt()
 }
 
-func runInternal(args ...string) ([]byte, error) {
+// useStateBackend switches memory, rejections, and the session journal to
+// the configured state backend. If it cannot be opened ew warns and keeps
+// using plain files rather than failing the command.
+func useStateBackend(cfg config.Config) func() {
+	if cfg.State.Backend == state.BackendFiles {
+		return nil
+	}
+	backend, err := state.Open(cfg.State.Backend)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ew: state.backend=%s unavailable, using files: %v\n", cfg.State.Backend, err)
+		return nil
+	}
+	state.Use(backend)
+	return func() {
+		state.Use(nil)
+		_ = backend.Close()
+	}
+}
+
+// internalBinaryCandidates lists the _ew executables to try: the one on
+// PATH first, then one installed next to ew.
+func internalBinaryCandidates() []string {
 	seen := map[string]struct{}{}
 	candidates := make([]string, 0, 2)
 
@@ -2086,48 +2817,82 @@ func runInternal(args ...string) ([]byte, error) {
 			}
 		}
 	}
+	return candidates
+}
 
-	var lastErr error
-	var lastOut []byte
-	for _, bin := range candidates {
-		cmd := exec.Command(bin, args...)
-		out, err := cmd.CombinedOutput()
-		if err == nil {
-			return out, nil
-		}
-		lastErr = err
-		lastOut = out
+// helperCommand is how the shell hooks reach the helper: ew's own hidden
+// `ew internal`.
+func helperCommand() []string {
+	self, err := os.Executable()
+	if err != nil {
+		return nil
 	}
+	return []string{self, "internal"}
+}
 
-	if lastErr == nil {
-		return nil, fmt.Errorf("_ew executable not found")
+// internalSubcommand recognises the hidden `ew internal <subcommand>` that
+// stands in for _ew. Anything else, including a prompt such as "ew
+// internal server error", is left to the normal argument parsing.
+func internalSubcommand(args []string) ([]string, bool) {
+	if len(args) < 2 || args[0] != "internal" || !helper.IsSubcommand(args[1]) {
+		return nil, false
 	}
-	return lastOut, lastErr
+	return args[1:], true
 }
 
+// detectShell picks the shell to set up hooks for from $SHELL. nushell
+// only announces itself through $NU_VERSION, and Windows, where $SHELL is
+// rarely set, defaults to PowerShell.
 func detectShell() string {
-	shellPath := strings.TrimSpace(os.Getenv("SHELL"))
-	if shellPath == "" {
-		return "zsh"
-	}
-	base := filepath.Base(shellPath)
-	switch base {
-	case "zsh", "bash", "fish":
-		return base
-	default:
-		return "zsh"
+	if shellPath := strings.TrimSpace(os.Getenv("SHELL")); shellPath != "" {
+		switch base := history.ShellName(filepath.Base(shellPath)); base {
+		case "zsh", "bash", "fish", "nu", "powershell":
+			return base
+		}
+	}
+	if os.Getenv("NU_VERSION") != "" {
+		return "nu"
 	}
+	if goruntime.GOOS == "windows" {
+		return "powershell"
+	}
+	return "zsh"
 }
 
 func resolveProvider(ctx context.Context, cfg config.Config, opts options, intent provider.Intent, prompt string) (provider.Resolution, string, error) {
+	started := time.Now()
+	resolution, providerName, err := askProvider(ctx, cfg, opts, intent, prompt)
+	noteSessionProvider(resolution, time.Since(started))
+	return resolution, providerName, err
+}
+
+// askProvider is resolveProvider without recording the answer in the
+// session, for side questions such as a picker comparison.
+func askProvider(ctx context.Context, cfg config.Config, opts options, intent provider.Intent, prompt string) (provider.Resolution, string, error) {
 	registry := provider.NewRegistry()
 	service := provider.NewService(registry)
+	warnFilteredProviderEnv(cfg, opts)
+	req := providerRequest(cfg, opts, intent, prompt)
+	ctx = provider.WithTracer(ctx, runtimeTracer)
+	resolution, providerName, err := resolveCached(ctx, service, cfg, opts, req)
+	if err == nil {
+		resolution = preferToolsInResolution(resolution, cfg.Tools.Prefer, opts)
+		resolution = toolFlagsInResolution(resolution, cfg.Tools.Groups, opts)
+	}
+	if err == nil && !isRemoteExecutionTarget(cfg) {
+		resolution = adaptResolutionForShell(resolution, opts)
+	}
+	return resolution, providerName, err
+}
+
+// providerRequest is the request askProvider sends for prompt, redacted
+// when safety.redact_secrets is on.
+func providerRequest(cfg config.Config, opts options, intent provider.Intent, prompt string) provider.Request {
 	model, thinking, mode := intentSettings(cfg, opts, intent)
 	if cfg.Safety.RedactSecrets {
 		prompt = safety.RedactText(prompt)
 	}
-
-	req := provider.Request{
+	return provider.Request{
 		Intent:   intent,
 		Prompt:   prompt,
 		Model:    model,
@@ -2135,14 +2900,13 @@ func resolveProvider(ctx context.Context, cfg config.Config, opts options, inten
 		Mode:     mode,
 		Context:  map[string]any{},
 	}
-	return service.Resolve(ctx, cfg, req, strings.TrimSpace(opts.Provider))
 }
 
 func intentSettings(cfg config.Config, opts options, intent provider.Intent) (string, string, string) {
 	var model string
 	var thinking string
 	switch intent {
-	case provider.IntentFind:
+	case provider.IntentFind, provider.IntentExplain:
 		model = cfg.Find.Model
 		thinking = cfg.Find.Thinking
 	default:
@@ -2163,45 +2927,59 @@ func intentSettings(cfg config.Config, opts options, intent provider.Intent) (st
 	return model, thinking, mode
 }
 
-func buildFixPrompt(command string, exitCode int, cwd string, userContext string) string {
+func buildFixPrompt(cfg config.Config, command string, exitCode int, cwd string, errorText string, userContext string, files []contextFile) string {
 	base := fmt.Sprintf(
-		"Return only JSON matching schema. Diagnose and fix this failed shell command. Failed command: %q. Exit code: %d. Working directory: %q. Output one safest next command.",
+		"Return only JSON matching schema. Diagnose and fix this failed shell command. Failed command: %q. Exit code: %d. Working directory: %q. Output one safest next command. If the fix takes several commands that must run in order (for example git fetch, then git rebase), list each one in steps and set command to the first; otherwise leave steps empty.",
 		command,
 		exitCode,
 		cwd,
 	)
+	if errorOutput := strings.TrimSpace(errorText); errorOutput != "" {
+		base += fmt.Sprintf(" Error output: %q.", errorOutput)
+	}
 	contextNote := strings.TrimSpace(userContext)
 	lower := strings.ToLower(contextNote)
 	if contextNote != "" && !isTrivialFixContext(lower) {
 		base += fmt.Sprintf(" Additional user context: %q.", contextNote)
 	}
-	return wrapWithSelfKnowledge(base)
+	base += contextFilesPrompt(files)
+	return wrapWithSelfKnowledge(cfg, knowledge.ScopeFix, base)
 }
 
-func buildFindPrompt(query string, candidates []history.Match) string {
+func buildFindPrompt(cfg config.Config, query string, candidates []history.Match) string {
 	base := fmt.Sprintf("Return only JSON matching schema. Find the best shell command for this request: %q.", query)
+	base += tldrGrounding(query)
+	scope := findKnowledgeScope(query)
 	if len(candidates) == 0 {
-		return wrapWithSelfKnowledge(base + " There were no local history matches.")
+		return wrapWithSelfKnowledge(cfg, scope, base+" There were no local history matches.")
 	}
 	lines := make([]string, 0, len(candidates))
 	for idx, candidate := range candidates {
 		lines = append(lines, fmt.Sprintf("%d) %s (score=%.2f)", idx+1, candidate.Command, candidate.Score))
 	}
-	return wrapWithSelfKnowledge(base + " Rank these candidate commands and pick the best one:\n" + strings.Join(lines, "\n"))
+	return wrapWithSelfKnowledge(cfg, scope, base+" Rank these candidate commands and pick the best one:\n"+strings.Join(lines, "\n"))
 }
 
-func wrapWithSelfKnowledge(prompt string) string {
-	core, err := knowledge.CorePrompt()
-	core = strings.TrimSpace(core)
+func wrapWithSelfKnowledge(cfg config.Config, scope knowledge.Scope, prompt string) string {
+	core := strings.TrimSpace(selfKnowledgePrompt(scope, cfg.Prompt))
 	systemContext := strings.TrimSpace(runtimeSystemContext)
 
 	parts := make([]string, 0, 2)
-	if err == nil && core != "" {
+	if core != "" {
 		parts = append(parts, "EW_SELF_KNOWLEDGE_JSON:\n"+core)
 	}
 	if systemContext != "" {
 		parts = append(parts, "EW_SYSTEM_PROFILE:\n"+systemContext)
 	}
+	if project := projectContextPrompt(runtimeRepo); project != "" {
+		parts = append(parts, "EW_PROJECT:\n"+project)
+	}
+	if tools := toolPreferencesPrompt(cfg.Tools.Prefer); tools != "" {
+		parts = append(parts, "EW_TOOL_PREFERENCES:\n"+tools)
+	}
+	if notes := toolNotesPrompt(cfg.Tools.Groups, prompt); notes != "" {
+		parts = append(parts, "EW_TOOL_NOTES:\n"+notes)
+	}
 	if len(parts) == 0 {
 		return strings.TrimSpace(prompt)
 	}
@@ -2209,6 +2987,40 @@ func wrapWithSelfKnowledge(prompt string) string {
 	return strings.Join(parts, "\n\n")
 }
 
+// selfKnowledgePrompt picks the self knowledge for a prompt according to
+// prompt.self_knowledge: the sections relevant to scope within the token
+// budget (compiled), the whole document (full), or nothing (off).
+func selfKnowledgePrompt(scope knowledge.Scope, cfg config.PromptConfig) string {
+	var (
+		core string
+		err  error
+	)
+	switch cfg.SelfKnowledge {
+	case "off":
+		return ""
+	case "full":
+		core, err = knowledge.CorePrompt()
+	default:
+		core, err = knowledge.ScopedPrompt(scope, cfg.SelfKnowledgeTokens)
+	}
+	if err != nil {
+		return ""
+	}
+	return core
+}
+
+// findKnowledgeScope gives questions about ew itself ("how do I turn off
+// ew's hints") the config sections instead of the find ones.
+func findKnowledgeScope(query string) knowledge.Scope {
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		switch strings.Trim(word, ".,:;!?'\"`") {
+		case "ew", "ew's", "_ew", "config", "config.toml", "setting", "settings":
+			return knowledge.ScopeConfig
+		}
+	}
+	return knowledge.ScopeFind
+}
+
 func isTrivialFixContext(lower string) bool {
 	switch strings.TrimSpace(lower) {
 	case "", "fix", "ew", "last failed", "fix last failed command", "fix the last failed command":
@@ -2236,10 +3048,12 @@ func lowSignalFindQuery(query string) bool {
 	return countSignalTokens(query) < 2
 }
 
-func filterFindMatches(query string, matches []history.Match) []history.Match {
+func filterFindMatches(cfg config.Config, query string, matches []history.Match) []history.Match {
 	if len(matches) == 0 {
 		return matches
 	}
+	matches = preferToolsInMatches(matches, cfg.Tools.Prefer)
+	matches = toolFlagsInMatches(matches, cfg.Tools.Groups)
 	allowDestructive := queryAllowsDestructive(query)
 	allowHighRisk := queryAllowsHighRisk(query)
 	readOnly := queryPrefersReadOnly(query)
@@ -2323,6 +3137,13 @@ func queryAllowsHighRisk(query string) bool {
 }
 
 func isDestructiveCommand(command string) bool {
+	_, ok := destructivePattern(command)
+	return ok
+}
+
+// destructivePattern returns the destructive pattern command contains, such
+// as "git reset --hard".
+func destructivePattern(command string) (string, bool) {
 	low := strings.ToLower(strings.TrimSpace(command))
 	patterns := []string{
 		"rm ",
@@ -2338,10 +3159,10 @@ func isDestructiveCommand(command string) bool {
 	}
 	for _, pattern := range patterns {
 		if strings.Contains(low, pattern) {
-			return true
+			return strings.TrimSpace(pattern), true
 		}
 	}
-	return false
+	return "", false
 }
 
 func commandAllowedForQuery(query string, command string) bool {
@@ -2508,29 +3329,111 @@ func hasWriteRedirection(command string) bool {
 	return false
 }
 
-func applyExecutionRiskPolicy(cfg config.Config, mode string, command string, riskHint string) (string, string) {
+// applyExecutionRiskPolicy decides how command may run and how risky it is.
+// The assessment keeps the reasons for its level: what the command's source
+// rated it, the risky parts of the command, and the settings that raised it.
+func applyExecutionRiskPolicy(cfg config.Config, mode string, command string, riskHint string) (string, riskAssessment) {
 	effectiveMode := strings.ToLower(strings.TrimSpace(mode))
 	if effectiveMode == "" {
 		effectiveMode = "confirm"
 	}
 
-	risk := normalizeRiskHint(riskHint)
+	risk := riskAssessment{Level: normalizeRiskHint(riskHint)}
+	if risk.Level != "low" {
+		risk.because(fmt.Sprintf("its source rated it %s", risk.Level))
+	}
 	isHighRiskCommand := ewrt.HighRisk(command)
 	isDestructive := isDestructiveCommand(command)
+	isMutating := isMutatingCommand(command)
+	// A safety.toml allow rule stands in for the built-in checks.
+	rule, ruled := safetyPolicyRule(command)
+	if ruled && rule.Action == safety.ActionAllow {
+		isHighRiskCommand, isDestructive, isMutating = false, false, false
+		risk = riskAssessment{Level: "low"}
+		if rule.Risk != "" {
+			risk.Level = rule.Risk
+		}
+		risk.because("safety policy: " + rule.Describe())
+	} else {
+		risk.Reasons = append(risk.Reasons, commandRiskReasons(command)...)
+	}
 	if (isHighRiskCommand || isDestructive) && cfg.Safety.BlockHighRisk {
-		risk = "high"
-	} else if (isHighRiskCommand || isDestructive) && risk == "low" {
-		risk = "medium"
-	} else if isMutatingCommand(command) && risk == "low" {
-		risk = "medium"
+		risk.Level = "high"
+		risk.because("safety.block_high_risk counts destructive commands as high risk")
+	} else if (isHighRiskCommand || isDestructive) && risk.Level == "low" {
+		risk.Level = "medium"
+	} else if isMutating && risk.Level == "low" {
+		risk.Level = "medium"
+		if len(risk.Reasons) == 0 {
+			risk.because("changes files or state")
+		}
+	}
+	if ruled && rule.Action != safety.ActionAllow {
+		if rule.Action == safety.ActionDeny {
+			risk.Level = "high"
+			risk.because("safety policy: " + rule.Describe())
+		} else if riskRank(rule.Risk) > riskRank(risk.Level) {
+			risk.Level = rule.Risk
+			risk.because("safety policy: " + rule.Describe())
+		}
+		if effectiveMode == "yolo" {
+			effectiveMode = "confirm"
+		}
+	}
+
+	// A [tools.<name>] group with confirm = true never runs unasked.
+	if _, ok := toolGroupConfirm(cfg, command); ok && effectiveMode == "yolo" {
+		effectiveMode = "confirm"
+	}
+
+	// Remote targets are shared machines: anything that changes state there is
+	// one notch riskier and never runs without a confirmation.
+	if isRemoteExecutionTarget(cfg) {
+		if risk.Level == "medium" && (isHighRiskCommand || isDestructive || isMutating) {
+			risk.Level = "high"
+			risk.because(fmt.Sprintf("changes state on the remote target %s", cfg.Execution.Target))
+		}
+		if effectiveMode == "yolo" && risk.Level != "low" {
+			effectiveMode = "confirm"
+		}
 	}
 
-	if effectiveMode == "yolo" && !cfg.Safety.AllowYoloHighRisk && (risk == "high" || (cfg.Safety.BlockHighRisk && (isHighRiskCommand || isDestructive))) {
+	if effectiveMode == "yolo" && !cfg.Safety.AllowYoloHighRisk && (risk.Level == "high" || (cfg.Safety.BlockHighRisk && (isHighRiskCommand || isDestructive))) {
+		effectiveMode = "confirm"
+	}
+	// Oversized one-liners always need a human look, even with allow_yolo_high_risk.
+	if effectiveMode == "yolo" && exceedsAutoExecutionLimits(cfg, command) {
 		effectiveMode = "confirm"
 	}
 	return effectiveMode, risk
 }
 
+func isRemoteExecutionTarget(cfg config.Config) bool {
+	backend, err := ewrt.ParseTarget(cfg.Execution.Target)
+	return err == nil && backend.Remote()
+}
+
+func riskLabelForTarget(risk string, target string) string {
+	if target == "" {
+		return risk
+	}
+	return fmt.Sprintf("%s (on %s)", risk, target)
+}
+
+func exceedsAutoExecutionLimits(cfg config.Config, command string) bool {
+	shape := ewrt.MeasureCommand(command)
+	if cfg.Safety.MaxAutoCommandLength > 0 && shape.Length > cfg.Safety.MaxAutoCommandLength {
+		return true
+	}
+	if cfg.Safety.MaxAutoArgs > 0 && shape.Args > cfg.Safety.MaxAutoArgs {
+		return true
+	}
+	if cfg.Safety.MaxAutoPaths > 0 && shape.Paths > cfg.Safety.MaxAutoPaths {
+		return true
+	}
+	return false
+}
+
 func countSignalTokens(query string) int {
 	return len(queryRelevanceTokens(query))
 }
@@ -2585,12 +3488,18 @@ func aiSuggestionMatchesTopHistory(aiCommand string, matches []history.Match) bo
 	return normalizeComparableCommand(aiCommand) == normalizeComparableCommand(top)
 }
 
+// normalizeComparableCommand folds case, collapses whitespace, and drops
+// trailing semicolons and line continuations, so two spellings of the same
+// command compare equal.
 func normalizeComparableCommand(command string) string {
-	normalized := strings.TrimSpace(strings.ToLower(command))
-	for strings.HasSuffix(normalized, `\`) {
-		normalized = strings.TrimSpace(strings.TrimSuffix(normalized, `\`))
+	normalized := strings.Join(strings.Fields(strings.ToLower(command)), " ")
+	for {
+		trimmed := strings.TrimSpace(strings.TrimRight(normalized, `;\`))
+		if trimmed == normalized {
+			return normalized
+		}
+		normalized = trimmed
 	}
-	return normalized
 }
 
 func compactReason(reason string, max int) string {
@@ -2627,13 +3536,19 @@ func providerFallbackMessage(action string, providerName string) string {
 	}
 }
 
-func printSuggestedCommandBlock(command, reason, source string, opts options) {
+func printSuggestedCommandBlock(command, reason, source string, cfg config.Config, opts options) {
+	normalized, alternative := preferSaferSuggestion(strings.TrimSpace(command), true)
+	writeSuggestedCommandBlock(normalized, reason, source, alternative, cfg, opts)
+}
+
+func writeSuggestedCommandBlock(command, reason, source, alternative string, cfg config.Config, opts options) {
 	normalized := strings.TrimSpace(command)
 	if normalized == "" {
 		fmt.Println("No suggested command available")
 		return
 	}
 	if opts.Quiet {
+		noteSessionSuggestion(normalized, reason, source)
 		if copySuggestedCommand(normalized, opts) {
 			// quiet mode intentionally emits only the command on stdout.
 		}
@@ -2641,28 +3556,105 @@ func printSuggestedCommandBlock(command, reason, source string, opts options) {
 		return
 	}
 
+	noteSessionSuggestion(normalized, reason, source)
 	fmt.Println("Suggested command:")
-	fmt.Println(normalized)
-	if reason != "" {
-		fmt.Printf("reason: %s\n", reason)
+	printCommand("", normalized)
+	for _, line := range renderReasonLines(reason, outputWidth(), reasonStylingEnabled()) {
+		fmt.Println(line)
+	}
+	if rejectedBefore(currentQuery(), normalized) {
+		printLabeled("note: ", rejectedBeforeNote)
 	}
-	if source != "" {
-		fmt.Printf("source: %s\n", source)
+	printLabeled("warning: ", joinWarnings(protectedBranchWarning(cfg, normalized), packageManagerWarning(cfg, normalized)))
+	printLabeled("source: ", source)
+	if opts.Verbose {
+		if providerName, model := suggestionModel(normalized); model != "" {
+			printLabeled("model: ", modelLabel(providerName, model))
+		}
+		if providerName, _ := suggestionModel(normalized); providerName != "" && runtimeInteraction.Confidence > 0 {
+			printLabeled("confidence: ", confidenceLabel(cfg, providerName, runtimeInteraction.Confidence))
+		}
+		if fallbacks := suggestionFallbacks(normalized); len(fallbacks) > 0 {
+			printLabeled("fallback: ", strings.Join(fallbacks, ", ")+" failed first")
+		}
+		if providerName, _ := suggestionModel(normalized); providerName != "" && runtimeProviderCached {
+			printLabeled("cache: ", "reused an answer to the same request (--no-cache asks again)")
+		}
 	}
+	printLabeled("alternative: ", alternative)
 	if copySuggestedCommand(normalized, opts) {
 		fmt.Println("copied: yes")
 	}
 }
 
+// preferSaferSuggestion applies the local rewrites (project package manager,
+// recoverable deletion) and returns the original command as the alternative.
+func preferSaferSuggestion(command string, allowInteractive bool) (string, string) {
+	if rewritten, original := preferProjectPackageManager(command); original != "" {
+		return rewritten, original
+	}
+	return preferSaferDeletion(command, allowInteractive)
+}
+
+// saferSuggestion applies the local rewrites to a command ew is about to
+// offer for running, so the confirmation shows what will run. It returns the
+// command as suggested as the alternative, or "" when nothing was rewritten.
+// Remote targets are left alone: trash tools and lockfiles are local facts.
+func saferSuggestion(cfg config.Config, command string) (string, string) {
+	if isRemoteExecutionTarget(cfg) {
+		return command, ""
+	}
+	if normalized, err := ewrt.NormalizeCommand(command); err == nil {
+		command = normalized
+	}
+	return preferSaferSuggestion(command, false)
+}
+
+// preferSaferDeletion swaps an rm suggestion for a recoverable rewrite and
+// returns the original command as the alternative.
+func preferSaferDeletion(command string, allowInteractive bool) (string, string) {
+	rewritten, ok := ewrt.SaferDelete(command, runtimeSystemTools, allowInteractive)
+	if !ok {
+		return command, ""
+	}
+	return rewritten, command
+}
+
 func searchHistoryWithLoader(query string, limit int, opts options, label string) ([]history.Match, error) {
+	results, err := searchHistoryPageWithLoader(query, history.Page{Limit: limit}, opts, label)
+	return results.Matches, err
+}
+
+func searchHistoryPageWithLoader(query string, page history.Page, opts options, label string) (history.Results, error) {
 	var (
-		matches []history.Match
+		results history.Results
 		err     error
 	)
 	withEWLoader(opts, label, func() {
-		matches, err = history.Search(query, limit)
+		results, err = history.SearchPage(invocationCtx, query, page)
 	})
-	return matches, err
+	return results, err
+}
+
+// moreFindMatches pages through history for the picker's "show more"
+// entry, starting at next. The page is filtered and ranked like the first
+// one; it is nil when history is exhausted.
+func moreFindMatches(query string, next int, cfg config.Config, opts options) func() []history.Match {
+	if next == 0 {
+		return nil
+	}
+	return func() []history.Match {
+		if next == 0 {
+			return nil
+		}
+		results, err := searchHistoryPageWithLoader(query, history.Page{Offset: next, Limit: cfg.Find.MaxResults}, opts, "digging further back")
+		if err != nil {
+			return nil
+		}
+		next = results.Next
+		matches := applyRepoBoost(applyTemporalBoost(filterFindMatches(cfg, query, results.Matches), cfg, time.Now()))
+		return downrankRejectedHistory(query, matches, loadRejections(), time.Now().UTC())
+	}
 }
 
 func searchMemoryWithLoader(query string, limit int, opts options, label string) ([]memory.Match, error) {
@@ -2676,20 +3668,20 @@ func searchMemoryWithLoader(query string, limit int, opts options, label string)
 		if err != nil {
 			return
 		}
-		matches = store.Search(query, limit)
+		matches = store.SearchFrom(query, limit, memoryOrigin())
 	})
 	return matches, err
 }
 
 func preferredMemoryMatch(query string, matches []memory.Match) (memory.Match, bool) {
 	for _, candidate := range matches {
-		if strings.TrimSpace(candidate.Command) == "" {
+		if strings.TrimSpace(candidate.Command) == "" || candidate.Rejected || candidate.Elsewhere {
 			continue
 		}
 		if !commandAllowedForQuery(query, candidate.Command) {
 			continue
 		}
-		if !memoryQueryCompatible(query, candidate.Query) {
+		if !memoryQueryCompatible(query, candidate.Query) && !semanticallyCompatible(query, candidate) {
 			continue
 		}
 		if candidate.Exact || candidate.Score >= 26 || (candidate.Uses >= 2 && candidate.Score >= 18) {
@@ -2824,7 +3816,7 @@ func latestHistoryEntryWithLoader(maxAge time.Duration, opts options) (*history.
 		err   error
 	)
 	withEWLoader(opts, "checking your latest shell command", func() {
-		entry, err = history.LatestEntry(maxAge)
+		entry, err = history.LatestEntry(invocationCtx, maxAge)
 	})
 	return entry, err
 }
@@ -2842,8 +3834,8 @@ func resolveProviderWithLoader(
 		providerName string
 		err          error
 	)
-	withEWLoader(opts, label, func() {
-		resolution, providerName, err = resolveProvider(ctx, cfg, opts, intent, prompt)
+	withStreamingLoader(opts, label, func(partial func(string)) {
+		resolution, providerName, err = resolveProvider(provider.WithPartialOutput(ctx, partial), cfg, opts, intent, prompt)
 	})
 	return resolution, providerName, err
 }
@@ -2857,14 +3849,22 @@ func persistExecutionMemory(query string, outcome executionOutcome) {
 	if query == "" || command == "" {
 		return
 	}
-	store, path, err := memory.Load()
+	store, _, err := memory.Load()
 	if err != nil {
 		return
 	}
-	if err := store.Learn(query, command, true); err != nil {
+	store.Describe("learn " + query)
+	if err := store.LearnAt(query, command, true, memoryOrigin()); err != nil {
 		return
 	}
-	_ = memory.Save(path, store)
+	_ = memory.Save(store)
+}
+
+// memoryOrigin is where memory is learned and searched from: the working
+// directory, its repository, and the user's shell.
+func memoryOrigin() memory.Origin {
+	dir, _ := os.Getwd()
+	return memory.OriginFor(dir, interactiveShell())
 }
 
 func shouldPersistFindSuggestion(query string, command string, source string, risk string) bool {
@@ -2879,6 +3879,9 @@ func shouldPersistFindSuggestion(query string, command string, source string, ri
 	if normalizeRiskHint(risk) == "high" {
 		return false
 	}
+	if unfilledCheat(command, source) {
+		return false
+	}
 	if !commandAllowedForQuery(query, command) {
 		return false
 	}
@@ -2889,31 +3892,49 @@ func persistFindSuggestionMemory(query string, command string, source string, ri
 	if !shouldPersistFindSuggestion(query, command, source, risk) {
 		return
 	}
-	store, path, err := memory.Load()
+	store, _, err := memory.Load()
 	if err != nil {
 		return
 	}
-	if err := store.Learn(query, command, true); err != nil {
+	store.Describe("learn " + query)
+	if err := store.LearnAt(query, command, true, memoryOrigin()); err != nil {
 		return
 	}
-	_ = memory.Save(path, store)
+	_ = memory.Save(store)
 }
 
 func withEWLoader(opts options, label string, run func()) {
 	if run == nil {
 		return
 	}
+	withStreamingLoader(opts, label, func(func(string)) { run() })
+}
+
+// withStreamingLoader is withEWLoader for a step that can report output as
+// it works, such as a provider CLI: run gets a func that shows its latest
+// line next to the loader message. The func is nil when the loader is off.
+func withStreamingLoader(opts options, label string, run func(partial func(string))) {
+	if run == nil {
+		return
+	}
+	defer exitIfInterrupted()
 	if !loaderEnabled(opts) {
-		run()
+		run(nil)
 		return
 	}
 
+	status := &loaderStatus{}
+	runWithLoader(label, status, func() { run(status.setPartial) })
+}
+
+// runWithLoader draws the loader for label, with status, while run works.
+func runWithLoader(label string, status *loaderStatus, run func()) {
 	done := make(chan struct{})
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		renderEWLoader(label, done)
+		renderEWLoader(label, done, status)
 	}()
 
 	run()
@@ -2933,7 +3954,7 @@ func loaderEnabled(opts options) bool {
 	return isTerminal(os.Stderr)
 }
 
-func renderEWLoader(label string, done <-chan struct{}) {
+func renderEWLoader(label string, done <-chan struct{}, status *loaderStatus) {
 	delay := time.NewTimer(180 * time.Millisecond)
 	defer delay.Stop()
 	select {
@@ -2947,11 +3968,19 @@ func renderEWLoader(label string, done <-chan struct{}) {
 	ticker := time.NewTicker(260 * time.Millisecond)
 	defer ticker.Stop()
 
+	// A dumb terminal ignores the erase-line sequence, so ASCII mode pads
+	// over the previous message with spaces instead.
+	clearLine := func(width int) string { return "\x1b[K" }
+	if ui.ASCIIOnly() {
+		clearLine = func(width int) string { return strings.Repeat(" ", max(width, 0)) }
+	}
 	index := 0
 	messageIndex := 0
+	previous := 0
 	for {
-		line := fmt.Sprintf("%s %s", frames[index], messages[messageIndex])
-		fmt.Fprintf(os.Stderr, "\r%s\x1b[K", line)
+		line := status.line(frames[index], messages[messageIndex], invocationCtx.Err() != nil, loaderWidth())
+		fmt.Fprintf(os.Stderr, "\r%s%s", line, clearLine(previous-len(line)))
+		previous = len(line)
 		index = (index + 1) % len(frames)
 		if index == 0 {
 			messageIndex = (messageIndex + 1) % len(messages)
@@ -2959,7 +3988,7 @@ func renderEWLoader(label string, done <-chan struct{}) {
 
 		select {
 		case <-done:
-			fmt.Fprint(os.Stderr, "\r\x1b[K")
+			fmt.Fprintf(os.Stderr, "\r%s\r", clearLine(previous))
 			return
 		case <-ticker.C:
 		}
@@ -6,10 +6,12 @@ import (
 	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 
 	"github.com/ashwch/ew/internal/config"
 	"github.com/ashwch/ew/internal/history"
 	"github.com/ashwch/ew/internal/hook"
+	"github.com/ashwch/ew/internal/knowledge"
 	"github.com/ashwch/ew/internal/memory"
 	"github.com/ashwch/ew/internal/router"
 )
@@ -183,24 +185,6 @@ func TestIsFixPrompt(t *testing.T) {
 	}
 }
 
-func TestFallbackHookSnippet(t *testing.T) {
-	zsh := fallbackHookSnippet("zsh")
-	if !strings.Contains(zsh, "add-zsh-hook preexec _ew_preexec") {
-		t.Fatalf("expected zsh fallback snippet to contain zsh hook setup")
-	}
-	bash := fallbackHookSnippet("bash")
-	if !strings.Contains(bash, "_EW_LAST_HISTCMD") {
-		t.Fatalf("expected bash fallback snippet to contain HISTCMD guard")
-	}
-	fish := fallbackHookSnippet("fish")
-	if !strings.Contains(fish, "function __ew_preexec --on-event fish_preexec") {
-		t.Fatalf("expected fish fallback snippet to contain fish preexec hook")
-	}
-	if got := fallbackHookSnippet("powershell"); got != "" {
-		t.Fatalf("expected unsupported shell fallback snippet to be empty, got %q", got)
-	}
-}
-
 func TestLowSignalFindQuery(t *testing.T) {
 	if !lowSignalFindQuery("push to gh") {
 		t.Fatalf("expected push-to-gh query to be low signal")
@@ -215,7 +199,7 @@ func TestFilterFindMatchesRemovesHighRiskForNonDestructiveQuery(t *testing.T) {
 		{Command: "rm -rf /tmp/foo", Score: 10},
 		{Command: "git worktree add ../my-wt -b feat/new", Score: 9},
 	}
-	filtered := filterFindMatches("command to create new worktree", matches)
+	filtered := filterFindMatches(config.Default(), "command to create new worktree", matches)
 	if len(filtered) != 1 {
 		t.Fatalf("expected one filtered match, got %d", len(filtered))
 	}
@@ -229,7 +213,7 @@ func TestFilterFindMatchesRemovesDestructiveRmForNonDestructiveQuery(t *testing.
 		{Command: "rm /tmp/foo", Score: 11},
 		{Command: "uv run scripts/create_worktree.py", Score: 10},
 	}
-	filtered := filterFindMatches("command to create new worktree", matches)
+	filtered := filterFindMatches(config.Default(), "command to create new worktree", matches)
 	if len(filtered) != 1 {
 		t.Fatalf("expected one non-destructive command, got %d", len(filtered))
 	}
@@ -242,7 +226,7 @@ func TestFilterFindMatchesKeepsHighRiskForExplicitHighRiskQuery(t *testing.T) {
 	matches := []history.Match{
 		{Command: "rm -rf /tmp/foo", Score: 10},
 	}
-	filtered := filterFindMatches("wipe disk with rm -rf", matches)
+	filtered := filterFindMatches(config.Default(), "wipe disk with rm -rf", matches)
 	if len(filtered) != 1 {
 		t.Fatalf("expected high-risk match to remain for explicit high-risk query")
 	}
@@ -253,7 +237,7 @@ func TestFilterFindMatchesReturnsEmptyWhenOnlyDestructiveMatchesForSafeQuery(t *
 		{Command: "rm -rf /tmp/foo", Score: 10},
 		{Command: "git checkout -- .", Score: 9},
 	}
-	filtered := filterFindMatches("command to create new worktree", matches)
+	filtered := filterFindMatches(config.Default(), "command to create new worktree", matches)
 	if len(filtered) != 0 {
 		t.Fatalf("expected no safe matches, got %d", len(filtered))
 	}
@@ -264,7 +248,7 @@ func TestFilterFindMatchesDropsLowQualityMatches(t *testing.T) {
 		{Command: "poetry run some-heavy-script --input global-data-file.csv", Score: 2.7},
 		{Command: "another unrelated command with global file text", Score: 3.1},
 	}
-	filtered := filterFindMatches("find my global gitignore file", matches)
+	filtered := filterFindMatches(config.Default(), "find my global gitignore file", matches)
 	if len(filtered) != 0 {
 		t.Fatalf("expected weak lexical overlaps to be removed, got %d matches", len(filtered))
 	}
@@ -275,7 +259,7 @@ func TestFilterFindMatchesDropsMutatingForReadOnlyQuery(t *testing.T) {
 		{Command: `echo 'export PATH="$HOME/bin:$PATH"' >> ~/.zshrc`, Score: 10.0},
 		{Command: "echo ~/.zshrc", Score: 9.0},
 	}
-	filtered := filterFindMatches("path to .zshrc", matches)
+	filtered := filterFindMatches(config.Default(), "path to .zshrc", matches)
 	if len(filtered) != 1 {
 		t.Fatalf("expected only read-only command to remain, got %d", len(filtered))
 	}
@@ -419,8 +403,8 @@ func TestApplyExecutionRiskPolicyForcesConfirmForDestructiveYolo(t *testing.T) {
 	if mode != "confirm" {
 		t.Fatalf("expected destructive yolo command to downgrade to confirm, got %q", mode)
 	}
-	if risk != "high" {
-		t.Fatalf("expected destructive command risk to be high, got %q", risk)
+	if risk.Level != "high" {
+		t.Fatalf("expected destructive command risk to be high, got %q", risk.Level)
 	}
 }
 
@@ -430,8 +414,8 @@ func TestApplyExecutionRiskPolicyProviderHighRiskForcesConfirm(t *testing.T) {
 	if mode != "confirm" {
 		t.Fatalf("expected provider high risk to force confirm in yolo mode, got %q", mode)
 	}
-	if risk != "high" {
-		t.Fatalf("expected normalized risk high, got %q", risk)
+	if risk.Level != "high" {
+		t.Fatalf("expected normalized risk high, got %q", risk.Level)
 	}
 }
 
@@ -442,8 +426,8 @@ func TestApplyExecutionRiskPolicyAllowYoloHighRiskBypassesDowngrade(t *testing.T
 	if mode != "yolo" {
 		t.Fatalf("expected yolo mode to remain when allow_yolo_high_risk=true, got %q", mode)
 	}
-	if risk != "high" {
-		t.Fatalf("expected destructive command risk to still be high, got %q", risk)
+	if risk.Level != "high" {
+		t.Fatalf("expected destructive command risk to still be high, got %q", risk.Level)
 	}
 }
 
@@ -454,8 +438,8 @@ func TestApplyExecutionRiskPolicyRespectsBlockHighRiskFalse(t *testing.T) {
 	if mode != "yolo" {
 		t.Fatalf("expected yolo mode to remain when block_high_risk=false, got %q", mode)
 	}
-	if risk != "medium" {
-		t.Fatalf("expected downgraded medium risk when block_high_risk=false, got %q", risk)
+	if risk.Level != "medium" {
+		t.Fatalf("expected downgraded medium risk when block_high_risk=false, got %q", risk.Level)
 	}
 }
 
@@ -465,8 +449,36 @@ func TestApplyExecutionRiskPolicyElevatesMutatingLowRisk(t *testing.T) {
 	if mode != "confirm" {
 		t.Fatalf("expected confirm mode to remain, got %q", mode)
 	}
-	if risk != "medium" {
-		t.Fatalf("expected mutating command low risk to be elevated to medium, got %q", risk)
+	if risk.Level != "medium" {
+		t.Fatalf("expected mutating command low risk to be elevated to medium, got %q", risk.Level)
+	}
+}
+
+func TestApplyExecutionRiskPolicyOversizedCommandForcesConfirm(t *testing.T) {
+	cfg := config.Default()
+	cfg.Safety.AllowYoloHighRisk = true
+	cfg.Safety.MaxAutoArgs = 4
+	mode, _ := applyExecutionRiskPolicy(cfg, "yolo", "echo one two three four five", "low")
+	if mode != "confirm" {
+		t.Fatalf("expected command over max_auto_args to force confirm, got %q", mode)
+	}
+
+	cfg = config.Default()
+	cfg.Safety.MaxAutoPaths = 2
+	mode, _ = applyExecutionRiskPolicy(cfg, "yolo", "cat /etc/hosts /etc/passwd ./notes.txt", "low")
+	if mode != "confirm" {
+		t.Fatalf("expected command over max_auto_paths to force confirm, got %q", mode)
+	}
+
+	cfg = config.Default()
+	mode, _ = applyExecutionRiskPolicy(cfg, "yolo", "echo "+strings.Repeat("x", cfg.Safety.MaxAutoCommandLength), "low")
+	if mode != "confirm" {
+		t.Fatalf("expected command over max_auto_command_length to force confirm, got %q", mode)
+	}
+
+	mode, _ = applyExecutionRiskPolicy(config.Default(), "yolo", "git status", "low")
+	if mode != "yolo" {
+		t.Fatalf("expected short command to keep yolo, got %q", mode)
 	}
 }
 
@@ -679,6 +691,18 @@ func TestParseSelfPromptActionSuggestExecutionQuestionDoesNotToggle(t *testing.T
 	}
 }
 
+func TestParseSelfPromptActionClearCache(t *testing.T) {
+	for _, prompt := range []string{"cache clear", "ew clear cache"} {
+		action, ok := parseSelfPromptAction(prompt)
+		if !ok || action.Kind != selfActionCacheClear {
+			t.Fatalf("%q: expected cache_clear action, got %+v", prompt, action)
+		}
+	}
+	if action, ok := parseSelfPromptAction("clear npm cache"); ok && action.Kind == selfActionCacheClear {
+		t.Fatalf("did not expect an external cache to clear ew's: %+v", action)
+	}
+}
+
 func TestParseSelfPromptActionAvoidsExternalConfigFalsePositive(t *testing.T) {
 	if action, ok := parseSelfPromptAction("show config for nginx"); ok {
 		t.Fatalf("did not expect self action for external config query: %+v", action)
@@ -763,6 +787,25 @@ func TestParseMemoryPromptAction(t *testing.T) {
 	}
 }
 
+func TestParseMemoryPromptActionScope(t *testing.T) {
+	cases := []struct {
+		prompt string
+		scope  string
+		query  string
+	}{
+		{"remember run tests means pytest", memoryScopeKeep, "run tests"},
+		{"remember here run tests means go test ./...", memoryScopeHere, "run tests"},
+		{"remember in this project that run tests => make test", memoryScopeHere, "run tests"},
+		{"remember everywhere run tests means make test", memoryScopeGlobal, "run tests"},
+	}
+	for _, tc := range cases {
+		action, ok := parseMemoryPromptAction(tc.prompt)
+		if !ok || action.Kind != memoryActionSave || action.Scope != tc.scope || action.Query != tc.query {
+			t.Fatalf("parseMemoryPromptAction(%q) = %+v", tc.prompt, action)
+		}
+	}
+}
+
 func TestParseMemoryPromptActionAvoidsForgetFalsePositives(t *testing.T) {
 	if action, ok := parseMemoryPromptAction("forget about this deploy plan"); ok {
 		t.Fatalf("did not expect memory action for generic forget prompt: %+v", action)
@@ -772,6 +815,32 @@ func TestParseMemoryPromptActionAvoidsForgetFalsePositives(t *testing.T) {
 	}
 }
 
+func TestParseMemoryPromptActionBootstrap(t *testing.T) {
+	for _, prompt := range []string{"memory bootstrap", "bootstrap my memory", "Bootstrap memory from shell history"} {
+		if action, ok := parseMemoryPromptAction(prompt); !ok || action.Kind != memoryActionSeed {
+			t.Fatalf("expected %q to bootstrap memory, got %+v %v", prompt, action, ok)
+		}
+	}
+	for _, prompt := range []string{"bootstrap", "bootstrap a react app", "memory bootstrap for nginx"} {
+		if action, ok := parseMemoryPromptAction(prompt); ok && action.Kind == memoryActionSeed {
+			t.Fatalf("did not expect %q to bootstrap memory", prompt)
+		}
+	}
+}
+
+func TestParseMemoryPromptActionUndo(t *testing.T) {
+	for _, prompt := range []string{"memory undo", "undo last memory change", "Undo forget"} {
+		if action, ok := parseMemoryPromptAction(prompt); !ok || action.Kind != memoryActionUndo {
+			t.Fatalf("expected %q to undo memory, got %+v %v", prompt, action, ok)
+		}
+	}
+	for _, prompt := range []string{"undo", "undo git commit", "memory undo button in vim"} {
+		if action, ok := parseMemoryPromptAction(prompt); ok && action.Kind == memoryActionUndo {
+			t.Fatalf("did not expect %q to undo memory", prompt)
+		}
+	}
+}
+
 func TestParseMemoryPromptActionHindiRemember(t *testing.T) {
 	action, ok := parseMemoryPromptAction("याद रखो push current branch का मतलब git push origin HEAD")
 	if !ok {
@@ -824,6 +893,17 @@ func TestPreferredMemoryMatchSkipsIncompatibleTopCandidate(t *testing.T) {
 	}
 }
 
+func TestPreferredMemoryMatchSkipsOtherRepositories(t *testing.T) {
+	matches := []memory.Match{
+		{Query: "run tests", Command: "make test-api", Score: 40, Uses: 5, Exact: true, Elsewhere: true},
+		{Query: "run tests", Command: "go test ./...", Score: 30, Uses: 2, Exact: true},
+	}
+	match, ok := preferredMemoryMatch("run tests", matches)
+	if !ok || match.Command != "go test ./..." {
+		t.Fatalf("expected the entry from another repository skipped, got %+v %v", match, ok)
+	}
+}
+
 func TestMemoryQueryCompatible(t *testing.T) {
 	if !memoryQueryCompatible("where is go installed", "where is go installed") {
 		t.Fatalf("expected exact query compatibility")
@@ -873,7 +953,7 @@ func TestWrapWithSelfKnowledgeIncludesSystemProfileBlock(t *testing.T) {
 		runtimeSystemContext = previous
 	})
 
-	wrapped := wrapWithSelfKnowledge("find the right command")
+	wrapped := wrapWithSelfKnowledge(config.Default(), knowledge.ScopeFind, "find the right command")
 	if !strings.Contains(wrapped, "EW_SYSTEM_PROFILE:\nos=darwin arch=arm64\ntools=git, go, uv") {
 		t.Fatalf("expected system profile block, got: %q", wrapped)
 	}
@@ -881,3 +961,136 @@ func TestWrapWithSelfKnowledgeIncludesSystemProfileBlock(t *testing.T) {
 		t.Fatalf("expected task block, got: %q", wrapped)
 	}
 }
+
+func TestWrapWithSelfKnowledgeFollowsPromptConfig(t *testing.T) {
+	cfg := config.Default()
+
+	fix := wrapWithSelfKnowledge(cfg, knowledge.ScopeFix, "fix it")
+	if !strings.Contains(fix, "EW_SELF_KNOWLEDGE_JSON:") || !strings.Contains(fix, `"diagnostics_and_hooks"`) {
+		t.Fatalf("expected compiled fix knowledge, got: %q", fix)
+	}
+	if strings.Contains(fix, `"config_surface":`) {
+		t.Fatalf("did not expect the config surface in a fix prompt")
+	}
+
+	cfg.Prompt.SelfKnowledge = "off"
+	if got := wrapWithSelfKnowledge(cfg, knowledge.ScopeFix, "fix it"); strings.Contains(got, "EW_SELF_KNOWLEDGE_JSON") {
+		t.Fatalf("expected no self knowledge when off, got: %q", got)
+	}
+}
+
+func TestFindKnowledgeScope(t *testing.T) {
+	if got := findKnowledgeScope("how do I turn off ew hints"); got != knowledge.ScopeConfig {
+		t.Fatalf("expected config scope, got %q", got)
+	}
+	if got := findKnowledgeScope("list docker containers"); got != knowledge.ScopeFind {
+		t.Fatalf("expected find scope, got %q", got)
+	}
+}
+
+func TestApplyExecutionRiskPolicyRemoteTargetIsStricter(t *testing.T) {
+	cfg := config.Default()
+	cfg.Execution.Target = "ssh:prod"
+	mode, risk := applyExecutionRiskPolicy(cfg, "yolo", "echo hi >/tmp/demo-file", "low")
+	if mode != "confirm" {
+		t.Fatalf("expected mutating remote command to need confirmation, got %q", mode)
+	}
+	if risk.Level != "high" {
+		t.Fatalf("expected mutating remote command risk to be high, got %q", risk.Level)
+	}
+
+	mode, risk = applyExecutionRiskPolicy(cfg, "yolo", "uptime", "low")
+	if mode != "yolo" || risk.Level != "low" {
+		t.Fatalf("expected read-only remote command to keep yolo/low, got %q/%q", mode, risk.Level)
+	}
+}
+
+func TestParseArgsExplicitFixFlags(t *testing.T) {
+	opts, prompt, err := parseArgs([]string{"--command", "git push", "--error", "rejected", "--exit-code", "128", "on", "my", "laptop"})
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	if opts.FailedCommand != "git push" || opts.ErrorText != "rejected" || opts.ExitCode != 128 {
+		t.Fatalf("unexpected explicit fix options: %+v", opts)
+	}
+	if prompt != "on my laptop" {
+		t.Fatalf("expected remaining args as fix context, got %q", prompt)
+	}
+
+	for _, args := range [][]string{
+		{"--error", "boom"},
+		{"--command", "-", "--error", "-"},
+		{"--command", "make", "--execute"},
+	} {
+		if _, _, err := parseArgs(args); err == nil {
+			t.Fatalf("expected %v to be rejected", args)
+		}
+	}
+}
+
+func TestReadExplicitFixInputReadsStdin(t *testing.T) {
+	input, err := readExplicitFixInput(options{FailedCommand: "npm test", ErrorText: "-", ExitCode: 1}, strings.NewReader("Error: cannot find module 'jest'\n"))
+	if err != nil {
+		t.Fatalf("readExplicitFixInput failed: %v", err)
+	}
+	if input.Event.Command != "npm test" || input.ErrorText != "Error: cannot find module 'jest'" {
+		t.Fatalf("unexpected fix input: %+v", input)
+	}
+
+	input, err = readExplicitFixInput(options{FailedCommand: "-", ExitCode: 2}, strings.NewReader("cargo build\nextra line\n"))
+	if err != nil {
+		t.Fatalf("readExplicitFixInput failed: %v", err)
+	}
+	if input.Event.Command != "cargo build" || input.Event.ExitCode != 2 {
+		t.Fatalf("expected first stdin line as command, got %+v", input.Event)
+	}
+}
+
+func TestReadExplicitFixInputKeepsExitCodeAndWholeRunes(t *testing.T) {
+	input, err := readExplicitFixInput(options{FailedCommand: "make lint", ExitCode: 0}, strings.NewReader(""))
+	if err != nil || input.Event.ExitCode != 0 {
+		t.Fatalf("expected an explicit --exit-code 0 to be kept, got %+v %v", input.Event, err)
+	}
+
+	long := strings.Repeat("€", maxExplicitErrorText)
+	input, err = readExplicitFixInput(options{FailedCommand: "make", ErrorText: long, ExitCode: 1}, strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("readExplicitFixInput failed: %v", err)
+	}
+	if !utf8.ValidString(input.ErrorText) || !strings.HasPrefix(input.ErrorText, "...€") || len(input.ErrorText) > maxExplicitErrorText+3 {
+		t.Fatalf("expected the tail cut on a rune boundary, got %d bytes starting %q", len(input.ErrorText), input.ErrorText[:8])
+	}
+}
+
+func TestBuildFixPromptIncludesErrorOutput(t *testing.T) {
+	prompt := buildFixPrompt(config.Default(), "npm test", 1, "/repo", "cannot find module", "", nil)
+	if !strings.Contains(prompt, `Error output: "cannot find module"`) {
+		t.Fatalf("expected error output in prompt, got %q", prompt)
+	}
+}
+
+func TestParseArgsOffset(t *testing.T) {
+	opts, prompt, err := parseArgs([]string{"--offset", "8", "docker", "logs"})
+	if err != nil || opts.Offset != 8 || prompt != "docker logs" {
+		t.Fatalf("unexpected parse result %+v %q %v", opts, prompt, err)
+	}
+	if _, _, err := parseArgs([]string{"--offset", "-1", "docker"}); err == nil {
+		t.Fatalf("expected a negative offset to fail")
+	}
+}
+
+func TestInternalSubcommandOnlyTakesHelperCommands(t *testing.T) {
+	sub, ok := internalSubcommand([]string{"internal", "latest-failure", "--session-id", "1.2"})
+	if !ok || strings.Join(sub, " ") != "latest-failure --session-id 1.2" {
+		t.Fatalf("expected the helper subcommand, got %q %v", sub, ok)
+	}
+	for _, args := range [][]string{
+		{"internal", "server", "error"},
+		{"internal"},
+		{"fix", "internal", "doctor"},
+	} {
+		if _, ok := internalSubcommand(args); ok {
+			t.Fatalf("expected %q to stay a prompt", args)
+		}
+	}
+}
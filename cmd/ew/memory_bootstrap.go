@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/history"
+	"github.com/ashwch/ew/internal/knowledge"
+	"github.com/ashwch/ew/internal/memory"
+	"github.com/ashwch/ew/internal/provider"
+	"github.com/ashwch/ew/internal/router"
+	ewrt "github.com/ashwch/ew/internal/runtime"
+	"github.com/ashwch/ew/internal/ui"
+)
+
+const (
+	bootstrapLimit = 15
+	// bootstrapQueryMax keeps provider names to something a person would
+	// type.
+	bootstrapQueryMax = 60
+)
+
+// handleMemoryBootstrap answers `ew memory bootstrap`: it proposes memory
+// entries for the commands you run most, names them, and learns the ones
+// you accept. Without a terminal it only lists them unless --yes is set.
+func handleMemoryBootstrap(cfg config.Config, opts options) {
+	entries, err := history.LoadRuns(invocationCtx)
+	if err != nil {
+		message := fmt.Sprintf("could not read shell history: %v", err)
+		if errors.Is(err, history.ErrNoHistory) {
+			message = "no shell history to learn from"
+		}
+		printResponse(response{Intent: string(router.IntentMemoryBootstrap), Message: message}, opts.JSON)
+		return
+	}
+	commands := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.ViaEW || entry.Redacted {
+			continue
+		}
+		commands = append(commands, entry.Command)
+	}
+
+	store, _, err := memory.Load()
+	if err != nil {
+		printResponse(response{Intent: string(router.IntentMemoryBootstrap), Message: fmt.Sprintf("memory load failed: %v", err)}, opts.JSON)
+		return
+	}
+	candidates := store.Bootstrap(commands, bootstrapLimit, func(command string) bool {
+		return !ewrt.HighRisk(command) && !isDestructiveCommand(command)
+	})
+	if len(candidates) == 0 {
+		printResponse(response{
+			Intent:  string(router.IntentMemoryBootstrap),
+			Message: fmt.Sprintf("nothing new to learn: no command in your history ran %d or more times that memory does not already have", memory.BootstrapMinRuns),
+		}, opts.JSON)
+		return
+	}
+	named := nameBootstrapCandidates(candidates, cfg, opts)
+
+	picked := candidates
+	if !opts.Yes {
+		backend := effectiveUIBackend(cfg, opts)
+		if !canUseInteractiveUI(opts, backend) {
+			printBootstrapCandidates(candidates, named, opts)
+			return
+		}
+		chosen, used, uiErr := ui.PickCandidates(backend, candidates)
+		exitIfInterrupted()
+		if uiErr != nil || !used {
+			if uiErr != nil {
+				fmt.Fprintf(os.Stderr, "ew: candidate picker failed (%v)\n", uiErr)
+			}
+			printBootstrapCandidates(candidates, named, opts)
+			return
+		}
+		if chosen == nil {
+			fmt.Println("Memory unchanged.")
+			return
+		}
+		picked = chosen
+	}
+
+	store.Describe("learn from shell history")
+	for _, candidate := range picked {
+		if err := store.Learn(candidate.Query, candidate.Command, true); err != nil {
+			printResponse(response{Intent: string(router.IntentMemoryBootstrap), Message: fmt.Sprintf("memory update failed: %v", err)}, opts.JSON)
+			return
+		}
+	}
+	if err := memory.Save(store); err != nil {
+		printResponse(response{Intent: string(router.IntentMemoryBootstrap), Message: fmt.Sprintf("memory save failed: %v", err)}, opts.JSON)
+		return
+	}
+	printResponse(response{
+		Intent:   string(router.IntentMemoryBootstrap),
+		Message:  fmt.Sprintf("learned %d of %d commands from shell history", len(picked), len(candidates)),
+		Results:  picked,
+		Provider: named,
+	}, opts.JSON)
+}
+
+// printBootstrapCandidates lists the proposals without learning them.
+func printBootstrapCandidates(candidates []memory.Candidate, named string, opts options) {
+	message := "memory candidates from shell history; rerun with --yes to learn all of them"
+	if opts.JSON {
+		printResponse(response{Intent: string(router.IntentMemoryBootstrap), Message: message, Results: candidates, Provider: named}, true)
+		return
+	}
+	fmt.Println("Memory candidates from shell history:")
+	for idx, candidate := range candidates {
+		printCommand(fmt.Sprintf("%d. ", idx+1), candidate.Command)
+		printLabeled("   query: ", candidate.Query)
+		fmt.Printf("   runs: %d | variants: %d\n", candidate.Runs, candidate.Variants)
+	}
+	if named != "" {
+		fmt.Printf("names suggested by %s\n", named)
+	}
+	fmt.Println("Rerun with --yes to learn all of them, or in a terminal to pick.")
+}
+
+// nameBootstrapCandidates asks one provider for a request-style name for
+// every candidate and returns who named them. Candidates the provider
+// skips, and all of them without a provider, keep the name made from their
+// own words; the provider is then empty.
+func nameBootstrapCandidates(candidates []memory.Candidate, cfg config.Config, opts options) string {
+	if !providerAvailability(cfg, opts).allows(capabilityAIMemoryNames, opts) {
+		return ""
+	}
+	resolution, providerName, err := resolveProviderWithLoader(invocationCtx, cfg, opts, provider.IntentExplain, buildBootstrapPrompt(cfg, candidates), "naming your frequent commands")
+	if err != nil {
+		return ""
+	}
+	names := map[string]string{}
+	for _, part := range resolution.Explanation {
+		name := strings.Trim(strings.TrimSpace(part.Meaning), ".\"'")
+		if name == "" || len(name) > bootstrapQueryMax {
+			continue
+		}
+		names[strings.TrimSpace(part.Part)] = name
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	for idx, candidate := range candidates {
+		if name, ok := names[candidate.Command]; ok && name != candidate.Command {
+			candidates[idx].Query = name
+		}
+	}
+	return providerName
+}
+
+func buildBootstrapPrompt(cfg config.Config, candidates []memory.Candidate) string {
+	lines := make([]string, 0, len(candidates))
+	for _, candidate := range candidates {
+		lines = append(lines, fmt.Sprintf("%q", candidate.Command))
+	}
+	base := "Return only JSON matching schema. These are shell commands the user runs often: " + strings.Join(lines, ", ") + ". " +
+		"For each one, write the short request (3 to 6 plain words, no command syntax) the user would type to ask for it, such as \"push current branch\". " +
+		"Fill explanation with one entry per command in the order given, the exact command as part and the request as meaning. " +
+		"Leave command empty and never set action to run."
+	return wrapWithSelfKnowledge(cfg, knowledge.ScopeFind, base)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/memory"
+)
+
+func TestMemoryBootstrapLearnsProviderNamedCommands(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script test is not portable on windows")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, ".local", "state"))
+	history := strings.Repeat("git push origin HEAD\n", 4) + strings.Repeat("make lint\n", 3) + "make build\n"
+	if err := os.WriteFile(filepath.Join(home, ".bash_history"), []byte(history), 0o600); err != nil {
+		t.Fatalf("write history failed: %v", err)
+	}
+	resetSkippedCapabilities(t)
+	original := healthyProviders
+	t.Cleanup(func() { healthyProviders = original })
+	healthyProviders = func(config.Config, string) []string { return []string{"stub"} }
+
+	script := filepath.Join(t.TempDir(), "provider.sh")
+	body := `#!/bin/sh
+echo '{"action":"suggest","command":"","reason":"","risk":"low","confidence":0.9,"needs_confirmation":false,"explanation":[{"part":"git push origin HEAD","meaning":"push current branch"}]}'
+`
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatalf("write script failed: %v", err)
+	}
+	enabled := true
+	cfg := config.Default()
+	cfg.Providers = map[string]config.ProviderConfig{
+		"stub": {Type: "command", Command: script, Model: "stub-model", Enabled: &enabled, Args: []string{"{prompt}"}},
+	}
+
+	out := captureStdout(t, func() {
+		handleMemoryBootstrap(cfg, options{JSON: true, Yes: true, Provider: "stub"})
+	})
+	var payload response
+	if err := json.Unmarshal([]byte(out), &payload); err != nil {
+		t.Fatalf("expected JSON, got %q: %v", out, err)
+	}
+	if !strings.Contains(payload.Message, "learned 2 of 2") || payload.Provider != "stub" {
+		t.Fatalf("unexpected payload %+v", payload)
+	}
+
+	store, _, err := memory.Load()
+	if err != nil {
+		t.Fatalf("memory load failed: %v", err)
+	}
+	if matches := store.Search("push current branch", 1); len(matches) == 0 || matches[0].Command != "git push origin HEAD" || !matches[0].Exact {
+		t.Fatalf("expected the provider's name learned, got %+v", matches)
+	}
+	if matches := store.Search("make lint", 1); len(matches) == 0 || matches[0].Command != "make lint" {
+		t.Fatalf("expected the unnamed command to keep its own words, got %+v", matches)
+	}
+}
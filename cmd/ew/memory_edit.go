@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/memory"
+	"github.com/ashwch/ew/internal/router"
+	"github.com/ashwch/ew/internal/ui"
+)
+
+// memoryManagerPrompts are the whole prompts that open the memory manager:
+// `ew memory edit` and its shorter and longer forms. --edit-memory does the
+// same.
+var memoryManagerPrompts = map[string]bool{
+	"memory":         true,
+	"memories":       true,
+	"memory edit":    true,
+	"edit memory":    true,
+	"manage memory":  true,
+	"memory manager": true,
+}
+
+func isMemoryManagerPrompt(prompt string) bool {
+	low := strings.ToLower(strings.Join(strings.Fields(prompt), " "))
+	return memoryManagerPrompts[strings.TrimRight(low, ".!?")]
+}
+
+// handleMemoryEdit opens the memory manager. Without a terminal it lists the
+// store instead, since there is nothing to edit interactively.
+func handleMemoryEdit(cfg config.Config, opts options) {
+	store, _, err := memory.Load()
+	if err != nil {
+		payload := response{Intent: string(router.IntentMemoryEdit), Message: fmt.Sprintf("memory load failed: %v", err)}
+		printResponse(payload, opts.JSON)
+		return
+	}
+
+	backend := effectiveUIBackend(cfg, opts)
+	if !canUseInteractiveUI(opts, backend) {
+		payload := response{
+			Intent:  string(router.IntentMemoryEdit),
+			Message: "memory editor needs an interactive terminal; listing entries instead",
+			Results: store.Entries,
+		}
+		if len(store.Entries) == 0 {
+			payload.Message = "memory is empty"
+			payload.Results = nil
+		}
+		printResponse(payload, opts.JSON)
+		return
+	}
+
+	edited, saved, used, uiErr := ui.EditMemory(backend, store)
+	if uiErr != nil || !used {
+		if uiErr != nil {
+			fmt.Fprintf(os.Stderr, "ew: memory editor failed (%v)\n", uiErr)
+		}
+		payload := response{Intent: string(router.IntentMemoryEdit), Message: "memory editor is only available with the bubbletea ui backend"}
+		printResponse(payload, false)
+		return
+	}
+	if !saved {
+		fmt.Println("Memory unchanged.")
+		return
+	}
+	edited.Describe("edit memory")
+	if err := memory.Save(edited); err != nil {
+		payload := response{Intent: string(router.IntentMemoryEdit), Message: fmt.Sprintf("memory save failed: %v", err)}
+		printResponse(payload, false)
+		return
+	}
+	fmt.Printf("Memory saved (%d entries).\n", len(edited.Entries))
+}
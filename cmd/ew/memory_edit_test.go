@@ -0,0 +1,16 @@
+package main
+
+import "testing"
+
+func TestMemoryManagerPromptsOpenTheManager(t *testing.T) {
+	for _, prompt := range []string{"memory", "memory edit", "Manage  memory", "memories."} {
+		if !isMemoryManagerPrompt(prompt) {
+			t.Fatalf("expected %q to open the memory manager", prompt)
+		}
+	}
+	for _, prompt := range []string{"memory usage of nginx", "show memory", "free memory", "memory edit distance"} {
+		if isMemoryManagerPrompt(prompt) {
+			t.Fatalf("expected %q to stay a request", prompt)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ashwch/ew/internal/cheats"
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/i18n"
+	"github.com/ashwch/ew/internal/router"
+	ewrt "github.com/ashwch/ew/internal/runtime"
+	"github.com/ashwch/ew/internal/session"
+)
+
+// Values of find.offer_run.
+const (
+	offerRunAuto   = "auto"
+	offerRunAlways = "always"
+	offerRunNever  = "never"
+)
+
+// askOfferRun is swapped out in tests.
+var askOfferRun = promptOfferRun
+
+// imperativeRunQuery reports whether query reads as an order to act now
+// ("restart nginx", "nginx रीस्टार्ट करो") rather than a request for a
+// command. The verbs come from the locale catalog; questions never count.
+func imperativeRunQuery(query string, catalog i18n.Catalog) bool {
+	low := strings.ToLower(strings.Join(strings.Fields(query), " "))
+	if low == "" || isQuestionLikePrompt(low, catalog.Self.Question) {
+		return false
+	}
+	for _, verb := range catalog.Intent.Run {
+		verb = strings.ToLower(strings.TrimSpace(verb))
+		if verb == "" {
+			continue
+		}
+		if low == verb || strings.HasPrefix(low, verb+" ") || strings.HasSuffix(low, " "+verb) {
+			return true
+		}
+	}
+	return false
+}
+
+// offerRunAfterFind asks "Run it now?" once find has printed a single
+// suggestion, so an imperative query does not have to be retyped with
+// --execute.
+func offerRunAfterFind(query string, cfg config.Config, opts options) {
+	switch cfg.Find.OfferRun {
+	case offerRunNever:
+		return
+	case offerRunAlways:
+	default:
+		if !imperativeRunQuery(query, localeCatalog) {
+			return
+		}
+	}
+	if outcome, ran := offerToRun(cfg, opts, router.IntentRun); ran {
+		persistExecutionMemory(query, outcome)
+	}
+}
+
+// offerToRun asks "Run it now?" about the suggestion just printed. A yes
+// goes through the same execution path as --execute and counts as the
+// confirmation, except for commands that would get a plan preview or a
+// high-risk confirm there, which still get it.
+func offerToRun(cfg config.Config, opts options, intent router.Intent) (executionOutcome, bool) {
+	if opts.JSON || opts.Quiet || runtimeInteraction == nil || runtimeInteraction.Decision != session.DecisionSuggested {
+		return executionOutcome{}, false
+	}
+	command := strings.TrimSpace(runtimeInteraction.Command)
+	if command == "" || len(cheats.Placeholders(command)) > 0 || !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		return executionOutcome{}, false
+	}
+	mode := cfg.Mode
+	if strings.TrimSpace(opts.Mode) != "" {
+		mode = strings.TrimSpace(opts.Mode)
+	}
+	if strings.EqualFold(strings.TrimSpace(mode), "suggest") {
+		return executionOutcome{}, false
+	}
+
+	run := askOfferRun()
+	exitIfInterrupted()
+	if !run {
+		return executionOutcome{}, false
+	}
+	if offerAnswerConfirms(cfg, mode, command) {
+		opts.Yes = true
+	}
+	runtimeInteraction.Intent = string(intent)
+	return executeSuggested(command, "", runtimeInteraction.Reason, "", cfg, opts, intent), true
+}
+
+func offerAnswerConfirms(cfg config.Config, mode string, command string) bool {
+	if isRemoteExecutionTarget(cfg) || exceedsAutoExecutionLimits(cfg, command) {
+		return false
+	}
+	if _, _, ok := ewrt.PlanPreview(command); ok {
+		return false
+	}
+	_, risk := applyExecutionRiskPolicy(cfg, mode, command, "")
+	return risk.Level != "high"
+}
+
+func promptOfferRun() bool {
+	fmt.Print("Run it now? [y/N]: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/i18n"
+)
+
+func TestImperativeRunQueryAcrossLocales(t *testing.T) {
+	english := i18n.LoadCatalog("en")
+	hindi := i18n.LoadCatalog("hi")
+	for _, tc := range []struct {
+		catalog i18n.Catalog
+		query   string
+		want    bool
+	}{
+		{english, "restart nginx", true},
+		{english, "Stop all docker containers", true},
+		{english, "how do I restart nginx", false},
+		{english, "find files changed today", false},
+		{english, "command to clear aws vault", false},
+		{hindi, "nginx रीस्टार्ट करो", true},
+		{hindi, "restart nginx", true},
+		{hindi, "nginx कैसे रीस्टार्ट करो", false},
+	} {
+		if got := imperativeRunQuery(tc.query, tc.catalog); got != tc.want {
+			t.Fatalf("imperativeRunQuery(%q, %s) = %v, want %v", tc.query, tc.catalog.Locale, got, tc.want)
+		}
+	}
+}
+
+func TestOfferAnswerConfirmsOnlyOrdinaryLocalCommands(t *testing.T) {
+	cfg := config.Default()
+	if !offerAnswerConfirms(cfg, "confirm", "sudo systemctl restart nginx") {
+		t.Fatalf("expected the answer to confirm an ordinary restart")
+	}
+	if offerAnswerConfirms(cfg, "confirm", "rm -rf build") {
+		t.Fatalf("expected a destructive command to keep its own confirmation")
+	}
+	if offerAnswerConfirms(cfg, "confirm", "terraform apply") {
+		t.Fatalf("expected a plan-previewed command to keep its own confirmation")
+	}
+	cfg.Execution.Target = "ssh:deploy@web1"
+	if offerAnswerConfirms(cfg, "confirm", "sudo systemctl restart nginx") {
+		t.Fatalf("expected remote targets to keep their own confirmation")
+	}
+}
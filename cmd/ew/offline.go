@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/provider"
+)
+
+// capability is something ew can only do with a provider.
+type capability string
+
+const (
+	capabilityProviderFallback capability = "provider fallback"
+	capabilityAIRerank         capability = "AI rerank"
+	capabilityAIFix            capability = "AI fix"
+	capabilityAIExplain        capability = "AI explain"
+	capabilityAIUndo           capability = "AI undo"
+	capabilityAIMemoryNames    capability = "AI memory names"
+)
+
+// offlineFallbacks is the degradation matrix: what find, run, and fix do
+// instead when a capability is unavailable. Every handler consults it
+// through providerAvailability, so --offline and a machine with no working
+// provider behave the same way.
+var offlineFallbacks = map[capability]string{
+	capabilityProviderFallback: "using memory, history, cheat files, and tldr pages only",
+	capabilityAIRerank:         "history matches keep their local ranking",
+	capabilityAIFix:            "built-in fix rules only",
+	capabilityAIExplain:        "see man <tool> or tldr <tool> instead",
+	capabilityAIUndo:           "only commands a built-in rule can reverse are undone",
+	capabilityAIMemoryNames:    "memory bootstrap names commands after their own words",
+}
+
+const (
+	unavailableOffline    = "offline"
+	unavailableNoProvider = "no healthy providers (see ew --doctor)"
+)
+
+// healthyProviders is swapped out in tests.
+var healthyProviders = func(cfg config.Config, preferred string) []string {
+	return provider.NewService(provider.NewRegistry()).Healthy(cfg, preferred)
+}
+
+// skippedCapabilities collects this invocation's notes for the JSON
+// "degraded" field.
+var skippedCapabilities []string
+
+// availability says whether provider-backed capabilities can run; reason is
+// empty when they can.
+type availability struct {
+	reason string
+}
+
+func providerAvailability(cfg config.Config, opts options) availability {
+	if opts.Offline {
+		return availability{reason: unavailableOffline}
+	}
+	if len(healthyProviders(cfg, opts.Provider)) == 0 {
+		return availability{reason: unavailableNoProvider}
+	}
+	return availability{}
+}
+
+// allows reports whether c can run. When it cannot, the skip is announced
+// once on stderr, or recorded for the JSON payload.
+func (a availability) allows(c capability, opts options) bool {
+	if a.reason == "" {
+		return true
+	}
+	note := fmt.Sprintf("%s skipped: %s", c, a.reason)
+	for _, seen := range skippedCapabilities {
+		if seen == note {
+			return false
+		}
+	}
+	skippedCapabilities = append(skippedCapabilities, note)
+	if !opts.JSON && !opts.Quiet {
+		fmt.Fprintf(os.Stderr, "ew: %s; %s\n", note, offlineFallbacks[c])
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ashwch/ew/internal/config"
+)
+
+func resetSkippedCapabilities(t *testing.T) {
+	t.Helper()
+	skippedCapabilities = nil
+	t.Cleanup(func() { skippedCapabilities = nil })
+}
+
+func TestProviderAvailabilityTreatsNoHealthyProviderLikeOffline(t *testing.T) {
+	resetSkippedCapabilities(t)
+	original := healthyProviders
+	t.Cleanup(func() { healthyProviders = original })
+
+	healthyProviders = func(config.Config, string) []string { return []string{"ew"} }
+	if !providerAvailability(config.Default(), options{JSON: true}).allows(capabilityAIRerank, options{JSON: true}) {
+		t.Fatalf("expected rerank with a healthy provider")
+	}
+
+	healthyProviders = func(config.Config, string) []string { return nil }
+	if got := providerAvailability(config.Default(), options{}); got.reason != unavailableNoProvider {
+		t.Fatalf("expected no-provider reason, got %q", got.reason)
+	}
+	if got := providerAvailability(config.Default(), options{Offline: true}); got.reason != unavailableOffline {
+		t.Fatalf("expected --offline to win, got %q", got.reason)
+	}
+}
+
+func TestSkippedCapabilitiesReachJSONOnce(t *testing.T) {
+	resetSkippedCapabilities(t)
+	opts := options{JSON: true, Offline: true}
+	available := providerAvailability(config.Default(), opts)
+	for i := 0; i < 2; i++ {
+		if available.allows(capabilityProviderFallback, opts) {
+			t.Fatalf("expected provider fallback to be skipped offline")
+		}
+	}
+	available.allows(capabilityAIRerank, opts)
+
+	out := captureStdout(t, func() {
+		printResponse(response{Intent: "find", Message: "no safe matching history entries found"}, true)
+	})
+	var payload response
+	if err := json.Unmarshal([]byte(out), &payload); err != nil {
+		t.Fatalf("invalid JSON %q: %v", out, err)
+	}
+	want := []string{"provider fallback skipped: offline", "AI rerank skipped: offline"}
+	if len(payload.Degraded) != len(want) || payload.Degraded[0] != want[0] || payload.Degraded[1] != want[1] {
+		t.Fatalf("expected %v, got %v", want, payload.Degraded)
+	}
+}
+
+func TestEveryCapabilityHasAFallback(t *testing.T) {
+	for _, c := range []capability{capabilityProviderFallback, capabilityAIRerank, capabilityAIFix} {
+		if offlineFallbacks[c] == "" {
+			t.Fatalf("capability %q has no offline fallback", c)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/ashwch/ew/internal/config"
+	ewrt "github.com/ashwch/ew/internal/runtime"
+)
+
+// checkPackageManager is swapped out in tests.
+var checkPackageManager = ewrt.CheckPackageManager
+
+// preferProjectPackageManager rewrites a dependency command to the package
+// manager the project's lockfile uses, e.g. npm install → pnpm add, and
+// returns the original as the alternative.
+func preferProjectPackageManager(command string) (string, string) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return command, ""
+	}
+	check := checkPackageManager(command, cwd)
+	if check.Rewritten == "" {
+		return command, ""
+	}
+	return check.Rewritten, command
+}
+
+// packageManagerWarning explains a package-manager mismatch that could not
+// be rewritten, or that the person picked anyway.
+func packageManagerWarning(cfg config.Config, command string) string {
+	if isRemoteExecutionTarget(cfg) {
+		return ""
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	return checkPackageManager(command, cwd).Warning
+}
+
+func joinWarnings(warnings ...string) string {
+	kept := []string{}
+	for _, warning := range warnings {
+		if strings.TrimSpace(warning) != "" {
+			kept = append(kept, warning)
+		}
+	}
+	return strings.Join(kept, "; ")
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+
+	ewrt "github.com/ashwch/ew/internal/runtime"
+)
+
+func TestPreferSaferSuggestionUsesProjectPackageManager(t *testing.T) {
+	previous := checkPackageManager
+	checkPackageManager = func(command, _ string) ewrt.PackageManagerCheck {
+		if command == "npm install zod" {
+			return ewrt.PackageManagerCheck{Rewritten: "pnpm add zod", Warning: "this project uses pnpm (pnpm-lock.yaml); npm would create a second lockfile"}
+		}
+		return ewrt.PackageManagerCheck{}
+	}
+	t.Cleanup(func() { checkPackageManager = previous })
+
+	command, alternative := preferSaferSuggestion("npm install zod", false)
+	if command != "pnpm add zod" || alternative != "npm install zod" {
+		t.Fatalf("expected pnpm rewrite with npm alternative, got %q / %q", command, alternative)
+	}
+	if command, alternative := preferSaferSuggestion("ls -la", false); command != "ls -la" || alternative != "" {
+		t.Fatalf("expected unrelated command untouched, got %q / %q", command, alternative)
+	}
+}
+
+func TestJoinWarningsSkipsEmpty(t *testing.T) {
+	if got := joinWarnings("", "you are on main", " ", "pip would bypass uv.lock"); got != "you are on main; pip would bypass uv.lock" {
+		t.Fatalf("unexpected joined warnings %q", got)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"slices"
+	"strings"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/router"
+	ewrt "github.com/ashwch/ew/internal/runtime"
+)
+
+// passthroughReason labels a command the person typed themselves.
+const passthroughReason = "your command, run with ew's safety policy"
+
+// parsePassthrough recognises `ew [flags] run [flags] -- <cmd>` once the
+// leading flags are parsed, parsing the flags between run and --, and
+// returns the words after --. It returns nil when the words are an
+// ordinary prompt, so `ew run the tests` still means find.
+func parsePassthrough(fs *flag.FlagSet) ([]string, error) {
+	rest := fs.Args()
+	if len(rest) < 2 || rest[0] != "run" || !slices.Contains(rest[1:], "--") {
+		return nil, nil
+	}
+	tail := rest[1:]
+	if err := fs.Parse(tail); err != nil {
+		return nil, err
+	}
+	consumed := len(tail) - len(fs.Args())
+	if consumed == 0 || tail[consumed-1] != "--" {
+		// Something other than a flag sits between run and --.
+		return nil, nil
+	}
+	return append([]string{}, fs.Args()...), nil
+}
+
+// passthroughCommand turns the words after -- back into a command line. A
+// single word is taken as written, so `ew run -- 'ls | wc -l'` keeps its
+// pipe; several are quoted one by one, as the shell already split them.
+func passthroughCommand(args []string) string {
+	if len(args) == 1 {
+		return strings.TrimSpace(args[0])
+	}
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		quoted = append(quoted, ewrt.ShellQuote(arg))
+	}
+	return strings.Join(quoted, " ")
+}
+
+// handlePassthrough runs a command the person already knows through the
+// same risk policy, confirmation, and journal as a suggestion, without
+// searching history or asking a provider. Unlike a suggestion it is never
+// rewritten: no trash tool for rm, no project package manager for npm.
+func handlePassthrough(command string, cfg config.Config, opts options) {
+	executeSuggested(command, "", passthroughReason, "", cfg, opts, router.IntentRun)
+}
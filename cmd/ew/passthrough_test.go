@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ashwch/ew/internal/config"
+	ewrt "github.com/ashwch/ew/internal/runtime"
+)
+
+func TestParseArgsPassthrough(t *testing.T) {
+	opts, prompt, err := parseArgs([]string{"--json", "run", "--yes", "--", "git", "commit", "-m", "fix the build"})
+	if err != nil {
+		t.Fatalf("parseArgs returned error: %v", err)
+	}
+	if !opts.Passthrough || !opts.Execute || !opts.Yes || !opts.JSON {
+		t.Fatalf("expected passthrough with flags on both sides of run, got %+v", opts)
+	}
+	if prompt != "git commit -m 'fix the build'" {
+		t.Fatalf("unexpected command %q", prompt)
+	}
+
+	_, prompt, err = parseArgs([]string{"run", "--", "ls | wc -l"})
+	if err != nil || prompt != "ls | wc -l" {
+		t.Fatalf("expected a single word to be taken as written, got %q (%v)", prompt, err)
+	}
+
+	opts, prompt, err = parseArgs([]string{"run", "the", "tests"})
+	if err != nil || opts.Passthrough || prompt != "run the tests" {
+		t.Fatalf("expected a plain prompt, got %+v %q (%v)", opts, prompt, err)
+	}
+
+	if _, _, err := parseArgs([]string{"run", "--"}); err == nil {
+		t.Fatalf("expected ew run -- without a command to be rejected")
+	}
+}
+
+func TestHandlePassthroughAppliesPolicy(t *testing.T) {
+	cfg := config.Default()
+	output := captureStdout(t, func() {
+		handlePassthrough("rm -rf build", cfg, options{JSON: true, DryRun: true, Execute: true, Passthrough: true})
+	})
+	var payload response
+	if err := json.Unmarshal([]byte(output), &payload); err != nil {
+		t.Fatalf("invalid JSON %q: %v", output, err)
+	}
+	if payload.Executed || payload.Risk != "high" || payload.Message != passthroughReason {
+		t.Fatalf("expected a dry run with the command rated high risk, got %+v", payload)
+	}
+}
+
+func TestHandlePassthroughRunsTheCommandAsWritten(t *testing.T) {
+	previousCheck, previousTools := checkPackageManager, runtimeSystemTools
+	checkPackageManager = func(command, _ string) ewrt.PackageManagerCheck {
+		if command == "npm install zod" {
+			return ewrt.PackageManagerCheck{Rewritten: "pnpm add zod"}
+		}
+		return ewrt.PackageManagerCheck{}
+	}
+	runtimeSystemTools = []string{"trash"}
+	t.Cleanup(func() { checkPackageManager, runtimeSystemTools = previousCheck, previousTools })
+
+	for _, command := range []string{"rm -rf build", "npm install zod"} {
+		output := captureStdout(t, func() {
+			handlePassthrough(command, config.Default(), options{JSON: true, DryRun: true, Execute: true, Passthrough: true})
+		})
+		var payload response
+		if err := json.Unmarshal([]byte(output), &payload); err != nil {
+			t.Fatalf("invalid JSON %q: %v", output, err)
+		}
+		if payload.Command != command || payload.Message != passthroughReason {
+			t.Fatalf("expected %q to run as written, got %+v", command, payload)
+		}
+	}
+	if rewritten, _ := preferSaferSuggestion("rm -rf build", false); rewritten == "rm -rf build" {
+		t.Fatalf("expected suggestions to still get the trash rewrite")
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ashwch/ew/internal/analysis"
+	"github.com/ashwch/ew/internal/config"
+	ewrt "github.com/ashwch/ew/internal/runtime"
+)
+
+const planPreviewTimeout = 2 * time.Minute
+
+// capturePlanOutput is swapped out in tests.
+var capturePlanOutput = ewrt.CaptureCommandOn
+
+// askPlanPreview is swapped out in tests.
+var askPlanPreview = promptPlanPreview
+
+// previewApplyPlan runs the read-only plan or diff for a terraform or kubectl
+// apply that is about to be reviewed, and summarizes it. It returns nil when
+// command is not an apply, the preview is disabled, or the person declines.
+// interactive is false for --json and --dry-run, where nobody can be asked:
+// there the preview, which still reaches real clusters and state, only runs
+// with plan_preview=always, and the summary goes into the payload.
+func previewApplyPlan(cfg config.Config, backend ewrt.Backend, command string, interactive bool) *ewrt.PlanSummary {
+	preview, tool, ok := ewrt.PlanPreview(command)
+	if !ok || cfg.Safety.PlanPreview == "never" {
+		return nil
+	}
+	if !interactive && cfg.Safety.PlanPreview != "always" {
+		return nil
+	}
+	if interactive && cfg.Safety.PlanPreview == "ask" && !askPlanPreview(preview) {
+		return nil
+	}
+	if interactive {
+		fmt.Fprintf(os.Stderr, "ew: running %s\n", preview)
+	}
+	okCodes := []int{}
+	if tool == ewrt.PlanToolKubectl {
+		okCodes = append(okCodes, 1)
+	}
+	output, err := capturePlanOutput(invocationCtx, backend, preview, planPreviewTimeout, okCodes...)
+	exitIfInterrupted()
+	summary := ewrt.SummarizePlan(tool, preview, output)
+	if err != nil {
+		summary.Error = strings.TrimSpace(err.Error())
+	}
+	return &summary
+}
+
+func promptPlanPreview(preview string) bool {
+	if !isTerminal(os.Stdin) {
+		return false
+	}
+	fmt.Printf("Preview with `%s` first? [Y/n]: ", preview)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "" || answer == "y" || answer == "yes"
+}
+
+func planSummaryLines(summary *ewrt.PlanSummary) []string {
+	if summary == nil {
+		return nil
+	}
+	return summary.Lines()
+}
+
+// confirmDetailLines is what a confirmation shows below the command: where
+// a multi-step plan stands, --preview's side effects, and the apply plan.
+func confirmDetailLines(opts options, plan *ewrt.PlanSummary, effects *analysis.Effects) []string {
+	lines := planStepLines(opts)
+	if effects != nil {
+		lines = append(lines, effects.Lines()...)
+	}
+	return append(lines, planSummaryLines(plan)...)
+}
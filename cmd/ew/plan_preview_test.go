@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/router"
+	ewrt "github.com/ashwch/ew/internal/runtime"
+)
+
+func stubPlanPreview(t *testing.T, output string, err error, answer bool) *[]string {
+	t.Helper()
+	ran := []string{}
+	previousCapture, previousAsk := capturePlanOutput, askPlanPreview
+	capturePlanOutput = func(_ context.Context, _ ewrt.Backend, command string, _ time.Duration, _ ...int) (string, error) {
+		ran = append(ran, command)
+		return output, err
+	}
+	askPlanPreview = func(string) bool { return answer }
+	t.Cleanup(func() { capturePlanOutput, askPlanPreview = previousCapture, previousAsk })
+	return &ran
+}
+
+func TestPreviewApplyPlanSummarizesTerraformPlan(t *testing.T) {
+	ran := stubPlanPreview(t, "  # aws_instance.web will be destroyed\nPlan: 0 to add, 0 to change, 1 to destroy.\n", nil, true)
+	plan := previewApplyPlan(config.Default(), nil, "terraform apply -auto-approve", true)
+	if plan == nil || plan.Destroy != 1 || len(plan.Destroys) != 1 {
+		t.Fatalf("expected one destroy, got %+v", plan)
+	}
+	if len(*ran) != 1 || (*ran)[0] != "terraform plan -no-color -input=false" {
+		t.Fatalf("unexpected preview commands: %#v", *ran)
+	}
+}
+
+func TestPreviewApplyPlanRespectsSetting(t *testing.T) {
+	ran := stubPlanPreview(t, "", nil, false)
+	cfg := config.Default()
+	if plan := previewApplyPlan(cfg, nil, "kubectl apply -f .", true); plan != nil {
+		t.Fatalf("expected declined preview to be skipped, got %+v", plan)
+	}
+	if plan := previewApplyPlan(cfg, nil, "kubectl apply -f .", false); plan != nil {
+		t.Fatalf("expected --json and --dry-run not to run the preview under ask, got %+v", plan)
+	}
+	if err := cfg.Set("safety.plan_preview", "always"); err != nil {
+		t.Fatalf("set plan_preview: %v", err)
+	}
+	if plan := previewApplyPlan(cfg, nil, "kubectl apply -f .", false); plan == nil || !plan.NoChanges {
+		t.Fatalf("expected always to run the non-interactive preview, got %+v", plan)
+	}
+	if err := cfg.Set("safety.plan_preview", "never"); err != nil {
+		t.Fatalf("set plan_preview: %v", err)
+	}
+	if plan := previewApplyPlan(cfg, nil, "kubectl apply -f .", false); plan != nil {
+		t.Fatalf("expected never to skip the preview, got %+v", plan)
+	}
+	if plan := previewApplyPlan(config.Default(), nil, "kubectl get pods", false); plan != nil {
+		t.Fatalf("expected no preview for non-apply command, got %+v", plan)
+	}
+	if len(*ran) != 1 {
+		t.Fatalf("expected exactly one preview run, got %#v", *ran)
+	}
+}
+
+func TestPreviewApplyPlanRecordsFailures(t *testing.T) {
+	stubPlanPreview(t, "", errors.New("exit status 1"), true)
+	plan := previewApplyPlan(config.Default(), nil, "terraform apply", true)
+	if plan == nil || plan.Error != "exit status 1" {
+		t.Fatalf("expected failure to be recorded, got %+v", plan)
+	}
+}
+
+func TestPreviewShowsSideEffectsAndAsksEvenInYolo(t *testing.T) {
+	t.Chdir(t.TempDir())
+	cfg := config.Default()
+	cfg.Mode = "yolo"
+
+	out := captureStdout(t, func() {
+		executeSuggested("touch notes.txt && curl -o page.html https://example.com/", "", "test", "low", cfg, options{JSON: true, Preview: true}, router.IntentRun)
+	})
+	var payload response
+	if err := json.Unmarshal([]byte(out), &payload); err != nil {
+		t.Fatalf("expected json output, got %q: %v", out, err)
+	}
+	if payload.Executed || !strings.Contains(payload.Message, "confirmation required") {
+		t.Fatalf("expected --preview to require confirmation in yolo mode, got %+v", payload)
+	}
+	if payload.Effects == nil || strings.Join(payload.Effects.Writes, ",") != "notes.txt,page.html" || strings.Join(payload.Effects.Network, ",") != "example.com" {
+		t.Fatalf("expected the writes and the network destination, got %+v", payload.Effects)
+	}
+	if _, err := os.Stat("notes.txt"); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing to run, got %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ashwch/ew/internal/config"
+	ewrt "github.com/ashwch/ew/internal/runtime"
+)
+
+// gitBranchContext is swapped out in tests.
+var gitBranchContext = ewrt.GitBranchContext
+
+// protectedBranchWarning returns "you are on <branch>" when command pushes,
+// resets or rebases while a protected branch is checked out locally.
+func protectedBranchWarning(cfg config.Config, command string) string {
+	if isRemoteExecutionTarget(cfg) || !ewrt.RewritesGitBranch(command) {
+		return ""
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	branch, defaultBranch := gitBranchContext(cwd)
+	if !ewrt.BranchProtected(branch, defaultBranch, cfg.Safety.ProtectedBranches) {
+		return ""
+	}
+	return fmt.Sprintf("you are on %s", branch)
+}
+
+// applyProtectedBranchPolicy raises risk to high when warning is set, which
+// sends yolo back to a confirmation unless allow_yolo_high_risk is on.
+func applyProtectedBranchPolicy(cfg config.Config, mode string, risk string, warning string) (string, string) {
+	if warning == "" {
+		return mode, risk
+	}
+	if mode == "yolo" && !cfg.Safety.AllowYoloHighRisk {
+		mode = "confirm"
+	}
+	return mode, "high"
+}
+
+func riskLabelWithWarning(label string, warning string) string {
+	if warning == "" {
+		return label
+	}
+	return fmt.Sprintf("%s - warning: %s", label, warning)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ashwch/ew/internal/config"
+)
+
+func stubGitBranch(t *testing.T, branch, defaultBranch string) {
+	t.Helper()
+	previous := gitBranchContext
+	gitBranchContext = func(string) (string, string) { return branch, defaultBranch }
+	t.Cleanup(func() { gitBranchContext = previous })
+}
+
+func TestProtectedBranchWarningOnMain(t *testing.T) {
+	stubGitBranch(t, "main", "main")
+	cfg := config.Default()
+	if got := protectedBranchWarning(cfg, "git push --force"); got != "you are on main" {
+		t.Fatalf("expected main warning, got %q", got)
+	}
+	if got := protectedBranchWarning(cfg, "git status"); got != "" {
+		t.Fatalf("expected no warning for read-only git, got %q", got)
+	}
+	cfg.Execution.Target = "ssh:deploy@prod"
+	if got := protectedBranchWarning(cfg, "git push"); got != "" {
+		t.Fatalf("expected no local branch warning for remote target, got %q", got)
+	}
+}
+
+func TestProtectedBranchWarningUsesDefaultBranchAndConfig(t *testing.T) {
+	stubGitBranch(t, "trunk", "trunk")
+	cfg := config.Default()
+	if err := cfg.Set("safety.protected_branches", "none"); err != nil {
+		t.Fatalf("set protected branches: %v", err)
+	}
+	if got := protectedBranchWarning(cfg, "git rebase origin/trunk"); got != "you are on trunk" {
+		t.Fatalf("expected default branch warning, got %q", got)
+	}
+
+	stubGitBranch(t, "feature/x", "main")
+	if got := protectedBranchWarning(config.Default(), "git reset --hard"); got != "" {
+		t.Fatalf("expected no warning on feature branch, got %q", got)
+	}
+}
+
+func TestApplyProtectedBranchPolicyElevatesRisk(t *testing.T) {
+	cfg := config.Default()
+	mode, risk := applyProtectedBranchPolicy(cfg, "yolo", "medium", "you are on main")
+	if mode != "confirm" || risk != "high" {
+		t.Fatalf("expected confirm/high, got %s/%s", mode, risk)
+	}
+	mode, risk = applyProtectedBranchPolicy(cfg, "yolo", "low", "")
+	if mode != "yolo" || risk != "low" {
+		t.Fatalf("expected policy to be unchanged without warning, got %s/%s", mode, risk)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/provider"
+	"github.com/ashwch/ew/internal/state"
+)
+
+// providerEnvWarnedFile names the command providers ew has already warned
+// about running with only the base environment.
+const providerEnvWarnedFile = "provider_env_warned.json"
+
+var providerEnvChecked sync.Once
+
+// warnFilteredProviderEnv says, once per provider and then never again,
+// that a command provider without an env list loses every variable outside
+// the base set, so a custom CLI that needs its own credentials is not left
+// failing without a hint. Providers with an env list chose what they get.
+func warnFilteredProviderEnv(cfg config.Config, opts options) {
+	providerEnvChecked.Do(func() {
+		if opts.JSON || opts.Quiet {
+			return
+		}
+		backend, err := state.Current()
+		if err != nil {
+			return
+		}
+		warned := map[string]bool{}
+		if payload, err := backend.Read(providerEnvWarnedFile); err == nil && len(payload) > 0 {
+			_ = json.Unmarshal(payload, &warned)
+		}
+		names := make([]string, 0, len(cfg.Providers))
+		for name := range cfg.Providers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		changed := false
+		for _, name := range names {
+			providerCfg := cfg.Providers[name]
+			if warned[name] || !filtersProviderEnv(providerCfg) {
+				continue
+			}
+			withheld := provider.WithheldEnv(providerCfg, os.Environ())
+			if len(withheld) == 0 {
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "ew: provider %s runs with only the base environment (%d variables withheld); list what it needs in providers.%s.env, or \"*\" to pass everything\n", name, len(withheld), name)
+			warned[name] = true
+			changed = true
+		}
+		if !changed {
+			return
+		}
+		if payload, err := json.Marshal(warned); err == nil {
+			_ = backend.Write(providerEnvWarnedFile, payload)
+		}
+	})
+}
+
+// filtersProviderEnv reports whether cfg is an enabled command provider
+// with no env list of its own.
+func filtersProviderEnv(cfg config.ProviderConfig) bool {
+	if cfg.Enabled != nil && !*cfg.Enabled {
+		return false
+	}
+	if cfg.Type != "" && cfg.Type != "command" {
+		return false
+	}
+	return len(cfg.Env) == 0
+}
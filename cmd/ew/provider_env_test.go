@@ -0,0 +1,50 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ashwch/ew/internal/config"
+)
+
+func TestWarnFilteredProviderEnvWarnsOncePerProvider(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, ".local", "state"))
+	t.Setenv("EW_TEST_CUSTOM_KEY", "value")
+	t.Cleanup(func() { providerEnvChecked = sync.Once{} })
+
+	cfg := config.Default()
+	cfg.Providers["custom"] = config.ProviderConfig{Type: "command", Command: "custom-ai"}
+	cfg.Providers["scoped"] = config.ProviderConfig{Type: "command", Command: "scoped-ai", Env: []string{"EW_TEST_*"}}
+
+	warn := func() string {
+		providerEnvChecked = sync.Once{}
+		old := os.Stderr
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatalf("pipe create failed: %v", err)
+		}
+		os.Stderr = w
+		warnFilteredProviderEnv(cfg, options{})
+		os.Stderr = old
+		_ = w.Close()
+		out, _ := io.ReadAll(r)
+		return string(out)
+	}
+
+	out := warn()
+	if !strings.Contains(out, "provider custom runs with only the base environment") || !strings.Contains(out, "providers.custom.env") {
+		t.Fatalf("expected a warning for the provider without an env list, got %q", out)
+	}
+	if strings.Contains(out, "scoped") || strings.Contains(out, "claude") || strings.Contains(out, "codex") {
+		t.Fatalf("expected providers with an env list left alone, got %q", out)
+	}
+	if out := warn(); out != "" {
+		t.Fatalf("expected the warning only once, got %q", out)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/router"
+	ewrt "github.com/ashwch/ew/internal/runtime"
+)
+
+// quickPlaceholder matches {1}, {2}, ... and {@} in a quick template.
+var quickPlaceholder = regexp.MustCompile(`\{(\d+|@)\}`)
+
+// parseQuickPrompt splits `/deploy staging prod` into the quick command's
+// name and its arguments.
+func parseQuickPrompt(prompt string) (string, []string, bool) {
+	fields := strings.Fields(prompt)
+	if len(fields) == 0 {
+		return "", nil, false
+	}
+	name, ok := strings.CutPrefix(fields[0], "/")
+	if !ok || (name != "" && !config.ValidQuickName(strings.ToLower(name))) {
+		return "", nil, false
+	}
+	return strings.ToLower(name), fields[1:], true
+}
+
+// maybeHandleQuickCommand expands `ew /<name> args...` from the [quick]
+// table and hands the command to the usual suggest or execute path, with
+// no provider or history lookup. A bare `ew /` lists the quick commands;
+// an unknown name falls through, so `ew /tmp is full` is still a query.
+func maybeHandleQuickCommand(prompt string, cfg config.Config, opts options) bool {
+	name, args, ok := parseQuickPrompt(prompt)
+	if !ok {
+		return false
+	}
+	if name == "" {
+		if len(args) > 0 {
+			return false
+		}
+		beginSessionInteraction(prompt, router.IntentQuick)
+		printResponse(quickCommandList(cfg.Quick), opts.JSON)
+		return true
+	}
+	quick, found := cfg.Quick[name]
+	if !found {
+		return false
+	}
+
+	beginSessionInteraction(prompt, router.IntentQuick)
+	command, err := expandQuickTemplate(quick.Template, args)
+	if err != nil {
+		printResponse(response{
+			Intent:      string(router.IntentQuick),
+			Message:     fmt.Sprintf("/%s %v", name, err),
+			Suggestions: []string{fmt.Sprintf("/%s runs: %s", name, quick.Template)},
+		}, opts.JSON)
+		return true
+	}
+	reason := strings.TrimSpace(quick.Description)
+	if reason == "" {
+		reason = "quick command /" + name
+	}
+	if opts.Execute {
+		executeSuggested(command, "", reason, "low", cfg, opts, router.IntentQuick)
+		return true
+	}
+	if opts.JSON {
+		_, risk := applyExecutionRiskPolicy(cfg, "suggest", command, "low")
+		noteSessionSuggestion(command, reason, "quick")
+		printResponse(response{
+			Intent:  string(router.IntentQuick),
+			Message: reason,
+			Command: command,
+			Risk:    risk.Level,
+		}, true)
+		return true
+	}
+	writeSuggestedCommandBlock(command, reason, "quick", "", cfg, opts)
+	if cfg.Find.OfferRun != offerRunNever {
+		offerToRun(cfg, opts, router.IntentQuick)
+	}
+	return true
+}
+
+// expandQuickTemplate fills template's placeholders with args, each quoted
+// for the shell. Every argument has to be used: a missing one or a spare
+// one without {@} to take it is an error rather than a guess.
+func expandQuickTemplate(template string, args []string) (string, error) {
+	highest, rest := 0, false
+	for _, m := range quickPlaceholder.FindAllStringSubmatch(template, -1) {
+		if m[1] == "@" {
+			rest = true
+			continue
+		}
+		n, _ := strconv.Atoi(m[1])
+		highest = max(highest, n)
+	}
+	switch {
+	case len(args) < highest:
+		return "", fmt.Errorf("needs %d argument%s, got %d", highest, plural(highest), len(args))
+	case len(args) > highest && !rest:
+		return "", fmt.Errorf("takes %d argument%s, got %d", highest, plural(highest), len(args))
+	}
+
+	var expandErr error
+	command := quickPlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		index := strings.Trim(placeholder, "{}")
+		if index == "@" {
+			quoted := make([]string, 0, len(args)-highest)
+			for _, arg := range args[highest:] {
+				quoted = append(quoted, ewrt.ShellQuote(arg))
+			}
+			return strings.Join(quoted, " ")
+		}
+		n, _ := strconv.Atoi(index)
+		if n < 1 {
+			expandErr = fmt.Errorf("has a template with an invalid placeholder %s", placeholder)
+			return placeholder
+		}
+		return ewrt.ShellQuote(args[n-1])
+	})
+	if expandErr != nil {
+		return "", expandErr
+	}
+	return strings.TrimSpace(command), nil
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
+
+func quickCommandList(quick map[string]config.QuickCommand) response {
+	payload := response{Intent: string(router.IntentQuick)}
+	if len(quick) == 0 {
+		payload.Message = "no quick commands yet; add one under [quick.<name>] in config.toml"
+		payload.Suggestions = []string{`[quick.deploy] template = "kubectl rollout restart deploy/{1} -n {2}"`}
+		return payload
+	}
+	names := make([]string, 0, len(quick))
+	for name := range quick {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	payload.Message = "quick commands:"
+	for _, name := range names {
+		line := fmt.Sprintf("/%s: %s", name, quick[name].Template)
+		if description := strings.TrimSpace(quick[name].Description); description != "" {
+			line += " (" + description + ")"
+		}
+		payload.Suggestions = append(payload.Suggestions, line)
+	}
+	return payload
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ashwch/ew/internal/config"
+)
+
+func TestExpandQuickTemplate(t *testing.T) {
+	cases := []struct {
+		template string
+		args     []string
+		want     string
+	}{
+		{"kubectl rollout restart deploy/{1} -n {2}", []string{"api", "staging"}, "kubectl rollout restart deploy/api -n staging"},
+		{"git commit -m {1}", []string{"it's done"}, `git commit -m 'it'\''s done'`},
+		{"rg {1} {@}", []string{"todo", "src", "docs"}, "rg todo src docs"},
+		{"make test {@}", nil, "make test"},
+		{"echo {1};", []string{"a;rm"}, "echo 'a;rm';"},
+	}
+	for _, tc := range cases {
+		got, err := expandQuickTemplate(tc.template, tc.args)
+		if err != nil || got != tc.want {
+			t.Fatalf("expandQuickTemplate(%q, %q) = %q, %v; want %q", tc.template, tc.args, got, err, tc.want)
+		}
+	}
+
+	if _, err := expandQuickTemplate("deploy {1} {2}", []string{"api"}); err == nil {
+		t.Fatalf("expected a missing argument to be an error")
+	}
+	if _, err := expandQuickTemplate("deploy {1}", []string{"api", "extra"}); err == nil {
+		t.Fatalf("expected a spare argument to be an error")
+	}
+}
+
+func TestParseQuickPrompt(t *testing.T) {
+	name, args, ok := parseQuickPrompt("/Deploy staging eu")
+	if !ok || name != "deploy" || len(args) != 2 || args[0] != "staging" {
+		t.Fatalf("unexpected parse %q %q %v", name, args, ok)
+	}
+	for _, prompt := range []string{"deploy staging", "/usr/local/bin is missing", "/tmp/x"} {
+		if name, _, ok := parseQuickPrompt(prompt); ok && name != "tmp" {
+			t.Fatalf("did not expect %q to parse as a quick command, got %q", prompt, name)
+		}
+	}
+}
+
+func TestQuickCommandSuggestsExpandedTemplate(t *testing.T) {
+	cfg := config.Default()
+	cfg.Quick = map[string]config.QuickCommand{
+		"deploy": {Template: "kubectl rollout restart deploy/{1} -n {2}", Description: "restart a deployment"},
+	}
+
+	if maybeHandleQuickCommand("/tmp is full", cfg, options{JSON: true}) {
+		t.Fatalf("expected an unknown quick command to fall through")
+	}
+
+	var handled bool
+	output := captureStdout(t, func() {
+		handled = maybeHandleQuickCommand("/deploy api staging", cfg, options{JSON: true})
+	})
+	var payload response
+	if !handled || json.Unmarshal([]byte(output), &payload) != nil {
+		t.Fatalf("expected a JSON answer, got %q", output)
+	}
+	if payload.Intent != "quick" || payload.Command != "kubectl rollout restart deploy/api -n staging" || payload.Message != "restart a deployment" {
+		t.Fatalf("unexpected payload %+v", payload)
+	}
+
+	payload = response{}
+	output = captureStdout(t, func() {
+		handled = maybeHandleQuickCommand("/deploy api", cfg, options{JSON: true})
+	})
+	if !handled || json.Unmarshal([]byte(output), &payload) != nil || payload.Command != "" || len(payload.Suggestions) != 1 {
+		t.Fatalf("expected a usage answer for a missing argument, got %q", output)
+	}
+}
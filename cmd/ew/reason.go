@@ -0,0 +1,126 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/ashwch/ew/internal/ui"
+)
+
+// compactReasonLimit is the shortest the one-line reason is cut to; narrow
+// terminals wrap it instead.
+const compactReasonLimit = 120
+const defaultReasonWidth = 100
+const reasonLabel = "reason: "
+
+// reasonSpaceHold stands in for spaces inside code spans while wrapping.
+const reasonSpaceHold = "\x1f"
+
+var (
+	reasonListItemRegex   = regexp.MustCompile(`^\s*(?:[-*+]|\d+[.)])\s+`)
+	reasonInlineCodeRegex = regexp.MustCompile("`([^`]+)`")
+	reasonBoldRegex       = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+)
+
+// reasonForDisplay keeps the one-line summary unless --verbose asks for the
+// provider's full explanation. Quiet output never carries a reason.
+func reasonForDisplay(reason string, opts options) string {
+	if opts.Verbose && !opts.Quiet {
+		return strings.TrimSpace(reason)
+	}
+	return compactReason(reason, compactReasonWidth(outputWidth()))
+}
+
+// compactReasonWidth lets the one-line summary use a wide terminal's full
+// line instead of cutting at compactReasonLimit.
+func compactReasonWidth(width int) int {
+	if available := width - len(reasonLabel); available > compactReasonLimit {
+		return available
+	}
+	return compactReasonLimit
+}
+
+// renderReasonLines lays a reason out under a "reason: " label with a
+// hanging indent. It understands a markdown-lite subset: list items keep
+// their own hanging indent, **bold** markers are dropped, and `code` spans
+// never wrap mid-span and are highlighted when styled is true.
+func renderReasonLines(reason string, width int, styled bool) []string {
+	reason = strings.TrimSpace(ui.ASCII(reason))
+	if reason == "" {
+		return nil
+	}
+	indent := strings.Repeat(" ", len(reasonLabel))
+	available := width - len(indent)
+	if available < minWrapWidth {
+		available = minWrapWidth
+	}
+
+	lines := []string{}
+	for _, paragraph := range strings.Split(reason, "\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" {
+			continue
+		}
+		paragraph = reasonBoldRegex.ReplaceAllString(paragraph, "$1")
+		firstPrefix, restPrefix := "", ""
+		if loc := reasonListItemRegex.FindStringIndex(paragraph); loc != nil {
+			paragraph = strings.TrimSpace(paragraph[loc[1]:])
+			firstPrefix, restPrefix = "- ", "  "
+		}
+		paragraph = reasonInlineCodeRegex.ReplaceAllStringFunc(paragraph, func(span string) string {
+			return strings.ReplaceAll(span, " ", reasonSpaceHold)
+		})
+		for i, line := range wrapReasonWords(paragraph, available-len(firstPrefix)) {
+			prefix := restPrefix
+			if i == 0 {
+				prefix = firstPrefix
+			}
+			line = styleReasonCode(line, styled)
+			lines = append(lines, prefix+strings.ReplaceAll(line, reasonSpaceHold, " "))
+		}
+	}
+
+	for i := range lines {
+		if i == 0 {
+			lines[i] = reasonLabel + lines[i]
+			continue
+		}
+		lines[i] = indent + lines[i]
+	}
+	return lines
+}
+
+func wrapReasonWords(text string, width int) []string {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return nil
+	}
+	lines := []string{}
+	current := words[0]
+	for _, word := range words[1:] {
+		if len([]rune(current))+1+len([]rune(word)) > width {
+			lines = append(lines, current)
+			current = word
+			continue
+		}
+		current += " " + word
+	}
+	return append(lines, current)
+}
+
+func styleReasonCode(line string, styled bool) string {
+	if !styled {
+		return line
+	}
+	return reasonInlineCodeRegex.ReplaceAllStringFunc(line, func(span string) string {
+		return ui.HighlightCode(strings.Trim(span, "`"))
+	})
+}
+
+func reasonStylingEnabled() bool {
+	if strings.TrimSpace(os.Getenv("NO_COLOR")) != "" || ui.ASCIIOnly() {
+		return false
+	}
+	return isTerminal(os.Stdout)
+}
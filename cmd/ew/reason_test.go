@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReasonForDisplayVerboseKeepsFullText(t *testing.T) {
+	long := strings.Repeat("word ", 40) + "end."
+	if got := reasonForDisplay(long, options{}); len(got) > compactReasonLimit+3 {
+		t.Fatalf("expected compact reason by default, got %d chars", len(got))
+	}
+	if got := reasonForDisplay(long, options{Verbose: true}); got != strings.TrimSpace(long) {
+		t.Fatalf("expected full reason with --verbose, got %q", got)
+	}
+	if got := reasonForDisplay(long, options{Verbose: true, Quiet: true}); got == strings.TrimSpace(long) {
+		t.Fatalf("expected quiet mode to keep the compact reason")
+	}
+}
+
+func TestRenderReasonLinesWrapsWithHangingIndent(t *testing.T) {
+	lines := renderReasonLines("the quick brown fox jumps over the lazy dog again and again", 30, false)
+	if len(lines) < 2 {
+		t.Fatalf("expected wrapped output, got %q", lines)
+	}
+	if !strings.HasPrefix(lines[0], "reason: ") {
+		t.Fatalf("expected label on first line, got %q", lines[0])
+	}
+	for _, line := range lines[1:] {
+		if !strings.HasPrefix(line, strings.Repeat(" ", len(reasonLabel))) {
+			t.Fatalf("expected hanging indent, got %q", line)
+		}
+	}
+	for _, line := range lines {
+		if len(line) > 30 {
+			t.Fatalf("expected lines within width, got %q (%d)", line, len(line))
+		}
+	}
+}
+
+func TestRenderReasonLinesHandlesListsAndInlineCode(t *testing.T) {
+	reason := "Two options:\n* run `git fetch --unshallow` first\n2. then **retry** the pull"
+	lines := renderReasonLines(reason, 120, false)
+	want := []string{
+		"reason: Two options:",
+		"        - run `git fetch --unshallow` first",
+		"        - then retry the pull",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected rendering:\n%s", strings.Join(lines, "\n"))
+	}
+}
+
+func TestRenderReasonLinesKeepsCodeSpansTogether(t *testing.T) {
+	lines := renderReasonLines("use `docker compose down --volumes` to reset", 30, false)
+	joined := strings.Join(lines, "\n")
+	if !strings.Contains(joined, "`docker compose down --volumes`") {
+		t.Fatalf("expected code span to stay on one line, got:\n%s", joined)
+	}
+}
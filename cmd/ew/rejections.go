@@ -0,0 +1,72 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ashwch/ew/internal/history"
+	"github.com/ashwch/ew/internal/memory"
+)
+
+const rejectedBeforeNote = "you rejected this before"
+
+// currentQuery is the prompt this invocation is answering; it is empty for a
+// bare `ew` fix.
+func currentQuery() string {
+	if runtimeInteraction == nil {
+		return ""
+	}
+	return runtimeInteraction.Query
+}
+
+// rememberRejection is best effort: failing to record a cancel must never
+// change what the user sees.
+func rememberRejection(query, command string) {
+	if strings.TrimSpace(command) == "" {
+		return
+	}
+	noteSessionRejection(command)
+	_ = memory.RecordRejection(query, command)
+}
+
+func loadRejections() memory.Rejections {
+	rejections, _, err := memory.LoadRejections()
+	if err != nil {
+		return memory.Rejections{}
+	}
+	return rejections
+}
+
+func rejectedBefore(query, command string) bool {
+	return loadRejections().Rejected(query, command, time.Now().UTC())
+}
+
+// withRejectionNote appends the "rejected before" hint to a reason.
+func withRejectionNote(reason string, rejected bool) string {
+	if !rejected {
+		return reason
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return rejectedBeforeNote
+	}
+	return reason + " (" + rejectedBeforeNote + ")"
+}
+
+// downrankRejectedHistory applies the same decayed penalty memory matches get
+// to history matches and re-sorts them.
+func downrankRejectedHistory(query string, matches []history.Match, rejections memory.Rejections, now time.Time) []history.Match {
+	if len(rejections.Entries) == 0 {
+		return matches
+	}
+	for idx := range matches {
+		if weight := rejections.Weight(query, matches[idx].Command, now); weight > 0 {
+			matches[idx].Score = matches[idx].Score / (1 + weight)
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+	return matches
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ashwch/ew/internal/history"
+	"github.com/ashwch/ew/internal/memory"
+)
+
+func TestDownrankRejectedHistoryReordersMatches(t *testing.T) {
+	now := time.Now().UTC()
+	var rejections memory.Rejections
+	_ = rejections.Reject("list pods", "kubectl get pods -A", now)
+	matches := downrankRejectedHistory("list pods", []history.Match{
+		{Command: "kubectl get pods -A", Score: 10},
+		{Command: "kubectl get pods", Score: 8},
+	}, rejections, now)
+	if matches[0].Command != "kubectl get pods" {
+		t.Fatalf("expected rejected history match to drop, got %+v", matches)
+	}
+}
+
+func TestWithRejectionNote(t *testing.T) {
+	if got := withRejectionNote("from history", true); got != "from history (you rejected this before)" {
+		t.Fatalf("unexpected note, got %q", got)
+	}
+	if got := withRejectionNote("from history", false); got != "from history" {
+		t.Fatalf("expected reason unchanged, got %q", got)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/history"
+)
+
+// aiRerankMode is find.ai_rerank, or off for --no-rerank.
+func aiRerankMode(cfg config.Config, opts options) string {
+	if opts.NoRerank {
+		return "off"
+	}
+	return cfg.Find.AIRerank
+}
+
+// rerankNote says how a provider's pick changed the history ranking: "AI
+// promoted #3 over #1" when it chose a lower match, or that it chose a
+// command not among them. It is empty when the pick is the top match.
+// offset is where this page of matches starts, so the numbers agree with
+// the match list.
+func rerankNote(command string, matches []history.Match, offset int) string {
+	if strings.TrimSpace(command) == "" || len(matches) == 0 {
+		return ""
+	}
+	want := normalizeComparableCommand(command)
+	for idx, match := range matches {
+		if normalizeComparableCommand(match.Command) != want {
+			continue
+		}
+		if idx == 0 {
+			return ""
+		}
+		return fmt.Sprintf("AI promoted #%d over #%d", offset+idx+1, offset+1)
+	}
+	return fmt.Sprintf("AI picked a command outside your history over #%d", offset+1)
+}
+
+// historyOrderHint is how to see the matches in their own order.
+func historyOrderHint(query string) string {
+	return "ew --no-rerank " + query
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/history"
+)
+
+func TestRerankNoteNamesTheDisplacedMatch(t *testing.T) {
+	matches := []history.Match{{Command: "git log"}, {Command: "git log --oneline"}, {Command: "git log --graph"}}
+	if got := rerankNote("git log --graph", matches, 0); got != "AI promoted #3 over #1" {
+		t.Fatalf("unexpected note %q", got)
+	}
+	if got := rerankNote("GIT LOG", matches, 0); got != "" {
+		t.Fatalf("expected no note when the top match stands, got %q", got)
+	}
+	if got := rerankNote("git log --oneline", matches, 10); got != "AI promoted #12 over #11" {
+		t.Fatalf("expected numbers to follow the page offset, got %q", got)
+	}
+	if got := rerankNote("tig", matches, 0); got != "AI picked a command outside your history over #1" {
+		t.Fatalf("unexpected note %q", got)
+	}
+}
+
+func TestNoRerankKeepsTheHistoryRanking(t *testing.T) {
+	cfg := config.Default()
+	cfg.Find.AIRerank = "always"
+	matches := []history.Match{{Command: "git log", Score: 30}}
+	if !shouldAIRerank(aiRerankMode(cfg, options{}), matches) {
+		t.Fatalf("expected find.ai_rerank = always to rerank")
+	}
+	if shouldAIRerank(aiRerankMode(cfg, options{NoRerank: true}), matches) {
+		t.Fatalf("expected --no-rerank to keep the history ranking")
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ashwch/ew/internal/analysis"
+	ewrt "github.com/ashwch/ew/internal/runtime"
+	"github.com/ashwch/ew/internal/ui"
+)
+
+// riskAssessment is the risk level the execution policy gives a command and
+// why, for the confirmation to show next to the command.
+type riskAssessment struct {
+	Level   string
+	Reasons []ui.RiskReason
+}
+
+func (r *riskAssessment) because(why string, tokens ...string) {
+	r.Reasons = append(r.Reasons, ui.RiskReason{Why: why, Tokens: tokens})
+}
+
+// overridingFlags turn off a safeguard the command would otherwise keep.
+var overridingFlags = map[string]bool{
+	"--force": true, "--force-with-lease": true, "--hard": true, "--no-verify": true,
+	"--auto-approve": true, "--no-preserve-root": true, "--delete": true, "--prune": true, "--purge": true,
+}
+
+// commandRiskReasons says what in command makes it risky: a high-risk or
+// destructive pattern, flags that override a safeguard, what it deletes or
+// writes, the hosts it reaches, and running as another user. Each reason
+// names the words of the command it is about.
+func commandRiskReasons(command string) []ui.RiskReason {
+	var risk riskAssessment
+	seen := map[string]bool{}
+	fresh := func(tokens []string) []string {
+		var out []string
+		for _, token := range tokens {
+			// Descriptions such as "files matched by find" are not words
+			// of the command.
+			if token != "" && !seen[token] && strings.Contains(command, token) {
+				seen[token] = true
+				out = append(out, token)
+			}
+		}
+		return out
+	}
+
+	if pattern, ok := ewrt.HighRiskPattern(command); ok {
+		risk.because(fmt.Sprintf("high-risk pattern %q", pattern), fresh(strings.Fields(pattern))...)
+	}
+	if pattern, ok := destructivePattern(command); ok {
+		if tokens := fresh(strings.Fields(pattern)); len(tokens) > 0 {
+			risk.because(fmt.Sprintf("destructive: %s", pattern), tokens...)
+		}
+	}
+	var flags []string
+	for _, word := range strings.Fields(command) {
+		if overridingFlags[strings.ToLower(word)] {
+			flags = append(flags, word)
+		}
+	}
+	if flags = fresh(flags); len(flags) > 0 {
+		risk.because("overrides a safeguard: "+strings.Join(flags, ", "), flags...)
+	}
+
+	effects := analysis.Analyze(command)
+	if len(effects.Deletes) > 0 {
+		risk.because("deletes "+strings.Join(effects.Deletes, ", "), fresh(effects.Deletes)...)
+	}
+	if len(effects.Writes) > 0 && isMutatingCommand(command) {
+		risk.because("writes "+strings.Join(effects.Writes, ", "), fresh(effects.Writes)...)
+	}
+	if len(effects.Network) > 0 {
+		risk.because("reaches "+strings.Join(effects.Network, ", "), fresh(effects.Network)...)
+	}
+	if len(effects.Privilege) > 0 {
+		risk.because("runs as another user with "+strings.Join(effects.Privilege, ", "), fresh(effects.Privilege)...)
+	}
+	return risk.Reasons
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/ui"
+)
+
+func TestCommandRiskReasonsNameTheRiskyWords(t *testing.T) {
+	got := commandRiskReasons("sudo rm -rf ./build")
+	want := []ui.RiskReason{
+		{Why: `high-risk pattern "rm -rf"`, Tokens: []string{"rm", "-rf"}},
+		{Why: "deletes ./build", Tokens: []string{"./build"}},
+		{Why: "runs as another user with sudo", Tokens: []string{"sudo"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected reasons:\n got %+v\nwant %+v", got, want)
+	}
+
+	got = commandRiskReasons("git reset --hard HEAD~1")
+	want = []ui.RiskReason{
+		{Why: "destructive: git reset --hard", Tokens: []string{"git", "reset", "--hard"}},
+		{Why: "deletes uncommitted changes (git reset --hard)"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected reasons:\n got %+v\nwant %+v", got, want)
+	}
+
+	if got := commandRiskReasons("git status"); len(got) != 0 {
+		t.Fatalf("expected no reasons for a read-only command, got %+v", got)
+	}
+}
+
+func TestApplyExecutionRiskPolicyExplainsItsLevel(t *testing.T) {
+	cfg := config.Default()
+	cfg.Execution.Target = "ssh:prod"
+	_, risk := applyExecutionRiskPolicy(cfg, "confirm", "echo hi >/tmp/demo-file", "low")
+	whys := []string{}
+	for _, reason := range risk.Reasons {
+		whys = append(whys, reason.Why)
+	}
+	want := []string{"writes /tmp/demo-file", "changes state on the remote target ssh:prod"}
+	if risk.Level != "high" || !reflect.DeepEqual(whys, want) {
+		t.Fatalf("expected high with %q, got %s with %q", want, risk.Level, whys)
+	}
+
+	_, risk = applyExecutionRiskPolicy(config.Default(), "confirm", "git status", "medium")
+	if len(risk.Reasons) != 1 || risk.Reasons[0].Why != "its source rated it medium" {
+		t.Fatalf("expected the source's rating as the reason, got %+v", risk.Reasons)
+	}
+}
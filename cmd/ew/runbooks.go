@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/history"
+	"github.com/ashwch/ew/internal/runbook"
+)
+
+const (
+	runbookSourcePrefix = "runbook:"
+	// runbookMinScore is how well a runbook section must match for find to
+	// take its command over a provider's answer: two heading words, or a
+	// heading word and a word of its prose.
+	runbookMinScore = 6
+)
+
+// runtimeRunbookDir is runbooks.dir resolved against the config dir, or
+// empty when no runbooks are set up.
+var runtimeRunbookDir = ""
+
+var (
+	runbookSectionsOnce sync.Once
+	runbookSections     []runbook.Section
+)
+
+// loadRunbookSections is swapped out in tests.
+var loadRunbookSections = func(dir string) []runbook.Section {
+	sections, _ := runbook.Load(dir)
+	return sections
+}
+
+// runbookDir resolves runbooks.dir the way includes are resolved.
+func runbookDir(cfg config.Config, cfgPath string) string {
+	return config.IncludePath(cfg.Runbooks.Dir, filepath.Dir(cfgPath))
+}
+
+// runbookMatches searches the runbooks for query, leaving out commands the
+// query does not allow, as history is filtered.
+func runbookMatches(query string, limit int) []runbook.Match {
+	if runtimeRunbookDir == "" {
+		return nil
+	}
+	runbookSectionsOnce.Do(func() {
+		runbookSections = loadRunbookSections(runtimeRunbookDir)
+	})
+	matches := []runbook.Match{}
+	for _, match := range runbook.Search(runbookSections, query, 0) {
+		if !commandAllowedForQuery(query, match.Command) {
+			continue
+		}
+		matches = append(matches, match)
+		if limit > 0 && len(matches) == limit {
+			break
+		}
+	}
+	return matches
+}
+
+func runbookSource(match runbook.Match) string {
+	return fmt.Sprintf("%s%s:%d", runbookSourcePrefix, match.File, match.Line)
+}
+
+// mergeRunbookMatches adds runbook commands for query to the history
+// matches, ranked together by score and trimmed to limit, so the picker
+// lists them with their file and line as the source.
+func mergeRunbookMatches(query string, matches []history.Match, limit int) []history.Match {
+	found := runbookMatches(query, limit)
+	if len(found) == 0 {
+		return matches
+	}
+	merged := append([]history.Match{}, matches...)
+	for _, match := range found {
+		merged = append(merged, history.Match{
+			Command: match.Command,
+			Score:   match.Score,
+			Source:  runbookSource(match),
+		})
+	}
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].Score > merged[j].Score })
+	if limit > 0 && len(merged) > limit {
+		merged = merged[:limit]
+	}
+	return merged
+}
+
+// runbookPick is the runbook command find and run prefer over a provider's
+// answer: the best matching section's, when it matches well enough, cited
+// by file, line, and section.
+func runbookPick(query string) (findPick, bool) {
+	found := runbookMatches(query, 1)
+	if len(found) == 0 || found[0].Score < runbookMinScore {
+		return findPick{}, false
+	}
+	top := found[0]
+	return findPick{
+		Command: top.Command,
+		Reason:  "from runbook " + top.Citation(),
+		Source:  runbookSource(top),
+	}, true
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/ashwch/ew/internal/history"
+	"github.com/ashwch/ew/internal/runbook"
+)
+
+const apiRunbook = "# API\n\n" +
+	"## Roll back a release\n\n" +
+	"```sh\n" +
+	"kubectl -n api rollout undo deployment/api\n" +
+	"```\n\n" +
+	"## Wipe the cache\n\n" +
+	"```sh\n" +
+	"rm -rf /var/cache/api\n" +
+	"```\n"
+
+func useRunbookSections(t *testing.T, sections ...runbook.Section) {
+	t.Helper()
+	originalLoad, originalDir := loadRunbookSections, runtimeRunbookDir
+	loadRunbookSections = func(string) []runbook.Section { return sections }
+	runtimeRunbookDir = "runbooks"
+	runbookSectionsOnce = sync.Once{}
+	t.Cleanup(func() {
+		loadRunbookSections, runtimeRunbookDir = originalLoad, originalDir
+		runbookSectionsOnce = sync.Once{}
+		runbookSections = nil
+	})
+}
+
+func TestRunbookPickCitesTheSectionAndMergesWithHistory(t *testing.T) {
+	useRunbookSections(t, runbook.Parse("api.md", apiRunbook)...)
+
+	pick, ok := runbookPick("roll back the api release")
+	if !ok || pick.Command != "kubectl -n api rollout undo deployment/api" {
+		t.Fatalf("expected the rollback command, got %+v %v", pick, ok)
+	}
+	if pick.Reason != "from runbook api.md:6 (API > Roll back a release)" || pick.Source != "runbook:api.md:6" {
+		t.Fatalf("unexpected citation %q from %q", pick.Reason, pick.Source)
+	}
+
+	merged := mergeRunbookMatches("roll back the api release", []history.Match{{Command: "kubectl get pods", Score: 2, Source: "zsh"}}, 5)
+	if len(merged) != 2 || merged[0].Source != "runbook:api.md:6" {
+		t.Fatalf("expected the runbook command ranked first, got %+v", merged)
+	}
+
+	if pick, ok := runbookPick("api"); ok {
+		t.Fatalf("expected a one-word match to be too weak, got %+v", pick)
+	}
+	if pick, ok := runbookPick("show the api cache"); ok {
+		t.Fatalf("expected rm -rf to be filtered for a read-only query, got %+v", pick)
+	}
+}
+
+func TestRunbookPickIsOffWithoutADir(t *testing.T) {
+	useRunbookSections(t, runbook.Parse("api.md", apiRunbook)...)
+	runtimeRunbookDir = ""
+	if pick, ok := runbookPick("roll back the api release"); ok {
+		t.Fatalf("expected no pick without runbooks.dir, got %+v", pick)
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/ashwch/ew/internal/safety"
+)
+
+// loadSafetyPolicy reads safety.toml once per run; tests swap it.
+var loadSafetyPolicy = sync.OnceValues(safety.LoadPolicy)
+
+// safetyPolicyRule is the safety.toml rule for command run from the working
+// directory. A policy that does not parse would otherwise drop the rules a
+// team relies on, so until it is fixed every command matches a confirm rule.
+func safetyPolicyRule(command string) (safety.Rule, bool) {
+	policy, err := loadSafetyPolicy()
+	if err != nil {
+		return safety.Rule{
+			Action: safety.ActionConfirm,
+			Reason: fmt.Sprintf("%s is invalid (%v); every command asks first until it is fixed", policy.Path, err),
+		}, true
+	}
+	dir, _ := os.Getwd()
+	return policy.Match(command, dir)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/router"
+	"github.com/ashwch/ew/internal/safety"
+)
+
+func useSafetyPolicy(t *testing.T, body string, loadErr error) {
+	t.Helper()
+	policy, err := safety.ParsePolicy([]byte(body))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	original := loadSafetyPolicy
+	t.Cleanup(func() { loadSafetyPolicy = original })
+	loadSafetyPolicy = func() (safety.Policy, error) { return policy, loadErr }
+}
+
+func TestSafetyPolicyAdjustsRiskAndMode(t *testing.T) {
+	useSafetyPolicy(t, `
+[[rule]]
+action = "allow"
+pattern = "git reset --hard*"
+
+[[rule]]
+action = "confirm"
+pattern = "uptime"
+risk = "medium"
+`, nil)
+	cfg := config.Default()
+
+	if mode, risk := applyExecutionRiskPolicy(cfg, "yolo", "git reset --hard", "high"); mode != "yolo" || risk.Level != "low" {
+		t.Fatalf("expected the allow rule to replace the built-in checks, got mode=%s risk=%s", mode, risk.Level)
+	}
+	if mode, risk := applyExecutionRiskPolicy(cfg, "yolo", "uptime", "low"); mode != "confirm" || risk.Level != "medium" {
+		t.Fatalf("expected the confirm rule to ask first, got mode=%s risk=%s", mode, risk.Level)
+	}
+}
+
+func TestSafetyPolicyDenyBlocksExecution(t *testing.T) {
+	useSafetyPolicy(t, `
+[[rule]]
+action = "deny"
+pattern = "echo blocked*"
+reason = "not here"
+`, nil)
+	var outcome executionOutcome
+	out := captureStdout(t, func() {
+		outcome = executeSuggested("echo blocked now", "", "test", "low", config.Default(), options{JSON: true, Yes: true, Mode: "yolo"}, router.IntentRun)
+	})
+	var payload response
+	if err := json.Unmarshal([]byte(out), &payload); err != nil {
+		t.Fatalf("expected json output, got %q: %v", out, err)
+	}
+	if outcome.Executed || payload.Executed || !strings.Contains(payload.Message, "blocked by safety policy: not here") {
+		t.Fatalf("expected the command blocked, got %+v", payload)
+	}
+}
+
+func TestBrokenSafetyPolicyAsksForEveryCommand(t *testing.T) {
+	useSafetyPolicy(t, "", errors.New("rule 1: pattern or regex is required"))
+	if mode, _ := applyExecutionRiskPolicy(config.Default(), "yolo", "git status", "low"); mode != "confirm" {
+		t.Fatalf("expected confirm while the policy is broken, got %s", mode)
+	}
+}
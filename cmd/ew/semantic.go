@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/embedding"
+	"github.com/ashwch/ew/internal/history"
+	"github.com/ashwch/ew/internal/memory"
+)
+
+// useEmbeddings turns on semantic memory and history search when
+// embeddings.enabled is set and --offline is not. After the first failed
+// call ew stops asking for the rest of the invocation and ranks by words
+// alone; --verbose says why.
+func useEmbeddings(cfg config.Config, opts options) {
+	if !cfg.Embeddings.Enabled || opts.Offline {
+		memory.SetSimilarity(nil)
+		history.SetSimilarity(nil)
+		return
+	}
+	client, err := embedding.New(cfg.Embeddings)
+	if err != nil {
+		if opts.Verbose {
+			fmt.Fprintf(os.Stderr, "ew: semantic search is off: %v\n", err)
+		}
+		return
+	}
+	failed := false
+	similar := func(query string, texts []string) []float64 {
+		if failed {
+			return nil
+		}
+		scores, err := embedding.Similarities(invocationCtx, client, query, texts)
+		if err != nil {
+			failed = true
+			if opts.Verbose {
+				fmt.Fprintf(os.Stderr, "ew: semantic search is off: %v\n", err)
+			}
+			return nil
+		}
+		return semanticScores(scores, cfg.Embeddings.MinSimilarity)
+	}
+	memory.SetSimilarity(similar)
+	history.SetSimilarity(similar)
+}
+
+// semanticScores zeroes the similarities below minimum, which are not
+// close enough to count as a match.
+func semanticScores(scores []float64, minimum float64) []float64 {
+	for idx, score := range scores {
+		if score < minimum {
+			scores[idx] = 0
+		}
+	}
+	return scores
+}
+
+// semanticallyCompatible reports whether a memory match found by meaning
+// rather than shared words may be picked for query: it has to mention the
+// same numbers, so "free port 3000" does not pick what was saved for
+// port 8080.
+func semanticallyCompatible(query string, match memory.Match) bool {
+	return match.Similarity > 0 && sameStringSet(memoryNumericTokens(query), memoryNumericTokens(match.Query))
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/ashwch/ew/internal/memory"
+)
+
+func TestPreferredMemoryMatchAcceptsSemanticMatches(t *testing.T) {
+	match := memory.Match{Query: "kill process on port 3000", Command: "lsof -ti :3000 | xargs kill", Score: 30}
+	if _, ok := preferredMemoryMatch("free up 3000", []memory.Match{match}); ok {
+		t.Fatalf("expected no pick without shared words or similarity")
+	}
+	match.Similarity = 0.82
+	if top, ok := preferredMemoryMatch("free up 3000", []memory.Match{match}); !ok || top.Command != match.Command {
+		t.Fatalf("expected the similar entry to be picked, got %+v %v", top, ok)
+	}
+	if _, ok := preferredMemoryMatch("free up 8080", []memory.Match{match}); ok {
+		t.Fatalf("expected a different port not to pick the entry")
+	}
+}
+
+func TestSemanticScoresDropsDistantTexts(t *testing.T) {
+	if got := semanticScores([]float64{0.9, 0.59, 0.6}, 0.6); !slices.Equal(got, []float64{0.9, 0, 0.6}) {
+		t.Fatalf("unexpected scores %v", got)
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/feedback"
+	"github.com/ashwch/ew/internal/provider"
+	"github.com/ashwch/ew/internal/router"
+	"github.com/ashwch/ew/internal/safety"
+	"github.com/ashwch/ew/internal/session"
+	"github.com/ashwch/ew/internal/ui"
+)
+
+// runtimeInteraction collects what this invocation asked and answered; main
+// flushes it to the session journal once the handler returns.
+var runtimeInteraction *session.Interaction
+
+// runtimeRejected lists the suggestions the user turned down during this
+// invocation, for the feedback dataset.
+var runtimeRejected []string
+
+// runtimeFeedbackEnabled mirrors feedback.enabled unless EW_FEEDBACK=off or
+// --no-record.
+var runtimeFeedbackEnabled = false
+
+// configureRecording decides what this invocation leaves behind: the
+// journal keeps what journal.privacy allows, and --no-record keeps it out
+// of both the journal and the feedback dataset.
+func configureRecording(cfg config.Config, opts options) {
+	runtimeFeedbackEnabled = cfg.Feedback.Enabled && !feedback.Disabled() && !opts.NoRecord
+	session.SetPrivacy(cfg.Journal.Privacy)
+	if opts.NoRecord {
+		session.SetPrivacy(session.PrivacyOff)
+	}
+}
+
+func beginSessionInteraction(prompt string, intent router.Intent) {
+	runtimeInteraction = &session.Interaction{
+		Query:     prompt,
+		Intent:    string(intent),
+		Decision:  session.DecisionSuggested,
+		SessionID: strings.TrimSpace(os.Getenv("EW_SESSION_ID")),
+	}
+}
+
+func noteSessionSuggestion(command, reason, source string) {
+	if runtimeInteraction == nil || command == "" {
+		return
+	}
+	runtimeInteraction.Command = command
+	runtimeInteraction.Reason = reason
+	if source != "" {
+		runtimeInteraction.Source = source
+	}
+	runtimeInteraction.Decision = session.DecisionSuggested
+}
+
+func noteSessionOutcome(outcome executionOutcome, reason string) {
+	if runtimeInteraction == nil {
+		return
+	}
+	runtimeInteraction.Command = outcome.Command
+	runtimeInteraction.Reason = reason
+	switch {
+	case outcome.Executed && outcome.Success:
+		runtimeInteraction.Decision = session.DecisionExecuted
+	case outcome.Executed:
+		runtimeInteraction.Decision = session.DecisionFailed
+	default:
+		runtimeInteraction.Decision = session.DecisionNotExecuted
+	}
+}
+
+// runtimeProviderAnswer is the command the provider last answered with, so
+// output can tell a provider suggestion from a history match.
+var runtimeProviderAnswer string
+
+// runtimeProviderFallbacks are the providers that failed before the one
+// behind runtimeProviderAnswer answered.
+var runtimeProviderFallbacks []string
+
+// runtimeProviderCached is set when runtimeProviderAnswer came from the
+// response cache.
+var runtimeProviderCached bool
+
+// runtimeIncludeRaw is --include-raw with --json; runtimeProviderRaw is then
+// the last provider answer as received.
+var (
+	runtimeIncludeRaw  bool
+	runtimeProviderRaw *providerRaw
+)
+
+// providerRaw is a provider's response text, redacted, and the parse path
+// ew took to read an answer out of it. A cached answer has neither.
+type providerRaw struct {
+	Provider  string `json:"provider"`
+	Model     string `json:"model,omitempty"`
+	Cached    bool   `json:"cached,omitempty"`
+	ParsePath string `json:"parse_path,omitempty"`
+	Raw       string `json:"raw,omitempty"`
+}
+
+// noteSessionProvider adds one provider round trip to the interaction's
+// latency total and records who answered.
+func noteSessionProvider(resolution provider.Resolution, elapsed time.Duration) {
+	if runtimeInteraction == nil {
+		return
+	}
+	if resolution.Provider != "" {
+		runtimeInteraction.Provider = resolution.Provider
+		runtimeInteraction.Model = resolution.Model
+		runtimeInteraction.Confidence = resolution.Confidence
+		runtimeProviderAnswer = resolution.Command
+		runtimeProviderFallbacks = resolution.FallbackFrom
+		runtimeProviderCached = resolution.Cached
+		if runtimeIncludeRaw {
+			runtimeProviderRaw = &providerRaw{
+				Provider:  resolution.Provider,
+				Model:     resolution.Model,
+				Cached:    resolution.Cached,
+				ParsePath: resolution.ParsePath,
+				Raw:       safety.RedactText(resolution.Raw),
+			}
+		}
+	}
+	runtimeInteraction.LatencyMS += elapsed.Milliseconds()
+}
+
+// suggestionModel returns the provider and model that produced command, or
+// empty strings when it did not come from this invocation's provider.
+func suggestionModel(command string) (string, string) {
+	if runtimeInteraction == nil || runtimeInteraction.Provider == "" {
+		return "", ""
+	}
+	key := comparableCommand(command)
+	if key == "" || key != comparableCommand(runtimeProviderAnswer) {
+		return "", ""
+	}
+	return runtimeInteraction.Provider, runtimeInteraction.Model
+}
+
+// suggestionFallbacks returns the providers that failed before the one that
+// produced command, or nil when command did not come from a provider.
+func suggestionFallbacks(command string) []string {
+	if providerName, _ := suggestionModel(command); providerName == "" {
+		return nil
+	}
+	return runtimeProviderFallbacks
+}
+
+// modelLabel renders provider and model as "gpt-5-mini (codex)".
+func modelLabel(providerName, model string) string {
+	if model == "" {
+		return providerName
+	}
+	return fmt.Sprintf("%s (%s)", model, providerName)
+}
+
+func flushSessionInteraction() {
+	if runtimeInteraction == nil {
+		return
+	}
+	interaction := *runtimeInteraction
+	runtimeInteraction = nil
+	_ = session.Record(interaction)
+	if runtimeFeedbackEnabled {
+		_ = feedback.Append(feedbackRecord(interaction, runtimeRejected))
+	}
+	runtimeRejected = nil
+}
+
+func noteSessionRejection(command string) {
+	if command = strings.TrimSpace(command); command != "" {
+		runtimeRejected = append(runtimeRejected, command)
+	}
+}
+
+// feedbackRecord turns a finished interaction into a dataset line. A
+// suggestion the user turned down is never reported as the chosen command.
+func feedbackRecord(interaction session.Interaction, rejected []string) feedback.Record {
+	record := feedback.Record{
+		Intent:       interaction.Intent,
+		Query:        interaction.Query,
+		Chosen:       interaction.Command,
+		ChosenSource: interaction.Source,
+		Rejected:     rejected,
+	}
+	if interaction.Source != "" && interaction.Source == interaction.Provider {
+		record.Model = interaction.Model
+	}
+	for _, command := range rejected {
+		if normalizeComparableCommand(command) == normalizeComparableCommand(record.Chosen) {
+			record.Chosen, record.ChosenSource, record.Model = "", "", ""
+		}
+	}
+
+	switch interaction.Decision {
+	case session.DecisionExecuted, session.DecisionFailed:
+		success := interaction.Decision == session.DecisionExecuted
+		record.Outcome = feedback.OutcomeFailed
+		if success {
+			record.Outcome = feedback.OutcomeSucceeded
+		}
+		record.Success = &success
+	case session.DecisionInterrupted:
+		record.Outcome = feedback.OutcomeInterrupted
+	case session.DecisionNotExecuted:
+		record.Outcome = feedback.OutcomeDeclined
+	default:
+		record.Outcome = feedback.OutcomeSuggested
+	}
+	if record.Chosen == "" && len(rejected) > 0 && record.Outcome == feedback.OutcomeSuggested {
+		record.Outcome = feedback.OutcomeDeclined
+	}
+	return record
+}
+
+func replaySteps(transcript session.Transcript) []ui.ReplayStep {
+	steps := make([]ui.ReplayStep, 0, len(transcript.Interactions))
+	for _, item := range transcript.Interactions {
+		title := item.Query
+		if title == "" {
+			title = "(no query)"
+		}
+		lines := []string{fmt.Sprintf("intent: %s  decision: %s", item.Intent, item.Decision)}
+		if item.Command != "" {
+			lines = append(lines, "", "$ "+item.Command)
+		}
+		if item.Reason != "" {
+			lines = append(lines, "", "reason: "+item.Reason)
+		}
+		if item.Source != "" {
+			lines = append(lines, "source: "+item.Source)
+		}
+		if item.Timestamp != "" {
+			lines = append(lines, "at: "+item.Timestamp)
+		}
+		steps = append(steps, ui.ReplayStep{Title: title, Lines: lines})
+	}
+	return steps
+}
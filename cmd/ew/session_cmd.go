@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/session"
+	"github.com/ashwch/ew/internal/ui"
+)
+
+// sessionVerbs are the words `ew session` takes.
+var sessionVerbs = []string{"export", "replay"}
+
+// defaultSessionExport is how many interactions `ew session export` prints
+// without --last.
+const defaultSessionExport = 10
+
+// sessionSubcommand recognises `ew session export|replay ...` and returns
+// the verb and its arguments. Anything else after "session" is an ordinary
+// request, so `ew session cookies in chrome` still finds.
+func sessionSubcommand(args []string) ([]string, bool) {
+	if len(args) < 2 || args[0] != "session" || !slices.Contains(sessionVerbs, args[1]) {
+		return nil, false
+	}
+	return args[1:], true
+}
+
+// runSessionCommand prints the latest interactions as a redacted
+// transcript, or steps through one exported with --json, and returns the
+// exit code. It uses config.toml without project settings.
+func runSessionCommand(args []string, stdout io.Writer) int {
+	verb := args[0]
+	fs := flag.NewFlagSet("ew session "+verb, flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print JSON")
+	last := fs.Int("last", defaultSessionExport, "export: how many interactions to include")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: ew session export [--last n] [--json] | replay [--json] <file>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return exitUsage
+	}
+
+	cfg, _, err := config.LoadFile()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ew: could not load config: %v\n", err)
+		return 1
+	}
+	if release := useStateBackend(cfg); release != nil {
+		defer release()
+	}
+
+	switch verb {
+	case "export":
+		if *last <= 0 {
+			fmt.Fprintln(os.Stderr, "ew: --last must be a positive number of interactions")
+			return exitUsage
+		}
+		return exportSession(*last, *asJSON, stdout)
+	default:
+		if fs.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, "ew: ew session replay needs a transcript, e.g. ew session replay ew-session.json")
+			return exitUsage
+		}
+		ui.SetASCIIOnly(cfg.UI.ASCIIOnly || ui.DumbTerminal())
+		return replaySession(fs.Arg(0), cfg, *asJSON, stdout)
+	}
+}
+
+// exportSession prints the last limit interactions, secrets masked
+// whatever journal.privacy is: markdown, or a replayable file with asJSON.
+func exportSession(limit int, asJSON bool, stdout io.Writer) int {
+	transcript, err := session.Export(limit)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ew: could not export session: %v\n", err)
+		return 1
+	}
+	if asJSON {
+		if err := writeJSON(stdout, transcript); err != nil {
+			fmt.Fprintf(os.Stderr, "ew: could not encode transcript: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+	fmt.Fprint(stdout, session.Markdown(transcript))
+	return 0
+}
+
+// replaySession pages through an exported transcript in the TUI, or prints
+// it as markdown when there is no interactive picker.
+func replaySession(path string, cfg config.Config, asJSON bool, stdout io.Writer) int {
+	transcript, err := session.LoadTranscript(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ew: could not replay session: %v\n", err)
+		return 1
+	}
+	if asJSON {
+		if err := writeJSON(stdout, transcript); err != nil {
+			fmt.Fprintf(os.Stderr, "ew: could not encode transcript: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	opts := options{}
+	backend := effectiveUIBackend(cfg, opts)
+	if canUseInteractiveUI(opts, backend) {
+		used, uiErr := ui.ReplaySession(backend, replaySteps(transcript))
+		if uiErr == nil && used {
+			return 0
+		}
+		if uiErr != nil {
+			fmt.Fprintf(os.Stderr, "ew: replay ui failed (%v); printing transcript instead\n", uiErr)
+		}
+	}
+	fmt.Fprint(stdout, session.Markdown(transcript))
+	return 0
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/feedback"
+	"github.com/ashwch/ew/internal/provider"
+	"github.com/ashwch/ew/internal/router"
+	"github.com/ashwch/ew/internal/session"
+)
+
+func TestSessionSubcommandExportsAndReplaysTranscripts(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, ".local", "state"))
+
+	if _, ok := sessionSubcommand([]string{"session", "cookies", "in", "chrome"}); ok {
+		t.Fatalf("expected an ordinary request about sessions to stay a query")
+	}
+	args, ok := sessionSubcommand([]string{"session", "export", "--last", "1", "--json"})
+	if !ok || args[0] != "export" {
+		t.Fatalf("expected ew session export to be recognised, got %v", args)
+	}
+	if err := session.Record(session.Interaction{Query: "push", Intent: "find", Command: "git push origin main", Decision: session.DecisionSuggested}); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+
+	var out strings.Builder
+	if code := runSessionCommand(args, &out); code != 0 {
+		t.Fatalf("expected export to succeed, got exit %d", code)
+	}
+	var transcript session.Transcript
+	if err := json.Unmarshal([]byte(out.String()), &transcript); err != nil {
+		t.Fatalf("expected a JSON transcript, got %q: %v", out.String(), err)
+	}
+	if len(transcript.Interactions) != 1 || transcript.Interactions[0].Command != "git push origin main" {
+		t.Fatalf("expected the recorded interaction, got %+v", transcript.Interactions)
+	}
+
+	path := filepath.Join(home, "ew-session.json")
+	if err := os.WriteFile(path, []byte(out.String()), 0o600); err != nil {
+		t.Fatalf("write transcript: %v", err)
+	}
+	out.Reset()
+	if code := runSessionCommand([]string{"replay", path}, &out); code != 0 || !strings.Contains(out.String(), "git push origin main") {
+		t.Fatalf("expected replay to print the transcript, got exit %d and %q", code, out.String())
+	}
+	if code := runSessionCommand([]string{"export", "--last", "0"}, &out); code != exitUsage {
+		t.Fatalf("expected a non-positive --last to be a usage error, got %d", code)
+	}
+}
+
+func TestSessionInteractionTracksExecutionOutcome(t *testing.T) {
+	t.Cleanup(func() { runtimeInteraction = nil })
+
+	beginSessionInteraction("free port 8000", router.IntentRun)
+	noteSessionSuggestion("lsof -ti :8000 | xargs kill", "found listener", "ew")
+	noteSessionOutcome(executionOutcome{Command: "lsof -ti :8000 | xargs kill", Executed: true, Success: false}, "found listener")
+
+	if runtimeInteraction.Decision != session.DecisionFailed {
+		t.Fatalf("expected failed decision, got %q", runtimeInteraction.Decision)
+	}
+	if runtimeInteraction.Source != "ew" {
+		t.Fatalf("expected source to survive outcome, got %q", runtimeInteraction.Source)
+	}
+}
+
+func TestFeedbackRecordKeepsRejectedSuggestionsOutOfChosen(t *testing.T) {
+	declined := feedbackRecord(session.Interaction{
+		Query:    "clean docker",
+		Intent:   string(router.IntentFind),
+		Command:  "docker system prune",
+		Source:   "codex",
+		Decision: session.DecisionSuggested,
+	}, []string{"docker system prune"})
+	if declined.Chosen != "" || declined.Outcome != feedback.OutcomeDeclined || len(declined.Rejected) != 1 {
+		t.Fatalf("expected a cancelled pick to be recorded as declined, got %+v", declined)
+	}
+
+	executed := feedbackRecord(session.Interaction{
+		Query:    "clean docker",
+		Intent:   string(router.IntentRun),
+		Command:  "docker image prune",
+		Source:   "zsh",
+		Decision: session.DecisionFailed,
+	}, []string{"docker system prune"})
+	if executed.Chosen != "docker image prune" || executed.ChosenSource != "zsh" || executed.Success == nil || *executed.Success {
+		t.Fatalf("expected the executed command with success=false, got %+v", executed)
+	}
+	if executed.Outcome != feedback.OutcomeFailed {
+		t.Fatalf("expected outcome failed, got %q", executed.Outcome)
+	}
+}
+
+func TestSuggestionModelOnlyLabelsProviderAnswers(t *testing.T) {
+	previous, previousAnswer, previousFallbacks := runtimeInteraction, runtimeProviderAnswer, runtimeProviderFallbacks
+	t.Cleanup(func() {
+		runtimeInteraction, runtimeProviderAnswer, runtimeProviderFallbacks = previous, previousAnswer, previousFallbacks
+	})
+	beginSessionInteraction("clean docker", router.IntentFind)
+	noteSessionProvider(provider.Resolution{Command: "docker system prune", Provider: "codex", Model: "gpt-5-mini", FallbackFrom: []string{"claude"}}, 0)
+
+	output := captureStdout(t, func() {
+		printResponse(response{Intent: "find", Command: "docker  system prune"}, true)
+	})
+	var payload response
+	if err := json.Unmarshal([]byte(output), &payload); err != nil {
+		t.Fatalf("invalid JSON %q: %v", output, err)
+	}
+	if payload.Provider != "codex" || payload.Model != "gpt-5-mini" {
+		t.Fatalf("expected the provider's answer to carry its model, got %+v", payload)
+	}
+	if len(payload.FallbackFrom) != 1 || payload.FallbackFrom[0] != "claude" {
+		t.Fatalf("expected the provider that failed first to be listed, got %v", payload.FallbackFrom)
+	}
+	if fallbacks := suggestionFallbacks("docker image prune"); fallbacks != nil {
+		t.Fatalf("expected a history match to carry no fallbacks, got %v", fallbacks)
+	}
+	if name, model := suggestionModel("docker image prune"); name != "" || model != "" {
+		t.Fatalf("expected a history match to carry no model, got %q %q", name, model)
+	}
+	if runtimeInteraction.Model != "gpt-5-mini" {
+		t.Fatalf("expected the journal entry to record the model, got %q", runtimeInteraction.Model)
+	}
+
+	record := feedbackRecord(session.Interaction{Command: "docker system prune", Source: "codex", Provider: "codex", Model: "gpt-5-mini"}, nil)
+	if record.Model != "gpt-5-mini" {
+		t.Fatalf("expected the feedback record to name the model, got %+v", record)
+	}
+}
+
+func TestIncludeRawAddsTheRedactedProviderResponse(t *testing.T) {
+	previous, previousRaw, previousInclude := runtimeInteraction, runtimeProviderRaw, runtimeIncludeRaw
+	t.Cleanup(func() {
+		runtimeInteraction, runtimeProviderRaw, runtimeIncludeRaw = previous, previousRaw, previousInclude
+	})
+	answer := provider.Resolution{
+		Command:   "aws s3 ls",
+		Provider:  "claude",
+		Raw:       `{"result":"{\"command\":\"aws s3 ls\"} api_key=abc123"}`,
+		ParsePath: "wrapper.result > extracted_object",
+	}
+
+	runtimeIncludeRaw, runtimeProviderRaw = false, nil
+	beginSessionInteraction("list buckets", router.IntentFind)
+	noteSessionProvider(answer, 0)
+	if output := captureStdout(t, func() { printResponse(response{Intent: "find", Command: "aws s3 ls"}, true) }); strings.Contains(output, "provider_raw") {
+		t.Fatalf("expected no raw output by default, got %s", output)
+	}
+
+	runtimeIncludeRaw = true
+	beginSessionInteraction("list buckets", router.IntentFind)
+	noteSessionProvider(answer, 0)
+	output := captureStdout(t, func() { printResponse(response{Intent: "find", Command: "aws s3 ls"}, true) })
+	var payload response
+	if err := json.Unmarshal([]byte(output), &payload); err != nil {
+		t.Fatalf("invalid JSON %q: %v", output, err)
+	}
+	raw := payload.ProviderRaw
+	if raw == nil || raw.Provider != "claude" || raw.ParsePath != answer.ParsePath {
+		t.Fatalf("expected the provider's raw answer, got %+v", raw)
+	}
+	if strings.Contains(raw.Raw, "abc123") || !strings.Contains(raw.Raw, "aws s3 ls") {
+		t.Fatalf("expected the raw text redacted, got %q", raw.Raw)
+	}
+}
+
+func TestNoRecordLeavesNoTraceOnDisk(t *testing.T) {
+	previousAppend, previousQueue := appendShellHistory, queueShellHistory
+	t.Cleanup(func() {
+		appendShellHistory, queueShellHistory = previousAppend, previousQueue
+		runtimeInteraction, runtimeFeedbackEnabled = nil, false
+		session.SetPrivacy("")
+	})
+	var written []string
+	appendShellHistory = func(_, command string, _ time.Time) (string, error) {
+		written = append(written, command)
+		return "", nil
+	}
+	queueShellHistory = func(_, command string) error {
+		written = append(written, command)
+		return nil
+	}
+
+	run := func(opts options) []string {
+		home := t.TempDir()
+		t.Setenv("HOME", home)
+		t.Setenv("XDG_STATE_HOME", filepath.Join(home, ".local", "state"))
+		t.Setenv("EW_SESSION_ID", "")
+		t.Chdir(t.TempDir())
+		written = nil
+
+		cfg := config.Default()
+		cfg.Feedback.Enabled = true
+		cfg.Journal.Privacy = session.PrivacyFull
+		cfg.History.WriteBack = true
+		configureRecording(cfg, opts)
+
+		service := provider.NewService(provider.NewRegistry())
+		req := provider.Request{Intent: provider.IntentFind, Prompt: "push to github"}
+		if _, _, err := resolveCached(context.Background(), service, cfg, options{Provider: "ew"}, req); err != nil {
+			t.Fatalf("resolveCached failed: %v", err)
+		}
+		beginSessionInteraction("make a build dir", router.IntentRun)
+		captureStdout(t, func() {
+			executeSuggested("mkdir build", "", "makes the build dir", "low", cfg, opts, router.IntentRun)
+		})
+		flushSessionInteraction()
+
+		var files []string
+		_ = filepath.WalkDir(filepath.Join(home, ".local", "state"), func(path string, entry fs.DirEntry, err error) error {
+			if err == nil && !entry.IsDir() {
+				files = append(files, filepath.Base(path))
+			}
+			return nil
+		})
+		return files
+	}
+
+	if files := run(options{JSON: true, Yes: true}); len(files) == 0 || len(written) == 0 {
+		t.Fatalf("expected a recorded run to leave state and history behind, got %v %v", files, written)
+	}
+	if files := run(options{JSON: true, Yes: true, NoRecord: true}); len(files) != 0 || len(written) != 0 {
+		t.Fatalf("expected --no-record to leave nothing behind, got files %v and history %v", files, written)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ashwch/ew/internal/hook"
+	"github.com/ashwch/ew/internal/provider"
+	ewrt "github.com/ashwch/ew/internal/runtime"
+)
+
+// interactiveShell is swapped out in tests.
+var interactiveShell = detectInteractiveShell
+
+// detectInteractiveShell prefers the shell the hooks recorded for this
+// terminal session, then the system profile, then $SHELL. main hands it to
+// ewrt.UseShell, so suggestions are adapted for and run in the same shell.
+func detectInteractiveShell() string {
+	if shell, err := hook.SessionShell(strings.TrimSpace(os.Getenv("EW_SESSION_ID"))); err == nil && shell != "" {
+		return shell
+	}
+	if runtimeSystemShell != "" {
+		return runtimeSystemShell
+	}
+	return filepath.Base(strings.TrimSpace(os.Getenv("SHELL")))
+}
+
+// adaptResolutionForShell translates bashisms in a provider suggestion into
+// the user's shell. With --verbose the reason also carries the original.
+func adaptResolutionForShell(resolution provider.Resolution, opts options) provider.Resolution {
+	command := strings.TrimSpace(resolution.Command)
+	if command == "" {
+		return resolution
+	}
+	shell := interactiveShell()
+	adapted, ok := ewrt.AdaptForShell(command, shell)
+	if !ok {
+		return resolution
+	}
+	resolution.Command = adapted
+	if opts.Verbose {
+		resolution.Reason = strings.TrimSpace(resolution.Reason + fmt.Sprintf("\n\nAdapted for %s from: `%s`", shell, command))
+	}
+	return resolution
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ashwch/ew/internal/provider"
+)
+
+func TestAdaptResolutionForShellTranslatesForFish(t *testing.T) {
+	previous := interactiveShell
+	interactiveShell = func() string { return "fish" }
+	t.Cleanup(func() { interactiveShell = previous })
+
+	resolution := provider.Resolution{Command: "export EDITOR=vim", Reason: "set your editor"}
+	adapted := adaptResolutionForShell(resolution, options{})
+	if adapted.Command != "set -x EDITOR vim" || adapted.Reason != "set your editor" {
+		t.Fatalf("unexpected adaptation %+v", adapted)
+	}
+
+	verbose := adaptResolutionForShell(resolution, options{Verbose: true})
+	if !strings.Contains(verbose.Reason, "Adapted for fish from: `export EDITOR=vim`") {
+		t.Fatalf("expected verbose reason to show the original, got %q", verbose.Reason)
+	}
+
+	interactiveShell = func() string { return "zsh" }
+	if got := adaptResolutionForShell(resolution, options{}); got.Command != "export EDITOR=vim" {
+		t.Fatalf("expected zsh suggestion unchanged, got %q", got.Command)
+	}
+}
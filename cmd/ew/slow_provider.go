@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/provider"
+)
+
+// providerSoftTimeout is how long a provider call runs before the loader
+// offers to skip it. ai.timeout_seconds still ends the call for good.
+const providerSoftTimeout = 5 * time.Second
+
+const providerSkipHint = "press ctrl-c to fall back to history results"
+
+// errProviderSkipped is returned when Ctrl-C stopped a slow provider call
+// so ew could fall back to history.
+var errProviderSkipped = errors.New("provider call skipped")
+
+// resolveProviderOrHistory is resolveProviderWithLoader for a call the
+// caller can do without, because it already has history results. Once the
+// call is past providerSoftTimeout the loader shows how long it has been
+// waiting and that Ctrl-C falls back; that Ctrl-C stops only the call,
+// which returns errProviderSkipped, and the caller shows what it has. A
+// second Ctrl-C ends ew as usual. provisional, the command the caller would
+// use without the call, is shown next to the loader meanwhile.
+func resolveProviderOrHistory(cfg config.Config, opts options, intent provider.Intent, prompt string, label string, provisional string) (provider.Resolution, string, error) {
+	if !loaderEnabled(opts) {
+		return resolveProviderWithLoader(invocationCtx, cfg, opts, intent, prompt, label)
+	}
+	defer exitIfInterrupted()
+
+	ctx, cancel := context.WithCancel(invocationCtx)
+	defer cancel()
+	var skipped atomic.Bool
+	skip := func() {
+		skipped.Store(true)
+		cancel()
+	}
+	status := &loaderStatus{provisional: provisional}
+	started := time.Now()
+	var mu sync.Mutex
+	finished := false
+	timer := time.AfterFunc(providerSoftTimeout, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if finished {
+			return
+		}
+		status.offerSkip(started, providerSkipHint)
+		softInterrupt.Store(&skip)
+	})
+
+	var (
+		resolution   provider.Resolution
+		providerName string
+		err          error
+	)
+	runWithLoader(label, status, func() {
+		resolution, providerName, err = resolveProvider(provider.WithPartialOutput(ctx, status.setPartial), cfg, opts, intent, prompt)
+	})
+	timer.Stop()
+	mu.Lock()
+	finished = true
+	softInterrupt.CompareAndSwap(&skip, nil)
+	mu.Unlock()
+
+	if skipped.Load() {
+		fmt.Fprintf(os.Stderr, "stopped waiting for the provider after %ds; showing history results\n", int(time.Since(started).Seconds()))
+		return provider.Resolution{}, "", errProviderSkipped
+	}
+	return resolution, providerName, err
+}
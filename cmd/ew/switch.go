@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/multiplexer"
+	"github.com/ashwch/ew/internal/router"
+)
+
+const maxSwitchListed = 8
+
+var (
+	switchPromptPattern   = regexp.MustCompile(`^(?:please\s+)?(?:switch|jump|go|attach)(?:\s+(?:back|over))?\s+(?:to|into)\s+(.+)$`)
+	openWorkspacePattern  = regexp.MustCompile(`^open\s+(?:my\s+|the\s+)?(.+?\s+(?:session|workspace|window))$`)
+	explicitSwitchPattern = regexp.MustCompile(`\b(?:tmux|wezterm|session|workspace|window)\b`)
+)
+
+// listSwitchTargets is swapped out in tests.
+var listSwitchTargets = multiplexer.List
+
+// parseSwitchPrompt returns what a "switch to my api project" prompt wants
+// to switch to.
+func parseSwitchPrompt(prompt string) (string, bool) {
+	low := strings.ToLower(strings.Join(strings.Fields(prompt), " "))
+	for _, pattern := range []*regexp.Regexp{switchPromptPattern, openWorkspacePattern} {
+		if m := pattern.FindStringSubmatch(low); m != nil && len(multiplexer.Terms(m[1])) > 0 {
+			return m[1], true
+		}
+	}
+	return "", false
+}
+
+// maybeHandleSwitchPrompt answers "switch to my api project" with the tmux
+// or wezterm command that jumps there. It only takes the prompt when a
+// running session matches, or when the prompt names a session or
+// workspace outright; "switch to the main branch" still goes to find.
+func maybeHandleSwitchPrompt(prompt string, cfg config.Config, opts options) bool {
+	subject, ok := parseSwitchPrompt(prompt)
+	if !ok {
+		return false
+	}
+	targets := listSwitchTargets(invocationCtx)
+	matches := multiplexer.Rank(subject, targets, multiplexer.InTmux())
+	if len(matches) == 0 {
+		if !explicitSwitchPattern.MatchString(subject) {
+			return false
+		}
+		beginSessionInteraction(prompt, router.IntentSwitch)
+		printResponse(noSwitchMatch(targets), opts.JSON)
+		return true
+	}
+
+	beginSessionInteraction(prompt, router.IntentSwitch)
+	best := matches[0]
+	reason := switchReason(best.Target)
+	if opts.Execute {
+		executeSuggested(best.Command, "", reason, "low", cfg, opts, router.IntentSwitch)
+		return true
+	}
+	if opts.JSON {
+		noteSessionSuggestion(best.Command, reason, best.Multiplexer)
+		printResponse(response{
+			Intent:  string(router.IntentSwitch),
+			Message: reason,
+			Command: best.Command,
+			Risk:    "low",
+			Results: matches,
+		}, true)
+		return true
+	}
+
+	alternative := ""
+	if len(matches) > 1 {
+		alternative = matches[1].Command
+	}
+	writeSuggestedCommandBlock(best.Command, reason, best.Multiplexer, alternative, cfg, opts)
+	if cfg.Find.OfferRun != offerRunNever {
+		offerToRun(cfg, opts, router.IntentSwitch)
+	}
+	return true
+}
+
+// switchReason says where the command lands, e.g. "tmux window api:2
+// (server) in ~/src/api".
+func switchReason(target multiplexer.Target) string {
+	kind := "session"
+	switch {
+	case target.Multiplexer == multiplexer.WezTerm:
+		kind = "workspace"
+	case target.Window >= 0:
+		kind = "window"
+	}
+	reason := fmt.Sprintf("%s %s %s", target.Multiplexer, kind, target.Name())
+	if target.Path != "" {
+		reason += " in " + displayPath(target.Path)
+	}
+	return reason
+}
+
+func noSwitchMatch(targets []multiplexer.Target) response {
+	payload := response{Intent: string(router.IntentSwitch)}
+	if len(targets) == 0 {
+		payload.Message = "no tmux sessions or wezterm workspaces are running"
+		return payload
+	}
+	payload.Message = "no running session matches; these are open:"
+	for _, target := range targets {
+		if len(payload.Suggestions) == maxSwitchListed {
+			break
+		}
+		if target.Window < 0 {
+			payload.Suggestions = append(payload.Suggestions, switchReason(target))
+		}
+	}
+	return payload
+}
+
+// displayPath shows a path under the home directory as ~/...
+func displayPath(path string) string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return path
+	}
+	home = filepath.Clean(home)
+	if path == home {
+		return "~"
+	}
+	if rest, ok := strings.CutPrefix(path, home+string(filepath.Separator)); ok {
+		return "~" + string(filepath.Separator) + rest
+	}
+	return path
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/multiplexer"
+)
+
+func TestParseSwitchPrompt(t *testing.T) {
+	cases := map[string]string{
+		"switch to my api project":  "my api project",
+		"Jump back to the web repo": "the web repo",
+		"open my notes workspace":   "notes workspace",
+	}
+	for prompt, want := range cases {
+		if got, ok := parseSwitchPrompt(prompt); !ok || got != want {
+			t.Fatalf("parseSwitchPrompt(%q) = %q, %v; want %q", prompt, got, ok, want)
+		}
+	}
+	for _, prompt := range []string{"how do I switch to zsh", "switch to my project", "open the file"} {
+		if got, ok := parseSwitchPrompt(prompt); ok {
+			t.Fatalf("expected %q not to be a switch prompt, got %q", prompt, got)
+		}
+	}
+}
+
+func TestSwitchPromptFallsThroughWithoutAMatchingSession(t *testing.T) {
+	previous := listSwitchTargets
+	t.Cleanup(func() { listSwitchTargets = previous })
+	listSwitchTargets = func(context.Context) []multiplexer.Target {
+		return []multiplexer.Target{{Multiplexer: multiplexer.Tmux, Session: "api", Window: -1, Path: "/src/api"}}
+	}
+	t.Setenv("TMUX", "")
+
+	if maybeHandleSwitchPrompt("switch to the main branch", config.Default(), options{JSON: true}) {
+		t.Fatalf("expected a prompt that matches no session to fall through to find")
+	}
+
+	var handled bool
+	output := captureStdout(t, func() {
+		handled = maybeHandleSwitchPrompt("switch to my api project", config.Default(), options{JSON: true})
+	})
+	if !handled {
+		t.Fatalf("expected the api session to be offered")
+	}
+	var payload response
+	if err := json.Unmarshal([]byte(output), &payload); err != nil {
+		t.Fatalf("invalid JSON %q: %v", output, err)
+	}
+	if payload.Intent != "switch" || payload.Command != "tmux attach-session -t api" {
+		t.Fatalf("unexpected payload %+v", payload)
+	}
+
+	payload = response{}
+	output = captureStdout(t, func() {
+		handled = maybeHandleSwitchPrompt("switch to my billing session", config.Default(), options{JSON: true})
+	})
+	if !handled || json.Unmarshal([]byte(output), &payload) != nil || payload.Command != "" || len(payload.Suggestions) != 1 {
+		t.Fatalf("expected an explicit session request to list what is open, got %q", output)
+	}
+}
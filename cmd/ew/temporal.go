@@ -0,0 +1,48 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/history"
+	"github.com/ashwch/ew/internal/hook"
+	"github.com/ashwch/ew/internal/usage"
+)
+
+// applyTemporalBoost adds the opt-in time-of-day and weekday signals
+// (find.temporal_boost) to history matches, records each contribution in
+// the match's score breakdown, and re-sorts.
+func applyTemporalBoost(matches []history.Match, cfg config.Config, now time.Time) []history.Match {
+	if !cfg.Find.TemporalBoost || len(matches) == 0 {
+		return matches
+	}
+	events, err := hook.RecentEvents(frequentEventWindow)
+	if err != nil || len(events) == 0 {
+		return matches
+	}
+	return boostMatches(matches, usage.NewTemporalProfile(events, now.Location()), now)
+}
+
+func boostMatches(matches []history.Match, profile usage.TemporalProfile, now time.Time) []history.Match {
+	for idx := range matches {
+		hour, weekday := profile.Boost(matches[idx].Command, now)
+		if hour == 0 && weekday == 0 {
+			continue
+		}
+		if matches[idx].Signals == nil {
+			matches[idx].Signals = map[string]float64{"text": matches[idx].Score}
+		}
+		if hour > 0 {
+			matches[idx].Signals["time_of_day"] = hour
+		}
+		if weekday > 0 {
+			matches[idx].Signals["weekday"] = weekday
+		}
+		matches[idx].Score += hour + weekday
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+	return matches
+}
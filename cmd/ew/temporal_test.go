@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ashwch/ew/internal/history"
+	"github.com/ashwch/ew/internal/hook"
+	"github.com/ashwch/ew/internal/usage"
+)
+
+func TestBoostMatchesReordersAndRecordsBreakdown(t *testing.T) {
+	now := time.Date(2026, 3, 6, 15, 0, 0, 0, time.UTC)
+	var events []hook.Event
+	for week := 1; week <= 4; week++ {
+		events = append(events, hook.Event{Command: "make deploy", Timestamp: now.AddDate(0, 0, -7*week).Format(time.RFC3339)})
+	}
+	profile := usage.NewTemporalProfile(events, time.UTC)
+
+	matches := boostMatches([]history.Match{
+		{Command: "make build", Score: 9},
+		{Command: "make deploy", Score: 8},
+	}, profile, now)
+	if matches[0].Command != "make deploy" {
+		t.Fatalf("expected habitual command to rank first, got %+v", matches)
+	}
+	if got := matches[0].Breakdown(); got != "text 8.00, time_of_day +3.00, weekday +2.00" {
+		t.Fatalf("unexpected breakdown %q", got)
+	}
+	if matches[1].Signals != nil {
+		t.Fatalf("expected untouched match to have no breakdown")
+	}
+}
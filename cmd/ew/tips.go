@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/router"
+	"github.com/ashwch/ew/internal/session"
+	"github.com/ashwch/ew/internal/tips"
+	"github.com/ashwch/ew/internal/ui"
+)
+
+// tipJournalWindow is how much of the session journal the tips look at.
+const tipJournalWindow = 50
+
+// showTip returns the exit step that prints at most one tip a day after an
+// answer. It runs before the interaction is flushed, so the answer just
+// given counts too. Nothing is printed for --json or --quiet, off a
+// terminal, or after Ctrl-C.
+func showTip(cfg config.Config, opts options) func() {
+	if !cfg.Tips.Enabled || tips.Disabled() || opts.JSON || opts.Quiet || !isTerminal(os.Stderr) {
+		return nil
+	}
+	return func() {
+		interruptMu.Lock()
+		interrupted := interruptCode != 0
+		interruptMu.Unlock()
+		if interrupted || runtimeInteraction == nil {
+			return
+		}
+		st, err := tips.Load()
+		if err != nil {
+			return
+		}
+		interactions, _ := session.Recent(tipJournalWindow)
+		signals := tips.Signals{
+			Interactions: append(interactions, *runtimeInteraction),
+			HooksLoaded:  strings.TrimSpace(os.Getenv("EW_SESSION_ID")) != "",
+		}
+		now := time.Now().UTC()
+		tip, ok := tips.Pick(signals, st, now)
+		if !ok {
+			return
+		}
+		st.LastShown = now.Format(time.RFC3339)
+		if tips.Save(st) != nil {
+			return
+		}
+		fmt.Fprintln(os.Stderr, ui.ASCII("tip: "+tip.Text))
+		fmt.Fprintf(os.Stderr, "     (ew --dismiss-tip %s hides it, --dismiss-tip all hides every tip)\n", tip.ID)
+	}
+}
+
+// handleDismissTip hides one tip, or all of them, for good. parseArgs has
+// already checked id.
+func handleDismissTip(id string, opts options) {
+	payload := response{Intent: string(router.IntentTipDismiss)}
+	st, err := tips.Load()
+	if err == nil {
+		err = st.Dismiss(id)
+	}
+	if err == nil {
+		err = tips.Save(st)
+	}
+	if err != nil {
+		payload.Message = fmt.Sprintf("could not dismiss tip: %v", err)
+		printResponse(payload, opts.JSON)
+		exitWith(exitFailure)
+	}
+	payload.Message = fmt.Sprintf("tip %s dismissed", id)
+	if id == tips.DismissAll {
+		payload.Message = "all tips dismissed"
+	}
+	printResponse(payload, opts.JSON)
+}
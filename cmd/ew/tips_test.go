@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ashwch/ew/internal/config"
+)
+
+func TestParseArgsDismissTip(t *testing.T) {
+	opts, _, err := parseArgs([]string{"--dismiss-tip", " Memory "})
+	if err != nil || opts.DismissTip != "memory" {
+		t.Fatalf("unexpected parse result %+v %v", opts, err)
+	}
+	if _, _, err := parseArgs([]string{"--dismiss-tip", "everything"}); err == nil {
+		t.Fatalf("expected an unknown tip to fail")
+	}
+}
+
+func TestShowTipIsOffForScriptsAndTheConfigSwitch(t *testing.T) {
+	cfg := config.Default()
+	cfg.Tips.Enabled = false
+	if showTip(cfg, options{}) != nil {
+		t.Fatalf("expected tips.enabled = false to turn tips off")
+	}
+	cfg.Tips.Enabled = true
+	if showTip(cfg, options{JSON: true}) != nil || showTip(cfg, options{Quiet: true}) != nil {
+		t.Fatalf("expected no tips with --json or --quiet")
+	}
+}
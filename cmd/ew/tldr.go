@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	goruntime "runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/router"
+	"github.com/ashwch/ew/internal/tldr"
+)
+
+const (
+	tldrUpdateTimeout    = 2 * time.Minute
+	tldrGroundingLimit   = 4
+	tldrMinFallbackScore = 6
+)
+
+// runtimeTLDREnabled mirrors tldr.enabled for the prompt builders.
+var runtimeTLDREnabled = false
+
+var (
+	tldrPagesOnce sync.Once
+	tldrPages     []tldr.Page
+)
+
+// loadTLDRPages is swapped out in tests.
+var loadTLDRPages = func() []tldr.Page {
+	pages, _ := tldr.LoadPages(tldr.Platform(goruntime.GOOS))
+	return pages
+}
+
+// tldrMatches searches the cached pages for query, preferring this
+// platform's pages. It is empty when tldr is disabled or not downloaded.
+func tldrMatches(query string, limit int) []tldr.Match {
+	if !runtimeTLDREnabled {
+		return nil
+	}
+	tldrPagesOnce.Do(func() {
+		tldrPages = loadTLDRPages()
+	})
+	return tldr.Search(tldrPages, query, limit)
+}
+
+// tldrGrounding lists a few matching tldr examples for provider prompts so
+// answers lean on documented flags for this platform.
+func tldrGrounding(query string) string {
+	matches := tldrMatches(query, tldrGroundingLimit)
+	if len(matches) == 0 {
+		return ""
+	}
+	lines := make([]string, 0, len(matches))
+	for _, match := range matches {
+		lines = append(lines, fmt.Sprintf("- %s (%s/%s): %s", match.Description, match.Platform, match.Page, match.Command))
+	}
+	return fmt.Sprintf(" Reference examples from tldr pages for %s ({{...}} are placeholders):\n%s", tldr.Platform(goruntime.GOOS), strings.Join(lines, "\n"))
+}
+
+// suggestFromTLDR answers a find query from tldr when history and the
+// providers had nothing. It reports false when no example is close enough.
+func suggestFromTLDR(query string, cfg config.Config, opts options) bool {
+	payload, ok := tldrFallback(query)
+	if !ok {
+		return false
+	}
+	if opts.JSON {
+		noteSessionSuggestion(payload.Command, payload.Suggestions[0], "tldr")
+		printResponse(payload, true)
+		return true
+	}
+	printSuggestedCommandBlock(payload.Command, reasonForDisplay(payload.Suggestions[0], opts), "tldr", cfg, opts)
+	return true
+}
+
+// tldrFallback is the find response for the best tldr example, with its
+// reason as the only suggestion.
+func tldrFallback(query string) (response, bool) {
+	matches := tldrMatches(query, 1)
+	if len(matches) == 0 || matches[0].Score < tldrMinFallbackScore {
+		return response{}, false
+	}
+	top := matches[0]
+	reason := fmt.Sprintf("tldr %s example: %s", top.Page, top.Description)
+	if placeholders := tldr.Placeholders(top.Command); len(placeholders) > 0 {
+		reason += fmt.Sprintf("; replace %s before running", strings.Join(renderedPlaceholders(placeholders), ", "))
+	}
+	return response{
+		Intent:      string(router.IntentFind),
+		Message:     "tldr example",
+		Command:     tldr.Render(top.Command),
+		Risk:        "low",
+		Results:     matches,
+		Suggestions: []string{reason},
+	}, true
+}
+
+func renderedPlaceholders(names []string) []string {
+	rendered := make([]string, 0, len(names))
+	for _, name := range names {
+		rendered = append(rendered, "<"+name+">")
+	}
+	return rendered
+}
+
+// handleTLDRUpdate downloads the tldr pages and turns the integration on,
+// since asking for the pages is the opt-in.
+func handleTLDRUpdate(cfg config.Config, cfgPath string, opts options) {
+	ctx, cancel := context.WithTimeout(invocationCtx, tldrUpdateTimeout)
+	defer cancel()
+	var result tldr.UpdateResult
+	var err error
+	withEWLoader(opts, "fetching tldr pages", func() {
+		result, err = tldr.Update(ctx, tldr.DefaultArchiveURL)
+	})
+	if err != nil {
+		printResponse(response{Intent: string(router.IntentTLDRUpdate), Message: err.Error()}, opts.JSON)
+		exitWith(1)
+	}
+	message := fmt.Sprintf("installed %d tldr pages in %s", result.Pages, result.Location)
+	if !cfg.TLDR.Enabled {
+		cfg.TLDR.Enabled = true
+		if err := saveGlobalConfigChanges(cfgPath, map[string]string{"tldr.enabled": "true"}); err != nil {
+			message += fmt.Sprintf(" (could not enable tldr.enabled: %v)", err)
+		} else {
+			message += "; tldr.enabled = true"
+		}
+	}
+	printResponse(response{Intent: string(router.IntentTLDRUpdate), Message: message, ConfigPath: cfgPath}, opts.JSON)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/tldr"
+)
+
+func useTLDRPages(t *testing.T, pages ...tldr.Page) {
+	t.Helper()
+	originalLoad, originalEnabled := loadTLDRPages, runtimeTLDREnabled
+	loadTLDRPages = func() []tldr.Page { return pages }
+	runtimeTLDREnabled = true
+	tldrPagesOnce = sync.Once{}
+	t.Cleanup(func() {
+		loadTLDRPages, runtimeTLDREnabled = originalLoad, originalEnabled
+		tldrPagesOnce = sync.Once{}
+		tldrPages = nil
+	})
+}
+
+func TestSuggestFromTLDRRendersPlaceholders(t *testing.T) {
+	useTLDRPages(t, tldr.ParsePage("tar", "common", "# tar\n\n- E[x]tract an archive [f]ile:\n\n`tar xf {{path/to/source.tar}}`\n"))
+
+	out := captureStdout(t, func() {
+		if !suggestFromTLDR("tar extract examples", config.Default(), options{JSON: true}) {
+			t.Fatalf("expected a tldr suggestion")
+		}
+	})
+	var payload response
+	if err := json.Unmarshal([]byte(out), &payload); err != nil {
+		t.Fatalf("invalid JSON %q: %v", out, err)
+	}
+	if payload.Command != "tar xf <path/to/source.tar>" {
+		t.Fatalf("expected rendered placeholder, got %q", payload.Command)
+	}
+	if len(payload.Suggestions) != 1 || !strings.Contains(payload.Suggestions[0], "replace <path/to/source.tar>") {
+		t.Fatalf("expected placeholder note, got %v", payload.Suggestions)
+	}
+	if suggestFromTLDR("rotate kubernetes secrets", config.Default(), options{JSON: true}) {
+		t.Fatalf("expected no suggestion for an unrelated query")
+	}
+}
+
+func TestBuildFindPromptGroundsOnTLDRWhenEnabled(t *testing.T) {
+	useTLDRPages(t, tldr.ParsePage("tar", "common", "# tar\n\n- E[x]tract an archive [f]ile:\n\n`tar xf {{path/to/source.tar}}`\n"))
+	prompt := buildFindPrompt(config.Default(), "extract a tar archive", nil)
+	if !strings.Contains(prompt, "Reference examples from tldr pages") || !strings.Contains(prompt, "tar xf {{path/to/source.tar}}") {
+		t.Fatalf("expected tldr grounding in prompt, got %q", prompt)
+	}
+
+	runtimeTLDREnabled = false
+	if strings.Contains(buildFindPrompt(config.Default(), "extract a tar archive", nil), "Reference examples from tldr pages") {
+		t.Fatalf("expected no grounding when tldr is disabled")
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/history"
+	"github.com/ashwch/ew/internal/provider"
+	ewrt "github.com/ashwch/ew/internal/runtime"
+)
+
+// knownToolAlternatives names what a preferred tool usually replaces, so
+// "always prefer docker compose" needs no "instead of".
+var knownToolAlternatives = map[string]string{
+	"docker compose": "docker-compose",
+	"eza":            "ls",
+	"rg":             "grep",
+	"ripgrep":        "grep",
+	"fd":             "find",
+	"bat":            "cat",
+	"podman":         "docker",
+	"nvim":           "vim",
+}
+
+var (
+	toolPreferencePromptRegex = regexp.MustCompile(`^(?:(?:please|always|only|from now on),?\s+)*(?:use|prefer)\s+([a-z0-9][a-z0-9._+-]*(?:\s+[a-z0-9][a-z0-9._+-]*){0,2}?)(?:,?\s+(?:instead of|rather than|over|not)\s+([a-z0-9][a-z0-9._+-]*(?:\s+[a-z0-9][a-z0-9._+-]*)?))?(?:\s+(?:from now on|always|please|and save))*$`)
+	toolVersionSuffixRegex    = regexp.MustCompile(`\s+v\d+$`)
+)
+
+// parsePromptToolPreference reads "use eza instead of ls", "only use docker
+// compose, not docker-compose", or "always prefer docker compose v2" as a
+// tools.prefer rule. The whole prompt has to be the preference, so a find
+// request that merely mentions one tool over another is not swallowed.
+func parsePromptToolPreference(low string) (string, string, bool) {
+	match := toolPreferencePromptRegex.FindStringSubmatch(strings.TrimSpace(strings.TrimSuffix(low, ".")))
+	if match == nil {
+		return "", "", false
+	}
+	prefer := toolVersionSuffixRegex.ReplaceAllString(match[1], "")
+	avoid := match[2]
+	if avoid == "" {
+		avoid = knownToolAlternatives[prefer]
+	}
+	if avoid == "" || avoid == prefer {
+		return "", "", false
+	}
+	return avoid, prefer, true
+}
+
+// preferToolsInResolution rewrites a provider suggestion to the tools the
+// person prefers. With --verbose the reason also carries the original.
+func preferToolsInResolution(resolution provider.Resolution, prefer map[string]string, opts options) provider.Resolution {
+	command := strings.TrimSpace(resolution.Command)
+	rewritten, ok := ewrt.PreferTools(command, prefer)
+	if !ok {
+		return resolution
+	}
+	resolution.Command = rewritten
+	if opts.Verbose {
+		resolution.Reason = strings.TrimSpace(resolution.Reason + fmt.Sprintf("\n\nRewritten for your tool preferences from: `%s`", command))
+	}
+	return resolution
+}
+
+// preferToolsInMatches rewrites history matches to the preferred tools and
+// drops any that then repeat a better-ranked match.
+func preferToolsInMatches(matches []history.Match, prefer map[string]string) []history.Match {
+	if len(prefer) == 0 {
+		return matches
+	}
+	return rewriteMatches(matches, func(command string) (string, bool) {
+		return ewrt.PreferTools(command, prefer)
+	})
+}
+
+// toolFlagsInMatches appends the [tools.<name>] flags to history matches,
+// as toolFlagsInResolution does to a provider suggestion.
+func toolFlagsInMatches(matches []history.Match, groups map[string]config.ToolGroup) []history.Match {
+	flags := toolFlags(groups)
+	if len(flags) == 0 {
+		return matches
+	}
+	return rewriteMatches(matches, func(command string) (string, bool) {
+		return ewrt.AppendToolFlags(command, flags)
+	})
+}
+
+func rewriteMatches(matches []history.Match, rewrite func(string) (string, bool)) []history.Match {
+	if len(matches) == 0 {
+		return matches
+	}
+	seen := map[string]bool{}
+	out := make([]history.Match, 0, len(matches))
+	for _, match := range matches {
+		if rewritten, ok := rewrite(match.Command); ok {
+			match.Command = rewritten
+		}
+		key := normalizeComparableCommand(match.Command)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, match)
+	}
+	return out
+}
+
+// toolFlagsInResolution appends the preferred flags of the tools a provider
+// suggestion runs, such as --profile staging for aws.
+func toolFlagsInResolution(resolution provider.Resolution, groups map[string]config.ToolGroup, opts options) provider.Resolution {
+	command := strings.TrimSpace(resolution.Command)
+	rewritten, ok := ewrt.AppendToolFlags(command, toolFlags(groups))
+	if !ok {
+		return resolution
+	}
+	resolution.Command = rewritten
+	if opts.Verbose {
+		resolution.Reason = strings.TrimSpace(resolution.Reason + fmt.Sprintf("\n\nFlags from your tool settings added to: `%s`", command))
+	}
+	return resolution
+}
+
+// toolFlags maps each tool whose group sets flags to them.
+func toolFlags(groups map[string]config.ToolGroup) map[string]string {
+	flags := map[string]string{}
+	for name, group := range groups {
+		if group.Flags != "" {
+			flags[name] = group.Flags
+		}
+	}
+	return flags
+}
+
+// toolGroupConfirm returns the first tool command runs whose group asks to
+// confirm every command.
+func toolGroupConfirm(cfg config.Config, command string) (string, bool) {
+	for _, tool := range ewrt.CommandTools(command) {
+		if group, ok := cfg.ToolGroupFor(tool); ok && group.Confirm {
+			return tool, true
+		}
+	}
+	return "", false
+}
+
+// toolNotesPrompt gives providers the notes and flags of the tools task
+// names, so "list buckets" with aws among its candidates gets the aws notes.
+func toolNotesPrompt(groups map[string]config.ToolGroup, task string) string {
+	if len(groups) == 0 {
+		return ""
+	}
+	words := map[string]bool{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(task), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_')
+	}) {
+		words[word] = true
+	}
+	lines := []string{}
+	for name, group := range groups {
+		if !words[name] || group.Notes == "" && group.Flags == "" {
+			continue
+		}
+		line := "- " + name + ":"
+		if group.Notes != "" {
+			line += " " + group.Notes
+		}
+		if group.Flags != "" {
+			line += fmt.Sprintf(" (always pass `%s`)", group.Flags)
+		}
+		lines = append(lines, line)
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+// toolPreferencesPrompt tells providers about tools.prefer up front, so the
+// rewrite is a safety net rather than the only guard.
+func toolPreferencesPrompt(prefer map[string]string) string {
+	if len(prefer) == 0 {
+		return ""
+	}
+	lines := make([]string, 0, len(prefer))
+	for avoid, replacement := range prefer {
+		lines = append(lines, fmt.Sprintf("- use `%s` instead of `%s`", replacement, avoid))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/history"
+)
+
+func TestParsePromptToolPreference(t *testing.T) {
+	cases := []struct {
+		prompt string
+		avoid  string
+		prefer string
+	}{
+		{"use eza instead of ls", "ls", "eza"},
+		{"only use docker compose, not docker-compose", "docker-compose", "docker compose"},
+		{"always prefer docker compose v2", "docker-compose", "docker compose"},
+		{"prefer rg over grep from now on", "grep", "rg"},
+	}
+	for _, tc := range cases {
+		avoid, prefer, ok := parsePromptToolPreference(tc.prompt)
+		if !ok || avoid != tc.avoid || prefer != tc.prefer {
+			t.Fatalf("parsePromptToolPreference(%q) = %q, %q, %v", tc.prompt, avoid, prefer, ok)
+		}
+	}
+	for _, prompt := range []string{
+		"find large files using fd instead of find in my home directory",
+		"use ripgrep instead of grep to search for todo comments",
+		"prefer something nice",
+	} {
+		if _, _, ok := parsePromptToolPreference(prompt); ok {
+			t.Fatalf("expected %q not to be read as a tool preference", prompt)
+		}
+	}
+}
+
+func TestSelfPromptSavesToolPreference(t *testing.T) {
+	action, ok := parseSelfPromptAction("use eza instead of ls")
+	if !ok || action.Kind != selfActionConfigSet {
+		t.Fatalf("expected a config change, got %+v (ok=%v)", action, ok)
+	}
+	if got := action.Changes["tools.prefer.ls"]; got != "eza" {
+		t.Fatalf("expected tools.prefer.ls=eza, got %q", got)
+	}
+	if !action.Persist {
+		t.Fatalf("expected a tool preference to be saved")
+	}
+}
+
+func TestPreferToolsInMatchesRewritesAndDedupes(t *testing.T) {
+	matches := []history.Match{
+		{Command: "docker compose up", Score: 30},
+		{Command: "docker-compose up", Score: 20},
+		{Command: "docker-compose logs -f", Score: 10},
+	}
+	got := preferToolsInMatches(matches, map[string]string{"docker-compose": "docker compose"})
+	if len(got) != 2 || got[0].Command != "docker compose up" || got[1].Command != "docker compose logs -f" {
+		t.Fatalf("unexpected matches %+v", got)
+	}
+}
+
+func TestToolGroupsApplyToMatchesPromptsAndPolicy(t *testing.T) {
+	groups := map[string]config.ToolGroup{
+		"aws":     {Flags: "--profile staging", Notes: "buckets live in eu-west-1"},
+		"kubectl": {Confirm: true},
+	}
+	matches := toolFlagsInMatches([]history.Match{
+		{Command: "aws s3 ls --profile staging", Score: 30},
+		{Command: "aws s3 ls", Score: 20},
+		{Command: "kubectl get pods", Score: 10},
+	}, groups)
+	if len(matches) != 2 || matches[0].Command != "aws s3 ls --profile staging" || matches[1].Command != "kubectl get pods" {
+		t.Fatalf("expected flags appended and the repeat dropped, got %+v", matches)
+	}
+
+	notes := toolNotesPrompt(groups, "1) aws s3 ls (score=0.80)")
+	if !strings.Contains(notes, "aws: buckets live in eu-west-1 (always pass `--profile staging`)") {
+		t.Fatalf("expected the aws notes, got %q", notes)
+	}
+	if toolNotesPrompt(groups, "list pods with awsome names") != "" {
+		t.Fatalf("expected no notes for a task that does not name a tool")
+	}
+
+	cfg := config.Default()
+	cfg.Tools.Groups = groups
+	if mode, _ := applyExecutionRiskPolicy(cfg, "yolo", "kubectl get pods | grep api", "low"); mode != "confirm" {
+		t.Fatalf("expected kubectl to need a confirmation, got %q", mode)
+	}
+	if mode, _ := applyExecutionRiskPolicy(cfg, "yolo", "aws s3 ls", "low"); mode != "yolo" {
+		t.Fatalf("expected other tools to keep yolo, got %q", mode)
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/hook"
+	"github.com/ashwch/ew/internal/memory"
+	"github.com/ashwch/ew/internal/session"
+	"github.com/ashwch/ew/internal/ui"
+	"github.com/ashwch/ew/internal/usage"
+)
+
+const (
+	topEventWindow = 5000
+	topRows        = 10
+)
+
+// statsPrompts are the whole prompts that open the usage dashboard: `ew top`,
+// `ew stats`, and their longer forms. --top does the same.
+var statsPrompts = map[string]bool{
+	"top":              true,
+	"show top":         true,
+	"stats":            true,
+	"statistics":       true,
+	"show stats":       true,
+	"show statistics":  true,
+	"show my stats":    true,
+	"my stats":         true,
+	"usage stats":      true,
+	"usage statistics": true,
+}
+
+// providerStatsPrompts are the whole prompts that show only the provider
+// sections of the dashboard: latency and the confidence calibration table.
+var providerStatsPrompts = map[string]bool{
+	"stats providers":      true,
+	"provider stats":       true,
+	"providers stats":      true,
+	"show provider stats":  true,
+	"provider statistics":  true,
+	"show stats providers": true,
+}
+
+func isStatsPrompt(prompt string) bool {
+	return statsPrompts[wholePrompt(prompt)]
+}
+
+func isProviderStatsPrompt(prompt string) bool {
+	return providerStatsPrompts[wholePrompt(prompt)]
+}
+
+func wholePrompt(prompt string) string {
+	low := strings.ToLower(strings.Join(strings.Fields(prompt), " "))
+	return strings.TrimRight(low, ".!?")
+}
+
+// handleTop shows the usage dashboard. It only reads local stores; a store
+// that cannot be read just leaves its section empty.
+func handleTop(cfg config.Config, opts options) {
+	report := buildUsageReport()
+	if opts.JSON {
+		encoded, _ := marshalOutput(report)
+		fmt.Println(string(encoded))
+		return
+	}
+	showSections(topSections(report), cfg, opts)
+}
+
+// handleProviderStats answers `ew stats providers`: provider latency and
+// the per-provider confidence calibration table. --json prints both.
+func handleProviderStats(cfg config.Config, opts options) {
+	report := buildUsageReport()
+	if opts.JSON {
+		encoded, _ := marshalOutput(struct {
+			ProviderLatency usage.LatencySummary `json:"provider_latency"`
+			Calibration     usage.Calibration    `json:"calibration"`
+		}{report.ProviderLatency, report.Calibration})
+		fmt.Println(string(encoded))
+		return
+	}
+	showSections(providerSections(report), cfg, opts)
+}
+
+func buildUsageReport() usage.Report {
+	events, _ := hook.RecentEvents(topEventWindow)
+	store, _, _ := memory.Load()
+	interactions, _ := session.Recent(0)
+	return usage.Build(usage.Sources{Events: events, Memory: store, Interactions: interactions}, time.Now(), topRows)
+}
+
+// showSections pages through sections in the dashboard TUI, or prints them.
+func showSections(sections []ui.ReplayStep, cfg config.Config, opts options) {
+	backend := effectiveUIBackend(cfg, opts)
+	if canUseInteractiveUI(opts, backend) {
+		used, uiErr := ui.ShowDashboard(backend, sections)
+		if uiErr == nil && used {
+			return
+		}
+		if uiErr != nil {
+			fmt.Fprintf(os.Stderr, "ew: dashboard ui failed (%v); printing summary instead\n", uiErr)
+		}
+	}
+	for idx, section := range sections {
+		if idx > 0 {
+			fmt.Println()
+		}
+		fmt.Println(section.Title)
+		for _, line := range section.Lines {
+			fmt.Println("  " + line)
+		}
+	}
+}
+
+func topSections(report usage.Report) []ui.ReplayStep {
+	commands := countLines(report.TopCommands, "No captured commands yet. Install hooks with `ew --setup-hooks`.")
+	failures := countLines(report.TopFailures, "No failed commands captured.")
+	suggestions := countLines(report.TopSuggestions, "No suggestions run yet.")
+
+	memoryLines := []string{}
+	for idx, item := range report.TopMemory {
+		memoryLines = append(memoryLines, fmt.Sprintf("%2d. %3d uses  %s  ->  %s", idx+1, item.Uses, item.Query, item.Command))
+	}
+	if len(memoryLines) == 0 {
+		memoryLines = append(memoryLines, "Memory is empty.")
+	}
+	if hits := report.MemoryHits; hits.Total > 0 {
+		memoryLines = append(memoryLines, fmt.Sprintf("hit rate: %.0f%% of %d answers came from memory", hits.Rate*100, hits.Total))
+	}
+
+	attempts := make([]int64, 0, len(report.FixSuccess))
+	successes := make([]int64, 0, len(report.FixSuccess))
+	for _, day := range report.FixSuccess {
+		attempts = append(attempts, int64(day.Attempts))
+		successes = append(successes, int64(day.Successes))
+	}
+	fixLines := []string{fmt.Sprintf("last %d days", usage.FixWindowDays)}
+	if report.FixAttempts == 0 {
+		fixLines = append(fixLines, "No executed fixes yet.")
+	} else {
+		fixLines = append(fixLines,
+			fmt.Sprintf("success rate: %.0f%% of %d executed fixes", report.FixSuccessRate*100, report.FixAttempts),
+			"attempts:  "+ui.Sparkline(attempts),
+			"succeeded: "+ui.Sparkline(successes),
+		)
+	}
+	if accepted := report.FixAcceptance; accepted.Total > 0 {
+		fixLines = append(fixLines, fmt.Sprintf("accepted: %.0f%% of %d suggested fixes were run (all time)", accepted.Rate*100, accepted.Total))
+	}
+
+	return append([]ui.ReplayStep{
+		{Title: "Most frequent commands", Lines: commands},
+		{Title: "Most failing commands", Lines: failures},
+		{Title: "Most used suggestions", Lines: suggestions},
+		{Title: "Most used memory entries", Lines: memoryLines},
+		{Title: "Fix success", Lines: fixLines},
+	}, providerSections(report)...)
+}
+
+// providerSections are the dashboard's provider latency and confidence
+// calibration, which `ew stats providers` shows on their own.
+func providerSections(report usage.Report) []ui.ReplayStep {
+	latency := report.ProviderLatency
+	latencyLines := []string{}
+	if latency.Samples == 0 {
+		latencyLines = append(latencyLines, "No provider calls recorded yet.")
+	} else {
+		latencyLines = append(latencyLines,
+			fmt.Sprintf("median %s, p90 %s, p95 %s, p99 %s over %d calls",
+				formatLatency(latency.MedianMS), formatLatency(latency.P90MS), formatLatency(latency.P95MS), formatLatency(latency.P99MS), latency.Samples),
+			"recent: "+ui.Sparkline(latency.RecentMS),
+		)
+	}
+	return []ui.ReplayStep{
+		{Title: "Provider latency", Lines: latencyLines},
+		{Title: "Provider confidence calibration", Lines: calibrationLines(report.Calibration)},
+	}
+}
+
+// countLines numbers counted commands, or holds the empty note when there
+// are none.
+func countLines(counts []usage.CommandCount, empty string) []string {
+	if len(counts) == 0 {
+		return []string{empty}
+	}
+	lines := make([]string, 0, len(counts))
+	for idx, item := range counts {
+		lines = append(lines, fmt.Sprintf("%2d. %4dx  %s", idx+1, item.Count, item.Command))
+	}
+	return lines
+}
+
+// calibrationLines is one row per provider and band of stated confidence:
+// what the provider said, how its answers went, and the confidence ew uses
+// instead.
+func calibrationLines(calibration usage.Calibration) []string {
+	if len(calibration.Providers) == 0 {
+		return []string{"No provider answers with a known outcome yet."}
+	}
+	lines := []string{"stated      answers  ran  worked  said -> calibrated"}
+	for _, provider := range calibration.Providers {
+		lines = append(lines, fmt.Sprintf("%s (%d answers)", provider.Provider, provider.Answers))
+		for _, band := range provider.Bands {
+			lines = append(lines, fmt.Sprintf("%.2f-%.2f  %7d  %3d  %6d  %.2f -> %.2f", band.Low, band.High, band.Answers, band.Accepted, band.Succeeded, band.Stated, band.Calibrated))
+		}
+	}
+	return lines
+}
+
+func formatLatency(ms int64) string {
+	return (time.Duration(ms) * time.Millisecond).Round(10 * time.Millisecond).String()
+}
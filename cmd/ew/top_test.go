@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ashwch/ew/internal/usage"
+)
+
+func TestStatsPromptsOpenTheDashboard(t *testing.T) {
+	for _, prompt := range []string{"top", "stats", "Show  stats", "statistics?"} {
+		if !isStatsPrompt(prompt) {
+			t.Fatalf("expected %q to open the dashboard", prompt)
+		}
+	}
+	for _, prompt := range []string{"stats for nginx", "docker stats", "top memory users"} {
+		if isStatsPrompt(prompt) {
+			t.Fatalf("expected %q to stay a request", prompt)
+		}
+	}
+}
+
+func TestStatsProvidersPromptShowsOnlyProviderSections(t *testing.T) {
+	if !isProviderStatsPrompt("Stats  providers") || !isProviderStatsPrompt("provider stats?") {
+		t.Fatalf("expected stats providers to open the provider view")
+	}
+	if isStatsPrompt("stats providers") || isProviderStatsPrompt("stats providers for aws") {
+		t.Fatalf("expected stats providers to be its own prompt")
+	}
+	sections := providerSections(usage.Report{})
+	if len(sections) != 2 || sections[1].Title != "Provider confidence calibration" {
+		t.Fatalf("expected latency and calibration, got %+v", sections)
+	}
+}
+
+func TestTopSectionsShowRatesOnlyWithData(t *testing.T) {
+	sections := topSections(usage.Report{
+		TopFailures:   []usage.CommandCount{{Command: "make test", Count: 3}},
+		MemoryHits:    usage.Rate{Count: 1, Total: 4, Rate: 0.25},
+		FixAcceptance: usage.Rate{},
+	})
+	text := map[string]string{}
+	for _, section := range sections {
+		text[section.Title] = strings.Join(section.Lines, "\n")
+	}
+	if !strings.Contains(text["Most failing commands"], "3x  make test") {
+		t.Fatalf("expected the failing command, got %q", text["Most failing commands"])
+	}
+	if !strings.Contains(text["Most used memory entries"], "hit rate: 25% of 4 answers") {
+		t.Fatalf("expected the memory hit rate, got %q", text["Most used memory entries"])
+	}
+	if strings.Contains(text["Fix success"], "accepted") || text["Most used suggestions"] != "No suggestions run yet." {
+		t.Fatalf("expected empty rates to stay out: %q", text)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ashwch/ew/internal/appdirs"
+	"github.com/ashwch/ew/internal/provider"
+)
+
+const (
+	traceFileName = "trace.jsonl"
+	// traceMaxBytes caps the trace file; a larger one is started over
+	// rather than appended to.
+	traceMaxBytes = 4 << 20
+)
+
+// runtimeTracer records provider resolutions when EW_TRACE is set; nil
+// otherwise.
+var runtimeTracer *provider.Tracer
+
+func traceEnabled() bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("EW_TRACE"))) {
+	case "1", "on", "true", "yes":
+		return true
+	}
+	return false
+}
+
+// openTrace starts the EW_TRACE file in the state dir and returns the step
+// that closes it, or nil when tracing is off or the file cannot be opened.
+func openTrace(opts options) func() {
+	if !traceEnabled() {
+		return nil
+	}
+	dir, err := appdirs.EnsureStateDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ew: EW_TRACE ignored: %v\n", err)
+		return nil
+	}
+	path := filepath.Join(dir, traceFileName)
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if info, err := os.Stat(path); err == nil && info.Size() > traceMaxBytes {
+		flags |= os.O_TRUNC
+	}
+	file, err := os.OpenFile(path, flags, 0o600)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ew: EW_TRACE ignored: could not open %s: %v\n", path, err)
+		return nil
+	}
+	if !opts.JSON && !opts.Quiet {
+		fmt.Fprintf(os.Stderr, "ew: tracing provider calls to %s\n", path)
+	}
+	runtimeTracer = provider.NewTracer(file)
+	return func() {
+		runtimeTracer = nil
+		_ = file.Close()
+	}
+}
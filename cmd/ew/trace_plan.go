@@ -0,0 +1,396 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ashwch/ew/internal/cache"
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/history"
+	"github.com/ashwch/ew/internal/hook"
+	"github.com/ashwch/ew/internal/memory"
+	"github.com/ashwch/ew/internal/multiplexer"
+	"github.com/ashwch/ew/internal/provider"
+	"github.com/ashwch/ew/internal/router"
+	ewrt "github.com/ashwch/ew/internal/runtime"
+	"github.com/ashwch/ew/internal/undo"
+)
+
+// Routes a prompt can take through main, as --trace-plan names them.
+const (
+	planRouteFix         = "fix"
+	planRouteFind        = "find"
+	planRouteRun         = "run"
+	planRoutePassthrough = "passthrough"
+	planRouteExplain     = "explain"
+	planRouteQuick       = "quick"
+	planRouteMemory      = "memory"
+	planRouteSelf        = "self"
+	planRouteSwitch      = "switch"
+	planRouteFrequent    = "frequent"
+	planRouteUndo        = "undo"
+)
+
+// tracePlan is what --trace-plan reports: how ew would handle a prompt, up
+// to the point where it would ask a provider or run a command.
+type tracePlan struct {
+	Query string `json:"query"`
+	Route string `json:"route"`
+	Note  string `json:"note,omitempty"`
+	// Failure and RuleFix are the fix route's failed command and the
+	// learned or built-in rule's fix for it, if one applies.
+	Failure *hook.Event `json:"failure,omitempty"`
+	RuleFix string      `json:"rule_fix,omitempty"`
+	// Memory and History are the ranked candidates, after the same
+	// filtering and boosts the real run applies.
+	Memory        []memory.Match  `json:"memory,omitempty"`
+	MemoryAnswers bool            `json:"memory_answers,omitempty"`
+	History       []history.Match `json:"history,omitempty"`
+	AI            planAI          `json:"ai"`
+}
+
+// planAI says whether the provider would be asked, why, and with what.
+type planAI struct {
+	Consulted bool   `json:"consulted"`
+	Reason    string `json:"reason"`
+	// Step is fallback (nothing local matched), rerank, or fix.
+	Step         string   `json:"step,omitempty"`
+	Intent       string   `json:"intent,omitempty"`
+	Providers    []string `json:"providers,omitempty"`
+	Model        string   `json:"model,omitempty"`
+	Thinking     string   `json:"thinking,omitempty"`
+	PromptBytes  int      `json:"prompt_bytes,omitempty"`
+	PromptTokens int      `json:"prompt_tokens,omitempty"`
+	// Cached is set when an identical request was answered recently, so
+	// the provider would not actually be called.
+	Cached bool `json:"cached,omitempty"`
+}
+
+// promptBytesPerToken turns a prompt's size into a rough token count.
+const promptBytesPerToken = 4
+
+// handleTracePlan prints the plan for prompt without asking a provider,
+// running anything, or recording the interaction.
+func handleTracePlan(prompt string, cfg config.Config, opts options) {
+	plan := buildTracePlan(prompt, cfg, opts)
+	if opts.JSON {
+		printResponse(response{Intent: string(router.IntentTracePlan), Results: plan}, true)
+		return
+	}
+	printTracePlan(plan, opts)
+}
+
+func buildTracePlan(prompt string, cfg config.Config, opts options) tracePlan {
+	plan := tracePlan{Query: prompt}
+	switch {
+	case opts.Passthrough:
+		plan.Route = planRoutePassthrough
+		plan.Note = "ew run -- runs the command as given, through the usual policy gates"
+		plan.AI.Reason = "no lookup for a command given with ew run --"
+		return plan
+	case opts.FailedCommand != "":
+		input, err := readExplicitFixInput(opts, os.Stdin)
+		plan.Route = planRouteFix
+		if err != nil {
+			plan.Note = err.Error()
+			plan.AI.Reason = "nothing to fix"
+			return plan
+		}
+		return traceFix(plan, input.Event, input.ErrorText, prompt, cfg, opts)
+	}
+	if subject, ok := parseExplainPrompt(prompt); opts.Explain || (ok && !opts.Execute) {
+		if !opts.Explain {
+			plan.Query = subject
+		}
+		plan.Route = planRouteExplain
+		plan.Note = "the command is explained part by part"
+		if unavailable := providerAvailability(cfg, opts).reason; unavailable != "" {
+			plan.AI.Reason = fmt.Sprintf("%s skipped: %s", capabilityAIExplain, unavailable)
+			return plan
+		}
+		plan.AI = tracePlanCall(cfg, opts, "explain", provider.IntentExplain, buildExplainPrompt(cfg, plan.Query))
+		plan.AI.Reason = "explanations come from the provider"
+		return plan
+	}
+	if prompt == "" {
+		if opts.Execute {
+			plan.Route = planRouteFrequent
+			plan.Note = "offers your most reused commands for this directory and time of day"
+			plan.AI.Reason = "frequent commands come from the hook events"
+			return plan
+		}
+		return traceLatestFailure(plan, "", cfg, opts)
+	}
+	if name, args, ok := parseQuickPrompt(prompt); ok {
+		if _, found := cfg.Quick[name]; found || (name == "" && len(args) == 0) {
+			plan.Route = planRouteQuick
+			plan.Note = "expands a quick command template"
+			plan.AI.Reason = "quick commands are expanded locally"
+			return plan
+		}
+	}
+	if isUndoPrompt(prompt) {
+		return traceUndo(plan, cfg, opts)
+	}
+	if !opts.Execute {
+		if action, ok := parseMemoryPromptAction(prompt); ok && action.Kind != memoryActionNone {
+			plan.Route = planRouteMemory
+			plan.Note = fmt.Sprintf("memory action %s", action.Kind)
+			plan.AI.Reason = "memory actions are handled locally"
+			return plan
+		}
+		if action, ok := parseSelfPromptAction(prompt); ok && action.Kind != selfActionNone {
+			plan.Route = planRouteSelf
+			plan.Note = fmt.Sprintf("ew setting or action %s", action.Kind)
+			plan.AI.Reason = "requests about ew itself are handled locally"
+			return plan
+		}
+	}
+	if subject, ok := parseSwitchPrompt(prompt); ok {
+		matches := multiplexer.Rank(subject, listSwitchTargets(invocationCtx), multiplexer.InTmux())
+		if len(matches) > 0 || explicitSwitchPattern.MatchString(subject) {
+			plan.Route = planRouteSwitch
+			plan.Note = fmt.Sprintf("%d tmux or wezterm targets match %q", len(matches), subject)
+			plan.AI.Reason = "switch targets are matched locally"
+			return plan
+		}
+	}
+	if !opts.Execute && isFixPrompt(prompt) {
+		return traceLatestFailure(plan, prompt, cfg, opts)
+	}
+	if meaninglessQuery(prompt) {
+		plan.Route = planRouteFrequent
+		plan.Note = "the query has no words to search for, so your most reused commands are offered"
+		plan.AI.Reason = "frequent commands come from the hook events"
+		return plan
+	}
+	return traceFind(plan, prompt, cfg, opts)
+}
+
+// traceFind follows handleFind, or handleRun with --execute.
+func traceFind(plan tracePlan, query string, cfg config.Config, opts options) tracePlan {
+	plan.Route = planRouteFind
+	if opts.Execute {
+		plan.Route = planRouteRun
+	}
+	now := time.Now().UTC()
+	rejections := loadRejections()
+	memoryMatches, _ := searchMemoryWithLoader(query, cfg.Find.MaxResults, opts, "checking what you've used before")
+	if !opts.Execute {
+		memoryMatches = rejections.Downrank(query, memoryMatches, now)
+	}
+	plan.Memory = memoryMatches
+	if top, ok := preferredMemoryMatch(query, memoryMatches); ok {
+		plan.MemoryAnswers = true
+		plan.Note = fmt.Sprintf("memory answers with %q, learned for %q", top.Command, top.Query)
+		plan.AI.Reason = "memory answered before history was searched"
+		return plan
+	}
+
+	var matches []history.Match
+	var err error
+	if opts.Execute {
+		matches, err = searchHistoryWithLoader(query, cfg.Find.MaxResults, opts, "scouting your history")
+	} else {
+		var page history.Results
+		page, err = searchHistoryPageWithLoader(query, history.Page{Offset: opts.Offset, Limit: cfg.Find.MaxResults}, opts, "scouting your history")
+		matches = page.Matches
+	}
+	if err != nil && !errors.Is(err, history.ErrNoHistory) {
+		plan.Note = fmt.Sprintf("history search failed: %v", err)
+	}
+	matches = applyRepoBoost(applyTemporalBoost(filterFindMatches(cfg, query, matches), cfg, time.Now()))
+	if !opts.Execute {
+		matches = downrankRejectedHistory(query, matches, rejections, now)
+	}
+	matches = mergeCheatMatches(query, matches, cfg.Find.MaxResults)
+	matches = mergeRunbookMatches(query, matches, cfg.Find.MaxResults)
+	plan.History = matches
+
+	unavailable := providerAvailability(cfg, opts).reason
+	book, bookOK := runbookPick(query)
+	switch {
+	case bookOK:
+		plan.AI.Reason = fmt.Sprintf("the command comes %s, so no provider is asked", book.Reason)
+	case len(matches) == 0 && unavailable != "":
+		plan.AI.Reason = fmt.Sprintf("no history match, but %s is skipped: %s", capabilityProviderFallback, unavailable)
+	case len(matches) == 0:
+		plan.AI = tracePlanCall(cfg, opts, "fallback", provider.IntentFind, buildFindPrompt(cfg, query, nil))
+		plan.AI.Reason = "no history match"
+	case !shouldAIRerank(aiRerankMode(cfg, opts), matches):
+		plan.AI.Reason = fmt.Sprintf("the history ranking stands (find.ai_rerank = %s, %d matches, top score %.2f)", cfg.Find.AIRerank, len(matches), matches[0].Score)
+	case unavailable != "":
+		plan.AI.Reason = fmt.Sprintf("%s skipped: %s", capabilityAIRerank, unavailable)
+	default:
+		plan.AI = tracePlanCall(cfg, opts, "rerank", provider.IntentFind, buildFindPrompt(cfg, query, matches))
+		plan.AI.Reason = fmt.Sprintf("%d history matches and the top one scores %.2f, so the provider reranks them", len(matches), matches[0].Score)
+	}
+	return plan
+}
+
+// traceUndo follows handleUndo.
+func traceUndo(plan tracePlan, cfg config.Config, opts options) tracePlan {
+	plan.Route = planRouteUndo
+	entry, err := undo.Latest()
+	switch {
+	case err != nil:
+		plan.Note = err.Error()
+		plan.AI.Reason = "nothing to undo"
+		return plan
+	case entry == nil:
+		plan.Note = "ew has not run a command that changes something"
+		plan.AI.Reason = "nothing to undo"
+		return plan
+	case entry.Inverse != "":
+		plan.Note = fmt.Sprintf("undoes %s with %s", entry.Command, entry.Inverse)
+		plan.AI.Reason = "a built-in rule knows the inverse"
+		return plan
+	}
+	plan.Note = fmt.Sprintf("no built-in rule knows how to undo %s", entry.Command)
+	if unavailable := providerAvailability(cfg, opts).reason; unavailable != "" {
+		plan.AI.Reason = fmt.Sprintf("%s skipped: %s", capabilityAIUndo, unavailable)
+		return plan
+	}
+	plan.AI = tracePlanCall(cfg, opts, "undo", provider.IntentFix, buildUndoPrompt(cfg, *entry))
+	plan.AI.Reason = "the provider is asked for the inverse"
+	return plan
+}
+
+// traceLatestFailure follows handleFix for the captured failure.
+func traceLatestFailure(plan tracePlan, userContext string, cfg config.Config, opts options) tracePlan {
+	plan.Route = planRouteFix
+	ev, err := hook.LatestFailure(strings.TrimSpace(os.Getenv("EW_SESSION_ID")))
+	if err != nil {
+		plan.Note = fmt.Sprintf("could not read latest failure: %v", err)
+		plan.AI.Reason = "nothing to fix"
+		return plan
+	}
+	if stale, detail := staleFailureDetail(ev, time.Now().UTC()); stale {
+		plan.Note = detail + "; ew would infer a fix from your latest history entry instead"
+		plan.AI.Reason = "no recent captured failure"
+		return plan
+	}
+	return traceFix(plan, *ev, ev.Output, userContext, cfg, opts)
+}
+
+// traceFix follows fixFailedCommand.
+func traceFix(plan tracePlan, ev hook.Event, errorText, userContext string, cfg config.Config, opts options) tracePlan {
+	plan.Failure = &ev
+	if learned, _ := learnedFix(ev.Command, false); learned != "" {
+		plan.RuleFix = learned
+		plan.AI.Reason = "ew learned a fix for this failure from your shell"
+		return plan
+	}
+	if suggested, _ := ewrt.SuggestFix(ev.Command); suggested != "" {
+		plan.RuleFix = suggested
+		plan.AI.Reason = "a built-in fix rule applies"
+		return plan
+	}
+	if unavailable := providerAvailability(cfg, opts).reason; unavailable != "" {
+		plan.AI.Reason = fmt.Sprintf("%s skipped: %s", capabilityAIFix, unavailable)
+		return plan
+	}
+	files, err := fixContextFiles(userContext, opts)
+	if err != nil {
+		plan.Note = err.Error()
+		plan.AI.Reason = "an attached file could not be read"
+		return plan
+	}
+	loop := fixLoop(ev.Command, strings.TrimSpace(os.Getenv("EW_SESSION_ID")), time.Now().UTC())
+	if len(loop) >= cfg.Fix.MaxAttempts && !addsFixContext(userContext) && len(files) == 0 {
+		plan.AI.Reason = fmt.Sprintf("ew already offered %d fixes for this failure (fix.max_attempts = %d), so it summarizes them instead", len(loop), cfg.Fix.MaxAttempts)
+		return plan
+	}
+	prompt := doNotRepeatPrompt(buildFixPrompt(cfg, ev.Command, ev.ExitCode, ev.CWD, errorText, userContext, files), loopCommands(loop))
+	plan.AI = tracePlanCall(cfg, opts, "fix", provider.IntentFix, prompt)
+	plan.AI.Reason = "no built-in fix rule applies"
+	if len(files) > 0 {
+		plan.AI.Reason += fmt.Sprintf("; %d attached files are in the prompt", len(files))
+	}
+	if len(loop) > 0 {
+		plan.AI.Reason += fmt.Sprintf("; %d earlier fixes are passed on as ones not to repeat", len(loop))
+	}
+	return plan
+}
+
+// tracePlanCall describes the provider request askProvider would send for
+// prompt.
+func tracePlanCall(cfg config.Config, opts options, step string, intent provider.Intent, prompt string) planAI {
+	req := providerRequest(cfg, opts, intent, prompt)
+	call := planAI{
+		Consulted:    true,
+		Step:         step,
+		Intent:       string(intent),
+		Providers:    healthyProviders(cfg, opts.Provider),
+		Model:        req.Model,
+		Thinking:     req.Thinking,
+		PromptBytes:  len(req.Prompt),
+		PromptTokens: (len(req.Prompt) + promptBytesPerToken - 1) / promptBytesPerToken,
+	}
+	if ttl := time.Duration(cfg.AI.CacheTTLSeconds) * time.Second; ttl > 0 && !opts.NoCache {
+		_, call.Cached = cache.Lookup(cache.Key(req, strings.TrimSpace(opts.Provider)), ttl, time.Now())
+	}
+	return call
+}
+
+func printTracePlan(plan tracePlan, opts options) {
+	fmt.Printf("Plan for: %q\n", plan.Query)
+	printLabeled("route: ", plan.Route)
+	printLabeled("note: ", plan.Note)
+	if plan.Failure != nil {
+		printLabeled("failure: ", fmt.Sprintf("%s (exit %d)", plan.Failure.Command, plan.Failure.ExitCode))
+		if plan.Failure.Output != "" {
+			printLabeled("output: ", fmt.Sprintf("%d captured lines", strings.Count(plan.Failure.Output, "\n")+1))
+		}
+	}
+	printLabeled("rule fix: ", plan.RuleFix)
+	if plan.Route == planRouteFind || plan.Route == planRouteRun {
+		fmt.Println("memory:")
+		if len(plan.Memory) == 0 {
+			fmt.Println("  (no matches)")
+		}
+		for idx, match := range plan.Memory {
+			line := fmt.Sprintf("  %d. %s  [score %.2f, uses %d, learned for %q", idx+1, match.Command, match.Score, match.Uses, match.Query)
+			if match.Rejected {
+				line += ", rejected"
+			}
+			fmt.Println(line + "]")
+		}
+		if !plan.MemoryAnswers {
+			fmt.Println("history:")
+			if len(plan.History) == 0 {
+				fmt.Println("  (no matches)")
+			}
+			for idx, match := range plan.History {
+				detail := fmt.Sprintf("score %.2f, %s", match.Score, match.Source)
+				if breakdown := match.Breakdown(); breakdown != "" {
+					detail += ": " + breakdown
+				}
+				lines := wrapCommand(fmt.Sprintf("  %d. ", opts.Offset+idx+1), match.Command, commandWrapWidth())
+				lines[len(lines)-1] += "  [" + detail + "]"
+				fmt.Println(strings.Join(lines, "\n"))
+			}
+		}
+	}
+	ai := "no"
+	if plan.AI.Consulted {
+		ai = fmt.Sprintf("yes, %s (%s intent)", plan.AI.Step, plan.AI.Intent)
+		if plan.AI.Cached {
+			ai += ", answered from the cache"
+		}
+	}
+	printLabeled("ai: ", ai)
+	printLabeled("why: ", plan.AI.Reason)
+	if plan.AI.Consulted {
+		providers := strings.Join(plan.AI.Providers, ", ")
+		if providers == "" {
+			providers = "(none healthy)"
+		}
+		printLabeled("providers: ", providers)
+		printLabeled("model: ", strings.TrimSpace(plan.AI.Model+" "+plan.AI.Thinking))
+		printLabeled("prompt: ", fmt.Sprintf("%d bytes, about %d tokens", plan.AI.PromptBytes, plan.AI.PromptTokens))
+	}
+}
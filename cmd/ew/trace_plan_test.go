@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ashwch/ew/internal/config"
+)
+
+func TestTracePlanFixStopsBeforeTheProvider(t *testing.T) {
+	resetSkippedCapabilities(t)
+	original := healthyProviders
+	t.Cleanup(func() { healthyProviders = original })
+	healthyProviders = func(config.Config, string) []string { return []string{"stub"} }
+	t.Setenv("EW_SESSION_ID", "")
+	cfg := config.Default()
+
+	plan := buildTracePlan("", cfg, options{FailedCommand: "gti status", NoCache: true})
+	if plan.Route != planRouteFix || plan.RuleFix != "git status" || plan.AI.Consulted {
+		t.Fatalf("expected the built-in rule to answer without a provider, got %+v", plan)
+	}
+
+	plan = buildTracePlan("", cfg, options{FailedCommand: "make build", ErrorText: "missing semicolon", NoCache: true})
+	if !plan.AI.Consulted || plan.AI.Step != "fix" || strings.Join(plan.AI.Providers, ",") != "stub" {
+		t.Fatalf("expected a fix request to the stub provider, got %+v", plan.AI)
+	}
+	if plan.AI.PromptBytes == 0 || plan.AI.PromptTokens != (plan.AI.PromptBytes+3)/4 {
+		t.Fatalf("expected the prompt size in bytes and tokens, got %d bytes and %d tokens", plan.AI.PromptBytes, plan.AI.PromptTokens)
+	}
+
+	plan = buildTracePlan("", cfg, options{FailedCommand: "make build", Offline: true})
+	if plan.AI.Consulted || !strings.Contains(plan.AI.Reason, "offline") {
+		t.Fatalf("expected no provider offline, got %+v", plan.AI)
+	}
+	if len(skippedCapabilities) != 0 {
+		t.Fatalf("expected tracing not to announce skipped capabilities, got %v", skippedCapabilities)
+	}
+}
+
+func TestTracePlanFollowsTheRoutesMainTakes(t *testing.T) {
+	original := healthyProviders
+	t.Cleanup(func() { healthyProviders = original })
+	healthyProviders = func(config.Config, string) []string { return nil }
+	cfg := config.Default()
+
+	for _, tc := range []struct {
+		prompt string
+		opts   options
+		want   string
+	}{
+		{prompt: "ls -la", opts: options{Passthrough: true}, want: planRoutePassthrough},
+		{prompt: "explain tar -xzvf backup.tgz", want: planRouteExplain},
+		{prompt: "/", want: planRouteQuick},
+		{prompt: "", opts: options{Execute: true}, want: planRouteFrequent},
+	} {
+		if got := buildTracePlan(tc.prompt, cfg, tc.opts); got.Route != tc.want || got.AI.Consulted {
+			t.Fatalf("expected %q to take the %s route without a provider, got %+v", tc.prompt, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/knowledge"
+	"github.com/ashwch/ew/internal/provider"
+	"github.com/ashwch/ew/internal/router"
+	ewrt "github.com/ashwch/ew/internal/runtime"
+	"github.com/ashwch/ew/internal/safety"
+	"github.com/ashwch/ew/internal/session"
+	"github.com/ashwch/ew/internal/undo"
+)
+
+// undoPrompts are the whole prompts that ask to roll back the last command
+// ew ran. Anything longer, like "undo git commit", is a search.
+var undoPrompts = map[string]bool{
+	"undo":                    true,
+	"undo it":                 true,
+	"undo that":               true,
+	"undo last":               true,
+	"undo the last":           true,
+	"undo last command":       true,
+	"undo the last command":   true,
+	"rollback":                true,
+	"roll back":               true,
+	"roll it back":            true,
+	"roll that back":          true,
+	"rollback that":           true,
+	"revert that":             true,
+	"revert it":               true,
+	"revert the last command": true,
+}
+
+func isUndoPrompt(prompt string) bool {
+	low := strings.ToLower(strings.Join(strings.Fields(prompt), " "))
+	return undoPrompts[strings.TrimRight(low, ".!?")]
+}
+
+// undoBefore is the state the last local command ran from, read by
+// runUserCommand just before it ran, so recordUndo can build an inverse
+// that restores exactly that.
+var undoBefore struct {
+	command string
+	state   undo.State
+}
+
+func noteUndoBefore(backend ewrt.Backend, command string) {
+	undoBefore.command, undoBefore.state = "", undo.State{}
+	if backend.Remote() {
+		return
+	}
+	cwd, _ := os.Getwd()
+	undoBefore.command, undoBefore.state = command, undo.Capture(command, cwd)
+}
+
+// recordUndo remembers a command ew ran successfully, with its inverse when
+// a rule knows one. Only commands that change something are kept, and only
+// local ones, since the inverse of a remote command would have to run there
+// too. It follows journal.privacy: nothing is kept when it is off or with
+// --no-record, and a command carrying a secret is kept only at full, since
+// a redacted command could not be undone.
+func recordUndo(command string, cfg config.Config) {
+	level := session.Privacy()
+	if level == session.PrivacyOff {
+		return
+	}
+	if level != session.PrivacyFull && safety.RedactText(command) != command {
+		return
+	}
+	backend, err := ewrt.ParseTarget(cfg.Execution.Target)
+	if err != nil || backend.Remote() {
+		return
+	}
+	cwd, _ := os.Getwd()
+	var before undo.State
+	if undoBefore.command == command {
+		before = undoBefore.state
+	}
+	inverse, ok := undo.Inverse(command, cwd, before)
+	if !ok && !isMutatingCommand(command) {
+		return
+	}
+	var after *undo.State
+	if ok {
+		if state := undo.Capture(command, cwd); state.Head != "" || state.LinkTarget != "" {
+			after = &state
+		}
+	}
+	if level != session.PrivacyFull && safety.RedactText(inverse) != inverse {
+		return
+	}
+	_ = undo.Record(undo.Entry{
+		Command:   command,
+		Inverse:   inverse,
+		After:     after,
+		CWD:       cwd,
+		SessionID: strings.TrimSpace(os.Getenv("EW_SESSION_ID")),
+	})
+}
+
+// handleUndo offers the inverse of the latest command ew ran, and runs it
+// with --execute. A rule's inverse is used when there is one; otherwise the
+// provider is asked for it.
+func handleUndo(cfg config.Config, opts options) {
+	entry, err := undo.Latest()
+	if err != nil {
+		printResponse(response{Intent: string(router.IntentUndo), Message: err.Error()}, opts.JSON)
+		return
+	}
+	if entry == nil {
+		printResponse(response{
+			Intent:      string(router.IntentUndo),
+			Message:     "nothing to undo: ew has not run a command that changes something",
+			Suggestions: []string{"ew --execute <request> records what it runs so it can be undone"},
+		}, opts.JSON)
+		return
+	}
+
+	if err := undo.Check(*entry); err != nil {
+		printResponse(response{Intent: string(router.IntentUndo), Message: err.Error()}, opts.JSON)
+		return
+	}
+	plan := undoPlan{Command: entry.Inverse, Reason: fmt.Sprintf("undoes %s", entry.Command), Source: "built-in undo rule", Runnable: true}
+	if plan.Command == "" {
+		var ok bool
+		if plan, ok = undoFromProvider(*entry, cfg, opts); !ok {
+			return
+		}
+	}
+
+	cwd, _ := os.Getwd()
+	moved := entry.CWD != "" && cwd != entry.CWD
+	if !opts.Execute || !plan.Runnable || moved {
+		payload := response{Intent: string(router.IntentUndo), Message: plan.Reason, Command: plan.Command, Risk: normalizeRiskHint(plan.RiskHint)}
+		switch {
+		case moved:
+			payload.Warning = fmt.Sprintf("%s ran in %s; run the undo from there", entry.Command, entry.CWD)
+		case !plan.Runnable:
+			payload.Warning = "the provider was not sure enough to run this on its own"
+		default:
+			payload.Suggestions = []string{"ew --execute undo runs it"}
+		}
+		if opts.JSON {
+			noteSessionSuggestion(plan.Command, plan.Reason, plan.Source)
+			printResponse(payload, true)
+			return
+		}
+		printLabeled("warning: ", payload.Warning)
+		printSuggestedCommandBlock(plan.Command, plan.Reason, plan.Source, cfg, opts)
+		if !opts.Quiet && len(payload.Suggestions) > 0 && !opts.Execute {
+			fmt.Println(payload.Suggestions[0])
+		}
+		return
+	}
+
+	outcome := executeSuggested(plan.Command, "", plan.Reason, plan.RiskHint, cfg, opts, router.IntentUndo)
+	if outcome.Executed && outcome.Success {
+		_ = undo.MarkUndone(entry.ID)
+	}
+}
+
+// undoPlan is the command that undoes an entry and where it came from.
+// Runnable is false when the provider's answer fell short of the AI policy,
+// so it is only shown.
+type undoPlan struct {
+	Command  string
+	Reason   string
+	RiskHint string
+	Source   string
+	Runnable bool
+}
+
+// undoFromProvider asks the provider for the command that reverses entry,
+// for commands no rule covers. It reports false, having said why, when
+// there is no usable answer.
+func undoFromProvider(entry undo.Entry, cfg config.Config, opts options) (undoPlan, bool) {
+	if !providerAvailability(cfg, opts).allows(capabilityAIUndo, opts) {
+		printResponse(response{
+			Intent:  string(router.IntentUndo),
+			Message: fmt.Sprintf("no built-in rule knows how to undo %s", entry.Command),
+		}, opts.JSON)
+		return undoPlan{}, false
+	}
+	resolution, providerName, err := resolveProviderWithLoader(invocationCtx, cfg, opts, provider.IntentFix, buildUndoPrompt(cfg, entry), "working out how to undo it")
+	if err != nil {
+		printResponse(response{
+			Intent:      string(router.IntentUndo),
+			Message:     fmt.Sprintf("no built-in rule knows how to undo %s and the provider failed", entry.Command),
+			Suggestions: errorSuggestions(err),
+		}, opts.JSON)
+		return undoPlan{}, false
+	}
+	decision := evaluateAIResolution(router.IntentFix, cfg, providerName, resolution)
+	if strings.TrimSpace(decision.Command) == "" {
+		why := strings.TrimSpace(resolution.Reason)
+		if why == "" {
+			why = decision.Message
+		}
+		printResponse(response{
+			Intent:  string(router.IntentUndo),
+			Message: fmt.Sprintf("%s cannot be undone automatically: %s", entry.Command, why),
+		}, opts.JSON)
+		return undoPlan{}, false
+	}
+	reason := strings.TrimSpace(resolution.Reason)
+	if reason == "" {
+		reason = fmt.Sprintf("undoes %s", entry.Command)
+	}
+	return undoPlan{Command: decision.Command, Reason: reason, RiskHint: resolution.Risk, Source: providerName, Runnable: decision.Allowed}, true
+}
+
+func buildUndoPrompt(cfg config.Config, entry undo.Entry) string {
+	base := fmt.Sprintf(
+		"Return only JSON matching schema. ew just ran this shell command successfully: %q, in working directory %q. Output the one command that reverses its effect. If it cannot be reversed safely (deleted files, pushed commits, sent requests), set action to ask, leave command empty, and say why in reason.",
+		entry.Command,
+		entry.CWD,
+	)
+	return wrapWithSelfKnowledge(cfg, knowledge.ScopeFix, base)
+}
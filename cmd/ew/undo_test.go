@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/router"
+	"github.com/ashwch/ew/internal/undo"
+)
+
+func TestIsUndoPromptOnlyMatchesWholeRequests(t *testing.T) {
+	for _, prompt := range []string{"undo", "Undo that!", "roll  it back", "rollback"} {
+		if !isUndoPrompt(prompt) {
+			t.Fatalf("expected %q to ask for an undo", prompt)
+		}
+	}
+	for _, prompt := range []string{"undo git commit", "how do I undo a rebase", "revert file changes"} {
+		if isUndoPrompt(prompt) {
+			t.Fatalf("expected %q to stay a search", prompt)
+		}
+	}
+}
+
+func TestUndoRollsBackTheLastExecution(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, ".local", "state"))
+	t.Setenv("EW_SESSION_ID", "")
+	dir := t.TempDir()
+	t.Chdir(dir)
+	cfg := config.Default()
+	opts := options{JSON: true, Yes: true}
+
+	captureStdout(t, func() {
+		executeSuggested("echo hi", "", "reads nothing", "low", cfg, opts, router.IntentRun)
+		executeSuggested("mkdir build", "", "makes the build dir", "low", cfg, opts, router.IntentRun)
+	})
+	entry, err := undo.Latest()
+	if err != nil || entry == nil || entry.Command != "mkdir build" || entry.Inverse != "rmdir build" {
+		t.Fatalf("expected mkdir recorded with its inverse and echo skipped, got %+v %v", entry, err)
+	}
+
+	out := captureStdout(t, func() { handleUndo(cfg, options{JSON: true}) })
+	var payload response
+	if err := json.Unmarshal([]byte(out), &payload); err != nil {
+		t.Fatalf("expected json output, got %q: %v", out, err)
+	}
+	if payload.Intent != string(router.IntentUndo) || payload.Command != "rmdir build" || payload.Executed {
+		t.Fatalf("expected the inverse suggested without running, got %+v", payload)
+	}
+
+	captureStdout(t, func() { handleUndo(cfg, options{JSON: true, Yes: true, Execute: true}) })
+	if _, err := os.Stat(filepath.Join(dir, "build")); !os.IsNotExist(err) {
+		t.Fatalf("expected the undo to remove the directory, got %v", err)
+	}
+	if entry, err := undo.Latest(); err != nil || entry != nil {
+		t.Fatalf("expected nothing left to undo, got %+v %v", entry, err)
+	}
+
+	out = captureStdout(t, func() { handleUndo(cfg, options{JSON: true}) })
+	if !strings.Contains(out, "nothing to undo") {
+		t.Fatalf("expected nothing to undo, got %q", out)
+	}
+}
+
+func TestUndoRefusesOnceTheResultChanged(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, ".local", "state"))
+	t.Setenv("EW_SESSION_ID", "")
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if err := os.Symlink("release-1", "current"); err != nil {
+		t.Fatalf("symlink failed: %v", err)
+	}
+	cfg := config.Default()
+
+	captureStdout(t, func() {
+		executeSuggested("ln -sfn release-2 current", "", "switches the release", "low", cfg, options{JSON: true, Yes: true}, router.IntentRun)
+	})
+	entry, err := undo.Latest()
+	if err != nil || entry == nil || entry.Inverse != "ln -sfn release-1 current" {
+		t.Fatalf("expected the old link target restored by the inverse, got %+v %v", entry, err)
+	}
+
+	if err := os.Remove("current"); err != nil {
+		t.Fatalf("remove failed: %v", err)
+	}
+	if err := os.Symlink("release-3", "current"); err != nil {
+		t.Fatalf("symlink failed: %v", err)
+	}
+	out := captureStdout(t, func() { handleUndo(cfg, options{JSON: true, Yes: true, Execute: true}) })
+	if !strings.Contains(out, "has changed since") {
+		t.Fatalf("expected the undo refused, got %q", out)
+	}
+	if target, _ := os.Readlink("current"); target != "release-3" {
+		t.Fatalf("expected the link left alone, got %q", target)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/history"
+	"github.com/ashwch/ew/internal/provider"
+	"github.com/ashwch/ew/internal/ui"
+)
+
+// findPick is the command find recommends and where it came from.
+type findPick struct {
+	Command string
+	Reason  string
+	Source  string
+	Risk    string
+	Rerank  string
+}
+
+// rerankPick is a provider's rerank answer as find's recommendation, when
+// it is a command find may suggest for query.
+func rerankPick(query string, matches []history.Match, offset int, resolution provider.Resolution, providerName string) (findPick, bool) {
+	command := strings.TrimSpace(resolution.Command)
+	if command == "" || !commandAllowedForQuery(query, command) {
+		return findPick{}, false
+	}
+	pick := findPick{
+		Command: command,
+		Reason:  strings.TrimSpace(resolution.Reason),
+		Source:  providerName,
+		Risk:    strings.TrimSpace(resolution.Risk),
+		Rerank:  rerankNote(command, matches, offset),
+	}
+	if pick.Reason == "" {
+		pick.Reason = fmt.Sprintf("suggested by %s", providerName)
+	}
+	return pick, true
+}
+
+// provisionalPick is what find shows while a provider reranks matches: the
+// pick it already has from memory or a cheat, or else the top history
+// match.
+func provisionalPick(current findPick, matches []history.Match) findPick {
+	if current.Command != "" || len(matches) == 0 {
+		return current
+	}
+	top := matches[0]
+	return findPick{
+		Command: top.Command,
+		Reason:  fmt.Sprintf("history match score %.2f", top.Score),
+		Source:  top.Source,
+	}
+}
+
+// warmRerank is a rerank call that runs while the picker is already open on
+// a provisional pick. The picker swaps in the answer through revision.
+type warmRerank struct {
+	revision *ui.Revision
+	cancel   context.CancelFunc
+	// pick and ok are set before revision is resolved.
+	pick findPick
+	ok   bool
+}
+
+// startWarmRerank asks the provider to rerank matches in the background.
+// selection turns its answer into the picker's recommendation.
+func startWarmRerank(query, prompt string, matches []history.Match, cfg config.Config, opts options, selection func(findPick) ui.Selection) *warmRerank {
+	ctx, cancel := context.WithCancel(invocationCtx)
+	warm := &warmRerank{revision: ui.NewRevision(), cancel: cancel}
+	go func() {
+		resolution, providerName, err := resolveProvider(ctx, cfg, opts, provider.IntentFind, prompt)
+		if err == nil {
+			warm.pick, warm.ok = rerankPick(query, matches, opts.Offset, resolution, providerName)
+		}
+		warm.revision.Resolve(selection(warm.pick), warm.ok)
+	}()
+	return warm
+}
+
+// stop abandons the call if it is still running, for when the user picked
+// before the answer came, and returns the answer if there was one.
+func (w *warmRerank) stop() (findPick, bool) {
+	w.cancel()
+	w.revision.Wait()
+	return w.pick, w.ok
+}
+
+// wait waits for the answer behind the loader, for when no picker could be
+// shown.
+func (w *warmRerank) wait(opts options, label string) (findPick, bool) {
+	withEWLoader(opts, label, func() { w.revision.Wait() })
+	w.cancel()
+	return w.pick, w.ok
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ashwch/ew/internal/history"
+	"github.com/ashwch/ew/internal/provider"
+)
+
+func TestProvisionalPickKeepsALocalPickOrTakesTheTopMatch(t *testing.T) {
+	matches := []history.Match{{Command: "git log", Score: 30, Source: "zsh"}, {Command: "git log --graph"}}
+	memory := findPick{Command: "git log --oneline", Source: "memory"}
+	if got := provisionalPick(memory, matches); got != memory {
+		t.Fatalf("expected the memory pick to stay, got %+v", got)
+	}
+	if got := provisionalPick(findPick{}, matches); got.Command != "git log" || got.Source != "zsh" || got.Reason != "history match score 30.00" {
+		t.Fatalf("expected the top history match, got %+v", got)
+	}
+}
+
+func TestRerankPickChecksTheAnswer(t *testing.T) {
+	matches := []history.Match{{Command: "git log"}, {Command: "git log --graph"}}
+	pick, ok := rerankPick("show git history", matches, 0, provider.Resolution{Command: " git log --graph "}, "claude")
+	if !ok || pick.Command != "git log --graph" || pick.Reason != "suggested by claude" || pick.Rerank != "AI promoted #2 over #1" {
+		t.Fatalf("unexpected pick %+v %v", pick, ok)
+	}
+	if _, ok := rerankPick("show git history", matches, 0, provider.Resolution{}, "claude"); ok {
+		t.Fatalf("expected an empty answer to be no pick")
+	}
+}
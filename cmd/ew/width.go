@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ashwch/ew/internal/ui"
+	"golang.org/x/term"
+)
+
+// minWrapWidth keeps wrapped text readable on very narrow terminals; below
+// it lines are allowed to overflow instead of breaking every word.
+const minWrapWidth = 20
+
+// outputWidth is the width plain output is laid out for: the terminal's,
+// then $COLUMNS, then defaultReasonWidth.
+func outputWidth() int {
+	if width, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil && width > 0 {
+		return width
+	}
+	if columns, err := strconv.Atoi(strings.TrimSpace(os.Getenv("COLUMNS"))); err == nil && columns > 0 {
+		return columns
+	}
+	return defaultReasonWidth
+}
+
+// commandWrapWidth is outputWidth when stdout is a terminal and 0 otherwise,
+// so piped output keeps each command on one line for scripts to read.
+func commandWrapWidth() int {
+	if !isTerminal(os.Stdout) {
+		return 0
+	}
+	return outputWidth()
+}
+
+// wrapLabeled lays text out after label with a hanging indent, so
+// continuation lines start under the first word of the value. The text is
+// made ASCII in ASCII mode.
+func wrapLabeled(label, text string, width int) []string {
+	text = strings.TrimSpace(ui.ASCII(text))
+	if text == "" {
+		return nil
+	}
+	available := width - len(label)
+	if width <= 0 || available < minWrapWidth {
+		return []string{label + text}
+	}
+	indent := strings.Repeat(" ", len(label))
+	lines := wrapReasonWords(text, available)
+	for i := range lines {
+		if i == 0 {
+			lines[i] = label + lines[i]
+			continue
+		}
+		lines[i] = indent + lines[i]
+	}
+	return lines
+}
+
+// printLabeled prints a "label: value" line wrapped to the output width.
+func printLabeled(label, text string) {
+	for _, line := range wrapLabeled(label, text, outputWidth()) {
+		fmt.Println(line)
+	}
+}
+
+// printCommand prints command after prefix, wrapped when stdout is a
+// terminal too narrow for it.
+func printCommand(prefix, command string) {
+	for _, line := range wrapCommand(prefix, command, commandWrapWidth()) {
+		fmt.Println(line)
+	}
+}
+
+// wrapCommand breaks a command that does not fit in width into lines joined
+// with shell line continuations, so the block still pastes as one command.
+// It only splits at spaces outside quotes; multi-line commands and a width
+// of 0 leave the command as it is. prefix (a list number, say) starts the
+// first line and continuation lines are indented two spaces past it.
+func wrapCommand(prefix, command string, width int) []string {
+	command = strings.TrimSpace(command)
+	if width <= 0 || len([]rune(prefix+command)) <= width || strings.Contains(command, "\n") {
+		return []string{prefix + command}
+	}
+	words := splitCommandWords(command)
+	if len(words) < 2 {
+		return []string{prefix + command}
+	}
+
+	const continuation = " \\"
+	indent := strings.Repeat(" ", len([]rune(prefix))+2)
+	lines := []string{}
+	current := prefix + words[0]
+	for _, word := range words[1:] {
+		if len([]rune(current))+1+len([]rune(word))+len(continuation) > width && strings.TrimSpace(current) != "" {
+			lines = append(lines, current+continuation)
+			current = indent + word
+			continue
+		}
+		current += " " + word
+	}
+	return append(lines, current)
+}
+
+// splitCommandWords splits command at runs of unquoted, unescaped spaces.
+// Words keep their quotes and escapes so joining them restores the command.
+func splitCommandWords(command string) []string {
+	words := []string{}
+	var (
+		current strings.Builder
+		quote   rune
+		escaped bool
+	)
+	for _, r := range command {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == ' ' || r == '\t':
+			if current.Len() > 0 {
+				words = append(words, current.String())
+				current.Reset()
+			}
+			continue
+		}
+		current.WriteRune(r)
+	}
+	if current.Len() > 0 {
+		words = append(words, current.String())
+	}
+	return words
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ashwch/ew/internal/doctor"
+)
+
+func TestWrapCommandSplitsOutsideQuotesWithContinuations(t *testing.T) {
+	command := `git log --since "two weeks ago" --author 'Jane Doe' --pretty=oneline --no-merges`
+	lines := wrapCommand("1. ", command, 40)
+	if len(lines) < 2 {
+		t.Fatalf("expected wrapped command, got %q", lines)
+	}
+	for i, line := range lines {
+		if len(line) > 40 {
+			t.Fatalf("expected lines within width, got %q", line)
+		}
+		if i < len(lines)-1 && !strings.HasSuffix(line, ` \`) {
+			t.Fatalf("expected a line continuation, got %q", line)
+		}
+		if i > 0 && !strings.HasPrefix(line, "     ") {
+			t.Fatalf("expected continuation indent under the prefix, got %q", line)
+		}
+	}
+	joined := strings.Join(lines, "\n")
+	for _, quoted := range []string{`"two weeks ago"`, `'Jane Doe'`} {
+		if !strings.Contains(joined, quoted) {
+			t.Fatalf("expected %s to stay on one line, got:\n%s", quoted, joined)
+		}
+	}
+	rebuilt := strings.Join(strings.Fields(strings.ReplaceAll(joined, " \\\n", " ")), " ")
+	if rebuilt != "1. "+command {
+		t.Fatalf("expected wrapping to preserve the command, got %q", rebuilt)
+	}
+}
+
+func TestWrapCommandLeavesShortPipedAndMultiLineCommands(t *testing.T) {
+	long := strings.Repeat("echo word ", 20)
+	for _, tc := range []struct {
+		command string
+		width   int
+	}{
+		{"ls -la", 40},
+		{long, 0},
+		{"cat <<EOF\n" + long + "\nEOF", 40},
+	} {
+		if lines := wrapCommand("", tc.command, tc.width); len(lines) != 1 {
+			t.Fatalf("expected %q to stay whole at width %d, got %q", tc.command, tc.width, lines)
+		}
+	}
+}
+
+func TestCompactReasonWidthUsesWideTerminals(t *testing.T) {
+	if got := compactReasonWidth(80); got != compactReasonLimit {
+		t.Fatalf("expected the floor on narrow terminals, got %d", got)
+	}
+	if got := compactReasonWidth(200); got != 200-len(reasonLabel) {
+		t.Fatalf("expected the full line on wide terminals, got %d", got)
+	}
+}
+
+func TestFormatDoctorReportWrapsUnderValueColumn(t *testing.T) {
+	report := doctor.Report{Checks: []doctor.Check{{
+		ID:       "provider",
+		Severity: "warn",
+		Value:    "codex is configured but the binary could not be found on PATH",
+		Hint:     "install codex or set provider to auto so ew picks one that is available",
+	}}}
+	lines := formatDoctorReport(report, 50)
+	column := strings.Index(lines[0], "codex")
+	if column <= 0 || len(lines) < 4 {
+		t.Fatalf("expected wrapped value and hint, got:\n%s", strings.Join(lines, "\n"))
+	}
+	for _, line := range lines[1 : len(lines)-1] {
+		if len(line) > 50 {
+			t.Fatalf("expected lines within width, got %q", line)
+		}
+		if strings.TrimSpace(line[:column]) != "" {
+			t.Fatalf("expected continuation under the value column, got %q", line)
+		}
+	}
+}
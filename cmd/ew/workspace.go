@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/workspace"
+)
+
+var runtimeWorkspace *workspace.Status
+
+// applyWorkspaceConfig layers the nearest project's .ew.toml over cfg, but
+// only once the user has trusted that project. Cloned repos are untrusted
+// input: their settings can swap provider commands or loosen safety limits.
+func applyWorkspaceConfig(cfg *config.Config, opts options) *workspace.Status {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil
+	}
+	project, ok := workspace.Discover(cwd)
+	if !ok {
+		return nil
+	}
+
+	status := &workspace.Status{Project: project, Trust: workspace.TrustUndecided}
+	store, err := workspace.LoadTrust()
+	if err != nil {
+		status.Note = fmt.Sprintf("project settings ignored: %v", err)
+		return status
+	}
+	hash, err := workspace.Fingerprint(project)
+	if err != nil {
+		status.Note = fmt.Sprintf("project settings ignored: %v", err)
+		return status
+	}
+	status.Trust = store.Lookup(project.Root, hash)
+	if status.Trust == workspace.TrustUndecided || status.Trust == workspace.TrustChanged {
+		if decision := promptWorkspaceTrust(project, status.Trust == workspace.TrustChanged, opts); decision != "" {
+			store.Decide(project.Root, hash, decision)
+			if err := workspace.SaveTrust(store); err != nil && !opts.JSON {
+				fmt.Fprintf(os.Stderr, "ew: could not save workspace trust: %v\n", err)
+			}
+			status.Trust = decision
+		}
+	}
+
+	switch status.Trust {
+	case workspace.TrustUntrusted:
+		status.Note = "project settings ignored: workspace is untrusted"
+		return status
+	case workspace.TrustSkipped:
+		status.Note = "project settings ignored: you skipped trusting this workspace; ew asks again in a day"
+		return status
+	case workspace.TrustChanged:
+		status.Note = "project settings ignored: they changed after you trusted this workspace (run ew interactively here to review them)"
+		return status
+	case workspace.TrustUndecided:
+		status.Note = "project settings ignored until you trust this workspace (run ew interactively here)"
+		return status
+	}
+
+	if project.ConfigPath != "" {
+		if err := config.ApplyOverlay(cfg, project.ConfigPath); err != nil {
+			status.Note = fmt.Sprintf("project settings ignored: %v", err)
+			if !opts.JSON {
+				fmt.Fprintf(os.Stderr, "ew: %s\n", status.Note)
+			}
+			return status
+		}
+	}
+	status.Applied = true
+	return status
+}
+
+// promptWorkspaceTrust asks whether to trust project, or, when changed is
+// set, its settings as they are now. It returns TrustTrusted,
+// TrustUntrusted, TrustSkipped, or "" when there was no one to ask.
+func promptWorkspaceTrust(project workspace.Project, changed bool, opts options) string {
+	if opts.JSON || opts.Quiet {
+		return ""
+	}
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		return ""
+	}
+
+	if changed {
+		fmt.Printf("The project settings in %s changed after you trusted them:\n", project.Root)
+	} else {
+		fmt.Printf("ew found project settings in %s:\n", project.Root)
+	}
+	if project.ConfigPath != "" {
+		fmt.Printf("- %s\n", project.ConfigPath)
+	}
+	if project.PackDir != "" {
+		fmt.Printf("- %s/\n", project.PackDir)
+	}
+	fmt.Println("Project settings can change providers, execution mode and safety limits.")
+	fmt.Print("Trust this workspace? [y]es / [N]o / [s]kip for now: ")
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		choice, err := reader.ReadString('\n')
+		if err != nil {
+			return ""
+		}
+		if decision, ok := workspaceTrustAnswer(choice); ok {
+			return decision
+		}
+		// A typo must not put the question off for a day.
+		fmt.Print("Please answer y, n, or s: ")
+	}
+}
+
+// workspaceTrustAnswer maps an answer to the trust prompt to a decision.
+// Only an explicit s skips; an answer it does not know is not a decision.
+func workspaceTrustAnswer(choice string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(choice)) {
+	case "y", "yes":
+		return workspace.TrustTrusted, true
+	case "", "n", "no":
+		return workspace.TrustUntrusted, true
+	case "s", "skip":
+		return workspace.TrustSkipped, true
+	}
+	return "", false
+}
+
+func describeWorkspaceStatus(status workspace.Status) string {
+	if status.Applied {
+		return fmt.Sprintf("project settings applied from %s (trusted)", status.Root)
+	}
+	return fmt.Sprintf("%s [%s]", status.Note, status.Root)
+}
+
+func workspacePackDirs() []string {
+	if runtimeWorkspace == nil || !runtimeWorkspace.Applied || runtimeWorkspace.PackDir == "" {
+		return nil
+	}
+	return []string{runtimeWorkspace.PackDir}
+}
+
+// saveGlobalConfigChanges persists changes to the user's config file without
+// leaking any project overlay or config include that is active for this
+// invocation.
+func saveGlobalConfigChanges(cfgPath string, changes map[string]string) error {
+	global, _, err := config.LoadFile()
+	if err != nil {
+		return err
+	}
+	for key, value := range changes {
+		if err := global.Set(key, value); err != nil {
+			return fmt.Errorf("%s=%s: %w", key, value, err)
+		}
+	}
+	return config.Save(cfgPath, global)
+}
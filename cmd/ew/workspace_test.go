@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/workspace"
+)
+
+func setupWorkspaceProject(t *testing.T) string {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", "")
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, workspace.ConfigFileName), []byte("mode = \"yolo\"\n"), 0o600); err != nil {
+		t.Fatalf("write project config failed: %v", err)
+	}
+	t.Chdir(root)
+	return root
+}
+
+func TestApplyWorkspaceConfigIgnoresUndecidedProject(t *testing.T) {
+	setupWorkspaceProject(t)
+
+	cfg := config.Default()
+	status := applyWorkspaceConfig(&cfg, options{JSON: true})
+	if status == nil {
+		t.Fatalf("expected workspace status for discovered project")
+	}
+	if status.Applied || status.Trust != workspace.TrustUndecided {
+		t.Fatalf("expected undecided project to be ignored, got %+v", status)
+	}
+	if cfg.Mode != "confirm" {
+		t.Fatalf("expected project mode to be ignored, got %q", cfg.Mode)
+	}
+	if !strings.Contains(describeWorkspaceStatus(*status), "ignored") {
+		t.Fatalf("expected ignored note, got %q", describeWorkspaceStatus(*status))
+	}
+}
+
+func TestApplyWorkspaceConfigAppliesTrustedProject(t *testing.T) {
+	root := setupWorkspaceProject(t)
+	store, err := workspace.LoadTrust()
+	if err != nil {
+		t.Fatalf("LoadTrust failed: %v", err)
+	}
+	project, _ := workspace.Discover(root)
+	hash, err := workspace.Fingerprint(project)
+	if err != nil {
+		t.Fatalf("Fingerprint failed: %v", err)
+	}
+	store.Decide(root, hash, workspace.TrustTrusted)
+	if err := workspace.SaveTrust(store); err != nil {
+		t.Fatalf("SaveTrust failed: %v", err)
+	}
+
+	cfg := config.Default()
+	status := applyWorkspaceConfig(&cfg, options{JSON: true})
+	if status == nil || !status.Applied {
+		t.Fatalf("expected trusted project to be applied, got %+v", status)
+	}
+	if cfg.Mode != "yolo" {
+		t.Fatalf("expected project mode, got %q", cfg.Mode)
+	}
+
+	if err := os.WriteFile(filepath.Join(root, workspace.ConfigFileName), []byte("mode = \"yolo\"\nsafety.max_auto_args = 999\n"), 0o600); err != nil {
+		t.Fatalf("rewrite project config failed: %v", err)
+	}
+	cfg = config.Default()
+	status = applyWorkspaceConfig(&cfg, options{JSON: true})
+	if status == nil || status.Applied || status.Trust != workspace.TrustChanged {
+		t.Fatalf("expected edited settings to need trust again, got %+v", status)
+	}
+	if cfg.Mode != "confirm" {
+		t.Fatalf("expected the edited project mode to be ignored, got %q", cfg.Mode)
+	}
+}
+
+func TestWorkspaceTrustAnswerOnlySkipsOnS(t *testing.T) {
+	for answer, want := range map[string]string{"y\n": workspace.TrustTrusted, "\n": workspace.TrustUntrusted, "No": workspace.TrustUntrusted, "s\n": workspace.TrustSkipped} {
+		if got, ok := workspaceTrustAnswer(answer); !ok || got != want {
+			t.Fatalf("answer %q = %q %v, want %q", answer, got, ok, want)
+		}
+	}
+	for _, answer := range []string{"ues\n", "t", "yess"} {
+		if got, ok := workspaceTrustAnswer(answer); ok {
+			t.Fatalf("expected %q to be asked again, got %q", answer, got)
+		}
+	}
+}
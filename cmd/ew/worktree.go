@@ -0,0 +1,106 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ashwch/ew/internal/history"
+	"github.com/ashwch/ew/internal/hook"
+	"github.com/ashwch/ew/internal/workspace"
+)
+
+// repoBoost is added to history matches that the hooks saw run in one of
+// this repository's checkouts. Like the temporal signals it reorders close
+// matches without outweighing a clearly better one.
+const repoBoost = 2.0
+
+// maxPromptWorktrees caps how many sibling checkouts the prompt lists.
+const maxPromptWorktrees = 5
+
+// runtimeRepo is the git repository the working directory belongs to, nil
+// outside one.
+var runtimeRepo *workspace.Repo
+
+func detectRuntimeRepo() *workspace.Repo {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil
+	}
+	repo, ok := workspace.DetectRepo(cwd)
+	if !ok {
+		return nil
+	}
+	return &repo
+}
+
+// projectContextPrompt tells the provider which repository and branch the
+// user is in, and, in a linked worktree, that other checkouts of the same
+// repository exist so it does not confuse their paths with this one.
+func projectContextPrompt(repo *workspace.Repo) string {
+	if repo == nil {
+		return ""
+	}
+	lines := []string{"repo=" + displayPath(repo.Root)}
+	if repo.Linked {
+		lines = append(lines, "worktree="+displayPath(repo.Worktree)+" (linked worktree of repo)")
+	}
+	if repo.Branch != "" {
+		lines = append(lines, "branch="+repo.Branch)
+	} else {
+		lines = append(lines, "branch=(detached HEAD)")
+	}
+	siblings := []string{}
+	for _, path := range repo.Worktrees {
+		if path != repo.Worktree {
+			siblings = append(siblings, displayPath(path))
+		}
+	}
+	if len(siblings) > maxPromptWorktrees {
+		siblings = append(siblings[:maxPromptWorktrees], "...")
+	}
+	if len(siblings) > 0 {
+		lines = append(lines, "other_worktrees="+strings.Join(siblings, ", "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// applyRepoBoost ranks history commands that ran in this repository,
+// whichever of its worktrees they ran in, above the same commands from
+// elsewhere, records the contribution as the "repo" signal, and re-sorts.
+func applyRepoBoost(matches []history.Match) []history.Match {
+	if runtimeRepo == nil || len(matches) == 0 {
+		return matches
+	}
+	events, err := hook.RecentEvents(frequentEventWindow)
+	if err != nil || len(events) == 0 {
+		return matches
+	}
+	return boostRepoMatches(matches, *runtimeRepo, events)
+}
+
+func boostRepoMatches(matches []history.Match, repo workspace.Repo, events []hook.Event) []history.Match {
+	ranHere := map[string]struct{}{}
+	for _, event := range events {
+		if event.CWD != "" && repo.Contains(event.CWD) {
+			ranHere[strings.Join(strings.Fields(event.Command), " ")] = struct{}{}
+		}
+	}
+	if len(ranHere) == 0 {
+		return matches
+	}
+	for idx := range matches {
+		if _, ok := ranHere[strings.Join(strings.Fields(matches[idx].Command), " ")]; !ok {
+			continue
+		}
+		if matches[idx].Signals == nil {
+			matches[idx].Signals = map[string]float64{"text": matches[idx].Score}
+		}
+		matches[idx].Signals["repo"] = repoBoost
+		matches[idx].Score += repoBoost
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+	return matches
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ashwch/ew/internal/history"
+	"github.com/ashwch/ew/internal/hook"
+	"github.com/ashwch/ew/internal/workspace"
+)
+
+func TestProjectContextPromptNamesWorktreeAndBranch(t *testing.T) {
+	t.Setenv("HOME", "/home/dev")
+	repo := &workspace.Repo{
+		Root:      "/home/dev/app",
+		Worktree:  "/home/dev/app-feature",
+		Branch:    "feature/login",
+		Linked:    true,
+		Worktrees: []string{"/home/dev/app", "/home/dev/app-feature", "/home/dev/app-hotfix"},
+	}
+	got := projectContextPrompt(repo)
+	want := "repo=~/app\nworktree=~/app-feature (linked worktree of repo)\nbranch=feature/login\nother_worktrees=~/app, ~/app-hotfix"
+	if got != want {
+		t.Fatalf("got %q\nwant %q", got, want)
+	}
+	if projectContextPrompt(nil) != "" {
+		t.Fatalf("expected no project context outside a repository")
+	}
+}
+
+func TestBoostRepoMatchesPrefersCommandsFromSiblingWorktrees(t *testing.T) {
+	repo := workspace.Repo{Root: "/src/app", Worktree: "/src/app-feature", Linked: true, Worktrees: []string{"/src/app", "/src/app-feature"}}
+	events := []hook.Event{
+		{Command: "make test", CWD: "/src/app/pkg"},
+		{Command: "go test ./...", CWD: "/src/other"},
+	}
+	matches := []history.Match{
+		{Command: "go test ./...", Score: 5},
+		{Command: "make  test", Score: 4},
+	}
+	got := boostRepoMatches(matches, repo, events)
+	if got[0].Command != "make  test" || got[0].Signals["repo"] != repoBoost || got[0].Signals["text"] != 4 {
+		t.Fatalf("expected the command from the main checkout ranked first, got %+v", got)
+	}
+	if got[1].Signals != nil {
+		t.Fatalf("expected no signal for a command run outside the repo, got %+v", got[1])
+	}
+	if !strings.Contains(got[0].Breakdown(), "repo +2.00") {
+		t.Fatalf("expected the repo signal in the breakdown, got %q", got[0].Breakdown())
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	ewrt "github.com/ashwch/ew/internal/runtime"
+	"github.com/ashwch/ew/internal/safety"
+)
+
+// yoloRegexPrefix marks a --yolo-for pattern as a regular expression
+// rather than a glob.
+const yoloRegexPrefix = "re:"
+
+// compileYoloScope turns --yolo-for patterns into regular expressions. A
+// glob must match the whole command, with * matching anything including
+// spaces and slashes and ? matching one character; a "re:" pattern is an
+// unanchored regular expression.
+func compileYoloScope(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			return nil, fmt.Errorf("--yolo-for needs a pattern, e.g. --yolo-for \"git *\"")
+		}
+		expr, isRegex := strings.CutPrefix(pattern, yoloRegexPrefix)
+		if !isRegex {
+			compiled = append(compiled, safety.GlobRegexp(strings.Join(strings.Fields(pattern), " ")))
+			continue
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --yolo-for pattern %q: %w", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// scopedYoloMode is the mode --yolo-for gives command: yolo when every
+// statement in it matches one of the patterns, confirm otherwise. The usual
+// risk policy still applies to the result.
+func scopedYoloMode(patterns []string, command string) string {
+	compiled, err := compileYoloScope(patterns)
+	if err != nil {
+		return "confirm"
+	}
+	statements := ewrt.Statements(command)
+	if len(statements) == 0 {
+		return "confirm"
+	}
+	for _, statement := range statements {
+		if !yoloScopeMatches(compiled, statement) {
+			return "confirm"
+		}
+	}
+	return "yolo"
+}
+
+func yoloScopeMatches(compiled []*regexp.Regexp, statement string) bool {
+	if safety.HasHiddenCommand(statement) {
+		return false
+	}
+	statement = strings.Join(strings.Fields(statement), " ")
+	for _, re := range compiled {
+		if re.MatchString(statement) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ashwch/ew/internal/config"
+)
+
+func TestScopedYoloModeOnlyRunsMatchingCommands(t *testing.T) {
+	cases := []struct {
+		patterns []string
+		command  string
+		want     string
+	}{
+		{[]string{"git *"}, "git push origin feature/login", "yolo"},
+		{[]string{"git *"}, "git  status", "yolo"},
+		{[]string{"git *"}, "gitk --all", "confirm"},
+		{[]string{"git *"}, "git fetch && git rebase origin/main", "yolo"},
+		{[]string{"git *"}, "git fetch && rm -rf build", "confirm"},
+		{[]string{"git *"}, "git log $(rm -rf ~)", "confirm"},
+		{[]string{"git *"}, "git log > ~/.bashrc", "confirm"},
+		{[]string{"git *"}, "git fetch & rm -rf build", "confirm"},
+		{[]string{"git *", "make test"}, "make test", "yolo"},
+		{[]string{"make test"}, "make test-all", "confirm"},
+		{[]string{"ls ?"}, "ls a", "yolo"},
+		{[]string{`re:^kubectl (get|describe) `}, "kubectl get pods -A", "yolo"},
+		{[]string{`re:^kubectl (get|describe) `}, "kubectl delete pod api", "confirm"},
+	}
+	for _, tc := range cases {
+		if got := scopedYoloMode(tc.patterns, tc.command); got != tc.want {
+			t.Fatalf("scopedYoloMode(%q, %q) = %q, want %q", tc.patterns, tc.command, got, tc.want)
+		}
+	}
+}
+
+func TestScopedYoloStillHonoursRiskPolicy(t *testing.T) {
+	cfg := config.Default()
+	mode := scopedYoloMode([]string{"git *"}, "git push --force origin main")
+	if mode, _ = applyExecutionRiskPolicy(cfg, mode, "git push --force origin main", "high"); mode != "confirm" {
+		t.Fatalf("expected a high-risk match to still need confirmation, got %q", mode)
+	}
+}
+
+func TestParseArgsYoloFor(t *testing.T) {
+	opts, prompt, err := parseArgs([]string{"--execute", "--yolo-for", "git *", "--yolo-for", "re:^make ", "sync", "my", "branch"})
+	if err != nil || len(opts.YoloFor) != 2 || prompt != "sync my branch" {
+		t.Fatalf("unexpected parse result %+v %q %v", opts, prompt, err)
+	}
+	for _, args := range [][]string{
+		{"--yolo-for", "git *", "--mode", "yolo", "sync"},
+		{"--yolo-for", "git *", "--save", "sync"},
+		{"--yolo-for", "re:(", "sync"},
+		{"--yolo-for", " ", "sync"},
+	} {
+		if _, _, err := parseArgs(args); err == nil {
+			t.Fatalf("expected %q to fail", args)
+		}
+	}
+}
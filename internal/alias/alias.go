@@ -0,0 +1,248 @@
+// Package alias turns learned commands into shell aliases. It writes them
+// to an aliases file that the shell hooks source, and remembers which ones
+// ew wrote so they can be listed and removed again.
+package alias
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	ewrt "github.com/ashwch/ew/internal/runtime"
+	"github.com/ashwch/ew/internal/state"
+)
+
+const storeFileName = "aliases.json"
+
+// header opens a new aliases file.
+const header = "# Shell aliases made by ew. `ew list aliases` shows them and\n# `ew remove alias <name>` deletes one; edits by hand are kept.\n"
+
+// ErrUnsupportedShell is returned for shells ew cannot write aliases for.
+var ErrUnsupportedShell = errors.New("aliases can only be made for zsh, bash, and fish")
+
+var validName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// Entry is one alias ew wrote.
+type Entry struct {
+	Name    string `json:"name"`
+	Command string `json:"command"`
+	// Query is the memory query the command was learned for, when there
+	// was one.
+	Query string `json:"query,omitempty"`
+	Shell string `json:"shell"`
+	File  string `json:"file"`
+	// Line is the exact definition written to File, so removing the alias
+	// leaves the rest of the file alone.
+	Line      string `json:"line"`
+	CreatedAt string `json:"created_at"`
+}
+
+// Store is every alias ew wrote, oldest first.
+type Store struct {
+	Aliases []Entry `json:"aliases"`
+}
+
+// Load reads the alias store from the state backend.
+func Load() (Store, error) {
+	backend, err := state.Current()
+	if err != nil {
+		return Store{}, err
+	}
+	data, err := backend.Read(storeFileName)
+	if err != nil {
+		return Store{}, fmt.Errorf("could not read alias store: %w", err)
+	}
+	if data == nil {
+		return Store{}, nil
+	}
+	var store Store
+	if err := json.Unmarshal(data, &store); err != nil {
+		return Store{}, fmt.Errorf("could not parse alias store: %w", err)
+	}
+	return store, nil
+}
+
+// Save writes the alias store to the state backend.
+func Save(store Store) error {
+	backend, err := state.Current()
+	if err != nil {
+		return err
+	}
+	payload, err := json.MarshalIndent(store, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode alias store: %w", err)
+	}
+	if err := backend.Write(storeFileName, payload); err != nil {
+		return fmt.Errorf("could not save aliases: %w", err)
+	}
+	return nil
+}
+
+// Find returns the alias called name.
+func (s *Store) Find(name string) (Entry, bool) {
+	for _, entry := range s.Aliases {
+		if entry.Name == name {
+			return entry, true
+		}
+	}
+	return Entry{}, false
+}
+
+// Put adds entry, replacing any alias of the same name, and returns the
+// one it replaced.
+func (s *Store) Put(entry Entry) (Entry, bool) {
+	if entry.CreatedAt == "" {
+		entry.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	previous, replaced := s.Remove(entry.Name)
+	s.Aliases = append(s.Aliases, entry)
+	return previous, replaced
+}
+
+// Remove drops the alias called name and returns it.
+func (s *Store) Remove(name string) (Entry, bool) {
+	for idx, entry := range s.Aliases {
+		if entry.Name == name {
+			s.Aliases = append(s.Aliases[:idx], s.Aliases[idx+1:]...)
+			return entry, true
+		}
+	}
+	return Entry{}, false
+}
+
+// FileName is the aliases file for shell: aliases.sh for zsh and bash,
+// which share one, and aliases.fish for fish.
+func FileName(shell string) (string, error) {
+	switch shell {
+	case "zsh", "bash":
+		return "aliases.sh", nil
+	case "fish":
+		return "aliases.fish", nil
+	}
+	return "", ErrUnsupportedShell
+}
+
+// ValidName reports whether name can be used as an alias in every shell
+// ew writes for.
+func ValidName(name string) bool {
+	return validName.MatchString(name)
+}
+
+// Suggest names an alias for query after the first letter of each word,
+// "pcb" for "push current branch". When that is too short or taken it
+// joins the words with dashes, then numbers them.
+func Suggest(query string, taken func(string) bool) string {
+	words := []string{}
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		word = strings.Map(func(r rune) rune {
+			if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+				return r
+			}
+			return -1
+		}, word)
+		if word != "" {
+			words = append(words, word)
+		}
+	}
+	if len(words) == 0 {
+		words = []string{"ew", "alias"}
+	}
+	initials := ""
+	for _, word := range words {
+		initials += word[:1]
+	}
+	if initials[0] >= '0' && initials[0] <= '9' {
+		initials = "x" + initials
+	}
+	if len(initials) >= 2 && !taken(initials) {
+		return initials
+	}
+	joined := strings.Join(words, "-")
+	if joined[0] >= '0' && joined[0] <= '9' {
+		joined = "x" + joined
+	}
+	name := joined
+	for n := 2; taken(name); n++ {
+		name = joined + strconv.Itoa(n)
+	}
+	return name
+}
+
+// Definition is the line that defines name as command in shell.
+func Definition(shell, name, command string) (string, error) {
+	switch shell {
+	case "zsh", "bash":
+		return "alias " + name + "=" + ewrt.ShellQuote(command), nil
+	case "fish":
+		return "alias " + name + " " + ewrt.FishQuote(command), nil
+	}
+	return "", ErrUnsupportedShell
+}
+
+// SourceLine is the rc-file line that loads path in shell when it exists.
+func SourceLine(shell, path string) (string, error) {
+	switch shell {
+	case "zsh", "bash":
+		return "[ -f " + ewrt.ShellQuote(path) + " ] && . " + ewrt.ShellQuote(path), nil
+	case "fish":
+		return "test -f " + ewrt.FishQuote(path) + "; and source " + ewrt.FishQuote(path), nil
+	}
+	return "", ErrUnsupportedShell
+}
+
+// AppendLine adds line to the aliases file at path, creating it.
+func AppendLine(path, line string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("could not create aliases directory: %w", err)
+	}
+	existing, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("could not read aliases file: %w", err)
+	}
+	content := string(existing)
+	if content == "" {
+		content = header
+	} else if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	content += line + "\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		return fmt.Errorf("could not write aliases file: %w", err)
+	}
+	return nil
+}
+
+// RemoveLine drops every copy of line from the aliases file at path and
+// reports whether there was one. A missing file has nothing to remove.
+func RemoveLine(path, line string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("could not read aliases file: %w", err)
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	kept := make([]string, 0, len(lines))
+	found := false
+	for _, current := range lines {
+		if strings.TrimRight(current, "\r\n") == line {
+			found = true
+			continue
+		}
+		kept = append(kept, current)
+	}
+	if !found {
+		return false, nil
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(kept, "")), 0o600); err != nil {
+		return false, fmt.Errorf("could not write aliases file: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,86 @@
+package alias
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSuggestUsesInitialsUnlessTaken(t *testing.T) {
+	none := func(string) bool { return false }
+	if got := Suggest("push current branch", none); got != "pcb" {
+		t.Fatalf("expected pcb, got %q", got)
+	}
+	taken := map[string]bool{"pcb": true, "push-current-branch": true}
+	if got := Suggest("Push current branch!", func(name string) bool { return taken[name] }); got != "push-current-branch2" {
+		t.Fatalf("expected a numbered name, got %q", got)
+	}
+	if got := Suggest("deploy", none); got != "deploy" {
+		t.Fatalf("expected a one-word query to keep its word, got %q", got)
+	}
+	if got := Suggest("2fa codes", none); !ValidName(got) {
+		t.Fatalf("expected a valid name, got %q", got)
+	}
+}
+
+func TestDefinitionQuotesForEachShell(t *testing.T) {
+	line, err := Definition("bash", "gl", "git log --format='%h %s'")
+	if err != nil || line != `alias gl='git log --format='\''%h %s'\'''` {
+		t.Fatalf("unexpected bash definition %q %v", line, err)
+	}
+	line, err = Definition("fish", "gl", `echo 'a\b'`)
+	if err != nil || line != `alias gl 'echo \'a\\b\''` {
+		t.Fatalf("unexpected fish definition %q %v", line, err)
+	}
+	if _, err := Definition("nu", "gl", "git log"); err != ErrUnsupportedShell {
+		t.Fatalf("expected nushell to be unsupported, got %v", err)
+	}
+}
+
+func TestAppendAndRemoveLineKeepOtherLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ew", "aliases.sh")
+	if err := AppendLine(path, "alias pcb='git push origin HEAD'"); err != nil {
+		t.Fatal(err)
+	}
+	if err := AppendLine(path, "alias gs='git status'"); err != nil {
+		t.Fatal(err)
+	}
+	found, err := RemoveLine(path, "alias pcb='git push origin HEAD'")
+	if err != nil || !found {
+		t.Fatalf("expected the line removed, got %v %v", found, err)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(data), header) || !strings.HasSuffix(string(data), "alias gs='git status'\n") || strings.Contains(string(data), "pcb") {
+		t.Fatalf("unexpected aliases file:\n%s", data)
+	}
+	if found, err := RemoveLine(filepath.Join(t.TempDir(), "missing.sh"), "x"); found || err != nil {
+		t.Fatalf("expected a missing file to have nothing to remove, got %v %v", found, err)
+	}
+}
+
+func TestStorePutReplacesByNameAndRoundTrips(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, ".local", "state"))
+
+	store := Store{}
+	store.Put(Entry{Name: "pcb", Command: "git push"})
+	previous, replaced := store.Put(Entry{Name: "pcb", Command: "git push origin HEAD"})
+	if !replaced || previous.Command != "git push" || len(store.Aliases) != 1 {
+		t.Fatalf("expected the old alias replaced, got %+v %v", store.Aliases, replaced)
+	}
+	if err := Save(store); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry, ok := loaded.Find("pcb"); !ok || entry.Command != "git push origin HEAD" || entry.CreatedAt == "" {
+		t.Fatalf("expected the alias to round trip, got %+v", loaded)
+	}
+	if _, ok := loaded.Remove("pcb"); !ok || len(loaded.Aliases) != 0 {
+		t.Fatalf("expected the alias removed, got %+v", loaded)
+	}
+}
@@ -0,0 +1,658 @@
+// Package analysis reads a shell command without running it and reports
+// what it would touch: files written or deleted, network destinations,
+// package managers, processes, and privilege escalation. It is a static
+// reading of the words, so anything decided at run time (a variable, a
+// command substitution, a script) is reported as not analyzed rather than
+// guessed.
+package analysis
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Effects is what a command would do.
+type Effects struct {
+	Writes    []string `json:"writes,omitempty"`
+	Deletes   []string `json:"deletes,omitempty"`
+	Network   []string `json:"network,omitempty"`
+	Packages  []string `json:"packages,omitempty"`
+	Processes []string `json:"processes,omitempty"`
+	// Privilege names the tools that run part of the command as another
+	// user, such as sudo.
+	Privilege []string `json:"privilege,omitempty"`
+	// Opaque lists the parts whose effect is only known at run time.
+	Opaque []string `json:"opaque,omitempty"`
+	// Unknown lists programs the classifier has no rule for, so an empty
+	// summary is not read as a harmless command.
+	Unknown []string `json:"unknown,omitempty"`
+}
+
+// Empty reports whether nothing at all was found, which means every
+// program is known to only read.
+func (e Effects) Empty() bool {
+	return len(e.Writes)+len(e.Deletes)+len(e.Network)+len(e.Packages)+len(e.Processes)+len(e.Privilege)+len(e.Opaque)+len(e.Unknown) == 0
+}
+
+// Lines is the summary shown before the command runs.
+func (e Effects) Lines() []string {
+	if e.Empty() {
+		return []string{"side effects: none found, it only reads"}
+	}
+	lines := []string{"side effects:"}
+	for _, part := range []struct {
+		label string
+		items []string
+	}{
+		{"privilege", e.Privilege},
+		{"deletes", e.Deletes},
+		{"writes", e.Writes},
+		{"network", e.Network},
+		{"packages", e.Packages},
+		{"processes", e.Processes},
+		{"not analyzed", e.Opaque},
+		{"unknown programs", e.Unknown},
+	} {
+		if len(part.items) > 0 {
+			lines = append(lines, fmt.Sprintf("  %s: %s", part.label, strings.Join(part.items, ", ")))
+		}
+	}
+	return lines
+}
+
+// readOnly are programs that only read, given no redirection.
+var readOnly = map[string]bool{
+	"ls": true, "cat": true, "less": true, "more": true, "head": true, "tail": true, "grep": true, "rg": true,
+	"ag": true, "echo": true, "printf": true, "pwd": true, "wc": true, "sort": true, "uniq": true, "cut": true,
+	"tr": true, "which": true, "whereis": true, "type": true, "file": true, "stat": true, "du": true, "df": true,
+	"ps": true, "top": true, "htop": true, "whoami": true, "id": true, "uname": true, "date": true, "env": true,
+	"printenv": true, "diff": true, "cmp": true, "jq": true, "yq": true, "awk": true, "column": true, "tree": true,
+	"man": true, "tldr": true, "history": true, "true": true, "false": true, "test": true, "[": true,
+	"basename": true, "dirname": true, "realpath": true, "readlink": true, "lsof": true, "free": true,
+	"uptime": true, "hostname": true, "bat": true, "eza": true, "exa": true, "fd": true, "sleep": true,
+	"nslookup": true, "dig": true, "host": true, "ping": true, "traceroute": true, "netstat": true, "ss": true,
+	"md5sum": true, "sha256sum": true, "shasum": true, "base64": true, "xxd": true, "od": true, "cd": true,
+	"pbcopy": true, "pbpaste": true, "open": true, "code": true, "vim": true, "nvim": true, "nano": true,
+}
+
+// wrappers run the rest of the command line as a command of their own.
+var wrappers = map[string]bool{
+	"nohup": true, "time": true, "nice": true, "command": true, "exec": true, "builtin": true, "caffeinate": true,
+	"timeout": true, "stdbuf": true, "ionice": true,
+}
+
+// privileged run the rest of the command as another user.
+var privileged = map[string]bool{"sudo": true, "doas": true, "pkexec": true, "run0": true}
+
+// packageManagers map a tool to its subcommands that change what is
+// installed. Those also fetch from the tool's registry.
+var packageManagers = map[string][]string{
+	"brew":     {"install", "uninstall", "remove", "rm", "upgrade", "update", "reinstall", "tap", "untap", "link", "unlink"},
+	"apt":      {"install", "remove", "purge", "upgrade", "full-upgrade", "dist-upgrade", "autoremove", "update"},
+	"apt-get":  {"install", "remove", "purge", "upgrade", "dist-upgrade", "autoremove", "update"},
+	"yum":      {"install", "remove", "erase", "update", "upgrade"},
+	"dnf":      {"install", "remove", "erase", "update", "upgrade"},
+	"pacman":   {"-S", "-Syu", "-Sy", "-R", "-Rs", "-U"},
+	"zypper":   {"install", "in", "remove", "rm", "update", "up"},
+	"apk":      {"add", "del", "upgrade", "update"},
+	"snap":     {"install", "remove", "refresh"},
+	"port":     {"install", "uninstall", "upgrade", "selfupdate"},
+	"npm":      {"install", "i", "ci", "uninstall", "remove", "rm", "update", "upgrade", "link"},
+	"pnpm":     {"install", "i", "add", "remove", "rm", "update", "up"},
+	"yarn":     {"install", "add", "remove", "upgrade", "up"},
+	"bun":      {"install", "i", "add", "remove", "rm", "update"},
+	"pip":      {"install", "uninstall", "download"},
+	"pip3":     {"install", "uninstall", "download"},
+	"pipx":     {"install", "uninstall", "upgrade", "reinstall"},
+	"uv":       {"add", "remove", "sync", "pip", "tool"},
+	"poetry":   {"add", "remove", "install", "update", "lock"},
+	"cargo":    {"install", "uninstall", "add", "remove", "update"},
+	"gem":      {"install", "uninstall", "update"},
+	"composer": {"install", "require", "remove", "update"},
+	"go":       {"install", "get"},
+}
+
+// gitNetwork are git subcommands that talk to a remote.
+var gitNetwork = map[string]bool{"push": true, "pull": true, "fetch": true, "clone": true, "ls-remote": true, "submodule": true}
+
+// gitWrites are git subcommands that change the repository or work tree.
+var gitWrites = map[string]bool{
+	"add": true, "commit": true, "checkout": true, "switch": true, "restore": true, "reset": true, "merge": true,
+	"rebase": true, "cherry-pick": true, "revert": true, "stash": true, "pull": true, "clone": true, "mv": true,
+	"tag": true, "branch": true, "am": true, "apply": true, "init": true, "worktree": true, "gc": true,
+}
+
+// Analyze reports the effects of command.
+func Analyze(command string) Effects {
+	var e Effects
+	for _, cmd := range splitCommands(tokenize(command)) {
+		for _, r := range cmd.redirects {
+			if strings.Contains(r.op, ">") {
+				e.addFile(&e.Writes, r.target)
+			}
+		}
+		e.classify(cmd.words)
+	}
+	return e
+}
+
+func (e *Effects) classify(words []token) {
+	for _, w := range words {
+		if w.expands && (strings.Contains(w.text, "$(") || strings.Contains(w.text, "`")) {
+			add(&e.Opaque, "command substitution "+w.text)
+		}
+	}
+	// Leading NAME=value assignments only set the environment.
+	for len(words) > 0 && isAssignment(words[0].text) {
+		words = words[1:]
+	}
+	if len(words) == 0 {
+		return
+	}
+	if words[0].expands {
+		add(&e.Opaque, "program named by "+words[0].text)
+		return
+	}
+	name := path.Base(words[0].text)
+	args := words[1:]
+	switch {
+	case privileged[name]:
+		add(&e.Privilege, name)
+		e.classify(skipOptions(args, "-u", "-g", "-C", "-D", "-h", "-p", "-r", "-t", "-U"))
+		return
+	case name == "su":
+		add(&e.Privilege, name)
+		add(&e.Opaque, "su shell")
+		return
+	case wrappers[name]:
+		if name == "timeout" {
+			args = skipOptions(args, "-s", "-k", "--signal", "--kill-after")
+			if len(args) > 0 {
+				args = args[1:]
+			}
+		} else {
+			args = skipOptions(args, "-n", "-c", "-o", "-e", "-i")
+		}
+		e.classify(args)
+		return
+	case name == "env":
+		rest := skipOptions(args, "-u", "-C", "-S")
+		for len(rest) > 0 && isAssignment(rest[0].text) {
+			rest = rest[1:]
+		}
+		e.classify(rest)
+		return
+	case name == "xargs":
+		rest := skipOptions(args, "-n", "-I", "-L", "-P", "-d", "-E", "-s")
+		if len(rest) > 0 {
+			e.classify(rest)
+			add(&e.Opaque, "xargs arguments from input")
+		}
+		return
+	case name == "eval" || name == "source" || name == ".":
+		add(&e.Opaque, name+" "+joinWords(args))
+		return
+	case isShell(name):
+		if script := flagValue(args, "-c"); script != "" {
+			nested := Analyze(script)
+			e.merge(nested)
+			return
+		}
+		add(&e.Opaque, "script run by "+name)
+		return
+	}
+
+	operands, _ := splitArgs(args)
+	switch name {
+	case "rm", "unlink", "shred", "rmdir", "trash", "trash-put":
+		e.addFiles(&e.Deletes, operands)
+	case "mv":
+		if len(operands) >= 2 {
+			e.addFiles(&e.Deletes, operands[:len(operands)-1])
+			e.addFile(&e.Writes, operands[len(operands)-1])
+		}
+	case "cp", "install", "ln":
+		if len(operands) >= 2 {
+			e.addFile(&e.Writes, operands[len(operands)-1])
+		}
+	case "touch", "mkdir", "tee", "truncate":
+		e.addFiles(&e.Writes, operands)
+	case "chmod", "chown", "chgrp":
+		if len(operands) >= 2 {
+			e.addFiles(&e.Writes, operands[1:])
+		}
+	case "sed", "perl":
+		if hasInPlace(args) {
+			e.addFiles(&e.Writes, scriptFiles(args))
+		}
+	case "dd":
+		for _, w := range operands {
+			if target, ok := strings.CutPrefix(w.text, "of="); ok {
+				e.addFile(&e.Writes, token{text: target, expands: w.expands})
+			}
+		}
+	case "tar":
+		if mode := firstWord(args); strings.ContainsAny(strings.TrimPrefix(mode, "-"), "xc") {
+			if strings.Contains(mode, "c") {
+				// The archive follows the f: "-czf out.tgz" or "czf out.tgz".
+				at := 0
+				if !strings.HasPrefix(mode, "-") {
+					at = 1
+				}
+				if strings.Contains(mode, "f") && len(operands) > at {
+					add(&e.Writes, operands[at].text)
+				}
+			} else {
+				add(&e.Writes, "files extracted into "+firstNonEmpty(flagValue(args, "-C"), "."))
+			}
+		}
+	case "unzip":
+		add(&e.Writes, "files extracted into "+firstNonEmpty(flagValue(args, "-d"), "."))
+	case "find":
+		for _, w := range args {
+			switch w.text {
+			case "-delete":
+				add(&e.Deletes, "files matched by find")
+			case "-exec", "-execdir", "-ok":
+				add(&e.Opaque, "find "+w.text)
+			}
+		}
+	case "curl", "wget", "http", "https", "xh":
+		e.download(name, args)
+	case "ssh", "mosh", "sftp", "telnet", "nc", "ncat":
+		if host := remoteHost(operands); host != "" {
+			add(&e.Network, host)
+		}
+		if name == "ssh" && len(operands) > 1 {
+			add(&e.Opaque, "remote command "+joinWords(operands[1:]))
+		}
+	case "scp", "rsync":
+		for idx, w := range operands {
+			if host, _, ok := strings.Cut(w.text, ":"); ok && host != "" && !strings.Contains(host, "/") {
+				add(&e.Network, hostOnly(host))
+			} else if idx == len(operands)-1 && len(operands) > 1 {
+				e.addFile(&e.Writes, w)
+			}
+		}
+		if name == "rsync" && slices.ContainsFunc(args, func(w token) bool { return strings.HasPrefix(w.text, "--delete") }) {
+			add(&e.Deletes, "files missing from the rsync source")
+		}
+	case "git":
+		e.git(args)
+	case "docker", "podman":
+		e.container(name, args)
+	case "kubectl", "helm":
+		if sub := firstWord(operands); sub != "" && !slices.Contains([]string{"get", "describe", "logs", "diff", "version", "top", "explain", "list", "status", "template", "show"}, sub) {
+			add(&e.Network, name+" cluster ("+sub+")")
+			if sub == "delete" || sub == "uninstall" {
+				add(&e.Deletes, name+" "+joinWords(operands[1:]))
+			}
+		}
+	case "kill", "pkill", "killall":
+		add(&e.Processes, name+" "+joinWords(operands))
+	case "systemctl", "service", "launchctl":
+		if sub := firstWord(operands); sub != "" && !slices.Contains([]string{"status", "list", "list-units", "show", "is-active", "is-enabled", "cat", "print"}, sub) {
+			add(&e.Processes, name+" "+joinWords(operands))
+		}
+	case "shutdown", "reboot", "halt", "poweroff":
+		add(&e.Processes, name+" (the whole machine)")
+	case "crontab":
+		if !slices.ContainsFunc(args, func(w token) bool { return w.text == "-l" }) {
+			add(&e.Writes, "crontab")
+		}
+	default:
+		if managed, ok := packageManagers[name]; ok {
+			e.packageManager(name, args, managed)
+			return
+		}
+		if !readOnly[name] {
+			add(&e.Unknown, name)
+		}
+	}
+}
+
+func (e *Effects) packageManager(name string, args []token, managed []string) {
+	operands, flags := splitArgs(args)
+	sub := firstWord(operands)
+	if name == "pacman" {
+		if len(flags) > 0 {
+			sub = flags[0].text
+		}
+	}
+	if name == "uv" && sub == "pip" && len(operands) > 1 {
+		sub = operands[1].text
+		if sub != "install" && sub != "uninstall" && sub != "sync" {
+			return
+		}
+	}
+	if !slices.Contains(managed, sub) {
+		if sub != "" && (name == "npm" || name == "pnpm" || name == "yarn" || name == "bun") && slices.Contains([]string{"run", "exec", "x", "dlx", "test", "start"}, sub) {
+			add(&e.Opaque, name+" "+sub+" "+joinWords(operands[1:]))
+		}
+		if name == "yarn" && sub == "" {
+			add(&e.Packages, "yarn install")
+			add(&e.Network, "yarn registry")
+		}
+		return
+	}
+	summary := strings.TrimSpace(name + " " + joinWords(args))
+	add(&e.Packages, summary)
+	add(&e.Network, name+" registry")
+}
+
+func (e *Effects) download(name string, args []token) {
+	operands, _ := splitArgs(args)
+	for _, w := range operands {
+		if host := urlHost(w.text); host != "" {
+			add(&e.Network, host)
+		}
+	}
+	switch name {
+	case "curl":
+		if out := firstNonEmpty(flagValue(args, "-o"), flagValue(args, "--output")); out != "" {
+			add(&e.Writes, out)
+		} else if slices.ContainsFunc(args, func(w token) bool { return w.text == "-O" || w.text == "--remote-name" }) {
+			for _, w := range operands {
+				if base := urlBase(w.text); base != "" {
+					add(&e.Writes, base)
+				}
+			}
+		}
+		if method := firstNonEmpty(flagValue(args, "-X"), flagValue(args, "--request")); method != "" && !strings.EqualFold(method, "GET") {
+			add(&e.Network, "sends "+strings.ToUpper(method))
+		} else if slices.ContainsFunc(args, func(w token) bool {
+			return w.text == "-d" || w.text == "--data" || w.text == "-F" || w.text == "--form" || w.text == "-T" || w.text == "--upload-file"
+		}) {
+			add(&e.Network, "sends data")
+		}
+	case "wget":
+		if out := firstNonEmpty(flagValue(args, "-O"), flagValue(args, "--output-document")); out != "" {
+			add(&e.Writes, out)
+		} else {
+			for _, w := range operands {
+				if base := urlBase(w.text); base != "" {
+					add(&e.Writes, base)
+				}
+			}
+		}
+	}
+}
+
+func (e *Effects) git(args []token) {
+	args = skipOptions(args, "-C", "-c", "--git-dir", "--work-tree")
+	operands, _ := splitArgs(args)
+	sub := firstWord(operands)
+	if sub == "" {
+		return
+	}
+	rest := operands[1:]
+	if gitNetwork[sub] {
+		remote := "origin"
+		if len(rest) > 0 {
+			remote = rest[0].text
+			if host := urlHost(remote); host != "" {
+				remote = host
+			} else if host, _, ok := strings.Cut(remote, ":"); ok && strings.Contains(host, "@") {
+				remote = hostOnly(host)
+			}
+		}
+		if sub == "submodule" {
+			remote = "submodule remotes"
+		}
+		add(&e.Network, "git "+sub+" "+remote)
+	}
+	switch {
+	case sub == "clean":
+		add(&e.Deletes, "untracked files (git clean)")
+	case sub == "rm":
+		e.addFiles(&e.Deletes, rest)
+	case sub == "branch" && slices.ContainsFunc(args, func(w token) bool { return w.text == "-d" || w.text == "-D" }):
+		add(&e.Deletes, "git branch "+joinWords(rest))
+	case sub == "reset" && slices.ContainsFunc(args, func(w token) bool { return w.text == "--hard" }):
+		add(&e.Deletes, "uncommitted changes (git reset --hard)")
+	case sub == "push" && slices.ContainsFunc(args, func(w token) bool { return strings.HasPrefix(w.text, "--force") || w.text == "-f" }):
+		add(&e.Deletes, "remote commits a force push replaces")
+	case gitWrites[sub]:
+		add(&e.Writes, "git repository ("+sub+")")
+	case sub == "config" && len(rest) > 1:
+		add(&e.Writes, "git config")
+	}
+}
+
+func (e *Effects) container(name string, args []token) {
+	operands, _ := splitArgs(args)
+	sub := firstWord(operands)
+	switch sub {
+	case "pull", "push", "login", "search":
+		add(&e.Network, name+" registry")
+	case "run", "create":
+		add(&e.Network, name+" registry (pulls missing images)")
+		add(&e.Processes, name+" "+sub+" "+joinWords(operands[1:]))
+	case "rm", "rmi", "prune", "kill":
+		add(&e.Deletes, name+" "+joinWords(operands))
+	case "compose":
+		add(&e.Processes, name+" "+joinWords(operands))
+	case "start", "stop", "restart", "pause", "unpause":
+		add(&e.Processes, name+" "+joinWords(operands))
+	case "build":
+		add(&e.Writes, name+" image")
+		add(&e.Network, name+" registry (base images)")
+	case "exec":
+		add(&e.Opaque, name+" exec "+joinWords(operands[1:]))
+	case "system", "volume", "network", "image", "container":
+		if len(operands) > 1 && (operands[1].text == "prune" || operands[1].text == "rm") {
+			add(&e.Deletes, name+" "+joinWords(operands))
+		}
+	}
+}
+
+func (e *Effects) merge(other Effects) {
+	for _, pair := range []struct{ into, from *[]string }{
+		{&e.Writes, &other.Writes}, {&e.Deletes, &other.Deletes}, {&e.Network, &other.Network},
+		{&e.Packages, &other.Packages}, {&e.Processes, &other.Processes}, {&e.Privilege, &other.Privilege},
+		{&e.Opaque, &other.Opaque}, {&e.Unknown, &other.Unknown},
+	} {
+		for _, item := range *pair.from {
+			add(pair.into, item)
+		}
+	}
+}
+
+// addFile records a file. Writes to the null device and the terminal are
+// not side effects; a name that expands is shown as written.
+func (e *Effects) addFile(into *[]string, w token) {
+	switch w.text {
+	case "", "-", "/dev/null", "/dev/stdout", "/dev/stderr", "/dev/tty":
+		return
+	}
+	add(into, w.text)
+}
+
+func (e *Effects) addFiles(into *[]string, words []token) {
+	for _, w := range words {
+		e.addFile(into, w)
+	}
+}
+
+func add(into *[]string, item string) {
+	item = strings.TrimSpace(item)
+	if item != "" && !slices.Contains(*into, item) {
+		*into = append(*into, item)
+	}
+}
+
+// splitArgs separates operands from flags. A lone - or anything after --
+// is an operand.
+func splitArgs(args []token) (operands, flags []token) {
+	for idx, w := range args {
+		if w.text == "--" {
+			return append(operands, args[idx+1:]...), flags
+		}
+		if strings.HasPrefix(w.text, "-") && w.text != "-" {
+			flags = append(flags, w)
+			continue
+		}
+		operands = append(operands, w)
+	}
+	return operands, flags
+}
+
+// skipOptions drops leading flags, and the value after each flag in
+// withValue.
+func skipOptions(args []token, withValue ...string) []token {
+	for len(args) > 0 && strings.HasPrefix(args[0].text, "-") {
+		flag := args[0].text
+		args = args[1:]
+		if flag == "--" {
+			break
+		}
+		if slices.Contains(withValue, flag) && len(args) > 0 {
+			args = args[1:]
+		}
+	}
+	return args
+}
+
+// flagValue returns the value given to flag, as "-o file", "-ofile", or
+// "--output=file".
+func flagValue(args []token, flag string) string {
+	for idx, w := range args {
+		switch {
+		case w.text == flag && idx+1 < len(args):
+			return args[idx+1].text
+		case strings.HasPrefix(flag, "--") && strings.HasPrefix(w.text, flag+"="):
+			return strings.TrimPrefix(w.text, flag+"=")
+		case len(flag) == 2 && !strings.HasPrefix(w.text, "--") && strings.HasPrefix(w.text, flag) && len(w.text) > 2:
+			return w.text[2:]
+		}
+	}
+	return ""
+}
+
+// hasInPlace reports an in-place edit: sed -i, sed -Ei, perl -pi -e, or
+// --in-place.
+func hasInPlace(args []token) bool {
+	return slices.ContainsFunc(args, func(w token) bool {
+		if w.text == "--in-place" || strings.HasPrefix(w.text, "--in-place=") {
+			return true
+		}
+		flags, ok := strings.CutPrefix(w.text, "-")
+		return ok && !strings.HasPrefix(flags, "-") && strings.Contains(flags, "i")
+	})
+}
+
+// scriptFiles are the files sed or perl edit: the operands, less the
+// script itself when no -e or -f gave it.
+func scriptFiles(args []token) []token {
+	var (
+		files     []token
+		hasScript bool
+	)
+	for idx := 0; idx < len(args); idx++ {
+		w := args[idx]
+		switch {
+		case w.text == "-e" || w.text == "-f" || w.text == "--expression" || w.text == "--file":
+			hasScript = true
+			idx++
+		case strings.HasPrefix(w.text, "-") && w.text != "-":
+			if !strings.HasPrefix(w.text, "--") && strings.HasSuffix(w.text, "e") {
+				// perl -pie 's/a/b/' takes the script next.
+				hasScript = true
+				idx++
+			}
+		default:
+			files = append(files, w)
+		}
+	}
+	if !hasScript && len(files) > 0 {
+		files = files[1:]
+	}
+	return files
+}
+
+func isAssignment(word string) bool {
+	name, _, ok := strings.Cut(word, "=")
+	if !ok || name == "" {
+		return false
+	}
+	for idx, r := range name {
+		if r != '_' && (r < 'A' || r > 'Z') && (r < 'a' || r > 'z') && (idx == 0 || r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func isShell(name string) bool {
+	switch name {
+	case "sh", "bash", "zsh", "dash", "ksh", "fish":
+		return true
+	}
+	return false
+}
+
+func firstWord(words []token) string {
+	if len(words) == 0 {
+		return ""
+	}
+	return words[0].text
+}
+
+func joinWords(words []token) string {
+	parts := make([]string, 0, len(words))
+	for _, w := range words {
+		parts = append(parts, w.text)
+	}
+	return strings.Join(parts, " ")
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// remoteHost is the host an ssh-like tool connects to: its first operand,
+// without the user.
+func remoteHost(operands []token) string {
+	if len(operands) == 0 {
+		return ""
+	}
+	return hostOnly(operands[0].text)
+}
+
+func hostOnly(target string) string {
+	if _, host, ok := strings.Cut(target, "@"); ok {
+		return host
+	}
+	return target
+}
+
+func urlHost(word string) string {
+	if !strings.Contains(word, "://") {
+		return ""
+	}
+	parsed, err := url.Parse(word)
+	if err != nil || parsed.Host == "" {
+		return ""
+	}
+	return parsed.Hostname()
+}
+
+func urlBase(word string) string {
+	parsed, err := url.Parse(word)
+	if err != nil || parsed.Host == "" {
+		return ""
+	}
+	base := path.Base(parsed.Path)
+	if base == "/" || base == "." {
+		return ""
+	}
+	return base
+}
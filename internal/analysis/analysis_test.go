@@ -0,0 +1,81 @@
+package analysis
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeClassifiesSideEffects(t *testing.T) {
+	for _, tc := range []struct {
+		command string
+		want    Effects
+	}{
+		{
+			command: "ls -la | grep go > out.txt 2>&1",
+			want:    Effects{Writes: []string{"out.txt"}},
+		},
+		{
+			command: "sudo rm -rf /var/cache/app && echo done >> /tmp/log",
+			want:    Effects{Privilege: []string{"sudo"}, Deletes: []string{"/var/cache/app"}, Writes: []string{"/tmp/log"}},
+		},
+		{
+			command: `curl -fsSL -o install.sh "https://get.example.com/install.sh"`,
+			want:    Effects{Network: []string{"get.example.com"}, Writes: []string{"install.sh"}},
+		},
+		{
+			command: "npm install -D left-pad",
+			want:    Effects{Packages: []string{"npm install -D left-pad"}, Network: []string{"npm registry"}},
+		},
+		{
+			command: "git push --force origin main",
+			want:    Effects{Network: []string{"git push origin"}, Deletes: []string{"remote commits a force push replaces"}},
+		},
+		{
+			command: "sed -i 's/a b/c/' config.yml notes.md",
+			want:    Effects{Writes: []string{"config.yml", "notes.md"}},
+		},
+		{
+			command: "tar -czf backup.tgz src",
+			want:    Effects{Writes: []string{"backup.tgz"}},
+		},
+		{
+			command: "mv draft.md docs/",
+			want:    Effects{Deletes: []string{"draft.md"}, Writes: []string{"docs/"}},
+		},
+		{
+			command: "bash -c 'pkill node; rm -f app.pid'",
+			want:    Effects{Processes: []string{"pkill node"}, Deletes: []string{"app.pid"}},
+		},
+		{
+			command: "FOO=1 make deploy",
+			want:    Effects{Unknown: []string{"make"}},
+		},
+		{
+			command: "echo '$(not run)' | cat",
+			want:    Effects{},
+		},
+	} {
+		if got := Analyze(tc.command); !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("Analyze(%q)\n got %+v\nwant %+v", tc.command, got, tc.want)
+		}
+	}
+}
+
+func TestAnalyzeMarksWhatIsDecidedAtRunTime(t *testing.T) {
+	got := Analyze("rm $(cat files.txt) && eval \"$CMD\"")
+	if len(got.Opaque) != 2 || !strings.HasPrefix(got.Opaque[0], "command substitution $(cat files.txt)") || !strings.HasPrefix(got.Opaque[1], "eval") {
+		t.Fatalf("expected the substitution and the eval reported, got %+v", got.Opaque)
+	}
+}
+
+func TestEffectsLines(t *testing.T) {
+	if lines := (Effects{}).Lines(); len(lines) != 1 || !strings.Contains(lines[0], "only reads") {
+		t.Fatalf("expected a read-only summary, got %v", lines)
+	}
+	lines := Effects{Privilege: []string{"sudo"}, Writes: []string{"a", "b"}}.Lines()
+	want := []string{"side effects:", "  privilege: sudo", "  writes: a, b"}
+	if !reflect.DeepEqual(lines, want) {
+		t.Fatalf("expected %v, got %v", want, lines)
+	}
+}
@@ -0,0 +1,194 @@
+package analysis
+
+import "strings"
+
+// tokenKind tells words from the operators between them.
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	// tokenSeparator ends a simple command: |, ||, &, &&, ;, (, ), or a
+	// newline.
+	tokenSeparator
+	// tokenRedirect is a redirection operator; the word after it is its
+	// target.
+	tokenRedirect
+)
+
+type token struct {
+	kind tokenKind
+	// text is the word with quotes and escapes removed, or the operator.
+	text string
+	// expands is set for a word with an unquoted or double-quoted $ or a
+	// backtick, whose value is only known when the shell runs it.
+	expands bool
+}
+
+// simpleCommand is one command between separators.
+type simpleCommand struct {
+	words     []token
+	redirects []redirect
+}
+
+type redirect struct {
+	op     string
+	target token
+}
+
+// tokenize splits a command line the way a POSIX shell would, far enough to
+// classify it: quotes and escapes are removed, operators are split out, and
+// words that expand are marked rather than expanded.
+func tokenize(command string) []token {
+	var (
+		tokens  []token
+		word    strings.Builder
+		inWord  bool
+		expands bool
+	)
+	flush := func() {
+		if inWord {
+			tokens = append(tokens, token{kind: tokenWord, text: word.String(), expands: expands})
+		}
+		word.Reset()
+		inWord, expands = false, false
+	}
+
+	runes := []rune(command)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\' && i+1 < len(runes):
+			i++
+			if runes[i] != '\n' {
+				word.WriteRune(runes[i])
+				inWord = true
+			}
+		case r == '\'':
+			inWord = true
+			for i++; i < len(runes) && runes[i] != '\''; i++ {
+				word.WriteRune(runes[i])
+			}
+		case r == '"':
+			inWord = true
+			for i++; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$`", runes[i+1]) {
+					i++
+				} else if runes[i] == '$' || runes[i] == '`' {
+					expands = true
+				}
+				word.WriteRune(runes[i])
+			}
+		case r == '$' || r == '`':
+			inWord, expands = true, true
+			word.WriteRune(r)
+			if r == '$' && i+1 < len(runes) && runes[i+1] == '(' {
+				// Keep $(...) in one word so its parentheses are not read
+				// as a subshell.
+				depth := 0
+				for i++; i < len(runes); i++ {
+					word.WriteRune(runes[i])
+					if runes[i] == '(' {
+						depth++
+					} else if runes[i] == ')' {
+						if depth--; depth == 0 {
+							break
+						}
+					}
+				}
+			}
+		case r == ' ' || r == '\t':
+			flush()
+		case r == '\n' || r == ';' || r == '(' || r == ')':
+			flush()
+			tokens = append(tokens, token{kind: tokenSeparator, text: string(r)})
+		case r == '|' || r == '&':
+			if r == '&' && i+1 < len(runes) && runes[i+1] == '>' {
+				flush()
+				op := "&>"
+				i++
+				if i+1 < len(runes) && runes[i+1] == '>' {
+					op = "&>>"
+					i++
+				}
+				tokens = append(tokens, token{kind: tokenRedirect, text: op})
+				continue
+			}
+			flush()
+			op := string(r)
+			if i+1 < len(runes) && runes[i+1] == r {
+				op += string(r)
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenSeparator, text: op})
+		case r == '>' || r == '<':
+			// A file descriptor number right before the operator, as in
+			// 2>, belongs to it.
+			fd := ""
+			if inWord && !expands && isDigits(word.String()) {
+				fd = word.String()
+				word.Reset()
+				inWord = false
+			}
+			flush()
+			op := fd + string(r)
+			for i+1 < len(runes) && strings.ContainsRune(">&|", runes[i+1]) {
+				i++
+				op += string(runes[i])
+			}
+			if strings.HasSuffix(op, "&") {
+				// 2>&1 duplicates a descriptor rather than naming a file.
+				for i+1 < len(runes) && (runes[i+1] >= '0' && runes[i+1] <= '9' || runes[i+1] == '-') {
+					i++
+				}
+				continue
+			}
+			tokens = append(tokens, token{kind: tokenRedirect, text: op})
+		default:
+			inWord = true
+			word.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+// splitCommands groups tokens into simple commands.
+func splitCommands(tokens []token) []simpleCommand {
+	var (
+		commands []simpleCommand
+		current  simpleCommand
+	)
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch tok.kind {
+		case tokenSeparator:
+			if len(current.words) > 0 || len(current.redirects) > 0 {
+				commands = append(commands, current)
+			}
+			current = simpleCommand{}
+		case tokenRedirect:
+			if i+1 < len(tokens) && tokens[i+1].kind == tokenWord {
+				i++
+				current.redirects = append(current.redirects, redirect{op: tok.text, target: tokens[i]})
+			}
+		default:
+			current.words = append(current.words, tok)
+		}
+	}
+	if len(current.words) > 0 || len(current.redirects) > 0 {
+		commands = append(commands, current)
+	}
+	return commands
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+package appdirs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// WriteError says which directory refused a write and why: read-only
+// file system, no space left on device, permission denied, or a file
+// where a directory should be.
+type WriteError struct {
+	Path string
+	Err  error
+}
+
+func (e *WriteError) Error() string {
+	return fmt.Sprintf("cannot write to %s: %v", e.Path, e.Err)
+}
+
+func (e *WriteError) Unwrap() error {
+	return e.Err
+}
+
+// CheckWritable reports whether files can be written in dir. A dir that
+// does not exist yet is checked through its nearest existing parent, so
+// nothing is created; a temp file is written there and removed again.
+func CheckWritable(dir string) error {
+	current := filepath.Clean(dir)
+	for {
+		info, err := os.Stat(current)
+		if err == nil {
+			if !info.IsDir() {
+				return &WriteError{Path: current, Err: syscall.ENOTDIR}
+			}
+			break
+		}
+		if !errors.Is(err, os.ErrNotExist) && !errors.Is(err, syscall.ENOTDIR) {
+			return &WriteError{Path: current, Err: pathCause(err)}
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return &WriteError{Path: current, Err: pathCause(err)}
+		}
+		current = parent
+	}
+
+	file, err := os.CreateTemp(current, ".ew-write-check-*")
+	if err != nil {
+		return &WriteError{Path: current, Err: pathCause(err)}
+	}
+	defer os.Remove(file.Name())
+	_, writeErr := file.Write([]byte("\n"))
+	closeErr := file.Close()
+	if err := errors.Join(writeErr, closeErr); err != nil {
+		return &WriteError{Path: current, Err: pathCause(err)}
+	}
+	return nil
+}
+
+// pathCause drops the *os.PathError wrapper, whose path is the temp file
+// rather than the directory.
+func pathCause(err error) error {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Err
+	}
+	return err
+}
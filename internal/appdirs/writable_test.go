@@ -0,0 +1,41 @@
+package appdirs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestCheckWritableLeavesNoTraceAndCreatesNothing(t *testing.T) {
+	root := t.TempDir()
+	missing := filepath.Join(root, "a", "b")
+	if err := CheckWritable(missing); err != nil {
+		t.Fatalf("expected a missing dir under a writable parent to pass, got %v", err)
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected nothing left behind, found %d entries", len(entries))
+	}
+}
+
+func TestCheckWritableNamesTheBlockingPath(t *testing.T) {
+	root := t.TempDir()
+	blocker := filepath.Join(root, ".local")
+	if err := os.WriteFile(blocker, []byte("x"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	err := CheckWritable(filepath.Join(blocker, "state", "ew"))
+	var writeErr *WriteError
+	if !errors.As(err, &writeErr) || writeErr.Path != blocker {
+		t.Fatalf("expected a WriteError for %s, got %v", blocker, err)
+	}
+	if !errors.Is(err, syscall.ENOTDIR) || !strings.Contains(err.Error(), "not a directory") {
+		t.Fatalf("expected the reason to be kept, got %v", err)
+	}
+}
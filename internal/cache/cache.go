@@ -0,0 +1,151 @@
+// Package cache keeps provider answers for a while, so asking the same
+// thing again soon after is answered from the state directory instead of
+// another provider call. Entries are keyed by a hash of the request, never
+// the request itself, and expire after ai.cache_ttl_seconds.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ashwch/ew/internal/provider"
+	"github.com/ashwch/ew/internal/state"
+)
+
+const fileName = "provider_cache.json"
+
+// maxEntries bounds the cache; the oldest answers are dropped first.
+const maxEntries = 200
+
+// Entry is one cached answer. Provider and Model are kept beside the
+// resolution because it does not serialize them.
+type Entry struct {
+	StoredAt   string              `json:"stored_at"`
+	Provider   string              `json:"provider"`
+	Model      string              `json:"model,omitempty"`
+	Resolution provider.Resolution `json:"resolution"`
+}
+
+type document struct {
+	Entries map[string]Entry `json:"entries"`
+}
+
+// Key identifies a request. preferred is the provider asked for with
+// --provider, if any. Runs of whitespace in the prompt do not matter.
+func Key(req provider.Request, preferred string) string {
+	parts := []string{
+		string(req.Intent),
+		strings.TrimSpace(req.Model),
+		strings.TrimSpace(req.Thinking),
+		strings.ToLower(strings.TrimSpace(req.Mode)),
+		strings.ToLower(strings.TrimSpace(preferred)),
+		strings.Join(strings.Fields(req.Prompt), " "),
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Lookup returns the answer stored under key if it is younger than ttl.
+func Lookup(key string, ttl time.Duration, now time.Time) (provider.Resolution, bool) {
+	if ttl <= 0 {
+		return provider.Resolution{}, false
+	}
+	doc, err := load()
+	if err != nil {
+		return provider.Resolution{}, false
+	}
+	entry, ok := doc.Entries[key]
+	if !ok {
+		return provider.Resolution{}, false
+	}
+	stored, err := time.Parse(time.RFC3339, entry.StoredAt)
+	if err != nil || now.Sub(stored) >= ttl || now.Before(stored) {
+		return provider.Resolution{}, false
+	}
+	resolution := entry.Resolution
+	resolution.Provider, resolution.Model = entry.Provider, entry.Model
+	resolution.Cached = true
+	return resolution, true
+}
+
+// Store saves resolution under key.
+func Store(key string, resolution provider.Resolution, now time.Time) error {
+	doc, err := load()
+	if err != nil {
+		return err
+	}
+	doc.Entries[key] = Entry{
+		StoredAt:   now.UTC().Format(time.RFC3339),
+		Provider:   resolution.Provider,
+		Model:      resolution.Model,
+		Resolution: resolution,
+	}
+	if len(doc.Entries) > maxEntries {
+		keys := make([]string, 0, len(doc.Entries))
+		for k := range doc.Entries {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return doc.Entries[keys[i]].StoredAt < doc.Entries[keys[j]].StoredAt
+		})
+		for _, k := range keys[:len(keys)-maxEntries] {
+			delete(doc.Entries, k)
+		}
+	}
+	return save(doc)
+}
+
+// Clear drops every cached answer and returns how many there were.
+func Clear() (int, error) {
+	doc, err := load()
+	if err != nil {
+		return 0, err
+	}
+	if err := save(document{Entries: map[string]Entry{}}); err != nil {
+		return 0, err
+	}
+	return len(doc.Entries), nil
+}
+
+func load() (document, error) {
+	doc := document{Entries: map[string]Entry{}}
+	backend, err := state.Current()
+	if err != nil {
+		return doc, err
+	}
+	payload, err := backend.Read(fileName)
+	if err != nil {
+		return doc, fmt.Errorf("could not read provider cache: %w", err)
+	}
+	if payload == nil {
+		return doc, nil
+	}
+	if err := json.Unmarshal(payload, &doc); err != nil {
+		// A cache that cannot be read is only a cache; start over.
+		return document{Entries: map[string]Entry{}}, nil
+	}
+	if doc.Entries == nil {
+		doc.Entries = map[string]Entry{}
+	}
+	return doc, nil
+}
+
+func save(doc document) error {
+	backend, err := state.Current()
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("could not encode provider cache: %w", err)
+	}
+	if err := backend.Write(fileName, payload); err != nil {
+		return fmt.Errorf("could not save provider cache: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ashwch/ew/internal/provider"
+)
+
+func useTempState(t *testing.T) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, ".local", "state"))
+}
+
+func TestKeyIgnoresWhitespaceButNotRequestSettings(t *testing.T) {
+	req := provider.Request{Intent: provider.IntentFind, Model: "auto-fast", Prompt: "list  open\tports"}
+	same := req
+	same.Prompt = " list open ports "
+	if Key(req, "") != Key(same, "") {
+		t.Fatalf("expected whitespace runs not to change the key")
+	}
+	other := req
+	other.Model = "auto-main"
+	if Key(req, "") == Key(other, "") || Key(req, "") == Key(req, "claude") {
+		t.Fatalf("expected the model and provider to be part of the key")
+	}
+	fix := req
+	fix.Intent = provider.IntentFix
+	if Key(req, "") == Key(fix, "") {
+		t.Fatalf("expected the intent to be part of the key")
+	}
+}
+
+func TestLookupHonoursTTLAndClear(t *testing.T) {
+	useTempState(t)
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	key := Key(provider.Request{Intent: provider.IntentFind, Prompt: "disk usage"}, "")
+	answer := provider.Resolution{Action: "suggest", Command: "du -sh .", Risk: "low", Provider: "codex", Model: "gpt-5-mini"}
+	if err := Store(key, answer, now); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+
+	got, ok := Lookup(key, 15*time.Minute, now.Add(time.Minute))
+	if !ok || got.Command != "du -sh ." || got.Provider != "codex" || got.Model != "gpt-5-mini" || !got.Cached {
+		t.Fatalf("expected a cached answer with its provider, got %+v %v", got, ok)
+	}
+	if _, ok := Lookup(key, 15*time.Minute, now.Add(20*time.Minute)); ok {
+		t.Fatalf("expected an expired answer to be ignored")
+	}
+	if _, ok := Lookup(key, 0, now); ok {
+		t.Fatalf("expected a zero ttl to turn the cache off")
+	}
+
+	count, err := Clear()
+	if err != nil || count != 1 {
+		t.Fatalf("expected one entry cleared, got %d %v", count, err)
+	}
+	if _, ok := Lookup(key, 15*time.Minute, now.Add(time.Minute)); ok {
+		t.Fatalf("expected nothing after Clear")
+	}
+}
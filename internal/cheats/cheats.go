@@ -0,0 +1,342 @@
+package cheats
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ashwch/ew/internal/appdirs"
+)
+
+const (
+	dirName   = "cheats"
+	extension = ".cheat"
+)
+
+// Entry is one command from a navi-style .cheat file. Variables maps a
+// placeholder name to the shell command navi would run to list its values;
+// ew only shows it as a hint and never runs it.
+type Entry struct {
+	File        string            `json:"file"`
+	Tags        []string          `json:"tags,omitempty"`
+	Description string            `json:"description"`
+	Command     string            `json:"command"`
+	Variables   map[string]string `json:"variables,omitempty"`
+}
+
+// Match is a scored entry for a find query.
+type Match struct {
+	Entry
+	Score float64 `json:"score"`
+}
+
+// ImportResult says what Import copied.
+type ImportResult struct {
+	Dir     string
+	Files   int
+	Entries int
+}
+
+var placeholderPattern = regexp.MustCompile(`<([A-Za-z0-9_-]+)>`)
+
+// Dir is where imported cheat files live: <config_dir>/cheats. They are
+// hand-curated, like the config, rather than cached state.
+func Dir() (string, error) {
+	base, err := appdirs.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, dirName), nil
+}
+
+// Parse reads a .cheat file: "% tags" starts a section, "# text" describes
+// the command lines below it, "$ name: command" declares where a
+// placeholder's values come from, and ";" lines are comments. Variables are
+// scoped to their section, so they apply to commands above them too.
+func Parse(file string, content string) []Entry {
+	entries := []Entry{}
+	sectionStart := 0
+	var tags []string
+	variables := map[string]string{}
+	description := ""
+	command := []string{}
+
+	flush := func() {
+		text := strings.TrimSpace(strings.Join(command, "\n"))
+		command = command[:0]
+		if text == "" {
+			return
+		}
+		entries = append(entries, Entry{File: file, Tags: tags, Description: description, Command: text})
+		description = ""
+	}
+	closeSection := func() {
+		flush()
+		for idx := sectionStart; idx < len(entries); idx++ {
+			entries[idx].Variables = sectionVariables(entries[idx].Command, variables)
+		}
+		sectionStart = len(entries)
+		variables = map[string]string{}
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+		switch {
+		case line == "":
+			flush()
+		case strings.HasPrefix(line, "%"):
+			closeSection()
+			tags = splitTags(strings.TrimPrefix(line, "%"))
+		case strings.HasPrefix(line, "#"):
+			flush()
+			description = strings.TrimSpace(strings.TrimPrefix(line, "#"))
+		case strings.HasPrefix(line, "$"):
+			flush()
+			name, source, ok := strings.Cut(strings.TrimSpace(strings.TrimPrefix(line, "$")), ":")
+			if ok {
+				// navi passes fzf options after "---"; they mean nothing here.
+				source, _, _ = strings.Cut(source, "---")
+				variables[strings.TrimSpace(name)] = strings.TrimSpace(source)
+			}
+		case strings.HasPrefix(line, ";"), strings.HasPrefix(line, "@"):
+			// Comments, and "@ tags" imports of other sections, which would
+			// need every file loaded to resolve.
+			flush()
+		default:
+			if description == "" && len(command) == 0 {
+				// navi ignores commands without a description.
+				continue
+			}
+			command = append(command, strings.TrimRight(raw, " \t"))
+		}
+	}
+	closeSection()
+	return entries
+}
+
+func splitTags(text string) []string {
+	tags := []string{}
+	for _, tag := range strings.Split(text, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func sectionVariables(command string, variables map[string]string) map[string]string {
+	var used map[string]string
+	for _, name := range Placeholders(command) {
+		source, ok := variables[name]
+		if !ok {
+			continue
+		}
+		if used == nil {
+			used = map[string]string{}
+		}
+		used[name] = source
+	}
+	return used
+}
+
+// Placeholders lists the distinct <name> placeholders in command, in order.
+func Placeholders(command string) []string {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, match := range placeholderPattern.FindAllStringSubmatch(command, -1) {
+		if seen[match[1]] {
+			continue
+		}
+		seen[match[1]] = true
+		names = append(names, match[1])
+	}
+	return names
+}
+
+// Fill replaces each <name> that has a value. Placeholders without one stay
+// as they are, which a shell refuses to run.
+func Fill(command string, values map[string]string) string {
+	return placeholderPattern.ReplaceAllStringFunc(command, func(token string) string {
+		name := strings.TrimSuffix(strings.TrimPrefix(token, "<"), ">")
+		if value, ok := values[name]; ok {
+			return value
+		}
+		return token
+	})
+}
+
+// Load reads every .cheat file under dir, including subdirectories, so a
+// cloned cheat repository can be dropped in as is. A missing dir is empty.
+func Load(dir string) ([]Entry, error) {
+	entries := []Entry{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir && os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), extension) {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		rel, relErr := filepath.Rel(dir, path)
+		if relErr != nil {
+			rel = d.Name()
+		}
+		entries = append(entries, Parse(filepath.ToSlash(rel), string(content))...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not read cheat files: %w", err)
+	}
+	return entries, nil
+}
+
+// Import copies src, a .cheat file or a directory of them, into dir. Files
+// with no usable entries are skipped; a file with the same name as an
+// earlier import replaces it, so re-importing picks up upstream edits.
+func Import(src string, dir string) (ImportResult, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("could not read %s: %w", src, err)
+	}
+	sources := []string{src}
+	if info.IsDir() {
+		sources = sources[:0]
+		err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(d.Name(), extension) {
+				sources = append(sources, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return ImportResult{}, fmt.Errorf("could not read %s: %w", src, err)
+		}
+		sort.Strings(sources)
+	}
+
+	result := ImportResult{Dir: dir}
+	for _, path := range sources {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return result, fmt.Errorf("could not read %s: %w", path, err)
+		}
+		name := filepath.Base(path)
+		if !strings.HasSuffix(name, extension) {
+			name += extension
+		}
+		entries := Parse(name, string(content))
+		if len(entries) == 0 {
+			continue
+		}
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return result, fmt.Errorf("could not create cheats dir: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0o600); err != nil {
+			return result, fmt.Errorf("could not write %s: %w", name, err)
+		}
+		result.Files++
+		result.Entries += len(entries)
+	}
+	if result.Files == 0 {
+		return result, fmt.Errorf("no cheat entries found in %s", src)
+	}
+	return result, nil
+}
+
+// Search scores entries against query: a query word naming one of the tags
+// counts most, then words shared with the description, then with the
+// command itself. Entries sharing nothing with the description or tags are
+// dropped.
+func Search(entries []Entry, query string, limit int) []Match {
+	tokens := queryTokens(query)
+	if len(tokens) == 0 {
+		return nil
+	}
+	matches := []Match{}
+	for _, entry := range entries {
+		tags := wordSet(strings.Join(entry.Tags, " "))
+		description := wordSet(entry.Description)
+		command := wordSet(entry.Command)
+		tagHit, overlap, commandOverlap := 0.0, 0.0, 0.0
+		for _, token := range tokens {
+			if tags[token] {
+				tagHit = 10
+			}
+			if description[token] {
+				overlap++
+			} else if command[token] {
+				commandOverlap++
+			}
+		}
+		if overlap == 0 && tagHit == 0 {
+			continue
+		}
+		matches = append(matches, Match{Entry: entry, Score: tagHit + overlap*3 + commandOverlap})
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score == matches[j].Score {
+			return matches[i].File < matches[j].File
+		}
+		return matches[i].Score > matches[j].Score
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+var stopwords = map[string]bool{
+	"a": true, "an": true, "the": true, "to": true, "of": true, "in": true, "on": true, "for": true,
+	"and": true, "or": true, "with": true, "how": true, "do": true, "i": true, "my": true, "me": true,
+	"command": true, "commands": true, "use": true, "using": true,
+}
+
+func queryTokens(query string) []string {
+	tokens := []string{}
+	for word := range wordSet(query) {
+		if !stopwords[word] {
+			tokens = append(tokens, word)
+		}
+	}
+	sort.Strings(tokens)
+	return tokens
+}
+
+func wordSet(text string) map[string]bool {
+	set := map[string]bool{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '.')
+	}) {
+		word = strings.Trim(word, ".-")
+		if word == "" {
+			continue
+		}
+		set[stem(word)] = true
+	}
+	return set
+}
+
+func stem(word string) string {
+	for _, suffix := range []string{"ing", "ed", "es", "s"} {
+		if len(word) > len(suffix)+2 && strings.HasSuffix(word, suffix) {
+			return strings.TrimSuffix(word, suffix)
+		}
+	}
+	return word
+}
@@ -0,0 +1,116 @@
+package cheats
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const gitCheat = `% git, code
+
+# Change branch
+git checkout <branch>
+
+; branches come from the local repo
+# Create a branch from another
+git checkout -b <new_branch> <branch>
+
+$ branch: git branch --format='%(refname:short)' --- --column 1
+
+% docker
+
+# Remove a container
+docker rm <container>
+`
+
+func TestParseReadsTagsDescriptionsAndVariables(t *testing.T) {
+	entries := Parse("git.cheat", gitCheat)
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %+v", entries)
+	}
+	first := entries[0]
+	if first.Description != "Change branch" || first.Command != "git checkout <branch>" {
+		t.Fatalf("unexpected first entry %+v", first)
+	}
+	if len(first.Tags) != 2 || first.Tags[1] != "code" {
+		t.Fatalf("unexpected tags %v", first.Tags)
+	}
+	if got := first.Variables["branch"]; got != "git branch --format='%(refname:short)'" {
+		t.Fatalf("expected the section variable to reach earlier commands, got %q", got)
+	}
+	if got := Placeholders(entries[1].Command); len(got) != 2 || got[0] != "new_branch" {
+		t.Fatalf("unexpected placeholders %v", got)
+	}
+	if _, ok := entries[1].Variables["new_branch"]; ok {
+		t.Fatalf("expected no source for new_branch, got %v", entries[1].Variables)
+	}
+	if entries[2].Tags[0] != "docker" || len(entries[2].Variables) != 0 {
+		t.Fatalf("expected the docker section to start fresh, got %+v", entries[2])
+	}
+}
+
+func TestFillKeepsUnknownPlaceholders(t *testing.T) {
+	got := Fill("git checkout -b <new_branch> <branch>", map[string]string{"new_branch": "feature"})
+	if got != "git checkout -b feature <branch>" {
+		t.Fatalf("unexpected fill %q", got)
+	}
+}
+
+func TestSearchPrefersTagsThenDescription(t *testing.T) {
+	entries := Parse("git.cheat", gitCheat)
+	matches := Search(entries, "git create branch", 2)
+	if len(matches) != 2 || matches[0].Description != "Create a branch from another" {
+		t.Fatalf("expected the create-branch entry first, got %+v", matches)
+	}
+	if got := Search(entries, "remove container", 0); len(got) != 1 || got[0].File != "git.cheat" {
+		t.Fatalf("expected the docker entry, got %+v", got)
+	}
+	if got := Search(entries, "the of a", 3); len(got) != 0 {
+		t.Fatalf("expected stopword-only query to match nothing, got %+v", got)
+	}
+}
+
+func TestImportCopiesCheatFilesAndLoadReadsThem(t *testing.T) {
+	src := t.TempDir()
+	writeFile(t, filepath.Join(src, "git.cheat"), gitCheat)
+	writeFile(t, filepath.Join(src, "nested", "k8s.cheat"), "% k8s\n\n# List pods\nkubectl get pods -n <namespace>\n")
+	writeFile(t, filepath.Join(src, "empty.cheat"), "; nothing here\n")
+	writeFile(t, filepath.Join(src, "README.md"), "# not a cheat\n")
+
+	dir := filepath.Join(t.TempDir(), "cheats")
+	result, err := Import(src, dir)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if result.Files != 2 || result.Entries != 4 {
+		t.Fatalf("unexpected import result %+v", result)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "empty.cheat")); !os.IsNotExist(err) {
+		t.Fatalf("expected the empty cheat to be skipped, got %v", err)
+	}
+
+	entries, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 entries, got %+v", entries)
+	}
+
+	if _, err := Import(filepath.Join(src, "empty.cheat"), dir); err == nil {
+		t.Fatal("expected an error importing a file without entries")
+	}
+	if missing, err := Load(filepath.Join(t.TempDir(), "missing")); err != nil || len(missing) != 0 {
+		t.Fatalf("expected a missing dir to load empty, got %v %v", missing, err)
+	}
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
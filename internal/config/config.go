@@ -3,14 +3,19 @@ package config
 import (
 	"errors"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/ashwch/ew/internal/appdirs"
 	"github.com/ashwch/ew/internal/i18n"
+	"github.com/ashwch/ew/internal/target"
 	"github.com/pelletier/go-toml/v2"
 )
 
@@ -21,6 +26,16 @@ type IntentConfig struct {
 	MaxResults    int     `toml:"max_results,omitempty" json:"max_results,omitempty"`
 	AIRerank      string  `toml:"ai_rerank,omitempty" json:"ai_rerank,omitempty"`
 	AutoRun       bool    `toml:"auto_run,omitempty" json:"auto_run,omitempty"`
+	// TemporalBoost (find only) favours commands you usually run at this
+	// hour and on this weekday.
+	TemporalBoost bool `toml:"temporal_boost,omitempty" json:"temporal_boost,omitempty"`
+	// OfferRun (find only) asks "run it now?" after a suggestion: auto for
+	// queries that read as an order ("restart nginx"), always, or never.
+	OfferRun string `toml:"offer_run,omitempty" json:"offer_run,omitempty"`
+	// MaxAttempts (fix only) is how many provider fixes in a row for the
+	// same failure ew offers in one shell session before it stops and
+	// summarizes what was tried.
+	MaxAttempts int `toml:"max_attempts,omitempty" json:"max_attempts,omitempty"`
 }
 
 type ModelConfig struct {
@@ -41,48 +56,242 @@ type ProviderConfig struct {
 	ThinkingFlag string                 `toml:"thinking_flag,omitempty" json:"thinking_flag,omitempty"`
 	Args         []string               `toml:"args,omitempty" json:"args,omitempty"`
 	Models       map[string]ModelConfig `toml:"models,omitempty" json:"models,omitempty"`
+	// Intents limits the provider to fix or find requests; empty means both.
+	Intents []string `toml:"intents,omitempty" json:"intents,omitempty"`
+	// MaxRisk is the riskiest command (low, medium, high) the provider may
+	// drive an execution with; anything riskier is only suggested. Empty
+	// means no ceiling beyond the safety policy.
+	MaxRisk string `toml:"max_risk,omitempty" json:"max_risk,omitempty"`
+	// Env lists the environment variables a command provider's CLI may see
+	// on top of a base set (PATH, HOME, locale, temp dir and proxy
+	// settings). An entry ending in * matches a prefix; "*" on its own
+	// passes ew's whole environment.
+	Env []string `toml:"env,omitempty" json:"env,omitempty"`
+	// TimeoutSeconds overrides ai.timeout_seconds for this provider; 0
+	// means the global value.
+	TimeoutSeconds int `toml:"timeout_seconds,omitempty" json:"timeout_seconds,omitempty"`
+
+	// The fields below only apply to type = "http" providers, which call a
+	// chat completion API directly instead of running a CLI.
+
+	// API is the request format: openai (also OpenRouter and other
+	// OpenAI-compatible servers) or anthropic.
+	API string `toml:"api,omitempty" json:"api,omitempty"`
+	// BaseURL is the API root, e.g. https://openrouter.ai/api/v1; empty
+	// means the API's own endpoint.
+	BaseURL string `toml:"base_url,omitempty" json:"base_url,omitempty"`
+	// APIKeyEnv names the environment variable holding the API key. Keys
+	// never go in the config file itself.
+	APIKeyEnv string `toml:"api_key_env,omitempty" json:"api_key_env,omitempty"`
+	// TimeoutMs bounds each HTTP attempt; MaxAttempts is how many attempts
+	// a request gets when the API is rate limited or failing.
+	TimeoutMs   int `toml:"timeout_ms,omitempty" json:"timeout_ms,omitempty"`
+	MaxAttempts int `toml:"max_attempts,omitempty" json:"max_attempts,omitempty"`
+}
+
+// AllowsIntent reports whether the provider may be asked for intent.
+func (p ProviderConfig) AllowsIntent(intent string) bool {
+	return len(p.Intents) == 0 || slices.Contains(p.Intents, intent)
 }
 
 type SafetyConfig struct {
-	RedactSecrets     bool `toml:"redact_secrets" json:"redact_secrets"`
-	BlockHighRisk     bool `toml:"block_high_risk" json:"block_high_risk"`
-	AllowYoloHighRisk bool `toml:"allow_yolo_high_risk" json:"allow_yolo_high_risk"`
+	RedactSecrets        bool `toml:"redact_secrets" json:"redact_secrets"`
+	BlockHighRisk        bool `toml:"block_high_risk" json:"block_high_risk"`
+	AllowYoloHighRisk    bool `toml:"allow_yolo_high_risk" json:"allow_yolo_high_risk"`
+	MaxAutoCommandLength int  `toml:"max_auto_command_length" json:"max_auto_command_length"`
+	MaxAutoArgs          int  `toml:"max_auto_args" json:"max_auto_args"`
+	MaxAutoPaths         int  `toml:"max_auto_paths" json:"max_auto_paths"`
+	// ProtectedBranches are glob patterns; git push/reset/rebase on a matching
+	// branch (or the repo's default branch) is treated as high risk.
+	ProtectedBranches []string `toml:"protected_branches" json:"protected_branches"`
+	// PlanPreview controls the read-only plan/diff offered before terraform
+	// or kubectl apply: ask, always, or never. Only always runs it for
+	// --json and --dry-run.
+	PlanPreview string `toml:"plan_preview" json:"plan_preview"`
 }
 
 type PromptConfig struct {
-	SelfKnowledge string `toml:"self_knowledge" json:"self_knowledge"`
-	StrictJSON    bool   `toml:"strict_json" json:"strict_json"`
+	// SelfKnowledge controls what ew tells the provider about itself:
+	// compiled (the sections relevant to the task, within
+	// SelfKnowledgeTokens), full, or off.
+	SelfKnowledge       string `toml:"self_knowledge" json:"self_knowledge"`
+	SelfKnowledgeTokens int    `toml:"self_knowledge_tokens" json:"self_knowledge_tokens"`
+	StrictJSON          bool   `toml:"strict_json" json:"strict_json"`
 }
 
 type AIConfig struct {
 	MinConfidence         float64 `toml:"min_confidence" json:"min_confidence"`
 	AllowSuggestExecution bool    `toml:"allow_suggest_execution" json:"allow_suggest_execution"`
+	// TimeoutSeconds is how long one provider may take to answer before ew
+	// gives up on it and asks the next one.
+	TimeoutSeconds int `toml:"timeout_seconds" json:"timeout_seconds"`
+	// CacheTTLSeconds is how long a provider answer is reused for an
+	// identical request; 0 turns the cache off.
+	CacheTTLSeconds int `toml:"cache_ttl_seconds" json:"cache_ttl_seconds"`
+	// CalibrateConfidence replaces a provider's stated confidence with how
+	// often its answers at that confidence worked out for this user.
+	CalibrateConfidence bool `toml:"calibrate_confidence" json:"calibrate_confidence"`
+	// MaxConcurrent is how many provider requests may run at once; more
+	// wait their turn, and the wait counts against their timeout.
+	MaxConcurrent int `toml:"max_concurrent" json:"max_concurrent"`
 }
 
+// UIConfig picks the interactive backend. ASCIIOnly keeps everything ew
+// renders to 7-bit ASCII for CI logs, syslog, and legacy terminals; TERM=dumb
+// turns it on too.
 type UIConfig struct {
-	Backend string `toml:"backend" json:"backend"`
+	Backend   string `toml:"backend" json:"backend"`
+	ASCIIOnly bool   `toml:"ascii_only" json:"ascii_only"`
 }
 
 type SystemConfig struct {
-	EnableContext  bool `toml:"enable_context" json:"enable_context"`
-	AutoTrain      bool `toml:"auto_train" json:"auto_train"`
-	RefreshHours   int  `toml:"refresh_hours" json:"refresh_hours"`
-	MaxPromptItems int  `toml:"max_prompt_items" json:"max_prompt_items"`
+	EnableContext  bool              `toml:"enable_context" json:"enable_context"`
+	AutoTrain      bool              `toml:"auto_train" json:"auto_train"`
+	RefreshHours   int               `toml:"refresh_hours" json:"refresh_hours"`
+	MaxPromptItems int               `toml:"max_prompt_items" json:"max_prompt_items"`
+	Share          SystemShareConfig `toml:"share" json:"share"`
+}
+
+// SystemShareConfig picks which parts of the system profile are sent to
+// providers. OS, architecture, shell, and locale always are while
+// enable_context is on; the rest can stay on this machine. Tools covers
+// the installed multiplexers too.
+type SystemShareConfig struct {
+	Tools       bool `toml:"tools" json:"tools"`
+	ConfigFiles bool `toml:"config_files" json:"config_files"`
+	GitIgnore   bool `toml:"git_ignore" json:"git_ignore"`
+	UserNote    bool `toml:"user_note" json:"user_note"`
+}
+
+type ExecutionConfig struct {
+	Target string `toml:"target" json:"target"`
+}
+
+// StateConfig picks where memory, rejections, and the session journal are
+// kept: "files" (one JSON/JSONL file each) or "sqlite" (a single state.db,
+// only in builds made with -tags sqlite).
+type StateConfig struct {
+	Backend string `toml:"backend" json:"backend"`
+}
+
+// TLDRConfig turns on tldr pages as an offline source for find and as
+// grounding for provider prompts. `ew --update-tldr` downloads the pages.
+type TLDRConfig struct {
+	Enabled bool `toml:"enabled" json:"enabled"`
+}
+
+// EmbeddingsConfig turns on semantic search: find also ranks memory and
+// recent history by how close they are in meaning to the query, so "free up
+// port 3000" finds what was saved as "kill process on port". API is ollama
+// (a local model, the default) or openai (also any OpenAI-compatible
+// embeddings endpoint); Model, BaseURL, and APIKeyEnv default per API.
+// Matches less similar than MinSimilarity are ignored.
+type EmbeddingsConfig struct {
+	Enabled       bool    `toml:"enabled" json:"enabled"`
+	API           string  `toml:"api" json:"api"`
+	Model         string  `toml:"model,omitempty" json:"model,omitempty"`
+	BaseURL       string  `toml:"base_url,omitempty" json:"base_url,omitempty"`
+	APIKeyEnv     string  `toml:"api_key_env,omitempty" json:"api_key_env,omitempty"`
+	MinSimilarity float64 `toml:"min_similarity" json:"min_similarity"`
+}
+
+// RunbooksConfig points find at a directory of team runbooks in markdown.
+// A runbook section whose heading or prose matches the query supplies its
+// command, cited by file and section, ahead of a provider's answer. A
+// relative Dir starts from the directory config.toml is in.
+type RunbooksConfig struct {
+	Dir string `toml:"dir,omitempty" json:"dir,omitempty"`
+}
+
+// FeedbackConfig turns on the suggestion feedback dataset: a redacted JSONL
+// record per answered query in <state_dir>/feedback.jsonl, for teams that
+// fine-tune their own models. EW_FEEDBACK=off overrides it.
+type FeedbackConfig struct {
+	Enabled bool `toml:"enabled" json:"enabled"`
+}
+
+// JournalConfig controls what the session journal keeps of each answer:
+// "full" records every field as given, "redacted" scrubs secrets first,
+// "commands-only" keeps the command and its outcome but not the query,
+// reason, or failure, and "off" records nothing. --no-record turns it off
+// for one invocation.
+type JournalConfig struct {
+	Privacy string `toml:"privacy" json:"privacy"`
+}
+
+// TipsConfig controls the occasional one-line tip ew prints after an
+// answer, at most once a day. EW_TIPS=off overrides it.
+type TipsConfig struct {
+	Enabled bool `toml:"enabled" json:"enabled"`
+}
+
+// HistoryConfig controls writing commands ew ran back to the shell's own
+// history, so they can be recalled with up-arrow or Ctrl-R like any other,
+// and what happens to history entries that carry a secret: "redact" keeps
+// them searchable with the secret replaced by <redacted>, "skip" leaves
+// them out of search altogether.
+type HistoryConfig struct {
+	WriteBack bool   `toml:"write_back" json:"write_back"`
+	Secrets   string `toml:"secrets" json:"secrets"`
+	// Watermark ends written-back commands with "# via ew"; ViaEW says
+	// how search treats commands so marked: keep, downrank, or skip.
+	Watermark bool   `toml:"watermark" json:"watermark"`
+	ViaEW     string `toml:"via_ew" json:"via_ew"`
+}
+
+// ToolsConfig holds the person's toolbox. Prefer maps a tool ew should not
+// suggest to the one to use instead, e.g. "docker-compose" = "docker compose";
+// history matches and provider suggestions are rewritten to match. Groups
+// are the [tools.<name>] tables, read and written by decodeToolGroups and
+// marshalConfig.
+type ToolsConfig struct {
+	Prefer map[string]string    `toml:"prefer" json:"prefer"`
+	Groups map[string]ToolGroup `toml:"-" json:"groups,omitempty"`
+}
+
+// QuickCommand is a shortcut run as `ew /<name> args...`. Template is a
+// shell command whose {1}, {2}, ... placeholders take the arguments in
+// order and {@} takes all of them.
+type QuickCommand struct {
+	Template    string `toml:"template" json:"template"`
+	Description string `toml:"description,omitempty" json:"description,omitempty"`
+}
+
+// DoctorConfig bounds `ew --doctor`: checks run concurrently, each one
+// still running after CheckTimeoutMs is reported as timed out, and the
+// whole run ends when BudgetMs elapses.
+type DoctorConfig struct {
+	BudgetMs       int `toml:"budget_ms" json:"budget_ms"`
+	CheckTimeoutMs int `toml:"check_timeout_ms" json:"check_timeout_ms"`
 }
 
 type Config struct {
-	Version   int                       `toml:"version" json:"version"`
-	Locale    string                    `toml:"locale" json:"locale"`
-	Provider  string                    `toml:"provider" json:"provider"`
-	Mode      string                    `toml:"mode" json:"mode"`
-	Fix       IntentConfig              `toml:"fix" json:"fix"`
-	Find      IntentConfig              `toml:"find" json:"find"`
-	Providers map[string]ProviderConfig `toml:"providers" json:"providers"`
-	Safety    SafetyConfig              `toml:"safety" json:"safety"`
-	Prompt    PromptConfig              `toml:"prompt" json:"prompt"`
-	AI        AIConfig                  `toml:"ai" json:"ai"`
-	UI        UIConfig                  `toml:"ui" json:"ui"`
-	System    SystemConfig              `toml:"system" json:"system"`
+	Version       int                       `toml:"version" json:"version"`
+	Locale        string                    `toml:"locale" json:"locale"`
+	Provider      string                    `toml:"provider" json:"provider"`
+	ProviderOrder []string                  `toml:"provider_order,omitempty" json:"provider_order,omitempty"`
+	Mode          string                    `toml:"mode" json:"mode"`
+	Include       []string                  `toml:"include,omitempty" json:"include,omitempty"`
+	Fix           IntentConfig              `toml:"fix" json:"fix"`
+	Find          IntentConfig              `toml:"find" json:"find"`
+	Providers     map[string]ProviderConfig `toml:"providers" json:"providers"`
+	Safety        SafetyConfig              `toml:"safety" json:"safety"`
+	Prompt        PromptConfig              `toml:"prompt" json:"prompt"`
+	AI            AIConfig                  `toml:"ai" json:"ai"`
+	UI            UIConfig                  `toml:"ui" json:"ui"`
+	System        SystemConfig              `toml:"system" json:"system"`
+	Execution     ExecutionConfig           `toml:"execution" json:"execution"`
+	Doctor        DoctorConfig              `toml:"doctor" json:"doctor"`
+	State         StateConfig               `toml:"state" json:"state"`
+	TLDR          TLDRConfig                `toml:"tldr" json:"tldr"`
+	Embeddings    EmbeddingsConfig          `toml:"embeddings" json:"embeddings"`
+	Runbooks      RunbooksConfig            `toml:"runbooks" json:"runbooks"`
+	Feedback      FeedbackConfig            `toml:"feedback" json:"feedback"`
+	Tools         ToolsConfig               `toml:"tools" json:"tools"`
+	History       HistoryConfig             `toml:"history" json:"history"`
+	Tips          TipsConfig                `toml:"tips" json:"tips"`
+	Journal       JournalConfig             `toml:"journal" json:"journal"`
+	Quick         map[string]QuickCommand   `toml:"quick" json:"quick"`
 }
 
 func Default() Config {
@@ -95,6 +304,7 @@ func Default() Config {
 			Model:         "auto-main",
 			Thinking:      "medium",
 			MinConfidence: 0.70,
+			MaxAttempts:   3,
 		},
 		Find: IntentConfig{
 			Model:         "auto-fast",
@@ -102,18 +312,28 @@ func Default() Config {
 			MinConfidence: 0.60,
 			MaxResults:    8,
 			AIRerank:      "auto",
+			OfferRun:      "auto",
 			AutoRun:       false,
 		},
 		Providers: defaultProviderCatalog(),
 		Safety: SafetyConfig{
-			RedactSecrets:     true,
-			BlockHighRisk:     true,
-			AllowYoloHighRisk: false,
+			RedactSecrets:        true,
+			BlockHighRisk:        true,
+			AllowYoloHighRisk:    false,
+			MaxAutoCommandLength: 512,
+			MaxAutoArgs:          32,
+			MaxAutoPaths:         8,
+			ProtectedBranches:    []string{"main", "master", "release/*"},
+			PlanPreview:          "ask",
 		},
-		Prompt: PromptConfig{SelfKnowledge: "compiled", StrictJSON: true},
+		Prompt: PromptConfig{SelfKnowledge: "compiled", SelfKnowledgeTokens: 2000, StrictJSON: true},
 		AI: AIConfig{
 			MinConfidence:         0.60,
 			AllowSuggestExecution: false,
+			TimeoutSeconds:        90,
+			CacheTTLSeconds:       900,
+			CalibrateConfidence:   true,
+			MaxConcurrent:         2,
 		},
 		UI: UIConfig{
 			Backend: "bubbletea",
@@ -123,6 +343,27 @@ func Default() Config {
 			AutoTrain:      true,
 			RefreshHours:   168,
 			MaxPromptItems: 16,
+			Share:          SystemShareConfig{Tools: true, ConfigFiles: true, GitIgnore: true, UserNote: true},
+		},
+		Execution: ExecutionConfig{
+			Target: "local",
+		},
+		Doctor: DoctorConfig{
+			BudgetMs:       3000,
+			CheckTimeoutMs: 1500,
+		},
+		State: StateConfig{
+			Backend: "files",
+		},
+		History: HistoryConfig{
+			Secrets:   "redact",
+			Watermark: true,
+			ViaEW:     "downrank",
+		},
+		Tips:       TipsConfig{Enabled: true},
+		Embeddings: EmbeddingsConfig{API: "ollama", MinSimilarity: 0.6},
+		Journal: JournalConfig{
+			Privacy: "redacted",
 		},
 	}
 }
@@ -156,6 +397,7 @@ func defaultProviderCatalog() map[string]ProviderConfig {
 			Thinking:     "medium",
 			ModelFlag:    "--model",
 			ThinkingFlag: "-c model_reasoning_effort={thinking}",
+			Env:          []string{"OPENAI_*", "CODEX_*"},
 			Args: []string{
 				"exec",
 				"--skip-git-repo-check",
@@ -196,6 +438,9 @@ func defaultProviderCatalog() map[string]ProviderConfig {
 			Thinking:     "medium",
 			ModelFlag:    "--model",
 			ThinkingFlag: "--thinking {thinking}",
+			// Bedrock and Vertex users authenticate through their cloud's
+			// variables.
+			Env: []string{"ANTHROPIC_*", "CLAUDE_*", "AWS_*", "GOOGLE_*", "CLOUDSDK_*"},
 			Args: []string{
 				"-p",
 				"--output-format",
@@ -228,19 +473,38 @@ func defaultProviderCatalog() map[string]ProviderConfig {
 	}
 }
 
+// LoadOrCreate returns the user's config and its path, writing the defaults
+// first when there is no config file. Files named by include are layered
+// over the main file in the order listed, so later files win; a missing
+// include is skipped so machine-specific files can be absent. Includes do
+// not nest: an included file's own include list is ignored.
 func LoadOrCreate() (Config, string, error) {
+	cfg, path, err := LoadFile()
+	if err != nil {
+		return Config{}, "", err
+	}
+	if err := applyIncludes(&cfg, filepath.Dir(path)); err != nil {
+		return Config{}, "", err
+	}
+	return cfg, path, nil
+}
+
+// LoadFile is LoadOrCreate without the includes: the main config file
+// alone, which is what changes should be saved over. When the file is
+// missing and cannot be created it returns the defaults.
+func LoadFile() (Config, string, error) {
 	path, err := appdirs.ConfigFilePath()
 	if err != nil {
 		return Config{}, "", err
 	}
 
 	cfg := Default()
-	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
-		if _, err := appdirs.EnsureConfigDir(); err != nil {
-			return Config{}, "", err
-		}
-		if err := Save(path, cfg); err != nil {
-			return Config{}, "", err
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) || errors.Is(err, syscall.ENOTDIR) {
+		// A config dir that cannot be written (read-only home, full disk)
+		// is not fatal: the defaults are used as if they had been saved,
+		// and the caller reports the directory once.
+		if _, err := appdirs.EnsureConfigDir(); err == nil {
+			_ = Save(path, cfg)
 		}
 		return cfg, path, nil
 	}
@@ -256,13 +520,101 @@ func LoadOrCreate() (Config, string, error) {
 	if err := toml.Unmarshal(bytes, &cfg); err != nil {
 		return Config{}, "", fmt.Errorf("could not parse config file: %w", err)
 	}
+	if cfg.Tools.Groups, err = decodeToolGroups(bytes, cfg.Tools.Groups, false); err != nil {
+		return Config{}, "", fmt.Errorf("could not parse config file: %w", err)
+	}
 	cfg.normalize()
 	return cfg, path, nil
 }
 
+func applyIncludes(cfg *Config, dir string) error {
+	// Decoding an include reuses the slice, so keep a copy of the list.
+	includes := slices.Clone(cfg.Include)
+	for _, include := range includes {
+		path := IncludePath(include, dir)
+		if path == "" {
+			continue
+		}
+		bytes, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("could not read config include %s: %w", path, err)
+		}
+		if err := overlay(cfg, bytes); err != nil {
+			return fmt.Errorf("could not parse config include %s: %w", path, err)
+		}
+	}
+	cfg.Include = includes
+	return nil
+}
+
+// IncludePath resolves an include entry: "~/" is the home directory and a
+// relative path is taken from dir, the main config file's directory.
+func IncludePath(include, dir string) string {
+	include = strings.TrimSpace(include)
+	if include == "" {
+		return ""
+	}
+	if rest, ok := strings.CutPrefix(include, "~/"); ok || include == "~" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		include = filepath.Join(home, rest)
+	}
+	if !filepath.IsAbs(include) {
+		include = filepath.Join(dir, include)
+	}
+	return filepath.Clean(include)
+}
+
+// ApplyOverlay layers a project-local TOML file over cfg. Keys missing from
+// the overlay keep their current values.
+func ApplyOverlay(cfg *Config, path string) error {
+	if cfg == nil {
+		return nil
+	}
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read project config: %w", err)
+	}
+	if err := overlay(cfg, bytes); err != nil {
+		return fmt.Errorf("could not parse project config: %w", err)
+	}
+	return nil
+}
+
+// overlay decodes TOML bytes over a copy of cfg and keeps the result only
+// when they parse.
+func overlay(cfg *Config, bytes []byte) error {
+	overlaid := *cfg
+	overlaid.Providers = make(map[string]ProviderConfig, len(cfg.Providers))
+	for name, provider := range cfg.Providers {
+		overlaid.Providers[name] = provider
+	}
+	overlaid.Quick = make(map[string]QuickCommand, len(cfg.Quick))
+	for name, quick := range cfg.Quick {
+		overlaid.Quick[name] = quick
+	}
+	overlaid.Tools.Groups = maps.Clone(cfg.Tools.Groups)
+	if err := toml.Unmarshal(bytes, &overlaid); err != nil {
+		return err
+	}
+	groups, err := decodeToolGroups(bytes, overlaid.Tools.Groups, false)
+	if err != nil {
+		return err
+	}
+	overlaid.Tools.Groups = groups
+	overlaid.normalize()
+	*cfg = overlaid
+	return nil
+}
+
 func Save(path string, cfg Config) error {
 	cfg.normalize()
-	payload, err := toml.Marshal(cfg)
+	payload, err := marshalConfig(cfg)
 	if err != nil {
 		return fmt.Errorf("could not serialize config: %w", err)
 	}
@@ -325,6 +677,9 @@ func (c *Config) normalize() {
 	if c.Fix.MinConfidence <= 0 || c.Fix.MinConfidence > 1 {
 		c.Fix.MinConfidence = defaults.Fix.MinConfidence
 	}
+	if c.Fix.MaxAttempts <= 0 {
+		c.Fix.MaxAttempts = defaults.Fix.MaxAttempts
+	}
 	if c.Find.Model == "" {
 		c.Find.Model = defaults.Find.Model
 	}
@@ -340,19 +695,62 @@ func (c *Config) normalize() {
 	if c.Find.AIRerank == "" {
 		c.Find.AIRerank = defaults.Find.AIRerank
 	}
-	if c.Prompt.SelfKnowledge == "" {
-		c.Prompt.SelfKnowledge = defaults.Prompt.SelfKnowledge
+	c.Find.OfferRun = normalizeOfferRun(c.Find.OfferRun, defaults.Find.OfferRun)
+	c.History.Secrets = normalizeHistorySecrets(c.History.Secrets, defaults.History.Secrets)
+	c.History.ViaEW = normalizeHistoryViaEW(c.History.ViaEW, defaults.History.ViaEW)
+	c.Journal.Privacy = normalizeJournalPrivacy(c.Journal.Privacy, defaults.Journal.Privacy)
+	c.Embeddings.API = normalizeEmbeddingsAPI(c.Embeddings.API, defaults.Embeddings.API)
+	if c.Embeddings.MinSimilarity <= 0 || c.Embeddings.MinSimilarity > 1 {
+		c.Embeddings.MinSimilarity = defaults.Embeddings.MinSimilarity
+	}
+	c.Prompt.SelfKnowledge = normalizeSelfKnowledge(c.Prompt.SelfKnowledge, defaults.Prompt.SelfKnowledge)
+	if c.Prompt.SelfKnowledgeTokens <= 0 {
+		c.Prompt.SelfKnowledgeTokens = defaults.Prompt.SelfKnowledgeTokens
 	}
 	if c.AI.MinConfidence <= 0 || c.AI.MinConfidence > 1 {
 		c.AI.MinConfidence = defaults.AI.MinConfidence
 	}
+	if c.AI.TimeoutSeconds <= 0 {
+		c.AI.TimeoutSeconds = defaults.AI.TimeoutSeconds
+	}
+	if c.AI.CacheTTLSeconds < 0 {
+		c.AI.CacheTTLSeconds = defaults.AI.CacheTTLSeconds
+	}
+	if c.AI.MaxConcurrent <= 0 {
+		c.AI.MaxConcurrent = defaults.AI.MaxConcurrent
+	}
+	if c.Safety.MaxAutoCommandLength <= 0 {
+		c.Safety.MaxAutoCommandLength = defaults.Safety.MaxAutoCommandLength
+	}
+	if c.Safety.MaxAutoArgs <= 0 {
+		c.Safety.MaxAutoArgs = defaults.Safety.MaxAutoArgs
+	}
+	if c.Safety.MaxAutoPaths <= 0 {
+		c.Safety.MaxAutoPaths = defaults.Safety.MaxAutoPaths
+	}
+	if c.Safety.ProtectedBranches == nil {
+		c.Safety.ProtectedBranches = defaults.Safety.ProtectedBranches
+	}
+	c.Safety.PlanPreview = normalizePlanPreview(c.Safety.PlanPreview, defaults.Safety.PlanPreview)
 	c.UI.Backend = normalizeUIBackend(c.UI.Backend, defaults.UI.Backend)
+	if spec, err := target.Parse(c.Execution.Target); err == nil {
+		c.Execution.Target = spec.String()
+	} else {
+		c.Execution.Target = defaults.Execution.Target
+	}
 	if c.System.RefreshHours <= 0 {
 		c.System.RefreshHours = defaults.System.RefreshHours
 	}
 	if c.System.MaxPromptItems <= 0 {
 		c.System.MaxPromptItems = defaults.System.MaxPromptItems
 	}
+	if c.Doctor.BudgetMs <= 0 {
+		c.Doctor.BudgetMs = defaults.Doctor.BudgetMs
+	}
+	if c.Doctor.CheckTimeoutMs <= 0 {
+		c.Doctor.CheckTimeoutMs = defaults.Doctor.CheckTimeoutMs
+	}
+	c.State.Backend = normalizeStateBackend(c.State.Backend, defaults.State.Backend)
 	if c.Providers == nil {
 		c.Providers = map[string]ProviderConfig{}
 	}
@@ -372,7 +770,7 @@ func (c *Config) normalize() {
 		if provider.Type == "" {
 			provider.Type = "command"
 		}
-		if provider.Command == "" {
+		if provider.Command == "" && provider.Type != "http" {
 			provider.Command = name
 		}
 		if provider.Enabled == nil {
@@ -387,9 +785,14 @@ func (c *Config) normalize() {
 		if provider.Thinking == "" {
 			provider.Thinking = defaults.Fix.Thinking
 		}
-		if provider.ModelFlag == "" {
+		if provider.ModelFlag == "" && provider.Type != "http" {
 			provider.ModelFlag = "--model"
 		}
+		if provider.Type == "http" {
+			provider.API = normalizeProviderAPI(provider.API, "openai")
+		}
+		provider.Intents, _ = normalizeProviderIntents(provider.Intents)
+		provider.MaxRisk = normalizeMaxRisk(provider.MaxRisk)
 		c.Providers[name] = provider
 	}
 
@@ -405,6 +808,14 @@ func (c *Config) normalize() {
 			}
 		}
 	}
+	for name, quick := range c.Quick {
+		normalized := strings.ToLower(strings.TrimSpace(name))
+		quick.Template = strings.TrimSpace(quick.Template)
+		delete(c.Quick, name)
+		if ValidQuickName(normalized) && quick.Template != "" {
+			c.Quick[normalized] = quick
+		}
+	}
 }
 
 func mergeProviderDefaults(target *ProviderConfig, defaults ProviderConfig) {
@@ -432,6 +843,9 @@ func mergeProviderDefaults(target *ProviderConfig, defaults ProviderConfig) {
 	if len(target.Args) == 0 {
 		target.Args = append([]string(nil), defaults.Args...)
 	}
+	if len(target.Env) == 0 {
+		target.Env = append([]string(nil), defaults.Env...)
+	}
 	if target.Models == nil {
 		target.Models = map[string]ModelConfig{}
 	}
@@ -442,6 +856,31 @@ func mergeProviderDefaults(target *ProviderConfig, defaults ProviderConfig) {
 	}
 }
 
+// ErrUnknownKey is wrapped by Set and Get when key is not part of the config
+// surface, including provider and model paths that do not exist.
+var ErrUnknownKey = errors.New("unknown config key")
+
+// InvalidValueError is returned by Set when key exists but value does not fit
+// it.
+type InvalidValueError struct {
+	Key    string
+	Reason string
+	Err    error
+}
+
+func (e *InvalidValueError) Error() string {
+	if e.Err != nil {
+		return e.Key + ": " + e.Err.Error()
+	}
+	return e.Key + " " + e.Reason
+}
+
+func (e *InvalidValueError) Unwrap() error { return e.Err }
+
+func invalidValue(key, reason string) error {
+	return &InvalidValueError{Key: key, Reason: reason}
+}
+
 func (c *Config) Set(key, value string) error {
 	key = strings.TrimSpace(strings.ToLower(key))
 	value = strings.TrimSpace(value)
@@ -453,46 +892,194 @@ func (c *Config) Set(key, value string) error {
 		c.normalize()
 		return nil
 	}
+	if strings.HasPrefix(key, "tools.prefer.") {
+		return c.setToolPreference(strings.TrimPrefix(key, "tools.prefer."), value)
+	}
+	if name, field, ok := toolGroupKey(key); ok {
+		return c.setToolGroupKey(name, field, value)
+	}
+	if name, ok := strings.CutPrefix(key, "quick."); ok {
+		return c.setQuickCommand(name, value)
+	}
 
 	switch key {
 	case "locale":
 		c.Locale = normalizeLocaleSetting(value, "")
 		if c.Locale == "" {
-			return fmt.Errorf("locale must be 'auto' or a locale like en, en-US, hi, hi-IN")
+			return invalidValue("locale", "must be 'auto' or a locale like en, en-US, hi, hi-IN")
 		}
 	case "provider":
 		c.Provider = value
+	case "provider_order":
+		if strings.EqualFold(strings.TrimSpace(value), "default") {
+			c.ProviderOrder = nil
+			break
+		}
+		c.ProviderOrder = splitCommaList(strings.ToLower(value))
 	case "mode":
-		c.Mode = value
+		switch mode := strings.ToLower(value); mode {
+		case "suggest", "confirm", "yolo":
+			c.Mode = mode
+		default:
+			return invalidValue("mode", "must be one of suggest|confirm|yolo")
+		}
 	case "ui.backend":
 		c.UI.Backend = normalizeUIBackend(value, "")
 		if c.UI.Backend == "" {
-			return fmt.Errorf("ui.backend must be one of auto|bubbletea|huh|tview|plain")
+			return invalidValue("ui.backend", "must be one of auto|bubbletea|huh|tview|plain")
+		}
+	case "ui.ascii_only":
+		b, err := parseBool(value)
+		if err != nil {
+			return invalidValue("ui.ascii_only", "must be boolean")
 		}
+		c.UI.ASCIIOnly = b
 	case "system.enable_context":
 		b, err := parseBool(value)
 		if err != nil {
-			return fmt.Errorf("system.enable_context must be boolean")
+			return invalidValue("system.enable_context", "must be boolean")
 		}
 		c.System.EnableContext = b
 	case "system.auto_train":
 		b, err := parseBool(value)
 		if err != nil {
-			return fmt.Errorf("system.auto_train must be boolean")
+			return invalidValue("system.auto_train", "must be boolean")
 		}
 		c.System.AutoTrain = b
 	case "system.refresh_hours":
 		n, err := strconv.Atoi(value)
 		if err != nil || n <= 0 {
-			return fmt.Errorf("system.refresh_hours must be a positive number")
+			return invalidValue("system.refresh_hours", "must be a positive number")
 		}
 		c.System.RefreshHours = n
 	case "system.max_prompt_items":
 		n, err := strconv.Atoi(value)
 		if err != nil || n <= 0 {
-			return fmt.Errorf("system.max_prompt_items must be a positive number")
+			return invalidValue("system.max_prompt_items", "must be a positive number")
 		}
 		c.System.MaxPromptItems = n
+	case "system.share.tools", "system.share.config_files", "system.share.git_ignore", "system.share.user_note":
+		b, err := parseBool(value)
+		if err != nil {
+			return invalidValue(key, "must be boolean")
+		}
+		switch key {
+		case "system.share.tools":
+			c.System.Share.Tools = b
+		case "system.share.config_files":
+			c.System.Share.ConfigFiles = b
+		case "system.share.git_ignore":
+			c.System.Share.GitIgnore = b
+		case "system.share.user_note":
+			c.System.Share.UserNote = b
+		}
+	case "state.backend":
+		c.State.Backend = normalizeStateBackend(value, "")
+		if c.State.Backend == "" {
+			return invalidValue("state.backend", "must be one of files|sqlite")
+		}
+	case "tldr.enabled":
+		b, err := parseBool(value)
+		if err != nil {
+			return invalidValue("tldr.enabled", "must be boolean")
+		}
+		c.TLDR.Enabled = b
+	case "embeddings.enabled":
+		b, err := parseBool(value)
+		if err != nil {
+			return invalidValue("embeddings.enabled", "must be boolean")
+		}
+		c.Embeddings.Enabled = b
+	case "embeddings.api":
+		c.Embeddings.API = normalizeEmbeddingsAPI(value, "")
+		if c.Embeddings.API == "" {
+			return invalidValue("embeddings.api", "must be one of ollama|openai")
+		}
+	case "embeddings.model":
+		c.Embeddings.Model = value
+	case "embeddings.base_url":
+		c.Embeddings.BaseURL = value
+	case "embeddings.api_key_env":
+		c.Embeddings.APIKeyEnv = value
+	case "embeddings.min_similarity":
+		n, err := parseConfidence(value)
+		if err != nil {
+			return invalidValue("embeddings.min_similarity", "must be between 0 and 1")
+		}
+		c.Embeddings.MinSimilarity = n
+	case "runbooks.dir":
+		c.Runbooks.Dir = value
+	case "feedback.enabled":
+		b, err := parseBool(value)
+		if err != nil {
+			return invalidValue("feedback.enabled", "must be boolean")
+		}
+		c.Feedback.Enabled = b
+	case "tips.enabled":
+		b, err := parseBool(value)
+		if err != nil {
+			return invalidValue("tips.enabled", "must be boolean")
+		}
+		c.Tips.Enabled = b
+	case "history.write_back":
+		b, err := parseBool(value)
+		if err != nil {
+			return invalidValue("history.write_back", "must be boolean")
+		}
+		c.History.WriteBack = b
+	case "history.secrets":
+		c.History.Secrets = normalizeHistorySecrets(value, "")
+		if c.History.Secrets == "" {
+			return invalidValue("history.secrets", "must be one of redact|skip")
+		}
+	case "history.watermark":
+		b, err := parseBool(value)
+		if err != nil {
+			return invalidValue("history.watermark", "must be boolean")
+		}
+		c.History.Watermark = b
+	case "history.via_ew":
+		c.History.ViaEW = normalizeHistoryViaEW(value, "")
+		if c.History.ViaEW == "" {
+			return invalidValue("history.via_ew", "must be one of keep|downrank|skip")
+		}
+	case "journal.privacy":
+		c.Journal.Privacy = normalizeJournalPrivacy(value, "")
+		if c.Journal.Privacy == "" {
+			return invalidValue("journal.privacy", "must be one of full|redacted|commands-only|off")
+		}
+	case "tools.prefer":
+		c.Tools.Prefer = nil
+		if strings.EqualFold(value, "none") {
+			break
+		}
+		for _, rule := range splitCommaList(value) {
+			avoid, prefer, ok := strings.Cut(rule, "=")
+			if !ok {
+				return invalidValue("tools.prefer", "must be a comma-separated list of tool=replacement, or none")
+			}
+			if err := c.setToolPreference(avoid, prefer); err != nil {
+				return err
+			}
+		}
+	case "doctor.budget_ms":
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return invalidValue("doctor.budget_ms", "must be a positive number")
+		}
+		c.Doctor.BudgetMs = n
+	case "doctor.check_timeout_ms":
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return invalidValue("doctor.check_timeout_ms", "must be a positive number")
+		}
+		c.Doctor.CheckTimeoutMs = n
+	case "execution.target":
+		spec, err := target.Parse(value)
+		if err != nil {
+			return &InvalidValueError{Key: "execution.target", Err: err}
+		}
+		c.Execution.Target = spec.String()
 	case "fix.model":
 		c.Fix.Model = value
 	case "fix.thinking":
@@ -500,7 +1087,7 @@ func (c *Config) Set(key, value string) error {
 	case "fix.min_confidence":
 		n, err := parseConfidence(value)
 		if err != nil {
-			return fmt.Errorf("fix.min_confidence must be between 0 and 1")
+			return invalidValue("fix.min_confidence", "must be between 0 and 1")
 		}
 		c.Fix.MinConfidence = n
 	case "find.model":
@@ -510,32 +1097,119 @@ func (c *Config) Set(key, value string) error {
 	case "find.min_confidence":
 		n, err := parseConfidence(value)
 		if err != nil {
-			return fmt.Errorf("find.min_confidence must be between 0 and 1")
+			return invalidValue("find.min_confidence", "must be between 0 and 1")
 		}
 		c.Find.MinConfidence = n
+	case "fix.max_attempts":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return invalidValue("fix.max_attempts", "must be a number")
+		}
+		if n <= 0 {
+			return invalidValue("fix.max_attempts", "must be positive")
+		}
+		c.Fix.MaxAttempts = n
 	case "find.max_results":
 		n, err := strconv.Atoi(value)
 		if err != nil {
-			return fmt.Errorf("find.max_results must be a number")
+			return invalidValue("find.max_results", "must be a number")
 		}
 		if n <= 0 {
-			return fmt.Errorf("find.max_results must be positive")
+			return invalidValue("find.max_results", "must be positive")
 		}
 		c.Find.MaxResults = n
+	case "find.temporal_boost":
+		b, err := parseBool(value)
+		if err != nil {
+			return invalidValue("find.temporal_boost", "must be boolean")
+		}
+		c.Find.TemporalBoost = b
+	case "find.offer_run":
+		c.Find.OfferRun = normalizeOfferRun(value, "")
+		if c.Find.OfferRun == "" {
+			return invalidValue("find.offer_run", "must be one of auto|always|never")
+		}
+	case "prompt.self_knowledge":
+		c.Prompt.SelfKnowledge = normalizeSelfKnowledge(value, "")
+		if c.Prompt.SelfKnowledge == "" {
+			return invalidValue("prompt.self_knowledge", "must be one of compiled|full|off")
+		}
+	case "prompt.self_knowledge_tokens":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return invalidValue("prompt.self_knowledge_tokens", "must be a number")
+		}
+		if n <= 0 {
+			return invalidValue("prompt.self_knowledge_tokens", "must be positive")
+		}
+		c.Prompt.SelfKnowledgeTokens = n
 	case "ai.min_confidence":
 		n, err := parseConfidence(value)
 		if err != nil {
-			return fmt.Errorf("ai.min_confidence must be between 0 and 1")
+			return invalidValue("ai.min_confidence", "must be between 0 and 1")
 		}
 		c.AI.MinConfidence = n
 	case "ai.allow_suggest_execution":
 		b, err := parseBool(value)
 		if err != nil {
-			return fmt.Errorf("ai.allow_suggest_execution must be boolean")
+			return invalidValue("ai.allow_suggest_execution", "must be boolean")
 		}
 		c.AI.AllowSuggestExecution = b
+	case "ai.timeout_seconds":
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return invalidValue("ai.timeout_seconds", "must be a positive number")
+		}
+		c.AI.TimeoutSeconds = n
+	case "ai.cache_ttl_seconds":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return invalidValue("ai.cache_ttl_seconds", "must be 0 (off) or a positive number")
+		}
+		c.AI.CacheTTLSeconds = n
+	case "ai.calibrate_confidence":
+		b, err := parseBool(value)
+		if err != nil {
+			return invalidValue("ai.calibrate_confidence", "must be boolean")
+		}
+		c.AI.CalibrateConfidence = b
+	case "ai.max_concurrent":
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return invalidValue("ai.max_concurrent", "must be a positive number")
+		}
+		c.AI.MaxConcurrent = n
+	case "safety.max_auto_command_length":
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return invalidValue("safety.max_auto_command_length", "must be a positive number")
+		}
+		c.Safety.MaxAutoCommandLength = n
+	case "safety.max_auto_args":
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return invalidValue("safety.max_auto_args", "must be a positive number")
+		}
+		c.Safety.MaxAutoArgs = n
+	case "safety.max_auto_paths":
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return invalidValue("safety.max_auto_paths", "must be a positive number")
+		}
+		c.Safety.MaxAutoPaths = n
+	case "safety.protected_branches":
+		if strings.EqualFold(strings.TrimSpace(value), "none") {
+			c.Safety.ProtectedBranches = []string{}
+			break
+		}
+		c.Safety.ProtectedBranches = splitCommaList(value)
+	case "safety.plan_preview":
+		c.Safety.PlanPreview = normalizePlanPreview(value, "")
+		if c.Safety.PlanPreview == "" {
+			return invalidValue("safety.plan_preview", "must be one of ask|always|never")
+		}
 	default:
-		return fmt.Errorf("unknown config key: %s", key)
+		return fmt.Errorf("%w: %s", ErrUnknownKey, key)
 	}
 	c.normalize()
 	return nil
@@ -544,7 +1218,7 @@ func (c *Config) Set(key, value string) error {
 func (c *Config) setProviderKey(key, value string) error {
 	parts := strings.Split(key, ".")
 	if len(parts) < 3 {
-		return fmt.Errorf("invalid provider key: %s", key)
+		return fmt.Errorf("%w: %s", ErrUnknownKey, key)
 	}
 	providerName := parts[1]
 	provider := c.ensureProvider(providerName)
@@ -566,13 +1240,49 @@ func (c *Config) setProviderKey(key, value string) error {
 		case "enabled":
 			b, err := parseBool(value)
 			if err != nil {
-				return fmt.Errorf("providers.%s.enabled must be boolean", providerName)
+				return invalidValue("providers."+providerName+".enabled", "must be boolean")
 			}
 			provider.Enabled = boolPtr(b)
 		case "args":
 			provider.Args = splitCommaList(value)
+		case "env":
+			provider.Env = splitCommaList(value)
+		case "intents":
+			intents, ok := normalizeProviderIntents(splitCommaList(value))
+			if !ok {
+				return invalidValue("providers."+providerName+".intents", "must be a comma-separated list of fix|find|explain, or all")
+			}
+			provider.Intents = intents
+		case "max_risk":
+			provider.MaxRisk = normalizeMaxRisk(value)
+			if provider.MaxRisk == "" && !strings.EqualFold(value, "none") && value != "" {
+				return invalidValue("providers."+providerName+".max_risk", "must be one of low|medium|high|none")
+			}
+		case "api":
+			// Empty clears it; only http providers use the field.
+			provider.API = normalizeProviderAPI(value, "")
+			if provider.API == "" && value != "" {
+				return invalidValue("providers."+providerName+".api", "must be one of openai|anthropic")
+			}
+		case "base_url":
+			provider.BaseURL = value
+		case "api_key_env":
+			provider.APIKeyEnv = value
+		case "timeout_ms", "max_attempts", "timeout_seconds":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return invalidValue("providers."+providerName+"."+parts[2], "must be a positive number, or 0 for the default")
+			}
+			switch parts[2] {
+			case "timeout_ms":
+				provider.TimeoutMs = n
+			case "max_attempts":
+				provider.MaxAttempts = n
+			default:
+				provider.TimeoutSeconds = n
+			}
 		default:
-			return fmt.Errorf("unknown provider field: %s", parts[2])
+			return fmt.Errorf("%w: %s (no provider field %s)", ErrUnknownKey, key, parts[2])
 		}
 		c.Providers[providerName] = provider
 		return nil
@@ -595,14 +1305,14 @@ func (c *Config) setProviderKey(key, value string) error {
 		case "description":
 			model.Description = value
 		default:
-			return fmt.Errorf("unknown model field: %s", field)
+			return fmt.Errorf("%w: %s (no model field %s)", ErrUnknownKey, key, field)
 		}
 		provider.Models[alias] = model
 		c.Providers[providerName] = provider
 		return nil
 	}
 
-	return fmt.Errorf("unsupported provider key path: %s", key)
+	return fmt.Errorf("%w: %s", ErrUnknownKey, key)
 }
 
 func (c *Config) ensureProvider(name string) ProviderConfig {
@@ -633,16 +1343,38 @@ func (c Config) Get(key string) (string, error) {
 	if strings.HasPrefix(key, "providers.") {
 		return c.getProviderKey(key)
 	}
+	if tool, ok := strings.CutPrefix(key, "tools.prefer."); ok {
+		if prefer := c.Tools.Prefer[strings.TrimSpace(tool)]; prefer != "" {
+			return prefer, nil
+		}
+		return "none", nil
+	}
+	if name, field, ok := toolGroupKey(key); ok {
+		return c.getToolGroupKey(name, field)
+	}
+	if name, ok := strings.CutPrefix(key, "quick."); ok {
+		if quick, found := c.Quick[strings.TrimSuffix(strings.TrimSpace(name), ".template")]; found {
+			return quick.Template, nil
+		}
+		return "none", nil
+	}
 
 	switch key {
 	case "locale":
 		return c.Locale, nil
 	case "provider":
 		return c.Provider, nil
+	case "provider_order":
+		if len(c.ProviderOrder) == 0 {
+			return "default", nil
+		}
+		return strings.Join(c.ProviderOrder, ","), nil
 	case "mode":
 		return c.Mode, nil
 	case "ui.backend":
 		return c.UI.Backend, nil
+	case "ui.ascii_only":
+		return strconv.FormatBool(c.UI.ASCIIOnly), nil
 	case "system.enable_context":
 		return strconv.FormatBool(c.System.EnableContext), nil
 	case "system.auto_train":
@@ -651,6 +1383,14 @@ func (c Config) Get(key string) (string, error) {
 		return fmt.Sprintf("%d", c.System.RefreshHours), nil
 	case "system.max_prompt_items":
 		return fmt.Sprintf("%d", c.System.MaxPromptItems), nil
+	case "system.share.tools":
+		return strconv.FormatBool(c.System.Share.Tools), nil
+	case "system.share.config_files":
+		return strconv.FormatBool(c.System.Share.ConfigFiles), nil
+	case "system.share.git_ignore":
+		return strconv.FormatBool(c.System.Share.GitIgnore), nil
+	case "system.share.user_note":
+		return strconv.FormatBool(c.System.Share.UserNote), nil
 	case "fix.model":
 		return c.Fix.Model, nil
 	case "fix.thinking":
@@ -663,26 +1403,105 @@ func (c Config) Get(key string) (string, error) {
 		return c.Find.Thinking, nil
 	case "find.min_confidence":
 		return fmt.Sprintf("%g", c.Find.MinConfidence), nil
+	case "fix.max_attempts":
+		return fmt.Sprintf("%d", c.Fix.MaxAttempts), nil
 	case "find.max_results":
 		return fmt.Sprintf("%d", c.Find.MaxResults), nil
+	case "find.temporal_boost":
+		return strconv.FormatBool(c.Find.TemporalBoost), nil
+	case "find.offer_run":
+		return c.Find.OfferRun, nil
+	case "prompt.self_knowledge":
+		return c.Prompt.SelfKnowledge, nil
+	case "prompt.self_knowledge_tokens":
+		return fmt.Sprintf("%d", c.Prompt.SelfKnowledgeTokens), nil
 	case "ai.min_confidence":
 		return fmt.Sprintf("%g", c.AI.MinConfidence), nil
 	case "ai.allow_suggest_execution":
 		return strconv.FormatBool(c.AI.AllowSuggestExecution), nil
+	case "ai.timeout_seconds":
+		return strconv.Itoa(c.AI.TimeoutSeconds), nil
+	case "ai.cache_ttl_seconds":
+		return strconv.Itoa(c.AI.CacheTTLSeconds), nil
+	case "ai.calibrate_confidence":
+		return strconv.FormatBool(c.AI.CalibrateConfidence), nil
+	case "ai.max_concurrent":
+		return strconv.Itoa(c.AI.MaxConcurrent), nil
+	case "safety.max_auto_command_length":
+		return fmt.Sprintf("%d", c.Safety.MaxAutoCommandLength), nil
+	case "safety.max_auto_args":
+		return fmt.Sprintf("%d", c.Safety.MaxAutoArgs), nil
+	case "safety.max_auto_paths":
+		return fmt.Sprintf("%d", c.Safety.MaxAutoPaths), nil
+	case "safety.protected_branches":
+		if len(c.Safety.ProtectedBranches) == 0 {
+			return "none", nil
+		}
+		return strings.Join(c.Safety.ProtectedBranches, ","), nil
+	case "safety.plan_preview":
+		return c.Safety.PlanPreview, nil
+	case "execution.target":
+		return c.Execution.Target, nil
+	case "doctor.budget_ms":
+		return fmt.Sprintf("%d", c.Doctor.BudgetMs), nil
+	case "doctor.check_timeout_ms":
+		return fmt.Sprintf("%d", c.Doctor.CheckTimeoutMs), nil
+	case "state.backend":
+		return c.State.Backend, nil
+	case "tldr.enabled":
+		return strconv.FormatBool(c.TLDR.Enabled), nil
+	case "embeddings.enabled":
+		return strconv.FormatBool(c.Embeddings.Enabled), nil
+	case "embeddings.api":
+		return c.Embeddings.API, nil
+	case "embeddings.model":
+		return c.Embeddings.Model, nil
+	case "embeddings.base_url":
+		return c.Embeddings.BaseURL, nil
+	case "embeddings.api_key_env":
+		return c.Embeddings.APIKeyEnv, nil
+	case "embeddings.min_similarity":
+		return fmt.Sprintf("%g", c.Embeddings.MinSimilarity), nil
+	case "runbooks.dir":
+		return c.Runbooks.Dir, nil
+	case "feedback.enabled":
+		return strconv.FormatBool(c.Feedback.Enabled), nil
+	case "tips.enabled":
+		return strconv.FormatBool(c.Tips.Enabled), nil
+	case "history.write_back":
+		return strconv.FormatBool(c.History.WriteBack), nil
+	case "history.secrets":
+		return c.History.Secrets, nil
+	case "history.watermark":
+		return strconv.FormatBool(c.History.Watermark), nil
+	case "history.via_ew":
+		return c.History.ViaEW, nil
+	case "journal.privacy":
+		return c.Journal.Privacy, nil
+	case "tools.prefer":
+		if len(c.Tools.Prefer) == 0 {
+			return "none", nil
+		}
+		rules := make([]string, 0, len(c.Tools.Prefer))
+		for avoid, prefer := range c.Tools.Prefer {
+			rules = append(rules, avoid+"="+prefer)
+		}
+		sort.Strings(rules)
+		return strings.Join(rules, ","), nil
 	default:
-		return "", fmt.Errorf("unknown config key: %s", key)
+		return "", fmt.Errorf("%w: %s", ErrUnknownKey, key)
 	}
 }
 
 func (c Config) getProviderKey(key string) (string, error) {
 	parts := strings.Split(key, ".")
 	if len(parts) < 3 {
-		return "", fmt.Errorf("invalid provider key: %s", key)
+		return "", fmt.Errorf("%w: %s", ErrUnknownKey, key)
 	}
 	providerName := parts[1]
 	provider, ok := c.Providers[providerName]
 	if !ok {
-		return "", fmt.Errorf("unknown provider: %s", providerName)
+		return "", fmt.Errorf("%w: %s (no provider %s)", ErrUnknownKey, key, providerName)
 	}
 
 	if len(parts) == 3 {
@@ -703,8 +1522,32 @@ func (c Config) getProviderKey(key string) (string, error) {
 			return strconv.FormatBool(provider.Enabled == nil || *provider.Enabled), nil
 		case "args":
 			return strings.Join(provider.Args, ","), nil
+		case "env":
+			return strings.Join(provider.Env, ","), nil
+		case "intents":
+			if len(provider.Intents) == 0 {
+				return "all", nil
+			}
+			return strings.Join(provider.Intents, ","), nil
+		case "max_risk":
+			if provider.MaxRisk == "" {
+				return "none", nil
+			}
+			return provider.MaxRisk, nil
+		case "api":
+			return provider.API, nil
+		case "base_url":
+			return provider.BaseURL, nil
+		case "api_key_env":
+			return provider.APIKeyEnv, nil
+		case "timeout_ms":
+			return strconv.Itoa(provider.TimeoutMs), nil
+		case "max_attempts":
+			return strconv.Itoa(provider.MaxAttempts), nil
+		case "timeout_seconds":
+			return strconv.Itoa(provider.TimeoutSeconds), nil
 		default:
-			return "", fmt.Errorf("unknown provider field: %s", parts[2])
+			return "", fmt.Errorf("%w: %s (no provider field %s)", ErrUnknownKey, key, parts[2])
 		}
 	}
 
@@ -713,7 +1556,7 @@ func (c Config) getProviderKey(key string) (string, error) {
 		field := parts[4]
 		model, ok := provider.Models[alias]
 		if !ok {
-			return "", fmt.Errorf("unknown model alias: %s", alias)
+			return "", fmt.Errorf("%w: %s (no model alias %s)", ErrUnknownKey, key, alias)
 		}
 		switch field {
 		case "provider_model":
@@ -725,11 +1568,24 @@ func (c Config) getProviderKey(key string) (string, error) {
 		case "description":
 			return model.Description, nil
 		default:
-			return "", fmt.Errorf("unknown model field: %s", field)
+			return "", fmt.Errorf("%w: %s (no model field %s)", ErrUnknownKey, key, field)
 		}
 	}
 
-	return "", fmt.Errorf("unsupported provider key path: %s", key)
+	return "", fmt.Errorf("%w: %s", ErrUnknownKey, key)
+}
+
+// Timeout is how long provider may take to answer: its own
+// timeout_seconds, or ai.timeout_seconds when that is unset.
+func (c Config) Timeout(provider string) time.Duration {
+	seconds := c.AI.TimeoutSeconds
+	if own := c.Providers[provider].TimeoutSeconds; own > 0 {
+		seconds = own
+	}
+	if seconds <= 0 {
+		seconds = Default().AI.TimeoutSeconds
+	}
+	return time.Duration(seconds) * time.Second
 }
 
 func (c Config) ProviderNames() []string {
@@ -752,6 +1608,61 @@ func parseBool(value string) (bool, error) {
 	}
 }
 
+// setToolPreference records that prefer replaces avoid; an empty prefer or
+// "none" drops the rule.
+func (c *Config) setToolPreference(avoid, prefer string) error {
+	avoid = strings.ToLower(strings.TrimSpace(avoid))
+	prefer = strings.Join(strings.Fields(prefer), " ")
+	if avoid == "" || strings.ContainsAny(avoid, ";|&`$<>(){}\"'") {
+		return invalidValue("tools.prefer."+avoid, "must name a tool")
+	}
+	if prefer == "" || strings.EqualFold(prefer, "none") {
+		delete(c.Tools.Prefer, avoid)
+		return nil
+	}
+	if strings.ContainsAny(prefer, ";|&`$<>(){}\"'") || strings.EqualFold(prefer, avoid) {
+		return invalidValue("tools.prefer."+avoid, "must be a different tool without shell operators")
+	}
+	if c.Tools.Prefer == nil {
+		c.Tools.Prefer = map[string]string{}
+	}
+	c.Tools.Prefer[avoid] = prefer
+	return nil
+}
+
+// setQuickCommand sets the template of the quick command name, as
+// quick.<name> or quick.<name>.template; an empty value or "none" drops it.
+func (c *Config) setQuickCommand(name, template string) error {
+	name = strings.TrimSuffix(strings.TrimSpace(name), ".template")
+	if !ValidQuickName(name) {
+		return invalidValue("quick."+name, "name must be letters, digits, - or _")
+	}
+	if template == "" || strings.EqualFold(template, "none") {
+		delete(c.Quick, name)
+		return nil
+	}
+	if c.Quick == nil {
+		c.Quick = map[string]QuickCommand{}
+	}
+	quick := c.Quick[name]
+	quick.Template = template
+	c.Quick[name] = quick
+	return nil
+}
+
+// ValidQuickName reports whether name can follow the slash in `ew /name`.
+func ValidQuickName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
 func splitCommaList(value string) []string {
 	if strings.TrimSpace(value) == "" {
 		return nil
@@ -774,7 +1685,7 @@ func parseConfidence(value string) (float64, error) {
 		return 0, err
 	}
 	if n <= 0 || n > 1 {
-		return 0, fmt.Errorf("confidence must be between 0 and 1")
+		return 0, invalidValue("confidence", "must be between 0 and 1")
 	}
 	return n, nil
 }
@@ -808,6 +1719,117 @@ func normalizeUIBackend(value string, fallback string) string {
 	}
 }
 
+func normalizePlanPreview(value string, fallback string) string {
+	switch normalized := strings.ToLower(strings.TrimSpace(value)); normalized {
+	case "ask", "always", "never":
+		return normalized
+	default:
+		return strings.ToLower(strings.TrimSpace(fallback))
+	}
+}
+
+func normalizeOfferRun(value string, fallback string) string {
+	switch normalized := strings.ToLower(strings.TrimSpace(value)); normalized {
+	case "auto", "always", "never":
+		return normalized
+	default:
+		return strings.ToLower(strings.TrimSpace(fallback))
+	}
+}
+
+// normalizeProviderIntents lowercases and dedupes a provider's intents;
+// "all" (or nothing) means no restriction. ok is false when a value is not
+// an intent.
+func normalizeProviderIntents(values []string) ([]string, bool) {
+	var intents []string
+	ok := true
+	for _, value := range values {
+		switch intent := strings.ToLower(strings.TrimSpace(value)); intent {
+		case "all":
+			return nil, ok
+		case "fix", "find", "explain":
+			if !slices.Contains(intents, intent) {
+				intents = append(intents, intent)
+			}
+		default:
+			ok = false
+		}
+	}
+	return intents, ok
+}
+
+func normalizeMaxRisk(value string) string {
+	switch normalized := strings.ToLower(strings.TrimSpace(value)); normalized {
+	case "low", "medium", "high":
+		return normalized
+	default:
+		return ""
+	}
+}
+
+func normalizeProviderAPI(value string, fallback string) string {
+	switch normalized := strings.ToLower(strings.TrimSpace(value)); normalized {
+	case "openai", "anthropic":
+		return normalized
+	default:
+		return strings.ToLower(strings.TrimSpace(fallback))
+	}
+}
+
+func normalizeEmbeddingsAPI(value string, fallback string) string {
+	switch normalized := strings.ToLower(strings.TrimSpace(value)); normalized {
+	case "ollama", "openai":
+		return normalized
+	default:
+		return strings.ToLower(strings.TrimSpace(fallback))
+	}
+}
+
+func normalizeHistorySecrets(value string, fallback string) string {
+	switch normalized := strings.ToLower(strings.TrimSpace(value)); normalized {
+	case "redact", "skip":
+		return normalized
+	default:
+		return strings.ToLower(strings.TrimSpace(fallback))
+	}
+}
+
+func normalizeHistoryViaEW(value string, fallback string) string {
+	switch normalized := strings.ToLower(strings.TrimSpace(value)); normalized {
+	case "keep", "downrank", "skip":
+		return normalized
+	default:
+		return strings.ToLower(strings.TrimSpace(fallback))
+	}
+}
+
+func normalizeJournalPrivacy(value string, fallback string) string {
+	switch normalized := strings.ToLower(strings.TrimSpace(value)); normalized {
+	case "full", "redacted", "commands-only", "off":
+		return normalized
+	default:
+		return strings.ToLower(strings.TrimSpace(fallback))
+	}
+}
+
+func normalizeSelfKnowledge(value string, fallback string) string {
+	switch normalized := strings.ToLower(strings.TrimSpace(value)); normalized {
+	case "compiled", "full", "off":
+		return normalized
+	default:
+		return strings.ToLower(strings.TrimSpace(fallback))
+	}
+}
+
+func normalizeStateBackend(value string, fallback string) string {
+	switch normalized := strings.ToLower(strings.TrimSpace(value)); normalized {
+	case "files", "sqlite":
+		return normalized
+	default:
+		return strings.ToLower(strings.TrimSpace(fallback))
+	}
+}
+
 func normalizeLocaleSetting(value string, fallback string) string {
 	trimmed := strings.TrimSpace(value)
 	if trimmed == "" {
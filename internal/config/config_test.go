@@ -1,12 +1,16 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/ashwch/ew/internal/appdirs"
 	"github.com/pelletier/go-toml/v2"
 )
 
@@ -124,6 +128,35 @@ func TestSetRejectsInvalidUIBackend(t *testing.T) {
 	}
 }
 
+func TestSetAndGetErrorsAreTyped(t *testing.T) {
+	cfg := Default()
+	for _, key := range []string{"no.such_key", "providers.codex", "providers.codex.colour", "providers.codex.models.fast.colour"} {
+		if err := cfg.Set(key, "x"); !errors.Is(err, ErrUnknownKey) {
+			t.Fatalf("Set(%q): expected ErrUnknownKey, got %v", key, err)
+		}
+	}
+	for _, key := range []string{"no.such_key", "providers.nobody.model"} {
+		if _, err := cfg.Get(key); !errors.Is(err, ErrUnknownKey) {
+			t.Fatalf("Get(%q): expected ErrUnknownKey, got %v", key, err)
+		}
+	}
+
+	var invalid *InvalidValueError
+	err := cfg.Set("find.max_results", "-3")
+	if !errors.As(err, &invalid) || invalid.Key != "find.max_results" {
+		t.Fatalf("expected InvalidValueError for find.max_results, got %v", err)
+	}
+	if err.Error() != "find.max_results must be positive" {
+		t.Fatalf("unexpected message %q", err.Error())
+	}
+	if err := cfg.Set("providers.codex.enabled", "maybe"); !errors.As(err, &invalid) || invalid.Key != "providers.codex.enabled" {
+		t.Fatalf("expected InvalidValueError for providers.codex.enabled, got %v", err)
+	}
+	if errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("an invalid value is not an unknown key")
+	}
+}
+
 func TestDefaultUIBackendIsBubbleTea(t *testing.T) {
 	cfg := Default()
 	if cfg.UI.Backend != "bubbletea" {
@@ -166,6 +199,53 @@ func TestSetRejectsInvalidSystemConfig(t *testing.T) {
 	}
 }
 
+func TestSystemShareDefaultsOnAndSetGet(t *testing.T) {
+	cfg := Default()
+	if !cfg.System.Share.Tools || !cfg.System.Share.ConfigFiles || !cfg.System.Share.GitIgnore || !cfg.System.Share.UserNote {
+		t.Fatalf("expected every profile part shared by default, got %+v", cfg.System.Share)
+	}
+	if err := cfg.Set("system.share.user_note", "false"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := cfg.Get("system.share.user_note"); got != "false" || cfg.System.Share.Tools != true {
+		t.Fatalf("expected only the note kept local, got %q %+v", got, cfg.System.Share)
+	}
+	if err := cfg.Set("system.share.tools", "sometimes"); err == nil {
+		t.Fatalf("expected a non-boolean to be rejected")
+	}
+
+	overlaid := Default()
+	if err := overlay(&overlaid, []byte("[system.share]\ngit_ignore = false\n")); err != nil {
+		t.Fatal(err)
+	}
+	if overlaid.System.Share.GitIgnore || !overlaid.System.Share.ConfigFiles {
+		t.Fatalf("expected keys missing from the file to stay shared, got %+v", overlaid.System.Share)
+	}
+}
+
+func TestSetGetSafetyAutoExecutionLimits(t *testing.T) {
+	cfg := Default()
+	if cfg.Safety.MaxAutoCommandLength <= 0 || cfg.Safety.MaxAutoArgs <= 0 || cfg.Safety.MaxAutoPaths <= 0 {
+		t.Fatalf("expected positive default auto-execution limits, got %+v", cfg.Safety)
+	}
+	if err := cfg.Set("safety.max_auto_args", "12"); err != nil {
+		t.Fatalf("set safety.max_auto_args failed: %v", err)
+	}
+	got, err := cfg.Get("safety.max_auto_args")
+	if err != nil {
+		t.Fatalf("get safety.max_auto_args failed: %v", err)
+	}
+	if got != "12" {
+		t.Fatalf("expected 12, got %q", got)
+	}
+	if err := cfg.Set("safety.max_auto_paths", "0"); err == nil {
+		t.Fatalf("expected non-positive max_auto_paths to be rejected")
+	}
+	if err := cfg.Set("safety.max_auto_command_length", "abc"); err == nil {
+		t.Fatalf("expected non-numeric max_auto_command_length to be rejected")
+	}
+}
+
 func TestNormalizePreservesExplicitSafetyFalseValues(t *testing.T) {
 	cfg := Default()
 	cfg.Safety.RedactSecrets = false
@@ -235,3 +315,598 @@ func TestSaveAtomicWriteProducesParseableConfigUnderConcurrentSaves(t *testing.T
 		t.Fatalf("expected final config to be parseable TOML, got error: %v\ncontent:\n%s", err, string(bytes))
 	}
 }
+
+func TestLoadFileUsesDefaultsWhenConfigDirIsUnwritable(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	blocker := filepath.Join(home, ".config")
+	t.Setenv("XDG_CONFIG_HOME", blocker)
+	if err := os.WriteFile(blocker, []byte("not a dir"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, _, err := LoadFile()
+	if err != nil {
+		t.Fatalf("expected defaults without an error, got %v", err)
+	}
+	if cfg.Mode != Default().Mode {
+		t.Fatalf("expected default mode, got %q", cfg.Mode)
+	}
+}
+
+func TestApplyOverlayKeepsUnsetKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".ew.toml")
+	overlay := "mode = \"suggest\"\n\n[providers.claude]\nmodel = \"haiku\"\n"
+	if err := os.WriteFile(path, []byte(overlay), 0o600); err != nil {
+		t.Fatalf("write overlay failed: %v", err)
+	}
+
+	cfg := Default()
+	cfg.Provider = "codex"
+	if err := ApplyOverlay(&cfg, path); err != nil {
+		t.Fatalf("ApplyOverlay failed: %v", err)
+	}
+	if cfg.Mode != "suggest" {
+		t.Fatalf("expected overlay mode, got %q", cfg.Mode)
+	}
+	if cfg.Provider != "codex" {
+		t.Fatalf("expected provider to survive overlay, got %q", cfg.Provider)
+	}
+	if cfg.Providers["claude"].Model != "haiku" {
+		t.Fatalf("expected overlay provider model, got %q", cfg.Providers["claude"].Model)
+	}
+	if cfg.Providers["claude"].Command != "claude" {
+		t.Fatalf("expected provider defaults to be kept, got %+v", cfg.Providers["claude"])
+	}
+}
+
+func TestLoadOrCreateAppliesIncludesInOrder(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("APPDATA", filepath.Join(home, ".config"))
+	path, err := appdirs.ConfigFilePath()
+	if err != nil {
+		t.Fatalf("config path failed: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+	mainFile := "mode = \"suggest\"\nprovider = \"codex\"\ninclude = [\"shared.toml\", \"~/work.toml\", \"missing.toml\"]\n"
+	shared := "mode = \"yolo\"\n\n[providers.claude]\nmodel = \"haiku\"\n"
+	work := "mode = \"confirm\"\ninclude = [\"nested.toml\"]\n\n[providers.codex]\nenv = [\"OPENAI_API_KEY\"]\n"
+	for file, content := range map[string]string{
+		path: mainFile,
+		filepath.Join(filepath.Dir(path), "shared.toml"): shared,
+		filepath.Join(home, "work.toml"):                 work,
+	} {
+		if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+			t.Fatalf("write %s failed: %v", file, err)
+		}
+	}
+
+	cfg, _, err := LoadOrCreate()
+	if err != nil {
+		t.Fatalf("LoadOrCreate failed: %v", err)
+	}
+	if cfg.Mode != "confirm" {
+		t.Fatalf("expected the last include to win, got mode %q", cfg.Mode)
+	}
+	if cfg.Provider != "codex" {
+		t.Fatalf("expected main file provider to survive includes, got %q", cfg.Provider)
+	}
+	if cfg.Providers["claude"].Model != "haiku" {
+		t.Fatalf("expected shared include provider model, got %q", cfg.Providers["claude"].Model)
+	}
+	if got := cfg.Providers["codex"].Env; len(got) != 1 || got[0] != "OPENAI_API_KEY" {
+		t.Fatalf("expected work include provider env, got %v", got)
+	}
+	if len(cfg.Include) != 3 || cfg.Include[0] != "shared.toml" {
+		t.Fatalf("expected the main file's include list, got %v", cfg.Include)
+	}
+
+	base, _, err := LoadFile()
+	if err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+	if base.Mode != "suggest" || base.Providers["claude"].Model != "sonnet" {
+		t.Fatalf("expected LoadFile to skip includes, got mode %q model %q", base.Mode, base.Providers["claude"].Model)
+	}
+
+	if err := os.WriteFile(filepath.Join(home, "work.toml"), []byte("mode = [\n"), 0o600); err != nil {
+		t.Fatalf("write work include failed: %v", err)
+	}
+	if _, _, err := LoadOrCreate(); err == nil || !strings.Contains(err.Error(), "could not parse config include") {
+		t.Fatalf("expected include parse error, got %v", err)
+	}
+}
+
+func TestSetGetExecutionTarget(t *testing.T) {
+	cfg := Default()
+	if got, _ := cfg.Get("execution.target"); got != "local" {
+		t.Fatalf("expected local default target, got %q", got)
+	}
+	if err := cfg.Set("execution.target", "Docker:web"); err != nil {
+		t.Fatalf("set execution.target failed: %v", err)
+	}
+	if got, _ := cfg.Get("execution.target"); got != "docker:web" {
+		t.Fatalf("expected canonical docker target, got %q", got)
+	}
+	if err := cfg.Set("execution.target", "podman:web"); err == nil {
+		t.Fatalf("expected unknown target kind to fail")
+	}
+}
+
+func TestFindTemporalBoostIsOptIn(t *testing.T) {
+	cfg := Default()
+	if got, _ := cfg.Get("find.temporal_boost"); got != "false" {
+		t.Fatalf("expected temporal boost off by default, got %q", got)
+	}
+	if err := cfg.Set("find.temporal_boost", "true"); err != nil {
+		t.Fatalf("set find.temporal_boost failed: %v", err)
+	}
+	if !cfg.Find.TemporalBoost {
+		t.Fatalf("expected temporal boost enabled")
+	}
+	if err := cfg.Set("find.temporal_boost", "sometimes"); err == nil {
+		t.Fatalf("expected non-boolean value to fail")
+	}
+}
+
+func TestProtectedBranchesSetGet(t *testing.T) {
+	cfg := Default()
+	if got, _ := cfg.Get("safety.protected_branches"); got != "main,master,release/*" {
+		t.Fatalf("expected default protected branches, got %q", got)
+	}
+	if err := cfg.Set("safety.protected_branches", "main, prod/*"); err != nil {
+		t.Fatalf("set protected branches: %v", err)
+	}
+	if got, _ := cfg.Get("safety.protected_branches"); got != "main,prod/*" {
+		t.Fatalf("expected updated protected branches, got %q", got)
+	}
+	if err := cfg.Set("safety.protected_branches", "none"); err != nil {
+		t.Fatalf("clear protected branches: %v", err)
+	}
+	if got, _ := cfg.Get("safety.protected_branches"); got != "none" {
+		t.Fatalf("expected none, got %q", got)
+	}
+}
+
+func TestPlanPreviewSetting(t *testing.T) {
+	cfg := Default()
+	if cfg.Safety.PlanPreview != "ask" {
+		t.Fatalf("expected ask by default, got %q", cfg.Safety.PlanPreview)
+	}
+	if err := cfg.Set("safety.plan_preview", "Always"); err != nil {
+		t.Fatalf("set plan_preview: %v", err)
+	}
+	if got, _ := cfg.Get("safety.plan_preview"); got != "always" {
+		t.Fatalf("expected always, got %q", got)
+	}
+	if err := cfg.Set("safety.plan_preview", "sometimes"); err == nil {
+		t.Fatalf("expected invalid value to fail")
+	}
+}
+
+func TestHistorySecretsSetting(t *testing.T) {
+	cfg := Default()
+	if got, _ := cfg.Get("history.secrets"); got != "redact" {
+		t.Fatalf("expected redact by default, got %q", got)
+	}
+	if err := cfg.Set("history.secrets", "Skip"); err != nil {
+		t.Fatalf("set history.secrets: %v", err)
+	}
+	if cfg.History.Secrets != "skip" {
+		t.Fatalf("expected skip, got %q", cfg.History.Secrets)
+	}
+	if err := cfg.Set("history.secrets", "keep"); err == nil {
+		t.Fatalf("expected invalid value to fail")
+	}
+}
+
+func TestHistoryWatermarkSettings(t *testing.T) {
+	cfg := Default()
+	if got, _ := cfg.Get("history.watermark"); got != "true" {
+		t.Fatalf("expected the watermark on by default, got %q", got)
+	}
+	if got, _ := cfg.Get("history.via_ew"); got != "downrank" {
+		t.Fatalf("expected downrank by default, got %q", got)
+	}
+	if err := cfg.Set("history.via_ew", "Skip"); err != nil {
+		t.Fatalf("set history.via_ew: %v", err)
+	}
+	if cfg.History.ViaEW != "skip" {
+		t.Fatalf("expected skip, got %q", cfg.History.ViaEW)
+	}
+	if err := cfg.Set("history.via_ew", "hide"); err == nil {
+		t.Fatalf("expected invalid value to fail")
+	}
+	if err := cfg.Set("history.watermark", "off"); err != nil || cfg.History.Watermark {
+		t.Fatalf("expected the watermark off, got %v, %v", cfg.History.Watermark, err)
+	}
+}
+
+func TestDoctorBudgetSetting(t *testing.T) {
+	cfg := Default()
+	if got, _ := cfg.Get("doctor.budget_ms"); got != "3000" {
+		t.Fatalf("expected default budget 3000, got %q", got)
+	}
+	if err := cfg.Set("doctor.budget_ms", "500"); err != nil {
+		t.Fatalf("set budget: %v", err)
+	}
+	if cfg.Doctor.BudgetMs != 500 {
+		t.Fatalf("expected budget 500, got %d", cfg.Doctor.BudgetMs)
+	}
+	if err := cfg.Set("doctor.budget_ms", "0"); err == nil {
+		t.Fatalf("expected non-positive budget to be rejected")
+	}
+	if got, _ := cfg.Get("doctor.check_timeout_ms"); got != "1500" {
+		t.Fatalf("expected default check timeout 1500, got %q", got)
+	}
+	if err := cfg.Set("doctor.check_timeout_ms", "250"); err != nil || cfg.Doctor.CheckTimeoutMs != 250 {
+		t.Fatalf("expected check timeout 250, got %d %v", cfg.Doctor.CheckTimeoutMs, err)
+	}
+	if err := cfg.Set("doctor.check_timeout_ms", "-1"); err == nil {
+		t.Fatalf("expected non-positive check timeout to be rejected")
+	}
+}
+
+func TestStateBackendSetting(t *testing.T) {
+	cfg := Default()
+	if got, _ := cfg.Get("state.backend"); got != "files" {
+		t.Fatalf("expected files backend by default, got %q", got)
+	}
+	if err := cfg.Set("state.backend", "SQLite"); err != nil || cfg.State.Backend != "sqlite" {
+		t.Fatalf("expected sqlite backend, got %q (%v)", cfg.State.Backend, err)
+	}
+	if err := cfg.Set("state.backend", "postgres"); err == nil {
+		t.Fatalf("expected unknown backend to be rejected")
+	}
+}
+
+func TestDiffReportsOnlyChangedKeys(t *testing.T) {
+	cfg := Default()
+	if _, keys, err := Diff(cfg); err != nil || len(keys) != 0 {
+		t.Fatalf("expected no differences for defaults, got %v (%v)", keys, err)
+	}
+
+	if err := cfg.Set("mode", "yolo"); err != nil {
+		t.Fatalf("set mode: %v", err)
+	}
+	if err := cfg.Set("safety.protected_branches", "main,prod/*"); err != nil {
+		t.Fatalf("set protected branches: %v", err)
+	}
+	changed, keys, err := Diff(cfg)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if strings.Join(keys, ",") != "mode,safety.protected_branches" {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+	safety, ok := changed["safety"].(map[string]any)
+	if !ok || len(safety) != 1 {
+		t.Fatalf("expected only protected_branches under safety, got %#v", changed["safety"])
+	}
+
+	fragment, _, err := DiffTOML(cfg)
+	if err != nil {
+		t.Fatalf("DiffTOML failed: %v", err)
+	}
+	if !strings.Contains(fragment, "mode = 'yolo'") || !strings.Contains(fragment, "[safety]") {
+		t.Fatalf("unexpected TOML fragment:\n%s", fragment)
+	}
+	if strings.Contains(fragment, "redact_secrets") {
+		t.Fatalf("expected unchanged keys to be omitted:\n%s", fragment)
+	}
+}
+
+func TestSetGetUIASCIIOnly(t *testing.T) {
+	cfg := Default()
+	if got, _ := cfg.Get("ui.ascii_only"); got != "false" {
+		t.Fatalf("expected ascii_only off by default, got %q", got)
+	}
+	if err := cfg.Set("ui.ascii_only", "true"); err != nil {
+		t.Fatalf("set ui.ascii_only failed: %v", err)
+	}
+	if !cfg.UI.ASCIIOnly {
+		t.Fatalf("expected ui.ascii_only to be set")
+	}
+	if err := cfg.Set("ui.ascii_only", "sometimes"); err == nil {
+		t.Fatalf("expected a non-boolean ui.ascii_only to be rejected")
+	}
+}
+
+func TestSetGetToolPreferences(t *testing.T) {
+	cfg := Default()
+	if err := cfg.Set("tools.prefer.docker-compose", "docker compose"); err != nil {
+		t.Fatalf("set tools.prefer.docker-compose failed: %v", err)
+	}
+	if err := cfg.Set("tools.prefer.LS", "eza"); err != nil {
+		t.Fatalf("set tools.prefer.ls failed: %v", err)
+	}
+	if got, _ := cfg.Get("tools.prefer"); got != "docker-compose=docker compose,ls=eza" {
+		t.Fatalf("unexpected tools.prefer %q", got)
+	}
+	if err := cfg.Set("tools.prefer.ls", "eza; rm -rf /"); err == nil {
+		t.Fatalf("expected shell operators in a replacement to be rejected")
+	}
+	if err := cfg.Set("tools.prefer.ls", "none"); err != nil {
+		t.Fatalf("clear tools.prefer.ls failed: %v", err)
+	}
+	if got, _ := cfg.Get("tools.prefer.ls"); got != "none" {
+		t.Fatalf("expected the ls rule to be dropped, got %q", got)
+	}
+	if err := cfg.Set("tools.prefer", "grep=rg,cat=bat"); err != nil {
+		t.Fatalf("set tools.prefer failed: %v", err)
+	}
+	if len(cfg.Tools.Prefer) != 2 || cfg.Tools.Prefer["grep"] != "rg" {
+		t.Fatalf("expected the list to replace the rules, got %v", cfg.Tools.Prefer)
+	}
+}
+
+func TestSetGetFindOfferRun(t *testing.T) {
+	cfg := Default()
+	if got, _ := cfg.Get("find.offer_run"); got != "auto" {
+		t.Fatalf("expected find.offer_run to default to auto, got %q", got)
+	}
+	if err := cfg.Set("find.offer_run", "Never"); err != nil {
+		t.Fatalf("set find.offer_run failed: %v", err)
+	}
+	if cfg.Find.OfferRun != "never" {
+		t.Fatalf("expected never, got %q", cfg.Find.OfferRun)
+	}
+	if err := cfg.Set("find.offer_run", "sometimes"); err == nil {
+		t.Fatalf("expected an unknown find.offer_run value to be rejected")
+	}
+}
+
+func TestSetGetPromptSelfKnowledge(t *testing.T) {
+	cfg := Default()
+	if got, _ := cfg.Get("prompt.self_knowledge"); got != "compiled" {
+		t.Fatalf("expected prompt.self_knowledge to default to compiled, got %q", got)
+	}
+	if err := cfg.Set("prompt.self_knowledge", "OFF"); err != nil {
+		t.Fatalf("set prompt.self_knowledge failed: %v", err)
+	}
+	if cfg.Prompt.SelfKnowledge != "off" {
+		t.Fatalf("expected off, got %q", cfg.Prompt.SelfKnowledge)
+	}
+	if err := cfg.Set("prompt.self_knowledge", "some"); err == nil {
+		t.Fatalf("expected an unknown prompt.self_knowledge value to be rejected")
+	}
+	if err := cfg.Set("prompt.self_knowledge_tokens", "800"); err != nil {
+		t.Fatalf("set prompt.self_knowledge_tokens failed: %v", err)
+	}
+	if got, _ := cfg.Get("prompt.self_knowledge_tokens"); got != "800" {
+		t.Fatalf("expected 800, got %q", got)
+	}
+	if err := cfg.Set("prompt.self_knowledge_tokens", "0"); err == nil {
+		t.Fatalf("expected a zero token budget to be rejected")
+	}
+}
+
+func TestSetGetQuickCommand(t *testing.T) {
+	cfg := Default()
+	if err := cfg.Set("quick.deploy", "kubectl rollout restart deploy/{1} -n {2}"); err != nil {
+		t.Fatalf("set quick.deploy failed: %v", err)
+	}
+	if got, _ := cfg.Get("quick.deploy.template"); got != "kubectl rollout restart deploy/{1} -n {2}" {
+		t.Fatalf("unexpected template %q", got)
+	}
+	if err := cfg.Set("quick.bad name", "ls"); err == nil {
+		t.Fatalf("expected an invalid quick command name to be rejected")
+	}
+	if err := cfg.Set("quick.deploy", "none"); err != nil {
+		t.Fatalf("drop quick.deploy failed: %v", err)
+	}
+	if got, _ := cfg.Get("quick.deploy"); got != "none" {
+		t.Fatalf("expected the quick command to be dropped, got %q", got)
+	}
+}
+
+func TestNormalizeQuickCommands(t *testing.T) {
+	cfg := Default()
+	cfg.Quick = map[string]QuickCommand{
+		"Deploy": {Template: " kubectl rollout restart deploy/{1} "},
+		"empty":  {Template: " "},
+		"a b":    {Template: "ls"},
+	}
+	cfg.normalize()
+	if len(cfg.Quick) != 1 || cfg.Quick["deploy"].Template != "kubectl rollout restart deploy/{1}" {
+		t.Fatalf("unexpected quick commands %+v", cfg.Quick)
+	}
+}
+
+func TestSetGetProviderIntentsAndMaxRisk(t *testing.T) {
+	cfg := Default()
+	if got, _ := cfg.Get("providers.codex.intents"); got != "all" {
+		t.Fatalf("expected providers to allow every intent by default, got %q", got)
+	}
+	if err := cfg.Set("providers.codex.intents", "Find"); err != nil {
+		t.Fatalf("set intents failed: %v", err)
+	}
+	if cfg.Providers["codex"].AllowsIntent("fix") || !cfg.Providers["codex"].AllowsIntent("find") {
+		t.Fatalf("expected codex to be limited to find, got %v", cfg.Providers["codex"].Intents)
+	}
+	if err := cfg.Set("providers.codex.intents", "find, explain"); err != nil || !cfg.Providers["codex"].AllowsIntent("explain") {
+		t.Fatalf("expected explain to be a valid intent, got %v %v", cfg.Providers["codex"].Intents, err)
+	}
+	if err := cfg.Set("providers.codex.intents", "fix,deploy"); err == nil {
+		t.Fatalf("expected an unknown intent to be rejected")
+	}
+	if err := cfg.Set("providers.codex.max_risk", "Medium"); err != nil {
+		t.Fatalf("set max_risk failed: %v", err)
+	}
+	if got, _ := cfg.Get("providers.codex.max_risk"); got != "medium" {
+		t.Fatalf("expected medium, got %q", got)
+	}
+	if err := cfg.Set("providers.codex.max_risk", "extreme"); err == nil {
+		t.Fatalf("expected an unknown risk to be rejected")
+	}
+	if err := cfg.Set("providers.codex.max_risk", "none"); err != nil {
+		t.Fatalf("clear max_risk failed: %v", err)
+	}
+	if got, _ := cfg.Get("providers.codex.max_risk"); got != "none" {
+		t.Fatalf("expected the ceiling to be cleared, got %q", got)
+	}
+}
+
+func TestHTTPProviderKeys(t *testing.T) {
+	cfg := Default()
+	for _, kv := range [][2]string{
+		{"providers.openrouter.type", "http"},
+		{"providers.openrouter.base_url", "https://openrouter.ai/api/v1"},
+		{"providers.openrouter.api_key_env", "OPENROUTER_API_KEY"},
+		{"providers.openrouter.model", "openai/gpt-5-mini"},
+		{"providers.openrouter.timeout_ms", "20000"},
+	} {
+		if err := cfg.Set(kv[0], kv[1]); err != nil {
+			t.Fatalf("set %s failed: %v", kv[0], err)
+		}
+	}
+	provider := cfg.Providers["openrouter"]
+	if provider.API != "openai" || provider.BaseURL != "https://openrouter.ai/api/v1" || provider.TimeoutMs != 20000 {
+		t.Fatalf("unexpected http provider %+v", provider)
+	}
+	if err := cfg.Set("providers.openrouter.api", "Anthropic"); err != nil {
+		t.Fatalf("set api failed: %v", err)
+	}
+	if got, _ := cfg.Get("providers.openrouter.api"); got != "anthropic" {
+		t.Fatalf("expected anthropic, got %q", got)
+	}
+	if err := cfg.Set("providers.openrouter.api", "gemini"); err == nil {
+		t.Fatalf("expected an unknown api to be rejected")
+	}
+	if err := cfg.Set("providers.openrouter.max_attempts", "-1"); err == nil {
+		t.Fatalf("expected a negative max_attempts to be rejected")
+	}
+}
+
+func TestProviderEnvAllowlist(t *testing.T) {
+	cfg := Default()
+	if got, _ := cfg.Get("providers.codex.env"); got != "OPENAI_*,CODEX_*" {
+		t.Fatalf("expected codex to default to its own key variables, got %q", got)
+	}
+	if err := cfg.Set("providers.codex.env", "OPENAI_*, CODEX_*, HTTPS_PROXY"); err != nil {
+		t.Fatalf("set env failed: %v", err)
+	}
+	if got, _ := cfg.Get("providers.codex.env"); got != "OPENAI_*,CODEX_*,HTTPS_PROXY" {
+		t.Fatalf("unexpected env %q", got)
+	}
+
+	// A config saved before env existed picks up the default allowlist.
+	saved := Config{Providers: map[string]ProviderConfig{"claude": {Type: "command", Command: "claude"}}}
+	saved.normalize()
+	if got := saved.Providers["claude"].Env; len(got) != 5 || got[0] != "ANTHROPIC_*" || got[2] != "AWS_*" {
+		t.Fatalf("expected the default claude allowlist, got %q", got)
+	}
+}
+
+func TestProviderTimeouts(t *testing.T) {
+	cfg := Default()
+	if got := cfg.Timeout("codex"); got != 90*time.Second {
+		t.Fatalf("expected the 90s default, got %s", got)
+	}
+	if err := cfg.Set("ai.timeout_seconds", "45"); err != nil {
+		t.Fatalf("set ai.timeout_seconds failed: %v", err)
+	}
+	if err := cfg.Set("providers.claude.timeout_seconds", "20"); err != nil {
+		t.Fatalf("set providers.claude.timeout_seconds failed: %v", err)
+	}
+	if cfg.Timeout("codex") != 45*time.Second || cfg.Timeout("claude") != 20*time.Second {
+		t.Fatalf("unexpected timeouts codex=%s claude=%s", cfg.Timeout("codex"), cfg.Timeout("claude"))
+	}
+	if got, _ := cfg.Get("providers.claude.timeout_seconds"); got != "20" {
+		t.Fatalf("unexpected provider timeout %q", got)
+	}
+	if err := cfg.Set("ai.timeout_seconds", "0"); err == nil {
+		t.Fatalf("expected a zero global timeout to be rejected")
+	}
+}
+
+func TestSetGetMaxConcurrent(t *testing.T) {
+	cfg := Default()
+	if got, _ := cfg.Get("ai.max_concurrent"); got != "2" {
+		t.Fatalf("expected 2 provider requests at once by default, got %q", got)
+	}
+	if err := cfg.Set("ai.max_concurrent", "4"); err != nil || cfg.AI.MaxConcurrent != 4 {
+		t.Fatalf("set ai.max_concurrent failed: %d %v", cfg.AI.MaxConcurrent, err)
+	}
+	if err := cfg.Set("ai.max_concurrent", "0"); err == nil {
+		t.Fatalf("expected 0 to be rejected")
+	}
+}
+
+func TestSetGetProviderOrder(t *testing.T) {
+	cfg := Default()
+	if got, _ := cfg.Get("provider_order"); got != "default" {
+		t.Fatalf("expected the built-in order by default, got %q", got)
+	}
+	if err := cfg.Set("provider_order", "Codex, claude,,ollama"); err != nil {
+		t.Fatalf("set provider_order failed: %v", err)
+	}
+	if got, _ := cfg.Get("provider_order"); got != "codex,claude,ollama" {
+		t.Fatalf("unexpected provider_order %q", got)
+	}
+	if err := cfg.Set("provider_order", "default"); err != nil || cfg.ProviderOrder != nil {
+		t.Fatalf("expected default to clear provider_order, got %v %v", cfg.ProviderOrder, err)
+	}
+}
+
+func TestSetGetCacheTTL(t *testing.T) {
+	cfg := Default()
+	if got, _ := cfg.Get("ai.cache_ttl_seconds"); got != "900" {
+		t.Fatalf("expected the 900s default, got %q", got)
+	}
+	if err := cfg.Set("ai.cache_ttl_seconds", "0"); err != nil || cfg.AI.CacheTTLSeconds != 0 {
+		t.Fatalf("expected 0 to turn the cache off, got %d %v", cfg.AI.CacheTTLSeconds, err)
+	}
+	if err := cfg.Set("ai.cache_ttl_seconds", "-5"); err == nil {
+		t.Fatalf("expected a negative ttl to be rejected")
+	}
+}
+
+func TestSetGetCalibrateConfidence(t *testing.T) {
+	cfg := Default()
+	if got, _ := cfg.Get("ai.calibrate_confidence"); got != "true" {
+		t.Fatalf("expected calibration on by default, got %q", got)
+	}
+	if err := cfg.Set("ai.calibrate_confidence", "off"); err != nil || cfg.AI.CalibrateConfidence {
+		t.Fatalf("expected calibration to turn off, got %v %v", cfg.AI.CalibrateConfidence, err)
+	}
+	if err := cfg.Set("ai.calibrate_confidence", "sometimes"); err == nil {
+		t.Fatalf("expected a non-boolean to be rejected")
+	}
+}
+
+func TestSetGetJournalPrivacy(t *testing.T) {
+	cfg := Default()
+	if got, _ := cfg.Get("journal.privacy"); got != "redacted" {
+		t.Fatalf("expected the redacted default, got %q", got)
+	}
+	if err := cfg.Set("journal.privacy", "Commands-Only"); err != nil {
+		t.Fatalf("set journal.privacy failed: %v", err)
+	}
+	if got, _ := cfg.Get("journal.privacy"); got != "commands-only" {
+		t.Fatalf("unexpected journal.privacy %q", got)
+	}
+	var invalid *InvalidValueError
+	if err := cfg.Set("journal.privacy", "everything"); !errors.As(err, &invalid) {
+		t.Fatalf("expected an invalid value error, got %v", err)
+	}
+}
+
+func TestSetGetTipsEnabled(t *testing.T) {
+	cfg := Default()
+	if !cfg.Tips.Enabled {
+		t.Fatalf("expected tips to be on by default")
+	}
+	if err := cfg.Set("tips.enabled", "false"); err != nil {
+		t.Fatalf("set tips.enabled failed: %v", err)
+	}
+	if got, _ := cfg.Get("tips.enabled"); got != "false" {
+		t.Fatalf("expected false, got %q", got)
+	}
+	if err := cfg.Set("tips.enabled", "sometimes"); err == nil {
+		t.Fatalf("expected a non-boolean to be rejected")
+	}
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// Diff returns the settings in cfg whose values differ from Default(),
+// nested by section the same way the config file is, plus the dotted keys
+// that changed. Both configs are normalized first. Fields tagged omitempty
+// that were cleared back to their zero value cannot be told apart from
+// "unset" and are left out.
+func Diff(cfg Config) (map[string]any, []string, error) {
+	cfg.normalize()
+	current, err := configTree(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	base := Default()
+	base.normalize()
+	defaults, err := configTree(base)
+	if err != nil {
+		return nil, nil, err
+	}
+	keys := []string{}
+	changed := diffTree(current, defaults, "", &keys)
+	if changed == nil {
+		changed = map[string]any{}
+	}
+	sort.Strings(keys)
+	return changed, keys, nil
+}
+
+// DiffTOML renders Diff as a config file fragment.
+func DiffTOML(cfg Config) (string, []string, error) {
+	changed, keys, err := Diff(cfg)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(changed) == 0 {
+		return "", keys, nil
+	}
+	payload, err := toml.Marshal(changed)
+	if err != nil {
+		return "", nil, fmt.Errorf("could not serialize config diff: %w", err)
+	}
+	return string(payload), keys, nil
+}
+
+// configTree round-trips cfg through TOML so the diff uses file key names.
+func configTree(cfg Config) (map[string]any, error) {
+	payload, err := marshalConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("could not serialize config: %w", err)
+	}
+	tree := map[string]any{}
+	if err := toml.Unmarshal(payload, &tree); err != nil {
+		return nil, fmt.Errorf("could not parse config: %w", err)
+	}
+	return tree, nil
+}
+
+func diffTree(current, defaults map[string]any, prefix string, keys *[]string) map[string]any {
+	var out map[string]any
+	for key, value := range current {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		base, ok := defaults[key]
+		if section, isMap := value.(map[string]any); isMap {
+			baseSection, _ := base.(map[string]any)
+			if nested := diffTree(section, baseSection, path, keys); nested != nil {
+				if out == nil {
+					out = map[string]any{}
+				}
+				out[key] = nested
+			}
+			continue
+		}
+		if ok && reflect.DeepEqual(value, base) {
+			continue
+		}
+		if out == nil {
+			out = map[string]any{}
+		}
+		out[key] = value
+		*keys = append(*keys, path)
+	}
+	return out
+}
@@ -0,0 +1,183 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// settingKeys are the keys Get and Set take that are not per provider,
+// tool, or quick command.
+var settingKeys = []string{
+	"locale",
+	"provider",
+	"provider_order",
+	"mode",
+	"ui.backend",
+	"ui.ascii_only",
+	"system.enable_context",
+	"system.auto_train",
+	"system.refresh_hours",
+	"system.max_prompt_items",
+	"system.share.tools",
+	"system.share.config_files",
+	"system.share.git_ignore",
+	"system.share.user_note",
+	"fix.model",
+	"fix.thinking",
+	"fix.min_confidence",
+	"fix.max_attempts",
+	"find.model",
+	"find.thinking",
+	"find.min_confidence",
+	"find.max_results",
+	"find.temporal_boost",
+	"find.offer_run",
+	"prompt.self_knowledge",
+	"prompt.self_knowledge_tokens",
+	"ai.min_confidence",
+	"ai.allow_suggest_execution",
+	"ai.timeout_seconds",
+	"ai.cache_ttl_seconds",
+	"ai.calibrate_confidence",
+	"ai.max_concurrent",
+	"safety.max_auto_command_length",
+	"safety.max_auto_args",
+	"safety.max_auto_paths",
+	"safety.protected_branches",
+	"safety.plan_preview",
+	"execution.target",
+	"doctor.budget_ms",
+	"doctor.check_timeout_ms",
+	"state.backend",
+	"tldr.enabled",
+	"embeddings.enabled",
+	"embeddings.api",
+	"embeddings.model",
+	"embeddings.base_url",
+	"embeddings.api_key_env",
+	"embeddings.min_similarity",
+	"runbooks.dir",
+	"feedback.enabled",
+	"tips.enabled",
+	"history.write_back",
+	"history.secrets",
+	"history.watermark",
+	"history.via_ew",
+	"journal.privacy",
+	"tools.prefer",
+}
+
+// providerFields are the providers.<name>.<field> keys.
+var providerFields = []string{
+	"type", "command", "args", "env", "enabled", "model", "thinking", "model_flag", "thinking_flag",
+	"intents", "max_risk", "api", "base_url", "api_key_env", "timeout_ms", "max_attempts", "timeout_seconds",
+}
+
+// modelFields are the providers.<name>.models.<alias>.<field> keys.
+var modelFields = []string{"provider_model", "thinking", "speed", "description"}
+
+// Keys lists every key Get accepts for cfg, sorted: the fixed settings
+// plus one set per configured provider, model alias, tool preference, tool
+// group, and quick command. Shell completion and `ew config list` read it.
+func Keys(cfg Config) []string {
+	keys := slices.Clone(settingKeys)
+	for name, provider := range cfg.Providers {
+		for _, field := range providerFields {
+			keys = append(keys, "providers."+name+"."+field)
+		}
+		for alias := range provider.Models {
+			for _, field := range modelFields {
+				keys = append(keys, "providers."+name+".models."+alias+"."+field)
+			}
+		}
+	}
+	for tool := range cfg.Tools.Prefer {
+		keys = append(keys, "tools.prefer."+tool)
+	}
+	for name := range cfg.Tools.Groups {
+		for _, field := range toolGroupFields {
+			keys = append(keys, "tools."+name+"."+field)
+		}
+	}
+	for name := range cfg.Quick {
+		keys = append(keys, "quick."+name)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Unset puts key back to its default. Tool preferences and quick commands
+// have no default and are removed; a provider key falls back to the
+// built-in provider's value, so providers ew does not ship have none.
+func (c *Config) Unset(key string) error {
+	key = strings.TrimSpace(strings.ToLower(key))
+	if strings.HasPrefix(key, "tools.prefer.") || strings.HasPrefix(key, "quick.") {
+		if _, err := c.Get(key); err != nil {
+			return err
+		}
+		return c.Set(key, "none")
+	}
+	if _, err := c.Get(key); err != nil {
+		return err
+	}
+	value, err := Default().Get(key)
+	if err != nil {
+		return fmt.Errorf("%s has no default: %w", key, err)
+	}
+	return c.Set(key, value)
+}
+
+// Validate checks a config file before it is used: the TOML must parse,
+// every key must be one ew knows, so a misspelt one is not silently
+// ignored, and every value must be one Set would accept. All problems are
+// returned together.
+func Validate(data []byte) error {
+	cfg := Default()
+	decoder := toml.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&cfg); err != nil {
+		var strict *toml.StrictMissingError
+		if !errors.As(err, &strict) {
+			return fmt.Errorf("could not parse config file: %w", err)
+		}
+		problems := make([]error, 0, len(strict.Errors))
+		for _, missing := range strict.Errors {
+			if isToolGroupPath(missing.Key()) {
+				continue
+			}
+			row, _ := missing.Position()
+			problems = append(problems, fmt.Errorf("line %d: %w: %s", row, ErrUnknownKey, strings.Join(missing.Key(), ".")))
+		}
+		if len(problems) > 0 {
+			return errors.Join(problems...)
+		}
+	}
+	groups, err := decodeToolGroups(data, nil, true)
+	if err != nil {
+		return err
+	}
+	cfg.Tools.Groups = groups
+	var problems []error
+	for _, key := range Keys(cfg) {
+		value, err := cfg.Get(key)
+		if err != nil {
+			continue
+		}
+		probe := cfg
+		probe.Providers = maps.Clone(cfg.Providers)
+		probe.Tools.Prefer = maps.Clone(cfg.Tools.Prefer)
+		probe.Tools.Groups = maps.Clone(cfg.Tools.Groups)
+		probe.Quick = maps.Clone(cfg.Quick)
+		if err := probe.Set(key, value); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	return errors.Join(problems...)
+}
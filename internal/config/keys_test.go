@@ -0,0 +1,85 @@
+package config
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+func TestKeysRoundTripThroughGetSetAndUnset(t *testing.T) {
+	cfg := Default()
+	cfg.normalize()
+	keys := Keys(cfg)
+	for _, want := range []string{"mode", "find.max_results", "providers.codex.model"} {
+		if !slices.Contains(keys, want) {
+			t.Fatalf("expected %s in Keys, got %v", want, keys)
+		}
+	}
+	for _, key := range keys {
+		value, err := cfg.Get(key)
+		if err != nil {
+			t.Fatalf("Get(%s) failed: %v", key, err)
+		}
+		changed := Default()
+		changed.normalize()
+		if err := changed.Set(key, value); err != nil {
+			t.Fatalf("Set(%s, %q) rejected its own default: %v", key, value, err)
+		}
+		if err := changed.Unset(key); err != nil {
+			t.Fatalf("Unset(%s) failed: %v", key, err)
+		}
+	}
+}
+
+func TestUnsetRestoresDefaultsAndDropsEntries(t *testing.T) {
+	cfg := Default()
+	if err := cfg.Set("find.max_results", "3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Set("quick.deploy", "make deploy {1}"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Unset("find.max_results"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := cfg.Get("find.max_results"); got != "8" {
+		t.Fatalf("expected the default back, got %q", got)
+	}
+	if err := cfg.Unset("quick.deploy"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cfg.Quick["deploy"]; ok {
+		t.Fatalf("expected the quick command removed")
+	}
+	if err := cfg.Unset("find.colour"); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("expected an unknown key error, got %v", err)
+	}
+}
+
+func TestValidateReportsUnknownKeysAndBadValues(t *testing.T) {
+	payload, err := toml.Marshal(Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(payload); err != nil {
+		t.Fatalf("expected the default config to validate, got %v", err)
+	}
+
+	err = Validate([]byte("mode = \"confirm\"\n[ui]\nbakend = \"huh\"\n"))
+	if !errors.Is(err, ErrUnknownKey) || !strings.Contains(err.Error(), "line 3") || !strings.Contains(err.Error(), "ui.bakend") {
+		t.Fatalf("expected the misspelt key with its line, got %v", err)
+	}
+
+	err = Validate([]byte("mode = \"sometimes\"\n[find]\nmax_results = 0\n"))
+	var invalid *InvalidValueError
+	if !errors.As(err, &invalid) || !strings.Contains(err.Error(), "mode") || !strings.Contains(err.Error(), "find.max_results") {
+		t.Fatalf("expected both bad values reported, got %v", err)
+	}
+
+	if err := Validate([]byte("mode = ")); err == nil || !strings.Contains(err.Error(), "could not parse") {
+		t.Fatalf("expected a parse error, got %v", err)
+	}
+}
@@ -0,0 +1,188 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// ToolGroup is what a [tools.<name>] table sets for every command that runs
+// the tool name, such as aws, kubectl, or git. Confirm asks before running
+// one even in yolo mode. Flags, such as "--profile staging", are appended
+// to a suggested command that does not set them already. Notes go into the
+// provider prompt when the request involves the tool.
+type ToolGroup struct {
+	Confirm bool   `toml:"confirm,omitempty" json:"confirm,omitempty"`
+	Flags   string `toml:"flags,omitempty" json:"flags,omitempty"`
+	Notes   string `toml:"notes,omitempty" json:"notes,omitempty"`
+}
+
+// toolGroupFields are the tools.<name>.<field> keys.
+var toolGroupFields = []string{"confirm", "flags", "notes"}
+
+// ToolGroupFor returns the group for the tool a command runs, such as
+// "kubectl" for "kubectl get pods".
+func (c Config) ToolGroupFor(tool string) (ToolGroup, bool) {
+	group, ok := c.Tools.Groups[strings.ToLower(strings.TrimSpace(tool))]
+	return group, ok
+}
+
+// toolGroupKey splits tools.<name>.<field>. tools.prefer keys are not
+// group keys.
+func toolGroupKey(key string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(key, "tools.")
+	if !ok {
+		return "", "", false
+	}
+	name, field, ok := strings.Cut(rest, ".")
+	if !ok || name == "prefer" {
+		return "", "", false
+	}
+	return name, field, true
+}
+
+func (c *Config) setToolGroupKey(name, field, value string) error {
+	key := "tools." + name + "." + field
+	if !ValidQuickName(name) {
+		return invalidValue(key, "tool name must be letters, digits, - or _")
+	}
+	group := c.Tools.Groups[name]
+	unset := value == "" || strings.EqualFold(value, "none")
+	switch field {
+	case "confirm":
+		b, err := parseBool(value)
+		if err != nil {
+			return invalidValue(key, "must be true or false")
+		}
+		group.Confirm = b
+	case "flags":
+		group.Flags = ""
+		if !unset {
+			if strings.ContainsAny(value, ";|&`$<>(){}") || !strings.HasPrefix(value, "-") {
+				return invalidValue(key, "must be flags such as --profile staging, without shell operators")
+			}
+			group.Flags = strings.Join(strings.Fields(value), " ")
+		}
+	case "notes":
+		group.Notes = ""
+		if !unset {
+			group.Notes = value
+		}
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownKey, key)
+	}
+	if group == (ToolGroup{}) {
+		delete(c.Tools.Groups, name)
+		return nil
+	}
+	if c.Tools.Groups == nil {
+		c.Tools.Groups = map[string]ToolGroup{}
+	}
+	c.Tools.Groups[name] = group
+	return nil
+}
+
+func (c Config) getToolGroupKey(name, field string) (string, error) {
+	group := c.Tools.Groups[name]
+	switch field {
+	case "confirm":
+		return strconv.FormatBool(group.Confirm), nil
+	case "flags":
+		return noneIfEmpty(group.Flags), nil
+	case "notes":
+		return noneIfEmpty(group.Notes), nil
+	}
+	return "", fmt.Errorf("%w: tools.%s.%s", ErrUnknownKey, name, field)
+}
+
+func noneIfEmpty(value string) string {
+	if value == "" {
+		return "none"
+	}
+	return value
+}
+
+// decodeToolGroups reads the [tools.<name>] tables of a config file over
+// groups. TOML has no place for them in ToolsConfig next to
+// [tools.prefer], so they are decoded on their own. strict reports keys a
+// group does not have and names that are not tools; otherwise those are
+// ignored like any unknown key.
+func decodeToolGroups(data []byte, groups map[string]ToolGroup, strict bool) (map[string]ToolGroup, error) {
+	var file struct {
+		Tools map[string]any `toml:"tools"`
+	}
+	if err := toml.Unmarshal(data, &file); err != nil {
+		return groups, err
+	}
+	var problems []error
+	for name, value := range file.Tools {
+		if name == "prefer" {
+			continue
+		}
+		table, ok := value.(map[string]any)
+		if !ok || !ValidQuickName(name) {
+			if strict {
+				problems = append(problems, fmt.Errorf("%w: tools.%s", ErrUnknownKey, name))
+			}
+			continue
+		}
+		encoded, err := toml.Marshal(table)
+		if err != nil {
+			return groups, fmt.Errorf("could not read tools.%s: %w", name, err)
+		}
+		group := groups[name]
+		decoder := toml.NewDecoder(bytes.NewReader(encoded))
+		if strict {
+			decoder.DisallowUnknownFields()
+		}
+		if err := decoder.Decode(&group); err != nil {
+			var missing *toml.StrictMissingError
+			if !errors.As(err, &missing) {
+				problems = append(problems, fmt.Errorf("tools.%s: %w", name, err))
+				continue
+			}
+			for _, item := range missing.Errors {
+				problems = append(problems, fmt.Errorf("%w: tools.%s.%s", ErrUnknownKey, name, strings.Join(item.Key(), ".")))
+			}
+			continue
+		}
+		if groups == nil {
+			groups = map[string]ToolGroup{}
+		}
+		groups[name] = group
+	}
+	return groups, errors.Join(problems...)
+}
+
+// isToolGroupPath reports whether a key path from a strict decode lies in
+// a [tools.<name>] table, which decodeToolGroups checks instead.
+func isToolGroupPath(path []string) bool {
+	return len(path) >= 2 && path[0] == "tools" && path[1] != "prefer"
+}
+
+// marshalConfig is toml.Marshal with the [tools.<name>] tables appended.
+func marshalConfig(cfg Config) ([]byte, error) {
+	payload, err := toml.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(cfg.Tools.Groups))
+	for name := range cfg.Tools.Groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		table, err := toml.Marshal(cfg.Tools.Groups[name])
+		if err != nil {
+			return nil, err
+		}
+		payload = append(payload, "\n[tools."+name+"]\n"...)
+		payload = append(payload, table...)
+	}
+	return payload, nil
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestToolGroupsRoundTripThroughSaveAndOverlay(t *testing.T) {
+	cfg := Default()
+	for key, value := range map[string]string{
+		"tools.aws.flags":       "--profile  staging",
+		"tools.aws.notes":       "prod account is read-only",
+		"tools.kubectl.confirm": "true",
+		"tools.prefer.ls":       "eza",
+	} {
+		if err := cfg.Set(key, value); err != nil {
+			t.Fatalf("Set(%s) failed: %v", key, err)
+		}
+	}
+	if got, _ := cfg.Get("tools.aws.flags"); got != "--profile staging" {
+		t.Fatalf("expected normalized flags, got %q", got)
+	}
+	payload, err := marshalConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(payload); err != nil {
+		t.Fatalf("expected the saved config to validate, got %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), ".ew.toml")
+	if err := os.WriteFile(path, payload, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	loaded := Default()
+	loaded.Tools.Groups = map[string]ToolGroup{"git": {Confirm: true}}
+	if err := ApplyOverlay(&loaded, path); err != nil {
+		t.Fatalf("ApplyOverlay failed: %v", err)
+	}
+	if group, ok := loaded.ToolGroupFor("AWS"); !ok || group.Flags != "--profile staging" || group.Notes == "" {
+		t.Fatalf("expected the aws group, got %+v %v", group, ok)
+	}
+	if !loaded.Tools.Groups["kubectl"].Confirm || !loaded.Tools.Groups["git"].Confirm || loaded.Tools.Prefer["ls"] != "eza" {
+		t.Fatalf("expected every group and preference kept, got %+v", loaded.Tools)
+	}
+
+	if err := loaded.Unset("tools.kubectl.confirm"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := loaded.Tools.Groups["kubectl"]; ok {
+		t.Fatalf("expected an empty group to be dropped")
+	}
+}
+
+func TestToolGroupsRejectBadKeysAndValues(t *testing.T) {
+	err := Validate([]byte("[tools.aws]\nconfirm = true\nflaggs = \"--profile x\"\n"))
+	if !errors.Is(err, ErrUnknownKey) || !strings.Contains(err.Error(), "tools.aws.flaggs") {
+		t.Fatalf("expected the misspelt group key, got %v", err)
+	}
+	err = Validate([]byte("[tools.aws]\nflags = \"staging; rm -rf /\"\n"))
+	var invalid *InvalidValueError
+	if !errors.As(err, &invalid) || !strings.Contains(err.Error(), "tools.aws.flags") {
+		t.Fatalf("expected bad flags reported, got %v", err)
+	}
+	cfg := Default()
+	if err := cfg.Set("tools.aws.colour", "red"); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("expected an unknown group field, got %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// InternalBinary checks the _ew helper that hook snippets written before
+// `ew internal` still call: whether it is installed, and that it comes
+// from the same build as ew. A helper left behind by an older install
+// records failures in a format ew may no longer read, which shows up as
+// "no recent failure" long after the fact.
+func InternalBinary(internalBin, version string, budget time.Duration) Check {
+	if internalBin == "" {
+		return Check{
+			ID:       "internal.binary",
+			Severity: SeverityWarn,
+			Value:    "_ew executable not found",
+			Hint:     "current hook snippets call `ew internal` and do not need it; if your rc file still calls _ew, rerun ew --setup-hooks and replace the snippet, or reinstall ew (rerun scripts/install.sh or brew reinstall ew)",
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), budget)
+	defer cancel()
+	begin := time.Now()
+	out, err := exec.CommandContext(ctx, internalBin, "version").Output()
+	took := time.Since(begin).Milliseconds()
+	helperVersion := strings.TrimSpace(string(out))
+	if err != nil || helperVersion == "" {
+		return Check{
+			ID:         "internal.binary",
+			Severity:   SeverityWarn,
+			Value:      fmt.Sprintf("%s does not report a version", internalBin),
+			Hint:       "it predates this ew; reinstall both binaries from the same release",
+			DurationMs: took,
+		}
+	}
+	if helperVersion != version {
+		return Check{
+			ID:         "internal.binary",
+			Severity:   SeverityWarn,
+			Value:      fmt.Sprintf("%s is %s but ew is %s", internalBin, helperVersion, version),
+			Hint:       "reinstall both binaries from the same release; a mismatched _ew can record failures ew does not read",
+			DurationMs: took,
+		}
+	}
+	return Check{ID: "internal.binary", Severity: SeverityOK, Value: fmt.Sprintf("%s (%s)", internalBin, helperVersion), DurationMs: took}
+}
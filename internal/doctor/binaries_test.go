@@ -0,0 +1,26 @@
+package doctor
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInternalBinaryComparesVersions(t *testing.T) {
+	bin := buildInternalBinary(t)
+
+	if check := InternalBinary(bin, "dev", 30*time.Second); check.Severity != SeverityOK {
+		t.Fatalf("expected matching versions to pass, got %+v", check)
+	}
+	check := InternalBinary(bin, "v1.2.3", 30*time.Second)
+	if check.Severity != SeverityWarn || !strings.Contains(check.Value, "dev but ew is v1.2.3") || check.Hint == "" {
+		t.Fatalf("expected a version mismatch warning, got %+v", check)
+	}
+}
+
+func TestInternalBinaryMissing(t *testing.T) {
+	check := InternalBinary("", "dev", time.Second)
+	if check.Severity != SeverityWarn || !strings.Contains(check.Hint, "ew --setup-hooks") {
+		t.Fatalf("expected missing _ew to warn with setup guidance, got %+v", check)
+	}
+}
@@ -0,0 +1,391 @@
+package doctor
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ashwch/ew/internal/appdirs"
+	"github.com/ashwch/ew/internal/config"
+	"github.com/ashwch/ew/internal/provider"
+	"github.com/ashwch/ew/internal/safety"
+)
+
+// SchemaVersion is bumped whenever a field is renamed or removed from Report
+// or Check. New check IDs do not change it.
+const SchemaVersion = 1
+
+type Severity string
+
+const (
+	SeverityOK    Severity = "ok"
+	SeverityWarn  Severity = "warn"
+	SeverityError Severity = "error"
+)
+
+// Check is one diagnostic. ID is stable across releases so scripts can
+// assert on it; Hint says how to fix anything that is not ok.
+type Check struct {
+	ID         string   `json:"id"`
+	Severity   Severity `json:"severity"`
+	Value      string   `json:"value"`
+	Hint       string   `json:"hint,omitempty"`
+	DurationMs int64    `json:"duration_ms"`
+	// Slow is set when the check passed but warns only because it took
+	// more than half its timeout.
+	Slow bool `json:"slow,omitempty"`
+}
+
+type Summary struct {
+	OK    int `json:"ok"`
+	Warn  int `json:"warn"`
+	Error int `json:"error"`
+}
+
+// Report is the `ew --doctor --json` payload.
+type Report struct {
+	SchemaVersion int      `json:"schema_version"`
+	Status        Severity `json:"status"`
+	Summary       Summary  `json:"summary"`
+	Checks        []Check  `json:"checks"`
+}
+
+// probe is one check that may block on the filesystem or PATH lookups.
+// timeout is how long it may take, or doctor.check_timeout_ms when zero.
+type probe struct {
+	id      string
+	run     func() Check
+	timeout time.Duration
+}
+
+// Run checks the local install. cfgErr is the error from loading the config,
+// if any; cfg is ignored when it is set. Checks run concurrently, each
+// within cfg.Doctor.CheckTimeoutMs, and the whole run returns within
+// cfg.Doctor.BudgetMs.
+func Run(cfg config.Config, cfgErr error) Report {
+	defaults := config.Default().Doctor
+	budget := time.Duration(defaults.BudgetMs) * time.Millisecond
+	checkTimeout := time.Duration(defaults.CheckTimeoutMs) * time.Millisecond
+	if cfgErr == nil && cfg.Doctor.BudgetMs > 0 {
+		budget = time.Duration(cfg.Doctor.BudgetMs) * time.Millisecond
+	}
+	if cfgErr == nil && cfg.Doctor.CheckTimeoutMs > 0 {
+		checkTimeout = time.Duration(cfg.Doctor.CheckTimeoutMs) * time.Millisecond
+	}
+	probes := []probe{
+		{id: "os", run: func() Check {
+			return Check{ID: "os", Severity: SeverityOK, Value: runtime.GOOS + "/" + runtime.GOARCH}
+		}},
+		{id: "config.path", run: configPathCheck},
+		{id: "state.dir", run: stateDirCheck},
+		{id: "config.writable", run: configWritableCheck},
+		{id: "state.writable", run: stateWritableCheck},
+		{id: "safety.policy", run: safetyPolicyCheck},
+	}
+	if cfgErr != nil {
+		probes = append(probes, probe{id: "config.load", run: func() Check {
+			return Check{
+				ID:       "config.load",
+				Severity: SeverityError,
+				Value:    cfgErr.Error(),
+				Hint:     "fix or remove the config file; `ew --show-config` prints its path",
+			}
+		}})
+		return NewReport(runProbes(probes, budget, checkTimeout))
+	}
+	probes = append(probes, probe{id: "config.load", run: func() Check {
+		return Check{ID: "config.load", Severity: SeverityOK, Value: "loaded"}
+	}})
+	probes = append(probes, providerProbes(cfg)...)
+	checks := runProbes(probes, budget, checkTimeout)
+	checks = append(checks, providersAvailable(checks))
+	return NewReport(append(checks, providerEnvChecks(cfg, os.Environ())...))
+}
+
+// runProbes runs every probe concurrently and returns their checks in probe
+// order. A probe still running when its timeout (checkTimeout unless it has
+// its own, and never past budget) elapses is reported as a timeout warning
+// and abandoned; its goroutine finishes in the background. Probes that
+// finish but take more than half their timeout are downgraded to warn so a
+// slow PATH entry shows up before it slows every ew call.
+func runProbes(probes []probe, budget, checkTimeout time.Duration) []Check {
+	type result struct {
+		index int
+		check Check
+	}
+	started := time.Now()
+	results := make(chan result, len(probes))
+	timeouts := make([]time.Duration, len(probes))
+	for idx, p := range probes {
+		timeouts[idx] = min(cmp.Or(p.timeout, checkTimeout), budget)
+		go func(idx int, p probe) {
+			begin := time.Now()
+			check := p.run()
+			check.DurationMs = time.Since(begin).Milliseconds()
+			results <- result{index: idx, check: check}
+		}(idx, p)
+	}
+
+	checks := make([]Check, len(probes))
+	done := make([]bool, len(probes))
+	for pending := len(probes); pending > 0; {
+		// Wake up when the next unfinished probe runs out of time.
+		next := budget
+		for idx := range probes {
+			if !done[idx] {
+				next = min(next, timeouts[idx])
+			}
+		}
+		timer := time.NewTimer(next - time.Since(started))
+		select {
+		case r := <-results:
+			timer.Stop()
+			if done[r.index] {
+				continue
+			}
+			checks[r.index] = markSlow(r.check, timeouts[r.index])
+			done[r.index] = true
+			pending--
+		case <-timer.C:
+			elapsed := time.Since(started)
+			for idx, p := range probes {
+				if done[idx] || timeouts[idx] > elapsed {
+					continue
+				}
+				hint := "look for network-mounted or unreachable PATH entries, or raise doctor.check_timeout_ms"
+				if timeouts[idx] == budget {
+					hint = "look for network-mounted or unreachable PATH entries, or raise doctor.budget_ms"
+				}
+				checks[idx] = Check{
+					ID:         p.id,
+					Severity:   SeverityWarn,
+					Value:      fmt.Sprintf("timed out after %s", elapsed.Round(time.Millisecond)),
+					Hint:       hint,
+					DurationMs: elapsed.Milliseconds(),
+				}
+				done[idx] = true
+				pending--
+			}
+		}
+	}
+	return checks
+}
+
+func markSlow(check Check, timeout time.Duration) Check {
+	took := time.Duration(check.DurationMs) * time.Millisecond
+	if took <= timeout/2 {
+		return check
+	}
+	check.Value = fmt.Sprintf("%s (slow: %s)", check.Value, took.Round(time.Millisecond))
+	if check.Severity == SeverityOK {
+		check.Slow = true
+		check.Severity = SeverityWarn
+		check.Hint = "this check is slow; a network-mounted PATH entry or slow disk is the usual cause"
+	}
+	return check
+}
+
+// NewReport derives the summary and overall status from checks.
+func NewReport(checks []Check) Report {
+	report := Report{SchemaVersion: SchemaVersion, Status: SeverityOK, Checks: checks}
+	for _, check := range checks {
+		switch check.Severity {
+		case SeverityError:
+			report.Summary.Error++
+		case SeverityWarn:
+			report.Summary.Warn++
+		default:
+			report.Summary.OK++
+		}
+	}
+	switch {
+	case report.Summary.Error > 0:
+		report.Status = SeverityError
+	case report.Summary.Warn > 0:
+		report.Status = SeverityWarn
+	}
+	return report
+}
+
+// ExitCode is 1 when any check failed with an error, 0 otherwise; warnings
+// do not fail provisioning scripts.
+func (r Report) ExitCode() int {
+	if r.Status == SeverityError {
+		return 1
+	}
+	return 0
+}
+
+func configPathCheck() Check {
+	cfgPath, err := appdirs.ConfigFilePath()
+	if err != nil {
+		return Check{ID: "config.path", Severity: SeverityError, Value: err.Error(), Hint: "set HOME or XDG_CONFIG_HOME"}
+	}
+	return pathCheck("config.path", cfgPath, "created on first run, or save a setting with `ew --mode confirm --save`")
+}
+
+func stateDirCheck() Check {
+	statePath, err := appdirs.StateDir()
+	if err != nil {
+		return Check{ID: "state.dir", Severity: SeverityError, Value: err.Error(), Hint: "set HOME or XDG_STATE_HOME"}
+	}
+	return pathCheck("state.dir", statePath, "created on first use; run any ew command or install the shell hooks")
+}
+
+// configWritableCheck and stateWritableCheck write a temp file where ew
+// saves things and report the exact path and reason when that fails, the
+// same check that puts a run into its in-memory degraded mode.
+func configWritableCheck() Check {
+	cfgPath, err := appdirs.ConfigFilePath()
+	if err != nil {
+		return Check{ID: "config.writable", Severity: SeverityError, Value: err.Error(), Hint: "set HOME or XDG_CONFIG_HOME"}
+	}
+	return writableCheck("config.writable", filepath.Dir(cfgPath), "--save and first-run config creation fail until the path is writable; point XDG_CONFIG_HOME elsewhere or free space")
+}
+
+func stateWritableCheck() Check {
+	dir, err := appdirs.StateDir()
+	if err != nil {
+		return Check{ID: "state.writable", Severity: SeverityError, Value: err.Error(), Hint: "set HOME or XDG_STATE_HOME"}
+	}
+	return writableCheck("state.writable", dir, "memory, sessions, and undo are kept for one run only; point XDG_STATE_HOME elsewhere or free space")
+}
+
+func writableCheck(id, dir, hint string) Check {
+	if err := appdirs.CheckWritable(dir); err != nil {
+		return Check{ID: id, Severity: SeverityWarn, Value: err.Error(), Hint: hint}
+	}
+	return Check{ID: id, Severity: SeverityOK, Value: "yes"}
+}
+
+// safetyPolicyCheck reports the rules in safety.toml. The file is optional,
+// so only a policy that does not load is a problem.
+func safetyPolicyCheck() Check {
+	policy, err := safety.LoadPolicy()
+	if err != nil {
+		return Check{ID: "safety.policy", Severity: SeverityError, Value: err.Error(), Hint: "fix " + safety.PolicyFileName + "; until then every command asks before it runs"}
+	}
+	if _, statErr := os.Stat(policy.Path); statErr != nil {
+		return Check{ID: "safety.policy", Severity: SeverityOK, Value: "none (built-in rules only)"}
+	}
+	return Check{ID: "safety.policy", Severity: SeverityOK, Value: fmt.Sprintf("%s (%d rules)", policy.Path, len(policy.Rules))}
+}
+
+func pathCheck(id, path, missingHint string) Check {
+	_, err := os.Stat(path)
+	switch {
+	case err == nil:
+		return Check{ID: id, Severity: SeverityOK, Value: path}
+	case os.IsNotExist(err):
+		return Check{ID: id, Severity: SeverityWarn, Value: path + " (missing)", Hint: missingHint}
+	default:
+		return Check{ID: id, Severity: SeverityError, Value: fmt.Sprintf("%s (%v)", path, err), Hint: "check permissions on the path"}
+	}
+}
+
+// providerProbes checks each configured provider separately so one CLI on a
+// hung PATH entry does not hold up the others.
+func providerProbes(cfg config.Config) []probe {
+	registry := provider.NewRegistry()
+	names := cfg.ProviderNames()
+	sort.Strings(names)
+
+	probes := make([]probe, 0, len(names))
+	for _, name := range names {
+		name := name
+		providerCfg := cfg.Providers[name]
+		id := providerCheckPrefix + name
+		probes = append(probes, probe{id: id, run: func() Check {
+			value := fmt.Sprintf("type=%s command=%s model=%s", providerCfg.Type, providerCfg.Command, providerCfg.Model)
+			hint := fmt.Sprintf("install %s or set enabled = false under [providers.%s]", commandName(providerCfg, name), name)
+			if providerCfg.Type == "http" {
+				value = fmt.Sprintf("type=http api=%s model=%s", providerCfg.API, providerCfg.Model)
+				hint = fmt.Sprintf("export the API key or set enabled = false under [providers.%s]", name)
+			}
+			if providerCfg.Enabled != nil && !*providerCfg.Enabled {
+				return Check{ID: id, Severity: SeverityOK, Value: value + disabledSuffix}
+			}
+			adapter, err := registry.Build(name, providerCfg)
+			if err != nil {
+				return Check{ID: id, Severity: SeverityError, Value: err.Error(), Hint: fmt.Sprintf("fix [providers.%s] in the config or disable it", name)}
+			}
+			if checker, ok := adapter.(provider.HealthChecker); ok {
+				if err := checker.HealthCheck(); err != nil {
+					return Check{ID: id, Severity: SeverityWarn, Value: err.Error(), Hint: hint}
+				}
+			}
+			return Check{ID: id, Severity: SeverityOK, Value: value}
+		}})
+	}
+	return probes
+}
+
+const (
+	providerCheckPrefix    = "provider."
+	providerEnvCheckPrefix = "provider_env."
+	disabledSuffix         = " (disabled)"
+)
+
+// providersAvailable counts the enabled providers whose check came back
+// ok, or warned only for being slow; a provider that timed out or failed
+// its health check does not count.
+func providersAvailable(checks []Check) Check {
+	usable := 0
+	for _, check := range checks {
+		passed := check.Severity == SeverityOK || check.Severity == SeverityWarn && check.Slow
+		if strings.HasPrefix(check.ID, providerCheckPrefix) && passed && !strings.Contains(check.Value, disabledSuffix) {
+			usable++
+		}
+	}
+	available := Check{ID: "providers.available", Severity: SeverityOK, Value: fmt.Sprintf("%d usable", usable)}
+	if usable == 0 {
+		available.Severity = SeverityError
+		available.Hint = "install the codex or claude CLI; history search still works with --offline"
+	}
+	return available
+}
+
+// providerEnvChecks reports, for each enabled command provider, which
+// credentials in the environment its CLI would see. A provider whose env
+// list passes the whole environment warns when that includes anything that
+// looks like a secret.
+func providerEnvChecks(cfg config.Config, environ []string) []Check {
+	names := cfg.ProviderNames()
+	sort.Strings(names)
+
+	var checks []Check
+	for _, name := range names {
+		providerCfg := cfg.Providers[name]
+		if providerCfg.Type != "command" || (providerCfg.Enabled != nil && !*providerCfg.Enabled) {
+			continue
+		}
+		id := providerEnvCheckPrefix + name
+		if exposed := provider.ExposedSecrets(providerCfg, environ); provider.InheritsEnv(providerCfg) && len(exposed) > 0 {
+			checks = append(checks, Check{
+				ID:       id,
+				Severity: SeverityWarn,
+				Value:    "passes ew's whole environment, including " + strings.Join(exposed, ", "),
+				Hint:     fmt.Sprintf("replace \"*\" in providers.%s.env with the variables %s needs", name, commandName(providerCfg, name)),
+			})
+			continue
+		}
+		value := fmt.Sprintf("passes %d of %d variables", len(provider.SandboxEnv(providerCfg, environ)), len(environ))
+		if withheld := provider.WithheldSecrets(providerCfg, environ); len(withheld) > 0 {
+			value += "; withholds " + strings.Join(withheld, ", ")
+		}
+		checks = append(checks, Check{ID: id, Severity: SeverityOK, Value: value})
+	}
+	return checks
+}
+
+func commandName(cfg config.ProviderConfig, fallback string) string {
+	if cfg.Command == "" {
+		return fallback
+	}
+	return cfg.Command
+}
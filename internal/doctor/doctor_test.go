@@ -0,0 +1,198 @@
+package doctor
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ashwch/ew/internal/config"
+)
+
+func isolateDirs(t *testing.T) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, ".local", "state"))
+}
+
+func findCheck(report Report, id string) (Check, bool) {
+	for _, check := range report.Checks {
+		if check.ID == id {
+			return check, true
+		}
+	}
+	return Check{}, false
+}
+
+func TestNewReportDerivesStatusAndExitCode(t *testing.T) {
+	report := NewReport([]Check{{ID: "a", Severity: SeverityOK}, {ID: "b", Severity: SeverityWarn}})
+	if report.Status != SeverityWarn || report.ExitCode() != 0 {
+		t.Fatalf("expected warn with exit 0, got %s/%d", report.Status, report.ExitCode())
+	}
+	report = NewReport([]Check{{ID: "a", Severity: SeverityWarn}, {ID: "b", Severity: SeverityError}})
+	if report.Status != SeverityError || report.ExitCode() != 1 {
+		t.Fatalf("expected error with exit 1, got %s/%d", report.Status, report.ExitCode())
+	}
+	if report.Summary != (Summary{Warn: 1, Error: 1}) || report.SchemaVersion != SchemaVersion {
+		t.Fatalf("unexpected summary %+v", report)
+	}
+}
+
+func TestRunReportsConfigLoadFailure(t *testing.T) {
+	isolateDirs(t)
+	report := Run(config.Config{}, errors.New("could not parse config"))
+	check, ok := findCheck(report, "config.load")
+	if !ok || check.Severity != SeverityError || check.Hint == "" {
+		t.Fatalf("expected config.load error with hint, got %+v", check)
+	}
+	if report.ExitCode() != 1 {
+		t.Fatalf("expected exit code 1")
+	}
+	if _, ok := findCheck(report, "providers.available"); ok {
+		t.Fatalf("expected provider checks to be skipped without a config")
+	}
+}
+
+func TestRunReportsUnwritableStateDir(t *testing.T) {
+	isolateDirs(t)
+	blocker := filepath.Join(os.Getenv("HOME"), ".local")
+	if err := os.WriteFile(blocker, []byte("not a dir"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	report := Run(config.Default(), nil)
+	check, ok := findCheck(report, "state.writable")
+	if !ok || check.Severity != SeverityWarn || check.Hint == "" {
+		t.Fatalf("expected state.writable warning with hint, got %+v", check)
+	}
+	if !strings.Contains(check.Value, blocker) || !strings.Contains(check.Value, "not a directory") {
+		t.Fatalf("expected the failing path and reason, got %q", check.Value)
+	}
+	if check, _ := findCheck(report, "config.writable"); check.Severity != SeverityOK {
+		t.Fatalf("expected config.writable ok, got %+v", check)
+	}
+}
+
+func TestRunFlagsMissingProviderCommands(t *testing.T) {
+	isolateDirs(t)
+	t.Setenv("PATH", t.TempDir())
+	disabled := false
+	cfg := config.Config{Providers: map[string]config.ProviderConfig{
+		"missing": {Type: "command", Command: "ew-no-such-binary"},
+		"off":     {Type: "command", Command: "ew-no-such-binary", Enabled: &disabled},
+		"broken":  {Type: "nonsense"},
+	}}
+	report := Run(cfg, nil)
+
+	if check, _ := findCheck(report, "provider.missing"); check.Severity != SeverityWarn || check.Hint == "" {
+		t.Fatalf("expected missing command to warn with a hint, got %+v", check)
+	}
+	if check, _ := findCheck(report, "provider.off"); check.Severity != SeverityOK {
+		t.Fatalf("expected disabled provider to be ok, got %+v", check)
+	}
+	if check, _ := findCheck(report, "provider.broken"); check.Severity != SeverityError {
+		t.Fatalf("expected invalid provider to be an error, got %+v", check)
+	}
+	if check, _ := findCheck(report, "providers.available"); check.Severity != SeverityError {
+		t.Fatalf("expected no usable providers to be an error, got %+v", check)
+	}
+	if check, _ := findCheck(report, "state.dir"); check.Severity != SeverityWarn {
+		t.Fatalf("expected missing state dir to warn, got %+v", check)
+	}
+}
+
+func TestRunProbesReportsTimeoutsAndSlowChecks(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	probes := []probe{
+		{id: "fast", run: func() Check { return Check{ID: "fast", Severity: SeverityOK, Value: "fine"} }},
+		{id: "slow", run: func() Check {
+			time.Sleep(60 * time.Millisecond)
+			return Check{ID: "slow", Severity: SeverityOK, Value: "fine"}
+		}},
+		{id: "hung", run: func() Check {
+			<-release
+			return Check{ID: "hung", Severity: SeverityOK}
+		}},
+	}
+
+	started := time.Now()
+	checks := runProbes(probes, 100*time.Millisecond, 100*time.Millisecond)
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Fatalf("expected runProbes to respect the budget, took %s", elapsed)
+	}
+	if len(checks) != 3 || checks[0].ID != "fast" || checks[1].ID != "slow" || checks[2].ID != "hung" {
+		t.Fatalf("expected checks in probe order, got %+v", checks)
+	}
+	if checks[0].Severity != SeverityOK {
+		t.Fatalf("expected fast check to stay ok, got %+v", checks[0])
+	}
+	if checks[1].Severity != SeverityWarn || !checks[1].Slow || !strings.Contains(checks[1].Value, "slow") {
+		t.Fatalf("expected slow check to warn, got %+v", checks[1])
+	}
+	if checks[2].Severity != SeverityWarn || !strings.Contains(checks[2].Value, "timed out") || checks[2].Hint == "" {
+		t.Fatalf("expected hung check to time out, got %+v", checks[2])
+	}
+}
+
+func TestRunProbesTimesOutEachCheckOnItsOwn(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	probes := []probe{
+		{id: "patient", timeout: 200 * time.Millisecond, run: func() Check {
+			time.Sleep(40 * time.Millisecond)
+			return Check{ID: "patient", Severity: SeverityOK, Value: "fine"}
+		}},
+		{id: "hung", run: func() Check {
+			<-release
+			return Check{ID: "hung", Severity: SeverityOK}
+		}},
+	}
+
+	checks := runProbes(probes, time.Second, 20*time.Millisecond)
+	if checks[0].Severity != SeverityOK {
+		t.Fatalf("expected a check with its own longer timeout to pass, got %+v", checks[0])
+	}
+	if checks[1].Severity != SeverityWarn || !strings.Contains(checks[1].Value, "timed out") || !strings.Contains(checks[1].Hint, "doctor.check_timeout_ms") {
+		t.Fatalf("expected the hung check to time out on the check timeout, got %+v", checks[1])
+	}
+	if checks[1].DurationMs >= 200 {
+		t.Fatalf("expected the hung check abandoned before the budget, took %dms", checks[1].DurationMs)
+	}
+}
+
+func TestProvidersAvailableCountsSlowProviders(t *testing.T) {
+	checks := []Check{
+		{ID: "provider.codex", Severity: SeverityWarn, Value: "type=command (slow: 900ms)", Slow: true},
+		{ID: "provider.claude", Severity: SeverityWarn, Value: "command not found in PATH: claude"},
+		{ID: "provider.gemini", Severity: SeverityWarn, Value: "timed out after 1.5s"},
+		{ID: "provider.ew", Severity: SeverityOK, Value: "type=builtin" + disabledSuffix},
+	}
+	if check := providersAvailable(checks); check.Severity != SeverityOK || check.Value != "1 usable" {
+		t.Fatalf("expected only the slow provider to count, got %+v", check)
+	}
+}
+
+func TestProviderEnvChecksWarnWhenSecretsLeak(t *testing.T) {
+	environ := []string{"PATH=/usr/bin", "ANTHROPIC_API_KEY=sk-ant", "GITHUB_TOKEN=ghp-secret"}
+	cfg := config.Config{Providers: map[string]config.ProviderConfig{
+		"claude": {Type: "command", Command: "claude", Env: []string{"ANTHROPIC_*"}},
+		"custom": {Type: "command", Command: "custom", Env: []string{"*"}},
+		"remote": {Type: "http", APIKeyEnv: "OPENAI_API_KEY"},
+	}}
+	report := NewReport(providerEnvChecks(cfg, environ))
+
+	if check, _ := findCheck(report, "provider_env.claude"); check.Severity != SeverityOK || !strings.Contains(check.Value, "withholds GITHUB_TOKEN") {
+		t.Fatalf("expected the sandboxed provider to be ok and name what it withholds, got %+v", check)
+	}
+	check, _ := findCheck(report, "provider_env.custom")
+	if check.Severity != SeverityWarn || !strings.Contains(check.Value, "ANTHROPIC_API_KEY, GITHUB_TOKEN") || !strings.Contains(check.Hint, "providers.custom.env") {
+		t.Fatalf("expected \"*\" to warn about leaked secrets, got %+v", check)
+	}
+	if _, ok := findCheck(report, "provider_env.remote"); ok {
+		t.Fatalf("expected http providers to be skipped")
+	}
+}
@@ -0,0 +1,155 @@
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ashwch/ew/internal/hook"
+)
+
+// probeCommand is recorded as the failing command. It has to get past the
+// hook's own-command filter, so it must not start with ew or _ew.
+const probeCommand = "ew-doctor-probe --missing-flag"
+
+// HookProbe checks the shell hook round trip end to end the way a real
+// shell would drive it: it records a failing command with `hook-record`
+// into a throwaway state directory and session, reads it back with
+// `latest-failure`, and checks that ew would treat it as fresh. helper is
+// the command the hooks call, normally `ew internal`. sessionID is the
+// caller's EW_SESSION_ID, checked for the mistakes that make ew ignore
+// every captured failure.
+func HookProbe(helper []string, shell string, sessionID string, budget time.Duration) []Check {
+	checks := []Check{sessionEnvCheck(sessionID)}
+	if len(helper) == 0 {
+		return append(checks, Check{
+			ID:       "probe.hook_record",
+			Severity: SeverityError,
+			Value:    "could not find the ew executable",
+			Hint:     "the shell hooks call `ew internal` after every failed command; make sure ew is on PATH",
+		})
+	}
+
+	stateDir, err := os.MkdirTemp("", "ew-doctor-probe-*")
+	if err != nil {
+		return append(checks, Check{ID: "probe.hook_record", Severity: SeverityError, Value: fmt.Sprintf("could not create probe state dir: %v", err)})
+	}
+	defer os.RemoveAll(stateDir)
+
+	ctx, cancel := context.WithTimeout(context.Background(), budget)
+	defer cancel()
+	run := func(args ...string) (string, time.Duration, error) {
+		begin := time.Now()
+		cmd := exec.CommandContext(ctx, helper[0], slices.Concat(helper[1:], args)...)
+		cmd.Env = append(os.Environ(), "XDG_STATE_HOME="+stateDir, "LOCALAPPDATA="+stateDir)
+		out, err := cmd.CombinedOutput()
+		if ctx.Err() != nil {
+			err = fmt.Errorf("timed out after %s", budget)
+		}
+		return strings.TrimSpace(string(out)), time.Since(begin), err
+	}
+
+	// Same shape as the hook snippets: "<pid>.<unix seconds>".
+	probeSession := fmt.Sprintf("%d.%d", os.Getpid(), time.Now().Unix())
+	if shell == "" {
+		shell = "sh"
+	}
+	out, took, err := run("hook-record", "--command", probeCommand, "--exit-code", "127", "--cwd", stateDir, "--shell", shell, "--session-id", probeSession)
+	if err != nil {
+		return append(checks, Check{
+			ID:         "probe.hook_record",
+			Severity:   SeverityError,
+			Value:      probeFailure(err, out),
+			Hint:       "the hook cannot record failures; check that the state directory is writable",
+			DurationMs: took.Milliseconds(),
+		})
+	}
+	checks = append(checks, Check{ID: "probe.hook_record", Severity: SeverityOK, Value: "recorded in session " + probeSession, DurationMs: took.Milliseconds()})
+
+	out, took, err = run("latest-failure", "--session-id", probeSession)
+	ev, parseErr := parseProbeEvent(out)
+	switch {
+	case err != nil:
+		return append(checks, Check{ID: "probe.latest_failure", Severity: SeverityError, Value: probeFailure(err, out), DurationMs: took.Milliseconds()})
+	case parseErr != nil || ev == nil || ev.Command != probeCommand || ev.SessionID != probeSession:
+		return append(checks, Check{
+			ID:         "probe.latest_failure",
+			Severity:   SeverityError,
+			Value:      fmt.Sprintf("recorded failure not returned for its session (got %q)", out),
+			Hint:       "ew and _ew are probably different versions; reinstall both from the same release",
+			DurationMs: took.Milliseconds(),
+		})
+	}
+	checks = append(checks, Check{ID: "probe.latest_failure", Severity: SeverityOK, Value: "returned for session " + probeSession, DurationMs: took.Milliseconds()})
+
+	// A failure must not leak into other sessions, or `ew` in a new tab
+	// "fixes" a command typed somewhere else.
+	out, took, err = run("latest-failure", "--session-id", probeSession+"-other")
+	if other, _ := parseProbeEvent(out); err == nil && other != nil && other.Command == probeCommand {
+		checks = append(checks, Check{
+			ID:         "probe.session_isolation",
+			Severity:   SeverityError,
+			Value:      "failure from one session was returned for another",
+			Hint:       "reinstall ew and _ew from the same release",
+			DurationMs: took.Milliseconds(),
+		})
+	} else {
+		checks = append(checks, Check{ID: "probe.session_isolation", Severity: SeverityOK, Value: "other sessions see nothing", DurationMs: took.Milliseconds()})
+	}
+
+	if stale, detail := hook.StaleFailure(ev, time.Now().UTC()); stale {
+		checks = append(checks, Check{
+			ID:       "probe.fresh",
+			Severity: SeverityError,
+			Value:    "a just-recorded failure is treated as stale: " + detail,
+			Hint:     "check the system clock and timezone; ew ignores failures older than " + hook.MaxFailureAge.String(),
+		})
+	} else {
+		checks = append(checks, Check{ID: "probe.fresh", Severity: SeverityOK, Value: "ew would fix it"})
+	}
+	return checks
+}
+
+func sessionEnvCheck(sessionID string) Check {
+	sessionID = strings.TrimSpace(sessionID)
+	switch {
+	case sessionID == "":
+		return Check{
+			ID:       "probe.session_env",
+			Severity: SeverityWarn,
+			Value:    "EW_SESSION_ID is not set in this shell",
+			Hint:     "the shell hooks are not loaded here; add `eval \"$(ew --setup-hooks)\"` to your shell rc",
+		}
+	case hook.IsSyntheticSessionID(sessionID):
+		return Check{
+			ID:       "probe.session_env",
+			Severity: SeverityError,
+			Value:    "EW_SESSION_ID=" + sessionID + " is reserved for provider tests",
+			Hint:     "failures from this session are never offered; unset EW_SESSION_ID and open a new shell",
+		}
+	}
+	return Check{ID: "probe.session_env", Severity: SeverityOK, Value: "EW_SESSION_ID=" + sessionID}
+}
+
+func parseProbeEvent(out string) (*hook.Event, error) {
+	var ev hook.Event
+	if err := json.Unmarshal([]byte(out), &ev); err != nil {
+		return nil, err
+	}
+	if ev.Command == "" {
+		return nil, nil
+	}
+	return &ev, nil
+}
+
+func probeFailure(err error, out string) string {
+	if out == "" {
+		return err.Error()
+	}
+	return fmt.Sprintf("%v: %s", err, out)
+}
@@ -0,0 +1,54 @@
+package doctor
+
+import (
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func buildInternalBinary(t *testing.T) string {
+	t.Helper()
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not on PATH")
+	}
+	name := "_ew"
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	bin := filepath.Join(t.TempDir(), name)
+	if out, err := exec.Command(goBin, "build", "-o", bin, "github.com/ashwch/ew/cmd/_ew").CombinedOutput(); err != nil {
+		t.Fatalf("build _ew: %v\n%s", err, out)
+	}
+	return bin
+}
+
+func TestHookProbeRoundTripsThroughInternalBinary(t *testing.T) {
+	bin := buildInternalBinary(t)
+	isolateDirs(t)
+
+	checks := HookProbe([]string{bin}, "bash", "4242.1700000000", 30*time.Second)
+	for _, id := range []string{"probe.session_env", "probe.hook_record", "probe.latest_failure", "probe.session_isolation", "probe.fresh"} {
+		check, ok := findCheck(NewReport(checks), id)
+		if !ok || check.Severity != SeverityOK {
+			t.Fatalf("expected %s to pass, got %+v (all: %+v)", id, check, checks)
+		}
+	}
+}
+
+func TestHookProbeFlagsMissingBinaryAndBadSession(t *testing.T) {
+	checks := HookProbe(nil, "bash", "ew-test-session", time.Second)
+	report := NewReport(checks)
+	if check, _ := findCheck(report, "probe.session_env"); check.Severity != SeverityError {
+		t.Fatalf("expected synthetic session id to be an error, got %+v", check)
+	}
+	if check, _ := findCheck(report, "probe.hook_record"); check.Severity != SeverityError || check.Hint == "" {
+		t.Fatalf("expected a missing helper to be an error with a hint, got %+v", check)
+	}
+
+	if check := sessionEnvCheck(""); check.Severity != SeverityWarn {
+		t.Fatalf("expected unset session id to warn, got %+v", check)
+	}
+}
@@ -0,0 +1,168 @@
+// Package embedding turns text into vectors with a local Ollama model or an
+// OpenAI-compatible embeddings API, so find can rank memory and history by
+// meaning as well as by shared words. Vectors are kept in the state
+// directory under a hash of the model and text, so each text is embedded
+// once.
+package embedding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ashwch/ew/internal/config"
+)
+
+const (
+	// requestTimeout bounds each embeddings call; find does not wait
+	// longer than this for semantic ranking.
+	requestTimeout = 3 * time.Second
+	// maxBatch is how many texts go in one request.
+	maxBatch          = 64
+	maxResponseBytes  = 32 << 20
+	defaultOllamaURL  = "http://localhost:11434"
+	defaultOpenAIURL  = "https://api.openai.com/v1"
+	defaultOllamaName = "nomic-embed-text"
+	defaultOpenAIName = "text-embedding-3-small"
+)
+
+// Client embeds text with one model.
+type Client struct {
+	api      string
+	endpoint string
+	model    string
+	keyEnv   string
+	client   *http.Client
+}
+
+// New builds a client for cfg, filling in the API's default endpoint,
+// model, and key variable.
+func New(cfg config.EmbeddingsConfig) (*Client, error) {
+	api := strings.ToLower(strings.TrimSpace(cfg.API))
+	baseURL, model, keyEnv, path := defaultOllamaURL, defaultOllamaName, "", "/api/embed"
+	switch api {
+	case "", "ollama":
+		api = "ollama"
+	case "openai":
+		baseURL, model, keyEnv, path = defaultOpenAIURL, defaultOpenAIName, "OPENAI_API_KEY", "/embeddings"
+	default:
+		return nil, fmt.Errorf("unsupported embeddings api %q (want ollama or openai)", cfg.API)
+	}
+	if value := strings.TrimSpace(cfg.BaseURL); value != "" {
+		baseURL = value
+	}
+	parsed, err := url.Parse(baseURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid embeddings base_url %q: want an http(s) URL", baseURL)
+	}
+	if value := strings.TrimSpace(cfg.Model); value != "" {
+		model = value
+	}
+	if value := strings.TrimSpace(cfg.APIKeyEnv); value != "" {
+		keyEnv = value
+	}
+	return &Client{
+		api:      api,
+		endpoint: strings.TrimRight(baseURL, "/") + path,
+		model:    model,
+		keyEnv:   keyEnv,
+		client:   &http.Client{Timeout: requestTimeout},
+	}, nil
+}
+
+// Model is the model vectors come from; vectors of different models are
+// never compared.
+func (c *Client) Model() string {
+	return c.api + ":" + c.model
+}
+
+// Embed returns one vector per text, in order.
+func (c *Client) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += maxBatch {
+		batch, err := c.embedBatch(ctx, texts[start:min(start+maxBatch, len(texts))])
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+func (c *Client) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(map[string]any{"model": c.model, "input": texts})
+	if err != nil {
+		return nil, fmt.Errorf("could not encode embeddings request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("could not build embeddings request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.keyEnv != "" {
+		key := strings.TrimSpace(os.Getenv(c.keyEnv))
+		if key == "" {
+			return nil, fmt.Errorf("api key not set: export %s", c.keyEnv)
+		}
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embeddings request failed (%s): %w", c.endpoint, err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return nil, fmt.Errorf("could not read embeddings response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("embeddings request failed (%s): HTTP %d: %s", c.endpoint, resp.StatusCode, strings.TrimSpace(string(raw[:min(len(raw), 300)])))
+	}
+	vectors, err := c.decode(raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("embeddings response has %d vectors for %d texts", len(vectors), len(texts))
+	}
+	return vectors, nil
+}
+
+// decode reads Ollama's {"embeddings": [...]} or OpenAI's
+// {"data": [{"index": 0, "embedding": [...]}]}.
+func (c *Client) decode(raw []byte) ([][]float32, error) {
+	if c.api == "ollama" {
+		var response struct {
+			Embeddings [][]float32 `json:"embeddings"`
+		}
+		if err := json.Unmarshal(raw, &response); err != nil {
+			return nil, fmt.Errorf("could not parse embeddings response: %w", err)
+		}
+		return response.Embeddings, nil
+	}
+	var response struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(raw, &response); err != nil {
+		return nil, fmt.Errorf("could not parse embeddings response: %w", err)
+	}
+	vectors := make([][]float32, len(response.Data))
+	for _, item := range response.Data {
+		if item.Index < 0 || item.Index >= len(vectors) {
+			return nil, fmt.Errorf("embeddings response has an out-of-range index %d", item.Index)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+	return vectors, nil
+}
@@ -0,0 +1,118 @@
+package embedding
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/ashwch/ew/internal/config"
+)
+
+func useTempState(t *testing.T) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, ".local", "state"))
+}
+
+// fakeVectors gives each known text a fixed vector.
+var fakeVectors = map[string][]float32{
+	"free up port 3000":     {1, 0.1, 0},
+	"kill process on port":  {0.9, 0.2, 0},
+	"list kubernetes pods":  {0, 0, 1},
+	"show git branch names": {0, 1, 0.1},
+}
+
+func TestSimilaritiesEmbedsEachTextOnce(t *testing.T) {
+	useTempState(t)
+	var embedded []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/embed" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		var req struct {
+			Model string   `json:"model"`
+			Input []string `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Model != "nomic-embed-text" {
+			t.Errorf("unexpected request %+v %v", req, err)
+		}
+		embedded = append(embedded, req.Input...)
+		vectors := make([][]float32, len(req.Input))
+		for idx, text := range req.Input {
+			vectors[idx] = fakeVectors[text]
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"embeddings": vectors})
+	}))
+	defer server.Close()
+
+	client, err := New(config.EmbeddingsConfig{API: "ollama", BaseURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	texts := []string{"kill process on port", "list kubernetes pods"}
+	scores, err := Similarities(context.Background(), client, "free up port 3000", texts)
+	if err != nil {
+		t.Fatalf("Similarities failed: %v", err)
+	}
+	if len(scores) != 2 || scores[0] < 0.9 || scores[1] > 0.1 {
+		t.Fatalf("expected the paraphrase close and the rest far, got %v", scores)
+	}
+	if len(embedded) != 3 {
+		t.Fatalf("expected the query and both texts embedded, got %v", embedded)
+	}
+
+	texts = append(texts, "show git branch names")
+	if _, err := Similarities(context.Background(), client, "free up port 3000", texts); err != nil {
+		t.Fatalf("Similarities failed: %v", err)
+	}
+	if len(embedded) != 4 || embedded[3] != "show git branch names" {
+		t.Fatalf("expected only the new text embedded, got %v", embedded)
+	}
+}
+
+func TestOpenAIResponsesAreReadByIndex(t *testing.T) {
+	t.Setenv("TEST_EMBED_KEY", "sk-test")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" || r.Header.Get("Authorization") != "Bearer sk-test" {
+			t.Errorf("unexpected request %s %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		_, _ = w.Write([]byte(`{"data":[{"index":1,"embedding":[0,1]},{"index":0,"embedding":[1,0]}]}`))
+	}))
+	defer server.Close()
+
+	client, err := New(config.EmbeddingsConfig{API: "openai", BaseURL: server.URL + "/v1", APIKeyEnv: "TEST_EMBED_KEY"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vectors, err := client.Embed(context.Background(), []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if vectors[0][0] != 1 || vectors[1][1] != 1 {
+		t.Fatalf("expected vectors in input order, got %v", vectors)
+	}
+
+	t.Setenv("TEST_EMBED_KEY", "")
+	if _, err := client.Embed(context.Background(), []string{"a"}); err == nil {
+		t.Fatalf("expected a missing key to fail before calling the API")
+	}
+	if _, err := New(config.EmbeddingsConfig{API: "cohere"}); err == nil {
+		t.Fatalf("expected an unknown api to be rejected")
+	}
+}
+
+func TestCosine(t *testing.T) {
+	if got := Cosine([]float32{1, 0}, []float32{2, 0}); got != 1 {
+		t.Fatalf("expected parallel vectors to score 1, got %v", got)
+	}
+	if got := Cosine([]float32{1, 0}, []float32{0, 1}); got != 0 {
+		t.Fatalf("expected orthogonal vectors to score 0, got %v", got)
+	}
+	if got := Cosine([]float32{1, 0}, []float32{1, 0, 0}); got != 0 {
+		t.Fatalf("expected vectors of different models to score 0, got %v", got)
+	}
+}
@@ -0,0 +1,175 @@
+package embedding
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/ashwch/ew/internal/state"
+)
+
+const fileName = "embeddings.json"
+
+// maxVectors bounds the stored vectors; the least recently used are
+// dropped first.
+const maxVectors = 1500
+
+// vector is one stored embedding. Values are little-endian float32s, which
+// JSON keeps as base64.
+type vector struct {
+	Values []byte `json:"values"`
+	UsedAt string `json:"used_at"`
+}
+
+type document struct {
+	Vectors map[string]vector `json:"vectors"`
+}
+
+// Similarities returns the cosine similarity of each text to query, from
+// -1 to 1. Texts embedded before are read from the state directory; the
+// rest are embedded in one go and stored with them.
+func Similarities(ctx context.Context, client *Client, query string, texts []string) ([]float64, error) {
+	doc, err := load()
+	if err != nil {
+		return nil, err
+	}
+	all := append([]string{query}, texts...)
+	keys := make([]string, len(all))
+	vectors := make([][]float32, len(all))
+	var missing []int
+	for idx, text := range all {
+		keys[idx] = key(client.Model(), text)
+		if stored, ok := doc.Vectors[keys[idx]]; ok {
+			vectors[idx] = decodeValues(stored.Values)
+		}
+		if len(vectors[idx]) == 0 {
+			missing = append(missing, idx)
+		}
+	}
+
+	if len(missing) > 0 {
+		pending := make([]string, len(missing))
+		for i, idx := range missing {
+			pending[i] = all[idx]
+		}
+		embedded, err := client.Embed(ctx, pending)
+		if err != nil {
+			return nil, err
+		}
+		for i, idx := range missing {
+			vectors[idx] = embedded[i]
+		}
+		// Every vector used here counts as used now, so pruning keeps
+		// the ones still searched.
+		now := time.Now().UTC().Format(time.RFC3339)
+		for idx := range all {
+			doc.Vectors[keys[idx]] = vector{Values: encodeValues(vectors[idx]), UsedAt: now}
+		}
+		if err := save(doc); err != nil {
+			return nil, err
+		}
+	}
+
+	scores := make([]float64, len(texts))
+	for idx := range texts {
+		scores[idx] = Cosine(vectors[0], vectors[idx+1])
+	}
+	return scores, nil
+}
+
+// Cosine is the cosine similarity of a and b, or 0 when they differ in
+// length or either is all zeros.
+func Cosine(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for idx := range a {
+		dot += float64(a[idx]) * float64(b[idx])
+		normA += float64(a[idx]) * float64(a[idx])
+		normB += float64(b[idx]) * float64(b[idx])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+func key(model, text string) string {
+	sum := sha256.Sum256([]byte(model + "\x00" + text))
+	return hex.EncodeToString(sum[:])
+}
+
+func encodeValues(values []float32) []byte {
+	out := make([]byte, 4*len(values))
+	for idx, value := range values {
+		binary.LittleEndian.PutUint32(out[4*idx:], math.Float32bits(value))
+	}
+	return out
+}
+
+func decodeValues(raw []byte) []float32 {
+	if len(raw)%4 != 0 {
+		return nil
+	}
+	out := make([]float32, len(raw)/4)
+	for idx := range out {
+		out[idx] = math.Float32frombits(binary.LittleEndian.Uint32(raw[4*idx:]))
+	}
+	return out
+}
+
+func load() (document, error) {
+	backend, err := state.Current()
+	if err != nil {
+		return document{}, err
+	}
+	raw, err := backend.Read(fileName)
+	if err != nil {
+		return document{}, fmt.Errorf("could not read embeddings: %w", err)
+	}
+	doc := document{Vectors: map[string]vector{}}
+	if raw == nil {
+		return doc, nil
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return document{}, fmt.Errorf("could not parse embeddings: %w", err)
+	}
+	if doc.Vectors == nil {
+		doc.Vectors = map[string]vector{}
+	}
+	return doc, nil
+}
+
+func save(doc document) error {
+	if len(doc.Vectors) > maxVectors {
+		keys := make([]string, 0, len(doc.Vectors))
+		for name := range doc.Vectors {
+			keys = append(keys, name)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return doc.Vectors[keys[i]].UsedAt < doc.Vectors[keys[j]].UsedAt
+		})
+		for _, name := range keys[:len(keys)-maxVectors] {
+			delete(doc.Vectors, name)
+		}
+	}
+	payload, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("could not encode embeddings: %w", err)
+	}
+	backend, err := state.Current()
+	if err != nil {
+		return err
+	}
+	if err := backend.Write(fileName, payload); err != nil {
+		return fmt.Errorf("could not save embeddings: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+// Package feedback writes the opt-in suggestion dataset: one JSON line per
+// answered query saying what ew suggested, what the user turned down, what
+// they went with, and whether it worked. It is off unless feedback.enabled
+// is set, and every text field is redacted before it reaches disk.
+package feedback
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ashwch/ew/internal/safety"
+	"github.com/ashwch/ew/internal/state"
+)
+
+// FileName is the dataset's name in the state directory.
+const FileName = "feedback.jsonl"
+
+// SchemaVersion is bumped whenever a Record field changes meaning.
+const SchemaVersion = 1
+
+const maxFieldLength = 2048
+
+// Outcomes of a Record.
+const (
+	OutcomeSucceeded   = "succeeded"
+	OutcomeFailed      = "failed"
+	OutcomeSuggested   = "suggested"
+	OutcomeDeclined    = "declined"
+	OutcomeInterrupted = "interrupted"
+)
+
+// Record is one line of the dataset. Success is only set when the chosen
+// command was executed, and Model only when a provider chose it.
+type Record struct {
+	Version      int      `json:"version"`
+	Timestamp    string   `json:"timestamp"`
+	Intent       string   `json:"intent"`
+	Query        string   `json:"query,omitempty"`
+	Chosen       string   `json:"chosen,omitempty"`
+	ChosenSource string   `json:"chosen_source,omitempty"`
+	Model        string   `json:"model,omitempty"`
+	Rejected     []string `json:"rejected,omitempty"`
+	Outcome      string   `json:"outcome"`
+	Success      *bool    `json:"success,omitempty"`
+}
+
+// Disabled reports whether EW_FEEDBACK turns the dataset off regardless of
+// the config.
+func Disabled() bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("EW_FEEDBACK"))) {
+	case "0", "off", "false", "no":
+		return true
+	}
+	return false
+}
+
+// Path says where the dataset is kept in the state backend.
+func Path() (string, error) {
+	backend, err := state.Current()
+	if err != nil {
+		return "", err
+	}
+	return backend.Location(FileName), nil
+}
+
+// Append redacts record and adds it to the dataset. The dataset is never
+// trimmed; it is the team's to export and clear.
+func Append(record Record) error {
+	if record.Chosen == "" && len(record.Rejected) == 0 {
+		return nil
+	}
+	line, err := json.Marshal(Redact(record))
+	if err != nil {
+		return fmt.Errorf("could not encode feedback: %w", err)
+	}
+	backend, err := state.Current()
+	if err != nil {
+		return err
+	}
+	if err := backend.Append(FileName, line, 0); err != nil {
+		return fmt.Errorf("could not write feedback: %w", err)
+	}
+	return nil
+}
+
+// Load returns every record in the dataset, oldest first.
+func Load() ([]Record, error) {
+	backend, err := state.Current()
+	if err != nil {
+		return nil, err
+	}
+	lines, err := backend.Records(FileName)
+	if err != nil {
+		return nil, fmt.Errorf("could not read feedback: %w", err)
+	}
+	records := make([]Record, 0, len(lines))
+	for _, line := range lines {
+		var record Record
+		if err := json.Unmarshal(line, &record); err != nil {
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// Redact stamps record and scrubs its text: secrets are masked, the home
+// directory becomes ~, and long fields are cut.
+func Redact(record Record) Record {
+	out := record
+	out.Version = SchemaVersion
+	if out.Timestamp == "" {
+		out.Timestamp = time.Now().UTC().Format(time.RFC3339)
+	}
+	home, _ := os.UserHomeDir()
+	out.Query = scrub(out.Query, home)
+	out.Chosen = scrub(out.Chosen, home)
+	if len(record.Rejected) > 0 {
+		out.Rejected = make([]string, 0, len(record.Rejected))
+		for _, command := range record.Rejected {
+			if scrubbed := scrub(command, home); scrubbed != "" {
+				out.Rejected = append(out.Rejected, scrubbed)
+			}
+		}
+	}
+	return out
+}
+
+func scrub(value, home string) string {
+	value = strings.TrimSpace(safety.RedactText(strings.TrimSpace(value)))
+	if home = strings.TrimRight(home, string(filepath.Separator)); len(home) > 1 {
+		value = strings.ReplaceAll(value, home, "~")
+	}
+	if len(value) > maxFieldLength {
+		value = value[:maxFieldLength]
+	}
+	return value
+}
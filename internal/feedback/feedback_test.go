@@ -0,0 +1,74 @@
+package feedback
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func useTempState(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", "")
+	return home
+}
+
+func TestAppendRedactsAndWritesOneLinePerRecord(t *testing.T) {
+	home := useTempState(t)
+	success := true
+	if err := Append(Record{
+		Intent:   "run",
+		Query:    "deploy with my token",
+		Chosen:   "deploy --token abc123 " + filepath.Join(home, "app"),
+		Rejected: []string{"curl -H 'Authorization: Bearer xyz789' example.com"},
+		Outcome:  OutcomeSucceeded,
+		Success:  &success,
+	}); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	if err := Append(Record{Intent: "find", Query: "nothing chosen", Outcome: OutcomeSuggested}); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+
+	path, err := Path()
+	if err != nil {
+		t.Fatalf("Path failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected a record without commands to be skipped, got %d lines", len(lines))
+	}
+	for _, secret := range []string{"abc123", "xyz789", home} {
+		if strings.Contains(lines[0], secret) {
+			t.Fatalf("expected %q to be redacted from %s", secret, lines[0])
+		}
+	}
+	records, err := Load()
+	if err != nil || len(records) != 1 {
+		t.Fatalf("expected Load to return the one record, got %+v (%v)", records, err)
+	}
+	record := records[0]
+	if record.Version != SchemaVersion || record.Timestamp == "" || record.Success == nil || !*record.Success {
+		t.Fatalf("unexpected record %+v", record)
+	}
+	if !strings.HasSuffix(record.Chosen, "~/app") {
+		t.Fatalf("expected the home directory shown as ~, got %q", record.Chosen)
+	}
+}
+
+func TestDisabledHonoursEnvironmentOffSwitch(t *testing.T) {
+	t.Setenv("EW_FEEDBACK", "off")
+	if !Disabled() {
+		t.Fatalf("expected EW_FEEDBACK=off to disable feedback")
+	}
+	t.Setenv("EW_FEEDBACK", "")
+	if Disabled() {
+		t.Fatalf("expected feedback to follow the config when EW_FEEDBACK is unset")
+	}
+}
@@ -0,0 +1,663 @@
+// Package helper implements the internal plumbing behind the shell hooks:
+// recording failures, reading them back, history and config access, and
+// the hook snippets themselves. It runs as the hidden `ew internal`
+// command, and as the _ew binary kept for rc files written before that.
+package helper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ashwch/ew/internal/alias"
+	"github.com/ashwch/ew/internal/appdirs"
+	"github.com/ashwch/ew/internal/config"
+	ewdoctor "github.com/ashwch/ew/internal/doctor"
+	"github.com/ashwch/ew/internal/history"
+	"github.com/ashwch/ew/internal/hook"
+	"github.com/ashwch/ew/internal/memory"
+	"github.com/ashwch/ew/internal/state"
+)
+
+// subcommands lists what Run understands, in usage order.
+var subcommands = []string{
+	"hook-record",
+	"latest-failure",
+	"history-search",
+	"config-get",
+	"config-set",
+	"config-keys",
+	"provider-names",
+	"memory-queries",
+	"config-path",
+	"state-path",
+	"doctor",
+	"hook-snippet",
+	"prompt-status",
+	"history-take",
+	"output-file",
+	"version",
+}
+
+// IsSubcommand reports whether name is a helper subcommand.
+func IsSubcommand(name string) bool {
+	for _, sub := range subcommands {
+		if sub == name {
+			return true
+		}
+	}
+	return false
+}
+
+// exitError carries a non-zero exit code that is not a failure of the
+// helper itself, such as doctor reporting a problem.
+type exitError struct {
+	code int
+}
+
+func (e exitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+// Run executes one helper subcommand and returns the process exit code.
+// name is how the helper was invoked ("_ew" or "ew internal") and version
+// the build's version, printed by the version subcommand.
+func Run(name, version string, args []string) int {
+	if len(args) == 0 {
+		printUsage(name)
+		return 2
+	}
+
+	sub := args[0]
+	args = args[1:]
+
+	var err error
+	switch sub {
+	case "hook-record":
+		err = hookRecord(args)
+	case "latest-failure":
+		err = latestFailure(args)
+	case "history-search":
+		err = historySearch(args)
+	case "config-get":
+		err = configGet(args)
+	case "config-set":
+		err = configSet(args)
+	case "config-keys":
+		err = configKeys()
+	case "provider-names":
+		err = providerNames()
+	case "memory-queries":
+		err = memoryQueries()
+	case "config-path":
+		err = configPath()
+	case "state-path":
+		err = statePath()
+	case "doctor":
+		err = doctor()
+	case "hook-snippet":
+		err = hookSnippet(args)
+	case "prompt-status":
+		err = promptStatus(args)
+	case "history-take":
+		err = historyTake(args)
+	case "output-file":
+		err = outputFile(args)
+	case "version":
+		fmt.Println(version)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown %s subcommand: %s\n", name, sub)
+		printUsage(name)
+		return 2
+	}
+
+	var exit exitError
+	if errors.As(err, &exit) {
+		return exit.code
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s error: %v\n", name, err)
+		return 1
+	}
+	return 0
+}
+
+func printUsage(name string) {
+	fmt.Printf("%s <%s>\n", name, strings.Join(subcommands, "|"))
+}
+
+func hookRecord(args []string) error {
+	fs := flag.NewFlagSet("hook-record", flag.ContinueOnError)
+	command := fs.String("command", "", "command that was run")
+	exitCode := fs.Int("exit-code", 1, "exit code")
+	cwd := fs.String("cwd", "", "working directory")
+	shell := fs.String("shell", "", "shell name")
+	sessionID := fs.String("session-id", "", "shell session id")
+	timestamp := fs.String("timestamp", "", "timestamp in RFC3339")
+	outputPath := fs.String("output-file", "", "file the hook captured the command's stderr in")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(*command) == "" {
+		return fmt.Errorf("--command is required")
+	}
+	var output string
+	if *exitCode != 0 && *outputPath != "" {
+		// The snippet only passes a file when capture is on, so a lost
+		// EW_CAPTURE_OUTPUT still gets the default.
+		lines := hook.CaptureLines()
+		if lines == 0 {
+			lines = hook.DefaultOutputLines
+		}
+		// Output is only context for a fix; the event is worth keeping
+		// without it.
+		output, _ = hook.ReadOutput(*outputPath, lines)
+	}
+	if *outputPath != "" {
+		// What is kept went through redaction with the event; the raw
+		// stderr is not left on disk until the next command.
+		hook.ClearOutput(*outputPath)
+	}
+
+	if cfg, _, err := config.LoadOrCreate(); err == nil {
+		defer useStateBackend(cfg)()
+	}
+	ev := hook.Event{
+		Command:   *command,
+		ExitCode:  *exitCode,
+		CWD:       *cwd,
+		Shell:     *shell,
+		SessionID: *sessionID,
+		Timestamp: *timestamp,
+		Output:    output,
+	}
+	if err := hook.RecordEvent(ev); err != nil {
+		return err
+	}
+	// The index only saves the next search some parsing; it catches up by
+	// itself if this fails.
+	_ = history.UpdateIndex()
+	return nil
+}
+
+func latestFailure(args []string) error {
+	fs := flag.NewFlagSet("latest-failure", flag.ContinueOnError)
+	sessionID := fs.String("session-id", "", "shell session id")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if cfg, _, err := config.LoadOrCreate(); err == nil {
+		defer useStateBackend(cfg)()
+	}
+	ev, err := hook.LatestFailure(*sessionID)
+	if err != nil {
+		return err
+	}
+	if ev == nil {
+		fmt.Println("{}")
+		return nil
+	}
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(payload))
+	return nil
+}
+
+// historyTake prints the commands ew queued for this session's history,
+// one per line, for the hook snippet to add with print -s, history -s, or
+// PSReadLine's AddToHistory.
+func historyTake(args []string) error {
+	fs := flag.NewFlagSet("history-take", flag.ContinueOnError)
+	sessionID := fs.String("session-id", "", "shell session id")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	commands, err := hook.TakeHistory(*sessionID)
+	if err != nil {
+		return err
+	}
+	for _, command := range commands {
+		fmt.Println(command)
+	}
+	return nil
+}
+
+// outputFile creates the file the zsh hook tees each command's stderr into
+// for EW_CAPTURE_OUTPUT and prints its path.
+func outputFile(args []string) error {
+	fs := flag.NewFlagSet("output-file", flag.ContinueOnError)
+	sessionID := fs.String("session-id", "", "shell session id")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	path, err := hook.OutputFile(*sessionID)
+	if err != nil {
+		return err
+	}
+	fmt.Println(path)
+	return nil
+}
+
+func promptStatus(args []string) error {
+	fs := flag.NewFlagSet("prompt-status", flag.ContinueOnError)
+	sessionID := fs.String("session-id", "", "shell session id")
+	maxAge := fs.Duration("max-age", 10*time.Minute, "hide the indicator for failures older than this")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if cfg, _, err := config.LoadOrCreate(); err == nil {
+		defer useStateBackend(cfg)()
+	}
+	status, err := hook.PromptStatus(*sessionID, *maxAge, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if status != "" {
+		fmt.Println(status)
+	}
+	return nil
+}
+
+func historySearch(args []string) error {
+	fs := flag.NewFlagSet("history-search", flag.ContinueOnError)
+	query := fs.String("query", "", "query text")
+	limit := fs.Int("limit", 8, "max results")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(*query) == "" {
+		return fmt.Errorf("--query is required")
+	}
+
+	if cfg, _, err := config.LoadOrCreate(); err == nil {
+		history.SetSkipSecrets(cfg.History.Secrets == "skip")
+		history.SetViaEW(cfg.History.ViaEW)
+		defer useStateBackend(cfg)()
+	}
+	matches, err := history.Search(context.Background(), *query, *limit)
+	if err != nil && !errors.Is(err, history.ErrNoHistory) {
+		return err
+	}
+	payload, err := json.Marshal(matches)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(payload))
+	return nil
+}
+
+func configGet(args []string) error {
+	fs := flag.NewFlagSet("config-get", flag.ContinueOnError)
+	key := fs.String("key", "", "optional config key")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, _, err := config.LoadOrCreate()
+	if err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(*key) == "" {
+		payload, err := json.MarshalIndent(cfg, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(payload))
+		return nil
+	}
+
+	val, err := cfg.Get(*key)
+	if err != nil {
+		return err
+	}
+	fmt.Println(val)
+	return nil
+}
+
+func configSet(args []string) error {
+	fs := flag.NewFlagSet("config-set", flag.ContinueOnError)
+	key := fs.String("key", "", "config key")
+	value := fs.String("value", "", "config value")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(*key) == "" {
+		return fmt.Errorf("--key is required")
+	}
+	if strings.TrimSpace(*value) == "" {
+		return fmt.Errorf("--value is required")
+	}
+
+	cfg, path, err := config.LoadFile()
+	if err != nil {
+		return err
+	}
+	if err := cfg.Set(*key, *value); err != nil {
+		return err
+	}
+	if err := config.Save(path, cfg); err != nil {
+		return err
+	}
+	fmt.Printf("saved %s=%s\n", *key, *value)
+	return nil
+}
+
+// configKeys prints every config key, one per line, for shell completion.
+func configKeys() error {
+	cfg, _, err := config.LoadOrCreate()
+	if err != nil {
+		return err
+	}
+	for _, key := range config.Keys(cfg) {
+		fmt.Println(key)
+	}
+	return nil
+}
+
+// providerNames prints the configured provider names, for completing
+// --provider.
+func providerNames() error {
+	cfg, _, err := config.LoadOrCreate()
+	if err != nil {
+		return err
+	}
+	for _, name := range slices.Sorted(maps.Keys(cfg.Providers)) {
+		fmt.Println(name)
+	}
+	return nil
+}
+
+// memoryQueries prints each learned memory query, for completing
+// `ew forget memory for ...` and the other memory prompts. It reads the
+// store from the configured state backend, as ew does.
+func memoryQueries() error {
+	cfg, _, err := config.LoadOrCreate()
+	if err != nil {
+		return err
+	}
+	defer useStateBackend(cfg)()
+	store, _, err := memory.Load()
+	if err != nil {
+		return err
+	}
+	for _, query := range store.Queries() {
+		fmt.Println(query)
+	}
+	return nil
+}
+
+// useStateBackend points the state package at the backend state.backend
+// names, as ew does, so the hooks read and write the same store as ew. It
+// returns the func that closes the backend again.
+func useStateBackend(cfg config.Config) func() {
+	if cfg.State.Backend == "" || cfg.State.Backend == state.BackendFiles {
+		return func() {}
+	}
+	backend, err := state.Open(cfg.State.Backend)
+	if err != nil {
+		return func() {}
+	}
+	state.Use(backend)
+	return func() {
+		state.Use(nil)
+		_ = backend.Close()
+	}
+}
+
+func configPath() error {
+	path, err := appdirs.ConfigFilePath()
+	if err != nil {
+		return err
+	}
+	fmt.Println(path)
+	return nil
+}
+
+func statePath() error {
+	path, err := appdirs.StateDir()
+	if err != nil {
+		return err
+	}
+	fmt.Println(path)
+	return nil
+}
+
+func doctor() error {
+	cfg, _, cfgErr := config.LoadOrCreate()
+	report := ewdoctor.Run(cfg, cfgErr)
+	payload, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(payload))
+	if code := report.ExitCode(); code != 0 {
+		return exitError{code: code}
+	}
+	return nil
+}
+
+func hookSnippet(args []string) error {
+	fs := flag.NewFlagSet("hook-snippet", flag.ContinueOnError)
+	shell := fs.String("shell", "zsh", "shell type: zsh|bash|fish|nu|powershell")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	snippet, err := HookSnippet(*shell)
+	if err != nil {
+		return err
+	}
+	fmt.Println(snippet)
+	return nil
+}
+
+// HookSnippet returns the rc-file snippet that installs ew's hooks in
+// shell. The snippets call `ew internal` rather than _ew, so a working ew
+// on PATH is all they need; `command` skips any ew alias or function. The
+// zsh, bash, and fish snippets also load `ew completion` for their shell
+// and the aliases ew made.
+func HookSnippet(shell string) (string, error) {
+	switch history.ShellName(shell) {
+	case "zsh":
+		return zshSnippet() + loadAliases("zsh"), nil
+	case "bash":
+		return bashSnippet() + loadAliases("bash"), nil
+	case "fish":
+		return fishSnippet() + loadAliases("fish"), nil
+	case "nu":
+		return nuSnippet(), nil
+	case "powershell":
+		return powershellSnippet(), nil
+	}
+	return "", fmt.Errorf("unsupported shell: %s", shell)
+}
+
+// loadAliases is the snippet line that sources the aliases file `ew make
+// alias` writes for shell, empty when the config dir is unknown.
+func loadAliases(shell string) string {
+	dir, err := appdirs.ConfigDir()
+	if err != nil {
+		return ""
+	}
+	name, err := alias.FileName(shell)
+	if err != nil {
+		return ""
+	}
+	line, err := alias.SourceLine(shell, filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return "\n" + line
+}
+
+func zshSnippet() string {
+	return `export EW_SESSION_ID=${EW_SESSION_ID:-"$$.$(date +%s)"}
+export EW_HISTORY_HOOK=1
+if [[ "${EW_CAPTURE_OUTPUT:-0}" != 0 && -t 2 && -z "$_EW_OUTPUT_FILE" ]]; then
+  _EW_OUTPUT_FILE=$(command ew internal output-file --session-id "$EW_SESSION_ID" 2>/dev/null)
+fi
+function _ew_preexec() {
+  EW_LAST_COMMAND="$1"
+  if [ -n "$_EW_OUTPUT_FILE" ] && [ -z "$_EW_STDERR" ]; then
+    : >| "$_EW_OUTPUT_FILE"
+    exec {_EW_STDERR}>&2 2> >(command tee -a -- "$_EW_OUTPUT_FILE" >&2)
+  fi
+  if [[ -o interactive_comments ]]; then
+    export EW_INTERACTIVE_COMMENTS=1
+  else
+    unset EW_INTERACTIVE_COMMENTS
+  fi
+}
+function _ew_precmd() {
+  local exit_code=$?
+  if [ -n "$_EW_STDERR" ]; then
+    exec 2>&$_EW_STDERR {_EW_STDERR}>&-
+    unset _EW_STDERR
+  fi
+  if [ -n "$EW_LAST_COMMAND" ]; then
+    command ew internal hook-record --command "$EW_LAST_COMMAND" --exit-code "$exit_code" --cwd "$PWD" --shell "zsh" --session-id "$EW_SESSION_ID" --output-file "$_EW_OUTPUT_FILE" >/dev/null 2>&1
+    case " $EW_LAST_COMMAND " in
+      *[[:space:]]ew[[:space:]]*)
+        local ew_queued
+        command ew internal history-take --session-id "$EW_SESSION_ID" 2>/dev/null | while IFS= read -r ew_queued; do print -s -- "$ew_queued"; done
+        ;;
+    esac
+    EW_LAST_COMMAND=""
+    EW_PROMPT_STATUS=""
+    if [ "$exit_code" -ne 0 ]; then
+      EW_PROMPT_STATUS=$(command ew internal prompt-status --session-id "$EW_SESSION_ID" 2>/dev/null)
+    fi
+  fi
+}
+autoload -Uz add-zsh-hook
+add-zsh-hook preexec _ew_preexec
+add-zsh-hook precmd _ew_precmd
+eval "$(command ew completion zsh 2>/dev/null)"`
+}
+
+func bashSnippet() string {
+	return `export EW_SESSION_ID=${EW_SESSION_ID:-"$$.$(date +%s)"}
+export EW_HISTORY_HOOK=1
+_EW_LAST_HISTCMD="$HISTCMD"
+_ew_prompt() {
+  local exit_code=$?
+  if [ "$HISTCMD" = "$_EW_LAST_HISTCMD" ]; then
+    return
+  fi
+  _EW_LAST_HISTCMD="$HISTCMD"
+  local last_command
+  last_command=$(fc -ln -1 2>/dev/null)
+  if [ -n "$last_command" ]; then
+    command ew internal hook-record --command "$last_command" --exit-code "$exit_code" --cwd "$PWD" --shell "bash" --session-id "$EW_SESSION_ID" >/dev/null 2>&1
+    case " $last_command " in
+      *[[:space:]]ew[[:space:]]*)
+        local ew_queued
+        while IFS= read -r ew_queued; do history -s -- "$ew_queued"; done < <(command ew internal history-take --session-id "$EW_SESSION_ID" 2>/dev/null)
+        _EW_LAST_HISTCMD="$HISTCMD"
+        ;;
+    esac
+    EW_PROMPT_STATUS=""
+    if [ "$exit_code" -ne 0 ]; then
+      EW_PROMPT_STATUS=$(command ew internal prompt-status --session-id "$EW_SESSION_ID" 2>/dev/null)
+    fi
+  fi
+}
+case ";$PROMPT_COMMAND;" in
+  *";_ew_prompt;"*) ;;
+  *) PROMPT_COMMAND="_ew_prompt${PROMPT_COMMAND:+;$PROMPT_COMMAND}" ;;
+esac
+eval "$(command ew completion bash 2>/dev/null)"`
+}
+
+func fishSnippet() string {
+	return `set -q EW_SESSION_ID; or set -gx EW_SESSION_ID "$fish_pid".(date +%s)
+function __ew_preexec --on-event fish_preexec
+  set -g EW_LAST_COMMAND $argv[1]
+end
+function __ew_postexec --on-event fish_postexec
+  set -l exit_code $status
+  if test -n "$EW_LAST_COMMAND"
+    command ew internal hook-record --command "$EW_LAST_COMMAND" --exit-code "$exit_code" --cwd "$PWD" --shell "fish" --session-id "$EW_SESSION_ID" >/dev/null 2>&1
+    if string match -qr '(^|\s)ew(\s|$)' -- "$EW_LAST_COMMAND"
+      history merge
+    end
+    set -e EW_LAST_COMMAND
+    set -g EW_PROMPT_STATUS ""
+    if test $exit_code -ne 0
+      set -g EW_PROMPT_STATUS (command ew internal prompt-status --session-id "$EW_SESSION_ID" 2>/dev/null)
+    end
+  end
+end
+command ew completion fish 2>/dev/null | source`
+}
+
+// nuSnippet hooks nushell's pre_execution and pre_prompt. Changes hooks make
+// to $env are kept, so the command is carried from one hook to the other.
+// Nushell has no way to add to its in-memory history, so write-back goes to
+// history.txt.
+func nuSnippet() string {
+	return `$env.EW_SESSION_ID = ($env.EW_SESSION_ID? | default $"($nu.pid).(date now | format date '%s')")
+$env.config.hooks.pre_execution = ($env.config.hooks.pre_execution? | default [] | append {||
+  $env.EW_LAST_COMMAND = (commandline)
+})
+$env.config.hooks.pre_prompt = ($env.config.hooks.pre_prompt? | default [] | append {||
+  let exit_code = $env.LAST_EXIT_CODE
+  if ($env.EW_LAST_COMMAND? | default "") != "" {
+    ^ew internal hook-record --command $env.EW_LAST_COMMAND --exit-code $"($exit_code)" --cwd $env.PWD --shell "nu" --session-id $env.EW_SESSION_ID | complete | ignore
+    $env.EW_LAST_COMMAND = ""
+    $env.EW_PROMPT_STATUS = ""
+    if $exit_code != 0 {
+      $env.EW_PROMPT_STATUS = (^ew internal prompt-status --session-id $env.EW_SESSION_ID | complete | get stdout | str trim)
+    }
+  }
+})`
+}
+
+// powershellSnippet wraps the prompt function, which runs after every
+// command. Get-History ids tell a new command from an empty line, and the
+// prompt's $? and $LASTEXITCODE are left as they were for the original
+// prompt.
+func powershellSnippet() string {
+	return `if (-not $env:EW_SESSION_ID) { $env:EW_SESSION_ID = "$PID.$([DateTimeOffset]::UtcNow.ToUnixTimeSeconds())" }
+$env:EW_HISTORY_HOOK = "1"
+$global:EwExe = (Get-Command ew -CommandType Application -ErrorAction SilentlyContinue | Select-Object -First 1).Source
+$global:EwLastHistoryId = (Get-History -Count 1).Id
+if (-not $global:EwOriginalPrompt) { $global:EwOriginalPrompt = $function:prompt }
+function global:prompt {
+  $ewSucceeded = $?
+  $ewNativeCode = $global:LASTEXITCODE
+  $ewLast = Get-History -Count 1
+  if ($global:EwExe -and $ewLast -and $ewLast.Id -ne $global:EwLastHistoryId) {
+    $global:EwLastHistoryId = $ewLast.Id
+    $exitCode = if ($ewSucceeded) { 0 } elseif ($ewNativeCode) { $ewNativeCode } else { 1 }
+    & $global:EwExe internal hook-record --command $ewLast.CommandLine --exit-code $exitCode --cwd $PWD.Path --shell "powershell" --session-id $env:EW_SESSION_ID *> $null
+    if ($ewLast.CommandLine -match '(^|\s)ew(\s|$)' -and (Get-Module PSReadLine)) {
+      & $global:EwExe internal history-take --session-id $env:EW_SESSION_ID 2> $null | ForEach-Object { [Microsoft.PowerShell.PSConsoleReadLine]::AddToHistory($_) }
+    }
+    $global:EW_PROMPT_STATUS = ""
+    if ($exitCode -ne 0) {
+      $global:EW_PROMPT_STATUS = (& $global:EwExe internal prompt-status --session-id $env:EW_SESSION_ID 2> $null) -join ""
+    }
+  }
+  $global:LASTEXITCODE = $ewNativeCode
+  & $global:EwOriginalPrompt
+}`
+}
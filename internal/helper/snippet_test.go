@@ -0,0 +1,179 @@
+package helper
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBashSnippetUsesHistoryInsteadOfDebugTrap(t *testing.T) {
+	snippet := bashSnippet()
+	if strings.Contains(snippet, "trap '_EW_LAST_COMMAND=$BASH_COMMAND' DEBUG") {
+		t.Fatalf("bash snippet should not use DEBUG trap capture")
+	}
+	if !strings.Contains(snippet, "fc -ln -1") {
+		t.Fatalf("bash snippet should derive last command from fc builtin")
+	}
+	if !strings.Contains(snippet, "2>/dev/null") {
+		t.Fatalf("bash snippet should suppress fc stderr noise when history is unavailable")
+	}
+	if !strings.Contains(snippet, "_EW_LAST_HISTCMD") {
+		t.Fatalf("bash snippet should guard duplicate prompt records using HISTCMD")
+	}
+	if !strings.Contains(snippet, `_EW_LAST_HISTCMD="$HISTCMD"`) {
+		t.Fatalf("bash snippet should initialize HISTCMD guard to avoid stale startup capture")
+	}
+	if !strings.Contains(snippet, `case ";$PROMPT_COMMAND;" in`) {
+		t.Fatalf("bash snippet should avoid duplicate PROMPT_COMMAND injection")
+	}
+}
+
+func TestHookSnippetsSetStableSessionID(t *testing.T) {
+	zsh := zshSnippet()
+	if !strings.Contains(zsh, `EW_SESSION_ID=${EW_SESSION_ID:-"$$.$(date +%s)"}`) {
+		t.Fatalf("zsh snippet should set deterministic session id")
+	}
+	if !strings.Contains(zsh, `EW_LAST_COMMAND=""`) {
+		t.Fatalf("zsh snippet should clear last command after recording")
+	}
+
+	bash := bashSnippet()
+	if !strings.Contains(bash, `EW_SESSION_ID=${EW_SESSION_ID:-"$$.$(date +%s)"}`) {
+		t.Fatalf("bash snippet should set deterministic session id")
+	}
+
+	fish := fishSnippet()
+	if !strings.Contains(fish, `set -q EW_SESSION_ID; or set -gx EW_SESSION_ID "$fish_pid".(date +%s)`) {
+		t.Fatalf("fish snippet should set deterministic session id")
+	}
+	if !strings.Contains(fish, `set -e EW_LAST_COMMAND`) {
+		t.Fatalf("fish snippet should clear last command after recording")
+	}
+}
+
+func TestHookSnippetsSetPromptStatusOnlyAfterFailures(t *testing.T) {
+	for name, snippet := range map[string]string{"zsh": zshSnippet(), "bash": bashSnippet(), "fish": fishSnippet()} {
+		if !strings.Contains(snippet, `command ew internal prompt-status --session-id "$EW_SESSION_ID"`) {
+			t.Fatalf("%s snippet should query prompt-status", name)
+		}
+		if !strings.Contains(snippet, "-ne 0") {
+			t.Fatalf("%s snippet should only query prompt-status after a failure", name)
+		}
+	}
+}
+
+func TestHookSnippetsTakeQueuedHistoryAfterEw(t *testing.T) {
+	for name, snippet := range map[string]string{"zsh": zshSnippet(), "bash": bashSnippet()} {
+		if !strings.Contains(snippet, "export EW_HISTORY_HOOK=1") {
+			t.Fatalf("%s snippet should announce that it takes queued history", name)
+		}
+		if !strings.Contains(snippet, `command ew internal history-take --session-id "$EW_SESSION_ID"`) {
+			t.Fatalf("%s snippet should take queued history", name)
+		}
+	}
+	if !strings.Contains(fishSnippet(), "history merge") {
+		t.Fatalf("fish snippet should merge history written by ew")
+	}
+}
+
+func TestHookSnippetsCallEwInternal(t *testing.T) {
+	for _, shell := range []string{"zsh", "bash", "fish"} {
+		snippet, err := HookSnippet(shell)
+		if err != nil {
+			t.Fatalf("HookSnippet(%s) failed: %v", shell, err)
+		}
+		if !strings.Contains(snippet, "command ew internal hook-record --command") {
+			t.Fatalf("%s snippet should record failures through ew internal", shell)
+		}
+		if strings.Contains(snippet, "_ew hook-record") || strings.Contains(snippet, "(_ew ") {
+			t.Fatalf("%s snippet should not need the _ew binary", shell)
+		}
+	}
+	if _, err := HookSnippet("tcsh"); err == nil {
+		t.Fatalf("expected an unsupported shell to fail")
+	}
+}
+
+func TestHookSnippetsLoadCompletion(t *testing.T) {
+	for shell, load := range map[string]string{
+		"zsh":  `eval "$(command ew completion zsh 2>/dev/null)"`,
+		"bash": `eval "$(command ew completion bash 2>/dev/null)"`,
+		"fish": "command ew completion fish 2>/dev/null | source",
+	} {
+		snippet, err := HookSnippet(shell)
+		if err != nil {
+			t.Fatalf("HookSnippet(%s) failed: %v", shell, err)
+		}
+		if !strings.Contains(snippet, load) {
+			t.Fatalf("%s snippet should load ew completion", shell)
+		}
+	}
+}
+
+func TestHookSnippetsSourceEwAliases(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	for shell, file := range map[string]string{"zsh": "aliases.sh", "bash": "aliases.sh", "fish": "aliases.fish"} {
+		snippet, err := HookSnippet(shell)
+		if err != nil {
+			t.Fatalf("HookSnippet(%s) failed: %v", shell, err)
+		}
+		if !strings.Contains(snippet, filepath.Join(home, ".config", "ew", file)) {
+			t.Fatalf("%s snippet should source %s", shell, file)
+		}
+	}
+}
+
+func TestNuAndPowerShellSnippetsRecordThroughEwInternal(t *testing.T) {
+	for _, shell := range []string{"nu", "nushell", "powershell", "pwsh"} {
+		snippet, err := HookSnippet(shell)
+		if err != nil {
+			t.Fatalf("HookSnippet(%s) failed: %v", shell, err)
+		}
+		if !strings.Contains(snippet, "internal hook-record --command") || !strings.Contains(snippet, "internal prompt-status") {
+			t.Fatalf("%s snippet should record failures and query prompt-status through ew internal", shell)
+		}
+	}
+
+	nu := nuSnippet()
+	for _, want := range []string{"hooks.pre_execution", "hooks.pre_prompt", "(commandline)", "$env.LAST_EXIT_CODE", `--shell "nu"`, "^ew internal"} {
+		if !strings.Contains(nu, want) {
+			t.Fatalf("nu snippet should contain %q", want)
+		}
+	}
+
+	ps := powershellSnippet()
+	for _, want := range []string{"function global:prompt", "Get-History -Count 1", `--shell "powershell"`, "AddToHistory", "& $global:EwOriginalPrompt", "$global:LASTEXITCODE = $ewNativeCode"} {
+		if !strings.Contains(ps, want) {
+			t.Fatalf("powershell snippet should contain %q", want)
+		}
+	}
+	if !strings.Contains(ps, "-CommandType Application") {
+		t.Fatalf("powershell snippet should skip any ew alias or function, like command ew does")
+	}
+}
+
+func TestZshSnippetCapturesStderrPerCommandOnlyWhenAsked(t *testing.T) {
+	snippet := zshSnippet()
+	for _, want := range []string{
+		`"${EW_CAPTURE_OUTPUT:-0}" != 0`,
+		`command ew internal output-file --session-id "$EW_SESSION_ID"`,
+		`exec {_EW_STDERR}>&2 2> >(command tee -a -- "$_EW_OUTPUT_FILE" >&2)`,
+		`exec 2>&$_EW_STDERR {_EW_STDERR}>&-`,
+		`--output-file "$_EW_OUTPUT_FILE"`,
+	} {
+		if !strings.Contains(snippet, want) {
+			t.Fatalf("zsh snippet should contain %q", want)
+		}
+	}
+	// The redirect belongs in preexec and precmd; at the top level it
+	// would cover the prompt and every later command too.
+	if strings.Index(snippet, "2> >(") < strings.Index(snippet, "function _ew_preexec") {
+		t.Fatalf("zsh snippet should only tee stderr from preexec")
+	}
+	// bash has no preexec hook to scope the redirect to one command.
+	if bash := bashSnippet(); strings.Contains(bash, "tee") || strings.Contains(bash, "output-file") {
+		t.Fatalf("bash snippet should not capture stderr")
+	}
+}
@@ -0,0 +1,388 @@
+package history
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ashwch/ew/internal/state"
+)
+
+// The history index keeps what has already been parsed out of the shell
+// history files, so a search only reads what the shells appended since.
+// history_index.json records how far each file has been read;
+// history_index.jsonl holds the normalized entries and is only appended to.
+// A history file that is rewritten, truncated, or removed rebuilds both.
+// Both are kept in the state backend, so a sqlite build keeps them in
+// state.db with the rest.
+const (
+	indexFileName = "history_index.json"
+	indexLogName  = "history_index.jsonl"
+	indexVersion  = 3
+	// indexFingerprintBytes is how much of a history file, ending at the
+	// indexed offset, is hashed to tell an append from a rewrite.
+	indexFingerprintBytes = 512
+)
+
+// historySources are the shells whose history is read, in order.
+var historySources = []struct {
+	shell string
+	parse historyParser
+}{
+	{"zsh", parseZshHistory},
+	{"bash", parseBashHistory},
+	{"fish", parseFishHistory},
+	{"nu", parseNuHistory},
+	{"powershell", parsePowerShellHistory},
+}
+
+type indexHeader struct {
+	Version     int                    `json:"version"`
+	SkipSecrets bool                   `json:"skip_secrets"`
+	Sources     map[string]indexSource `json:"sources"`
+}
+
+// indexSource is how far one history file has been indexed. Size and
+// ModTime are the file as it was then; Offset is where reading resumes.
+type indexSource struct {
+	Path        string `json:"path"`
+	Size        int64  `json:"size"`
+	ModTime     int64  `json:"mod_time"`
+	Offset      int64  `json:"offset"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	NextOrder   int    `json:"next_order"`
+}
+
+type indexRecord struct {
+	Source    string `json:"source"`
+	Command   string `json:"command"`
+	Timestamp int64  `json:"ts"`
+	Redacted  bool   `json:"redacted,omitempty"`
+	Approx    bool   `json:"approx,omitempty"`
+	ViaEW     bool   `json:"via_ew,omitempty"`
+	Order     int    `json:"order"`
+}
+
+type historyIndex struct {
+	backend state.Backend
+	header  indexHeader
+	// stale is set when the stored index cannot be used as it is and has
+	// to be rebuilt from the history files.
+	stale bool
+}
+
+// UpdateIndex reads what the shells appended to their history files into
+// the index. It is cheap enough to run after every command: it does nothing
+// until a search has built the index, and leaves a rebuild to the next
+// search.
+func UpdateIndex() error {
+	idx, err := openIndex(false)
+	if err != nil || idx == nil || idx.stale {
+		return err
+	}
+	_, err = idx.update(context.Background(), true)
+	return err
+}
+
+// loadIndexedEntries brings the index up to date and returns its entries,
+// one per command.
+func loadIndexedEntries(ctx context.Context) ([]Entry, error) {
+	idx, err := openIndex(true)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := idx.update(ctx, false); err != nil {
+		return nil, err
+	}
+	return idx.entries()
+}
+
+// openIndex reads the index header. A missing index is nil unless create
+// is set, in which case it is an empty one to be built.
+func openIndex(create bool) (*historyIndex, error) {
+	backend, err := state.Current()
+	if err != nil {
+		return nil, err
+	}
+	idx := &historyIndex{backend: backend}
+	payload, err := idx.backend.Read(indexFileName)
+	if err != nil {
+		return nil, err
+	}
+	if payload == nil && !create {
+		return nil, nil
+	}
+	if payload == nil || json.Unmarshal(payload, &idx.header) != nil || idx.header.Version != indexVersion {
+		idx.header = indexHeader{Version: indexVersion}
+		idx.stale = true
+	}
+	if create && idx.header.SkipSecrets != skipSecrets.Load() {
+		// Entries were filtered for the other history.secrets setting.
+		idx.header.SkipSecrets = skipSecrets.Load()
+		idx.stale = true
+	}
+	if idx.stale {
+		idx.header.Sources = nil
+	}
+	if idx.header.Sources == nil {
+		idx.header.Sources = map[string]indexSource{}
+	}
+	return idx, nil
+}
+
+// update indexes what changed in each history file since it was last
+// read. appendOnly returns without changes, and false, when that would take
+// a rebuild.
+func (idx *historyIndex) update(ctx context.Context, appendOnly bool) (bool, error) {
+	rebuild := idx.stale
+	present := make(map[string]string, len(historySources))
+	changed := make(map[string]bool, len(historySources))
+	for _, src := range historySources {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		path, err := FilePath(src.shell)
+		if err != nil {
+			return false, err
+		}
+		known, indexed := idx.header.Sources[src.shell]
+		info, err := os.Stat(path)
+		if err != nil {
+			// Gone or unreadable: what was indexed from it has to go.
+			rebuild = rebuild || indexed
+			continue
+		}
+		present[src.shell] = path
+		if indexed && known.Path == path && known.Size == info.Size() && known.ModTime == info.ModTime().UnixNano() {
+			continue
+		}
+		if indexed && !known.continuedBy(path, info) {
+			rebuild = true
+		}
+		changed[src.shell] = true
+	}
+	if rebuild && appendOnly {
+		return false, nil
+	}
+	if !rebuild && len(changed) == 0 {
+		return false, nil
+	}
+
+	var log bytes.Buffer
+	if rebuild {
+		idx.header.Sources = map[string]indexSource{}
+	}
+	for _, src := range historySources {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		// A rebuild reads every file again from the start.
+		path, ok := present[src.shell]
+		if !ok || !(rebuild || changed[src.shell]) {
+			continue
+		}
+		next, err := idx.read(src.shell, path, src.parse, idx.header.Sources[src.shell], &log)
+		if err != nil {
+			// An unreadable file is left out, as it was before there was
+			// an index; it is tried again next time.
+			continue
+		}
+		idx.header.Sources[src.shell] = next
+	}
+
+	if rebuild {
+		if err := idx.backend.Replace(indexLogName, splitRecords(log.Bytes())); err != nil {
+			return false, fmt.Errorf("could not write history index: %w", err)
+		}
+	} else if err := idx.appendLog(log.Bytes()); err != nil {
+		return false, err
+	}
+	idx.stale = false
+	return true, idx.saveHeader()
+}
+
+// continuedBy reports whether the file at path, now described by info, is
+// the indexed file with more written to it.
+func (s indexSource) continuedBy(path string, info os.FileInfo) bool {
+	if s.Path != path || info.Size() < s.Offset || info.Size() < s.Size {
+		return false
+	}
+	if info.Size() == s.Size {
+		// Same length but touched: most likely rewritten in place.
+		return false
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	fingerprint, err := fingerprintBefore(f, s.Offset)
+	return err == nil && fingerprint == s.Fingerprint
+}
+
+// read parses path from where from left off and appends the entries worth
+// searching to log.
+func (idx *historyIndex) read(shell, path string, parse historyParser, from indexSource, log *bytes.Buffer) (indexSource, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return from, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return from, err
+	}
+	if _, err := f.Seek(from.Offset, io.SeekStart); err != nil {
+		return from, err
+	}
+	entries, settled, err := parse(f)
+	if err != nil {
+		return from, err
+	}
+
+	next := from
+	next.Path = path
+	next.Size = info.Size()
+	next.ModTime = info.ModTime().UnixNano()
+	next.Offset += settled
+	encoder := json.NewEncoder(log)
+	for _, entry := range entries {
+		order := next.NextOrder
+		next.NextOrder++
+		entry, _, ok := searchableEntry(entry, idx.header.SkipSecrets)
+		if !ok {
+			continue
+		}
+		if err := encoder.Encode(indexRecord{
+			Source:    shell,
+			Command:   entry.Command,
+			Timestamp: entry.Timestamp.Unix(),
+			Redacted:  entry.Redacted,
+			Approx:    entry.approxTS,
+			ViaEW:     entry.ViaEW,
+			Order:     order,
+		}); err != nil {
+			return from, err
+		}
+	}
+	if next.Fingerprint, err = fingerprintBefore(f, next.Offset); err != nil {
+		return from, err
+	}
+	return next, nil
+}
+
+// fingerprintBefore hashes up to indexFingerprintBytes of f ending at
+// offset.
+func fingerprintBefore(f *os.File, offset int64) (string, error) {
+	start := max(offset-indexFingerprintBytes, 0)
+	buf := make([]byte, offset-start)
+	if _, err := f.ReadAt(buf, start); err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// entries reads the log back, keeping the newest run of each command. A
+// log that has grown well past the commands it holds is rewritten without
+// the older runs.
+func (idx *historyIndex) entries() ([]Entry, error) {
+	records, err := idx.backend.Records(indexLogName)
+	if err != nil {
+		return nil, fmt.Errorf("could not read history index: %w", err)
+	}
+	// Orders count up through the historySources in turn, as if the files
+	// were read one after another.
+	base := make(map[string]int, len(historySources))
+	total := 0
+	for _, src := range historySources {
+		base[src.shell] = total
+		total += idx.header.Sources[src.shell].NextOrder
+	}
+
+	latest := make(map[string]Entry)
+	lines := 0
+	for _, line := range records {
+		var record indexRecord
+		if json.Unmarshal(line, &record) != nil || record.Command == "" {
+			continue
+		}
+		lines++
+		entry := Entry{
+			Command:   record.Command,
+			Timestamp: time.Unix(record.Timestamp, 0).UTC(),
+			Source:    record.Source,
+			Redacted:  record.Redacted,
+			order:     base[record.Source] + record.Order,
+			ViaEW:     record.ViaEW,
+			approxTS:  record.Approx,
+		}
+		keepLatest(latest, strings.ToLower(entry.Command), entry)
+	}
+
+	out := make([]Entry, 0, len(latest))
+	for _, entry := range latest {
+		out = append(out, entry)
+	}
+	if lines > 2*len(out)+256 {
+		_ = idx.compact(out, base)
+	}
+	return out, nil
+}
+
+// compact replaces the log with entries, one line per command.
+func (idx *historyIndex) compact(entries []Entry, base map[string]int) error {
+	var log bytes.Buffer
+	encoder := json.NewEncoder(&log)
+	for _, entry := range entries {
+		if err := encoder.Encode(indexRecord{
+			Source:    entry.Source,
+			Command:   entry.Command,
+			Timestamp: entry.Timestamp.Unix(),
+			Redacted:  entry.Redacted,
+			Approx:    entry.approxTS,
+			ViaEW:     entry.ViaEW,
+			Order:     entry.order - base[entry.Source],
+		}); err != nil {
+			return err
+		}
+	}
+	return idx.backend.Replace(indexLogName, splitRecords(log.Bytes()))
+}
+
+// appendLog adds the JSON lines in log to the index log. The log is never
+// trimmed by the backend; entries compacts it instead.
+func (idx *historyIndex) appendLog(log []byte) error {
+	for _, record := range splitRecords(log) {
+		if err := idx.backend.Append(indexLogName, record, 0); err != nil {
+			return fmt.Errorf("could not write history index: %w", err)
+		}
+	}
+	return nil
+}
+
+// splitRecords turns JSON lines into the records the state backend keeps.
+func splitRecords(log []byte) [][]byte {
+	var records [][]byte
+	for _, line := range bytes.Split(log, []byte("\n")) {
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			records = append(records, line)
+		}
+	}
+	return records
+}
+
+func (idx *historyIndex) saveHeader() error {
+	payload, err := json.Marshal(idx.header)
+	if err != nil {
+		return fmt.Errorf("could not encode history index: %w", err)
+	}
+	return idx.backend.Write(indexFileName, payload)
+}
@@ -3,6 +3,7 @@ package history
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -113,3 +114,49 @@ func TestLoadBashHistoryFallbackTimestampsPreserveCommandOrder(t *testing.T) {
 		t.Fatalf("expected newer command to have newer timestamp; got %s then %s", entries[0].Timestamp.Format(time.RFC3339), entries[1].Timestamp.Format(time.RFC3339))
 	}
 }
+
+func TestParseNuHistoryDecodesNewlines(t *testing.T) {
+	entries, settled, err := parseNuHistory(strings.NewReader("ls | sort-by size\nfor x in [1 2] {<\\n>  print $x<\\n>}\ngit sta"))
+	if err != nil {
+		t.Fatalf("parseNuHistory failed: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %+v", entries)
+	}
+	if entries[1].Command != "for x in [1 2] {\n  print $x\n}" || entries[1].Source != "nu" {
+		t.Fatalf("expected <\\n> decoded, got %q", entries[1].Command)
+	}
+	if !entries[0].Timestamp.Before(entries[2].Timestamp) || !entries[0].approxTS {
+		t.Fatalf("expected approximate timestamps in file order, got %+v", entries)
+	}
+	if want := int64(len("ls | sort-by size\nfor x in [1 2] {<\\n>  print $x<\\n>}\n")); settled != want {
+		t.Fatalf("expected the unfinished last line to stay unsettled: settled %d, want %d", settled, want)
+	}
+}
+
+func TestParsePowerShellHistoryJoinsBacktickContinuations(t *testing.T) {
+	content := "Get-ChildItem\nGet-Process |`\n  Where-Object CPU -gt 10\nStart-Job `\n"
+	entries, settled, err := parsePowerShellHistory(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("parsePowerShellHistory failed: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %+v", entries)
+	}
+	if entries[1].Command != "Get-Process |\n  Where-Object CPU -gt 10" || entries[1].Source != "powershell" {
+		t.Fatalf("expected the continuation joined, got %q", entries[1].Command)
+	}
+	if want := int64(len("Get-ChildItem\nGet-Process |`\n  Where-Object CPU -gt 10\n")); settled != want {
+		t.Fatalf("expected a command still being continued to stay unsettled: settled %d, want %d", settled, want)
+	}
+}
+
+func TestLoadFishHistoryUnescapesCommands(t *testing.T) {
+	entries, _, err := parseFishHistory(strings.NewReader("- cmd: echo \"a\\\\b\"\\necho done\n  when: 1700000000\n"))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected 1 fish entry, got %+v (err=%v)", entries, err)
+	}
+	if entries[0].Command != "echo \"a\\b\"\necho done" {
+		t.Fatalf("expected fish escapes undone, got %q", entries[0].Command)
+	}
+}
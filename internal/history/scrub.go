@@ -0,0 +1,265 @@
+package history
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ashwch/ew/internal/safety"
+)
+
+// Placeholder kinds a Scrubber numbers: <user1>, <host1>, <project1>,
+// <path1>.
+const (
+	scrubUser    = "user"
+	scrubHost    = "host"
+	scrubProject = "project"
+	scrubPath    = "path"
+)
+
+var (
+	scrubURL      = regexp.MustCompile(`\b([a-zA-Z][a-zA-Z0-9+.-]*://)(?:([^@/\s'"]+)@)?([^/:\s'"]+)(:\d+)?(/[^\s'"?#]*)?`)
+	scrubUserHost = regexp.MustCompile(`\b([A-Za-z_][A-Za-z0-9_.-]*)@([A-Za-z0-9][A-Za-z0-9.-]*[A-Za-z0-9])`)
+	scrubIPv4     = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+	scrubVersion  = regexp.MustCompile(`^[vV]?\d+(?:\.\d+)*(?:[-+][0-9A-Za-z.-]*)?$`)
+	scrubDomain   = regexp.MustCompile(`\b[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?(?:\.[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?)+\b`)
+	// scrubPathToken is an absolute, home, or ./ path after the start of
+	// the text, a space, a quote, or =.
+	scrubPathToken = regexp.MustCompile(`(^|[\s'"=])((?:~|\.\.?)?/[^\s'"` + "`" + `;|&<>()]*)`)
+)
+
+// scrubKeep are names that say nothing about the person: shared accounts,
+// public hosts, and the directories every machine has. They are left as
+// they are so a scrubbed command still reads like the original.
+var scrubKeep = map[string]bool{
+	"git": true, "root": true, "localhost": true, "127.0.0.1": true, "0.0.0.0": true,
+	"github.com": true, "gitlab.com": true, "bitbucket.org": true, "docker.io": true,
+	"registry.npmjs.org": true, "pypi.org": true, "ghcr.io": true, "quay.io": true,
+	"": true, ".": true, "..": true, "~": true,
+	"users": true, "home": true, "usr": true, "bin": true, "sbin": true, "etc": true, "var": true,
+	"tmp": true, "opt": true, "lib": true, "local": true, "share": true, "dev": true, "proc": true,
+	"sys": true, "mnt": true, "volumes": true, "private": true, "library": true, "applications": true,
+	"documents": true, "downloads": true, "desktop": true, "src": true, "code": true, "log": true,
+	"logs": true, "cache": true, ".config": true, ".ssh": true, ".local": true, ".cache": true,
+	"node_modules": true, "homebrew": true, "cellar": true,
+}
+
+// scrubTLDs end a word that is a host name rather than a file name.
+var scrubTLDs = map[string]bool{
+	"com": true, "net": true, "org": true, "io": true, "dev": true, "internal": true, "local": true,
+	"lan": true, "corp": true, "cloud": true, "app": true, "ai": true, "co": true, "us": true,
+	"uk": true, "de": true, "in": true,
+}
+
+// Scrubber replaces what identifies a person, machine, or project in
+// history text with numbered placeholders. The same name always gets the
+// same placeholder, so scrubbed commands still show which of them share a
+// host or a path. Secrets are redacted first.
+type Scrubber struct {
+	names        []scrubName
+	placeholders map[string]string
+	counts       map[string]int
+}
+
+type scrubName struct {
+	text string
+	kind string
+}
+
+// NewScrubber scrubs the names in users, hosts, and projects, such as the
+// current repository's, wherever they appear as words. Names shorter than
+// three letters and names in scrubKeep are ignored.
+func NewScrubber(users, hosts, projects []string) *Scrubber {
+	s := &Scrubber{placeholders: map[string]string{}, counts: map[string]int{}}
+	add := func(text, kind string) {
+		text = strings.TrimSpace(text)
+		if len(text) < 3 || scrubKeep[strings.ToLower(text)] {
+			return
+		}
+		s.names = append(s.names, scrubName{text: text, kind: kind})
+	}
+	for _, user := range users {
+		add(user, scrubUser)
+	}
+	for _, host := range hosts {
+		add(host, scrubHost)
+		if short, _, ok := strings.Cut(host, "."); ok {
+			add(short, scrubHost)
+		}
+	}
+	for _, project := range projects {
+		add(project, scrubProject)
+	}
+	// Longer names first, so "acme-api" is not scrubbed as "acme" + "-api".
+	sort.SliceStable(s.names, func(i, j int) bool {
+		return len(s.names[i].text) > len(s.names[j].text)
+	})
+	return s
+}
+
+// Scrub returns text with secrets redacted and user names, hosts, project
+// names, and path components replaced by placeholders.
+func (s *Scrubber) Scrub(text string) string {
+	text = safety.RedactText(text)
+	text = scrubURL.ReplaceAllStringFunc(text, func(match string) string {
+		parts := scrubURL.FindStringSubmatch(match)
+		out := parts[1]
+		if parts[2] != "" {
+			user, password, ok := strings.Cut(parts[2], ":")
+			out += s.placeholder(scrubUser, user)
+			if ok && password != "" {
+				out += ":" + safety.RedactedMarker
+			}
+			out += "@"
+		}
+		return out + s.placeholder(scrubHost, parts[3]) + parts[4] + s.scrubPath(parts[5])
+	})
+	text = scrubUserHost.ReplaceAllStringFunc(text, func(match string) string {
+		parts := scrubUserHost.FindStringSubmatch(match)
+		if isVersion(parts[2]) {
+			return match
+		}
+		return s.placeholder(scrubUser, parts[1]) + "@" + s.placeholder(scrubHost, parts[2])
+	})
+	text = scrubPathToken.ReplaceAllStringFunc(text, func(match string) string {
+		parts := scrubPathToken.FindStringSubmatch(match)
+		return parts[1] + s.scrubPath(parts[2])
+	})
+	text = scrubIPv4.ReplaceAllStringFunc(text, func(match string) string {
+		return s.placeholder(scrubHost, match)
+	})
+	text = scrubDomain.ReplaceAllStringFunc(text, func(match string) string {
+		if !looksLikeHost(match) {
+			return match
+		}
+		return s.placeholder(scrubHost, match)
+	})
+	for _, name := range s.names {
+		text = replaceWord(text, name.text, func() string { return s.placeholder(name.kind, name.text) })
+	}
+	return text
+}
+
+// scrubPath replaces each component of p that is not in scrubKeep. A known
+// name keeps its own placeholder, and a file keeps its extension, so
+// /Users/ana/acme/deploy.sh becomes /Users/<user1>/<project1>/<path1>.sh.
+func (s *Scrubber) scrubPath(p string) string {
+	parts := strings.Split(p, "/")
+	for idx, part := range parts {
+		if scrubKeep[strings.ToLower(part)] || isPlaceholder(part) {
+			continue
+		}
+		if name, ok := s.knownName(part); ok {
+			parts[idx] = s.placeholder(name.kind, name.text)
+			continue
+		}
+		ext := path.Ext(part)
+		if ext == part {
+			ext = ""
+		}
+		parts[idx] = s.placeholder(scrubPath, strings.TrimSuffix(part, ext)) + ext
+	}
+	return strings.Join(parts, "/")
+}
+
+func (s *Scrubber) knownName(text string) (scrubName, bool) {
+	for _, name := range s.names {
+		if strings.EqualFold(name.text, text) {
+			return name, true
+		}
+	}
+	return scrubName{}, false
+}
+
+// placeholder returns the placeholder for original, numbering a new one
+// for each name of a kind. Kept names and placeholders pass through.
+func (s *Scrubber) placeholder(kind, original string) string {
+	if scrubKeep[strings.ToLower(original)] || isPlaceholder(original) {
+		return original
+	}
+	if name, ok := s.knownName(original); ok {
+		kind, original = name.kind, name.text
+	}
+	key := kind + "\x00" + strings.ToLower(original)
+	if existing, ok := s.placeholders[key]; ok {
+		return existing
+	}
+	s.counts[kind]++
+	placeholder := fmt.Sprintf("<%s%d>", kind, s.counts[kind])
+	s.placeholders[key] = placeholder
+	return placeholder
+}
+
+func isPlaceholder(text string) bool {
+	return strings.HasPrefix(text, "<") && strings.HasSuffix(text, ">")
+}
+
+// isVersion reports whether the text after an @ is a package version, as
+// in tool@v1.2.3 or react@latest, rather than a host.
+func isVersion(text string) bool {
+	switch strings.ToLower(text) {
+	case "latest", "next", "main", "master", "head", "stable", "beta", "canary", "none":
+		return true
+	}
+	if scrubIPv4.MatchString(text) {
+		return false
+	}
+	return scrubVersion.MatchString(text)
+}
+
+// looksLikeHost tells a host name (db1.acme.internal, api.example.com)
+// from a file or version (deploy.tar.gz, v1.2.3): it ends in a known
+// top-level domain, or has three or more labels ending in letters that
+// are not a file extension.
+func looksLikeHost(word string) bool {
+	labels := strings.Split(strings.ToLower(word), ".")
+	last := labels[len(labels)-1]
+	if scrubTLDs[last] {
+		return true
+	}
+	if len(labels) < 3 || strings.Trim(last, "abcdefghijklmnopqrstuvwxyz") != "" {
+		return false
+	}
+	switch last {
+	case "gz", "tgz", "zip", "tar", "xz", "bz2", "json", "yaml", "yml", "toml", "txt", "log",
+		"go", "py", "js", "ts", "sh", "md", "conf", "cfg", "ini", "lock", "env", "min", "map":
+		return false
+	}
+	return true
+}
+
+// replaceWord replaces name in text, ignoring case, where it is not part
+// of a longer word, so the user "ana" does not touch "banana". replacement
+// is only called for a match, so names not in text are not numbered.
+func replaceWord(text, name string, replacement func() string) string {
+	lower, needle := strings.ToLower(text), strings.ToLower(name)
+	if len(lower) != len(text) || len(needle) != len(name) {
+		// Lowercasing changed the byte offsets; match case exactly.
+		lower, needle = text, name
+	}
+	var out strings.Builder
+	start := 0
+	for {
+		idx := strings.Index(lower[start:], needle)
+		if idx < 0 {
+			break
+		}
+		idx += start
+		end := idx + len(needle)
+		if (idx == 0 || !isWordByte(text[idx-1])) && (end == len(text) || !isWordByte(text[end])) {
+			out.WriteString(text[start:idx])
+			out.WriteString(replacement())
+		} else {
+			out.WriteString(text[start:end])
+		}
+		start = end
+	}
+	out.WriteString(text[start:])
+	return out.String()
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
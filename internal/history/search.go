@@ -2,22 +2,33 @@ package history
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"math"
 	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
 	"unicode"
 	"unicode/utf8"
+
+	"github.com/ashwch/ew/internal/safety"
 )
 
+// Entry is one history command. Redacted marks a command that carried a
+// secret, now replaced by <redacted>. ViaEW marks one that only ever got
+// into history through ew's write-back, with the Watermark removed.
 type Entry struct {
 	Command   string
 	Timestamp time.Time
 	Source    string
+	Redacted  bool
+	ViaEW     bool
 	order     int
 	approxTS  bool
 }
@@ -27,49 +38,153 @@ type Match struct {
 	Score     float64 `json:"score"`
 	Source    string  `json:"source"`
 	Timestamp string  `json:"timestamp,omitempty"`
+	Redacted  bool    `json:"redacted,omitempty"`
+	ViaEW     bool    `json:"via_ew,omitempty"`
+	// Signals breaks Score down by contribution when ranking signals beyond
+	// text relevance were applied; "text" is the base search score.
+	Signals map[string]float64 `json:"signals,omitempty"`
+}
+
+// Breakdown renders Signals as "text 7.20, time_of_day +1.50"; it is empty
+// when no extra signals were applied.
+func (m Match) Breakdown() string {
+	if len(m.Signals) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(m.Signals))
+	for name := range m.Signals {
+		if name != "text" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	parts := []string{fmt.Sprintf("text %.2f", m.Signals["text"])}
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s %+.2f", name, m.Signals[name]))
+	}
+	return strings.Join(parts, ", ")
 }
 
 const maxHistoryLineBytes = 1024 * 1024
 
+var (
+	// ErrNoHistory means no shell history file had entries.
+	ErrNoHistory = errors.New("no shell history found")
+	// ErrEmptyQuery is returned by Search for a blank query.
+	ErrEmptyQuery = errors.New("query cannot be empty")
+)
+
 var promptClockSuffix = regexp.MustCompile(`\s{2,}\d{1,2}:\d{2}$`)
 
-func LoadEntries() ([]Entry, error) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return nil, fmt.Errorf("could not determine home directory: %w", err)
-	}
+var skipSecrets atomic.Bool
 
-	var entries []Entry
-	nextOrder := 0
-	paths := []struct {
-		path   string
-		loader func(string) ([]Entry, error)
-	}{
-		{filepath.Join(home, ".zsh_history"), loadZshHistory},
-		{filepath.Join(home, ".bash_history"), loadBashHistory},
-		{filepath.Join(home, ".local", "share", "fish", "fish_history"), loadFishHistory},
+// Watermark ends the commands ew writes back to shell history, so search
+// can tell them from commands typed by hand and ew's own suggestions do not
+// keep ranking themselves up.
+const Watermark = "# via ew"
+
+// What search does with commands that carry the Watermark; the
+// history.via_ew setting.
+const (
+	ViaEWKeep     = "keep"
+	ViaEWDownrank = "downrank"
+	ViaEWSkip     = "skip"
+)
+
+// viaEWPenalty is taken off the score of a downranked ViaEW match, about
+// what a day's recency is worth.
+const viaEWPenalty = 4.0
+
+var viaEWPolicy atomic.Value
+
+// SetViaEW sets how search treats commands ew wrote back itself: one of
+// ViaEWKeep, ViaEWDownrank (the default), or ViaEWSkip.
+func SetViaEW(policy string) {
+	viaEWPolicy.Store(policy)
+}
+
+func currentViaEW() string {
+	if policy, ok := viaEWPolicy.Load().(string); ok && policy != "" {
+		return policy
 	}
+	return ViaEWDownrank
+}
 
-	for _, p := range paths {
-		if _, err := os.Stat(p.path); errors.Is(err, os.ErrNotExist) {
-			continue
-		}
-		loaded, err := p.loader(p.path)
-		if err != nil {
-			continue
+// semanticWindow is how many of the newest entries are compared by
+// meaning when a similarity is set; semanticWeight is what a similarity of
+// 1 adds to a match's score, enough on its own to pass find's threshold
+// from about 0.7 up.
+const (
+	semanticWindow = 300
+	semanticWeight = 12.0
+)
+
+var similarity atomic.Pointer[func(query string, texts []string) []float64]
+
+// SetSimilarity makes search also rank the newest entries by how close the
+// command is in meaning to the query; it is the embeddings.enabled
+// setting. fn returns a score in (0, 1] for each text close enough to
+// query and 0 for the rest, or nil when it cannot tell. A nil fn turns it
+// off.
+func SetSimilarity(fn func(query string, texts []string) []float64) {
+	if fn == nil {
+		similarity.Store(nil)
+		return
+	}
+	similarity.Store(&fn)
+}
+
+// similarTo scores the newest semanticWindow entries against query, or
+// returns nil when no similarity is set or it could not tell.
+func similarTo(query string, entries []Entry) []float64 {
+	fn := similarity.Load()
+	if fn == nil || len(entries) == 0 {
+		return nil
+	}
+	window := entries[:min(len(entries), semanticWindow)]
+	texts := make([]string, len(window))
+	for idx, entry := range window {
+		texts[idx] = entry.Command
+	}
+	scores := (*fn)(query, texts)
+	if len(scores) != len(window) {
+		return nil
+	}
+	return scores
+}
+
+// SetSkipSecrets makes history leave out commands that carry a secret
+// instead of keeping them with the secret redacted. It is the
+// history.secrets = "skip" setting.
+func SetSkipSecrets(skip bool) {
+	skipSecrets.Store(skip)
+}
+
+// LoadEntries reads every shell history it knows, newest first: zsh, bash,
+// fish, nushell, and PowerShell. It goes through the history index in the
+// state dir, so only what was appended since the last call is parsed, and
+// reads the files directly when the index cannot be used. A nushell
+// history.sqlite3 is read as well when the build can open it. It returns
+// ErrNoHistory when none of them has any entries, and ctx's error when ctx
+// is cancelled between files.
+func LoadEntries(ctx context.Context) ([]Entry, error) {
+	entries, err := loadIndexedEntries(ctx)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
 		}
-		for _, entry := range loaded {
-			entry.order = nextOrder
-			nextOrder++
-			entries = append(entries, entry)
+		if entries, err = loadHistoryFiles(ctx); err != nil {
+			return nil, err
 		}
+		entries = dedupeEntries(entries)
+	}
+	if extra, err := loadNuSQLiteHistory(ctx); err == nil {
+		entries = mergeEntries(entries, extra)
 	}
-
 	if len(entries) == 0 {
-		return nil, nil
+		return nil, ErrNoHistory
 	}
 
-	entries = dedupeEntries(entries)
 	sort.Slice(entries, func(i, j int) bool {
 		if entries[i].Timestamp.Equal(entries[j].Timestamp) {
 			return entries[i].order > entries[j].order
@@ -82,8 +197,58 @@ func LoadEntries() ([]Entry, error) {
 	return entries, nil
 }
 
-func LatestEntry(maxAge time.Duration) (*Entry, error) {
-	entries, err := LoadEntries()
+// LoadRuns reads every run of every command, in file order, cleaned up the
+// way LoadEntries cleans them. LoadEntries keeps only the latest run of a
+// command, so this is what counts how often something was run. It parses
+// the history files from the start rather than going through the index.
+func LoadRuns(ctx context.Context) ([]Entry, error) {
+	raw, err := loadHistoryFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	skip := skipSecrets.Load()
+	runs := make([]Entry, 0, len(raw))
+	for _, entry := range raw {
+		if entry, _, ok := searchableEntry(entry, skip); ok {
+			runs = append(runs, entry)
+		}
+	}
+	if len(runs) == 0 {
+		return nil, ErrNoHistory
+	}
+	return runs, nil
+}
+
+// loadHistoryFiles parses every history file from the start.
+func loadHistoryFiles(ctx context.Context) ([]Entry, error) {
+	var entries []Entry
+	nextOrder := 0
+	for _, src := range historySources {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		path, err := FilePath(src.shell)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		loaded, err := loadHistoryFile(path, src.parse)
+		if err != nil {
+			continue
+		}
+		for _, entry := range loaded {
+			entry.order = nextOrder
+			nextOrder++
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func LatestEntry(ctx context.Context, maxAge time.Duration) (*Entry, error) {
+	entries, err := LoadEntries(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -103,20 +268,43 @@ func LatestEntry(maxAge time.Duration) (*Entry, error) {
 	return nil, nil
 }
 
-func Search(query string, limit int) ([]Match, error) {
+// Page selects a window of the ranked matches; Offset counts from the best
+// match and Limit defaults to 8.
+type Page struct {
+	Offset int
+	Limit  int
+}
+
+// Results is one page of matches. Next is the Offset of the following page,
+// or 0 when this page is the last; Total counts every match.
+type Results struct {
+	Matches []Match
+	Next    int
+	Total   int
+}
+
+// Search returns the best limit matches for query.
+func Search(ctx context.Context, query string, limit int) ([]Match, error) {
+	results, err := SearchPage(ctx, query, Page{Limit: limit})
+	return results.Matches, err
+}
+
+// SearchPage ranks every history entry against query and returns the
+// window page asks for, so callers can browse past the first matches.
+func SearchPage(ctx context.Context, query string, page Page) (Results, error) {
 	if strings.TrimSpace(query) == "" {
-		return nil, fmt.Errorf("query cannot be empty")
+		return Results{}, ErrEmptyQuery
+	}
+	if page.Limit <= 0 {
+		page.Limit = 8
 	}
-	if limit <= 0 {
-		limit = 8
+	if page.Offset < 0 {
+		page.Offset = 0
 	}
 
-	entries, err := LoadEntries()
+	entries, err := LoadEntries(ctx)
 	if err != nil {
-		return nil, err
-	}
-	if len(entries) == 0 {
-		return nil, nil
+		return Results{}, err
 	}
 
 	queryLower := strings.ToLower(strings.TrimSpace(query))
@@ -124,18 +312,40 @@ func Search(query string, limit int) ([]Match, error) {
 
 	matches := make([]Match, 0, len(entries))
 	now := time.Now()
+	viaEW := currentViaEW()
+	similar := similarTo(query, entries)
 	for idx, entry := range entries {
+		if idx%1024 == 0 && ctx.Err() != nil {
+			return Results{}, ctx.Err()
+		}
+		if entry.ViaEW && viaEW == ViaEWSkip {
+			continue
+		}
 		cmdLower := strings.ToLower(entry.Command)
 		score := scoreCommand(queryLower, tokens, cmdLower, idx, now.Sub(entry.Timestamp))
-		if score <= 0 {
+		semantic := 0.0
+		if idx < len(similar) && similar[idx] > 0 {
+			semantic = similar[idx] * semanticWeight
+		}
+		if score <= 0 && semantic == 0 {
 			continue
 		}
-		matches = append(matches, Match{
+		score = max(score, 0) + semantic
+		if entry.ViaEW && viaEW == ViaEWDownrank {
+			score = max(score-viaEWPenalty, 0.1)
+		}
+		match := Match{
 			Command:   entry.Command,
 			Score:     score,
 			Source:    entry.Source,
 			Timestamp: entry.Timestamp.Format(time.RFC3339),
-		})
+			Redacted:  entry.Redacted,
+			ViaEW:     entry.ViaEW,
+		}
+		if semantic > 0 {
+			match.Signals = map[string]float64{"text": score - semantic, "semantic": semantic}
+		}
+		matches = append(matches, match)
 	}
 
 	sort.Slice(matches, func(i, j int) bool {
@@ -145,10 +355,25 @@ func Search(query string, limit int) ([]Match, error) {
 		return matches[i].Score > matches[j].Score
 	})
 
-	if len(matches) > limit {
-		matches = matches[:limit]
-	}
-	return matches, nil
+	results := Results{Matches: []Match{}, Total: len(matches)}
+	if page.Offset >= len(matches) {
+		return results, nil
+	}
+	end := page.Offset + page.Limit
+	if end < len(matches) {
+		results.Next = end
+	} else {
+		end = len(matches)
+	}
+	results.Matches = matches[page.Offset:end]
+	return results, nil
+}
+
+// ScoreCommand is the score command would get for query as an old history
+// entry, so without the recency bonuses; 0 means it does not match.
+func ScoreCommand(query, command string) float64 {
+	queryLower := strings.ToLower(strings.TrimSpace(query))
+	return scoreCommand(queryLower, splitTokens(queryLower), strings.ToLower(command), math.MaxInt, time.Duration(math.MaxInt64))
 }
 
 func scoreCommand(query string, tokens []string, cmd string, recencyIndex int, age time.Duration) float64 {
@@ -284,26 +509,11 @@ func splitTokens(query string) []string {
 func dedupeEntries(entries []Entry) []Entry {
 	latestByCommand := make(map[string]Entry, len(entries))
 	for _, entry := range entries {
-		cmd := normalizeHistoryCommand(entry.Command)
-		if cmd == "" {
-			continue
-		}
-		if isSensitiveCommand(cmd) {
-			continue
-		}
-		if isLikelyShellOutput(cmd) {
+		entry, key, ok := searchableEntry(entry, skipSecrets.Load())
+		if !ok {
 			continue
 		}
-		if isInternalCommand(cmd) {
-			continue
-		}
-		key := strings.ToLower(cmd)
-		entry.Command = cmd
-
-		current, ok := latestByCommand[key]
-		if !ok || entry.Timestamp.After(current.Timestamp) || (entry.Timestamp.Equal(current.Timestamp) && entry.order > current.order) {
-			latestByCommand[key] = entry
-		}
+		keepLatest(latestByCommand, key, entry)
 	}
 	out := make([]Entry, 0, len(latestByCommand))
 	for _, entry := range latestByCommand {
@@ -312,6 +522,52 @@ func dedupeEntries(entries []Entry) []Entry {
 	return out
 }
 
+// searchableEntry cleans entry up for search and returns it with its dedupe
+// key, or false when it should not be searched at all. skip leaves out
+// commands with a secret instead of redacting them.
+func searchableEntry(entry Entry, skip bool) (Entry, string, bool) {
+	cmd := normalizeHistoryCommand(entry.Command)
+	if marked, ok := strings.CutSuffix(cmd, Watermark); ok {
+		cmd = strings.TrimSpace(marked)
+		entry.ViaEW = true
+	}
+	if cmd == "" {
+		return Entry{}, "", false
+	}
+	if isSensitiveCommand(cmd) {
+		// The rest of a command such as curl -H 'Authorization: Bearer
+		// ...' https://api... is still worth finding. A secret the
+		// redaction rules cannot pin down drops the whole command.
+		redacted := safety.RedactText(cmd)
+		if skip || redacted == cmd {
+			return Entry{}, "", false
+		}
+		cmd = redacted
+		entry.Redacted = true
+	}
+	if isLikelyShellOutput(cmd) || isInternalCommand(cmd) {
+		return Entry{}, "", false
+	}
+	entry.Command = cmd
+	return entry, strings.ToLower(cmd), true
+}
+
+// keepLatest stores entry under key unless a newer run of the same command
+// is already there. A command counts as ViaEW only if every run of it was.
+func keepLatest(latest map[string]Entry, key string, entry Entry) {
+	current, ok := latest[key]
+	if !ok {
+		latest[key] = entry
+		return
+	}
+	viaEW := current.ViaEW && entry.ViaEW
+	if entry.Timestamp.After(current.Timestamp) || (entry.Timestamp.Equal(current.Timestamp) && entry.order > current.order) {
+		current = entry
+	}
+	current.ViaEW = viaEW
+	latest[key] = current
+}
+
 func normalizeHistoryCommand(command string) string {
 	cmd := strings.TrimSpace(command)
 	if cmd == "" {
@@ -324,17 +580,43 @@ func normalizeHistoryCommand(command string) string {
 	return cmd
 }
 
+// historyParser reads a shell's history from r. Besides the entries it
+// returns how many bytes of r are settled: an unfinished last line, or a
+// record that may still grow, is parsed but not counted, so the index reads
+// it again next time.
+type historyParser func(r io.Reader) ([]Entry, int64, error)
+
 func loadZshHistory(path string) ([]Entry, error) {
+	return loadHistoryFile(path, parseZshHistory)
+}
+
+func loadBashHistory(path string) ([]Entry, error) {
+	return loadHistoryFile(path, parseBashHistory)
+}
+
+func loadFishHistory(path string) ([]Entry, error) {
+	return loadHistoryFile(path, parseFishHistory)
+}
+
+func loadHistoryFile(path string, parse historyParser) ([]Entry, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
+	entries, _, err := parse(f)
+	return entries, err
+}
 
+func parseZshHistory(r io.Reader) ([]Entry, int64, error) {
 	var entries []Entry
+	var settled int64
 	untimedIndexes := make([]int, 0, 32)
-	scanner := newHistoryScanner(f)
+	scanner := newHistoryScanner(r)
 	for scanner.Scan() {
+		if scanner.complete {
+			settled = scanner.end
+		}
 		line := strings.TrimSpace(scanner.Text())
 		if line == "" {
 			continue
@@ -367,7 +649,7 @@ func loadZshHistory(path string) ([]Entry, error) {
 		}
 	}
 	if err := scanner.Err(); err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	if len(untimedIndexes) > 0 {
@@ -377,18 +659,13 @@ func loadZshHistory(path string) ([]Entry, error) {
 			entries[idx].approxTS = true
 		}
 	}
-	return entries, nil
+	return entries, settled, nil
 }
 
-func loadBashHistory(path string) ([]Entry, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-
+func parseBashHistory(r io.Reader) ([]Entry, int64, error) {
 	var entries []Entry
-	scanner := newHistoryScanner(f)
+	var settled int64
+	scanner := newHistoryScanner(r)
 	untimedIndexes := make([]int, 0, 32)
 	var pendingTimestamp time.Time
 	for scanner.Scan() {
@@ -404,6 +681,11 @@ func loadBashHistory(path string) ([]Entry, error) {
 			}
 			continue
 		}
+		// A timestamp comment belongs to the command after it, so it is
+		// only settled together with that command.
+		if scanner.complete {
+			settled = scanner.end
+		}
 		timestamp := pendingTimestamp
 		pendingTimestamp = time.Time{}
 		approx := false
@@ -419,7 +701,7 @@ func loadBashHistory(path string) ([]Entry, error) {
 		}
 	}
 	if err := scanner.Err(); err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	if len(untimedIndexes) > 0 {
@@ -428,18 +710,13 @@ func loadBashHistory(path string) ([]Entry, error) {
 			entries[idx].Timestamp = start.Add(time.Duration(i) * time.Second)
 		}
 	}
-	return entries, nil
+	return entries, settled, nil
 }
 
-func loadFishHistory(path string) ([]Entry, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-
+func parseFishHistory(r io.Reader) ([]Entry, int64, error) {
 	var entries []Entry
-	scanner := newHistoryScanner(f)
+	var settled int64
+	scanner := newHistoryScanner(r)
 	currentCommand := ""
 	currentTimestamp := time.Time{}
 	flush := func() {
@@ -464,8 +741,11 @@ func loadFishHistory(path string) ([]Entry, error) {
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
 		if strings.HasPrefix(line, "- cmd:") {
+			// Everything before the newest record is settled; its own
+			// when: and paths: lines may not be written yet.
+			settled = scanner.start
 			flush()
-			currentCommand = strings.TrimSpace(strings.TrimPrefix(line, "- cmd:"))
+			currentCommand = unescapeFish(strings.TrimSpace(strings.TrimPrefix(line, "- cmd:")))
 			continue
 		}
 		if strings.HasPrefix(line, "when:") {
@@ -475,10 +755,35 @@ func loadFishHistory(path string) ([]Entry, error) {
 		}
 	}
 	if err := scanner.Err(); err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	flush()
-	return entries, nil
+	return entries, settled, nil
+}
+
+// unescapeFish undoes the escaping fish applies to a command in
+// fish_history: a newline is written as \n and a backslash as \\.
+func unescapeFish(command string) string {
+	if !strings.Contains(command, `\`) {
+		return command
+	}
+	var b strings.Builder
+	for i := 0; i < len(command); i++ {
+		if command[i] == '\\' && i+1 < len(command) {
+			switch command[i+1] {
+			case 'n':
+				b.WriteByte('\n')
+				i++
+				continue
+			case '\\':
+				b.WriteByte('\\')
+				i++
+				continue
+			}
+		}
+		b.WriteByte(command[i])
+	}
+	return b.String()
 }
 
 func parseUnix(s string) (int64, error) {
@@ -760,8 +1065,25 @@ func historyIsEnvAssignmentToken(token string) bool {
 	return strings.IndexAny(token[:eq], "/\\") == -1
 }
 
-func newHistoryScanner(f *os.File) *bufio.Scanner {
-	scanner := bufio.NewScanner(f)
-	scanner.Buffer(make([]byte, 0, 64*1024), maxHistoryLineBytes)
-	return scanner
+// historyScanner is a line scanner that knows where each line sits in the
+// input: start and end are the byte offsets of the current line, newline
+// included, and complete says whether the newline was there.
+type historyScanner struct {
+	*bufio.Scanner
+	start, end int64
+	complete   bool
+}
+
+func newHistoryScanner(r io.Reader) *historyScanner {
+	s := &historyScanner{Scanner: bufio.NewScanner(r)}
+	s.Buffer(make([]byte, 0, 64*1024), maxHistoryLineBytes)
+	s.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		if advance > 0 {
+			s.start, s.end = s.end, s.end+int64(advance)
+			s.complete = data[advance-1] == '\n'
+		}
+		return advance, token, err
+	})
+	return s
 }
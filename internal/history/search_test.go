@@ -1,6 +1,8 @@
 package history
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -59,6 +61,30 @@ func TestDedupeEntriesSkipsInternalEWCommands(t *testing.T) {
 	}
 }
 
+func TestDedupeEntriesRedactsSecretsAndKeepsTheCommand(t *testing.T) {
+	now := time.Now().UTC()
+	entries := []Entry{
+		{Command: "curl -H 'Authorization: Bearer abc123' https://api.example.com/v1/users", Timestamp: now, Source: "zsh"},
+		{Command: "curl -H 'Authorization: Bearer def456' https://api.example.com/v1/users", Timestamp: now.Add(-time.Minute), Source: "zsh"},
+		{Command: "ssh-add ~/.ssh/private_key", Timestamp: now.Add(-2 * time.Minute), Source: "zsh"},
+	}
+
+	deduped := dedupeEntries(entries)
+	if len(deduped) != 1 {
+		t.Fatalf("expected one redacted curl and no unredactable entry, got %+v", deduped)
+	}
+	want := "curl -H 'Authorization: Bearer <redacted>' https://api.example.com/v1/users"
+	if deduped[0].Command != want || !deduped[0].Redacted || !deduped[0].Timestamp.Equal(now) {
+		t.Fatalf("expected the newest curl redacted, got %+v", deduped[0])
+	}
+
+	SetSkipSecrets(true)
+	t.Cleanup(func() { SetSkipSecrets(false) })
+	if deduped := dedupeEntries(entries); len(deduped) != 0 {
+		t.Fatalf("expected skip to drop secret-bearing commands, got %+v", deduped)
+	}
+}
+
 func TestDedupeEntriesSkipsLikelyShellOutputLines(t *testing.T) {
 	now := time.Now().UTC()
 	entries := []Entry{
@@ -232,7 +258,7 @@ func TestLatestEntryReturnsMostRecentNonInternalCommand(t *testing.T) {
 		t.Fatalf("write zsh history failed: %v", err)
 	}
 
-	latest, err := LatestEntry(5 * time.Minute)
+	latest, err := LatestEntry(context.Background(), 5*time.Minute)
 	if err != nil {
 		t.Fatalf("LatestEntry failed: %v", err)
 	}
@@ -255,7 +281,7 @@ func TestLatestEntryReturnsNilWhenOlderThanMaxAge(t *testing.T) {
 		t.Fatalf("write zsh history failed: %v", err)
 	}
 
-	latest, err := LatestEntry(5 * time.Minute)
+	latest, err := LatestEntry(context.Background(), 5*time.Minute)
 	if err != nil {
 		t.Fatalf("LatestEntry failed: %v", err)
 	}
@@ -274,7 +300,7 @@ func TestLatestEntrySkipsApproximateTimestamps(t *testing.T) {
 		t.Fatalf("write zsh history failed: %v", err)
 	}
 
-	latest, err := LatestEntry(5 * time.Minute)
+	latest, err := LatestEntry(context.Background(), 5*time.Minute)
 	if err != nil {
 		t.Fatalf("LatestEntry failed: %v", err)
 	}
@@ -286,3 +312,172 @@ func TestLatestEntrySkipsApproximateTimestamps(t *testing.T) {
 func formatUnix(ts int64) string {
 	return strconv.FormatInt(ts, 10)
 }
+
+func TestSearchReportsMissingHistoryAndEmptyQuery(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if _, err := Search(context.Background(), "docker logs", 5); !errors.Is(err, ErrNoHistory) {
+		t.Fatalf("expected ErrNoHistory, got %v", err)
+	}
+	if _, err := LatestEntry(context.Background(), time.Hour); !errors.Is(err, ErrNoHistory) {
+		t.Fatalf("expected ErrNoHistory from LatestEntry, got %v", err)
+	}
+	if _, err := Search(context.Background(), "  ", 5); !errors.Is(err, ErrEmptyQuery) {
+		t.Fatalf("expected ErrEmptyQuery, got %v", err)
+	}
+}
+
+func TestSearchStopsWhenContextIsCancelled(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.WriteFile(filepath.Join(home, ".bash_history"), []byte("docker logs web\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Search(ctx, "docker logs", 5); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if matches, err := Search(context.Background(), "docker logs", 5); err != nil || len(matches) != 1 {
+		t.Fatalf("expected one match without cancellation, got %v %v", matches, err)
+	}
+}
+
+func TestSearchPageWalksEveryMatchOnce(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	lines := []string{}
+	for i := 0; i < 5; i++ {
+		lines = append(lines, "docker logs web-"+strconv.Itoa(i))
+	}
+	if err := os.WriteFile(filepath.Join(home, ".bash_history"), []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	seen := map[string]bool{}
+	page := Page{Limit: 2}
+	for pages := 0; ; pages++ {
+		results, err := SearchPage(context.Background(), "docker logs", page)
+		if err != nil {
+			t.Fatalf("SearchPage failed: %v", err)
+		}
+		if results.Total != 5 {
+			t.Fatalf("expected total 5, got %d", results.Total)
+		}
+		for _, match := range results.Matches {
+			if seen[match.Command] {
+				t.Fatalf("match %q returned twice", match.Command)
+			}
+			seen[match.Command] = true
+		}
+		if results.Next == 0 {
+			if pages != 2 {
+				t.Fatalf("expected three pages of two, got %d", pages+1)
+			}
+			break
+		}
+		page.Offset = results.Next
+	}
+	if len(seen) != 5 {
+		t.Fatalf("expected every match once, got %v", seen)
+	}
+
+	past, err := SearchPage(context.Background(), "docker logs", Page{Offset: 10, Limit: 2})
+	if err != nil || len(past.Matches) != 0 || past.Next != 0 {
+		t.Fatalf("expected an empty last page past the end, got %+v %v", past, err)
+	}
+}
+
+func TestSearchDownranksOrSkipsWatermarkedCommands(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, "state"))
+	t.Cleanup(func() { SetViaEW("") })
+	content := strings.Join([]string{
+		"#1700000000",
+		"docker compose logs web",
+		"#1700000100",
+		"docker compose logs api  " + Watermark,
+		"#1700000200",
+		"docker compose logs db  " + Watermark,
+		"#1700000300",
+		"docker compose logs db",
+		"",
+	}, "\n")
+	if err := os.WriteFile(filepath.Join(home, ".bash_history"), []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	search := func(policy string) []Match {
+		t.Helper()
+		SetViaEW(policy)
+		matches, err := Search(context.Background(), "docker compose logs", 8)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		return matches
+	}
+
+	kept := search(ViaEWKeep)
+	if len(kept) != 3 || kept[1].Command != "docker compose logs api" || !kept[1].ViaEW {
+		t.Fatalf("expected the watermark stripped and the command flagged, got %+v", kept)
+	}
+	for _, match := range kept {
+		if match.Command == "docker compose logs db" && match.ViaEW {
+			t.Fatalf("expected a command also typed by hand not to count as via ew")
+		}
+	}
+	downranked := search(ViaEWDownrank)
+	if last := downranked[len(downranked)-1]; last.Command != "docker compose logs api" || last.Score >= kept[1].Score {
+		t.Fatalf("expected the via-ew command ranked last with a lower score, got %+v", downranked)
+	}
+	skipped := search(ViaEWSkip)
+	if len(skipped) != 2 {
+		t.Fatalf("expected the via-ew command left out, got %+v", skipped)
+	}
+}
+
+func TestSearchAddsSemanticMatches(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, "state"))
+	t.Cleanup(func() { SetSimilarity(nil) })
+	content := "lsof -ti :3000 | xargs kill\ngit status\n"
+	if err := os.WriteFile(filepath.Join(home, ".bash_history"), []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if matches, err := Search(context.Background(), "free up the dev server", 8); err != nil || len(matches) != 0 {
+		t.Fatalf("expected no word matches, got %+v %v", matches, err)
+	}
+	SetSimilarity(func(query string, texts []string) []float64 {
+		scores := make([]float64, len(texts))
+		for idx, text := range texts {
+			if strings.HasPrefix(text, "lsof") {
+				scores[idx] = 0.8
+			}
+		}
+		return scores
+	})
+	matches, err := Search(context.Background(), "free up the dev server", 8)
+	if err != nil || len(matches) != 1 || matches[0].Command != "lsof -ti :3000 | xargs kill" {
+		t.Fatalf("expected the semantic match, got %+v %v", matches, err)
+	}
+	if got := matches[0].Signals["semantic"]; got < 0.79*semanticWeight || got > 0.81*semanticWeight || matches[0].Breakdown() == "" {
+		t.Fatalf("expected the semantic signal in the breakdown, got %+v", matches[0])
+	}
+}
+
+func TestLoadRunsKeepsRepeatedCommands(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.WriteFile(filepath.Join(home, ".bash_history"), []byte("make lint\nmake lint\ngit status\n"), 0o600); err != nil {
+		t.Fatalf("write history failed: %v", err)
+	}
+	runs, err := LoadRuns(context.Background())
+	if err != nil {
+		t.Fatalf("LoadRuns failed: %v", err)
+	}
+	if len(runs) != 3 || runs[0].Command != "make lint" || runs[1].Command != "make lint" {
+		t.Fatalf("expected every run kept, got %+v", runs)
+	}
+}
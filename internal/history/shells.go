@@ -0,0 +1,219 @@
+package history
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+// sqliteDriver is the database/sql driver name modernc.org/sqlite registers.
+// It is only linked into builds made with -tags sqlite; without it a
+// nushell history.sqlite3 is not read.
+const sqliteDriver = "sqlite"
+
+// nuNewline is how nushell's plaintext history stores a newline inside a
+// command.
+const nuNewline = `<\n>`
+
+// ShellName returns shell in the form the history functions and hook
+// snippets use: "nu" for nushell and "powershell" for pwsh too. Other
+// shells are returned lower-cased.
+func ShellName(shell string) string {
+	shell = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(shell), ".exe"))
+	switch shell {
+	case "nushell":
+		return "nu"
+	case "pwsh":
+		return "powershell"
+	}
+	return shell
+}
+
+// nuConfigDir is where nushell keeps its config and history.
+func nuConfigDir(home string) string {
+	switch runtime.GOOS {
+	case "darwin":
+		return filepath.Join(home, "Library", "Application Support", "nushell")
+	case "windows":
+		if appData := os.Getenv("APPDATA"); appData != "" {
+			return filepath.Join(appData, "nushell")
+		}
+		return filepath.Join(home, "AppData", "Roaming", "nushell")
+	}
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return filepath.Join(xdg, "nushell")
+	}
+	return filepath.Join(home, ".config", "nushell")
+}
+
+// powershellHistoryPath is the file PSReadLine saves history to, for both
+// Windows PowerShell and pwsh.
+func powershellHistoryPath(home string) string {
+	if runtime.GOOS == "windows" {
+		appData := os.Getenv("APPDATA")
+		if appData == "" {
+			appData = filepath.Join(home, "AppData", "Roaming")
+		}
+		return filepath.Join(appData, "Microsoft", "Windows", "PowerShell", "PSReadLine", "ConsoleHost_history.txt")
+	}
+	return filepath.Join(home, ".local", "share", "powershell", "PSReadLine", "ConsoleHost_history.txt")
+}
+
+// parseNuHistory reads nushell's plaintext history.txt: one command per
+// line, with newlines inside a command written as <\n>. It has no
+// timestamps.
+func parseNuHistory(r io.Reader) ([]Entry, int64, error) {
+	var entries []Entry
+	var settled int64
+	scanner := newHistoryScanner(r)
+	for scanner.Scan() {
+		if scanner.complete {
+			settled = scanner.end
+		}
+		command := strings.TrimSpace(strings.ReplaceAll(scanner.Text(), nuNewline, "\n"))
+		if command == "" {
+			continue
+		}
+		entries = append(entries, Entry{Command: command, Source: "nu"})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+	approximateTimestamps(entries)
+	return entries, settled, nil
+}
+
+// parsePowerShellHistory reads PSReadLine's ConsoleHost_history.txt: one
+// command per line, where a line ending in a backtick goes on to the next
+// one. It has no timestamps.
+func parsePowerShellHistory(r io.Reader) ([]Entry, int64, error) {
+	var entries []Entry
+	var settled int64
+	var pending []string
+	scanner := newHistoryScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if continued, ok := strings.CutSuffix(line, "`"); ok {
+			pending = append(pending, continued)
+			continue
+		}
+		// A command is settled once its last line is complete.
+		if scanner.complete {
+			settled = scanner.end
+		}
+		command := strings.TrimSpace(strings.Join(append(pending, line), "\n"))
+		pending = nil
+		if command == "" {
+			continue
+		}
+		entries = append(entries, Entry{Command: command, Source: "powershell"})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+	if command := strings.TrimSpace(strings.Join(pending, "\n")); command != "" {
+		entries = append(entries, Entry{Command: command, Source: "powershell"})
+	}
+	approximateTimestamps(entries)
+	return entries, settled, nil
+}
+
+// approximateTimestamps spaces entries from a file without timestamps a
+// second apart, ending now, so they keep their order.
+func approximateTimestamps(entries []Entry) {
+	start := time.Now().UTC().Add(-time.Duration(len(entries)) * time.Second)
+	for i := range entries {
+		entries[i].Timestamp = start.Add(time.Duration(i) * time.Second)
+		entries[i].approxTS = true
+	}
+}
+
+// nuHistoryEntry writes one line of nushell's plaintext history.
+func nuHistoryEntry(command string) string {
+	return strings.ReplaceAll(command, "\n", nuNewline) + "\n"
+}
+
+// powershellHistoryEntry writes one command the way PSReadLine does, each
+// line but the last ending in a backtick.
+func powershellHistoryEntry(command string) string {
+	return strings.ReplaceAll(command, "\n", "`\n") + "\n"
+}
+
+// loadNuSQLiteHistory reads nushell's history.sqlite3, used instead of
+// history.txt when nushell's history.file_format is "sqlite". It is read
+// whole every time rather than indexed, and is skipped when the file is
+// missing or this build has no SQLite driver.
+func loadNuSQLiteHistory(ctx context.Context) ([]Entry, error) {
+	if !slices.Contains(sql.Drivers(), sqliteDriver) {
+		return nil, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, nil
+	}
+	path := filepath.Join(nuConfigDir(home), "history.sqlite3")
+	if _, err := os.Stat(path); err != nil {
+		return nil, nil
+	}
+	db, err := sql.Open(sqliteDriver, "file:"+filepath.ToSlash(path)+"?mode=ro")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	rows, err := db.QueryContext(ctx, `SELECT command_line, start_timestamp FROM history ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		var command string
+		var started sql.NullInt64
+		if err := rows.Scan(&command, &started); err != nil {
+			return nil, err
+		}
+		entry := Entry{Command: command, Source: "nu", order: len(entries)}
+		if started.Valid && started.Int64 > 0 {
+			entry.Timestamp = time.UnixMilli(started.Int64).UTC()
+		} else {
+			entry.Timestamp = time.Now().UTC()
+			entry.approxTS = true
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// mergeEntries adds extra to entries, which already hold one entry per
+// command, keeping the newest run of each.
+func mergeEntries(entries, extra []Entry) []Entry {
+	if len(extra) == 0 {
+		return entries
+	}
+	latest := make(map[string]Entry, len(entries)+len(extra))
+	base := 0
+	for _, entry := range entries {
+		latest[strings.ToLower(entry.Command)] = entry
+		base = max(base, entry.order+1)
+	}
+	for _, entry := range extra {
+		entry, key, ok := searchableEntry(entry, skipSecrets.Load())
+		if !ok {
+			continue
+		}
+		entry.order += base
+		keepLatest(latest, key, entry)
+	}
+	out := make([]Entry, 0, len(latest))
+	for _, entry := range latest {
+		out = append(out, entry)
+	}
+	return out
+}
@@ -0,0 +1,149 @@
+package history
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// formatSniffBytes is how much of the end of a history file Append reads to
+// tell whether the shell is writing timestamps.
+const formatSniffBytes = 4096
+
+const formatSniffLines = 3
+
+// FilePath is where shell keeps its history: the same files LoadEntries
+// reads. For nushell it is the plaintext history.txt. It is "" for shells
+// ew does not know.
+func FilePath(shell string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not determine home directory: %w", err)
+	}
+	switch ShellName(shell) {
+	case "zsh":
+		return filepath.Join(home, ".zsh_history"), nil
+	case "bash":
+		return filepath.Join(home, ".bash_history"), nil
+	case "fish":
+		return filepath.Join(home, ".local", "share", "fish", "fish_history"), nil
+	case "nu":
+		return filepath.Join(nuConfigDir(home), "history.txt"), nil
+	case "powershell":
+		return powershellHistoryPath(home), nil
+	}
+	return "", nil
+}
+
+// Append adds command to shell's history file in the format that shell
+// writes, so the command shows up in the shell's own history search. zsh
+// and bash timestamps are only written when the file already has them. It
+// returns the path it wrote, or "" for shells ew does not know.
+func Append(shell, command string, when time.Time) (string, error) {
+	command = strings.TrimSpace(command)
+	if command == "" {
+		return "", nil
+	}
+	path, err := FilePath(shell)
+	if err != nil || path == "" {
+		return "", err
+	}
+
+	var entry string
+	switch shell = ShellName(shell); shell {
+	case "zsh":
+		entry = zshHistoryEntry(command, when, recentLinesMatch(path, func(line string) bool {
+			return strings.HasPrefix(line, ": ") && strings.Contains(line, ";")
+		}))
+	case "bash":
+		entry = bashHistoryEntry(command, when, recentLinesMatch(path, func(line string) bool {
+			_, err := parseUnix(strings.TrimPrefix(line, "#"))
+			return strings.HasPrefix(line, "#") && err == nil
+		}))
+	case "fish":
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return "", fmt.Errorf("could not create fish history dir: %w", err)
+		}
+		entry = fishHistoryEntry(command, when)
+	case "nu", "powershell":
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return "", fmt.Errorf("could not create %s history dir: %w", shell, err)
+		}
+		if shell == "nu" {
+			entry = nuHistoryEntry(command)
+		} else {
+			entry = powershellHistoryEntry(command)
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("could not open %s history: %w", shell, err)
+	}
+	if _, err := f.WriteString(entry); err != nil {
+		_ = f.Close()
+		return "", fmt.Errorf("could not write %s history: %w", shell, err)
+	}
+	return path, f.Close()
+}
+
+// zshHistoryEntry writes zsh's extended format (": <start>:<elapsed>;cmd")
+// when extended is set. zsh stores the newlines of a multi-line command
+// as a backslash at the end of each line.
+func zshHistoryEntry(command string, when time.Time, extended bool) string {
+	command = strings.ReplaceAll(command, "\n", "\\\n")
+	if extended {
+		return fmt.Sprintf(": %d:0;%s\n", when.Unix(), command)
+	}
+	return command + "\n"
+}
+
+// bashHistoryEntry precedes the command with a "#<epoch>" line when
+// timestamped is set, which is how bash writes HISTTIMEFORMAT history.
+func bashHistoryEntry(command string, when time.Time, timestamped bool) string {
+	if timestamped {
+		return fmt.Sprintf("#%d\n%s\n", when.Unix(), command)
+	}
+	return command + "\n"
+}
+
+// fishHistoryEntry writes one item of fish's YAML-like history, escaping
+// backslashes and newlines the way fish does.
+func fishHistoryEntry(command string, when time.Time) string {
+	escaped := strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(command)
+	return fmt.Sprintf("- cmd: %s\n  when: %d\n", escaped, when.Unix())
+}
+
+// recentLinesMatch reports whether any of the last few lines of path
+// satisfies match. Several lines are checked because a bash timestamp sits
+// above its command and a multi-line zsh command ends in continuations. A
+// missing or empty file matches nothing.
+func recentLinesMatch(path string, match func(line string) bool) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	offset := max(info.Size()-formatSniffBytes, 0)
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return false
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return false
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	for i := len(lines) - 1; i >= 0 && i >= len(lines)-formatSniffLines; i-- {
+		if match(strings.TrimSpace(lines[i])) {
+			return true
+		}
+	}
+	return false
+}
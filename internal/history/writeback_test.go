@@ -0,0 +1,103 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAppendMatchesEachShellsHistoryFormat(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	when := time.Unix(1700000000, 0)
+
+	zshPath := filepath.Join(home, ".zsh_history")
+	if err := os.WriteFile(zshPath, []byte(": 1699999999:0;git status\n"), 0o600); err != nil {
+		t.Fatalf("write zsh history failed: %v", err)
+	}
+	if _, err := Append("zsh", "for f in *; do\necho $f\ndone", when); err != nil {
+		t.Fatalf("Append zsh failed: %v", err)
+	}
+	assertFileContent(t, zshPath, ": 1699999999:0;git status\n: 1700000000:0;for f in *; do\\\necho $f\\\ndone\n")
+
+	bashPath := filepath.Join(home, ".bash_history")
+	if err := os.WriteFile(bashPath, []byte("ls\n"), 0o600); err != nil {
+		t.Fatalf("write bash history failed: %v", err)
+	}
+	if _, err := Append("bash", "make test", when); err != nil {
+		t.Fatalf("Append bash failed: %v", err)
+	}
+	assertFileContent(t, bashPath, "ls\nmake test\n")
+
+	if err := os.WriteFile(bashPath, []byte("#1699999999\nls\n"), 0o600); err != nil {
+		t.Fatalf("write bash history failed: %v", err)
+	}
+	if _, err := Append("bash", "make test", when); err != nil {
+		t.Fatalf("Append bash failed: %v", err)
+	}
+	assertFileContent(t, bashPath, "#1699999999\nls\n#1700000000\nmake test\n")
+
+	fishPath, err := Append("fish", `echo "a\b"`, when)
+	if err != nil {
+		t.Fatalf("Append fish failed: %v", err)
+	}
+	assertFileContent(t, fishPath, "- cmd: echo \"a\\\\b\"\n  when: 1700000000\n")
+
+	entries, err := loadFishHistory(fishPath)
+	if err != nil || len(entries) != 1 || entries[0].Timestamp.Unix() != 1700000000 {
+		t.Fatalf("expected the fish entry to load back, got %+v (err=%v)", entries, err)
+	}
+}
+
+func TestAppendWritesNuAndPowerShellHistory(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("APPDATA", filepath.Join(home, "AppData"))
+	when := time.Unix(1700000000, 0)
+
+	nuPath, err := Append("nu", "for x in [1 2] {\n  print $x\n}", when)
+	if err != nil {
+		t.Fatalf("Append nu failed: %v", err)
+	}
+	assertFileContent(t, nuPath, "for x in [1 2] {<\\n>  print $x<\\n>}\n")
+
+	psPath, err := Append("pwsh", "Get-Process |\n  Where-Object CPU -gt 10", when)
+	if err != nil {
+		t.Fatalf("Append powershell failed: %v", err)
+	}
+	if filepath.Base(psPath) != "ConsoleHost_history.txt" {
+		t.Fatalf("expected PSReadLine's history file, got %s", psPath)
+	}
+	assertFileContent(t, psPath, "Get-Process |`\n  Where-Object CPU -gt 10\n")
+
+	f, err := os.Open(psPath)
+	if err != nil {
+		t.Fatalf("open powershell history failed: %v", err)
+	}
+	defer f.Close()
+	entries, _, err := parsePowerShellHistory(f)
+	if err != nil || len(entries) != 1 || entries[0].Command != "Get-Process |\n  Where-Object CPU -gt 10" {
+		t.Fatalf("expected the powershell entry to load back, got %+v (err=%v)", entries, err)
+	}
+}
+
+func TestAppendIgnoresUnknownShells(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	path, err := Append("tcsh", "ls", time.Now())
+	if err != nil || path != "" {
+		t.Fatalf("expected unknown shells to be skipped, got %q (err=%v)", path, err)
+	}
+}
+
+func assertFileContent(t *testing.T, path, want string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s failed: %v", path, err)
+	}
+	if string(data) != want {
+		t.Fatalf("unexpected %s content:\n%q\nwant:\n%q", filepath.Base(path), data, want)
+	}
+}
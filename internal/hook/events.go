@@ -1,21 +1,27 @@
 package hook
 
 import (
-	"bufio"
 	"encoding/json"
 	"fmt"
-	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
-	"github.com/ashwch/ew/internal/appdirs"
 	"github.com/ashwch/ew/internal/safety"
+	"github.com/ashwch/ew/internal/state"
 )
 
 const eventsFileName = "events.jsonl"
 const maxCommandLength = 8192
 
+// maxEvents is how many captured commands the state backend keeps; fixes
+// and the prompt indicator only look at the newest ones.
+const maxEvents = 5000
+
+// MaxFailureAge is how old a captured failure can be before `ew` stops
+// treating it as the thing to fix.
+const MaxFailureAge = 60 * time.Minute
+
 type Event struct {
 	Command   string `json:"command"`
 	ExitCode  int    `json:"exit_code"`
@@ -23,6 +29,9 @@ type Event struct {
 	Shell     string `json:"shell"`
 	SessionID string `json:"session_id,omitempty"`
 	Timestamp string `json:"timestamp"`
+	// Output is the end of a failed command's stderr, when the zsh hook
+	// captures it (EW_CAPTURE_OUTPUT), with secrets redacted.
+	Output string `json:"output,omitempty"`
 }
 
 func RecordEvent(ev Event) error {
@@ -43,79 +52,131 @@ func RecordEvent(ev Event) error {
 	if len(ev.Command) > maxCommandLength {
 		ev.Command = ev.Command[:maxCommandLength]
 	}
+	ev.Output = clipOutput(strings.TrimSpace(safety.RedactText(ev.Output)))
 
-	if _, err := appdirs.EnsureStateDir(); err != nil {
-		return err
+	encoded, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("could not serialize event: %w", err)
 	}
-	path, err := appdirs.StateFilePath(eventsFileName)
+	backend, err := state.Current()
 	if err != nil {
 		return err
 	}
+	if err := backend.Append(eventsFileName, encoded, maxEvents); err != nil {
+		return fmt.Errorf("could not write event: %w", err)
+	}
+	return nil
+}
+
+func LatestFailure(sessionID string) (*Event, error) {
+	failures, err := RecentFailures(sessionID, 1)
+	if err != nil || len(failures) == 0 {
+		return nil, err
+	}
+	return &failures[0], nil
+}
 
-	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+// StaleFailure reports whether ev is too old (or has no usable timestamp) to
+// fix at now, with a detail line for the "no captured failure" message.
+func StaleFailure(ev *Event, now time.Time) (bool, string) {
+	if ev == nil {
+		return true, "no captured failure event"
+	}
+	ts, err := time.Parse(time.RFC3339, strings.TrimSpace(ev.Timestamp))
 	if err != nil {
-		return fmt.Errorf("could not open events file: %w", err)
+		detail := "captured failure has invalid timestamp"
+		if strings.TrimSpace(ev.Command) != "" {
+			detail += fmt.Sprintf(": %s", strings.TrimSpace(ev.Command))
+		}
+		return true, detail
 	}
-	defer f.Close()
-	if err := os.Chmod(path, 0o600); err != nil {
-		return fmt.Errorf("could not secure events file permissions: %w", err)
+	age := now.Sub(ts)
+	if age <= MaxFailureAge {
+		return false, ""
 	}
+	detail := fmt.Sprintf("captured %s ago: %s", age.Round(time.Minute), strings.TrimSpace(ev.Command))
+	if strings.TrimSpace(ev.SessionID) != "" {
+		detail += fmt.Sprintf(" (session: %s)", strings.TrimSpace(ev.SessionID))
+	}
+	return true, detail
+}
 
-	encoded, err := json.Marshal(ev)
-	if err != nil {
-		return fmt.Errorf("could not serialize event: %w", err)
+// SessionShell returns the shell that recorded the newest event for
+// sessionID, or "" when the session has no captured events.
+func SessionShell(sessionID string) (string, error) {
+	if strings.TrimSpace(sessionID) == "" {
+		return "", nil
 	}
-	if _, err := f.WriteString(string(encoded) + "\n"); err != nil {
-		return fmt.Errorf("could not write event: %w", err)
+	events, err := scanEvents(1, func(ev Event) bool {
+		return ev.SessionID == sessionID && strings.TrimSpace(ev.Shell) != ""
+	})
+	if err != nil || len(events) == 0 {
+		return "", err
 	}
-	return nil
+	return strings.TrimSpace(events[0].Shell), nil
 }
 
-func LatestFailure(sessionID string) (*Event, error) {
-	path, err := appdirs.StateFilePath(eventsFileName)
+// RecentFailures returns up to limit failed events for sessionID (any
+// session when empty), newest first. Synthetic provider sessions are skipped.
+func RecentFailures(sessionID string, limit int) ([]Event, error) {
+	failures, err := scanEvents(limit, func(ev Event) bool {
+		return ev.ExitCode != 0 && (sessionID == "" || ev.SessionID == sessionID)
+	})
 	if err != nil {
 		return nil, err
 	}
-	f, err := os.Open(path)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil
-		}
-		return nil, fmt.Errorf("could not read events file: %w", err)
+	for i, j := 0, len(failures)-1; i < j; i, j = i+1, j-1 {
+		failures[i], failures[j] = failures[j], failures[i]
 	}
-	defer f.Close()
+	return failures, nil
+}
 
-	scanner := bufio.NewScanner(f)
-	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
-	var latest *Event
-	for scanner.Scan() {
-		line := scanner.Text()
-		if line == "" {
-			continue
-		}
+// RecentEvents returns up to limit captured events (all when limit <= 0),
+// oldest first. Synthetic provider sessions are skipped.
+func RecentEvents(limit int) ([]Event, error) {
+	return scanEvents(limit, func(Event) bool { return true })
+}
+
+// scanEvents keeps the last limit events accepted by keep, oldest first.
+func scanEvents(limit int, keep func(Event) bool) ([]Event, error) {
+	records, err := eventRecords()
+	if err != nil {
+		return nil, err
+	}
+	var events []Event
+	for _, record := range records {
 		var ev Event
-		if err := json.Unmarshal([]byte(line), &ev); err != nil {
-			continue
-		}
-		if ev.ExitCode == 0 {
+		if err := json.Unmarshal(record, &ev); err != nil {
 			continue
 		}
-		if isSyntheticSessionID(ev.SessionID) {
+		if IsSyntheticSessionID(ev.SessionID) || !keep(ev) {
 			continue
 		}
-		if sessionID != "" && ev.SessionID != sessionID {
-			continue
+		events = append(events, ev)
+		if limit > 0 && len(events) > limit {
+			events = events[1:]
 		}
-		candidate := ev
-		latest = &candidate
 	}
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("could not scan events file: %w", err)
+	return events, nil
+}
+
+// eventRecords reads the captured events from the state backend, oldest
+// first.
+func eventRecords() ([][]byte, error) {
+	backend, err := state.Current()
+	if err != nil {
+		return nil, err
+	}
+	records, err := backend.Records(eventsFileName)
+	if err != nil {
+		return nil, fmt.Errorf("could not read events: %w", err)
 	}
-	return latest, nil
+	return records, nil
 }
 
-func isSyntheticSessionID(sessionID string) bool {
+// IsSyntheticSessionID reports whether sessionID belongs to provider test
+// runs; events from those sessions are never offered as failures to fix.
+func IsSyntheticSessionID(sessionID string) bool {
 	normalized := strings.ToLower(strings.TrimSpace(sessionID))
 	if normalized == "" {
 		return false
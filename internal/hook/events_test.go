@@ -14,6 +14,9 @@ func TestShouldIgnoreCommand(t *testing.T) {
 	if !shouldIgnoreCommand("_ew hook-record --command \"ls\"") {
 		t.Fatalf("expected internal hook record command to be ignored")
 	}
+	if !shouldIgnoreCommand(`command ew internal hook-record --command "ls"`) {
+		t.Fatalf("expected ew internal hook record command to be ignored")
+	}
 	if !shouldIgnoreCommand("ew find kube logs") {
 		t.Fatalf("expected ew command to be ignored")
 	}
@@ -307,3 +310,52 @@ func TestRecordEventRedactsPrefixedPositionalSecretKeyNameBeforePersisting(t *te
 		t.Fatalf("expected prefixed positional redaction marker in persisted event, got %q", payload)
 	}
 }
+
+func TestRecentFailuresReturnsNewestFirstWithinLimit(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, ".local", "state"))
+
+	for _, ev := range []Event{
+		{Command: "one", ExitCode: 1, SessionID: "1.1"},
+		{Command: "two", ExitCode: 2, SessionID: "1.1"},
+		{Command: "ok", ExitCode: 0, SessionID: "1.1"},
+		{Command: "other", ExitCode: 1, SessionID: "2.2"},
+		{Command: "three", ExitCode: 1, SessionID: "1.1"},
+	} {
+		if err := RecordEvent(ev); err != nil {
+			t.Fatalf("RecordEvent failed: %v", err)
+		}
+	}
+
+	failures, err := RecentFailures("1.1", 2)
+	if err != nil {
+		t.Fatalf("RecentFailures failed: %v", err)
+	}
+	if len(failures) != 2 || failures[0].Command != "three" || failures[1].Command != "two" {
+		t.Fatalf("expected [three two], got %+v", failures)
+	}
+}
+
+func TestSessionShellUsesNewestEventForSession(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, ".local", "state"))
+
+	for _, ev := range []Event{
+		{Command: "ls", Shell: "bash", SessionID: "1.1"},
+		{Command: "ls", Shell: "fish", SessionID: "1.1"},
+		{Command: "ls", Shell: "zsh", SessionID: "2.2"},
+	} {
+		if err := RecordEvent(ev); err != nil {
+			t.Fatalf("RecordEvent failed: %v", err)
+		}
+	}
+
+	if shell, err := SessionShell("1.1"); err != nil || shell != "fish" {
+		t.Fatalf("expected fish, got %q (%v)", shell, err)
+	}
+	if shell, err := SessionShell("3.3"); err != nil || shell != "" {
+		t.Fatalf("expected no shell for unknown session, got %q (%v)", shell, err)
+	}
+}
@@ -0,0 +1,118 @@
+package hook
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ashwch/ew/internal/appdirs"
+)
+
+const historyQueueDirName = "history_queue"
+
+// HistoryHookEnv is exported by hook snippets that drain the history queue
+// after each `ew` command. Without it a queued command would never reach
+// the shell, so ew writes the history file directly instead.
+const HistoryHookEnv = "EW_HISTORY_HOOK"
+
+// InteractiveCommentsEnv is set to 1 by the zsh hook while the
+// interactive_comments option is on. Only then does zsh read the "# via ew"
+// watermark back as a comment rather than as arguments.
+const InteractiveCommentsEnv = "EW_INTERACTIVE_COMMENTS"
+
+// QueueHistory holds command for the shell of sessionID to add to its own
+// history with `ew internal history-take`. Queued commands reach the
+// session's in-memory history, which a direct write to the history file
+// does not.
+func QueueHistory(sessionID, command string) error {
+	command = strings.TrimSpace(command)
+	if command == "" || strings.Contains(command, "\n") {
+		return fmt.Errorf("only single-line commands can be queued")
+	}
+	path, err := historyQueuePath(sessionID)
+	if err != nil {
+		return err
+	}
+	if _, err := appdirs.EnsureStateDir(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("could not create history queue dir: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("could not open history queue: %w", err)
+	}
+	if _, err := f.WriteString(command + "\n"); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("could not write history queue: %w", err)
+	}
+	return f.Close()
+}
+
+// TakeHistory returns the commands queued for sessionID, oldest first, and
+// empties the queue.
+func TakeHistory(sessionID string) ([]string, error) {
+	path, err := historyQueuePath(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	// Renaming first means a command queued while this one is read lands in
+	// a fresh queue instead of being dropped.
+	taken := path + ".taking"
+	if err := os.Rename(path, taken); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not take history queue: %w", err)
+	}
+	defer os.Remove(taken)
+
+	f, err := os.Open(taken)
+	if err != nil {
+		return nil, fmt.Errorf("could not open history queue: %w", err)
+	}
+	defer f.Close()
+	var commands []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxCommandLength*4)
+	for scanner.Scan() {
+		if command := strings.TrimSpace(scanner.Text()); command != "" {
+			commands = append(commands, command)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read history queue: %w", err)
+	}
+	return commands, nil
+}
+
+func historyQueuePath(sessionID string) (string, error) {
+	name, err := sessionFileName(sessionID)
+	if err != nil {
+		return "", err
+	}
+	dir, err := appdirs.StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, historyQueueDirName, name), nil
+}
+
+// sessionFileName turns sessionID into a safe file name.
+func sessionFileName(sessionID string) (string, error) {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, strings.TrimSpace(sessionID))
+	if strings.Trim(name, "._") == "" {
+		return "", fmt.Errorf("session id is required")
+	}
+	return name, nil
+}
@@ -0,0 +1,39 @@
+package hook
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestTakeHistoryDrainsQueueInOrder(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, ".local", "state"))
+
+	for _, command := range []string{"git status", "make test"} {
+		if err := QueueHistory("123.456", command); err != nil {
+			t.Fatalf("QueueHistory failed: %v", err)
+		}
+	}
+	if err := QueueHistory("other", "ls"); err != nil {
+		t.Fatalf("QueueHistory failed: %v", err)
+	}
+	if err := QueueHistory("123.456", "echo a\necho b"); err == nil {
+		t.Fatalf("expected a multi-line command to be refused")
+	}
+
+	got, err := TakeHistory("123.456")
+	if err != nil {
+		t.Fatalf("TakeHistory failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, []string{"git status", "make test"}) {
+		t.Fatalf("unexpected queued commands %v", got)
+	}
+	if again, err := TakeHistory("123.456"); err != nil || len(again) != 0 {
+		t.Fatalf("expected the queue to be empty after taking, got %v (err=%v)", again, err)
+	}
+	if _, err := TakeHistory("../"); err == nil {
+		t.Fatalf("expected a session id without usable characters to be refused")
+	}
+}
@@ -0,0 +1,201 @@
+package hook
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/ashwch/ew/internal/appdirs"
+)
+
+const outputDirName = "output"
+
+// OutputCaptureEnv turns on stderr capture in the zsh hook: on keeps the
+// last DefaultOutputLines lines of a failed command's stderr, a number keeps
+// that many. It has to be set before the hook snippet runs.
+const OutputCaptureEnv = "EW_CAPTURE_OUTPUT"
+
+const (
+	DefaultOutputLines = 20
+	maxOutputLines     = 200
+	// maxOutputLength bounds what an event keeps; the end of the output
+	// usually carries the actual error.
+	maxOutputLength = 4000
+	// outputTailBytes is how much of the end of a capture file is read.
+	outputTailBytes = 64 * 1024
+	// staleOutputAge is when a capture file left behind by a closed shell
+	// is removed. Every command empties the file of a live shell, so only
+	// a shell left idle for a week loses its capture.
+	staleOutputAge = 7 * 24 * time.Hour
+)
+
+// CaptureLines is how many lines of stderr OutputCaptureEnv asks for, or 0
+// when capture is off.
+func CaptureLines() int {
+	value := strings.ToLower(strings.TrimSpace(os.Getenv(OutputCaptureEnv)))
+	switch value {
+	case "", "0", "off", "false", "no":
+		return 0
+	case "on", "true", "yes":
+		return DefaultOutputLines
+	}
+	lines, err := strconv.Atoi(value)
+	if err != nil || lines <= 0 {
+		return 0
+	}
+	return min(lines, maxOutputLines)
+}
+
+// OutputFile creates the empty, private file the zsh hook of sessionID
+// tees each command's stderr into and returns its path. Capture files of sessions that have
+// not run anything for staleOutputAge are removed along the way.
+func OutputFile(sessionID string) (string, error) {
+	name, err := sessionFileName(sessionID)
+	if err != nil {
+		return "", err
+	}
+	dir, err := outputDir()
+	if err != nil {
+		return "", err
+	}
+	if _, err := appdirs.EnsureStateDir(); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("could not create output dir: %w", err)
+	}
+	path := filepath.Join(dir, name+".log")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("could not create output file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("could not create output file: %w", err)
+	}
+	pruneOutputFiles(dir, path, time.Now())
+	return path, nil
+}
+
+// ReadOutput returns the last lines lines of the stderr a command left in
+// path, with escape sequences and carriage-return redraws such as progress
+// bars reduced to the text they leave on screen. A missing file is no
+// output.
+func ReadOutput(path string, lines int) (string, error) {
+	if strings.TrimSpace(path) == "" || lines <= 0 {
+		return "", nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", fmt.Errorf("could not open output file: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", fmt.Errorf("could not read output file: %w", err)
+	}
+	offset := max(info.Size()-outputTailBytes, 0)
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return "", fmt.Errorf("could not read output file: %w", err)
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return "", fmt.Errorf("could not read output file: %w", err)
+	}
+
+	raw := strings.Split(string(data), "\n")
+	if offset > 0 && len(raw) > 1 {
+		// The first line was cut by the seek.
+		raw = raw[1:]
+	}
+	kept := make([]string, 0, lines)
+	for _, line := range raw {
+		if line = screenText(line); line != "" {
+			kept = append(kept, line)
+		}
+	}
+	if len(kept) > lines {
+		kept = kept[len(kept)-lines:]
+	}
+	return strings.Join(kept, "\n"), nil
+}
+
+// ClearOutput empties the capture file at path once its command has been
+// recorded.
+func ClearOutput(path string) {
+	_ = os.Truncate(path, 0)
+}
+
+// screenText is what line leaves on the terminal: only the text after the
+// last carriage return, without escape sequences or control characters.
+func screenText(line string) string {
+	line = strings.TrimRight(line, "\r")
+	if i := strings.LastIndex(line, "\r"); i >= 0 {
+		line = line[i+1:]
+	}
+	var b strings.Builder
+	inEscape := false
+	for _, r := range line {
+		switch {
+		case inEscape:
+			// CSI sequences end with a letter; anything else after ESC is
+			// a two-character sequence.
+			if unicode.IsLetter(r) || r == '~' {
+				inEscape = false
+			}
+		case r == '\x1b':
+			inEscape = true
+		case r == '\t':
+			b.WriteRune(' ')
+		case unicode.IsControl(r):
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return strings.TrimRight(b.String(), " ")
+}
+
+// clipOutput keeps the end of output when it is longer than
+// maxOutputLength.
+func clipOutput(output string) string {
+	if len(output) <= maxOutputLength {
+		return output
+	}
+	cut := len(output) - maxOutputLength
+	if i := strings.IndexByte(output[cut:], '\n'); i >= 0 {
+		cut += i + 1
+	}
+	return "..." + output[cut:]
+}
+
+func pruneOutputFiles(dir, keep string, now time.Time) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if path == keep || !strings.HasSuffix(entry.Name(), ".log") {
+			continue
+		}
+		if info, err := entry.Info(); err == nil && now.Sub(info.ModTime()) > staleOutputAge {
+			_ = os.Remove(path)
+		}
+	}
+}
+
+func outputDir() (string, error) {
+	dir, err := appdirs.StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, outputDirName), nil
+}
@@ -0,0 +1,113 @@
+package hook
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCaptureLinesReadsEnv(t *testing.T) {
+	for value, want := range map[string]int{"": 0, "off": 0, "0": 0, "on": DefaultOutputLines, "true": DefaultOutputLines, "5": 5, "9999": maxOutputLines, "lots": 0} {
+		t.Setenv(OutputCaptureEnv, value)
+		if got := CaptureLines(); got != want {
+			t.Fatalf("CaptureLines with %s=%q = %d, want %d", OutputCaptureEnv, value, got, want)
+		}
+	}
+}
+
+func TestReadOutputKeepsTheScreenTextOfTheLastLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.log")
+	content := "downloading 10%\rdownloading 100%\n" +
+		"\x1b[31merror:\x1b[0m missing\tsemicolon\n" +
+		"\n" +
+		"make: *** [build] Error 1\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write output failed: %v", err)
+	}
+
+	got, err := ReadOutput(path, 10)
+	if err != nil {
+		t.Fatalf("ReadOutput failed: %v", err)
+	}
+	want := "downloading 100%\nerror: missing semicolon\nmake: *** [build] Error 1"
+	if got != want {
+		t.Fatalf("expected escapes and redraws removed:\n%q\nwant:\n%q", got, want)
+	}
+
+	if got, _ := ReadOutput(path, 1); got != "make: *** [build] Error 1" {
+		t.Fatalf("expected only the last line, got %q", got)
+	}
+	if got, err := ReadOutput(filepath.Join(t.TempDir(), "missing.log"), 10); err != nil || got != "" {
+		t.Fatalf("expected a missing file to be no output, got %q (err=%v)", got, err)
+	}
+
+	ClearOutput(path)
+	if got, _ := ReadOutput(path, 10); got != "" {
+		t.Fatalf("expected a cleared capture to be no output, got %q", got)
+	}
+}
+
+func TestOutputFileIsPrivateAndPrunesStaleCaptures(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, ".local", "state"))
+
+	stale, err := OutputFile("old.1")
+	if err != nil {
+		t.Fatalf("OutputFile failed: %v", err)
+	}
+	past := time.Now().Add(-staleOutputAge - time.Hour)
+	if err := os.Chtimes(stale, past, past); err != nil {
+		t.Fatalf("chtimes failed: %v", err)
+	}
+	if err := os.WriteFile(stale+"x", nil, 0o600); err != nil {
+		t.Fatalf("write unrelated file failed: %v", err)
+	}
+
+	path, err := OutputFile("123.456")
+	if err != nil {
+		t.Fatalf("OutputFile failed: %v", err)
+	}
+	if filepath.Base(path) != "123.456.log" {
+		t.Fatalf("unexpected capture file %s", path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat capture file failed: %v", err)
+	}
+	if info.Size() != 0 || (os.PathSeparator == '/' && info.Mode().Perm() != 0o600) {
+		t.Fatalf("expected an empty private file, got size %d mode %v", info.Size(), info.Mode().Perm())
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Fatalf("expected the stale capture to be removed, got %v", err)
+	}
+	if _, err := os.Stat(stale + "x"); err != nil {
+		t.Fatalf("expected files that are not captures to be left alone: %v", err)
+	}
+	if _, err := OutputFile(" "); err == nil {
+		t.Fatalf("expected an empty session id to fail")
+	}
+}
+
+func TestRecordEventRedactsAndClipsOutput(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, ".local", "state"))
+
+	output := strings.Repeat("warning: retrying\n", 400) + "error: login failed for token=supersecretvalue123"
+	if err := RecordEvent(Event{Command: "deploy", ExitCode: 1, Shell: "bash", Output: output}); err != nil {
+		t.Fatalf("RecordEvent failed: %v", err)
+	}
+	ev, err := LatestFailure("")
+	if err != nil || ev == nil {
+		t.Fatalf("LatestFailure failed: %v", err)
+	}
+	if strings.Contains(ev.Output, "supersecretvalue123") {
+		t.Fatalf("expected the secret in the output to be redacted, got %q", ev.Output)
+	}
+	if len(ev.Output) > maxOutputLength+3 || !strings.HasPrefix(ev.Output, "...") || !strings.Contains(ev.Output, "error: login failed") {
+		t.Fatalf("expected the end of the output to be kept, got %d bytes", len(ev.Output))
+	}
+}
@@ -0,0 +1,104 @@
+package hook
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/ashwch/ew/internal/state"
+)
+
+const promptStatusCacheFileName = "prompt_status.json"
+
+// Only the newest events are looked at; the newest event for a session is
+// almost always among the last few.
+const promptStatusTail = 200
+
+const PromptStatusText = "ew: fix available"
+
+// promptStatusThrottle is how long one read of the events answers every
+// prompt. The hooks only ask after a failed command, and a failure that
+// just happened always shows, so a few stale seconds change nothing.
+const promptStatusThrottle = 5 * time.Second
+
+type promptStatusCache struct {
+	CheckedAt string                        `json:"checked_at"`
+	Sessions  map[string]promptStatusRecord `json:"sessions"`
+}
+
+// promptStatusRecord keeps only what the indicator needs, not the command.
+type promptStatusRecord struct {
+	ExitCode  int    `json:"exit_code"`
+	Timestamp string `json:"timestamp"`
+}
+
+// PromptStatus returns PromptStatusText when the newest captured command for
+// sessionID failed within maxAge, and "" otherwise. It is called from shell
+// prompts, so it keeps what it last read of the events for
+// promptStatusThrottle and only rereads them after that.
+func PromptStatus(sessionID string, maxAge time.Duration, now time.Time) (string, error) {
+	sessionID = strings.TrimSpace(sessionID)
+	backend, err := state.Current()
+	if err != nil {
+		return "", err
+	}
+	cache := loadPromptStatusCache(backend)
+	checked, err := time.Parse(time.RFC3339Nano, cache.CheckedAt)
+	if err != nil || now.Sub(checked) < 0 || now.Sub(checked) >= promptStatusThrottle {
+		records, err := backend.Records(eventsFileName)
+		if err != nil {
+			return "", err
+		}
+		cache = promptStatusCache{CheckedAt: now.UTC().Format(time.RFC3339Nano), Sessions: latestEventsBySession(records)}
+		savePromptStatusCache(backend, cache)
+	}
+
+	record, ok := cache.Sessions[sessionID]
+	if !ok || record.ExitCode == 0 {
+		return "", nil
+	}
+	ts, err := time.Parse(time.RFC3339, strings.TrimSpace(record.Timestamp))
+	if err != nil || now.Sub(ts) > maxAge {
+		return "", nil
+	}
+	return PromptStatusText, nil
+}
+
+// latestEventsBySession keeps the newest of the last promptStatusTail
+// events for each session.
+func latestEventsBySession(records [][]byte) map[string]promptStatusRecord {
+	if len(records) > promptStatusTail {
+		records = records[len(records)-promptStatusTail:]
+	}
+	latest := map[string]promptStatusRecord{}
+	for _, record := range records {
+		var ev Event
+		if err := json.Unmarshal(record, &ev); err != nil {
+			continue
+		}
+		latest[strings.TrimSpace(ev.SessionID)] = promptStatusRecord{ExitCode: ev.ExitCode, Timestamp: ev.Timestamp}
+	}
+	return latest
+}
+
+func loadPromptStatusCache(backend state.Backend) promptStatusCache {
+	payload, err := backend.Read(promptStatusCacheFileName)
+	if err != nil || len(payload) == 0 {
+		return promptStatusCache{}
+	}
+	var cache promptStatusCache
+	if err := json.Unmarshal(payload, &cache); err != nil {
+		return promptStatusCache{}
+	}
+	return cache
+}
+
+// savePromptStatusCache is best effort: a missing cache only costs one
+// extra tail read on the next prompt.
+func savePromptStatusCache(backend state.Backend, cache promptStatusCache) {
+	payload, err := json.Marshal(cache)
+	if err != nil {
+		return
+	}
+	_ = backend.Write(promptStatusCacheFileName, payload)
+}
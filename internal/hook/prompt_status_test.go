@@ -0,0 +1,52 @@
+package hook
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPromptStatusTracksLatestEventPerSession(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, ".local", "state"))
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	record := func(command string, exitCode int, session string) {
+		t.Helper()
+		if err := RecordEvent(Event{
+			Command:   command,
+			ExitCode:  exitCode,
+			Shell:     "zsh",
+			SessionID: session,
+			Timestamp: now.Add(-time.Minute).Format(time.RFC3339),
+		}); err != nil {
+			t.Fatalf("RecordEvent failed: %v", err)
+		}
+	}
+
+	if got, err := PromptStatus("s1", 10*time.Minute, now); err != nil || got != "" {
+		t.Fatalf("expected empty status without events, got %q (err=%v)", got, err)
+	}
+
+	record("git pshu", 1, "s1")
+	record("ls", 0, "s2")
+	now = now.Add(promptStatusThrottle)
+	if got, _ := PromptStatus("s1", 10*time.Minute, now); got != PromptStatusText {
+		t.Fatalf("expected fix indicator after failure, got %q", got)
+	}
+	if got, _ := PromptStatus("s2", 10*time.Minute, now); got != "" {
+		t.Fatalf("expected no indicator for a session whose last command passed, got %q", got)
+	}
+	if got, _ := PromptStatus("s1", 30*time.Second, now); got != "" {
+		t.Fatalf("expected stale failure to be hidden, got %q", got)
+	}
+
+	record("git push", 0, "s1")
+	if got, _ := PromptStatus("s1", 10*time.Minute, now.Add(time.Second)); got != PromptStatusText {
+		t.Fatalf("expected the answer reused within the throttle, got %q", got)
+	}
+	if got, _ := PromptStatus("s1", 10*time.Minute, now.Add(promptStatusThrottle)); got != "" {
+		t.Fatalf("expected indicator to clear after a successful command, got %q", got)
+	}
+}
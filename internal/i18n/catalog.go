@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/ashwch/ew/internal/appdirs"
@@ -13,6 +14,8 @@ type Catalog struct {
 	Locale string        `json:"locale"`
 	Loader LoaderCatalog `json:"loader"`
 	Self   SelfCatalog   `json:"self"`
+	Intent IntentCatalog `json:"intent"`
+	Flags  FlagCatalog   `json:"flags"`
 }
 
 type LoaderCatalog struct {
@@ -27,6 +30,7 @@ type SelfCatalog struct {
 	ShowConfig []string `json:"show_config"`
 	SetupHooks []string `json:"setup_hooks"`
 	Diagnose   []string `json:"diagnose"`
+	ClearCache []string `json:"clear_cache"`
 	Provider   []string `json:"provider"`
 	UI         []string `json:"ui"`
 	Mode       []string `json:"mode"`
@@ -36,7 +40,62 @@ type SelfCatalog struct {
 	Question   []string `json:"question"`
 }
 
-func LoadCatalog(requestedLocale string) Catalog {
+// IntentCatalog holds the words that tell intents apart. Run lists verbs
+// that make a query an order to act now ("restart nginx"); an entry matches
+// at the start or the end of the query, so verb-final languages work too.
+type IntentCatalog struct {
+	Run []string `json:"run"`
+}
+
+// FlagCatalog holds words that stand for a flag, so "ew chalaao restart
+// nginx" is "ew --execute restart nginx". Like flags, they are only read
+// before the request itself; the canonical English flags always work.
+type FlagCatalog struct {
+	Execute []string `json:"execute"`
+	DryRun  []string `json:"dry_run"`
+	Yes     []string `json:"yes"`
+	Explain []string `json:"explain"`
+	Preview []string `json:"preview"`
+	Quiet   []string `json:"quiet"`
+	Copy    []string `json:"copy"`
+}
+
+// FlagAlias is a word or phrase and the flag it stands for.
+type FlagAlias struct {
+	Words []string
+	Flag  string
+}
+
+// FlagAliases lists the catalog's flag aliases, longest phrase first so a
+// phrase wins over a word it starts with.
+func (c Catalog) FlagAliases() []FlagAlias {
+	var aliases []FlagAlias
+	for _, group := range []struct {
+		flag    string
+		phrases []string
+	}{
+		{"execute", c.Flags.Execute},
+		{"dry-run", c.Flags.DryRun},
+		{"yes", c.Flags.Yes},
+		{"explain", c.Flags.Explain},
+		{"preview", c.Flags.Preview},
+		{"quiet", c.Flags.Quiet},
+		{"copy", c.Flags.Copy},
+	} {
+		for _, phrase := range group.phrases {
+			if words := strings.Fields(phrase); len(words) > 0 && !strings.HasPrefix(words[0], "-") {
+				aliases = append(aliases, FlagAlias{Words: words, Flag: group.flag})
+			}
+		}
+	}
+	slices.SortStableFunc(aliases, func(a, b FlagAlias) int { return len(b.Words) - len(a.Words) })
+	return aliases
+}
+
+// LoadCatalog builds the catalog for requestedLocale. packDirs are extra
+// directories (for example a trusted project's .ew/) whose locales/ packs win
+// over the user's own config dir.
+func LoadCatalog(requestedLocale string, packDirs ...string) Catalog {
 	locale := NormalizeLocale(requestedLocale)
 	if locale == "" {
 		locale = DetectLocale()
@@ -46,7 +105,7 @@ func LoadCatalog(requestedLocale string) Catalog {
 	}
 	base := baseCatalogForLocale(locale)
 
-	if override, ok := loadCommunityCatalog(locale); ok {
+	if override, ok := loadCommunityCatalog(locale, packDirs); ok {
 		merged := mergeCatalog(base, override)
 		if strings.TrimSpace(override.Locale) != "" {
 			merged.Locale = NormalizeLocale(override.Locale)
@@ -137,9 +196,17 @@ func isValidLocaleToken(token string, lettersOnly bool) bool {
 	return true
 }
 
-func loadCommunityCatalog(locale string) (Catalog, bool) {
-	configDir, err := appdirs.ConfigDir()
-	if err != nil {
+func loadCommunityCatalog(locale string, packDirs []string) (Catalog, bool) {
+	dirs := make([]string, 0, len(packDirs)+1)
+	for _, dir := range packDirs {
+		if strings.TrimSpace(dir) != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	if configDir, err := appdirs.ConfigDir(); err == nil {
+		dirs = append(dirs, configDir)
+	}
+	if len(dirs) == 0 {
 		return Catalog{}, false
 	}
 
@@ -152,11 +219,12 @@ func loadCommunityCatalog(locale string) (Catalog, bool) {
 		lang = lang[:idx]
 	}
 
-	paths := []string{
-		filepath.Join(configDir, "locales", normalized+".json"),
-	}
-	if lang != normalized {
-		paths = append(paths, filepath.Join(configDir, "locales", lang+".json"))
+	paths := make([]string, 0, len(dirs)*2)
+	for _, dir := range dirs {
+		paths = append(paths, filepath.Join(dir, "locales", normalized+".json"))
+		if lang != normalized {
+			paths = append(paths, filepath.Join(dir, "locales", lang+".json"))
+		}
 	}
 
 	for _, path := range paths {
@@ -192,6 +260,7 @@ func mergeCatalog(base Catalog, override Catalog) Catalog {
 	merged.Self.ShowConfig = mergeStringSlices(base.Self.ShowConfig, override.Self.ShowConfig)
 	merged.Self.SetupHooks = mergeStringSlices(base.Self.SetupHooks, override.Self.SetupHooks)
 	merged.Self.Diagnose = mergeStringSlices(base.Self.Diagnose, override.Self.Diagnose)
+	merged.Self.ClearCache = mergeStringSlices(base.Self.ClearCache, override.Self.ClearCache)
 	merged.Self.Provider = mergeStringSlices(base.Self.Provider, override.Self.Provider)
 	merged.Self.UI = mergeStringSlices(base.Self.UI, override.Self.UI)
 	merged.Self.Mode = mergeStringSlices(base.Self.Mode, override.Self.Mode)
@@ -200,6 +269,16 @@ func mergeCatalog(base Catalog, override Catalog) Catalog {
 	merged.Self.Imperative = mergeStringSlices(base.Self.Imperative, override.Self.Imperative)
 	merged.Self.Question = mergeStringSlices(base.Self.Question, override.Self.Question)
 
+	merged.Intent.Run = mergeStringSlices(base.Intent.Run, override.Intent.Run)
+
+	merged.Flags.Execute = mergeStringSlices(base.Flags.Execute, override.Flags.Execute)
+	merged.Flags.DryRun = mergeStringSlices(base.Flags.DryRun, override.Flags.DryRun)
+	merged.Flags.Yes = mergeStringSlices(base.Flags.Yes, override.Flags.Yes)
+	merged.Flags.Explain = mergeStringSlices(base.Flags.Explain, override.Flags.Explain)
+	merged.Flags.Preview = mergeStringSlices(base.Flags.Preview, override.Flags.Preview)
+	merged.Flags.Quiet = mergeStringSlices(base.Flags.Quiet, override.Flags.Quiet)
+	merged.Flags.Copy = mergeStringSlices(base.Flags.Copy, override.Flags.Copy)
+
 	return merged
 }
 
@@ -330,6 +409,14 @@ func defaultEnglishCatalog() Catalog {
 				"check setup",
 				"diagnose",
 			},
+			ClearCache: []string{
+				"clear cache",
+				"clear the cache",
+				"cache clear",
+				"flush cache",
+				"reset cache",
+				"cache_clear",
+			},
 			Provider: []string{
 				"provider",
 				"switch provider",
@@ -380,6 +467,26 @@ func defaultEnglishCatalog() Catalog {
 				"can ",
 			},
 		},
+		Intent: IntentCatalog{
+			Run: []string{
+				"restart",
+				"start",
+				"stop",
+				"kill",
+				"reload",
+				"deploy",
+				"install",
+				"uninstall",
+				"upgrade",
+				"rebuild",
+				"reboot",
+				"shutdown",
+				"mount",
+				"unmount",
+				"launch",
+				"run",
+			},
+		},
 	}
 }
 
@@ -485,6 +592,13 @@ func defaultHindiCatalog() Catalog {
 				"doctor",
 				"diagnose",
 			},
+			ClearCache: []string{
+				"कैश साफ़ करो",
+				"कैश साफ करो",
+				"clear cache",
+				"cache clear",
+				"cache_clear",
+			},
 			Provider: []string{
 				"प्रोवाइडर",
 				"provider",
@@ -547,5 +661,51 @@ func defaultHindiCatalog() Catalog {
 				"क्यों",
 			},
 		},
+		Intent: IntentCatalog{
+			Run: []string{
+				"रीस्टार्ट करो",
+				"रीस्टार्ट करें",
+				"शुरू करो",
+				"चालू करो",
+				"बंद करो",
+				"रोको",
+				"चलाओ",
+				"इंस्टॉल करो",
+				"अपडेट करो",
+			},
+		},
+		Flags: FlagCatalog{
+			Execute: []string{
+				"chalaao",
+				"chalao",
+				"chala do",
+				"चलाओ",
+				"चला दो",
+			},
+			DryRun: []string{
+				"bas dikhao",
+				"बस दिखाओ",
+			},
+			Yes: []string{
+				"haan",
+				"हाँ",
+			},
+			Explain: []string{
+				"samjhao",
+				"समझाओ",
+			},
+			Preview: []string{
+				"pehle dikhao",
+				"पहले दिखाओ",
+			},
+			Quiet: []string{
+				"chupchaap",
+				"चुपचाप",
+			},
+			Copy: []string{
+				"copy karo",
+				"कॉपी करो",
+			},
+		},
 	}
 }
@@ -106,3 +106,23 @@ func TestLoadCatalogHindiHasRichCoverage(t *testing.T) {
 		t.Fatalf("expected Hindi self-intent coverage for show config")
 	}
 }
+
+func TestFlagAliasesPutLongerPhrasesFirst(t *testing.T) {
+	catalog := LoadCatalog("hi")
+	aliases := catalog.FlagAliases()
+	if len(aliases) == 0 || len(aliases[0].Words) < 2 {
+		t.Fatalf("expected multi-word aliases first, got %+v", aliases)
+	}
+	found := false
+	for _, alias := range aliases {
+		if strings.Join(alias.Words, " ") == "chalaao" && alias.Flag == "execute" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected chalaao to stand for --execute, got %+v", aliases)
+	}
+	if english := LoadCatalog("en").FlagAliases(); len(english) != 0 {
+		t.Fatalf("expected no English flag aliases, got %+v", english)
+	}
+}
@@ -0,0 +1,180 @@
+package i18n
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// labelPlaceholder is replaced with the loader's label in loader.default
+// phrases.
+const labelPlaceholder = "{label}"
+
+// PackReport is the result of checking a community locale pack.
+type PackReport struct {
+	Locale   string   `json:"locale"`
+	Path     string   `json:"path"`
+	Phrases  int      `json:"phrases"`
+	Errors   []string `json:"errors,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// OK reports whether the pack can be shipped as is.
+func (r PackReport) OK() bool {
+	return len(r.Errors) == 0
+}
+
+// catalogList is one phrase list of a catalog, named by its JSON path such
+// as "loader.ranking".
+type catalogList struct {
+	key    string
+	values *[]string
+}
+
+// catalogLists walks every phrase list in c, section by section, so the
+// scaffold and the checks pick up new catalog keys on their own.
+func catalogLists(c *Catalog) []catalogList {
+	var lists []catalogList
+	root := reflect.ValueOf(c).Elem()
+	for i := 0; i < root.NumField(); i++ {
+		section := root.Field(i)
+		if section.Kind() != reflect.Struct {
+			continue
+		}
+		sectionKey := jsonName(root.Type().Field(i))
+		for j := 0; j < section.NumField(); j++ {
+			field := section.Field(j)
+			if field.Type() != reflect.TypeOf([]string(nil)) {
+				continue
+			}
+			lists = append(lists, catalogList{
+				key:    sectionKey + "." + jsonName(section.Type().Field(j)),
+				values: field.Addr().Interface().(*[]string),
+			})
+		}
+	}
+	return lists
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	return name
+}
+
+// Scaffold returns a starter community pack for locale: every key the
+// English catalog has, each with an empty list to fill in.
+func Scaffold(locale string) ([]byte, error) {
+	normalized := NormalizeLocale(locale)
+	if normalized == "" {
+		return nil, fmt.Errorf("invalid locale %q: want a code such as es or pt-BR", locale)
+	}
+	pack := Catalog{Locale: normalized}
+	for _, list := range catalogLists(&pack) {
+		*list.values = []string{}
+	}
+	payload, err := json.MarshalIndent(pack, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("could not encode locale pack: %w", err)
+	}
+	return append(payload, '\n'), nil
+}
+
+// CheckPack validates the community pack at path: it must use only known
+// keys, name a valid locale, and load back through LoadCatalog with every
+// phrase intact. Problems with the pack go in the report; the error is only
+// for a file that cannot be read at all.
+func CheckPack(path string) (PackReport, error) {
+	report := PackReport{Path: path}
+	payload, err := os.ReadFile(path)
+	if err != nil {
+		return report, fmt.Errorf("could not read locale pack: %w", err)
+	}
+
+	var pack Catalog
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&pack); err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("not a valid pack: %v", err))
+		return report, nil
+	}
+
+	report.Locale = NormalizeLocale(pack.Locale)
+	switch {
+	case strings.TrimSpace(pack.Locale) == "":
+		report.Errors = append(report.Errors, `"locale" is missing`)
+	case report.Locale == "":
+		report.Errors = append(report.Errors, fmt.Sprintf("invalid locale %q: want a code such as es or pt-BR", pack.Locale))
+	default:
+		lang, _, _ := strings.Cut(report.Locale, "-")
+		if stem := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)); NormalizeLocale(stem) != report.Locale && stem != lang {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("ew only loads this pack as locales/%s.json or locales/%s.json", report.Locale, lang))
+		}
+	}
+
+	for _, list := range catalogLists(&pack) {
+		seen := map[string]bool{}
+		for _, phrase := range *list.values {
+			trimmed := strings.TrimSpace(phrase)
+			switch {
+			case trimmed == "":
+				report.Errors = append(report.Errors, fmt.Sprintf("%s has an empty phrase", list.key))
+				continue
+			case seen[trimmed]:
+				report.Warnings = append(report.Warnings, fmt.Sprintf("%s lists %q twice", list.key, trimmed))
+				continue
+			}
+			seen[trimmed] = true
+			report.Phrases++
+			if list.key == "loader.default" && !strings.Contains(trimmed, labelPlaceholder) {
+				report.Warnings = append(report.Warnings, fmt.Sprintf("%s phrase %q drops the %s placeholder", list.key, trimmed, labelPlaceholder))
+			}
+		}
+	}
+	if report.Phrases == 0 && report.OK() {
+		report.Warnings = append(report.Warnings, "the pack has no phrases yet; every key falls back to English")
+	}
+	if report.OK() {
+		if err := roundTrip(report.Locale, payload, pack); err != nil {
+			report.Errors = append(report.Errors, err.Error())
+		}
+	}
+	return report, nil
+}
+
+// roundTrip installs the pack in a scratch pack dir and loads it the way
+// ew does, to check that every phrase comes back.
+func roundTrip(locale string, payload []byte, pack Catalog) error {
+	dir, err := os.MkdirTemp("", "ew-locale-check-*")
+	if err != nil {
+		return fmt.Errorf("could not create scratch dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.MkdirAll(filepath.Join(dir, "locales"), 0o755); err != nil {
+		return fmt.Errorf("could not create scratch dir: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "locales", locale+".json"), payload, 0o644); err != nil {
+		return fmt.Errorf("could not write scratch pack: %w", err)
+	}
+
+	loaded := LoadCatalog(locale, dir)
+	if loaded.Locale != locale {
+		return fmt.Errorf("round trip: loaded locale %q instead of %q", loaded.Locale, locale)
+	}
+	loadedLists := map[string][]string{}
+	for _, list := range catalogLists(&loaded) {
+		loadedLists[list.key] = *list.values
+	}
+	for _, list := range catalogLists(&pack) {
+		for _, phrase := range *list.values {
+			if trimmed := strings.TrimSpace(phrase); !slices.Contains(loadedLists[list.key], trimmed) {
+				return fmt.Errorf("round trip: %s phrase %q was lost on load", list.key, trimmed)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package i18n
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScaffoldHasEveryKeyAndChecksClean(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	payload, err := Scaffold("pt_BR")
+	if err != nil {
+		t.Fatalf("Scaffold failed: %v", err)
+	}
+	var generic map[string]any
+	if err := json.Unmarshal(payload, &generic); err != nil {
+		t.Fatalf("scaffold is not JSON: %v", err)
+	}
+	self, _ := generic["self"].(map[string]any)
+	if generic["locale"] != "pt-BR" || len(self) != 11 || self["show_config"] == nil {
+		t.Fatalf("expected every self key with an empty list, got %s", payload)
+	}
+
+	path := filepath.Join(t.TempDir(), "pt-BR.json")
+	if err := os.WriteFile(path, payload, 0o644); err != nil {
+		t.Fatalf("write scaffold failed: %v", err)
+	}
+	report, err := CheckPack(path)
+	if err != nil || !report.OK() || report.Phrases != 0 || len(report.Warnings) != 1 {
+		t.Fatalf("expected a blank pack to pass with one warning, got %+v %v", report, err)
+	}
+	if _, err := Scaffold("not a locale"); err == nil {
+		t.Fatalf("expected an invalid code to fail")
+	}
+}
+
+func TestCheckPackAcceptsTheExamplePack(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	report, err := CheckPack(filepath.Join("..", "..", "docs", "locales", "community-locale.example.json"))
+	if err != nil || !report.OK() || report.Locale != "es-ES" || report.Phrases == 0 {
+		t.Fatalf("expected the example pack to pass, got %+v %v", report, err)
+	}
+}
+
+func TestCheckPackReportsProblems(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	write := func(name, body string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatalf("write pack failed: %v", err)
+		}
+		return path
+	}
+
+	report, _ := CheckPack(write("fr.json", `{"locale":"fr","self":{"show_conifg":["afficher la configuration"]}}`))
+	if report.OK() || !strings.Contains(report.Errors[0], "show_conifg") {
+		t.Fatalf("expected the misspelt key to be reported, got %+v", report)
+	}
+
+	report, _ = CheckPack(write("de.json", `{"locale":"de","loader":{"default":["{label}","bitte warten"," "]},"intent":{"run":["starten","starten"]}}`))
+	if report.OK() || len(report.Errors) != 1 || !strings.Contains(report.Errors[0], "empty phrase") {
+		t.Fatalf("expected one empty-phrase error, got %+v", report)
+	}
+	warnings := strings.Join(report.Warnings, "\n")
+	for _, want := range []string{"drops the {label} placeholder", `"starten" twice`} {
+		if !strings.Contains(warnings, want) {
+			t.Fatalf("expected a warning containing %q, got %q", want, warnings)
+		}
+	}
+
+	report, _ = CheckPack(write("spanish.json", `{"locale":"es","intent":{"run":["reiniciar"]}}`))
+	if !report.OK() || !strings.Contains(strings.Join(report.Warnings, "\n"), "locales/es.json") {
+		t.Fatalf("expected a file name warning, got %+v", report)
+	}
+
+	if _, err := CheckPack(filepath.Join(dir, "missing.json")); err == nil {
+		t.Fatalf("expected a missing file to fail")
+	}
+}
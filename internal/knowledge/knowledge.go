@@ -1,8 +1,11 @@
 package knowledge
 
 import (
+	"bytes"
 	_ "embed"
+	"encoding/json"
 	"fmt"
+	"strings"
 )
 
 //go:embed self_knowledge.json
@@ -14,3 +17,130 @@ func CorePrompt() (string, error) {
 	}
 	return string(selfKnowledge), nil
 }
+
+// Scope picks the self-knowledge sections that matter for a prompt.
+type Scope string
+
+const (
+	ScopeFix    Scope = "fix"
+	ScopeFind   Scope = "find"
+	ScopeConfig Scope = "config"
+)
+
+// bytesPerToken is the rough size of a token in this JSON, used to turn a
+// token budget into bytes without a tokenizer.
+const bytesPerToken = 4
+
+// requiredSections go into every scoped prompt regardless of the budget:
+// they are what keeps the provider's answer well-formed and honest.
+var requiredSections = []string{
+	"name",
+	"version",
+	"public_command",
+	"internal_command",
+	"public_cli_contract",
+	"output_contract",
+	"anti_hallucination_rules",
+	"llm_behavior_priorities",
+}
+
+// scopeSections lists the rest of each scope's sections, most useful
+// first. A section that does not fit the budget is skipped and the next,
+// smaller one may still fit.
+var scopeSections = map[Scope][]string{
+	ScopeFix: {
+		"safety",
+		"diagnostics_and_hooks",
+		"modes",
+		"examples",
+		"environment_variables",
+		"files_and_paths",
+		"prompt_envelope",
+	},
+	ScopeFind: {
+		"safety",
+		"examples",
+		"modes",
+		"memory_actions",
+		"files_and_paths",
+		"environment_variables",
+		"prompt_envelope",
+	},
+	ScopeConfig: {
+		"config_surface",
+		"defaults",
+		"flags",
+		"self_actions",
+		"flag_interactions",
+		"files_and_paths",
+		"provider_architecture",
+		"localization",
+		"ui_contract",
+		"modes",
+		"intents",
+		"environment_variables",
+		"memory_actions",
+		"dispatch_order",
+	},
+}
+
+// ScopedPrompt returns the self knowledge for scope as compact JSON: the
+// required sections plus as many of the scope's sections as fit in
+// budgetTokens, with the names of the rest listed under
+// "omitted_sections" so the provider knows they exist. A budget of zero
+// or less includes every section of the scope.
+func ScopedPrompt(scope Scope, budgetTokens int) (string, error) {
+	sections := map[string]json.RawMessage{}
+	if err := json.Unmarshal(selfKnowledge, &sections); err != nil {
+		return "", fmt.Errorf("could not parse self knowledge: %w", err)
+	}
+	optional, ok := scopeSections[scope]
+	if !ok {
+		return "", fmt.Errorf("unknown self knowledge scope %q", scope)
+	}
+
+	compact := func(key string) ([]byte, error) {
+		var value bytes.Buffer
+		if err := json.Compact(&value, sections[key]); err != nil {
+			return nil, fmt.Errorf("could not compact self knowledge section %s: %w", key, err)
+		}
+		return value.Bytes(), nil
+	}
+	var out bytes.Buffer
+	out.WriteByte('{')
+	write := func(key string, value []byte) {
+		if out.Len() > 1 {
+			out.WriteByte(',')
+		}
+		fmt.Fprintf(&out, "%q:%s", key, value)
+	}
+	for _, key := range requiredSections {
+		value, err := compact(key)
+		if err != nil {
+			return "", err
+		}
+		write(key, value)
+	}
+
+	budget := budgetTokens * bytesPerToken
+	var omitted []string
+	for _, key := range optional {
+		if _, ok := sections[key]; !ok {
+			continue
+		}
+		value, err := compact(key)
+		if err != nil {
+			return "", err
+		}
+		if budgetTokens > 0 && out.Len()+len(key)+len(value)+4 > budget {
+			omitted = append(omitted, key)
+			continue
+		}
+		write(key, value)
+	}
+	if len(omitted) > 0 {
+		fmt.Fprintf(&out, `,"omitted_sections":["%s"]`, strings.Join(omitted, `","`))
+	}
+	out.WriteByte('}')
+	return out.String(), nil
+}
@@ -29,7 +29,7 @@ func TestCorePromptContract(t *testing.T) {
 
 	assertStringField(t, payload, "name", "ew")
 	assertStringField(t, payload, "public_command", "ew")
-	assertStringField(t, payload, "internal_command", "_ew")
+	assertStringField(t, payload, "internal_command", "ew internal")
 
 	publicCLI := mustObjectField(t, payload, "public_cli_contract")
 	assertStringField(t, publicCLI, "user_subcommands", "none")
@@ -119,3 +119,61 @@ func containsString(values []string, want string) bool {
 	}
 	return false
 }
+
+func TestScopedPromptIncludesScopeSections(t *testing.T) {
+	for scope, want := range map[Scope]string{ScopeFix: "diagnostics_and_hooks", ScopeFind: "examples", ScopeConfig: "config_surface"} {
+		prompt, err := ScopedPrompt(scope, 0)
+		if err != nil {
+			t.Fatalf("ScopedPrompt(%s) returned error: %v", scope, err)
+		}
+		var payload map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(prompt), &payload); err != nil {
+			t.Fatalf("scoped prompt must be valid JSON: %v", err)
+		}
+		for _, key := range requiredSections {
+			if _, ok := payload[key]; !ok {
+				t.Fatalf("%s prompt missing required section %q", scope, key)
+			}
+		}
+		if _, ok := payload[want]; !ok {
+			t.Fatalf("%s prompt missing %q", scope, want)
+		}
+		if _, ok := payload["omitted_sections"]; ok {
+			t.Fatalf("did not expect omitted sections without a budget")
+		}
+	}
+
+	fix, _ := ScopedPrompt(ScopeFix, 0)
+	if strings.Contains(fix, `"config_surface"`) {
+		t.Fatalf("fix prompt should leave out the config surface")
+	}
+}
+
+func TestScopedPromptRespectsBudget(t *testing.T) {
+	full, err := ScopedPrompt(ScopeConfig, 0)
+	if err != nil {
+		t.Fatalf("ScopedPrompt returned error: %v", err)
+	}
+	capped, err := ScopedPrompt(ScopeConfig, 1000)
+	if err != nil {
+		t.Fatalf("ScopedPrompt returned error: %v", err)
+	}
+	if len(capped) >= len(full) {
+		t.Fatalf("expected the budget to shrink the prompt: %d >= %d", len(capped), len(full))
+	}
+	var payload struct {
+		Name    string   `json:"name"`
+		Omitted []string `json:"omitted_sections"`
+	}
+	if err := json.Unmarshal([]byte(capped), &payload); err != nil {
+		t.Fatalf("capped prompt must be valid JSON: %v", err)
+	}
+	if payload.Name != "ew" || len(payload.Omitted) == 0 {
+		t.Fatalf("expected required sections kept and the rest listed as omitted, got %+v", payload)
+	}
+	// Required sections survive a budget too small for them.
+	tiny, err := ScopedPrompt(ScopeFix, 1)
+	if err != nil || !strings.Contains(tiny, `"anti_hallucination_rules"`) {
+		t.Fatalf("expected required sections under a tiny budget, got %q (%v)", tiny, err)
+	}
+}
@@ -0,0 +1,160 @@
+package memory
+
+import (
+	"sort"
+	"strings"
+)
+
+// BootstrapMinRuns is how often the same command must appear in history
+// before bootstrap proposes it.
+const BootstrapMinRuns = 3
+
+// Candidate is a frequent history command that bootstrap proposes to learn.
+// Runs counts every command in its cluster, the commands that share its
+// program and subcommand; Variants is how many distinct ones there are.
+// Command is the most frequent of them.
+type Candidate struct {
+	Query    string `json:"query"`
+	Command  string `json:"command"`
+	Runs     int    `json:"runs"`
+	Variants int    `json:"variants"`
+}
+
+// bootstrapSkip are programs whose runs say nothing worth a memory entry:
+// navigation, listing, and opening files.
+var bootstrapSkip = map[string]struct{}{
+	"cd": {}, "ls": {}, "ll": {}, "la": {}, "l": {}, "pwd": {}, "clear": {}, "exit": {},
+	"history": {}, "ew": {}, "_ew": {}, "z": {}, "j": {}, "cat": {}, "less": {}, "more": {},
+	"vi": {}, "vim": {}, "nvim": {}, "nano": {}, "emacs": {}, "code": {}, "open": {}, "man": {},
+	"echo": {}, "which": {}, "source": {}, ".": {},
+}
+
+// Bootstrap clusters history commands, newest first, and returns up to
+// limit candidates with the most runs. Each cluster contributes its most
+// frequent command when that one ran at least BootstrapMinRuns times.
+// Commands already in the store, redacted commands, and keep's rejects
+// are left out. Query is a name made from the command's own words.
+func (s *Store) Bootstrap(commands []string, limit int, keep func(string) bool) []Candidate {
+	if limit <= 0 {
+		limit = 10
+	}
+	known := map[string]struct{}{}
+	for _, entry := range s.Entries {
+		known[normalize(entry.Command)] = struct{}{}
+	}
+
+	type cluster struct {
+		runs   int
+		counts map[string]int
+		first  map[string]int
+		shown  map[string]string
+	}
+	clusters := map[string]*cluster{}
+	order := 0
+	for _, command := range commands {
+		command = strings.Join(strings.Fields(command), " ")
+		key := clusterKey(command)
+		if key == "" || strings.Contains(command, "<redacted>") {
+			continue
+		}
+		if _, ok := known[normalize(command)]; ok {
+			continue
+		}
+		if keep != nil && !keep(command) {
+			continue
+		}
+		c := clusters[key]
+		if c == nil {
+			c = &cluster{counts: map[string]int{}, first: map[string]int{}, shown: map[string]string{}}
+			clusters[key] = c
+		}
+		c.runs++
+		variant := normalize(command)
+		if _, seen := c.first[variant]; !seen {
+			c.first[variant] = order
+			c.shown[variant] = command
+			order++
+		}
+		c.counts[variant]++
+	}
+
+	candidates := make([]Candidate, 0, len(clusters))
+	for _, c := range clusters {
+		best := ""
+		for variant, count := range c.counts {
+			if best == "" || count > c.counts[best] || (count == c.counts[best] && c.first[variant] < c.first[best]) {
+				best = variant
+			}
+		}
+		if c.counts[best] < BootstrapMinRuns {
+			continue
+		}
+		command := c.shown[best]
+		candidates = append(candidates, Candidate{
+			Query:    CommandQuery(command),
+			Command:  command,
+			Runs:     c.runs,
+			Variants: len(c.counts),
+		})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Runs == candidates[j].Runs {
+			return candidates[i].Command < candidates[j].Command
+		}
+		return candidates[i].Runs > candidates[j].Runs
+	})
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	return candidates
+}
+
+// clusterKey is the program and its first non-flag word, after any
+// sudo or VAR=value prefix: "git push origin main" and "git push -f" share
+// "git push". It is empty for commands bootstrap skips.
+func clusterKey(command string) string {
+	words := commandWords(command)
+	if len(words) == 0 {
+		return ""
+	}
+	if _, skip := bootstrapSkip[words[0]]; skip {
+		return ""
+	}
+	if len(words) == 1 {
+		return words[0]
+	}
+	return words[0] + " " + words[1]
+}
+
+// CommandQuery names a command after its words, without flags or quoting:
+// "docker compose up -d" becomes "docker compose up". It is the name
+// bootstrap proposes when no provider suggests a better one.
+func CommandQuery(command string) string {
+	words := commandWords(command)
+	if len(words) > 4 {
+		words = words[:4]
+	}
+	return strings.Join(words, " ")
+}
+
+// commandWords lists the lowercased non-flag words of the first command in
+// a line, skipping sudo and environment assignments.
+func commandWords(command string) []string {
+	out := []string{}
+	for _, field := range strings.Fields(command) {
+		if strings.ContainsAny(field, "|;&><") {
+			break
+		}
+		if len(out) == 0 && (field == "sudo" || strings.Contains(field, "=")) {
+			continue
+		}
+		if strings.HasPrefix(field, "-") {
+			continue
+		}
+		word := strings.ToLower(strings.Trim(field, `"'`))
+		if word != "" {
+			out = append(out, word)
+		}
+	}
+	return out
+}
@@ -0,0 +1,49 @@
+package memory
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBootstrapClustersFrequentCommands(t *testing.T) {
+	history := []string{}
+	add := func(command string, times int) {
+		for range times {
+			history = append(history, command)
+		}
+	}
+	add("git push origin HEAD", 5)
+	add("git push -f origin HEAD", 1)
+	add("docker compose up -d", 4)
+	add("ls -la", 9)
+	add("kubectl get pods", 2)
+	add("aws sso login --profile <redacted>", 6)
+	add("make test", 3)
+	add("rm -rf build", 4)
+
+	store := Store{}
+	if err := store.Remember("run tests", "make test"); err != nil {
+		t.Fatalf("remember failed: %v", err)
+	}
+	got := store.Bootstrap(history, 10, func(command string) bool { return !strings.HasPrefix(command, "rm ") })
+	want := []Candidate{
+		{Query: "git push origin head", Command: "git push origin HEAD", Runs: 6, Variants: 2},
+		{Query: "docker compose up", Command: "docker compose up -d", Runs: 4, Variants: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected candidates\n got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestCommandQuery(t *testing.T) {
+	for command, want := range map[string]string{
+		"sudo systemctl restart nginx":      "systemctl restart nginx",
+		"AWS_PROFILE=dev terraform plan -x": "terraform plan",
+		"kubectl logs -f web | grep error":  "kubectl logs web",
+	} {
+		if got := CommandQuery(command); got != want {
+			t.Fatalf("CommandQuery(%q) = %q, want %q", command, got, want)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ashwch/ew/internal/hook"
+	"github.com/ashwch/ew/internal/state"
+)
+
+const fixesFileName = "learned_fixes.json"
+
+// A failure is paired with a command that succeeded within FixPairWindow and
+// fixPairLookahead commands of it, in the same shell and directory. A fix
+// learned once applies to that exact command; it takes
+// generalizedFixMinCount pairs before it applies to the same command with
+// other arguments.
+const (
+	FixPairWindow          = 5 * time.Minute
+	fixPairLookahead       = 3
+	generalizedFixMinCount = 2
+	maxLearnedFixes        = 300
+)
+
+// LearnedFix is a failed command shape and the command that fixed it.
+// Arguments the fix kept from the failure are slots, written {1}, {2}, ...,
+// so "python {1}" -> "python3 {1}" fixes python with any script.
+type LearnedFix struct {
+	Shape string `json:"shape"`
+	Fix   string `json:"fix"`
+	// Failed and Command are the last pair seen, verbatim.
+	Failed     string `json:"failed"`
+	Command    string `json:"command"`
+	Count      int    `json:"count"`
+	LastSeenAt string `json:"last_seen_at"`
+}
+
+type Fixes struct {
+	Entries []LearnedFix `json:"entries"`
+	// ScannedUntil is the timestamp of the last failure already paired or
+	// given up on, so each pair is only counted once.
+	ScannedUntil string `json:"scanned_until,omitempty"`
+}
+
+func LoadFixes() (Fixes, error) {
+	backend, err := state.Current()
+	if err != nil {
+		return Fixes{}, err
+	}
+	bytes, err := backend.Read(fixesFileName)
+	if err != nil {
+		return Fixes{}, fmt.Errorf("could not read learned fixes: %w", err)
+	}
+	if bytes == nil {
+		return Fixes{}, nil
+	}
+	var fixes Fixes
+	if err := json.Unmarshal(bytes, &fixes); err != nil {
+		return Fixes{}, fmt.Errorf("could not parse learned fixes: %w", err)
+	}
+	return fixes, nil
+}
+
+func SaveFixes(fixes Fixes) error {
+	fixes.prune()
+	payload, err := json.MarshalIndent(fixes, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode learned fixes: %w", err)
+	}
+	return writeStateDocument(fixesFileName, payload, "learned fixes")
+}
+
+// Learn pairs each failure in events (oldest first) that is newer than
+// ScannedUntil with a similar command that succeeded soon after it. It stops
+// at a failure whose window is still open at now, so it is looked at again
+// once the user has had time to fix it. It reports whether anything changed.
+func (f *Fixes) Learn(events []hook.Event, now time.Time) bool {
+	scanned, _ := time.Parse(time.RFC3339, f.ScannedUntil)
+	changed := false
+	for idx, ev := range events {
+		// 128 and up are signals, such as Ctrl-C, not failures to learn from.
+		if ev.ExitCode == 0 || ev.ExitCode >= 128 {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, ev.Timestamp)
+		if err != nil || !at.After(scanned) {
+			continue
+		}
+		fix, done := pairedSuccess(ev, at, events[idx+1:], now)
+		if !done {
+			break
+		}
+		if fix != "" {
+			f.record(ev.Command, fix, at)
+		}
+		f.ScannedUntil = ev.Timestamp
+		scanned = at
+		changed = true
+	}
+	return changed
+}
+
+// pairedSuccess looks through the commands after failed in its session for
+// a similar one that succeeded. done is false while the window is open and
+// the session has not run enough commands to tell.
+func pairedSuccess(failed hook.Event, at time.Time, later []hook.Event, now time.Time) (string, bool) {
+	failedTokens := strings.Fields(failed.Command)
+	seen := 0
+	for _, ev := range later {
+		if ev.SessionID != failed.SessionID {
+			continue
+		}
+		ranAt, err := time.Parse(time.RFC3339, ev.Timestamp)
+		if err != nil || ranAt.Sub(at) > FixPairWindow {
+			return "", true
+		}
+		if ev.ExitCode == 0 && ev.CWD == failed.CWD && similarCommands(failedTokens, strings.Fields(ev.Command)) {
+			return strings.Join(strings.Fields(ev.Command), " "), true
+		}
+		seen++
+		if seen >= fixPairLookahead {
+			return "", true
+		}
+	}
+	return "", now.Sub(at) > FixPairWindow
+}
+
+// similarCommands reports whether success looks like a corrected failed:
+// the same failed command under sudo, the same arguments with a near miss
+// of a program name ("pyhton" -> "python"), or the same program keeping at
+// least half of the failed command's words. A plain retry is not a fix.
+func similarCommands(failed, success []string) bool {
+	if len(failed) == 0 || len(success) == 0 || slices.Equal(failed, success) {
+		return false
+	}
+	if success[0] == "sudo" && slices.Equal(failed, success[1:]) {
+		return true
+	}
+	if failed[0] != success[0] {
+		return slices.Equal(failed[1:], success[1:]) && editDistance(failed[0], success[0]) <= 2
+	}
+	kept := map[string]bool{}
+	for _, token := range success {
+		kept[token] = true
+	}
+	shared := 0
+	for _, token := range failed {
+		if kept[token] {
+			shared++
+		}
+	}
+	return shared*2 >= len(failed)
+}
+
+func (f *Fixes) record(failed, command string, at time.Time) {
+	shape, fix := fixShape(strings.Fields(failed), strings.Fields(command))
+	stamp := at.UTC().Format(time.RFC3339)
+	failed = strings.Join(strings.Fields(failed), " ")
+	for idx, entry := range f.Entries {
+		if entry.Shape != shape || entry.Fix != fix {
+			continue
+		}
+		entry.Count++
+		entry.Failed, entry.Command, entry.LastSeenAt = failed, command, stamp
+		f.Entries[idx] = entry
+		return
+	}
+	f.Entries = append(f.Entries, LearnedFix{Shape: shape, Fix: fix, Failed: failed, Command: command, Count: 1, LastSeenAt: stamp})
+}
+
+// fixShape turns the arguments failed and success share into slots. The
+// program name, a subcommand word right after it ("push" in git push), and
+// flags are never slots.
+func fixShape(failed, success []string) (string, string) {
+	inSuccess := map[string]bool{}
+	for _, token := range success {
+		inSuccess[token] = true
+	}
+	slots := map[string]string{}
+	shape := make([]string, len(failed))
+	for idx, token := range failed {
+		shape[idx] = token
+		if idx == 0 || idx == 1 && isSubcommand(token) || strings.HasPrefix(token, "-") || !inSuccess[token] {
+			continue
+		}
+		if _, ok := slots[token]; !ok {
+			slots[token] = "{" + strconv.Itoa(len(slots)+1) + "}"
+		}
+		shape[idx] = slots[token]
+	}
+	fix := make([]string, len(success))
+	for idx, token := range success {
+		fix[idx] = token
+		if slot, ok := slots[token]; ok {
+			fix[idx] = slot
+		}
+	}
+	return strings.Join(shape, " "), strings.Join(fix, " ")
+}
+
+// Lookup returns the learned fix for failed. A fix learned for exactly this
+// command comes first, then the most often seen, then the most recent.
+func (f Fixes) Lookup(failed string) (string, LearnedFix, bool) {
+	tokens := strings.Fields(failed)
+	normalized := strings.Join(tokens, " ")
+	type candidate struct {
+		command string
+		exact   bool
+		entry   LearnedFix
+	}
+	var candidates []candidate
+	for _, entry := range f.Entries {
+		if entry.Failed == normalized {
+			candidates = append(candidates, candidate{entry.Command, true, entry})
+			continue
+		}
+		if entry.Count < generalizedFixMinCount {
+			continue
+		}
+		if command, ok := fillFix(entry.Shape, entry.Fix, tokens); ok && command != normalized {
+			candidates = append(candidates, candidate{command, false, entry})
+		}
+	}
+	if len(candidates) == 0 {
+		return "", LearnedFix{}, false
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.exact != b.exact {
+			return a.exact
+		}
+		if a.entry.Count != b.entry.Count {
+			return a.entry.Count > b.entry.Count
+		}
+		return a.entry.LastSeenAt > b.entry.LastSeenAt
+	})
+	return candidates[0].command, candidates[0].entry, true
+}
+
+// fillFix matches tokens against shape and writes what each slot took into
+// fix.
+func fillFix(shape, fix string, tokens []string) (string, bool) {
+	shapeTokens := strings.Fields(shape)
+	if len(shapeTokens) != len(tokens) {
+		return "", false
+	}
+	values := map[string]string{}
+	for idx, token := range shapeTokens {
+		if !isSlot(token) {
+			if token != tokens[idx] {
+				return "", false
+			}
+			continue
+		}
+		if strings.HasPrefix(tokens[idx], "-") {
+			return "", false
+		}
+		if bound, ok := values[token]; ok && bound != tokens[idx] {
+			return "", false
+		}
+		values[token] = tokens[idx]
+	}
+	out := strings.Fields(fix)
+	for idx, token := range out {
+		if value, ok := values[token]; ok {
+			out[idx] = value
+		}
+	}
+	return strings.Join(out, " "), true
+}
+
+func isSubcommand(token string) bool {
+	for _, r := range token {
+		if (r < 'a' || r > 'z') && r != '-' {
+			return false
+		}
+	}
+	return token != ""
+}
+
+func isSlot(token string) bool {
+	if len(token) < 3 || token[0] != '{' || token[len(token)-1] != '}' {
+		return false
+	}
+	_, err := strconv.Atoi(token[1 : len(token)-1])
+	return err == nil
+}
+
+// prune keeps the maxLearnedFixes most recently seen fixes.
+func (f *Fixes) prune() {
+	if len(f.Entries) <= maxLearnedFixes {
+		return
+	}
+	sort.SliceStable(f.Entries, func(i, j int) bool {
+		return f.Entries[i].LastSeenAt > f.Entries[j].LastSeenAt
+	})
+	f.Entries = f.Entries[:maxLearnedFixes]
+}
+
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(min(previous[j]+1, current[j-1]+1), previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
@@ -0,0 +1,101 @@
+package memory
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ashwch/ew/internal/hook"
+)
+
+func fixEvent(command string, exitCode int, at time.Time) hook.Event {
+	return hook.Event{Command: command, ExitCode: exitCode, CWD: "/repo", SessionID: "s1", Timestamp: at.UTC().Format(time.RFC3339)}
+}
+
+func TestLearnPairsFailureWithSimilarSuccess(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	events := []hook.Event{
+		fixEvent("git push origin feature", 1, start),
+		fixEvent("git status", 0, start.Add(10*time.Second)),
+		fixEvent("git push -u origin feature", 0, start.Add(30*time.Second)),
+		fixEvent("pyhton app.py", 127, start.Add(time.Minute)),
+		fixEvent("python app.py", 0, start.Add(70*time.Second)),
+		fixEvent("make build", 2, start.Add(2*time.Minute)),
+		fixEvent("make build", 0, start.Add(130*time.Second)),
+	}
+	var fixes Fixes
+	if !fixes.Learn(events, start.Add(time.Hour)) {
+		t.Fatalf("expected the scan to change the store")
+	}
+	if len(fixes.Entries) != 2 {
+		t.Fatalf("expected two learned fixes (a retry is not one), got %+v", fixes.Entries)
+	}
+	if got := fixes.Entries[0]; got.Shape != "git push {1} {2}" || got.Fix != "git push -u {1} {2}" {
+		t.Fatalf("unexpected shape: %+v", got)
+	}
+	if command, _, ok := fixes.Lookup("pyhton  app.py"); !ok || command != "python app.py" {
+		t.Fatalf("expected the typo fix, got %q %v", command, ok)
+	}
+
+	if fixes.Learn(events, start.Add(time.Hour)) || fixes.Entries[0].Count != 1 {
+		t.Fatalf("expected a second scan to count nothing twice: %+v", fixes.Entries)
+	}
+}
+
+func TestLearnWaitsForAnOpenWindowAndIgnoresOtherSessions(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	other := fixEvent("npm run test", 0, start.Add(5*time.Second))
+	other.SessionID = "s2"
+	events := []hook.Event{fixEvent("npm run tset", 1, start), other}
+
+	var fixes Fixes
+	if fixes.Learn(events, start.Add(time.Minute)) || fixes.ScannedUntil != "" {
+		t.Fatalf("expected the failure to stay open: %+v", fixes)
+	}
+	events = append(events, fixEvent("npm run test", 0, start.Add(90*time.Second)))
+	if !fixes.Learn(events, start.Add(2*time.Minute)) || len(fixes.Entries) != 1 {
+		t.Fatalf("expected the later success in the same session to pair: %+v", fixes)
+	}
+
+	var late Fixes
+	late.Learn([]hook.Event{fixEvent("ls buidl", 2, start), fixEvent("ls build", 0, start.Add(FixPairWindow+time.Second))}, start.Add(time.Hour))
+	if len(late.Entries) != 0 || late.ScannedUntil == "" {
+		t.Fatalf("expected a success outside the window to be ignored: %+v", late)
+	}
+}
+
+func TestLookupGeneralizesOnlyAfterRepeatedPairs(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	var fixes Fixes
+	fixes.record("git push origin one", "git push -u origin one", start)
+	if _, _, ok := fixes.Lookup("git push origin two"); ok {
+		t.Fatalf("expected one pair to apply only to its own command")
+	}
+	fixes.record("git push origin two", "git push -u origin two", start.Add(time.Hour))
+	command, entry, ok := fixes.Lookup("git push origin three")
+	if !ok || command != "git push -u origin three" || entry.Count != 2 {
+		t.Fatalf("expected the generalized fix, got %q %+v %v", command, entry, ok)
+	}
+	if _, _, ok := fixes.Lookup("git push origin --force"); ok {
+		t.Fatalf("expected a flag not to fill a slot")
+	}
+}
+
+func TestSimilarCommands(t *testing.T) {
+	cases := []struct {
+		failed, success string
+		want            bool
+	}{
+		{"apt install jq", "sudo apt install jq", true},
+		{"gti status", "git status", true},
+		{"git push", "git push --set-upstream origin main", true},
+		{"git push", "git push", false},
+		{"cargo build", "ls -la", false},
+		{"docker compose up", "docker ps", false},
+	}
+	for _, tc := range cases {
+		if got := similarCommands(strings.Fields(tc.failed), strings.Fields(tc.success)); got != tc.want {
+			t.Fatalf("similarCommands(%q, %q) = %v", tc.failed, tc.success, got)
+		}
+	}
+}
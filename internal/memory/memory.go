@@ -3,13 +3,11 @@ package memory
 import (
 	"encoding/json"
 	"fmt"
-	"os"
-	"path/filepath"
 	"sort"
 	"strings"
 	"time"
 
-	"github.com/ashwch/ew/internal/appdirs"
+	"github.com/ashwch/ew/internal/state"
 )
 
 const storeFileName = "memory.json"
@@ -23,10 +21,23 @@ type Entry struct {
 	Failures   int     `json:"failures"`
 	UpdatedAt  string  `json:"updated_at"`
 	LastUsedAt string  `json:"last_used_at,omitempty"`
+	// CWD, Repo and Shell record where the command last succeeded; see
+	// Origin.
+	CWD   string `json:"cwd,omitempty"`
+	Repo  string `json:"repo,omitempty"`
+	Shell string `json:"shell,omitempty"`
+	// Scope limits the entry to one project namespace (see
+	// Origin.Namespace); empty entries are global.
+	Scope string `json:"scope,omitempty"`
 }
 
 type Store struct {
 	Entries []Entry `json:"entries"`
+	// Revisions are the latest changes, oldest first; see Revision.
+	Revisions []Revision `json:"revisions,omitempty"`
+
+	action string
+	undone bool
 }
 
 type Match struct {
@@ -35,66 +46,67 @@ type Match struct {
 	Score   float64 `json:"score"`
 	Uses    int     `json:"uses"`
 	Exact   bool    `json:"exact"`
+	// Rejected is set when the user recently cancelled this command for the
+	// same query.
+	Rejected bool `json:"rejected,omitempty"`
+	// CWD is the directory the entry was learned in.
+	CWD string `json:"cwd,omitempty"`
+	// Elsewhere is set when the entry was learned inside a different
+	// repository than the one searched from.
+	Elsewhere bool `json:"elsewhere,omitempty"`
+	// Scope is the project namespace the entry is limited to, if any.
+	Scope string `json:"scope,omitempty"`
+	// Similarity is how close in meaning the entry's query is to the one
+	// searched for, when embeddings are on and it is close enough.
+	Similarity float64 `json:"similarity,omitempty"`
 }
 
+// Load reads the memory store from the state backend. The returned location
+// says where it lives, for messages.
 func Load() (Store, string, error) {
-	path, err := appdirs.StateFilePath(storeFileName)
+	backend, err := state.Current()
 	if err != nil {
 		return Store{}, "", err
 	}
-	bytes, err := os.ReadFile(path)
-	if os.IsNotExist(err) {
-		return Store{}, path, nil
-	}
+	location := backend.Location(storeFileName)
+	bytes, err := backend.Read(storeFileName)
 	if err != nil {
 		return Store{}, "", fmt.Errorf("could not read memory store: %w", err)
 	}
+	if bytes == nil {
+		return Store{}, location, nil
+	}
 	var store Store
 	if err := json.Unmarshal(bytes, &store); err != nil {
 		return Store{}, "", fmt.Errorf("could not parse memory store: %w", err)
 	}
 	store.normalize()
-	return store, path, nil
+	return store, location, nil
 }
 
-func Save(path string, store Store) error {
+// Save writes the store. Unless the store was just undone, what changed
+// since the saved copy is recorded as a revision (see Describe and Undo).
+func Save(store Store) error {
 	store.normalize()
+	if !store.undone {
+		if saved, _, err := Load(); err == nil {
+			store.Revisions = revise(saved, store, store.action, time.Now())
+		}
+	}
 	payload, err := json.MarshalIndent(store, "", "  ")
 	if err != nil {
 		return fmt.Errorf("could not encode memory store: %w", err)
 	}
-	if _, err := appdirs.EnsureStateDir(); err != nil {
-		return err
-	}
-	dir := filepath.Dir(path)
-	tempFile, err := os.CreateTemp(dir, ".ew-memory-*.json")
+	return writeStateDocument(storeFileName, payload, "memory")
+}
+
+func writeStateDocument(name string, payload []byte, label string) error {
+	backend, err := state.Current()
 	if err != nil {
-		return fmt.Errorf("could not create temp memory file: %w", err)
-	}
-	tempPath := tempFile.Name()
-	cleanup := func() {
-		_ = os.Remove(tempPath)
-	}
-	if _, err := tempFile.Write(payload); err != nil {
-		_ = tempFile.Close()
-		cleanup()
-		return fmt.Errorf("could not write temp memory file: %w", err)
-	}
-	if err := tempFile.Chmod(0o600); err != nil {
-		_ = tempFile.Close()
-		cleanup()
-		return fmt.Errorf("could not secure temp memory file: %w", err)
-	}
-	if err := tempFile.Close(); err != nil {
-		cleanup()
-		return fmt.Errorf("could not close temp memory file: %w", err)
-	}
-	if err := os.Rename(tempPath, path); err != nil {
-		cleanup()
-		return fmt.Errorf("could not atomically replace memory file: %w", err)
+		return err
 	}
-	if err := os.Chmod(path, 0o600); err != nil {
-		return fmt.Errorf("could not secure memory file: %w", err)
+	if err := backend.Write(name, payload); err != nil {
+		return fmt.Errorf("could not save %s: %w", label, err)
 	}
 	return nil
 }
@@ -114,7 +126,7 @@ func (s *Store) normalize() {
 		if entry.Score < 0 {
 			entry.Score = 0
 		}
-		key := normalize(entry.Query) + "|" + normalize(entry.Command)
+		key := entry.Key()
 		if _, exists := seen[key]; exists {
 			continue
 		}
@@ -199,6 +211,36 @@ func (s *Store) adjust(query, command string, delta float64, success bool, failu
 	return nil
 }
 
+// Edit rewrites the query and command of an entry while keeping its score
+// and counters. Editing into a pair that already exists merges the two and
+// keeps the higher-ranked one.
+func (s *Store) Edit(query, command, newQuery, newCommand string) error {
+	newQuery = strings.TrimSpace(newQuery)
+	newCommand = strings.TrimSpace(newCommand)
+	if newQuery == "" || newCommand == "" {
+		return fmt.Errorf("query and command are required")
+	}
+	idx := s.entryIndex(query, command)
+	if idx < 0 {
+		return fmt.Errorf("memory entry not found")
+	}
+	s.Entries[idx].Query = newQuery
+	s.Entries[idx].Command = newCommand
+	s.Entries[idx].UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	s.normalize()
+	return nil
+}
+
+// Delete removes one query+command entry and reports whether it existed.
+func (s *Store) Delete(query, command string) bool {
+	idx := s.entryIndex(query, command)
+	if idx < 0 {
+		return false
+	}
+	s.removeAt(idx)
+	return true
+}
+
 func (s *Store) ForgetQuery(query string) int {
 	query = normalize(query)
 	if query == "" {
@@ -218,6 +260,25 @@ func (s *Store) ForgetQuery(query string) int {
 	return removed
 }
 
+// Queries lists each learned query once, in store order, for shell
+// completion of `ew forget memory for ...` and the like.
+func (s *Store) Queries() []string {
+	seen := map[string]struct{}{}
+	out := make([]string, 0, len(s.Entries))
+	for _, entry := range s.Entries {
+		key := normalize(entry.Query)
+		if key == "" {
+			continue
+		}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		out = append(out, entry.Query)
+	}
+	return out
+}
+
 func (s *Store) Top(limit int) []Match {
 	if limit <= 0 {
 		limit = 8
@@ -230,6 +291,8 @@ func (s *Store) Top(limit int) []Match {
 			Score:   entry.Score,
 			Uses:    entry.Uses,
 			Exact:   false,
+			CWD:     entry.CWD,
+			Scope:   entry.Scope,
 		})
 		if len(out) >= limit {
 			break
@@ -239,6 +302,11 @@ func (s *Store) Top(limit int) []Match {
 }
 
 func (s *Store) Search(query string, limit int) []Match {
+	return s.SearchFrom(query, limit, Origin{})
+}
+
+// SearchFrom is Search ranked for a search made from here: see originBonus.
+func (s *Store) SearchFrom(query string, limit int, here Origin) []Match {
 	query = strings.TrimSpace(query)
 	if query == "" {
 		return nil
@@ -251,24 +319,43 @@ func (s *Store) Search(query string, limit int) []Match {
 		return nil
 	}
 	qTokens := splitTokens(qn)
+	namespace := here.Namespace()
 
-	matches := make([]Match, 0, len(s.Entries))
+	candidates := make([]Entry, 0, len(s.Entries))
 	for _, entry := range s.Entries {
-		en := normalize(entry.Query)
-		if en == "" {
+		if normalize(entry.Query) == "" || entry.Scope != "" && entry.Scope != namespace {
 			continue
 		}
-		base, exact := similarityScore(qn, qTokens, en)
-		if base <= 0 {
+		candidates = append(candidates, entry)
+	}
+	similar := similarTo(query, candidates)
+
+	matches := make([]Match, 0, len(candidates))
+	for idx, entry := range candidates {
+		base, exact := similarityScore(qn, qTokens, normalize(entry.Query))
+		semantic := 0.0
+		if similar != nil {
+			semantic = max(similar[idx], 0)
+		}
+		if base <= 0 && semantic <= 0 {
 			continue
 		}
-		score := base + (entry.Score * 0.7) + recencyBonus(entry.UpdatedAt)
+		base += semantic * semanticWeight
+		bonus, elsewhere := originBonus(entry, here)
+		if entry.Scope != "" {
+			bonus += namespaceBonus
+		}
+		score := base + (entry.Score * 0.7) + recencyBonus(entry.UpdatedAt) + bonus
 		matches = append(matches, Match{
-			Query:   entry.Query,
-			Command: entry.Command,
-			Score:   score,
-			Uses:    entry.Uses,
-			Exact:   exact,
+			Query:      entry.Query,
+			Command:    entry.Command,
+			Score:      score,
+			Uses:       entry.Uses,
+			Exact:      exact,
+			CWD:        entry.CWD,
+			Elsewhere:  elsewhere,
+			Scope:      entry.Scope,
+			Similarity: semantic,
 		})
 	}
 
@@ -287,6 +374,11 @@ func (s *Store) Search(query string, limit int) []Match {
 	return matches
 }
 
+// Key identifies an entry by its normalized query and command.
+func (e Entry) Key() string {
+	return normalize(e.Query) + "|" + normalize(e.Command)
+}
+
 func (s *Store) entryIndex(query, command string) int {
 	qn := normalize(query)
 	cn := normalize(command)
@@ -3,6 +3,7 @@ package memory
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 )
 
@@ -73,7 +74,7 @@ func TestLoadSaveRoundTrip(t *testing.T) {
 	if len(loaded.Entries) != 0 {
 		t.Fatalf("expected empty store before save")
 	}
-	if err := Save(path, store); err != nil {
+	if err := Save(store); err != nil {
 		t.Fatalf("save failed: %v", err)
 	}
 
@@ -111,3 +112,179 @@ func TestLearnBoostsSuccessfulRuns(t *testing.T) {
 		t.Fatalf("expected repeated success to boost memory score")
 	}
 }
+
+func TestEditAndDeleteEntries(t *testing.T) {
+	store := Store{}
+	if err := store.Remember("push branch", "git push"); err != nil {
+		t.Fatalf("remember failed: %v", err)
+	}
+	if err := store.Edit("push branch", "git push", "push current branch", "git push origin HEAD"); err != nil {
+		t.Fatalf("edit failed: %v", err)
+	}
+	if store.Entries[0].Query != "push current branch" || store.Entries[0].Command != "git push origin HEAD" {
+		t.Fatalf("unexpected entry after edit: %+v", store.Entries[0])
+	}
+	if err := store.Edit("push branch", "git push", "x", "y"); err == nil {
+		t.Fatalf("expected error editing a missing entry")
+	}
+	if err := store.Edit("push current branch", "git push origin HEAD", "", "y"); err == nil {
+		t.Fatalf("expected error for an empty query")
+	}
+	if !store.Delete("Push Current Branch", "git push origin HEAD") || len(store.Entries) != 0 {
+		t.Fatalf("expected normalized delete to remove the entry")
+	}
+}
+
+func TestQueriesListsEachQueryOnce(t *testing.T) {
+	store := Store{}
+	for _, pair := range [][2]string{
+		{"push current branch", "git push origin HEAD"},
+		{"push current branch", "git push"},
+		{"list pods", "kubectl get pods"},
+	} {
+		if err := store.Remember(pair[0], pair[1]); err != nil {
+			t.Fatalf("remember failed: %v", err)
+		}
+	}
+	got := store.Queries()
+	if len(got) != 2 || !slices.Contains(got, "push current branch") || !slices.Contains(got, "list pods") {
+		t.Fatalf("expected two distinct queries, got %v", got)
+	}
+}
+
+func TestSearchFromPrefersEntriesLearnedHere(t *testing.T) {
+	root := t.TempDir()
+	api := filepath.Join(root, "api")
+	web := filepath.Join(root, "web")
+	for _, dir := range []string{api, web} {
+		if err := os.MkdirAll(filepath.Join(dir, ".git"), 0o755); err != nil {
+			t.Fatalf("mkdir failed: %v", err)
+		}
+	}
+	if origin := OriginFor(filepath.Join(api, "cmd"), "zsh"); origin.Repo != api || origin.Shell != "zsh" {
+		t.Fatalf("expected the repository found from a subdirectory, got %+v", origin)
+	}
+
+	store := Store{}
+	if err := store.LearnAt("run tests", "make test-api", true, OriginFor(api, "zsh")); err != nil {
+		t.Fatalf("learn failed: %v", err)
+	}
+	if err := store.LearnAt("run tests", "npm test", true, OriginFor(web, "zsh")); err != nil {
+		t.Fatalf("learn failed: %v", err)
+	}
+
+	matches := store.SearchFrom("run tests", 5, OriginFor(filepath.Join(web, "src"), "zsh"))
+	if len(matches) != 2 || matches[0].Command != "npm test" || matches[0].Elsewhere || !matches[1].Elsewhere {
+		t.Fatalf("expected the web entry first and the api entry marked elsewhere, got %+v", matches)
+	}
+	if matches[0].CWD != web {
+		t.Fatalf("expected the origin directory on the match, got %q", matches[0].CWD)
+	}
+
+	plain := store.Search("run tests", 5)
+	if plain[0].Elsewhere || plain[1].Elsewhere {
+		t.Fatalf("expected no origin ranking without a search origin, got %+v", plain)
+	}
+}
+
+func TestRememberInScopesEntriesToAProject(t *testing.T) {
+	root := t.TempDir()
+	api := filepath.Join(root, "api")
+	web := filepath.Join(root, "web")
+	for _, dir := range []string{api, web} {
+		if err := os.MkdirAll(filepath.Join(dir, ".git"), 0o755); err != nil {
+			t.Fatalf("mkdir failed: %v", err)
+		}
+	}
+	here := OriginFor(filepath.Join(api, "cmd"), "zsh")
+	if here.Namespace() != api {
+		t.Fatalf("expected the repository as the namespace, got %q", here.Namespace())
+	}
+	if outside := OriginFor(root, "zsh"); outside.Namespace() != root {
+		t.Fatalf("expected the directory as the namespace outside a repository, got %q", outside.Namespace())
+	}
+
+	store := Store{}
+	if err := store.Remember("run tests", "pytest"); err != nil {
+		t.Fatalf("remember failed: %v", err)
+	}
+	if err := store.RememberIn("run tests", "go test ./...", here, here.Namespace()); err != nil {
+		t.Fatalf("remember failed: %v", err)
+	}
+
+	matches := store.SearchFrom("run tests", 5, here)
+	if len(matches) != 2 || matches[0].Command != "go test ./..." || matches[0].Scope != api || matches[1].Command != "pytest" {
+		t.Fatalf("expected the project entry before the global one, got %+v", matches)
+	}
+	if matches := store.SearchFrom("run tests", 5, OriginFor(web, "zsh")); len(matches) != 1 || matches[0].Command != "pytest" {
+		t.Fatalf("expected only the global entry in another project, got %+v", matches)
+	}
+	if matches := store.Search("run tests", 5); len(matches) != 1 {
+		t.Fatalf("expected scoped entries to stay out of a search without an origin, got %+v", matches)
+	}
+
+	if err := store.RememberIn("run tests", "go test ./...", here, ""); err != nil {
+		t.Fatalf("remember failed: %v", err)
+	}
+	if matches := store.SearchFrom("run tests", 5, OriginFor(web, "zsh")); len(matches) != 2 {
+		t.Fatalf("expected the entry to be global again, got %+v", matches)
+	}
+}
+
+func TestSearchMatchesSimilarQueries(t *testing.T) {
+	t.Cleanup(func() { SetSimilarity(nil) })
+	store := Store{}
+	if err := store.Remember("kill process on port", "lsof -ti :3000 | xargs kill"); err != nil {
+		t.Fatalf("remember failed: %v", err)
+	}
+	if err := store.Remember("list pods", "kubectl get pods"); err != nil {
+		t.Fatalf("remember failed: %v", err)
+	}
+	if matches := store.Search("free up a busy socket", 5); len(matches) != 0 {
+		t.Fatalf("expected no word matches, got %+v", matches)
+	}
+
+	SetSimilarity(func(query string, texts []string) []float64 {
+		scores := make([]float64, len(texts))
+		for idx, text := range texts {
+			if text == "kill process on port" {
+				scores[idx] = 0.9
+			}
+		}
+		return scores
+	})
+	matches := store.Search("free up a busy socket", 5)
+	if len(matches) != 1 || matches[0].Query != "kill process on port" || matches[0].Similarity != 0.9 {
+		t.Fatalf("expected the similar entry, got %+v", matches)
+	}
+}
+
+func TestOriginForSharesRepoAcrossLinkedWorktrees(t *testing.T) {
+	root := t.TempDir()
+	main := filepath.Join(root, "app")
+	linked := filepath.Join(root, "app-fix")
+	admin := filepath.Join(main, ".git", "worktrees", "app-fix")
+	for _, dir := range []string{admin, linked} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir failed: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(admin, "commondir"), []byte("../..\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(linked, ".git"), []byte("gitdir: "+admin+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	store := Store{}
+	if err := store.LearnAt("run tests", "make test", true, OriginFor(main, "zsh")); err != nil {
+		t.Fatalf("learn failed: %v", err)
+	}
+	origin := OriginFor(linked, "zsh")
+	if origin.Repo != main {
+		t.Fatalf("expected the worktree to belong to %s, got %+v", main, origin)
+	}
+	if matches := store.SearchFrom("run tests", 5, origin); len(matches) != 1 || matches[0].Elsewhere {
+		t.Fatalf("expected the main checkout's entry to count as this repo, got %+v", matches)
+	}
+}
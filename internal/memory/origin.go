@@ -0,0 +1,125 @@
+package memory
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/ashwch/ew/internal/workspace"
+)
+
+// Origin is where a command was learned: the directory, the repository
+// that directory belongs to, and the shell.
+type Origin struct {
+	CWD   string `json:"cwd,omitempty"`
+	Repo  string `json:"repo,omitempty"`
+	Shell string `json:"shell,omitempty"`
+}
+
+// OriginFor describes dir and shell as an Origin. Repo is the main
+// checkout of the repository dir belongs to, so linked worktrees of one
+// repository share it, or empty outside a repository.
+func OriginFor(dir string, shell string) Origin {
+	origin := Origin{Shell: strings.TrimSpace(shell)}
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		return origin
+	}
+	origin.CWD = filepath.Clean(dir)
+	if repo, ok := workspace.DetectRepo(origin.CWD); ok {
+		origin.Repo = repo.Root
+	}
+	return origin
+}
+
+// Namespace is the project an entry saved here is scoped to: the
+// repository, or the directory outside one.
+func (o Origin) Namespace() string {
+	if o.Repo != "" {
+		return o.Repo
+	}
+	return o.CWD
+}
+
+func (o Origin) empty() bool {
+	return o.CWD == "" && o.Repo == "" && o.Shell == ""
+}
+
+// RememberAt is Remember that also records where the command was saved.
+func (s *Store) RememberAt(query, command string, origin Origin) error {
+	if err := s.Remember(query, command); err != nil {
+		return err
+	}
+	s.stamp(query, command, origin)
+	return nil
+}
+
+// RememberIn is RememberAt that also sets the entry's scope: namespace
+// limits it to that project, and empty makes it global again. An entry that
+// was scoped elsewhere moves, since there is one entry per query and
+// command.
+func (s *Store) RememberIn(query, command string, origin Origin, namespace string) error {
+	if err := s.RememberAt(query, command, origin); err != nil {
+		return err
+	}
+	if idx := s.entryIndex(query, command); idx >= 0 {
+		s.Entries[idx].Scope = namespace
+	}
+	return nil
+}
+
+// LearnAt is Learn that also records where the command ran. An entry keeps
+// the origin of its latest success, so it follows a command that moved.
+func (s *Store) LearnAt(query, command string, success bool, origin Origin) error {
+	if err := s.Learn(query, command, success); err != nil {
+		return err
+	}
+	if success {
+		s.stamp(query, command, origin)
+	}
+	return nil
+}
+
+func (s *Store) stamp(query, command string, origin Origin) {
+	if origin.empty() {
+		return
+	}
+	if idx := s.entryIndex(query, command); idx >= 0 {
+		s.Entries[idx].CWD = origin.CWD
+		s.Entries[idx].Repo = origin.Repo
+		s.Entries[idx].Shell = origin.Shell
+	}
+}
+
+// namespaceBonus ranks an entry scoped to the project searched from above
+// global entries for the same query; entries scoped to other projects are
+// not searched at all.
+const namespaceBonus = 8
+
+// originBonus adjusts a match for where its entry was learned. Entries
+// learned in this directory or repository rank higher; entries learned
+// inside another repository rank lower and are marked elsewhere, since
+// "run tests" there is likely a different command here. Entries learned
+// outside any repository, and entries from before origins were recorded,
+// are left alone.
+func originBonus(entry Entry, here Origin) (bonus float64, elsewhere bool) {
+	if here.empty() {
+		return 0, false
+	}
+	switch {
+	case entry.CWD != "" && entry.CWD == here.CWD:
+		bonus += 6
+	case entry.Repo != "" && entry.Repo == here.Repo:
+		bonus += 4
+	case entry.Repo != "":
+		bonus -= 6
+		elsewhere = true
+	}
+	if entry.Shell != "" && here.Shell != "" {
+		if entry.Shell == here.Shell {
+			bonus += 1
+		} else {
+			bonus -= 2
+		}
+	}
+	return bonus, elsewhere
+}
@@ -0,0 +1,182 @@
+package memory
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ashwch/ew/internal/state"
+)
+
+const rejectionsFileName = "rejections.json"
+
+// A rejection loses half its weight every RejectionHalfLife, so a command
+// cancelled once stops being flagged after a few weeks and people can change
+// their minds.
+const (
+	RejectionHalfLife    = 14 * 24 * time.Hour
+	rejectedWeightMin    = 0.5
+	rejectionPruneWeight = 0.05
+	maxRejectionEntries  = 500
+)
+
+// Rejection stores only a hash of the query and command, so the file never
+// holds the text of commands the user turned down.
+type Rejection struct {
+	Key            string `json:"key"`
+	Count          int    `json:"count"`
+	LastRejectedAt string `json:"last_rejected_at"`
+}
+
+type Rejections struct {
+	Entries []Rejection `json:"entries"`
+}
+
+func LoadRejections() (Rejections, string, error) {
+	backend, err := state.Current()
+	if err != nil {
+		return Rejections{}, "", err
+	}
+	location := backend.Location(rejectionsFileName)
+	bytes, err := backend.Read(rejectionsFileName)
+	if err != nil {
+		return Rejections{}, "", fmt.Errorf("could not read rejections: %w", err)
+	}
+	if bytes == nil {
+		return Rejections{}, location, nil
+	}
+	var rejections Rejections
+	if err := json.Unmarshal(bytes, &rejections); err != nil {
+		return Rejections{}, "", fmt.Errorf("could not parse rejections: %w", err)
+	}
+	return rejections, location, nil
+}
+
+func SaveRejections(rejections Rejections, now time.Time) error {
+	rejections.prune(now)
+	payload, err := json.MarshalIndent(rejections, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode rejections: %w", err)
+	}
+	return writeStateDocument(rejectionsFileName, payload, "rejections")
+}
+
+// RecordRejection loads, updates and saves the rejection store in one go.
+func RecordRejection(query, command string) error {
+	rejections, _, err := LoadRejections()
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	if err := rejections.Reject(query, command, now); err != nil {
+		return err
+	}
+	return SaveRejections(rejections, now)
+}
+
+// Reject notes that command was turned down for query. Earlier rejections
+// are decayed first so the count reflects recent behaviour.
+func (r *Rejections) Reject(query, command string, now time.Time) error {
+	key := RejectionKey(query, command)
+	if key == "" {
+		return fmt.Errorf("command is required")
+	}
+	stamp := now.UTC().Format(time.RFC3339)
+	for idx, entry := range r.Entries {
+		if entry.Key != key {
+			continue
+		}
+		decayed := int(math.Round(decayedWeight(entry, now)))
+		entry.Count = decayed + 1
+		entry.LastRejectedAt = stamp
+		r.Entries[idx] = entry
+		return nil
+	}
+	r.Entries = append(r.Entries, Rejection{Key: key, Count: 1, LastRejectedAt: stamp})
+	return nil
+}
+
+// Weight is the decayed rejection count for query+command; 0 when the pair
+// was never rejected.
+func (r Rejections) Weight(query, command string, now time.Time) float64 {
+	key := RejectionKey(query, command)
+	if key == "" {
+		return 0
+	}
+	for _, entry := range r.Entries {
+		if entry.Key == key {
+			return decayedWeight(entry, now)
+		}
+	}
+	return 0
+}
+
+func (r Rejections) Rejected(query, command string, now time.Time) bool {
+	return r.Weight(query, command, now) >= rejectedWeightMin
+}
+
+// Downrank divides the score of each rejected match by 1+weight, flags it,
+// and re-sorts the matches.
+func (r Rejections) Downrank(query string, matches []Match, now time.Time) []Match {
+	if len(r.Entries) == 0 {
+		return matches
+	}
+	for idx := range matches {
+		weight := r.Weight(query, matches[idx].Command, now)
+		if weight <= 0 {
+			continue
+		}
+		matches[idx].Score = matches[idx].Score / (1 + weight)
+		matches[idx].Rejected = weight >= rejectedWeightMin
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+	return matches
+}
+
+func RejectionKey(query, command string) string {
+	command = normalize(command)
+	if command == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(normalize(query) + "\n" + command))
+	return hex.EncodeToString(sum[:])
+}
+
+func (r *Rejections) prune(now time.Time) {
+	kept := make([]Rejection, 0, len(r.Entries))
+	for _, entry := range r.Entries {
+		if strings.TrimSpace(entry.Key) == "" || decayedWeight(entry, now) < rejectionPruneWeight {
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	sort.SliceStable(kept, func(i, j int) bool {
+		return kept[i].LastRejectedAt > kept[j].LastRejectedAt
+	})
+	if len(kept) > maxRejectionEntries {
+		kept = kept[:maxRejectionEntries]
+	}
+	r.Entries = kept
+}
+
+func decayedWeight(entry Rejection, now time.Time) float64 {
+	if entry.Count <= 0 {
+		return 0
+	}
+	at, err := time.Parse(time.RFC3339, strings.TrimSpace(entry.LastRejectedAt))
+	if err != nil {
+		return 0
+	}
+	age := now.Sub(at)
+	if age < 0 {
+		age = 0
+	}
+	return float64(entry.Count) * math.Pow(0.5, float64(age)/float64(RejectionHalfLife))
+}
@@ -0,0 +1,68 @@
+package memory
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRejectionsDecayOverTime(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	var rejections Rejections
+	if err := rejections.Reject("push branch", "git push --force", now); err != nil {
+		t.Fatalf("reject failed: %v", err)
+	}
+	if !rejections.Rejected("Push  Branch", "git push --force", now) {
+		t.Fatalf("expected normalized query+command to be rejected")
+	}
+	if rejections.Rejected("push branch", "git push", now) {
+		t.Fatalf("expected a different command to be unaffected")
+	}
+	if rejections.Rejected("push branch", "git push --force", now.Add(RejectionHalfLife*2)) {
+		t.Fatalf("expected a single old rejection to decay below the flag threshold")
+	}
+
+	_ = rejections.Reject("push branch", "git push --force", now)
+	if got := rejections.Weight("push branch", "git push --force", now); got != 2 {
+		t.Fatalf("expected repeated rejection to count twice, got %v", got)
+	}
+}
+
+func TestDownrankFlagsAndReordersRejectedMatches(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	var rejections Rejections
+	_ = rejections.Reject("clean build", "make clean", now)
+	matches := rejections.Downrank("clean build", []Match{
+		{Command: "make clean", Score: 30},
+		{Command: "rm -rf build", Score: 20},
+	}, now)
+	if matches[0].Command != "rm -rf build" || !matches[1].Rejected || matches[1].Score != 15 {
+		t.Fatalf("expected rejected match to be halved and moved down, got %+v", matches)
+	}
+}
+
+func TestRecordRejectionStoresHashesOnly(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, ".local", "state"))
+
+	if err := RecordRejection("drop db", "dropdb production"); err != nil {
+		t.Fatalf("RecordRejection failed: %v", err)
+	}
+	rejections, path, err := LoadRejections()
+	if err != nil {
+		t.Fatalf("LoadRejections failed: %v", err)
+	}
+	if !rejections.Rejected("drop db", "dropdb production", time.Now().UTC()) {
+		t.Fatalf("expected persisted rejection")
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read rejections failed: %v", err)
+	}
+	if strings.Contains(string(raw), "dropdb") {
+		t.Fatalf("expected rejections file to hold hashes only, got %s", raw)
+	}
+}
@@ -0,0 +1,125 @@
+package memory
+
+import (
+	"time"
+)
+
+const (
+	// maxRevisions is how many of the latest changes can be undone.
+	maxRevisions = 20
+	// tombstoneRevisions bounds the older changes kept past maxRevisions
+	// because they removed entries, so a forget is not pushed out by
+	// everyday learning before tombstoneAge.
+	tombstoneRevisions = 30
+	tombstoneAge       = 30 * 24 * time.Hour
+)
+
+// Revision is one saved change to the store, kept so it can be undone.
+// Before holds each entry the change edited or removed as it was before;
+// removed entries live on here as tombstones. Added are the keys of the
+// entries it created.
+type Revision struct {
+	At      string   `json:"at"`
+	Action  string   `json:"action"`
+	Before  []Entry  `json:"before,omitempty"`
+	Added   []string `json:"added,omitempty"`
+	Removed int      `json:"removed,omitempty"`
+}
+
+// Describe names the change the next Save records, such as
+// "forget memory for deploy", for `memory undo` to report.
+func (s *Store) Describe(action string) {
+	s.action = action
+}
+
+// LastRevision is the change Undo would revert.
+func (s *Store) LastRevision() (Revision, bool) {
+	if len(s.Revisions) == 0 {
+		return Revision{}, false
+	}
+	return s.Revisions[len(s.Revisions)-1], true
+}
+
+// Undo reverts the latest revision: entries it added are dropped and the
+// ones it edited or removed are put back as they were. Saving the store
+// afterwards does not record the undo as a revision of its own, so undoing
+// again reverts the change before.
+func (s *Store) Undo() (Revision, bool) {
+	last, ok := s.LastRevision()
+	if !ok {
+		return Revision{}, false
+	}
+	s.Revisions = s.Revisions[:len(s.Revisions)-1]
+	drop := map[string]bool{}
+	for _, key := range last.Added {
+		drop[key] = true
+	}
+	for _, entry := range last.Before {
+		drop[entry.Key()] = true
+	}
+	kept := make([]Entry, 0, len(s.Entries)+len(last.Before))
+	for _, entry := range s.Entries {
+		if !drop[entry.Key()] {
+			kept = append(kept, entry)
+		}
+	}
+	s.Entries = append(kept, last.Before...)
+	s.normalize()
+	s.undone = true
+	return last, true
+}
+
+// revise returns the revisions to save with after, recording what changed
+// since before. Nothing is recorded when nothing changed.
+func revise(before, after Store, action string, now time.Time) []Revision {
+	previous := make(map[string]Entry, len(before.Entries))
+	for _, entry := range before.Entries {
+		previous[entry.Key()] = entry
+	}
+	revision := Revision{At: now.UTC().Format(time.RFC3339), Action: action}
+	if revision.Action == "" {
+		revision.Action = "update memory"
+	}
+	current := make(map[string]bool, len(after.Entries))
+	for _, entry := range after.Entries {
+		key := entry.Key()
+		current[key] = true
+		old, existed := previous[key]
+		switch {
+		case !existed:
+			revision.Added = append(revision.Added, key)
+		case old != entry:
+			revision.Before = append(revision.Before, old)
+		}
+	}
+	for _, entry := range before.Entries {
+		if !current[entry.Key()] {
+			revision.Before = append(revision.Before, entry)
+			revision.Removed++
+		}
+	}
+	if len(revision.Added) == 0 && len(revision.Before) == 0 {
+		return before.Revisions
+	}
+	return pruneRevisions(append(append([]Revision(nil), before.Revisions...), revision), now)
+}
+
+// pruneRevisions keeps the latest maxRevisions, and older ones that removed
+// entries until they are tombstoneAge old.
+func pruneRevisions(revisions []Revision, now time.Time) []Revision {
+	if len(revisions) <= maxRevisions {
+		return revisions
+	}
+	cutoff := len(revisions) - maxRevisions
+	kept := make([]Revision, 0, len(revisions))
+	for _, revision := range revisions[:cutoff] {
+		at, err := time.Parse(time.RFC3339, revision.At)
+		if revision.Removed > 0 && err == nil && now.Sub(at) < tombstoneAge {
+			kept = append(kept, revision)
+		}
+	}
+	if len(kept) > tombstoneRevisions {
+		kept = kept[len(kept)-tombstoneRevisions:]
+	}
+	return append(kept, revisions[cutoff:]...)
+}
@@ -0,0 +1,77 @@
+package memory
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUndoRestoresForgottenEntries(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, ".local", "state"))
+
+	store := Store{}
+	store.Describe("remember deploy")
+	_ = store.Remember("deploy", "make deploy")
+	_ = store.Remember("deploy", "make ship")
+	if err := Save(store); err != nil {
+		t.Fatal(err)
+	}
+	store, _, _ = Load()
+	store.Describe("forget memory for deploy")
+	if removed := store.ForgetQuery("deploy"); removed != 2 {
+		t.Fatalf("expected two entries forgotten, got %d", removed)
+	}
+	if err := Save(store); err != nil {
+		t.Fatal(err)
+	}
+
+	store, _, _ = Load()
+	revision, ok := store.Undo()
+	if !ok || revision.Action != "forget memory for deploy" || revision.Removed != 2 {
+		t.Fatalf("expected to undo the forget, got %+v %v", revision, ok)
+	}
+	if err := Save(store); err != nil {
+		t.Fatal(err)
+	}
+	store, _, _ = Load()
+	if len(store.Entries) != 2 || len(store.Revisions) != 1 {
+		t.Fatalf("expected both entries back and the undo not recorded, got %+v", store)
+	}
+
+	if revision, ok := store.Undo(); !ok || len(revision.Added) != 2 || len(store.Entries) != 0 {
+		t.Fatalf("expected undoing again to drop the remembered entries, got %+v %+v", revision, store.Entries)
+	}
+	if _, ok := store.Undo(); ok {
+		t.Fatalf("expected nothing left to undo")
+	}
+}
+
+func TestReviseRecordsOnlyChanges(t *testing.T) {
+	before := Store{Entries: []Entry{{Query: "logs", Command: "docker logs web", Score: 20}}}
+	if got := revise(before, before, "", time.Now()); len(got) != 0 {
+		t.Fatalf("expected no revision without a change, got %+v", got)
+	}
+	after := Store{Entries: []Entry{{Query: "logs", Command: "docker logs web", Score: 30}}}
+	got := revise(before, after, "", time.Now())
+	if len(got) != 1 || got[0].Action != "update memory" || len(got[0].Before) != 1 || got[0].Before[0].Score != 20 || got[0].Removed != 0 {
+		t.Fatalf("expected the old score to be kept, got %+v", got)
+	}
+}
+
+func TestPruneRevisionsKeepsRecentTombstones(t *testing.T) {
+	now := time.Now()
+	var revisions []Revision
+	old := now.Add(-40 * 24 * time.Hour).UTC().Format(time.RFC3339)
+	recent := now.Add(-time.Hour).UTC().Format(time.RFC3339)
+	revisions = append(revisions, Revision{At: old, Action: "forget old", Removed: 1}, Revision{At: recent, Action: "forget recent", Removed: 3})
+	for idx := range maxRevisions + 5 {
+		revisions = append(revisions, Revision{At: recent, Action: fmt.Sprintf("learn %d", idx)})
+	}
+	kept := pruneRevisions(revisions, now)
+	if len(kept) != maxRevisions+1 || kept[0].Action != "forget recent" || kept[len(kept)-1].Action != fmt.Sprintf("learn %d", maxRevisions+4) {
+		t.Fatalf("expected the latest revisions and the recent forget, got %d starting %q", len(kept), kept[0].Action)
+	}
+}
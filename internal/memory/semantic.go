@@ -0,0 +1,39 @@
+package memory
+
+import "sync/atomic"
+
+// semanticWeight is what a similarity of 1 adds to a match's score, about
+// what sharing three words with the stored query is worth.
+const semanticWeight = 16
+
+var similarity atomic.Pointer[func(query string, texts []string) []float64]
+
+// SetSimilarity makes search also match entries whose query means the same
+// as the one searched for; it is the embeddings.enabled setting. fn returns
+// a score in (0, 1] for each text close enough to query and 0 for the rest,
+// or nil when it cannot tell. A nil fn turns it off.
+func SetSimilarity(fn func(query string, texts []string) []float64) {
+	if fn == nil {
+		similarity.Store(nil)
+		return
+	}
+	similarity.Store(&fn)
+}
+
+// similarTo scores each entry's query against query, or returns nil when
+// no similarity is set or it could not tell.
+func similarTo(query string, entries []Entry) []float64 {
+	fn := similarity.Load()
+	if fn == nil || len(entries) == 0 {
+		return nil
+	}
+	texts := make([]string, len(entries))
+	for idx, entry := range entries {
+		texts[idx] = entry.Query
+	}
+	scores := (*fn)(query, texts)
+	if len(scores) != len(entries) {
+		return nil
+	}
+	return scores
+}
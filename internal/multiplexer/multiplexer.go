@@ -0,0 +1,272 @@
+// Package multiplexer lists the tmux sessions and windows and the wezterm
+// workspaces on this machine, ranks them against a "switch to my api
+// project" query, and builds the command that jumps there.
+package multiplexer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	ewrt "github.com/ashwch/ew/internal/runtime"
+)
+
+// Multiplexers ew can switch between.
+const (
+	Tmux    = "tmux"
+	WezTerm = "wezterm"
+)
+
+const listTimeout = 2 * time.Second
+
+// Target is a place a switch can land: a tmux window (Window >= 0) or
+// session, or a wezterm workspace. Path is the working directory of its
+// active pane, which is often what "my api project" refers to.
+type Target struct {
+	Multiplexer string `json:"multiplexer"`
+	Session     string `json:"session"`
+	Window      int    `json:"window"`
+	WindowName  string `json:"window_name,omitempty"`
+	PaneID      int    `json:"pane_id,omitempty"`
+	Path        string `json:"path,omitempty"`
+	Attached    bool   `json:"attached,omitempty"`
+}
+
+// Match is a Target scored against a query.
+type Match struct {
+	Target
+	Score   float64 `json:"score"`
+	Command string  `json:"command"`
+}
+
+// Name renders the target the way its multiplexer names it, e.g. "api:2
+// (server)" for a tmux window.
+func (t Target) Name() string {
+	if t.Multiplexer != Tmux || t.Window < 0 {
+		return t.Session
+	}
+	name := fmt.Sprintf("%s:%d", t.Session, t.Window)
+	if t.WindowName != "" {
+		name += " (" + t.WindowName + ")"
+	}
+	return name
+}
+
+// output runs a listing command; swapped out in tests.
+var output = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, listTimeout)
+	defer cancel()
+	return exec.CommandContext(ctx, name, args...).Output()
+}
+
+// lookPath is swapped out in tests.
+var lookPath = exec.LookPath
+
+// Installed returns the multiplexers found on PATH.
+func Installed() []string {
+	var found []string
+	for _, name := range []string{Tmux, WezTerm} {
+		if _, err := lookPath(name); err == nil {
+			found = append(found, name)
+		}
+	}
+	return found
+}
+
+// List returns every target of every installed multiplexer that has a
+// server running. A multiplexer that is installed but not running simply
+// contributes nothing.
+func List(ctx context.Context) []Target {
+	var targets []Target
+	for _, name := range Installed() {
+		switch name {
+		case Tmux:
+			if out, err := output(ctx, Tmux, "list-windows", "-a", "-F", tmuxFormat); err == nil {
+				targets = append(targets, parseTmux(string(out))...)
+			}
+		case WezTerm:
+			if out, err := output(ctx, WezTerm, "cli", "list", "--format", "json"); err == nil {
+				targets = append(targets, parseWezTerm(out)...)
+			}
+		}
+	}
+	return targets
+}
+
+const tmuxFormat = "#{session_name}\t#{window_index}\t#{window_name}\t#{pane_current_path}\t#{session_attached}"
+
+// parseTmux reads list-windows output in tmuxFormat, adding a session-level
+// target ahead of each session's windows.
+func parseTmux(out string) []Target {
+	var targets []Target
+	seen := map[string]bool{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(strings.TrimRight(line, "\r"), "\t")
+		if len(fields) < 5 || fields[0] == "" {
+			continue
+		}
+		index, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+		attached := fields[4] != "" && fields[4] != "0"
+		if !seen[fields[0]] {
+			seen[fields[0]] = true
+			targets = append(targets, Target{Multiplexer: Tmux, Session: fields[0], Window: -1, Path: fields[3], Attached: attached})
+		}
+		targets = append(targets, Target{
+			Multiplexer: Tmux,
+			Session:     fields[0],
+			Window:      index,
+			WindowName:  fields[2],
+			Path:        fields[3],
+			Attached:    attached,
+		})
+	}
+	return targets
+}
+
+type wezTermPane struct {
+	Workspace string `json:"workspace"`
+	PaneID    int    `json:"pane_id"`
+	CWD       string `json:"cwd"`
+	IsActive  bool   `json:"is_active"`
+}
+
+// parseWezTerm turns `wezterm cli list --format json` panes into one
+// target per workspace, landing on its first active pane.
+func parseWezTerm(out []byte) []Target {
+	var panes []wezTermPane
+	if err := json.Unmarshal(out, &panes); err != nil {
+		return nil
+	}
+	byWorkspace := map[string]int{}
+	var targets []Target
+	for _, pane := range panes {
+		if pane.Workspace == "" {
+			continue
+		}
+		idx, ok := byWorkspace[pane.Workspace]
+		if !ok {
+			byWorkspace[pane.Workspace] = len(targets)
+			targets = append(targets, Target{Multiplexer: WezTerm, Session: pane.Workspace, Window: -1, PaneID: pane.PaneID, Path: cwdPath(pane.CWD)})
+			continue
+		}
+		if pane.IsActive && targets[idx].Path == "" {
+			targets[idx].PaneID = pane.PaneID
+			targets[idx].Path = cwdPath(pane.CWD)
+		}
+	}
+	return targets
+}
+
+// cwdPath strips the file://host prefix wezterm reports a cwd with.
+func cwdPath(cwd string) string {
+	if rest, ok := strings.CutPrefix(cwd, "file://"); ok {
+		if slash := strings.Index(rest, "/"); slash >= 0 {
+			return rest[slash:]
+		}
+		return ""
+	}
+	return cwd
+}
+
+// queryNoise is left out of the words a target is matched on.
+var queryNoise = map[string]bool{
+	"switch": true, "to": true, "go": true, "jump": true, "attach": true, "open": true, "back": true,
+	"my": true, "the": true, "a": true, "me": true, "into": true, "over": true,
+	"project": true, "repo": true, "session": true, "window": true, "workspace": true, "tab": true,
+	"tmux": true, "wezterm": true, "please": true,
+}
+
+// Terms returns the words of query that name a target.
+func Terms(query string) []string {
+	var terms []string
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		word = strings.Trim(word, ".,!?'\"")
+		if word != "" && !queryNoise[word] {
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+// Rank scores targets against the terms of query, best first, and leaves
+// out targets that match none of them. A session or window name counts
+// more than the directory its pane is in, and an exact name more than a
+// prefix or substring. inTmux picks switch-client over attach-session.
+func Rank(query string, targets []Target, inTmux bool) []Match {
+	terms := Terms(query)
+	if len(terms) == 0 {
+		return nil
+	}
+	var matches []Match
+	for _, target := range targets {
+		score := 0.0
+		for _, term := range terms {
+			score += max(
+				termScore(term, target.Session, 3),
+				termScore(term, target.WindowName, 2.5),
+				termScore(term, filepath.Base(target.Path), 2),
+			)
+		}
+		if score == 0 {
+			continue
+		}
+		if target.Window < 0 {
+			// A session beats its own windows on an equal score.
+			score += 0.1
+		}
+		matches = append(matches, Match{Target: target, Score: score, Command: SwitchCommand(target, inTmux)})
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+	return matches
+}
+
+func termScore(term, name string, weight float64) float64 {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch {
+	case name == "" || name == "." || name == "/":
+		return 0
+	case name == term:
+		return weight
+	case strings.HasPrefix(name, term):
+		return weight * 0.75
+	case len(term) >= 3 && strings.Contains(name, term):
+		return weight * 0.5
+	}
+	return 0
+}
+
+// InTmux reports whether ew is running inside a tmux client, where
+// attaching would nest sessions and switch-client is used instead.
+func InTmux() bool {
+	return strings.TrimSpace(os.Getenv("TMUX")) != ""
+}
+
+// SwitchCommand is the shell command that brings target to the front.
+func SwitchCommand(target Target, inTmux bool) string {
+	switch target.Multiplexer {
+	case Tmux:
+		spec := target.Session
+		if target.Window >= 0 {
+			spec = fmt.Sprintf("%s:%d", target.Session, target.Window)
+		}
+		if inTmux {
+			return "tmux switch-client -t " + ewrt.ShellQuote(spec)
+		}
+		return "tmux attach-session -t " + ewrt.ShellQuote(spec)
+	case WezTerm:
+		return fmt.Sprintf("wezterm cli activate-pane --pane-id %d", target.PaneID)
+	}
+	return ""
+}
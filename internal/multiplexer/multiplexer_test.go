@@ -0,0 +1,69 @@
+package multiplexer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestListReadsTmuxWindowsAndWezTermWorkspaces(t *testing.T) {
+	previousLook, previousOutput := lookPath, output
+	t.Cleanup(func() { lookPath, output = previousLook, previousOutput })
+	lookPath = func(name string) (string, error) { return "/usr/bin/" + name, nil }
+	output = func(_ context.Context, name string, _ ...string) ([]byte, error) {
+		switch name {
+		case Tmux:
+			return []byte("api\t1\teditor\t/home/me/src/api\t1\napi\t2\tserver\t/home/me/src/api\t1\nweb\t1\tzsh\t/home/me/src/web-app\t0\n"), nil
+		case WezTerm:
+			return []byte(`[{"workspace":"notes","pane_id":7,"cwd":"file://laptop/home/me/notes","is_active":true},{"workspace":"notes","pane_id":8,"cwd":"file://laptop/tmp","is_active":false}]`), nil
+		}
+		return nil, fmt.Errorf("unexpected %s", name)
+	}
+
+	targets := List(context.Background())
+	if len(targets) != 6 {
+		t.Fatalf("expected 2 tmux sessions, 3 windows, and 1 workspace, got %+v", targets)
+	}
+	if targets[0].Session != "api" || targets[0].Window != -1 || !targets[0].Attached {
+		t.Fatalf("expected the api session ahead of its windows, got %+v", targets[0])
+	}
+	notes := targets[5]
+	if notes.Multiplexer != WezTerm || notes.PaneID != 7 || notes.Path != "/home/me/notes" {
+		t.Fatalf("unexpected wezterm workspace %+v", notes)
+	}
+}
+
+func TestRankPrefersNamesOverDirectoriesAndBuildsSwitchCommands(t *testing.T) {
+	targets := []Target{
+		{Multiplexer: Tmux, Session: "work", Window: -1, Path: "/src/api"},
+		{Multiplexer: Tmux, Session: "work", Window: 3, WindowName: "logs", Path: "/src/api"},
+		{Multiplexer: Tmux, Session: "api", Window: -1, Path: "/src/other"},
+		{Multiplexer: WezTerm, Session: "notes", Window: -1, PaneID: 7},
+	}
+
+	matches := Rank("switch to my api project", targets, false)
+	if len(matches) != 3 {
+		t.Fatalf("expected three api matches, got %+v", matches)
+	}
+	if matches[0].Session != "api" || matches[0].Command != "tmux attach-session -t api" {
+		t.Fatalf("expected the session named api first, got %+v", matches[0])
+	}
+
+	logs := Rank("logs window", targets, true)
+	if len(logs) != 1 || logs[0].Command != "tmux switch-client -t work:3" {
+		t.Fatalf("expected switch-client to the logs window inside tmux, got %+v", logs)
+	}
+	if got := Rank("notes", targets, false); len(got) != 1 || got[0].Command != "wezterm cli activate-pane --pane-id 7" {
+		t.Fatalf("expected the wezterm workspace, got %+v", got)
+	}
+	if got := Rank("switch to my project", targets, false); got != nil {
+		t.Fatalf("expected no matches without a naming term, got %+v", got)
+	}
+}
+
+func TestSwitchCommandQuotesSessionNames(t *testing.T) {
+	got := SwitchCommand(Target{Multiplexer: Tmux, Session: "my app's", Window: -1}, false)
+	if got != `tmux attach-session -t 'my app'\''s'` {
+		t.Fatalf("unexpected command %q", got)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -17,6 +18,8 @@ import (
 
 var placeholderRegex = regexp.MustCompile(`\{([a-z_]+)\}`)
 
+const providerWaitDelay = 2 * time.Second
+
 type CommandAdapter struct {
 	name string
 	cfg  config.ProviderConfig
@@ -48,6 +51,12 @@ func (a *CommandAdapter) Type() string {
 }
 
 func (a *CommandAdapter) Resolve(ctx context.Context, req Request) (Resolution, error) {
+	return a.ResolveStream(ctx, req, nil)
+}
+
+// ResolveStream is Resolve that also passes each new line the CLI prints,
+// on stdout or stderr, to partial. A nil partial streams nothing.
+func (a *CommandAdapter) ResolveStream(ctx context.Context, req Request, partial func(string)) (Resolution, error) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
@@ -62,31 +71,50 @@ func (a *CommandAdapter) Resolve(ctx context.Context, req Request) (Resolution,
 	if err != nil {
 		return Resolution{}, err
 	}
+	tracer := tracerFrom(ctx)
+	tracer.record(TraceEvent{Step: TraceInvocation, Provider: a.name, Args: traceArgs(invocation, workingReq)})
 
 	cmd := exec.CommandContext(ctx, invocation[0], invocation[1:]...)
+	// A provider CLI may leave children holding stdout open after it is
+	// killed; stop waiting for them shortly after cancellation.
+	cmd.WaitDelay = providerWaitDelay
+	// Third-party CLIs only see the variables they need, not every token
+	// in the user's shell.
+	cmd.Env = SandboxEnv(a.cfg, os.Environ())
 	var stdout bytes.Buffer
 	var stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
+	if partial != nil {
+		reporter := newLineReporter(partial)
+		cmd.Stdout = io.MultiWriter(&stdout, reporter)
+		cmd.Stderr = io.MultiWriter(&stderr, reporter)
+	}
 
 	runErr := cmd.Run()
 	raw := strings.TrimSpace(readPreferredOutput(workingReq, stdout.String()))
 	if raw == "" {
 		raw = strings.TrimSpace(stdout.String())
 	}
+	tracer.record(TraceEvent{Step: TraceRawOutput, Provider: a.name, Stdout: raw, Stderr: stderr.String(), Error: errorText(runErr)})
 	if runErr != nil {
 		return Resolution{}, fmt.Errorf("provider command failed (%s): %w; stderr=%s", a.cfg.Command, runErr, truncate(stderr.String(), 800))
 	}
 
-	resolution, parseErr := parseResolution(raw)
+	resolution, path, parseErr := parseResolutionPath(raw)
+	tracer.record(TraceEvent{Step: TraceParse, Provider: a.name, Attempt: "output", Error: errorText(parseErr)})
 	if parseErr == nil {
+		resolution.Raw, resolution.ParsePath = raw, path
 		return normalizeResolution(resolution), nil
 	}
 
 	combined := strings.TrimSpace(strings.TrimSpace(stdout.String()) + "\n" + strings.TrimSpace(stderr.String()))
 	if combined != "" {
 		if extracted, ok := extractJSONObject(combined); ok {
-			if parsed, err := parseResolution(extracted); err == nil {
+			parsed, path, err := parseResolutionPath(extracted)
+			tracer.record(TraceEvent{Step: TraceParse, Provider: a.name, Attempt: "json_in_stdout_stderr", Error: errorText(err)})
+			if err == nil {
+				parsed.Raw, parsed.ParsePath = combined, "stdout_stderr"+parseStageSeparator+ParseExtractedObject+parseStageSeparator+path
 				return normalizeResolution(parsed), nil
 			}
 		}
@@ -158,20 +186,21 @@ func (a *CommandAdapter) prepareRequest(req Request) (Request, func(), error) {
 		working.Context = map[string]any{}
 	}
 
-	tmpDir, err := os.MkdirTemp("", "ew-provider-")
+	tmpDir, err := requestTempDir()
 	if err != nil {
 		return Request{}, nil, fmt.Errorf("could not create provider temp dir: %w", err)
 	}
 
+	schema := responseSchema(req.Intent)
 	schemaFile := filepath.Join(tmpDir, "resolution.schema.json")
-	if err := os.WriteFile(schemaFile, []byte(resolutionJSONSchema), 0o644); err != nil {
+	if err := os.WriteFile(schemaFile, []byte(schema), 0o644); err != nil {
 		_ = os.RemoveAll(tmpDir)
 		return Request{}, nil, fmt.Errorf("could not write schema file: %w", err)
 	}
 
 	working.Context["schema_file"] = schemaFile
 	working.Context["output_file"] = filepath.Join(tmpDir, "resolution.output.json")
-	working.Context["schema_json"] = compactSchema(resolutionJSONSchema)
+	working.Context["schema_json"] = compactSchema(schema)
 
 	cleanup := func() {
 		_ = os.RemoveAll(tmpDir)
@@ -216,13 +245,25 @@ func renderTemplateArg(template string, values map[string]string) (string, bool)
 }
 
 func parseResolution(raw string) (Resolution, error) {
+	resolution, _, err := parseResolutionPath(raw)
+	return resolution, err
+}
+
+// parseResolutionPath is parseResolution that also returns the parse path
+// it took, such as "wrapper.result > direct_json" for a CLI that wraps the
+// answer in {"result": "..."}.
+func parseResolutionPath(raw string) (Resolution, string, error) {
 	trimmed := preprocessStructuredText(raw)
 	if trimmed == "" {
-		return Resolution{}, fmt.Errorf("empty response")
+		return Resolution{}, "", fmt.Errorf("empty response")
 	}
 
 	if parsed, err := decodeResolutionJSON(trimmed); err == nil {
-		return parsed, nil
+		// decodeResolutionJSON digs an object out of surrounding prose too.
+		if !json.Valid([]byte(trimmed)) {
+			return parsed, ParseExtractedObject, nil
+		}
+		return parsed, ParseDirectJSON, nil
 	}
 
 	var wrapper map[string]any
@@ -230,13 +271,13 @@ func parseResolution(raw string) (Resolution, error) {
 		if value, ok := wrapper["result"]; ok {
 			switch result := value.(type) {
 			case string:
-				if parsed, err := parseResolution(result); err == nil {
-					return parsed, nil
+				if parsed, path, err := parseResolutionPath(result); err == nil {
+					return parsed, ParseWrapperResult + parseStageSeparator + path, nil
 				}
 			case map[string]any:
 				if bytes, err := json.Marshal(result); err == nil {
-					if parsed, err := parseResolution(string(bytes)); err == nil {
-						return parsed, nil
+					if parsed, path, err := parseResolutionPath(string(bytes)); err == nil {
+						return parsed, ParseWrapperResult + parseStageSeparator + path, nil
 					}
 				}
 			}
@@ -244,8 +285,8 @@ func parseResolution(raw string) (Resolution, error) {
 		if value, ok := wrapper["content"]; ok {
 			switch content := value.(type) {
 			case string:
-				if parsed, err := parseResolution(content); err == nil {
-					return parsed, nil
+				if parsed, path, err := parseResolutionPath(content); err == nil {
+					return parsed, ParseWrapperContent + parseStageSeparator + path, nil
 				}
 			case []any:
 				for _, item := range content {
@@ -254,8 +295,8 @@ func parseResolution(raw string) (Resolution, error) {
 						continue
 					}
 					if text, ok := obj["text"].(string); ok {
-						if parsed, err := parseResolution(text); err == nil {
-							return parsed, nil
+						if parsed, path, err := parseResolutionPath(text); err == nil {
+							return parsed, ParseWrapperContent + parseStageSeparator + path, nil
 						}
 					}
 				}
@@ -265,11 +306,11 @@ func parseResolution(raw string) (Resolution, error) {
 
 	if extracted, ok := extractJSONObject(trimmed); ok {
 		if parsed, err := decodeResolutionJSON(extracted); err == nil {
-			return parsed, nil
+			return parsed, ParseExtractedObject, nil
 		}
 	}
 
-	return Resolution{}, fmt.Errorf("could not parse structured resolution")
+	return Resolution{}, "", fmt.Errorf("could not parse structured resolution")
 }
 
 func decodeResolutionJSON(raw string) (Resolution, error) {
@@ -313,6 +354,27 @@ func normalizeResolution(in Resolution) Resolution {
 	if out.Action == "run" && out.NeedsConfirmation {
 		out.Action = "suggest"
 	}
+	out.Steps = normalizeSteps(out.Steps)
+	if len(out.Steps) > 0 {
+		out.Command = out.Steps[0].Command
+	}
+	return out
+}
+
+// normalizeSteps drops empty steps and a plan of one step, which is just
+// Command.
+func normalizeSteps(steps []PlanStep) []PlanStep {
+	out := make([]PlanStep, 0, len(steps))
+	for _, step := range steps {
+		step.Command = strings.TrimSpace(step.Command)
+		step.Reason = strings.TrimSpace(step.Reason)
+		if step.Command != "" {
+			out = append(out, step)
+		}
+	}
+	if len(out) < 2 {
+		return nil
+	}
 	return out
 }
 
@@ -488,9 +550,29 @@ func adaptLooseResolution(payload map[string]any) (Resolution, bool) {
 		Risk:              risk,
 		Confidence:        confidence,
 		NeedsConfirmation: needsConfirmation,
+		Steps:             looseSteps(payload["steps"]),
 	}, true
 }
 
+// looseSteps reads a steps array given as objects or as plain command
+// strings.
+func looseSteps(value any) []PlanStep {
+	items, ok := value.([]any)
+	if !ok {
+		return nil
+	}
+	steps := make([]PlanStep, 0, len(items))
+	for _, item := range items {
+		switch v := item.(type) {
+		case string:
+			steps = append(steps, PlanStep{Command: strings.TrimSpace(v)})
+		case map[string]any:
+			steps = append(steps, PlanStep{Command: stringValue(v["command"]), Reason: stringValue(v["reason"])})
+		}
+	}
+	return steps
+}
+
 func stringValue(value any) string {
 	switch v := value.(type) {
 	case string:
@@ -566,9 +648,75 @@ const resolutionJSONSchema = `
 }
 `
 
-func timeoutContext(parent context.Context, d time.Duration) (context.Context, context.CancelFunc) {
-	if d <= 0 {
-		d = 120 * time.Second
+// responseSchema is the JSON schema a provider answers intent in.
+func responseSchema(intent Intent) string {
+	switch intent {
+	case IntentExplain:
+		return explainJSONSchema
+	case IntentFix:
+		return fixJSONSchema
 	}
-	return context.WithTimeout(parent, d)
+	return resolutionJSONSchema
 }
+
+// fixJSONSchema is resolutionJSONSchema plus the ordered steps of a fix
+// that takes more than one command.
+const fixJSONSchema = `
+{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "required": ["action", "command", "reason", "risk", "confidence", "needs_confirmation", "steps"],
+  "properties": {
+    "action": { "type": "string", "enum": ["ask", "suggest", "run"] },
+    "command": { "type": "string" },
+    "reason": { "type": "string" },
+    "risk": { "type": "string", "enum": ["low", "medium", "high"] },
+    "confidence": { "type": "number", "minimum": 0, "maximum": 1 },
+    "needs_confirmation": { "type": "boolean" },
+    "steps": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "required": ["command", "reason"],
+        "properties": {
+          "command": { "type": "string" },
+          "reason": { "type": "string" }
+        },
+        "additionalProperties": false
+      }
+    }
+  },
+  "additionalProperties": false
+}
+`
+
+// explainJSONSchema is resolutionJSONSchema plus the part-by-part
+// breakdown of the command.
+const explainJSONSchema = `
+{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "required": ["action", "command", "reason", "risk", "confidence", "needs_confirmation", "explanation"],
+  "properties": {
+    "action": { "type": "string", "enum": ["ask", "suggest", "run"] },
+    "command": { "type": "string" },
+    "reason": { "type": "string" },
+    "risk": { "type": "string", "enum": ["low", "medium", "high"] },
+    "confidence": { "type": "number", "minimum": 0, "maximum": 1 },
+    "needs_confirmation": { "type": "boolean" },
+    "explanation": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "required": ["part", "meaning"],
+        "properties": {
+          "part": { "type": "string" },
+          "meaning": { "type": "string" }
+        },
+        "additionalProperties": false
+      }
+    }
+  },
+  "additionalProperties": false
+}
+`
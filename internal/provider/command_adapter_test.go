@@ -1,6 +1,7 @@
 package provider
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"os"
@@ -38,6 +39,22 @@ func TestParseResolutionHandlesWrapperResultWithFencedJSON(t *testing.T) {
 	}
 }
 
+func TestParseResolutionPathNamesEachStage(t *testing.T) {
+	answer := `{"action":"run","command":"ls","reason":"list"}`
+	quoted, _ := json.Marshal(answer)
+	cases := map[string]string{
+		answer:                              ParseDirectJSON,
+		`{"result":` + string(quoted) + `}`: "wrapper.result > direct_json",
+		`{"content":[{"text":"Sure: ` + strings.ReplaceAll(answer, `"`, `\"`) + `"}]}`: "wrapper.content > extracted_object",
+		"Here it is: " + answer + " done":                                              ParseExtractedObject,
+	}
+	for raw, want := range cases {
+		if _, path, err := parseResolutionPath(raw); err != nil || path != want {
+			t.Fatalf("parseResolutionPath(%s) = %q, %v; want %q", raw, path, err, want)
+		}
+	}
+}
+
 func TestPreprocessStructuredTextStripsCodeFence(t *testing.T) {
 	raw := "```json\n{\"action\":\"run\"}\n```"
 	got := preprocessStructuredText(raw)
@@ -71,6 +88,30 @@ func TestAdaptLooseResolutionDefaultsConfidenceByActionWhenMissing(t *testing.T)
 	}
 }
 
+func TestNormalizeResolutionKeepsOnlyRealPlans(t *testing.T) {
+	resolution, err := decodeResolutionJSON(`{"action":"run","command":"git rebase origin/main","reason":"rebase","risk":"low","confidence":0.9,"needs_confirmation":true,"steps":[{"command":" git fetch origin ","reason":"update"},{"command":"","reason":"empty"},{"command":"git rebase origin/main","reason":"rebase"}]}`)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	resolution = normalizeResolution(resolution)
+	if len(resolution.Steps) != 2 || resolution.Steps[0].Command != "git fetch origin" || resolution.Command != "git fetch origin" {
+		t.Fatalf("expected two steps with command set to the first, got %+v", resolution)
+	}
+
+	single := normalizeResolution(Resolution{Action: "run", Command: "make", Steps: []PlanStep{{Command: "make"}}})
+	if single.Steps != nil || single.Command != "make" {
+		t.Fatalf("expected a one-step plan to be just the command, got %+v", single)
+	}
+
+	loose, ok := adaptLooseResolution(map[string]any{"command": "git fetch", "reason": "r", "steps": []any{"git fetch", "git rebase"}})
+	if !ok || len(normalizeResolution(loose).Steps) != 2 {
+		t.Fatalf("expected plain command strings to be read as steps, got %+v", loose)
+	}
+	if responseSchema(IntentFix) != fixJSONSchema || responseSchema(IntentFind) != resolutionJSONSchema {
+		t.Fatalf("expected only fix requests to ask for steps")
+	}
+}
+
 func TestResolveFailsWhenProviderExitsNonZeroEvenWithParseableJSON(t *testing.T) {
 	if runtime.GOOS == "windows" {
 		t.Skip("shell script test is not portable on windows")
@@ -109,3 +150,91 @@ exit 1
 		t.Fatalf("expected provider command failure error, got: %v", resolveErr)
 	}
 }
+
+func TestResolveTracesInvocationOutputAndParseAttempts(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script test is not portable on windows")
+	}
+
+	tempDir := t.TempDir()
+	scriptPath := filepath.Join(tempDir, "provider.sh")
+	script := `#!/bin/sh
+echo 'thinking... token=supersecret'
+echo '{"action":"suggest","command":"ls -la","reason":"ok","risk":"low","confidence":0.9,"needs_confirmation":true}'
+`
+	if err := os.WriteFile(scriptPath, []byte(script), 0o755); err != nil {
+		t.Fatalf("write script failed: %v", err)
+	}
+	adapter, err := NewCommandAdapter("test", config.ProviderConfig{
+		Type:    "command",
+		Command: scriptPath,
+		Model:   "test-model",
+		Args:    []string{"--model", "{model}", "{prompt}"},
+	})
+	if err != nil {
+		t.Fatalf("NewCommandAdapter failed: %v", err)
+	}
+
+	var trace bytes.Buffer
+	ctx := WithTracer(context.Background(), NewTracer(&trace))
+	if _, err := adapter.Resolve(ctx, Request{Intent: IntentFind, Prompt: "list files", Model: "test-model"}); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	if strings.Contains(trace.String(), "supersecret") {
+		t.Fatalf("expected the trace to be redacted, got %s", trace.String())
+	}
+	var steps []TraceEvent
+	for _, line := range strings.Split(strings.TrimSpace(trace.String()), "\n") {
+		var event TraceEvent
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("trace line is not JSON: %q", line)
+		}
+		steps = append(steps, event)
+	}
+	if len(steps) != 3 || steps[0].Step != TraceInvocation || steps[1].Step != TraceRawOutput || steps[2].Step != TraceParse {
+		t.Fatalf("unexpected trace steps %+v", steps)
+	}
+	if got := strings.Join(steps[0].Args, " "); got != scriptPath+" --model test-model {prompt}" {
+		t.Fatalf("expected the prompt argument shown as its placeholder, got %q", got)
+	}
+	if steps[2].Attempt != "output" || steps[2].Error != "" {
+		t.Fatalf("expected the first parse attempt to succeed, got %+v", steps[2])
+	}
+}
+
+func TestResolveExplainUsesExplainSchemaAndKeepsBreakdown(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script test is not portable on windows")
+	}
+
+	tempDir := t.TempDir()
+	scriptPath := filepath.Join(tempDir, "provider.sh")
+	script := `#!/bin/sh
+case "$1" in
+  *explanation*) ;;
+  *) echo "explain schema missing" >&2; exit 3 ;;
+esac
+echo '{"action":"suggest","command":"tar -xzf a.tgz","reason":"extracts","risk":"low","confidence":0.9,"needs_confirmation":true,"explanation":[{"part":"tar","meaning":"archive tool"},{"part":"-xzf","meaning":"extract a gzip archive from a file"}]}'
+`
+	if err := os.WriteFile(scriptPath, []byte(script), 0o755); err != nil {
+		t.Fatalf("write script failed: %v", err)
+	}
+	adapter, err := NewCommandAdapter("test", config.ProviderConfig{
+		Type:    "command",
+		Command: scriptPath,
+		Model:   "test-model",
+		Args:    []string{"{schema_json}", "{prompt}"},
+	})
+	if err != nil {
+		t.Fatalf("NewCommandAdapter failed: %v", err)
+	}
+
+	resolution, err := adapter.Resolve(context.Background(), Request{Intent: IntentExplain, Prompt: "explain tar -xzf a.tgz", Model: "test-model"})
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if len(resolution.Explanation) != 2 || resolution.Explanation[1].Part != "-xzf" || resolution.Explanation[1].Meaning == "" {
+		t.Fatalf("expected the breakdown to survive parsing, got %+v", resolution.Explanation)
+	}
+}
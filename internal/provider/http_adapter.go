@@ -0,0 +1,377 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ashwch/ew/internal/config"
+)
+
+const (
+	defaultHTTPTimeout     = 60 * time.Second
+	defaultHTTPMaxAttempts = 3
+	maxHTTPRetryDelay      = 10 * time.Second
+	maxHTTPResponseBytes   = 1 << 20
+
+	anthropicVersion   = "2023-06-01"
+	anthropicMaxTokens = 2048
+	resolutionToolName = "resolution"
+)
+
+// httpRetryDelay is the wait before the first retry; it doubles after
+// each one. Swapped out in tests.
+var httpRetryDelay = 500 * time.Millisecond
+
+// httpAPIDefaults are the endpoint and key variable each API uses when the
+// provider does not set base_url or api_key_env.
+var httpAPIDefaults = map[string]struct{ baseURL, keyEnv string }{
+	"openai":    {baseURL: "https://api.openai.com/v1", keyEnv: "OPENAI_API_KEY"},
+	"anthropic": {baseURL: "https://api.anthropic.com/v1", keyEnv: "ANTHROPIC_API_KEY"},
+}
+
+// HTTPAdapter calls a chat completion API directly: OpenAI's chat
+// completions (which OpenRouter and most local servers also speak) or
+// Anthropic's messages. The answer is constrained to the resolution schema
+// and goes through the same parsing and normalization as a CLI provider's.
+type HTTPAdapter struct {
+	name     string
+	cfg      config.ProviderConfig
+	api      string
+	endpoint string
+	keyEnv   string
+	timeout  time.Duration
+	attempts int
+	client   *http.Client
+}
+
+func NewHTTPAdapter(name string, cfg config.ProviderConfig) (Adapter, error) {
+	api := strings.ToLower(strings.TrimSpace(cfg.API))
+	if api == "" {
+		api = "openai"
+	}
+	defaults, ok := httpAPIDefaults[api]
+	if !ok {
+		return nil, fmt.Errorf("unsupported api %q (want openai or anthropic)", cfg.API)
+	}
+
+	baseURL := strings.TrimSpace(cfg.BaseURL)
+	if baseURL == "" {
+		baseURL = defaults.baseURL
+	}
+	parsed, err := url.Parse(baseURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid base_url %q: want an http(s) URL such as %s", baseURL, defaults.baseURL)
+	}
+	path := "/chat/completions"
+	if api == "anthropic" {
+		path = "/messages"
+	}
+
+	keyEnv := strings.TrimSpace(cfg.APIKeyEnv)
+	if keyEnv == "" {
+		keyEnv = defaults.keyEnv
+	}
+	timeout := time.Duration(cfg.TimeoutMs) * time.Millisecond
+	if timeout <= 0 {
+		timeout = defaultHTTPTimeout
+	}
+	attempts := cfg.MaxAttempts
+	if attempts <= 0 {
+		attempts = defaultHTTPMaxAttempts
+	}
+
+	return &HTTPAdapter{
+		name:     name,
+		cfg:      cfg,
+		api:      api,
+		endpoint: strings.TrimRight(baseURL, "/") + path,
+		keyEnv:   keyEnv,
+		timeout:  timeout,
+		attempts: attempts,
+		client:   &http.Client{},
+	}, nil
+}
+
+func (a *HTTPAdapter) Name() string {
+	return a.name
+}
+
+func (a *HTTPAdapter) Type() string {
+	return "http"
+}
+
+// BuildInvocation describes the request for traces: the method and URL.
+func (a *HTTPAdapter) BuildInvocation(req Request) ([]string, error) {
+	if strings.TrimSpace(req.Prompt) == "" {
+		return nil, fmt.Errorf("prompt cannot be empty")
+	}
+	return []string{http.MethodPost, a.endpoint}, nil
+}
+
+// HealthCheck only looks for the API key; it never calls the API.
+func (a *HTTPAdapter) HealthCheck() error {
+	if strings.TrimSpace(os.Getenv(a.keyEnv)) == "" {
+		return fmt.Errorf("api key not set: export %s", a.keyEnv)
+	}
+	return nil
+}
+
+func (a *HTTPAdapter) Resolve(ctx context.Context, req Request) (Resolution, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	invocation, err := a.BuildInvocation(req)
+	if err != nil {
+		return Resolution{}, err
+	}
+	model := strings.TrimSpace(req.Model)
+	if model == "" {
+		model = strings.TrimSpace(a.cfg.Model)
+	}
+	if model == "" {
+		return Resolution{}, fmt.Errorf("model cannot be empty")
+	}
+	key := strings.TrimSpace(os.Getenv(a.keyEnv))
+	if key == "" {
+		return Resolution{}, fmt.Errorf("api key not set: export %s", a.keyEnv)
+	}
+	body, err := a.requestBody(req, model)
+	if err != nil {
+		return Resolution{}, err
+	}
+
+	tracer := tracerFrom(ctx)
+	tracer.record(TraceEvent{Step: TraceInvocation, Provider: a.name, Args: invocation})
+	raw, postErr := a.post(ctx, key, body)
+	tracer.record(TraceEvent{Step: TraceRawOutput, Provider: a.name, Stdout: string(raw), Error: errorText(postErr)})
+	if postErr != nil {
+		return Resolution{}, fmt.Errorf("provider request failed (%s): %w", a.endpoint, postErr)
+	}
+
+	output, field, err := a.responseOutput(raw)
+	if err != nil {
+		return Resolution{}, err
+	}
+	resolution, path, parseErr := parseResolutionPath(output)
+	tracer.record(TraceEvent{Step: TraceParse, Provider: a.name, Attempt: "output", Error: errorText(parseErr)})
+	if parseErr != nil {
+		return Resolution{}, fmt.Errorf("provider returned unparseable output: %s", truncate(output, 800))
+	}
+	resolution.Raw, resolution.ParsePath = string(raw), field+parseStageSeparator+path
+	return normalizeResolution(resolution), nil
+}
+
+// requestBody asks for the answer in the request's schema: a strict
+// json_schema response format for OpenAI, a forced tool call for
+// Anthropic.
+func (a *HTTPAdapter) requestBody(req Request, model string) ([]byte, error) {
+	schemaText := responseSchema(req.Intent)
+	var schema map[string]any
+	if err := json.Unmarshal([]byte(schemaText), &schema); err != nil {
+		return nil, fmt.Errorf("could not parse resolution schema: %w", err)
+	}
+	delete(schema, "$schema")
+
+	messages := []map[string]string{{"role": "user", "content": req.Prompt}}
+	var payload map[string]any
+	switch a.api {
+	case "anthropic":
+		payload = map[string]any{
+			"model":      model,
+			"max_tokens": anthropicMaxTokens,
+			"messages":   messages,
+			"tools": []map[string]any{{
+				"name":         resolutionToolName,
+				"description":  "Report the answer to the request.",
+				"input_schema": schema,
+			}},
+			"tool_choice": map[string]string{"type": "tool", "name": resolutionToolName},
+		}
+	default:
+		payload = map[string]any{
+			"model":    model,
+			"messages": messages,
+			"response_format": map[string]any{
+				"type": "json_schema",
+				"json_schema": map[string]any{
+					"name":   "ew_" + resolutionToolName,
+					"strict": true,
+					"schema": schema,
+				},
+			},
+		}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode provider request: %w", err)
+	}
+	return body, nil
+}
+
+// responseOutput pulls the structured answer out of an API response and
+// names the field it came from.
+func (a *HTTPAdapter) responseOutput(raw []byte) (string, string, error) {
+	if a.api == "anthropic" {
+		var response struct {
+			Content []struct {
+				Type  string          `json:"type"`
+				Text  string          `json:"text"`
+				Input json.RawMessage `json:"input"`
+			} `json:"content"`
+		}
+		if err := json.Unmarshal(raw, &response); err != nil {
+			return "", "", fmt.Errorf("could not parse provider response: %w", err)
+		}
+		var text []string
+		for _, block := range response.Content {
+			if block.Type == "tool_use" && len(block.Input) > 0 {
+				return string(block.Input), "content.tool_use.input", nil
+			}
+			if strings.TrimSpace(block.Text) != "" {
+				text = append(text, block.Text)
+			}
+		}
+		if len(text) == 0 {
+			return "", "", fmt.Errorf("provider returned no content")
+		}
+		return strings.Join(text, "\n"), "content.text", nil
+	}
+
+	var response struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+				Refusal string `json:"refusal"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(raw, &response); err != nil {
+		return "", "", fmt.Errorf("could not parse provider response: %w", err)
+	}
+	if len(response.Choices) == 0 {
+		return "", "", fmt.Errorf("provider returned no choices")
+	}
+	message := response.Choices[0].Message
+	if refusal := strings.TrimSpace(message.Refusal); refusal != "" {
+		return "", "", fmt.Errorf("provider refused: %s", truncate(refusal, 300))
+	}
+	if strings.TrimSpace(message.Content) == "" {
+		return "", "", fmt.Errorf("provider returned no content")
+	}
+	return message.Content, "choices.message.content", nil
+}
+
+// post sends body, retrying rate limits, server errors, and dropped
+// connections with a doubling delay or the server's Retry-After.
+func (a *HTTPAdapter) post(ctx context.Context, key string, body []byte) ([]byte, error) {
+	for attempt := 1; ; attempt++ {
+		raw, err := a.send(ctx, key, body)
+		if err == nil {
+			return raw, nil
+		}
+		// A connection error is retried unless the request itself was
+		// cancelled; a response only when the status says so.
+		var statusErr *httpStatusError
+		isStatus := errors.As(err, &statusErr)
+		retryable := (isStatus && statusErr.retryable()) || (!isStatus && ctx.Err() == nil)
+		if !retryable || attempt >= a.attempts {
+			if attempt > 1 {
+				return nil, fmt.Errorf("%w (after %d attempts)", err, attempt)
+			}
+			return nil, err
+		}
+
+		delay := httpRetryDelay << (attempt - 1)
+		if isStatus && statusErr.retryAfter > 0 {
+			delay = statusErr.retryAfter
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(min(delay, maxHTTPRetryDelay)):
+		}
+	}
+}
+
+func (a *HTTPAdapter) send(ctx context.Context, key string, body []byte) ([]byte, error) {
+	attemptCtx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+	httpReq, err := http.NewRequestWithContext(attemptCtx, http.MethodPost, a.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("could not build provider request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if a.api == "anthropic" {
+		httpReq.Header.Set("x-api-key", key)
+		httpReq.Header.Set("anthropic-version", anthropicVersion)
+	} else {
+		httpReq.Header.Set("Authorization", "Bearer "+key)
+	}
+
+	resp, err := a.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPResponseBytes))
+	if err != nil {
+		return nil, fmt.Errorf("could not read provider response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &httpStatusError{
+			status:     resp.StatusCode,
+			message:    apiErrorMessage(raw),
+			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+	return raw, nil
+}
+
+type httpStatusError struct {
+	status     int
+	message    string
+	retryAfter time.Duration
+}
+
+func (e *httpStatusError) Error() string {
+	if e.message == "" {
+		return fmt.Sprintf("status %d", e.status)
+	}
+	return fmt.Sprintf("status %d: %s", e.status, e.message)
+}
+
+func (e *httpStatusError) retryable() bool {
+	return e.status == http.StatusRequestTimeout || e.status == http.StatusTooManyRequests || e.status >= 500
+}
+
+// apiErrorMessage is the message from an {"error": {"message": ...}} body,
+// which both APIs use, or the start of the body otherwise.
+func apiErrorMessage(raw []byte) string {
+	var payload struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &payload); err == nil && strings.TrimSpace(payload.Error.Message) != "" {
+		return truncate(payload.Error.Message, 300)
+	}
+	return truncate(string(raw), 300)
+}
+
+func parseRetryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
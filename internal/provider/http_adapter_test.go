@@ -0,0 +1,155 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ashwch/ew/internal/config"
+)
+
+const httpTestResolution = `{"action":"run","command":"git status","reason":"show the working tree","risk":"low","confidence":0.9,"needs_confirmation":false}`
+
+func newTestHTTPAdapter(t *testing.T, api, baseURL string) Adapter {
+	t.Helper()
+	t.Setenv("EW_TEST_API_KEY", "sk-test")
+	adapter, err := NewHTTPAdapter("remote", config.ProviderConfig{
+		Type:      "http",
+		API:       api,
+		BaseURL:   baseURL,
+		APIKeyEnv: "EW_TEST_API_KEY",
+		Model:     "test-model",
+	})
+	if err != nil {
+		t.Fatalf("NewHTTPAdapter failed: %v", err)
+	}
+	return adapter
+}
+
+func TestHTTPAdapterOpenAIUsesStrictSchema(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" || r.Header.Get("Authorization") != "Bearer sk-test" {
+			t.Errorf("unexpected request %s auth=%q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		var body struct {
+			Model          string `json:"model"`
+			ResponseFormat struct {
+				Type       string `json:"type"`
+				JSONSchema struct {
+					Strict bool           `json:"strict"`
+					Schema map[string]any `json:"schema"`
+				} `json:"json_schema"`
+			} `json:"response_format"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode request failed: %v", err)
+		}
+		schema := body.ResponseFormat.JSONSchema
+		if body.Model != "test-model" || body.ResponseFormat.Type != "json_schema" || !schema.Strict || schema.Schema["$schema"] != nil || schema.Schema["properties"] == nil {
+			t.Errorf("unexpected request body %+v", body)
+		}
+		reply, _ := json.Marshal(map[string]any{"choices": []any{map[string]any{"message": map[string]any{"content": httpTestResolution}}}})
+		_, _ = w.Write(reply)
+	}))
+	defer server.Close()
+
+	resolution, err := newTestHTTPAdapter(t, "openai", server.URL+"/v1/").Resolve(context.Background(), Request{Intent: IntentFind, Prompt: "show status"})
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if resolution.Command != "git status" || resolution.Action != "run" {
+		t.Fatalf("unexpected resolution %+v", resolution)
+	}
+}
+
+func TestHTTPAdapterAnthropicForcesToolCall(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/messages" || r.Header.Get("x-api-key") != "sk-test" || r.Header.Get("anthropic-version") == "" {
+			t.Errorf("unexpected request %s headers=%v", r.URL.Path, r.Header)
+		}
+		var body struct {
+			ToolChoice map[string]string `json:"tool_choice"`
+			Tools      []struct {
+				Name        string         `json:"name"`
+				InputSchema map[string]any `json:"input_schema"`
+			} `json:"tools"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode request failed: %v", err)
+		}
+		if len(body.Tools) != 1 || body.ToolChoice["name"] != body.Tools[0].Name {
+			t.Errorf("expected a forced tool call, got %+v", body)
+		}
+		reply := `{"content":[{"type":"text","text":"Here you go."},{"type":"tool_use","name":"resolution","input":` + httpTestResolution + `}]}`
+		_, _ = w.Write([]byte(reply))
+	}))
+	defer server.Close()
+
+	resolution, err := newTestHTTPAdapter(t, "anthropic", server.URL).Resolve(context.Background(), Request{Intent: IntentFind, Prompt: "show status"})
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if resolution.Command != "git status" {
+		t.Fatalf("unexpected resolution %+v", resolution)
+	}
+	if resolution.ParsePath != "content.tool_use.input > direct_json" || !strings.Contains(resolution.Raw, `"type":"tool_use"`) {
+		t.Fatalf("expected the raw body and parse path, got %q %q", resolution.ParsePath, resolution.Raw)
+	}
+}
+
+func TestHTTPAdapterRetriesRateLimitsButNotBadRequests(t *testing.T) {
+	original := httpRetryDelay
+	t.Cleanup(func() { httpRetryDelay = original })
+	httpRetryDelay = 0
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch calls.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"error":{"message":"slow down"}}`))
+		case 2:
+			reply, _ := json.Marshal(map[string]any{"choices": []any{map[string]any{"message": map[string]any{"content": httpTestResolution}}}})
+			_, _ = w.Write(reply)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":{"message":"unknown model"}}`))
+		}
+	}))
+	defer server.Close()
+
+	adapter := newTestHTTPAdapter(t, "openai", server.URL)
+	if _, err := adapter.Resolve(context.Background(), Request{Intent: IntentFind, Prompt: "show status"}); err != nil {
+		t.Fatalf("expected the rate limit to be retried, got %v", err)
+	}
+	_, err := adapter.Resolve(context.Background(), Request{Intent: IntentFind, Prompt: "show status"})
+	if err == nil || !strings.Contains(err.Error(), "status 400: unknown model") {
+		t.Fatalf("expected the API's error message, got %v", err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Fatalf("expected a bad request not to be retried, got %d calls", got)
+	}
+}
+
+func TestHTTPAdapterConfigChecks(t *testing.T) {
+	if _, err := NewHTTPAdapter("remote", config.ProviderConfig{API: "gemini"}); err == nil {
+		t.Fatalf("expected an unknown api to fail")
+	}
+	if _, err := NewHTTPAdapter("remote", config.ProviderConfig{BaseURL: "api.openai.com"}); err == nil {
+		t.Fatalf("expected a base_url without a scheme to fail")
+	}
+
+	t.Setenv("EW_TEST_API_KEY", "")
+	adapter, err := NewHTTPAdapter("remote", config.ProviderConfig{APIKeyEnv: "EW_TEST_API_KEY"})
+	if err != nil {
+		t.Fatalf("NewHTTPAdapter failed: %v", err)
+	}
+	err = adapter.(HealthChecker).HealthCheck()
+	if err == nil || !strings.Contains(err.Error(), "EW_TEST_API_KEY") {
+		t.Fatalf("expected the health check to name the key variable, got %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package provider
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ashwch/ew/internal/config"
+)
+
+// slots bounds how many providers are asked at once across every Service
+// in the process, so overlapping requests (a background rerank and a
+// picker comparison, say) cannot start a pile of heavy provider CLIs
+// together. The built-in rules run in-process and never take a slot.
+var slots = &limiter{}
+
+// limiter is a semaphore whose waiters are served in the order they
+// arrived. Its size comes with each acquire, so a changed ai.max_concurrent
+// takes effect on the next request.
+type limiter struct {
+	mu      sync.Mutex
+	size    int
+	used    int
+	waiting []chan struct{}
+}
+
+// acquire takes a slot, queueing behind earlier requests while all size of
+// them are taken; queued reports whether it had to. It gives up when ctx is
+// done; otherwise the caller must call release once the provider has
+// answered.
+func (l *limiter) acquire(ctx context.Context, size int) (queued bool, err error) {
+	l.mu.Lock()
+	l.size = max(size, 1)
+	if l.used < l.size && len(l.waiting) == 0 {
+		l.used++
+		l.mu.Unlock()
+		return false, nil
+	}
+	ready := make(chan struct{})
+	l.waiting = append(l.waiting, ready)
+	l.mu.Unlock()
+
+	select {
+	case <-ready:
+		return true, nil
+	case <-ctx.Done():
+	}
+	l.mu.Lock()
+	if idx := slices.Index(l.waiting, ready); idx >= 0 {
+		l.waiting = slices.Delete(l.waiting, idx, idx+1)
+		l.mu.Unlock()
+		return true, ctx.Err()
+	}
+	l.mu.Unlock()
+	// The slot was handed over as ctx ended; pass it on.
+	l.release()
+	return true, ctx.Err()
+}
+
+// release frees a slot and hands any free slots to the longest waiters.
+func (l *limiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.used--
+	for l.used < l.size && len(l.waiting) > 0 {
+		l.used++
+		close(l.waiting[0])
+		l.waiting = l.waiting[1:]
+	}
+}
+
+// takeSlot waits for a slot for adapter and returns the func that frees
+// it, and how long it waited. The wait is spent out of ctx, so a request
+// queued behind others still ends within its provider's timeout.
+func takeSlot(ctx context.Context, cfg config.Config, adapter Adapter) (func(), time.Duration, error) {
+	if adapter.Type() == "builtin" {
+		return func() {}, 0, nil
+	}
+	started := time.Now()
+	queued, err := slots.acquire(ctx, maxConcurrent(cfg))
+	waited := time.Duration(0)
+	if queued {
+		waited = time.Since(started)
+	}
+	if err != nil {
+		return nil, waited, err
+	}
+	return slots.release, waited, nil
+}
+
+// maxConcurrent is ai.max_concurrent, or its default when unset.
+func maxConcurrent(cfg config.Config) int {
+	if cfg.AI.MaxConcurrent > 0 {
+		return cfg.AI.MaxConcurrent
+	}
+	return config.Default().AI.MaxConcurrent
+}
@@ -0,0 +1,88 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ashwch/ew/internal/config"
+)
+
+func TestLimiterServesWaitersInOrder(t *testing.T) {
+	l := &limiter{}
+	if queued, err := l.acquire(context.Background(), 1); queued || err != nil {
+		t.Fatalf("expected a free slot, got queued=%v err=%v", queued, err)
+	}
+
+	order := make(chan int, 2)
+	for i := 1; i <= 2; i++ {
+		go func() {
+			if _, err := l.acquire(context.Background(), 1); err == nil {
+				order <- i
+				l.release()
+			}
+		}()
+		// Let each waiter join the queue before the next.
+		for deadline := time.Now().Add(time.Second); ; {
+			l.mu.Lock()
+			joined := len(l.waiting) == i
+			l.mu.Unlock()
+			if joined || time.Now().After(deadline) {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if queued, err := l.acquire(ctx, 1); !queued || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a timed-out wait, got queued=%v err=%v", queued, err)
+	}
+
+	l.release()
+	if first, second := <-order, <-order; first != 1 || second != 2 {
+		t.Fatalf("expected waiters served in arrival order, got %d then %d", first, second)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.used != 0 || len(l.waiting) != 0 {
+		t.Fatalf("expected every slot returned, got used=%d waiting=%d", l.used, len(l.waiting))
+	}
+}
+
+func TestResolveQueuedRequestTimesOutWithinItsTimeout(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("stall", func(name string, _ config.ProviderConfig) (Adapter, error) {
+		return stallAdapter{name: name}, nil
+	})
+	cfg := config.Config{
+		AI:        config.AIConfig{TimeoutSeconds: 30, MaxConcurrent: 1},
+		Providers: map[string]config.ProviderConfig{"slow": {Type: "stall", TimeoutSeconds: 1}},
+	}
+	req := Request{Intent: IntentFind, Prompt: "x"}
+
+	hold, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _, _ = NewService(registry).Resolve(hold, cfg, req, "slow")
+	}()
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		slots.mu.Lock()
+		busy := slots.used == 1
+		slots.mu.Unlock()
+		if busy || time.Now().After(deadline) {
+			break
+		}
+	}
+
+	_, _, err := NewService(registry).Resolve(context.Background(), cfg, req, "slow")
+	cancel()
+	<-done
+	if !errors.Is(err, ErrTimedOut) || !strings.Contains(err.Error(), "queued behind other provider requests") {
+		t.Fatalf("expected the queue wait to count against the timeout, got %v", err)
+	}
+}
@@ -10,8 +10,9 @@ import (
 type Intent string
 
 const (
-	IntentFix  Intent = "fix"
-	IntentFind Intent = "find"
+	IntentFix     Intent = "fix"
+	IntentFind    Intent = "find"
+	IntentExplain Intent = "explain"
 )
 
 type Request struct {
@@ -30,6 +31,51 @@ type Resolution struct {
 	Risk              string  `json:"risk"`
 	Confidence        float64 `json:"confidence"`
 	NeedsConfirmation bool    `json:"needs_confirmation"`
+	// Explanation breaks Command down part by part; only explain requests
+	// ask for it.
+	Explanation []ExplainPart `json:"explanation,omitempty"`
+	// Steps is a fix that takes several commands, in the order they run;
+	// Command is then the first of them. Only fix requests ask for it.
+	Steps []PlanStep `json:"steps,omitempty"`
+	// Provider and Model say who answered: the provider's name and the
+	// concrete model its alias (auto-fast, auto-main) resolved to. The
+	// service sets them; they are never read from a provider's output.
+	Provider string `json:"-"`
+	Model    string `json:"-"`
+	// FallbackFrom lists, in order, the providers that were tried and failed
+	// before Provider answered.
+	FallbackFrom []string `json:"-"`
+	// Cached is set when the answer came from the response cache instead
+	// of a provider call.
+	Cached bool `json:"-"`
+	// Raw is the response text the answer was read from and ParsePath how
+	// it was read, stage by stage ("wrapper.result > direct_json"). Adapters
+	// that parse a response set them; they are not cached.
+	Raw       string `json:"-"`
+	ParsePath string `json:"-"`
+}
+
+// Parse paths: how parseResolution found the answer in a response.
+const (
+	ParseDirectJSON      = "direct_json"
+	ParseWrapperResult   = "wrapper.result"
+	ParseWrapperContent  = "wrapper.content"
+	ParseExtractedObject = "extracted_object"
+	// parseStageSeparator joins the stages of a parse path.
+	parseStageSeparator = " > "
+)
+
+// PlanStep is one command of a multi-step fix.
+type PlanStep struct {
+	Command string `json:"command"`
+	Reason  string `json:"reason"`
+}
+
+// ExplainPart is one piece of an explained command, such as "-x" or
+// "backup.tgz", and what it does there.
+type ExplainPart struct {
+	Part    string `json:"part"`
+	Meaning string `json:"meaning"`
 }
 
 type Adapter interface {
@@ -53,6 +99,7 @@ func NewRegistry() *Registry {
 	r := &Registry{factories: map[string]Factory{}}
 	r.Register("command", NewCommandAdapter)
 	r.Register("builtin", NewBuiltinAdapter)
+	r.Register("http", NewHTTPAdapter)
 	return r
 }
 
@@ -0,0 +1,150 @@
+package provider
+
+import (
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/ashwch/ew/internal/config"
+)
+
+// InheritEnv as a provider env entry passes ew's whole environment to the
+// provider CLI.
+const InheritEnv = "*"
+
+// baseProviderEnv is what every provider CLI gets whatever its env list
+// says: enough to find binaries, config and temp dirs, print in the user's
+// locale and reach the network through a proxy.
+var baseProviderEnv = []string{
+	"PATH", "HOME", "USER", "LOGNAME", "SHELL", "TERM", "TZ",
+	"LANG", "LANGUAGE", "LC_*", "XDG_*",
+	"TMPDIR", "TMP", "TEMP",
+	"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "ALL_PROXY",
+	"SSL_CERT_FILE", "SSL_CERT_DIR", "NODE_EXTRA_CA_CERTS",
+	"SYSTEMROOT", "COMSPEC", "PATHEXT", "USERPROFILE", "APPDATA", "LOCALAPPDATA",
+}
+
+// sensitiveEnvMarkers are name fragments of variables that usually hold a
+// credential.
+var sensitiveEnvMarkers = []string{"TOKEN", "SECRET", "PASSWORD", "PASSWD", "API_KEY", "APIKEY", "ACCESS_KEY", "PRIVATE_KEY", "CREDENTIAL", "AUTH"}
+
+// SandboxEnv returns the entries of environ ("NAME=value") that a provider
+// CLI configured by cfg may see: the base set plus whatever cfg.Env allows.
+func SandboxEnv(cfg config.ProviderConfig, environ []string) []string {
+	if InheritsEnv(cfg) {
+		return append([]string(nil), environ...)
+	}
+	allowed := make([]string, 0, len(baseProviderEnv)+len(cfg.Env))
+	allowed = append(allowed, baseProviderEnv...)
+	allowed = append(allowed, cfg.Env...)
+
+	filtered := make([]string, 0, len(allowed))
+	for _, entry := range environ {
+		name, _, _ := strings.Cut(entry, "=")
+		if envAllowed(allowed, name) {
+			filtered = append(filtered, entry)
+		}
+	}
+	return filtered
+}
+
+// ExposedSecrets returns the names of variables in environ that look like
+// credentials and that a provider configured by cfg would receive.
+func ExposedSecrets(cfg config.ProviderConfig, environ []string) []string {
+	return sensitiveNames(SandboxEnv(cfg, environ))
+}
+
+// WithheldSecrets returns the names of variables in environ that look like
+// credentials and that the sandbox keeps from a provider configured by cfg.
+func WithheldSecrets(cfg config.ProviderConfig, environ []string) []string {
+	var withheld []string
+	for _, name := range WithheldEnv(cfg, environ) {
+		if sensitiveName(name) {
+			withheld = append(withheld, name)
+		}
+	}
+	return withheld
+}
+
+// WithheldEnv returns the sorted names of every variable in environ that
+// the sandbox keeps from a provider configured by cfg.
+func WithheldEnv(cfg config.ProviderConfig, environ []string) []string {
+	passed := map[string]bool{}
+	for _, name := range envNames(SandboxEnv(cfg, environ)) {
+		passed[name] = true
+	}
+	var withheld []string
+	for _, name := range envNames(environ) {
+		if !passed[name] {
+			withheld = append(withheld, name)
+		}
+	}
+	sort.Strings(withheld)
+	return withheld
+}
+
+// InheritsEnv reports whether cfg opts out of the sandbox with "*".
+func InheritsEnv(cfg config.ProviderConfig) bool {
+	for _, entry := range cfg.Env {
+		if strings.TrimSpace(entry) == InheritEnv {
+			return true
+		}
+	}
+	return false
+}
+
+func envAllowed(allowed []string, name string) bool {
+	if runtime.GOOS == "windows" {
+		// Windows variable names are case-insensitive; Path is the usual
+		// spelling of PATH.
+		name = strings.ToUpper(name)
+	}
+	for _, pattern := range allowed {
+		pattern = strings.TrimSpace(pattern)
+		if runtime.GOOS == "windows" {
+			pattern = strings.ToUpper(pattern)
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if prefix != "" && strings.HasPrefix(name, prefix) {
+				return true
+			}
+			continue
+		}
+		// Proxy variables are conventionally honoured in lower case too.
+		if name == pattern || (strings.HasSuffix(pattern, "_PROXY") && name == strings.ToLower(pattern)) {
+			return true
+		}
+	}
+	return false
+}
+
+func sensitiveNames(environ []string) []string {
+	var names []string
+	for _, name := range envNames(environ) {
+		if sensitiveName(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sensitiveName(name string) bool {
+	upper := strings.ToUpper(name)
+	for _, marker := range sensitiveEnvMarkers {
+		if strings.Contains(upper, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+func envNames(environ []string) []string {
+	names := make([]string, 0, len(environ))
+	for _, entry := range environ {
+		if name, _, _ := strings.Cut(entry, "="); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
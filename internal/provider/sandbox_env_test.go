@@ -0,0 +1,87 @@
+package provider
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+
+	"github.com/ashwch/ew/internal/config"
+)
+
+func TestSandboxEnvKeepsBaseAndAllowedVariables(t *testing.T) {
+	environ := []string{
+		"PATH=/usr/bin",
+		"HOME=/home/me",
+		"LC_ALL=C",
+		"https_proxy=http://proxy:3128",
+		"OPENAI_API_KEY=sk-openai",
+		"GITHUB_TOKEN=ghp-secret",
+		"AWS_SECRET_ACCESS_KEY=aws-secret",
+		"EDITOR=vim",
+	}
+	cfg := config.ProviderConfig{Env: []string{"OPENAI_*", "EDITOR"}}
+
+	got := SandboxEnv(cfg, environ)
+	want := []string{"PATH=/usr/bin", "HOME=/home/me", "LC_ALL=C", "https_proxy=http://proxy:3128", "OPENAI_API_KEY=sk-openai", "EDITOR=vim"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("SandboxEnv = %q, want %q", got, want)
+	}
+	if exposed := ExposedSecrets(cfg, environ); !slices.Equal(exposed, []string{"OPENAI_API_KEY"}) {
+		t.Fatalf("unexpected exposed secrets %q", exposed)
+	}
+	if withheld := WithheldSecrets(cfg, environ); !slices.Equal(withheld, []string{"AWS_SECRET_ACCESS_KEY", "GITHUB_TOKEN"}) {
+		t.Fatalf("unexpected withheld secrets %q", withheld)
+	}
+	if withheld := WithheldEnv(cfg, environ); !slices.Equal(withheld, []string{"AWS_SECRET_ACCESS_KEY", "GITHUB_TOKEN"}) {
+		t.Fatalf("unexpected withheld variables %q", withheld)
+	}
+	if withheld := WithheldEnv(config.ProviderConfig{}, environ); !slices.Equal(withheld, []string{"AWS_SECRET_ACCESS_KEY", "EDITOR", "GITHUB_TOKEN", "OPENAI_API_KEY"}) {
+		t.Fatalf("expected an empty env list to keep only the base set, got %q", withheld)
+	}
+
+	inherit := config.ProviderConfig{Env: []string{InheritEnv}}
+	if got := SandboxEnv(inherit, environ); !slices.Equal(got, environ) {
+		t.Fatalf("expected \"*\" to pass the whole environment, got %q", got)
+	}
+	if withheld := WithheldSecrets(inherit, environ); len(withheld) != 0 {
+		t.Fatalf("expected nothing withheld with \"*\", got %q", withheld)
+	}
+}
+
+func TestCommandAdapterRunsWithSandboxedEnv(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script test is not portable on windows")
+	}
+	t.Setenv("EW_TEST_LEAKED_TOKEN", "leaked")
+	t.Setenv("EW_TEST_PROVIDER_KEY", "allowed")
+
+	scriptPath := filepath.Join(t.TempDir(), "provider.sh")
+	script := `#!/bin/sh
+reason="key=${EW_TEST_PROVIDER_KEY:-unset} token=${EW_TEST_LEAKED_TOKEN:-unset}"
+echo "{\"action\":\"suggest\",\"command\":\"true\",\"reason\":\"$reason\",\"risk\":\"low\",\"confidence\":0.9,\"needs_confirmation\":true}"
+`
+	if err := os.WriteFile(scriptPath, []byte(script), 0o755); err != nil {
+		t.Fatalf("write script failed: %v", err)
+	}
+	adapter, err := NewCommandAdapter("test", config.ProviderConfig{
+		Type:    "command",
+		Command: scriptPath,
+		Model:   "test-model",
+		Env:     []string{"EW_TEST_PROVIDER_*"},
+		Args:    []string{"{prompt}"},
+	})
+	if err != nil {
+		t.Fatalf("NewCommandAdapter failed: %v", err)
+	}
+
+	resolution, err := adapter.Resolve(context.Background(), Request{Intent: IntentFind, Prompt: "anything", Model: "test-model"})
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if resolution.Reason != "key=allowed token=unset" {
+		t.Fatalf("expected only allowed variables to reach the provider, got %q", resolution.Reason)
+	}
+}
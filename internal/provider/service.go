@@ -2,6 +2,7 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
@@ -10,6 +11,31 @@ import (
 	"github.com/ashwch/ew/internal/config"
 )
 
+// ErrNoneHealthy is wrapped by Resolve when no provider could be asked at
+// all: none configured, all disabled, or all failing their health check.
+var ErrNoneHealthy = errors.New("no healthy provider")
+
+// ErrTimedOut is wrapped by a provider's error when it did not answer
+// within its timeout (providers.<name>.timeout_seconds or
+// ai.timeout_seconds). Resolve moves on to the next provider.
+var ErrTimedOut = errors.New("provider timed out")
+
+// failedError is returned by Resolve when every provider it asked failed.
+// It unwraps to each provider's error, so errors.Is finds ErrTimedOut when
+// any of them timed out.
+type failedError struct {
+	issues []string
+	causes []error
+}
+
+func (e *failedError) Error() string {
+	return "all providers failed: " + strings.Join(e.issues, " | ")
+}
+
+func (e *failedError) Unwrap() []error {
+	return e.causes
+}
+
 type Service struct {
 	registry *Registry
 }
@@ -24,11 +50,18 @@ func NewService(registry *Registry) *Service {
 func (s *Service) Resolve(ctx context.Context, cfg config.Config, req Request, preferredProvider string) (Resolution, string, error) {
 	order := providerOrder(cfg, preferredProvider)
 	if len(order) == 0 {
-		return Resolution{}, "", fmt.Errorf("no providers configured")
+		return Resolution{}, "", fmt.Errorf("%w: none configured", ErrNoneHealthy)
 	}
 
 	issues := make([]string, 0, len(order))
+	var causes []error
+	var failed []string
+	attempted, restricted := 0, 0
 	for _, name := range order {
+		// A cancelled request must not fall through to the next provider.
+		if err := ctx.Err(); err != nil {
+			return Resolution{}, "", err
+		}
 		providerCfg, ok := cfg.Providers[name]
 		if !ok {
 			continue
@@ -36,15 +69,21 @@ func (s *Service) Resolve(ctx context.Context, cfg config.Config, req Request, p
 		if providerCfg.Enabled != nil && !*providerCfg.Enabled {
 			continue
 		}
+		if !providerCfg.AllowsIntent(string(req.Intent)) {
+			restricted++
+			continue
+		}
 
 		adapter, err := s.registry.Build(name, providerCfg)
 		if err != nil {
 			issues = append(issues, fmt.Sprintf("%s: %v", name, err))
+			failed = append(failed, name)
 			continue
 		}
 		if checker, ok := adapter.(HealthChecker); ok {
 			if err := checker.HealthCheck(); err != nil {
 				issues = append(issues, fmt.Sprintf("%s: %v", name, err))
+				failed = append(failed, name)
 				continue
 			}
 		}
@@ -55,20 +94,82 @@ func (s *Service) Resolve(ctx context.Context, cfg config.Config, req Request, p
 		providerReq.Context = cloneContext(req.Context)
 		providerReq.Context["permission_mode"] = permissionModeFor(providerReq.Mode)
 
-		providerCtx, cancel := timeoutContext(ctx, 90*time.Second)
-		resolution, err := adapter.Resolve(providerCtx, providerReq)
+		attempted++
+		tracer := tracerFrom(ctx)
+		tracer.record(TraceEvent{
+			Step:     TraceRequest,
+			Provider: name,
+			Intent:   string(providerReq.Intent),
+			Model:    providerReq.Model,
+			Thinking: providerReq.Thinking,
+			Prompt:   providerReq.Prompt,
+		})
+		timeout := cfg.Timeout(name)
+		providerCtx, cancel := context.WithTimeout(ctx, timeout)
+		var resolution Resolution
+		release, waited, err := takeSlot(providerCtx, cfg, adapter)
+		if waited > 0 {
+			tracer.record(TraceEvent{Step: TraceQueue, Provider: name, Waited: waited.Round(time.Millisecond).String()})
+		}
+		if err == nil {
+			resolution, err = resolveWith(providerCtx, adapter, providerReq)
+			release()
+		}
+		if err != nil && ctx.Err() == nil && errors.Is(providerCtx.Err(), context.DeadlineExceeded) {
+			// The adapter's own error is a killed process or a cancelled
+			// request; say what actually happened.
+			err = fmt.Errorf("%w after %s", ErrTimedOut, timeout)
+			if waited > 0 {
+				err = fmt.Errorf("%w (%s of it queued behind other provider requests; see ai.max_concurrent)", err, waited.Round(time.Second))
+			}
+		}
 		cancel()
 		if err != nil {
+			tracer.record(TraceEvent{Step: TraceError, Provider: name, Error: err.Error()})
 			issues = append(issues, fmt.Sprintf("%s: %v", name, err))
+			causes = append(causes, err)
+			failed = append(failed, name)
 			continue
 		}
-		return normalizeResolution(resolution), name, nil
+		resolution = normalizeResolution(resolution)
+		resolution.Provider, resolution.Model = name, providerReq.Model
+		resolution.FallbackFrom = failed
+		tracer.record(TraceEvent{Step: TraceResult, Provider: name, Model: providerReq.Model, Command: resolution.Command})
+		return resolution, name, nil
 	}
 
 	if len(issues) == 0 {
-		return Resolution{}, "", fmt.Errorf("no enabled provider was available")
+		if restricted > 0 {
+			return Resolution{}, "", fmt.Errorf("%w: none enabled for %s", ErrNoneHealthy, req.Intent)
+		}
+		return Resolution{}, "", fmt.Errorf("%w: none enabled", ErrNoneHealthy)
 	}
-	return Resolution{}, "", fmt.Errorf("all providers failed: %s", strings.Join(issues, " | "))
+	if attempted == 0 {
+		return Resolution{}, "", fmt.Errorf("%w: %s", ErrNoneHealthy, strings.Join(issues, " | "))
+	}
+	return Resolution{}, "", &failedError{issues: issues, causes: causes}
+}
+
+// Healthy lists, in resolution order, the enabled providers that build and
+// pass their health check. It never calls a provider, so it is cheap enough
+// to decide up front whether a request can go out at all.
+func (s *Service) Healthy(cfg config.Config, preferredProvider string) []string {
+	healthy := []string{}
+	for _, name := range providerOrder(cfg, preferredProvider) {
+		providerCfg := cfg.Providers[name]
+		if providerCfg.Enabled != nil && !*providerCfg.Enabled {
+			continue
+		}
+		adapter, err := s.registry.Build(name, providerCfg)
+		if err != nil {
+			continue
+		}
+		if checker, ok := adapter.(HealthChecker); ok && checker.HealthCheck() != nil {
+			continue
+		}
+		healthy = append(healthy, name)
+	}
+	return healthy
 }
 
 func providerOrder(cfg config.Config, preferredProvider string) []string {
@@ -92,6 +193,9 @@ func providerOrder(cfg config.Config, preferredProvider string) []string {
 
 	add(preferredProvider)
 	add(cfg.Provider)
+	for _, name := range cfg.ProviderOrder {
+		add(name)
+	}
 	add("codex")
 	add("claude")
 	add("ew")
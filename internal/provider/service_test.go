@@ -1,7 +1,11 @@
 package provider
 
 import (
+	"context"
+	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/ashwch/ew/internal/config"
 )
@@ -79,3 +83,152 @@ func TestResolveModelInvalidProviderDefaultFallsBackToKnownAlias(t *testing.T) {
 		t.Fatalf("expected fallback to known quality alias provider id, got %q", got)
 	}
 }
+
+func TestHealthySkipsDisabledAndMissingProviders(t *testing.T) {
+	enabled, disabled := true, false
+	cfg := config.Config{
+		Provider: "missing",
+		Providers: map[string]config.ProviderConfig{
+			"missing": {Type: "command", Command: "ew-test-no-such-cli", Enabled: &enabled},
+			"off":     {Type: "builtin", Command: "ew", Enabled: &disabled},
+			"ew":      {Type: "builtin", Command: "ew", Enabled: &enabled},
+		},
+	}
+	got := NewService(nil).Healthy(cfg, "")
+	if len(got) != 1 || got[0] != "ew" {
+		t.Fatalf("expected only the builtin provider, got %v", got)
+	}
+}
+
+func TestResolveWrapsErrNoneHealthyWhenNothingCouldBeAsked(t *testing.T) {
+	enabled, disabled := true, false
+	service := NewService(nil)
+	cases := map[string]config.Config{
+		"none configured": {},
+		"all disabled": {Providers: map[string]config.ProviderConfig{
+			"ew": {Type: "builtin", Command: "ew", Enabled: &disabled},
+		}},
+		"all missing": {Providers: map[string]config.ProviderConfig{
+			"missing": {Type: "command", Command: "ew-test-no-such-cli", Enabled: &enabled},
+		}},
+		"fix only": {Providers: map[string]config.ProviderConfig{
+			"ew": {Type: "builtin", Command: "ew", Enabled: &enabled, Intents: []string{"fix"}},
+		}},
+	}
+	for name, cfg := range cases {
+		if _, _, err := service.Resolve(context.Background(), cfg, Request{Intent: IntentFind, Prompt: "x"}, ""); !errors.Is(err, ErrNoneHealthy) {
+			t.Fatalf("%s: expected ErrNoneHealthy, got %v", name, err)
+		}
+	}
+}
+
+func TestResolveRecordsProviderAndModel(t *testing.T) {
+	enabled := true
+	cfg := config.Config{Providers: map[string]config.ProviderConfig{
+		"ew": {
+			Type:    "builtin",
+			Command: "ew",
+			Enabled: &enabled,
+			Model:   "rules",
+			Models:  map[string]config.ModelConfig{"rules": {ProviderModel: "ew-rules-v1", Speed: "fast"}},
+		},
+	}}
+	resolution, name, err := NewService(nil).Resolve(context.Background(), cfg, Request{
+		Intent: IntentFind,
+		Model:  "auto-fast",
+		Prompt: `Return only JSON matching schema. Find the best shell command for this request: "how to git push current branch".`,
+	}, "")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if name != "ew" || resolution.Provider != "ew" || resolution.Model != "ew-rules-v1" {
+		t.Fatalf("expected the alias resolved to its concrete model, got %q %q %q", name, resolution.Provider, resolution.Model)
+	}
+}
+
+// stallAdapter never answers; it returns once its context is done.
+type stallAdapter struct{ name string }
+
+func (a stallAdapter) Name() string { return a.name }
+func (a stallAdapter) Type() string { return "stall" }
+func (a stallAdapter) BuildInvocation(Request) ([]string, error) {
+	return nil, nil
+}
+func (a stallAdapter) Resolve(ctx context.Context, _ Request) (Resolution, error) {
+	<-ctx.Done()
+	return Resolution{}, ctx.Err()
+}
+
+func TestResolveTimesOutAndFallsBackToTheNextProvider(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("stall", func(name string, _ config.ProviderConfig) (Adapter, error) {
+		return stallAdapter{name: name}, nil
+	})
+	cfg := config.Config{
+		AI: config.AIConfig{TimeoutSeconds: 30},
+		Providers: map[string]config.ProviderConfig{
+			"slow": {Type: "stall", TimeoutSeconds: 1},
+			"ew":   {Type: "builtin", Command: "ew", Model: "ew-core"},
+		},
+	}
+	req := Request{Intent: IntentFind, Prompt: `Return only JSON matching schema. Find the best shell command for this request: "how to git push current branch".`}
+
+	started := time.Now()
+	_, name, err := NewService(registry).Resolve(context.Background(), cfg, req, "slow")
+	if err != nil || name != "ew" {
+		t.Fatalf("expected the next provider to answer after the timeout, got %q %v", name, err)
+	}
+	if elapsed := time.Since(started); elapsed > 10*time.Second {
+		t.Fatalf("expected the per-provider timeout to win over ai.timeout_seconds, took %s", elapsed)
+	}
+
+	delete(cfg.Providers, "ew")
+	_, _, err = NewService(registry).Resolve(context.Background(), cfg, req, "slow")
+	if !errors.Is(err, ErrTimedOut) || !strings.Contains(err.Error(), "slow: provider timed out after 1s") {
+		t.Fatalf("expected a clear timeout error, got %v", err)
+	}
+}
+
+func TestProviderOrderFollowsConfiguredPriority(t *testing.T) {
+	cfg := config.Config{
+		Provider:      "auto",
+		ProviderOrder: []string{"ollama", "claude", "unknown"},
+		Providers: map[string]config.ProviderConfig{
+			"codex":  {},
+			"claude": {},
+			"ollama": {},
+			"ew":     {},
+			"zed":    {},
+		},
+	}
+	got := strings.Join(providerOrder(cfg, "codex"), ",")
+	if got != "codex,ollama,claude,ew,zed" {
+		t.Fatalf("expected preferred provider, then provider_order, then the built-in order, got %s", got)
+	}
+}
+
+func TestResolveFallsBackAlongProviderOrderAndRecordsIt(t *testing.T) {
+	enabled := true
+	cfg := config.Config{
+		Provider:      "missing",
+		ProviderOrder: []string{"rules", "ew"},
+		Providers: map[string]config.ProviderConfig{
+			"missing": {Type: "command", Command: "ew-test-no-such-cli", Enabled: &enabled},
+			"rules":   {Type: "builtin", Command: "ew", Enabled: &enabled},
+			"ew":      {Type: "builtin", Command: "ew", Enabled: &enabled},
+		},
+	}
+	resolution, name, err := NewService(nil).Resolve(context.Background(), cfg, Request{
+		Intent: IntentFind,
+		Prompt: `Return only JSON matching schema. Find the best shell command for this request: "how to git push current branch".`,
+	}, "")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if name != "rules" || resolution.Provider != "rules" {
+		t.Fatalf("expected provider_order's first entry to answer, got %q", name)
+	}
+	if len(resolution.FallbackFrom) != 1 || resolution.FallbackFrom[0] != "missing" {
+		t.Fatalf("expected the failed provider to be recorded, got %v", resolution.FallbackFrom)
+	}
+}
@@ -0,0 +1,94 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+)
+
+// StreamingAdapter is implemented by adapters that can report output while
+// a request is still running. Service.Resolve calls ResolveStream instead
+// of Resolve when the caller asked for partial output with
+// WithPartialOutput; partial gets the latest line of output each time it
+// changes and may be called from several goroutines.
+type StreamingAdapter interface {
+	ResolveStream(ctx context.Context, req Request, partial func(string)) (Resolution, error)
+}
+
+type partialKey struct{}
+
+// WithPartialOutput attaches fn to ctx so streaming adapters report their
+// output to it as it arrives.
+func WithPartialOutput(ctx context.Context, fn func(string)) context.Context {
+	if fn == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, partialKey{}, fn)
+}
+
+func partialFrom(ctx context.Context) func(string) {
+	if ctx == nil {
+		return nil
+	}
+	fn, _ := ctx.Value(partialKey{}).(func(string))
+	return fn
+}
+
+// resolveWith asks adapter for a resolution, streaming when both the
+// adapter and the caller support it.
+func resolveWith(ctx context.Context, adapter Adapter, req Request) (Resolution, error) {
+	if streamer, ok := adapter.(StreamingAdapter); ok {
+		if partial := partialFrom(ctx); partial != nil {
+			return streamer.ResolveStream(ctx, req, partial)
+		}
+	}
+	return adapter.Resolve(ctx, req)
+}
+
+// maxPendingLine bounds how much of an unfinished line lineReporter keeps.
+const maxPendingLine = 4096
+
+// lineReporter is an io.Writer for a subprocess's stdout and stderr that
+// passes the latest non-blank line on to partial, including a line still
+// being written.
+type lineReporter struct {
+	mu      sync.Mutex
+	pending []byte
+	last    string
+	partial func(string)
+}
+
+func newLineReporter(partial func(string)) *lineReporter {
+	return &lineReporter{partial: partial}
+}
+
+func (r *lineReporter) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pending = append(r.pending, p...)
+	latest := ""
+	for {
+		idx := bytes.IndexByte(r.pending, '\n')
+		if idx < 0 {
+			break
+		}
+		if line := strings.TrimSpace(string(r.pending[:idx])); line != "" {
+			latest = line
+		}
+		r.pending = r.pending[idx+1:]
+	}
+	// Only the end of an unfinished line is ever shown; a CLI that prints
+	// its whole answer on one line should not grow the buffer with it.
+	if len(r.pending) > maxPendingLine {
+		r.pending = r.pending[len(r.pending)-maxPendingLine:]
+	}
+	if tail := strings.TrimSpace(strings.ToValidUTF8(string(r.pending), "")); tail != "" {
+		latest = tail
+	}
+	if latest != "" && latest != r.last {
+		r.last = latest
+		r.partial(latest)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,66 @@
+package provider
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/ashwch/ew/internal/config"
+)
+
+func TestLineReporterPassesLatestLine(t *testing.T) {
+	var got []string
+	reporter := newLineReporter(func(line string) { got = append(got, line) })
+	for _, chunk := range []string{"think", "ing\n\n", "  \n", "reading files\nwri", "ting\n"} {
+		if _, err := reporter.Write([]byte(chunk)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	want := []string{"think", "thinking", "wri", "writing"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("reported %q, want %q", got, want)
+	}
+}
+
+func TestServiceStreamsCommandProviderOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script test is not portable on windows")
+	}
+	scriptPath := filepath.Join(t.TempDir(), "provider.sh")
+	script := `#!/bin/sh
+echo "looking at git" >&2
+echo '{"action":"suggest","command":"git status","reason":"show changes","risk":"low","confidence":0.9,"needs_confirmation":true}'
+`
+	if err := os.WriteFile(scriptPath, []byte(script), 0o755); err != nil {
+		t.Fatalf("write script failed: %v", err)
+	}
+	cfg := config.Config{Providers: map[string]config.ProviderConfig{
+		"local": {Type: "command", Command: scriptPath, Model: "test-model", Args: []string{"{prompt}"}},
+	}}
+
+	var (
+		mu    sync.Mutex
+		lines []string
+	)
+	ctx := WithPartialOutput(context.Background(), func(line string) {
+		mu.Lock()
+		defer mu.Unlock()
+		lines = append(lines, line)
+	})
+	resolution, _, err := NewService(nil).Resolve(ctx, cfg, Request{Intent: IntentFind, Prompt: "what changed"}, "local")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if resolution.Command != "git status" {
+		t.Fatalf("unexpected resolution %+v", resolution)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.Contains(lines, "looking at git") {
+		t.Fatalf("expected stderr progress to be streamed, got %q", lines)
+	}
+}
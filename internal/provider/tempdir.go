@@ -0,0 +1,173 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ashwch/ew/internal/appdirs"
+)
+
+// Provider scratch files live under one parent dir per ew process, so a
+// crashed run leaves exactly one directory behind and the sweeper removes it
+// with a single RemoveAll.
+const (
+	processTempPrefix = "ew-run-"
+	requestTempPrefix = "ew-provider-"
+	sweepStampName    = "tempdir-sweep"
+)
+
+var processTemp struct {
+	mu  sync.Mutex
+	dir string
+}
+
+func requestTempDir() (string, error) {
+	parent, err := processTempDir()
+	if err != nil {
+		return "", err
+	}
+	return os.MkdirTemp(parent, requestTempPrefix)
+}
+
+func processTempDir() (string, error) {
+	processTemp.mu.Lock()
+	defer processTemp.mu.Unlock()
+	if processTemp.dir != "" {
+		if info, err := os.Stat(processTemp.dir); err == nil && info.IsDir() {
+			return processTemp.dir, nil
+		}
+	}
+	dir, err := os.MkdirTemp("", fmt.Sprintf("%s%d-", processTempPrefix, os.Getpid()))
+	if err != nil {
+		return "", err
+	}
+	processTemp.dir = dir
+	return dir, nil
+}
+
+// CleanupTempDirs removes this process's provider scratch dir.
+func CleanupTempDirs() {
+	processTemp.mu.Lock()
+	defer processTemp.mu.Unlock()
+	if processTemp.dir == "" {
+		return
+	}
+	_ = os.RemoveAll(processTemp.dir)
+	processTemp.dir = ""
+}
+
+// SweepOrphanedTempDirs removes ew scratch dirs in the system temp dir that
+// are older than maxAge and whose process is gone. Provider calls time out
+// long before that, so anything this old was left behind by a killed
+// process. A stamp in the state dir limits the sweep to once per maxAge, so
+// most invocations never list the temp dir. It returns the number of
+// directories removed.
+func SweepOrphanedTempDirs(maxAge time.Duration) int {
+	stamp, err := appdirs.StateFilePath(sweepStampName)
+	if err != nil {
+		return 0
+	}
+	now := time.Now()
+	if !sweepDue(stamp, maxAge, now) {
+		return 0
+	}
+	return sweepTempDirs(os.TempDir(), maxAge, now)
+}
+
+// sweepDue reports whether the last sweep recorded in stamp is at least
+// maxAge old, and if so records this one. A stamp that cannot be written
+// skips the sweep rather than running it on every invocation.
+func sweepDue(stamp string, maxAge time.Duration, now time.Time) bool {
+	if info, err := os.Stat(stamp); err == nil && now.Sub(info.ModTime()) < maxAge {
+		return false
+	}
+	if _, err := appdirs.EnsureStateDir(); err != nil {
+		return false
+	}
+	if err := os.WriteFile(stamp, nil, 0o600); err != nil {
+		return false
+	}
+	return os.Chtimes(stamp, now, now) == nil
+}
+
+func sweepTempDirs(root string, maxAge time.Duration, now time.Time) int {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return 0
+	}
+	processTemp.mu.Lock()
+	own := processTemp.dir
+	processTemp.mu.Unlock()
+
+	removed := 0
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() {
+			continue
+		}
+		// ew-provider-* dirs directly under the temp root come from older
+		// releases that did not use a per-process parent.
+		if !strings.HasPrefix(name, processTempPrefix) && !strings.HasPrefix(name, requestTempPrefix) {
+			continue
+		}
+		path := filepath.Join(root, name)
+		if path == own {
+			continue
+		}
+		// A long-running ew, such as a chat session, keeps its dir for as
+		// long as it lives, however old the dir gets.
+		if pid, ok := tempDirPID(name); ok && processAlive(pid) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || now.Sub(info.ModTime()) < maxAge {
+			continue
+		}
+		if err := os.RemoveAll(path); err == nil {
+			removed++
+		}
+	}
+	return removed
+}
+
+// tempDirPID returns the process id in an ew-run-<pid>-<random> name.
+func tempDirPID(name string) (int, bool) {
+	rest, ok := strings.CutPrefix(name, processTempPrefix)
+	if !ok {
+		return 0, false
+	}
+	digits, _, ok := strings.Cut(rest, "-")
+	if !ok {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(digits)
+	if err != nil || pid <= 0 {
+		return 0, false
+	}
+	return pid, true
+}
+
+// processAlive reports whether pid names a running process. On Windows
+// FindProcess already fails for a process that has exited; elsewhere it
+// always succeeds and signal 0 does the check. EPERM means the process
+// exists but belongs to another user.
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		_ = process.Release()
+		return true
+	}
+	err = process.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
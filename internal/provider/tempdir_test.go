@@ -0,0 +1,116 @@
+package provider
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ashwch/ew/internal/appdirs"
+)
+
+func TestRequestTempDirUsesPerProcessParent(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	t.Cleanup(CleanupTempDirs)
+
+	first, err := requestTempDir()
+	if err != nil {
+		t.Fatalf("requestTempDir failed: %v", err)
+	}
+	second, err := requestTempDir()
+	if err != nil {
+		t.Fatalf("requestTempDir failed: %v", err)
+	}
+	parent := filepath.Dir(first)
+	if filepath.Dir(second) != parent {
+		t.Fatalf("expected request dirs to share a parent, got %q and %q", first, second)
+	}
+	if !strings.HasPrefix(filepath.Base(parent), processTempPrefix) {
+		t.Fatalf("expected per-process parent, got %q", parent)
+	}
+
+	CleanupTempDirs()
+	if _, err := os.Stat(parent); !os.IsNotExist(err) {
+		t.Fatalf("expected process temp dir to be removed, stat err=%v", err)
+	}
+}
+
+func TestSweepTempDirsRemovesOnlyStaleEwDirs(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	stale := now.Add(-2 * time.Hour)
+
+	mkdir := func(name string, modTime time.Time) string {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Join(path, "nested"), 0o700); err != nil {
+			t.Fatalf("mkdir failed: %v", err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("chtimes failed: %v", err)
+		}
+		return path
+	}
+	staleRun := mkdir(fmt.Sprintf("ew-run-%d-abc", exitedPID(t)), stale)
+	staleLegacy := mkdir("ew-provider-xyz", stale)
+	freshRun := mkdir("ew-run-456-def", now)
+	liveRun := mkdir(fmt.Sprintf("ew-run-%d-ghi", os.Getpid()), stale)
+	unrelated := mkdir("other-tool-1", stale)
+
+	if removed := sweepTempDirs(root, time.Hour, now); removed != 2 {
+		t.Fatalf("expected 2 stale dirs removed, got %d", removed)
+	}
+	for _, path := range []string{staleRun, staleLegacy} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("expected %q to be removed", path)
+		}
+	}
+	for _, path := range []string{freshRun, liveRun, unrelated} {
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("expected %q to be kept: %v", path, err)
+		}
+	}
+}
+
+// exitedPID returns the pid of a process that has already been reaped.
+func exitedPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("could not run a short-lived process: %v", err)
+	}
+	return cmd.Process.Pid
+}
+
+func TestSweepDueRunsOncePerInterval(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, ".local", "state"))
+	stamp, err := appdirs.StateFilePath(sweepStampName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if !sweepDue(stamp, time.Hour, now) {
+		t.Fatal("expected the first sweep to run")
+	}
+	if sweepDue(stamp, time.Hour, now.Add(time.Minute)) {
+		t.Fatal("expected a sweep within the interval to be skipped")
+	}
+	if !sweepDue(stamp, time.Hour, now.Add(2*time.Hour)) {
+		t.Fatal("expected the sweep to run again after the interval")
+	}
+}
+
+func TestTempDirPIDParsesProcessDirs(t *testing.T) {
+	if pid, ok := tempDirPID("ew-run-4242-abc123"); !ok || pid != 4242 {
+		t.Fatalf("expected pid 4242, got %d %v", pid, ok)
+	}
+	for _, name := range []string{"ew-provider-xyz", "ew-run-abc-123", "ew-run-", "ew-run-0-x"} {
+		if _, ok := tempDirPID(name); ok {
+			t.Fatalf("expected no pid in %q", name)
+		}
+	}
+}
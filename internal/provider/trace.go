@@ -0,0 +1,129 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ashwch/ew/internal/safety"
+)
+
+// Trace steps, in the order a resolution goes through them.
+const (
+	TraceRequest    = "request"
+	TraceQueue      = "queue"
+	TraceInvocation = "invocation"
+	TraceRawOutput  = "raw_output"
+	TraceParse      = "parse"
+	TraceResult     = "result"
+	TraceError      = "error"
+)
+
+// TraceEvent is one line of an EW_TRACE file. Text fields are redacted
+// before they are written.
+type TraceEvent struct {
+	Time     string   `json:"time"`
+	Step     string   `json:"step"`
+	Provider string   `json:"provider,omitempty"`
+	Intent   string   `json:"intent,omitempty"`
+	Model    string   `json:"model,omitempty"`
+	Thinking string   `json:"thinking,omitempty"`
+	Prompt   string   `json:"prompt,omitempty"`
+	Args     []string `json:"args,omitempty"`
+	// Attempt names the parse strategy for parse events.
+	Attempt string `json:"attempt,omitempty"`
+	Stdout  string `json:"stdout,omitempty"`
+	Stderr  string `json:"stderr,omitempty"`
+	Command string `json:"command,omitempty"`
+	Error   string `json:"error,omitempty"`
+	// Waited is how long a queue event waited for a provider slot.
+	Waited string `json:"waited,omitempty"`
+}
+
+// Tracer writes TraceEvents as JSON lines. A nil Tracer drops them, so
+// callers never need to check whether tracing is on.
+type Tracer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewTracer(w io.Writer) *Tracer {
+	return &Tracer{w: w}
+}
+
+type tracerKey struct{}
+
+// WithTracer attaches t to ctx; Service.Resolve and the adapters it calls
+// record each step to it.
+func WithTracer(ctx context.Context, t *Tracer) context.Context {
+	if t == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, tracerKey{}, t)
+}
+
+func tracerFrom(ctx context.Context) *Tracer {
+	if ctx == nil {
+		return nil
+	}
+	t, _ := ctx.Value(tracerKey{}).(*Tracer)
+	return t
+}
+
+func (t *Tracer) record(event TraceEvent) {
+	if t == nil || t.w == nil {
+		return
+	}
+	event.Time = time.Now().UTC().Format(time.RFC3339Nano)
+	event.Prompt = safety.RedactText(event.Prompt)
+	event.Stdout = safety.RedactText(event.Stdout)
+	event.Stderr = safety.RedactText(event.Stderr)
+	event.Command = safety.RedactText(event.Command)
+	event.Error = safety.RedactText(event.Error)
+	if len(event.Args) > 0 {
+		args := make([]string, len(event.Args))
+		for i, arg := range event.Args {
+			args[i] = safety.RedactText(arg)
+		}
+		event.Args = args
+	}
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, _ = t.w.Write(append(line, '\n'))
+}
+
+// traceArgs replaces long rendered values in an invocation with the
+// placeholder they came from, so the trace shows how the arguments were
+// built without repeating the prompt and schema in every line.
+func traceArgs(invocation []string, req Request) []string {
+	values := map[string]string{"prompt": req.Prompt}
+	if schema, ok := req.Context["schema_json"].(string); ok {
+		values["schema_json"] = schema
+	}
+	out := make([]string, len(invocation))
+	for i, arg := range invocation {
+		out[i] = arg
+		for _, key := range []string{"prompt", "schema_json"} {
+			value := values[key]
+			if strings.TrimSpace(value) != "" && strings.Contains(arg, value) {
+				out[i] = strings.ReplaceAll(out[i], value, "{"+key+"}")
+			}
+		}
+	}
+	return out
+}
+
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -13,4 +13,17 @@ const (
 	IntentConfigSet  Intent = "config_set"
 	IntentDiagnose   Intent = "diagnose"
 	IntentSetupHooks Intent = "setup_hooks"
+
+	IntentMemoryEdit      Intent = "memory_edit"
+	IntentTLDRUpdate      Intent = "tldr_update"
+	IntentCheatImport     Intent = "cheat_import"
+	IntentSwitch          Intent = "switch"
+	IntentQuick           Intent = "quick"
+	IntentExplain         Intent = "explain"
+	IntentTipDismiss      Intent = "tip_dismiss"
+	IntentCacheClear      Intent = "cache_clear"
+	IntentTracePlan       Intent = "trace_plan"
+	IntentUndo            Intent = "undo"
+	IntentMemoryBootstrap Intent = "memory_bootstrap"
+	IntentAlias           Intent = "alias"
 )
@@ -0,0 +1,146 @@
+package runbook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ashwch/ew/internal/state"
+)
+
+const (
+	indexName = "runbook_index.json"
+	// maxFiles and maxFileBytes bound what is indexed, so pointing
+	// runbooks.dir at a large tree does not slow every find down.
+	maxFiles     = 2000
+	maxFileBytes = 1 << 20
+)
+
+type index struct {
+	Dir   string                 `json:"dir"`
+	Files map[string]indexedFile `json:"files"`
+}
+
+// indexedFile is a parsed runbook, reused while its size and modification
+// time stay the same.
+type indexedFile struct {
+	ModTime  int64     `json:"mod_time"`
+	Size     int64     `json:"size"`
+	Sections []Section `json:"sections"`
+}
+
+// Load returns the sections of every markdown file under dir, including
+// subdirectories, with File relative to dir. Parsed files are kept in an
+// index in the state directory and only read again when they change. A
+// missing dir is empty.
+func Load(dir string) ([]Section, error) {
+	dir = filepath.Clean(dir)
+	previous := loadIndex(dir)
+	current := index{Dir: dir, Files: map[string]indexedFile{}}
+	changed := false
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir && os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isMarkdown(d.Name()) {
+			return nil
+		}
+		if len(current.Files) >= maxFiles {
+			return filepath.SkipAll
+		}
+		info, err := d.Info()
+		if err != nil || info.Size() > maxFileBytes {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if cached, ok := previous.Files[rel]; ok && cached.ModTime == info.ModTime().UnixNano() && cached.Size == info.Size() {
+			current.Files[rel] = cached
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		current.Files[rel] = indexedFile{ModTime: info.ModTime().UnixNano(), Size: info.Size(), Sections: Parse(rel, string(content))}
+		changed = true
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not read runbooks: %w", err)
+	}
+	if changed || len(current.Files) != len(previous.Files) {
+		// The index only saves parsing; a failed save is not worth failing
+		// the search for.
+		_ = saveIndex(current)
+	}
+
+	names := make([]string, 0, len(current.Files))
+	for name := range current.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	sections := []Section{}
+	for _, name := range names {
+		sections = append(sections, current.Files[name].Sections...)
+	}
+	return sections, nil
+}
+
+func isMarkdown(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".md", ".markdown":
+		return true
+	}
+	return false
+}
+
+// loadIndex reads the saved index for dir. An index for another dir, or
+// one that cannot be read, counts as empty.
+func loadIndex(dir string) index {
+	empty := index{Dir: dir, Files: map[string]indexedFile{}}
+	backend, err := state.Current()
+	if err != nil {
+		return empty
+	}
+	raw, err := backend.Read(indexName)
+	if err != nil || raw == nil {
+		return empty
+	}
+	var saved index
+	if err := json.Unmarshal(raw, &saved); err != nil || saved.Dir != dir || saved.Files == nil {
+		return empty
+	}
+	return saved
+}
+
+func saveIndex(doc index) error {
+	payload, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("could not encode runbook index: %w", err)
+	}
+	backend, err := state.Current()
+	if err != nil {
+		return err
+	}
+	if err := backend.Write(indexName, payload); err != nil {
+		return fmt.Errorf("could not save runbook index: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+package runbook
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// maxSectionText bounds the prose kept per section for matching.
+const maxSectionText = 600
+
+// Section is the part of a markdown runbook under one heading that has
+// shell code blocks. Heading is the heading with its parents, as in
+// "Deploy > Roll back the API", and Line is where the heading is.
+type Section struct {
+	File     string    `json:"file"`
+	Heading  string    `json:"heading"`
+	Line     int       `json:"line"`
+	Text     string    `json:"text,omitempty"`
+	Commands []Command `json:"commands"`
+}
+
+// Command is one command from a code block and the line it is on.
+type Command struct {
+	Command string `json:"command"`
+	Line    int    `json:"line"`
+}
+
+// Match is the best command of a section for a find query.
+type Match struct {
+	File    string  `json:"file"`
+	Heading string  `json:"heading"`
+	Line    int     `json:"line"`
+	Command string  `json:"command"`
+	Score   float64 `json:"score"`
+}
+
+// Citation names where the command came from, as deploy.md:42 (Deploy >
+// Roll back the API), so it can be looked up before it is run.
+func (m Match) Citation() string {
+	if m.Heading == "" {
+		return fmt.Sprintf("%s:%d", m.File, m.Line)
+	}
+	return fmt.Sprintf("%s:%d (%s)", m.File, m.Line, m.Heading)
+}
+
+var (
+	headingPattern = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	fencePattern   = regexp.MustCompile("^(```+|~~~+)\\s*([A-Za-z0-9_+-]*)")
+)
+
+// shellLanguages are the code block languages read as commands. Blocks
+// with no language are read too, since runbooks often leave it out.
+var shellLanguages = map[string]bool{
+	"": true, "sh": true, "bash": true, "zsh": true, "fish": true, "shell": true,
+	"console": true, "shell-session": true, "terminal": true,
+}
+
+// Parse reads the sections of a markdown runbook: its ATX headings, the
+// prose under each, and the commands in its shell code blocks. In a
+// console block, or any block where lines start with a "$ " prompt, only
+// the prompted lines are commands and the rest is output. Lines ending in
+// "\" continue on the next one; comment lines are skipped.
+func Parse(file string, content string) []Section {
+	sections := []Section{}
+	headings := []string{}
+	current := Section{File: file}
+	var text []string
+
+	flush := func() {
+		if len(current.Commands) > 0 {
+			current.Text = strings.Join(text, " ")
+			if len(current.Text) > maxSectionText {
+				current.Text = current.Text[:maxSectionText]
+			}
+			sections = append(sections, current)
+		}
+		text = nil
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNo := 0
+	var (
+		fence    string
+		language string
+		block    []Command
+	)
+	for scanner.Scan() {
+		lineNo++
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+		if fence != "" {
+			if strings.HasPrefix(line, fence) && strings.Trim(line, fence[:1]) == "" {
+				if shellLanguages[language] {
+					current.Commands = append(current.Commands, blockCommands(block, language)...)
+				}
+				fence, block = "", nil
+				continue
+			}
+			block = append(block, Command{Command: raw, Line: lineNo})
+			continue
+		}
+		if match := fencePattern.FindStringSubmatch(line); match != nil {
+			fence, language = match[1], strings.ToLower(match[2])
+			continue
+		}
+		if match := headingPattern.FindStringSubmatch(line); match != nil {
+			flush()
+			level := len(match[1])
+			if len(headings) >= level {
+				headings = headings[:level-1]
+			}
+			for len(headings) < level-1 {
+				headings = append(headings, "")
+			}
+			headings = append(headings, match[2])
+			current = Section{File: file, Heading: joinHeadings(headings), Line: lineNo}
+			continue
+		}
+		if line != "" {
+			text = append(text, line)
+		}
+	}
+	flush()
+	return sections
+}
+
+func joinHeadings(headings []string) string {
+	parts := make([]string, 0, len(headings))
+	for _, heading := range headings {
+		if heading != "" {
+			parts = append(parts, heading)
+		}
+	}
+	return strings.Join(parts, " > ")
+}
+
+func blockCommands(lines []Command, language string) []Command {
+	prompted := language == "console" || language == "shell-session" || language == "terminal"
+	for _, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line.Command), "$ ") {
+			prompted = true
+		}
+	}
+	commands := []Command{}
+	var pending *Command
+	for _, line := range lines {
+		text := strings.TrimSpace(line.Command)
+		if pending != nil {
+			pending.Command += " " + strings.TrimSuffix(text, `\`)
+			if !strings.HasSuffix(text, `\`) {
+				commands = append(commands, *pending)
+				pending = nil
+			}
+			continue
+		}
+		if prompted {
+			rest, ok := strings.CutPrefix(text, "$ ")
+			if !ok {
+				continue
+			}
+			text = strings.TrimSpace(rest)
+		}
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		command := Command{Command: strings.TrimSpace(strings.TrimSuffix(text, `\`)), Line: line.Line}
+		if strings.HasSuffix(text, `\`) {
+			pending = &command
+			continue
+		}
+		commands = append(commands, command)
+	}
+	if pending != nil {
+		commands = append(commands, *pending)
+	}
+	return commands
+}
+
+// Search scores sections against query: a query word in the heading
+// counts most, then one in the prose, then one in a command. Sections
+// sharing nothing with the heading or prose are dropped. Each section
+// gives its one command sharing the most words with the query, or its
+// first command.
+func Search(sections []Section, query string, limit int) []Match {
+	tokens := queryTokens(query)
+	if len(tokens) == 0 {
+		return nil
+	}
+	matches := []Match{}
+	for _, section := range sections {
+		heading := wordSet(section.Heading)
+		text := wordSet(section.Text)
+		headingHits, textHits := 0.0, 0.0
+		for _, token := range tokens {
+			if heading[token] {
+				headingHits++
+			} else if text[token] {
+				textHits++
+			}
+		}
+		if headingHits == 0 && textHits == 0 || len(section.Commands) == 0 {
+			continue
+		}
+		best, bestHits := section.Commands[0], 0.0
+		for _, command := range section.Commands {
+			words := wordSet(command.Command)
+			hits := 0.0
+			for _, token := range tokens {
+				if words[token] && !heading[token] && !text[token] {
+					hits++
+				}
+			}
+			if hits > bestHits {
+				best, bestHits = command, hits
+			}
+		}
+		matches = append(matches, Match{
+			File:    section.File,
+			Heading: section.Heading,
+			Line:    best.Line,
+			Command: best.Command,
+			Score:   headingHits*4 + textHits*2 + bestHits,
+		})
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score == matches[j].Score {
+			return matches[i].File < matches[j].File
+		}
+		return matches[i].Score > matches[j].Score
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+var stopwords = map[string]bool{
+	"a": true, "an": true, "the": true, "to": true, "of": true, "in": true, "on": true, "for": true,
+	"and": true, "or": true, "with": true, "how": true, "do": true, "i": true, "my": true, "me": true,
+	"command": true, "commands": true, "use": true, "using": true, "runbook": true, "step": true, "steps": true,
+}
+
+func queryTokens(query string) []string {
+	tokens := []string{}
+	for word := range wordSet(query) {
+		if !stopwords[word] {
+			tokens = append(tokens, word)
+		}
+	}
+	sort.Strings(tokens)
+	return tokens
+}
+
+func wordSet(text string) map[string]bool {
+	set := map[string]bool{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '.')
+	}) {
+		word = strings.Trim(word, ".-")
+		if word == "" {
+			continue
+		}
+		set[stem(word)] = true
+	}
+	return set
+}
+
+func stem(word string) string {
+	for _, suffix := range []string{"ing", "ed", "es", "s"} {
+		if len(word) > len(suffix)+2 && strings.HasSuffix(word, suffix) {
+			return strings.TrimSuffix(word, suffix)
+		}
+	}
+	return word
+}
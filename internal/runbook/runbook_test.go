@@ -0,0 +1,120 @@
+package runbook
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const deployRunbook = "# Deploy\n\n" +
+	"Deploys go out from the release branch.\n\n" +
+	"## Roll back the API\n\n" +
+	"Use this when the new release fails its health checks.\n\n" +
+	"```bash\n" +
+	"# pick the previous release first\n" +
+	"kubectl -n api rollout undo deployment/api \\\n" +
+	"  --to-revision=0\n" +
+	"kubectl -n api rollout status deployment/api\n" +
+	"```\n\n" +
+	"## Check the logs\n\n" +
+	"```console\n" +
+	"$ kubectl -n api logs deploy/api --since=10m\n" +
+	"Found 3 pods, using pod/api-1\n" +
+	"```\n\n" +
+	"```python\n" +
+	"print('not a command')\n" +
+	"```\n"
+
+func TestParseReadsHeadingsAndShellBlocks(t *testing.T) {
+	sections := Parse("deploy.md", deployRunbook)
+	want := []Section{
+		{
+			File:    "deploy.md",
+			Heading: "Deploy > Roll back the API",
+			Line:    5,
+			Text:    "Use this when the new release fails its health checks.",
+			Commands: []Command{
+				{Command: "kubectl -n api rollout undo deployment/api --to-revision=0", Line: 11},
+				{Command: "kubectl -n api rollout status deployment/api", Line: 13},
+			},
+		},
+		{
+			File:     "deploy.md",
+			Heading:  "Deploy > Check the logs",
+			Line:     16,
+			Commands: []Command{{Command: "kubectl -n api logs deploy/api --since=10m", Line: 19}},
+		},
+	}
+	if !reflect.DeepEqual(sections, want) {
+		t.Fatalf("unexpected sections:\n got %+v\nwant %+v", sections, want)
+	}
+}
+
+func TestSearchPrefersHeadingWordsAndCitesTheSection(t *testing.T) {
+	sections := Parse("deploy.md", deployRunbook)
+
+	matches := Search(sections, "roll back the api", 0)
+	if len(matches) == 0 {
+		t.Fatal("expected a match")
+	}
+	top := matches[0]
+	if top.Command != "kubectl -n api rollout undo deployment/api --to-revision=0" || top.Score < 8 {
+		t.Fatalf("expected the rollback command, got %+v", top)
+	}
+	if got := top.Citation(); got != "deploy.md:11 (Deploy > Roll back the API)" {
+		t.Fatalf("unexpected citation %q", got)
+	}
+
+	matches = Search(sections, "rollout status after a release", 0)
+	if len(matches) == 0 || matches[0].Command != "kubectl -n api rollout status deployment/api" {
+		t.Fatalf("expected the command sharing the query's words, got %+v", matches)
+	}
+
+	if matches := Search(sections, "rotate tls certificates", 0); len(matches) != 0 {
+		t.Fatalf("expected no match, got %+v", matches)
+	}
+}
+
+func TestLoadIndexesMarkdownAndReusesUnchangedFiles(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "ops"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "ops", "deploy.md")
+	if err := os.WriteFile(path, []byte(deployRunbook), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("```\nls\n```\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sections, err := Load(dir)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(sections) != 2 || sections[0].File != "ops/deploy.md" {
+		t.Fatalf("expected the two runbook sections, got %+v", sections)
+	}
+	saved := loadIndex(filepath.Clean(dir))
+	if _, ok := saved.Files["ops/deploy.md"]; !ok {
+		t.Fatalf("expected the file in the saved index, got %+v", saved)
+	}
+
+	// A stale entry with the file's size and time is trusted as parsed.
+	info, _ := os.Stat(path)
+	saved.Files["ops/deploy.md"] = indexedFile{ModTime: info.ModTime().UnixNano(), Size: info.Size(), Sections: []Section{{File: "ops/deploy.md", Heading: "Cached"}}}
+	if err := saveIndex(saved); err != nil {
+		t.Fatal(err)
+	}
+	sections, err = Load(dir)
+	if err != nil || len(sections) != 1 || sections[0].Heading != "Cached" {
+		t.Fatalf("expected the indexed sections to be reused, got %+v %v", sections, err)
+	}
+
+	if sections, err := Load(filepath.Join(dir, "missing")); err != nil || len(sections) != 0 {
+		t.Fatalf("expected a missing dir to be empty, got %+v %v", sections, err)
+	}
+}
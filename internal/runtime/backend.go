@@ -0,0 +1,109 @@
+package runtime
+
+import (
+	"os"
+	"os/exec"
+
+	"github.com/ashwch/ew/internal/target"
+)
+
+// Backend runs a shell command somewhere: on this machine, over ssh, or
+// inside a container.
+type Backend interface {
+	// Target is the canonical spec, e.g. "local" or "docker:web".
+	Target() string
+	// Remote reports whether the command leaves this machine's shell.
+	Remote() bool
+	Invocation(command string) (string, []string)
+}
+
+type localBackend struct{}
+
+func (localBackend) Target() string { return "local" }
+func (localBackend) Remote() bool   { return false }
+func (localBackend) Invocation(command string) (string, []string) {
+	return shellCommandInvocation(command)
+}
+
+type sshBackend struct {
+	spec target.Spec
+}
+
+func (b sshBackend) Target() string { return b.spec.String() }
+func (b sshBackend) Remote() bool   { return true }
+func (b sshBackend) Invocation(command string) (string, []string) {
+	// ssh joins its trailing args into one remote shell line, so the command
+	// is passed as a single argument.
+	args := []string{}
+	if isStdinInteractive() {
+		args = append(args, "-t")
+	}
+	return "ssh", append(args, b.spec.Host, "--", command)
+}
+
+type dockerBackend struct {
+	spec target.Spec
+}
+
+func (b dockerBackend) Target() string { return b.spec.String() }
+func (b dockerBackend) Remote() bool   { return true }
+func (b dockerBackend) Invocation(command string) (string, []string) {
+	args := []string{"exec", "-i"}
+	if isStdinInteractive() {
+		args = append(args, "-t")
+	}
+	return "docker", append(args, b.spec.Container, "sh", "-lc", command)
+}
+
+type kubectlBackend struct {
+	spec target.Spec
+}
+
+func (b kubectlBackend) Target() string { return b.spec.String() }
+func (b kubectlBackend) Remote() bool   { return true }
+func (b kubectlBackend) Invocation(command string) (string, []string) {
+	args := []string{"exec", "-i"}
+	if isStdinInteractive() {
+		args = append(args, "-t")
+	}
+	if b.spec.Namespace != "" {
+		args = append(args, "-n", b.spec.Namespace)
+	}
+	args = append(args, b.spec.Pod)
+	if b.spec.Container != "" {
+		args = append(args, "-c", b.spec.Container)
+	}
+	return "kubectl", append(args, "--", "sh", "-lc", command)
+}
+
+// ParseTarget resolves an execution target spec (see target.Parse) to the
+// backend that runs commands there.
+func ParseTarget(spec string) (Backend, error) {
+	parsed, err := target.Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+	switch parsed.Kind {
+	case target.SSH:
+		return sshBackend{spec: parsed}, nil
+	case target.Docker:
+		return dockerBackend{spec: parsed}, nil
+	case target.Kubectl:
+		return kubectlBackend{spec: parsed}, nil
+	default:
+		return localBackend{}, nil
+	}
+}
+
+// RunCommandOn executes command through backend with the caller's stdio.
+func RunCommandOn(backend Backend, command string) error {
+	if backend == nil {
+		backend = localBackend{}
+	}
+	name, args := backend.Invocation(command)
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
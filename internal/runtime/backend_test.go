@@ -0,0 +1,59 @@
+package runtime
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTargetBuildsInvocations(t *testing.T) {
+	cases := []struct {
+		spec       string
+		wantTarget string
+		wantName   string
+		wantTail   string
+	}{
+		{spec: "ssh:deploy@prod", wantTarget: "ssh:deploy@prod", wantName: "ssh", wantTail: "deploy@prod -- uptime"},
+		{spec: "docker:web", wantTarget: "docker:web", wantName: "docker", wantTail: "web sh -lc uptime"},
+		{spec: "kubectl:prod/api-0/app", wantTarget: "kubectl:prod/api-0/app", wantName: "kubectl", wantTail: "-n prod api-0 -c app -- sh -lc uptime"},
+		{spec: "KUBECTL:api-0", wantTarget: "kubectl:api-0", wantName: "kubectl", wantTail: "api-0 -- sh -lc uptime"},
+	}
+	for _, tc := range cases {
+		backend, err := ParseTarget(tc.spec)
+		if err != nil {
+			t.Fatalf("ParseTarget(%q) failed: %v", tc.spec, err)
+		}
+		if !backend.Remote() {
+			t.Fatalf("expected %q to be remote", tc.spec)
+		}
+		if backend.Target() != tc.wantTarget {
+			t.Fatalf("expected target %q, got %q", tc.wantTarget, backend.Target())
+		}
+		name, args := backend.Invocation("uptime")
+		if name != tc.wantName {
+			t.Fatalf("expected %q invocation, got %q", tc.wantName, name)
+		}
+		if joined := strings.Join(args, " "); !strings.HasSuffix(joined, tc.wantTail) {
+			t.Fatalf("expected args for %q to end with %q, got %q", tc.spec, tc.wantTail, joined)
+		}
+	}
+}
+
+func TestParseTargetDefaultsToLocal(t *testing.T) {
+	for _, spec := range []string{"", "local", " LOCAL "} {
+		backend, err := ParseTarget(spec)
+		if err != nil {
+			t.Fatalf("ParseTarget(%q) failed: %v", spec, err)
+		}
+		if backend.Remote() || backend.Target() != "local" {
+			t.Fatalf("expected local backend for %q, got %q", spec, backend.Target())
+		}
+	}
+}
+
+func TestParseTargetRejectsMalformedSpecs(t *testing.T) {
+	for _, spec := range []string{"ssh:", "ssh:-oProxyCommand=x", "docker:a b", "kubectl:a//b", "kubectl:a/b/c/d", "podman:web", "prod"} {
+		if _, err := ParseTarget(spec); err == nil {
+			t.Fatalf("expected %q to be rejected", spec)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package runtime
+
+import (
+	"context"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+)
+
+const gitProbeTimeout = 2 * time.Second
+
+// branchRewritingGitCommands move or overwrite branch history and deserve a
+// second look on shared branches.
+var branchRewritingGitCommands = map[string]bool{
+	"push":   true,
+	"reset":  true,
+	"rebase": true,
+}
+
+// gitOptionsWithValue are git global options that consume the next word.
+var gitOptionsWithValue = map[string]bool{
+	"-C":             true,
+	"-c":             true,
+	"--git-dir":      true,
+	"--work-tree":    true,
+	"--namespace":    true,
+	"--super-prefix": true,
+	"--config-env":   true,
+	"--attr-source":  true,
+}
+
+// RewritesGitBranch reports whether any segment of command runs git push,
+// reset or rebase.
+func RewritesGitBranch(command string) bool {
+	replacer := strings.NewReplacer("&&", "\n", "||", "\n", ";", "\n", "|", "\n")
+	for _, segment := range strings.Split(replacer.Replace(command), "\n") {
+		if sub := gitSubcommand(strings.Fields(segment)); branchRewritingGitCommands[sub] {
+			return true
+		}
+	}
+	return false
+}
+
+func gitSubcommand(fields []string) string {
+	idx := 0
+	for idx < len(fields) {
+		word := fields[idx]
+		if strings.Contains(word, "=") && !strings.HasPrefix(word, "-") {
+			idx++
+			continue
+		}
+		if word == "sudo" || word == "command" || word == "env" || word == "time" {
+			idx++
+			continue
+		}
+		break
+	}
+	if idx >= len(fields) || path.Base(fields[idx]) != "git" {
+		return ""
+	}
+	for idx++; idx < len(fields); idx++ {
+		word := fields[idx]
+		if !strings.HasPrefix(word, "-") {
+			return strings.ToLower(word)
+		}
+		if gitOptionsWithValue[word] {
+			idx++
+		}
+	}
+	return ""
+}
+
+// GitBranchContext returns the checked-out branch in dir and the repo's
+// default branch as advertised by origin/HEAD. Either is empty when git is
+// missing, dir is not a repository, HEAD is detached, or origin has no HEAD.
+func GitBranchContext(dir string) (string, string) {
+	branch := gitOutput(dir, "rev-parse", "--abbrev-ref", "HEAD")
+	if branch == "HEAD" {
+		branch = ""
+	}
+	defaultBranch := strings.TrimPrefix(gitOutput(dir, "symbolic-ref", "--quiet", "--short", "refs/remotes/origin/HEAD"), "origin/")
+	return branch, defaultBranch
+}
+
+func gitOutput(dir string, args ...string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), gitProbeTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// BranchProtected reports whether branch is the repo's default branch or
+// matches one of the glob patterns (e.g. "release/*").
+func BranchProtected(branch, defaultBranch string, patterns []string) bool {
+	branch = strings.TrimSpace(branch)
+	if branch == "" {
+		return false
+	}
+	if branch == strings.TrimSpace(defaultBranch) {
+		return true
+	}
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if ok, err := path.Match(pattern, branch); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package runtime
+
+import "testing"
+
+func TestRewritesGitBranchDetectsPushResetRebase(t *testing.T) {
+	cases := map[string]bool{
+		"git push origin main":                  true,
+		"git -C repo reset --hard HEAD~1":       true,
+		"git -c core.editor=true rebase -i @~3": true,
+		"GIT_TRACE=1 sudo git push --force":     true,
+		"git fetch && git rebase origin/main":   true,
+		"git status":                            false,
+		"git log --grep push":                   false,
+		"echo git push":                         false,
+		"gitk":                                  false,
+	}
+	for command, want := range cases {
+		if got := RewritesGitBranch(command); got != want {
+			t.Fatalf("RewritesGitBranch(%q) = %v, expected %v", command, got, want)
+		}
+	}
+}
+
+func TestBranchProtectedMatchesPatternsAndDefaultBranch(t *testing.T) {
+	patterns := []string{"main", "master", "release/*"}
+	if !BranchProtected("main", "", patterns) {
+		t.Fatalf("expected main to be protected")
+	}
+	if !BranchProtected("release/1.2", "", patterns) {
+		t.Fatalf("expected release/1.2 to match release/*")
+	}
+	if !BranchProtected("trunk", "trunk", patterns) {
+		t.Fatalf("expected the default branch to be protected")
+	}
+	if BranchProtected("feature/login", "main", patterns) {
+		t.Fatalf("expected feature branch to be unprotected")
+	}
+	if BranchProtected("", "", patterns) {
+		t.Fatalf("expected detached HEAD to be unprotected")
+	}
+}
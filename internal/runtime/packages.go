@@ -0,0 +1,244 @@
+package runtime
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	ecosystemNode   = "node"
+	ecosystemPython = "python"
+)
+
+// Dependency operations a package-manager command can perform.
+const (
+	depInstallAll = "install"
+	depAdd        = "add"
+	depRemove     = "remove"
+	depUpdate     = "update"
+)
+
+// lockfiles are checked in order, so a project with both pnpm-lock.yaml and
+// a stray package-lock.json is treated as pnpm.
+var lockfiles = []struct {
+	name      string
+	manager   string
+	ecosystem string
+}{
+	{"pnpm-lock.yaml", "pnpm", ecosystemNode},
+	{"yarn.lock", "yarn", ecosystemNode},
+	{"bun.lock", "bun", ecosystemNode},
+	{"bun.lockb", "bun", ecosystemNode},
+	{"package-lock.json", "npm", ecosystemNode},
+	{"uv.lock", "uv", ecosystemPython},
+	{"poetry.lock", "poetry", ecosystemPython},
+	{"Pipfile.lock", "pipenv", ecosystemPython},
+}
+
+// ProjectPackageManager is the manager a project's lockfile commits it to.
+type ProjectPackageManager struct {
+	Manager  string
+	Lockfile string
+}
+
+// PackageManagerCheck is the result of comparing a suggested dependency
+// command with the project's lockfile. Warning is empty when they agree;
+// Rewritten is set only when the command translates exactly.
+type PackageManagerCheck struct {
+	Rewritten string
+	Warning   string
+}
+
+// DetectPackageManager finds the nearest lockfile for ecosystem, walking up
+// from dir and stopping at the repository root.
+func DetectPackageManager(dir string, ecosystem string) (ProjectPackageManager, bool) {
+	current := filepath.Clean(dir)
+	for {
+		for _, lock := range lockfiles {
+			if lock.ecosystem != ecosystem {
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(current, lock.name)); err == nil {
+				return ProjectPackageManager{Manager: lock.manager, Lockfile: lock.name}, true
+			}
+		}
+		if _, err := os.Stat(filepath.Join(current, ".git")); err == nil {
+			return ProjectPackageManager{}, false
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return ProjectPackageManager{}, false
+		}
+		current = parent
+	}
+}
+
+type dependencyCommand struct {
+	manager   string
+	ecosystem string
+	op        string
+	packages  []string
+	dev       bool
+	// frozen installs exactly what the lockfile pins and fails rather
+	// than update it: npm ci, pnpm install --frozen-lockfile.
+	frozen bool
+	// exact is false when flags were present that have no portable
+	// translation, so the command is only warned about.
+	exact bool
+}
+
+// CheckPackageManager compares a dependency-changing npm/yarn/pnpm/bun or
+// pip/uv/poetry/pipenv command with the lockfile of the project in dir.
+func CheckPackageManager(command string, dir string) PackageManagerCheck {
+	trimmed := strings.TrimSpace(command)
+	if trimmed == "" || strings.ContainsAny(trimmed, ";|&`<>\n") || strings.Contains(trimmed, "$(") {
+		return PackageManagerCheck{}
+	}
+	dep, ok := parseDependencyCommand(strings.Fields(trimmed))
+	if !ok {
+		return PackageManagerCheck{}
+	}
+	project, ok := DetectPackageManager(dir, dep.ecosystem)
+	if !ok || project.Manager == dep.manager {
+		return PackageManagerCheck{}
+	}
+	effect := "would create a second lockfile"
+	if dep.manager == "pip" {
+		effect = "would bypass " + project.Lockfile
+	}
+	check := PackageManagerCheck{
+		Warning: fmt.Sprintf("this project uses %s (%s); %s %s", project.Manager, project.Lockfile, dep.manager, effect),
+	}
+	if dep.exact {
+		check.Rewritten = renderDependencyCommand(project.Manager, dep)
+	}
+	return check
+}
+
+func parseDependencyCommand(fields []string) (dependencyCommand, bool) {
+	if len(fields) == 0 {
+		return dependencyCommand{}, false
+	}
+	manager := path.Base(fields[0])
+	rest := fields[1:]
+	switch {
+	case manager == "pip3":
+		manager = "pip"
+	case strings.HasPrefix(manager, "python") && len(rest) >= 2 && rest[0] == "-m" && rest[1] == "pip":
+		manager = "pip"
+		rest = rest[2:]
+	case manager == "uv" && len(rest) > 0 && rest[0] == "pip":
+		// uv pip skips uv.lock just like plain pip.
+		manager = "pip"
+		rest = rest[1:]
+	}
+
+	ecosystem := ecosystemNode
+	var ops map[string]string
+	switch manager {
+	case "npm":
+		ops = map[string]string{"install": depAdd, "i": depAdd, "add": depAdd, "ci": depInstallAll, "uninstall": depRemove, "remove": depRemove, "rm": depRemove, "un": depRemove, "update": depUpdate, "up": depUpdate}
+	case "yarn":
+		ops = map[string]string{"add": depAdd, "install": depInstallAll, "remove": depRemove, "upgrade": depUpdate, "up": depUpdate}
+	case "pnpm":
+		ops = map[string]string{"add": depAdd, "install": depAdd, "i": depAdd, "remove": depRemove, "rm": depRemove, "uninstall": depRemove, "update": depUpdate, "up": depUpdate}
+	case "bun":
+		ops = map[string]string{"add": depAdd, "install": depAdd, "i": depAdd, "remove": depRemove, "rm": depRemove, "update": depUpdate}
+	case "pip":
+		ecosystem = ecosystemPython
+		ops = map[string]string{"install": depAdd, "uninstall": depRemove}
+	case "uv":
+		ecosystem = ecosystemPython
+		ops = map[string]string{"add": depAdd, "remove": depRemove, "sync": depInstallAll}
+	case "poetry":
+		ecosystem = ecosystemPython
+		ops = map[string]string{"add": depAdd, "remove": depRemove, "install": depInstallAll, "update": depUpdate}
+	case "pipenv":
+		ecosystem = ecosystemPython
+		ops = map[string]string{"install": depAdd, "uninstall": depRemove, "sync": depInstallAll, "update": depUpdate}
+	default:
+		return dependencyCommand{}, false
+	}
+
+	dep := dependencyCommand{manager: manager, ecosystem: ecosystem, exact: true}
+	if len(rest) == 0 {
+		// Bare `yarn` installs; the rest print help.
+		if manager != "yarn" {
+			return dependencyCommand{}, false
+		}
+		dep.op = depInstallAll
+		return dep, true
+	}
+	op, ok := ops[rest[0]]
+	if !ok {
+		return dependencyCommand{}, false
+	}
+	dep.op = op
+	dep.frozen = manager == "npm" && rest[0] == "ci"
+	for _, arg := range rest[1:] {
+		switch {
+		case arg == "-g" || arg == "--global":
+			// A global install writes no project lockfile.
+			return dependencyCommand{}, false
+		case arg == "-D" || arg == "-d" || arg == "--save-dev" || arg == "--dev" || arg == "--group=dev":
+			dep.dev = true
+		case arg == "--frozen-lockfile" || arg == "--immutable" || arg == "--locked" || arg == "--frozen":
+			dep.frozen = true
+		case arg == "-y" || arg == "--yes":
+		case strings.HasPrefix(arg, "-"):
+			dep.exact = false
+		default:
+			dep.packages = append(dep.packages, arg)
+		}
+	}
+	// `npm install` with no packages installs the lockfile, not a new dep.
+	if dep.op == depAdd && len(dep.packages) == 0 {
+		dep.op = depInstallAll
+	}
+	if dep.op == depRemove && len(dep.packages) == 0 {
+		return dependencyCommand{}, false
+	}
+	return dep, true
+}
+
+func renderDependencyCommand(manager string, dep dependencyCommand) string {
+	verbs := map[string]map[string]string{
+		"npm":    {depInstallAll: "install", depAdd: "install", depRemove: "uninstall", depUpdate: "update"},
+		"yarn":   {depInstallAll: "install", depAdd: "add", depRemove: "remove", depUpdate: "upgrade"},
+		"pnpm":   {depInstallAll: "install", depAdd: "add", depRemove: "remove", depUpdate: "update"},
+		"bun":    {depInstallAll: "install", depAdd: "add", depRemove: "remove", depUpdate: "update"},
+		"uv":     {depInstallAll: "sync", depAdd: "add", depRemove: "remove", depUpdate: "lock --upgrade"},
+		"poetry": {depInstallAll: "install", depAdd: "add", depRemove: "remove", depUpdate: "update"},
+		"pipenv": {depInstallAll: "sync", depAdd: "install", depRemove: "uninstall", depUpdate: "update"},
+	}
+	devFlags := map[string]string{
+		"npm": "--save-dev", "yarn": "--dev", "pnpm": "--save-dev", "bun": "--dev",
+		"uv": "--dev", "poetry": "--group dev", "pipenv": "--dev",
+	}
+	// Each manager's way to install exactly the lockfile.
+	frozenInstalls := map[string]string{
+		"npm": "npm ci", "yarn": "yarn install --frozen-lockfile", "pnpm": "pnpm install --frozen-lockfile",
+		"bun": "bun install --frozen-lockfile", "uv": "uv sync --locked", "poetry": "poetry install", "pipenv": "pipenv sync",
+	}
+	if dep.frozen && dep.op == depInstallAll {
+		return frozenInstalls[manager]
+	}
+	verb, ok := verbs[manager][dep.op]
+	if !ok {
+		return ""
+	}
+	// uv lock --upgrade takes --upgrade-package per name; keep it simple and
+	// only translate whole-project upgrades.
+	if manager == "uv" && dep.op == depUpdate && len(dep.packages) > 0 {
+		return ""
+	}
+	parts := []string{manager, verb}
+	if dep.dev && dep.op == depAdd {
+		parts = append(parts, devFlags[manager])
+	}
+	parts = append(parts, dep.packages...)
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,73 @@
+package runtime
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func projectWithLockfile(t *testing.T, lockfile string) string {
+	t.Helper()
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, ".git"), 0o755); err != nil {
+		t.Fatalf("mkdir .git: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, lockfile), []byte{}, 0o644); err != nil {
+		t.Fatalf("write lockfile: %v", err)
+	}
+	nested := filepath.Join(root, "packages", "web")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatalf("mkdir nested: %v", err)
+	}
+	return nested
+}
+
+func TestCheckPackageManagerRewritesToLockfileManager(t *testing.T) {
+	cases := []struct {
+		lockfile string
+		command  string
+		want     string
+	}{
+		{"pnpm-lock.yaml", "npm install -D typescript", "pnpm add --save-dev typescript"},
+		{"yarn.lock", "npm uninstall lodash", "yarn remove lodash"},
+		{"package-lock.json", "yarn add react react-dom", "npm install react react-dom"},
+		{"pnpm-lock.yaml", "npm ci", "pnpm install --frozen-lockfile"},
+		{"yarn.lock", "npm ci", "yarn install --frozen-lockfile"},
+		{"package-lock.json", "pnpm install --frozen-lockfile", "npm ci"},
+		{"pnpm-lock.yaml", "npm install", "pnpm install"},
+		{"uv.lock", "pip install requests", "uv add requests"},
+		{"uv.lock", "python3 -m pip install httpx", "uv add httpx"},
+		{"poetry.lock", "uv add --dev pytest", "poetry add --group dev pytest"},
+	}
+	for _, tc := range cases {
+		dir := projectWithLockfile(t, tc.lockfile)
+		check := CheckPackageManager(tc.command, dir)
+		if check.Rewritten != tc.want {
+			t.Fatalf("CheckPackageManager(%q) with %s rewrote to %q, expected %q", tc.command, tc.lockfile, check.Rewritten, tc.want)
+		}
+		if !strings.Contains(check.Warning, tc.lockfile) {
+			t.Fatalf("expected warning to name %s, got %q", tc.lockfile, check.Warning)
+		}
+	}
+}
+
+func TestCheckPackageManagerWarnsWithoutExactTranslation(t *testing.T) {
+	dir := projectWithLockfile(t, "uv.lock")
+	check := CheckPackageManager("pip install -r requirements.txt", dir)
+	if check.Rewritten != "" {
+		t.Fatalf("expected no rewrite for -r, got %q", check.Rewritten)
+	}
+	if check.Warning != "this project uses uv (uv.lock); pip would bypass uv.lock" {
+		t.Fatalf("unexpected warning %q", check.Warning)
+	}
+}
+
+func TestCheckPackageManagerLeavesMatchingAndUnrelatedCommands(t *testing.T) {
+	dir := projectWithLockfile(t, "pnpm-lock.yaml")
+	for _, command := range []string{"pnpm add zod", "npm run build", "npm test", "pip install requests", "npm install x && npm test", "npm install -g typescript", "yarn global add serve", "bun add --global vercel"} {
+		if check := CheckPackageManager(command, dir); check != (PackageManagerCheck{}) {
+			t.Fatalf("expected no check result for %q, got %+v", command, check)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package runtime
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	PlanToolTerraform = "terraform"
+	PlanToolKubectl   = "kubectl"
+)
+
+// PlanSummary condenses the output of a read-only plan or diff so a person
+// can see what an apply would do before confirming it.
+type PlanSummary struct {
+	Tool      string   `json:"tool"`
+	Command   string   `json:"command"`
+	Add       int      `json:"add"`
+	Change    int      `json:"change"`
+	Destroy   int      `json:"destroy"`
+	Destroys  []string `json:"destroys,omitempty"`
+	NoChanges bool     `json:"no_changes,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// planPreviewUnsafe are the shell constructs that would let the rewritten
+// preview run or write more than the plan: chains, command and process
+// substitution, and redirection.
+var planPreviewUnsafe = []string{";", "&", "|", "\n", "\r", "`", "$(", "<", ">"}
+
+// PlanPreview returns the read-only command that previews an apply:
+// terraform/tofu apply becomes plan (or show for a saved plan file) and
+// kubectl apply becomes kubectl diff. ok is false for anything else, or when
+// the command chains several steps, substitutes a command, or redirects,
+// since the preview runs through a shell on its own.
+func PlanPreview(command string) (string, string, bool) {
+	trimmed := strings.TrimSpace(command)
+	if trimmed == "" {
+		return "", "", false
+	}
+	for _, unsafe := range planPreviewUnsafe {
+		if strings.Contains(trimmed, unsafe) {
+			return "", "", false
+		}
+	}
+	fields := strings.Fields(trimmed)
+	binary := path.Base(fields[0])
+	switch binary {
+	case "terraform", "tofu":
+		return terraformPreview(fields)
+	case "kubectl":
+		return kubectlPreview(fields)
+	}
+	return "", "", false
+}
+
+// terraformValueFlags take their value as the next word when not written as
+// -flag=value.
+var terraformValueFlags = map[string]bool{
+	"-var": true, "-var-file": true, "-target": true, "-replace": true,
+	"-parallelism": true, "-state": true, "-state-out": true, "-backup": true,
+	"-lock-timeout": true,
+}
+
+func terraformPreview(fields []string) (string, string, bool) {
+	idx := indexOfWord(fields, "apply")
+	if idx < 0 {
+		return "", "", false
+	}
+	args := []string{}
+	planFile := ""
+	rest := fields[idx+1:]
+	for i := 0; i < len(rest); i++ {
+		arg := rest[i]
+		switch {
+		case arg == "-auto-approve" || arg == "--auto-approve":
+		case arg == "-no-color" || strings.HasPrefix(arg, "-input"):
+		case terraformValueFlags[arg] && i+1 < len(rest):
+			args = append(args, arg, rest[i+1])
+			i++
+		case !strings.HasPrefix(arg, "-"):
+			planFile = arg
+		default:
+			args = append(args, arg)
+		}
+	}
+	prefix := strings.Join(fields[:idx], " ")
+	if planFile != "" {
+		return prefix + " show -no-color " + planFile, PlanToolTerraform, true
+	}
+	preview := prefix + " plan -no-color -input=false"
+	if len(args) > 0 {
+		preview += " " + strings.Join(args, " ")
+	}
+	return preview, PlanToolTerraform, true
+}
+
+func kubectlPreview(fields []string) (string, string, bool) {
+	idx := indexOfWord(fields, "apply")
+	if idx < 0 {
+		return "", "", false
+	}
+	// apply-only flags that kubectl diff rejects.
+	skip := map[string]bool{"--record": true, "--wait": true, "--overwrite": true, "--timeout": true}
+	args := []string{}
+	for _, arg := range fields[idx+1:] {
+		name := arg
+		if eq := strings.Index(arg, "="); eq > 0 {
+			name = arg[:eq]
+		}
+		if skip[name] || strings.HasPrefix(name, "--dry-run") {
+			continue
+		}
+		args = append(args, arg)
+	}
+	preview := strings.Join(fields[:idx], " ") + " diff"
+	if len(args) > 0 {
+		preview += " " + strings.Join(args, " ")
+	}
+	return preview, PlanToolKubectl, true
+}
+
+func indexOfWord(fields []string, word string) int {
+	for idx := 1; idx < len(fields); idx++ {
+		if fields[idx] == word {
+			return idx
+		}
+	}
+	return -1
+}
+
+// CaptureCommandOn runs command through backend and returns its combined
+// output. Exit codes listed in okExitCodes are not errors; kubectl diff, for
+// one, exits 1 when it found differences. The command is killed when ctx is
+// cancelled or timeout passes.
+func CaptureCommandOn(ctx context.Context, backend Backend, command string, timeout time.Duration, okExitCodes ...int) (string, error) {
+	if backend == nil {
+		backend = localBackend{}
+	}
+	parent := ctx
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	name, args := backend.Invocation(command)
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+	if parent.Err() != nil {
+		return out.String(), parent.Err()
+	}
+	if ctx.Err() != nil {
+		return out.String(), fmt.Errorf("timed out after %s", timeout)
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		for _, code := range okExitCodes {
+			if exitErr.ExitCode() == code {
+				return out.String(), nil
+			}
+		}
+	}
+	return out.String(), err
+}
+
+var (
+	terraformPlanTotals = regexp.MustCompile(`(\d+) to add, (\d+) to change, (\d+) to destroy`)
+	terraformDestroyed  = regexp.MustCompile(`^\s*# (\S+) (will be destroyed|must be replaced)`)
+)
+
+// SummarizePlan parses terraform plan/show or kubectl diff output.
+func SummarizePlan(tool string, preview string, output string) PlanSummary {
+	summary := PlanSummary{Tool: tool, Command: preview}
+	if tool == PlanToolKubectl {
+		summarizeKubectlDiff(&summary, output)
+	} else {
+		summarizeTerraformPlan(&summary, output)
+	}
+	return summary
+}
+
+func summarizeTerraformPlan(summary *PlanSummary, output string) {
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if match := terraformDestroyed.FindStringSubmatch(line); match != nil {
+			name := match[1]
+			if match[2] == "must be replaced" {
+				name += " (replace)"
+			}
+			summary.Destroys = append(summary.Destroys, name)
+			continue
+		}
+		if match := terraformPlanTotals.FindStringSubmatch(line); match != nil {
+			summary.Add, _ = strconv.Atoi(match[1])
+			summary.Change, _ = strconv.Atoi(match[2])
+			summary.Destroy, _ = strconv.Atoi(match[3])
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "No changes.") {
+			summary.NoChanges = true
+		}
+	}
+}
+
+// summarizeKubectlDiff counts one object per "diff -u -N" section. An empty
+// old side means the object is created, an empty new side that it is pruned.
+func summarizeKubectlDiff(summary *PlanSummary, output string) {
+	object := ""
+	classified := true
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "diff ") {
+			if !classified {
+				summary.Change++
+			}
+			fields := strings.Fields(line)
+			object = path.Base(fields[len(fields)-1])
+			classified = false
+			continue
+		}
+		if classified || !strings.HasPrefix(line, "@@ ") {
+			continue
+		}
+		classified = true
+		switch {
+		case strings.HasPrefix(line, "@@ -0,0 "):
+			summary.Add++
+		case strings.Contains(line, " +0,0 @@"):
+			summary.Destroy++
+			summary.Destroys = append(summary.Destroys, object)
+		default:
+			summary.Change++
+		}
+	}
+	if !classified {
+		summary.Change++
+	}
+	summary.NoChanges = strings.TrimSpace(output) == ""
+}
+
+// Lines renders the summary for confirmation prompts: totals, then one
+// line per destroyed or replaced resource.
+func (s PlanSummary) Lines() []string {
+	if s.Error != "" {
+		return []string{fmt.Sprintf("plan: %s failed: %s", s.Command, s.Error)}
+	}
+	if s.NoChanges {
+		return []string{"plan: no changes"}
+	}
+	lines := []string{fmt.Sprintf("plan: %d to add, %d to change, %d to destroy", s.Add, s.Change, s.Destroy)}
+	for _, name := range s.Destroys {
+		lines = append(lines, "  DESTROY "+name)
+	}
+	return lines
+}
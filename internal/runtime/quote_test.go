@@ -0,0 +1,23 @@
+package runtime
+
+import "testing"
+
+func TestShellQuoteAndFishQuote(t *testing.T) {
+	for _, tc := range []struct {
+		value, sh, fish string
+	}{
+		{"deploy/api", "deploy/api", "deploy/api"},
+		{"", "''", "''"},
+		{"my app", "'my app'", "'my app'"},
+		{"it's", `'it'\''s'`, `'it\'s'`},
+		{`a\b`, `'a\b'`, `'a\\b'`},
+		{"$HOME", "'$HOME'", "'$HOME'"},
+	} {
+		if got := ShellQuote(tc.value); got != tc.sh {
+			t.Fatalf("ShellQuote(%q) = %q, want %q", tc.value, got, tc.sh)
+		}
+		if got := FishQuote(tc.value); got != tc.fish {
+			t.Fatalf("FishQuote(%q) = %q, want %q", tc.value, got, tc.fish)
+		}
+	}
+}
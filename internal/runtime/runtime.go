@@ -8,10 +8,27 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync/atomic"
 )
 
 var stdinIsInteractive = isStdinInteractive
 
+// sessionShell is the shell set by UseShell.
+var sessionShell atomic.Value
+
+// lcShells take -l and -c the way shellCommandInvocation passes them.
+var lcShells = map[string]bool{
+	"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true, "fish": true,
+}
+
+// UseShell makes local commands run in shell, a name like "fish" or a
+// path, rather than $SHELL, so a command whose syntax was adapted for the
+// shell the user is typing in also runs there. A shell that does not take
+// -lc, or that cannot be found, leaves $SHELL in charge.
+func UseShell(shell string) {
+	sessionShell.Store(strings.TrimSpace(shell))
+}
+
 func ShouldExecute(mode string, yes bool) (bool, error) {
 	switch strings.ToLower(strings.TrimSpace(mode)) {
 	case "suggest":
@@ -39,12 +56,7 @@ func ShouldExecute(mode string, yes bool) (bool, error) {
 }
 
 func RunCommand(command string) error {
-	shell, args := shellCommandInvocation(command)
-	cmd := exec.Command(shell, args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Stdin = os.Stdin
-	return cmd.Run()
+	return RunCommandOn(localBackend{}, command)
 }
 
 func shellCommandInvocation(command string) (string, []string) {
@@ -56,6 +68,11 @@ func shellCommandInvocation(command string) (string, []string) {
 		return comspec, []string{"/C", command}
 	}
 
+	if preferred, _ := sessionShell.Load().(string); preferred != "" && lcShells[filepath.Base(preferred)] {
+		if resolved, err := exec.LookPath(preferred); err == nil {
+			return resolved, []string{"-lc", command}
+		}
+	}
 	shell := strings.TrimSpace(os.Getenv("SHELL"))
 	if shell != "" {
 		if filepath.IsAbs(shell) {
@@ -111,6 +128,13 @@ func isStdinInteractive() bool {
 }
 
 func HighRisk(command string) bool {
+	_, ok := HighRiskPattern(command)
+	return ok
+}
+
+// HighRiskPattern returns the high-risk pattern command contains, such as
+// "rm -rf", for saying why it was judged high risk.
+func HighRiskPattern(command string) (string, bool) {
 	low := strings.ToLower(strings.TrimSpace(command))
 	highRiskPatterns := []string{
 		"rm -rf",
@@ -123,10 +147,128 @@ func HighRisk(command string) bool {
 	}
 	for _, pattern := range highRiskPatterns {
 		if strings.Contains(low, pattern) {
-			return true
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+type CommandShape struct {
+	Length int
+	Args   int
+	Paths  int
+}
+
+// MeasureCommand reports the size of a command line: byte length, argument
+// count, and how many distinct filesystem paths it references.
+func MeasureCommand(command string) CommandShape {
+	trimmed := strings.TrimSpace(command)
+	fields := strings.Fields(trimmed)
+	paths := map[string]struct{}{}
+	for _, field := range fields {
+		token := strings.Trim(field, `"'();|&<>`)
+		if idx := strings.IndexRune(token, '='); idx >= 0 {
+			token = token[idx+1:]
+		}
+		if looksLikePath(token) {
+			paths[token] = struct{}{}
+		}
+	}
+	return CommandShape{
+		Length: len(trimmed),
+		Args:   len(fields),
+		Paths:  len(paths),
+	}
+}
+
+func looksLikePath(token string) bool {
+	if token == "" || strings.HasPrefix(token, "-") {
+		return false
+	}
+	if strings.Contains(token, "://") {
+		return false
+	}
+	if strings.HasPrefix(token, "~") || strings.HasPrefix(token, "./") || strings.HasPrefix(token, "../") {
+		return true
+	}
+	return strings.Contains(token, "/")
+}
+
+// SaferDelete rewrites a plain `rm` invocation into a recoverable variant.
+// It prefers a trash tool from the given list (trash, trash-put, gio) and
+// otherwise falls back to `rm -i` when allowInteractive is set. The boolean
+// result reports whether a rewrite was produced.
+func SaferDelete(command string, tools []string, allowInteractive bool) (string, bool) {
+	trimmed := strings.TrimSpace(command)
+	if trimmed == "" || strings.ContainsAny(trimmed, ";|&`<>\n") || strings.Contains(trimmed, "$(") {
+		return "", false
+	}
+	fields := strings.Fields(trimmed)
+	if len(fields) < 2 || fields[0] != "rm" {
+		return "", false
+	}
+
+	shortFlags := ""
+	longFlags := make([]string, 0, 2)
+	operands := make([]string, 0, len(fields)-1)
+	endOfFlags := false
+	for _, field := range fields[1:] {
+		switch {
+		case endOfFlags:
+			operands = append(operands, field)
+		case field == "--":
+			endOfFlags = true
+		case strings.HasPrefix(field, "--"):
+			longFlags = append(longFlags, field)
+		case strings.HasPrefix(field, "-") && len(field) > 1:
+			shortFlags += field[1:]
+		default:
+			operands = append(operands, field)
+		}
+	}
+	if len(operands) == 0 {
+		return "", false
+	}
+
+	// Operands after -- may look like flags (rm -- -foo); the trash tools
+	// take -- too, so it is kept for them as well as for rm -i.
+	if endOfFlags {
+		operands = append([]string{"--"}, operands...)
+	}
+	available := map[string]struct{}{}
+	for _, tool := range tools {
+		available[strings.ToLower(strings.TrimSpace(tool))] = struct{}{}
+	}
+	for _, candidate := range []struct {
+		tool   string
+		prefix string
+	}{
+		{"trash", "trash"},
+		{"trash-put", "trash-put"},
+		{"gio", "gio trash"},
+	} {
+		if _, ok := available[candidate.tool]; ok {
+			return candidate.prefix + " " + strings.Join(operands, " "), true
+		}
+	}
+
+	if !allowInteractive {
+		return "", false
+	}
+	if strings.Contains(shortFlags, "i") && !strings.Contains(shortFlags, "f") {
+		return "", false
+	}
+	flags := strings.ReplaceAll(shortFlags, "f", "")
+	flags = strings.ReplaceAll(flags, "i", "") + "i"
+	parts := []string{"rm", "-" + flags}
+	for _, long := range longFlags {
+		if long == "--force" || long == "--interactive" || strings.HasPrefix(long, "--interactive=") {
+			continue
 		}
+		parts = append(parts, long)
 	}
-	return false
+	parts = append(parts, operands...)
+	return strings.Join(parts, " "), true
 }
 
 func SuggestFix(command string) (string, string) {
@@ -84,3 +84,79 @@ func TestShellCommandInvocationFallsBackWhenShellEnvInvalid(t *testing.T) {
 		t.Fatalf("expected fallback shell sh, got %q", shell)
 	}
 }
+
+func TestShellCommandInvocationPrefersTheSessionShell(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell selection test is unix-specific")
+	}
+	t.Cleanup(func() { UseShell("") })
+
+	t.Setenv("SHELL", "/bin/sh")
+	UseShell("sh")
+	shell, args := shellCommandInvocation("echo hi")
+	if filepath.Base(shell) != "sh" || len(args) != 2 || args[0] != "-lc" {
+		t.Fatalf("expected the session shell with -lc, got %q %#v", shell, args)
+	}
+
+	UseShell("pwsh")
+	if shell, _ := shellCommandInvocation("echo hi"); shell != "/bin/sh" {
+		t.Fatalf("expected $SHELL for a shell that does not take -lc, got %q", shell)
+	}
+	UseShell(filepath.Join(t.TempDir(), "zsh"))
+	if shell, _ := shellCommandInvocation("echo hi"); shell != "/bin/sh" {
+		t.Fatalf("expected $SHELL when the session shell is missing, got %q", shell)
+	}
+}
+
+func TestMeasureCommandCountsArgsAndDistinctPaths(t *testing.T) {
+	shape := MeasureCommand("cp ./a.txt /tmp/b.txt --target=~/backup ./a.txt https://example.com/x")
+	if shape.Args != 6 {
+		t.Fatalf("expected 6 args, got %d", shape.Args)
+	}
+	if shape.Paths != 3 {
+		t.Fatalf("expected 3 distinct paths, got %d", shape.Paths)
+	}
+	if shape.Length != len("cp ./a.txt /tmp/b.txt --target=~/backup ./a.txt https://example.com/x") {
+		t.Fatalf("unexpected length %d", shape.Length)
+	}
+}
+
+func TestSaferDeletePrefersTrashTool(t *testing.T) {
+	got, ok := SaferDelete("rm -rf build dist", []string{"git", "trash"}, true)
+	if !ok || got != "trash build dist" {
+		t.Fatalf("expected trash rewrite, got %q (ok=%v)", got, ok)
+	}
+	got, ok = SaferDelete("rm -r build", []string{"gio"}, true)
+	if !ok || got != "gio trash build" {
+		t.Fatalf("expected gio trash rewrite, got %q (ok=%v)", got, ok)
+	}
+	got, ok = SaferDelete("rm -- -foo", []string{"trash"}, true)
+	if !ok || got != "trash -- -foo" {
+		t.Fatalf("expected -- kept before a dash operand, got %q (ok=%v)", got, ok)
+	}
+	got, ok = SaferDelete("rm -f -- -foo", nil, true)
+	if !ok || got != "rm -i -- -foo" {
+		t.Fatalf("expected -- kept for rm -i, got %q (ok=%v)", got, ok)
+	}
+}
+
+func TestSaferDeleteFallsBackToInteractiveRm(t *testing.T) {
+	got, ok := SaferDelete("rm -rf --verbose build", nil, true)
+	if !ok || got != "rm -ri --verbose build" {
+		t.Fatalf("expected interactive rewrite, got %q (ok=%v)", got, ok)
+	}
+	if _, ok := SaferDelete("rm -rf build", nil, false); ok {
+		t.Fatalf("expected no rewrite without trash tool when interactive fallback is disabled")
+	}
+	if _, ok := SaferDelete("rm -i build", nil, true); ok {
+		t.Fatalf("expected already-interactive rm to be left alone")
+	}
+}
+
+func TestSaferDeleteIgnoresNonRmAndCompoundCommands(t *testing.T) {
+	for _, command := range []string{"git rm file", "rm -rf build && make", "rm", "ls | rm"} {
+		if got, ok := SaferDelete(command, []string{"trash"}, true); ok {
+			t.Fatalf("expected no rewrite for %q, got %q", command, got)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package runtime
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	shellAssignment = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)=(.*)$`)
+	shellName       = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// AdaptForShell rewrites POSIX/bash syntax into the given interactive shell's
+// dialect. Only fish needs a translation today; ok is false when the command
+// was left as is, either because nothing needed changing or because it uses
+// a construct with no safe translation (heredocs, [[ ]], functions, ${x:-y}).
+func AdaptForShell(command string, shell string) (string, bool) {
+	switch strings.ToLower(filepath.Base(strings.TrimSpace(shell))) {
+	case "fish":
+		adapted, ok := adaptForFish(command)
+		if !ok || adapted == command {
+			return command, false
+		}
+		return adapted, true
+	}
+	return command, false
+}
+
+type shellStatement struct {
+	text string
+	sep  string
+}
+
+func adaptForFish(command string) (string, bool) {
+	if strings.Contains(command, "<<") || strings.Contains(command, "[[") || strings.Contains(command, "() {") || strings.Contains(command, "$((") {
+		return "", false
+	}
+	expanded, ok := adaptFishExpansions(command)
+	if !ok {
+		return "", false
+	}
+
+	changed := expanded != command
+	var b strings.Builder
+	for _, stmt := range splitShellStatements(expanded) {
+		text, keep, ok := adaptFishStatement(stmt.text)
+		if !ok {
+			return "", false
+		}
+		changed = changed || !keep || text != stmt.text
+		if !keep {
+			// A bare `do`/`then` disappears along with its separator.
+			continue
+		}
+		b.WriteString(text)
+		b.WriteString(stmt.sep)
+	}
+	if !changed {
+		// Re-joining normalizes spacing; keep the original when nothing
+		// else differs.
+		return command, true
+	}
+	return strings.TrimSpace(b.String()), true
+}
+
+// adaptFishStatement translates one simple statement. keep is false when the
+// statement is only a bash keyword fish does not need; ok is false when it
+// cannot be translated.
+func adaptFishStatement(text string) (string, bool, bool) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return text, true, true
+	}
+	switch fields[0] {
+	case "case", "function", "select":
+		return "", false, false
+	case "do", "then":
+		rest := strings.TrimSpace(strings.TrimPrefix(text, fields[0]))
+		return rest, rest != "", true
+	case "done", "fi":
+		if len(fields) == 1 {
+			return "end", true, true
+		}
+	case "elif":
+		return "else if " + strings.TrimSpace(strings.TrimPrefix(text, "elif")), true, true
+	case "export":
+		adapted, ok := fishSetStatements("set -x", fields[1:], true)
+		return adapted, true, ok
+	case "unset":
+		if len(fields) > 1 && !strings.HasPrefix(fields[1], "-") {
+			return "set -e " + strings.Join(fields[1:], " "), true, true
+		}
+	}
+	// A statement made only of NAME=value words is a shell variable
+	// assignment; with a command after them fish 3.1+ accepts them as is.
+	for _, field := range fields {
+		if !shellAssignment.MatchString(field) {
+			return text, true, true
+		}
+	}
+	adapted, ok := fishSetStatements("set", fields, false)
+	return adapted, true, ok
+}
+
+func fishSetStatements(prefix string, words []string, allowBare bool) (string, bool) {
+	if len(words) == 0 {
+		return "", false
+	}
+	parts := make([]string, 0, len(words))
+	for _, word := range words {
+		if match := shellAssignment.FindStringSubmatch(word); match != nil {
+			value := match[2]
+			if value == "" {
+				value = "''"
+			}
+			parts = append(parts, prefix+" "+match[1]+" "+value)
+			continue
+		}
+		if allowBare && shellName.MatchString(word) {
+			parts = append(parts, prefix+" "+word+" $"+word)
+			continue
+		}
+		// Flags, or quoted values with spaces that Fields split apart.
+		return "", false
+	}
+	return strings.Join(parts, "; "), true
+}
+
+// adaptFishExpansions rewrites $?, ${NAME} and backticks outside single
+// quotes. It reports false for parameter expansions fish cannot express.
+func adaptFishExpansions(command string) (string, bool) {
+	var b strings.Builder
+	inSingle, inDouble, inBacktick := false, false, false
+	runes := []rune(command)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\' && !inSingle && i+1 < len(runes):
+			b.WriteRune(r)
+			b.WriteRune(runes[i+1])
+			i++
+			continue
+		case r == '\'' && !inDouble:
+			inSingle = !inSingle
+		case r == '"' && !inSingle:
+			inDouble = !inDouble
+		case inSingle:
+		case r == '`':
+			if inBacktick {
+				b.WriteRune(')')
+			} else {
+				b.WriteString("$(")
+			}
+			inBacktick = !inBacktick
+			continue
+		case r == '$' && i+1 < len(runes) && runes[i+1] == '?':
+			b.WriteString("$status")
+			i++
+			continue
+		case r == '$' && i+1 < len(runes) && runes[i+1] == '{':
+			end := strings.IndexRune(string(runes[i+2:]), '}')
+			if end < 0 {
+				return "", false
+			}
+			name := string(runes[i+2 : i+2+end])
+			if !shellName.MatchString(name) {
+				return "", false
+			}
+			i += 2 + end
+			next := rune(0)
+			if i+1 < len(runes) {
+				next = runes[i+1]
+			}
+			switch {
+			case !inDouble:
+				b.WriteString("{$" + name + "}")
+			case next == '_' || next >= 'a' && next <= 'z' || next >= 'A' && next <= 'Z' || next >= '0' && next <= '9':
+				// Close and reopen the quotes so the name does not run on.
+				b.WriteString("$" + name + `""`)
+			default:
+				b.WriteString("$" + name)
+			}
+			continue
+		}
+		b.WriteRune(r)
+	}
+	if inSingle || inDouble || inBacktick {
+		return "", false
+	}
+	return b.String(), true
+}
+
+// Statements returns the statements of command, split on ; && || | and
+// newlines outside quotes and parentheses.
+func Statements(command string) []string {
+	var out []string
+	for _, stmt := range splitShellStatements(command) {
+		if stmt.text != "" {
+			out = append(out, stmt.text)
+		}
+	}
+	return out
+}
+
+// splitShellStatements splits on ; && || | and newlines outside quotes and
+// parentheses, keeping each separator with the statement before it.
+func splitShellStatements(command string) []shellStatement {
+	out := []shellStatement{}
+	var current strings.Builder
+	flush := func(sep string) {
+		out = append(out, shellStatement{text: strings.TrimSpace(current.String()), sep: sep})
+		current.Reset()
+	}
+	inSingle, inDouble, depth := false, false, 0
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case c == '\\' && !inSingle && i+1 < len(command):
+			current.WriteByte(c)
+			current.WriteByte(command[i+1])
+			i++
+			continue
+		case c == '\'' && !inDouble:
+			inSingle = !inSingle
+		case c == '"' && !inSingle:
+			inDouble = !inDouble
+		case inSingle || inDouble:
+		case c == '(':
+			depth++
+		case c == ')' && depth > 0:
+			depth--
+		case depth == 0 && c == ';':
+			flush("; ")
+			continue
+		case depth == 0 && c == '\n':
+			flush("\n")
+			continue
+		case depth == 0 && (c == '&' || c == '|') && i+1 < len(command) && command[i+1] == c:
+			flush(" " + string(c) + string(c) + " ")
+			i++
+			continue
+		case depth == 0 && c == '|':
+			flush(" | ")
+			continue
+		}
+		current.WriteByte(c)
+	}
+	flush("")
+	return out
+}
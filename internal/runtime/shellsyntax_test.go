@@ -0,0 +1,44 @@
+package runtime
+
+import "testing"
+
+func TestAdaptForShellTranslatesBashismsForFish(t *testing.T) {
+	cases := map[string]string{
+		"export FOO=bar":                   "set -x FOO bar",
+		"export A=1 B=2 && make":           "set -x A 1; set -x B 2 && make",
+		"unset FOO":                        "set -e FOO",
+		"FOO=bar":                          "set FOO bar",
+		"make; echo $?":                    "make; echo $status",
+		"echo ${HOME}/bin":                 "echo {$HOME}/bin",
+		`echo "${USER}_backup"`:            `echo "$USER""_backup"`,
+		"echo `date`":                      "echo $(date)",
+		"for f in *.log; do gzip $f; done": "for f in *.log; gzip $f; end",
+		"if test -f a; then cat a; else echo no; fi": "if test -f a; cat a; else echo no; end",
+	}
+	for command, want := range cases {
+		got, ok := AdaptForShell(command, "/usr/bin/fish")
+		if !ok || got != want {
+			t.Fatalf("AdaptForShell(%q) = %q, %v; expected %q", command, got, ok, want)
+		}
+	}
+}
+
+func TestAdaptForShellLeavesPortableOrUntranslatableCommands(t *testing.T) {
+	for _, command := range []string{
+		"git status",
+		"FOO=1 make build",
+		"echo '${literal} $?'",
+		"echo ${FOO:-default}",
+		"cat <<EOF\nhi\nEOF",
+		"[[ -f a ]] && echo yes",
+		`export MSG="two words"`,
+		"ls -la  |  grep x",
+	} {
+		if got, ok := AdaptForShell(command, "fish"); ok || got != command {
+			t.Fatalf("expected %q to stay unchanged, got %q", command, got)
+		}
+	}
+	if got, ok := AdaptForShell("export FOO=bar", "zsh"); ok || got != "export FOO=bar" {
+		t.Fatalf("expected zsh to need no translation, got %q", got)
+	}
+}
@@ -0,0 +1,147 @@
+package runtime
+
+import (
+	"slices"
+	"sort"
+	"strings"
+)
+
+// PreferTools rewrites the tools a person asked ew not to suggest. prefer
+// maps the tool to avoid to its replacement, e.g. "docker-compose" to
+// "docker compose" or "ls" to "eza". Only the command word of each statement
+// is rewritten (after any sudo, env, or VAR=value prefix), so arguments that
+// share a tool's name are left alone. ok is false when nothing changed.
+func PreferTools(command string, prefer map[string]string) (string, bool) {
+	if len(prefer) == 0 || strings.TrimSpace(command) == "" || strings.Contains(command, "<<") {
+		return command, false
+	}
+	avoid := make([]string, 0, len(prefer))
+	for tool := range prefer {
+		avoid = append(avoid, tool)
+	}
+	// Longest first, so a "docker compose" rule wins over a "docker" one.
+	sort.Slice(avoid, func(i, j int) bool { return len(avoid[i]) > len(avoid[j]) })
+
+	changed := false
+	var b strings.Builder
+	for _, stmt := range splitShellStatements(command) {
+		text := preferToolInStatement(stmt.text, avoid, prefer)
+		changed = changed || text != stmt.text
+		b.WriteString(text)
+		b.WriteString(stmt.sep)
+	}
+	if !changed {
+		return command, false
+	}
+	return strings.TrimSpace(b.String()), true
+}
+
+func preferToolInStatement(text string, avoid []string, prefer map[string]string) string {
+	start := commandWordOffset(text)
+	rest := text[start:]
+	for _, tool := range avoid {
+		replacement := prefer[tool]
+		if !startsWithWords(rest, tool) || startsWithWords(rest, replacement) {
+			continue
+		}
+		return text[:start] + replacement + rest[len(tool):]
+	}
+	return text
+}
+
+// CommandTools lists the program each statement of command runs, after any
+// sudo, env, or VAR=value prefix: "kubectl" and "grep" for
+// "kubectl get pods | grep api".
+func CommandTools(command string) []string {
+	var tools []string
+	for _, stmt := range splitShellStatements(command) {
+		word, _, _ := strings.Cut(stmt.text[commandWordOffset(stmt.text):], " ")
+		if word = strings.TrimSpace(word); word != "" {
+			tools = append(tools, word)
+		}
+	}
+	return tools
+}
+
+// AppendToolFlags adds a tool's preferred flags, such as "--profile
+// staging" for aws, to each statement that runs it, leaving out any flag
+// the statement sets already. flags maps the tool to its flags. A statement
+// with "--" is left alone, since what follows belongs to another program.
+// ok is false when nothing changed.
+func AppendToolFlags(command string, flags map[string]string) (string, bool) {
+	if len(flags) == 0 || strings.TrimSpace(command) == "" || strings.Contains(command, "<<") {
+		return command, false
+	}
+	changed := false
+	var b strings.Builder
+	for _, stmt := range splitShellStatements(command) {
+		text := appendFlagsToStatement(stmt.text, flags)
+		changed = changed || text != stmt.text
+		b.WriteString(text)
+		b.WriteString(stmt.sep)
+	}
+	if !changed {
+		return command, false
+	}
+	return strings.TrimSpace(b.String()), true
+}
+
+func appendFlagsToStatement(text string, flags map[string]string) string {
+	words := strings.Fields(text[commandWordOffset(text):])
+	if len(words) == 0 || flags[words[0]] == "" || slices.Contains(words, "--") {
+		return text
+	}
+	for _, group := range flagGroups(flags[words[0]]) {
+		name, _, _ := strings.Cut(group[0], "=")
+		set := slices.ContainsFunc(words[1:], func(word string) bool {
+			return word == name || strings.HasPrefix(word, name+"=")
+		})
+		if !set {
+			text += " " + strings.Join(group, " ")
+		}
+	}
+	return text
+}
+
+// flagGroups splits "--profile staging --no-cli-pager" into each flag with
+// the values that follow it.
+func flagGroups(flags string) [][]string {
+	var groups [][]string
+	for _, word := range strings.Fields(flags) {
+		if strings.HasPrefix(word, "-") || len(groups) == 0 {
+			groups = append(groups, []string{word})
+			continue
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], word)
+	}
+	return groups
+}
+
+// commandWordOffset skips the words that run another command: sudo, env,
+// command, time, nohup, and leading VAR=value assignments.
+func commandWordOffset(text string) int {
+	offset := 0
+	for {
+		rest := text[offset:]
+		trimmed := strings.TrimLeft(rest, " \t")
+		offset += len(rest) - len(trimmed)
+		word, _, _ := strings.Cut(trimmed, " ")
+		switch {
+		case word == "sudo" || word == "env" || word == "command" || word == "time" || word == "nohup":
+		case strings.Contains(word, "=") && !strings.HasPrefix(word, "=") && !strings.ContainsAny(word, `'"`):
+		default:
+			return offset
+		}
+		offset += len(word)
+	}
+}
+
+// startsWithWords reports whether text begins with the words of tool,
+// followed by a space or the end of the statement.
+func startsWithWords(text, tool string) bool {
+	if tool == "" || !strings.HasPrefix(text, tool) {
+		return false
+	}
+	rest := text[len(tool):]
+	return rest == "" || rest[0] == ' ' || rest[0] == '\t'
+}
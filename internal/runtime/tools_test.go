@@ -0,0 +1,61 @@
+package runtime
+
+import "testing"
+
+func TestPreferToolsRewritesCommandWords(t *testing.T) {
+	prefer := map[string]string{
+		"docker-compose": "docker compose",
+		"ls":             "eza",
+		"docker":         "podman",
+	}
+	cases := []struct {
+		command string
+		want    string
+		changed bool
+	}{
+		{"docker-compose up -d", "docker compose up -d", true},
+		{"sudo ls -la /var/log | grep ls", "sudo eza -la /var/log | grep ls", true},
+		{"FOO=1 docker-compose logs && ls", "FOO=1 docker compose logs && eza", true},
+		{"docker ps", "podman ps", true},
+		{"echo ls", "echo ls", false},
+		{"lsof -i :8000", "lsof -i :8000", false},
+		{"cat <<EOF\nls\nEOF", "cat <<EOF\nls\nEOF", false},
+	}
+	for _, tc := range cases {
+		got, changed := PreferTools(tc.command, prefer)
+		if got != tc.want || changed != tc.changed {
+			t.Fatalf("PreferTools(%q) = %q, %v; want %q, %v", tc.command, got, changed, tc.want, tc.changed)
+		}
+	}
+}
+
+func TestPreferToolsPicksTheLongestRule(t *testing.T) {
+	prefer := map[string]string{"docker": "podman", "docker compose": "podman-compose"}
+	if got, _ := PreferTools("docker compose up", prefer); got != "podman-compose up" {
+		t.Fatalf("expected the docker compose rule to win, got %q", got)
+	}
+}
+
+func TestAppendToolFlagsSkipsFlagsAlreadySet(t *testing.T) {
+	flags := map[string]string{"aws": "--profile staging --no-cli-pager", "kubectl": "--context dev"}
+	cases := []struct {
+		command string
+		want    string
+		changed bool
+	}{
+		{"aws s3 ls", "aws s3 ls --profile staging --no-cli-pager", true},
+		{"aws s3 ls --profile=prod", "aws s3 ls --profile=prod --no-cli-pager", true},
+		{"sudo kubectl get pods | grep api", "sudo kubectl get pods --context dev | grep api", true},
+		{"kubectl exec -it api -- sh", "kubectl exec -it api -- sh", false},
+		{"echo aws", "echo aws", false},
+	}
+	for _, tc := range cases {
+		got, changed := AppendToolFlags(tc.command, flags)
+		if got != tc.want || changed != tc.changed {
+			t.Fatalf("AppendToolFlags(%q) = %q, %v; want %q, %v", tc.command, got, changed, tc.want, tc.changed)
+		}
+	}
+	if tools := CommandTools("FOO=1 aws s3 ls && sudo kubectl get pods"); len(tools) != 2 || tools[0] != "aws" || tools[1] != "kubectl" {
+		t.Fatalf("unexpected command tools %v", tools)
+	}
+}
@@ -0,0 +1,293 @@
+package safety
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ashwch/ew/internal/appdirs"
+	ewrt "github.com/ashwch/ew/internal/runtime"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// PolicyFileName is the safety policy in the config directory.
+const PolicyFileName = "safety.toml"
+
+// Policy actions. Deny blocks a command outright, confirm always asks
+// before running it, and allow replaces the built-in high-risk and
+// destructive checks with the rule's risk.
+const (
+	ActionAllow   = "allow"
+	ActionConfirm = "confirm"
+	ActionDeny    = "deny"
+)
+
+// Rule is one [[rule]] in safety.toml. Exactly one of Pattern, a glob over
+// a whole statement where * matches anything, and Regex, searched anywhere
+// in a statement, is set. Dirs limits the rule to working directories that
+// match one of the globs, or sit below one that does.
+type Rule struct {
+	Action  string   `toml:"action" json:"action"`
+	Pattern string   `toml:"pattern,omitempty" json:"pattern,omitempty"`
+	Regex   string   `toml:"regex,omitempty" json:"regex,omitempty"`
+	Risk    string   `toml:"risk,omitempty" json:"risk,omitempty"`
+	Dirs    []string `toml:"dirs,omitempty" json:"dirs,omitempty"`
+	Reason  string   `toml:"reason,omitempty" json:"reason,omitempty"`
+
+	match *regexp.Regexp
+	dirs  []*regexp.Regexp
+}
+
+// Policy is the parsed safety.toml. Rules are tried in file order and the
+// first match wins, so put exceptions above the rules they carve out of.
+// Rules match one statement at a time; see Match.
+type Policy struct {
+	Rules []Rule `toml:"rule" json:"rules"`
+	// Path is where the policy was read from, for messages.
+	Path string `toml:"-" json:"path,omitempty"`
+}
+
+// PolicyPath is <config_dir>/safety.toml.
+func PolicyPath() (string, error) {
+	dir, err := appdirs.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, PolicyFileName), nil
+}
+
+// LoadPolicy reads safety.toml. A missing file is an empty policy.
+func LoadPolicy() (Policy, error) {
+	path, err := PolicyPath()
+	if err != nil {
+		return Policy{}, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Policy{Path: path}, nil
+	}
+	if err != nil {
+		return Policy{Path: path}, fmt.Errorf("could not read safety policy: %w", err)
+	}
+	policy, err := ParsePolicy(data)
+	policy.Path = path
+	return policy, err
+}
+
+// ParsePolicy decodes and checks a safety policy. Unknown keys are errors,
+// so a misspelt field does not quietly turn a rule off.
+func ParsePolicy(data []byte) (Policy, error) {
+	var policy Policy
+	decoder := toml.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&policy); err != nil {
+		return Policy{}, fmt.Errorf("could not parse safety policy: %w", err)
+	}
+	for idx := range policy.Rules {
+		if err := policy.Rules[idx].compile(); err != nil {
+			return Policy{}, fmt.Errorf("rule %d: %w", idx+1, err)
+		}
+	}
+	return policy, nil
+}
+
+func (r *Rule) compile() error {
+	r.Action = strings.ToLower(strings.TrimSpace(r.Action))
+	switch r.Action {
+	case ActionAllow, ActionConfirm, ActionDeny:
+	default:
+		return fmt.Errorf("action must be allow, confirm, or deny, not %q", r.Action)
+	}
+	r.Risk = strings.ToLower(strings.TrimSpace(r.Risk))
+	switch r.Risk {
+	case "", "low", "medium", "high":
+	default:
+		return fmt.Errorf("risk must be low, medium, or high, not %q", r.Risk)
+	}
+	pattern := strings.TrimSpace(r.Pattern)
+	expr := strings.TrimSpace(r.Regex)
+	switch {
+	case pattern != "" && expr != "":
+		return fmt.Errorf("set pattern or regex, not both")
+	case pattern != "":
+		r.match = GlobRegexp(strings.Join(strings.Fields(pattern), " "))
+	case expr != "":
+		compiled, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("invalid regex: %w", err)
+		}
+		r.match = compiled
+	default:
+		return fmt.Errorf("pattern or regex is required")
+	}
+	r.dirs = r.dirs[:0]
+	for _, dir := range r.Dirs {
+		dir = expandHome(strings.TrimSpace(dir))
+		if dir == "" {
+			continue
+		}
+		r.dirs = append(r.dirs, GlobRegexp(filepath.Clean(dir)))
+	}
+	return nil
+}
+
+// Match returns the rule that applies to command run from cwd. Each
+// statement of a chain such as "a && b" is matched on its own, the first
+// matching rule winning for it. A deny on any statement denies the whole
+// command, and an allow only applies when every statement is allowed, so
+// an allowed command cannot carry another one along. Otherwise a confirm
+// on any statement applies.
+func (p Policy) Match(command, cwd string) (Rule, bool) {
+	statements := policyStatements(command)
+	if len(statements) == 0 {
+		return Rule{}, false
+	}
+	var allow, confirm *Rule
+	allowed := 0
+	for _, statement := range statements {
+		rule, ok := p.matchStatement(statement, cwd)
+		if !ok {
+			continue
+		}
+		switch rule.Action {
+		case ActionDeny:
+			return rule, true
+		case ActionConfirm:
+			if confirm == nil {
+				confirm = &rule
+			}
+		case ActionAllow:
+			if HasHiddenCommand(statement) {
+				// Substitutions and redirections are not what the
+				// allow rule was written for; leave them to the
+				// built-in checks.
+				continue
+			}
+			allowed++
+			if allow == nil || riskRank(rule.Risk) > riskRank(allow.Risk) {
+				allow = &rule
+			}
+		}
+	}
+	switch {
+	case confirm != nil:
+		return *confirm, true
+	case allow != nil && allowed == len(statements):
+		return *allow, true
+	}
+	return Rule{}, false
+}
+
+func (p Policy) matchStatement(statement, cwd string) (Rule, bool) {
+	for _, rule := range p.Rules {
+		if rule.match == nil || !rule.match.MatchString(statement) {
+			continue
+		}
+		if len(rule.Dirs) > 0 && !rule.inDirs(cwd) {
+			continue
+		}
+		return rule, true
+	}
+	return Rule{}, false
+}
+
+// policyStatements splits command into its statements with their spaces
+// collapsed, looking inside subshells such as "(cd x && rm -rf y)" too.
+func policyStatements(command string) []string {
+	var out []string
+	for _, statement := range ewrt.Statements(command) {
+		if inner, ok := strings.CutPrefix(statement, "("); ok {
+			if inner, ok = strings.CutSuffix(inner, ")"); ok {
+				out = append(out, policyStatements(inner)...)
+				continue
+			}
+		}
+		if statement = strings.Join(strings.Fields(statement), " "); statement != "" {
+			out = append(out, statement)
+		}
+	}
+	return out
+}
+
+// HasHiddenCommand reports whether statement can run or touch more than it
+// shows: a command substitution, a redirection, or a background job. Rules
+// and --yolo-for both refuse to wave such a statement through.
+func HasHiddenCommand(statement string) bool {
+	return strings.ContainsAny(statement, "`<>&") || strings.Contains(statement, "$(")
+}
+
+func riskRank(risk string) int {
+	switch risk {
+	case "high":
+		return 3
+	case "medium":
+		return 2
+	case "low":
+		return 1
+	}
+	return 0
+}
+
+func (r Rule) inDirs(cwd string) bool {
+	if strings.TrimSpace(cwd) == "" {
+		return false
+	}
+	for dir := filepath.Clean(cwd); ; {
+		for _, pattern := range r.dirs {
+			if pattern.MatchString(dir) {
+				return true
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+}
+
+// Describe names the rule for messages: its reason, else its pattern.
+func (r Rule) Describe() string {
+	if reason := strings.TrimSpace(r.Reason); reason != "" {
+		return reason
+	}
+	if r.Pattern != "" {
+		return fmt.Sprintf("%s %q", r.Action, r.Pattern)
+	}
+	return fmt.Sprintf("%s /%s/", r.Action, r.Regex)
+}
+
+// GlobRegexp turns a glob into an anchored regexp where * matches any run
+// of characters, / included, and ? matches one. Rule patterns and
+// --yolo-for globs share it so both read a glob the same way.
+func GlobRegexp(glob string) *regexp.Regexp {
+	var out strings.Builder
+	out.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			out.WriteString(".*")
+		case '?':
+			out.WriteString(".")
+		default:
+			out.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	out.WriteString("$")
+	return regexp.MustCompile(out.String())
+}
+
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
@@ -0,0 +1,127 @@
+package safety
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPolicyMatchesFirstRuleInOrder(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	policy, err := ParsePolicy([]byte(`
+[[rule]]
+action = "allow"
+pattern = "terraform destroy*"
+dirs = ["~/sandbox"]
+risk = "medium"
+
+[[rule]]
+action = "deny"
+regex = '^kubectl delete (ns|namespace)\b'
+reason = "namespaces are deleted through the platform team"
+
+[[rule]]
+action = "confirm"
+pattern = "terraform *"
+`))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+
+	sandbox := filepath.Join(home, "sandbox", "infra")
+	if rule, ok := policy.Match("terraform   destroy -auto-approve", sandbox); !ok || rule.Action != ActionAllow || rule.Risk != "medium" {
+		t.Fatalf("expected the sandbox allow rule, got %+v %v", rule, ok)
+	}
+	if rule, ok := policy.Match("terraform destroy", filepath.Join(home, "prod")); !ok || rule.Action != ActionConfirm {
+		t.Fatalf("expected the confirm rule outside the sandbox, got %+v %v", rule, ok)
+	}
+	rule, ok := policy.Match("kubectl delete ns staging", home)
+	if !ok || rule.Action != ActionDeny || !strings.Contains(rule.Describe(), "platform team") {
+		t.Fatalf("expected the deny rule with its reason, got %+v %v", rule, ok)
+	}
+	if _, ok := policy.Match("kubectl delete pod web-1", home); ok {
+		t.Fatalf("expected no rule for a pod delete")
+	}
+}
+
+func TestParsePolicyRejectsBrokenRules(t *testing.T) {
+	for _, body := range []string{
+		"[[rule]]\naction = \"block\"\npattern = \"rm *\"\n",
+		"[[rule]]\naction = \"deny\"\n",
+		"[[rule]]\naction = \"deny\"\npattern = \"rm *\"\nregex = \"rm\"\n",
+		"[[rule]]\naction = \"deny\"\nregex = \"(\"\n",
+		"[[rule]]\naction = \"deny\"\npatern = \"rm *\"\n",
+		"[[rule]]\naction = \"allow\"\npattern = \"ls\"\nrisk = \"none\"\n",
+	} {
+		if _, err := ParsePolicy([]byte(body)); err == nil {
+			t.Fatalf("expected %q to be rejected", body)
+		}
+	}
+}
+
+func TestLoadPolicyWithoutFileIsEmpty(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	policy, err := LoadPolicy()
+	if err != nil || len(policy.Rules) != 0 || policy.Path == "" {
+		t.Fatalf("expected an empty policy with its path, got %+v %v", policy, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(policy.Path), 0o755); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+	if err := os.WriteFile(policy.Path, []byte("[[rule]]\naction = \"deny\"\npattern = \"rm -rf /\"\n"), 0o644); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if policy, err = LoadPolicy(); err != nil || len(policy.Rules) != 1 {
+		t.Fatalf("expected one rule loaded, got %+v %v", policy, err)
+	}
+}
+
+func TestPolicyMatchesEachStatementOfAChain(t *testing.T) {
+	policy, err := ParsePolicy([]byte(`
+[[rule]]
+action = "allow"
+pattern = "terraform destroy*"
+
+[[rule]]
+action = "allow"
+pattern = "terraform plan*"
+risk = "medium"
+
+[[rule]]
+action = "deny"
+regex = '^kubectl delete (ns|namespace)\b'
+`))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	cwd := t.TempDir()
+
+	for _, command := range []string{
+		"terraform destroy -auto-approve && rm -rf ~",
+		"terraform destroy; rm -rf ~",
+		"terraform destroy $(rm -rf ~)",
+		"terraform destroy > /etc/passwd",
+	} {
+		if rule, ok := policy.Match(command, cwd); ok && rule.Action == ActionAllow {
+			t.Fatalf("expected %q not to be allowed, got %+v", command, rule)
+		}
+	}
+	if rule, ok := policy.Match("terraform plan && terraform destroy", cwd); !ok || rule.Action != ActionAllow || rule.Risk != "medium" {
+		t.Fatalf("expected an all-allowed chain to take its riskiest rule, got %+v %v", rule, ok)
+	}
+
+	for _, command := range []string{
+		"true && kubectl delete ns prod",
+		"kubectl get ns | kubectl delete namespace prod",
+		"(cd infra && kubectl delete ns prod)",
+		"terraform destroy && kubectl delete ns prod",
+	} {
+		if rule, ok := policy.Match(command, cwd); !ok || rule.Action != ActionDeny {
+			t.Fatalf("expected %q to be denied, got %+v %v", command, rule, ok)
+		}
+	}
+}
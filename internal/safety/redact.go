@@ -1,6 +1,9 @@
 package safety
 
-import "regexp"
+import (
+	"regexp"
+	"strings"
+)
 
 type redactionRule struct {
 	pattern     *regexp.Regexp
@@ -8,6 +11,17 @@ type redactionRule struct {
 }
 
 var secretRedactionRules = []redactionRule{
+	{
+		// user:password@ in a URL such as postgres://app:hunter2@db/app.
+		pattern:     regexp.MustCompile(`(?i)\b([a-z][a-z0-9+.-]*://[^\s:/@]+):([^\s/@]+)@`),
+		replacement: `$1:<redacted>@`,
+	},
+	{
+		// Keys that say what they are: Stripe, GitHub, Slack, AWS access
+		// key ids, and OpenAI-style sk- keys.
+		pattern:     regexp.MustCompile(`\b(?:(?:sk|rk)_(?:live|test)_[A-Za-z0-9]{8,}|gh[pousr]_[A-Za-z0-9]{20,}|github_pat_[A-Za-z0-9_]{20,}|xox[abprs]-[A-Za-z0-9-]{10,}|AKIA[0-9A-Z]{16}|sk-[A-Za-z0-9_-]{20,})`),
+		replacement: `<redacted>`,
+	},
 	{
 		pattern:     regexp.MustCompile(`(?i)\b([a-z0-9_]*(?:token|secret|password|passwd|api[_-]?key|access[_-]?key)[a-z0-9_]*)\s*=\s*([^\s"']+|"[^"]*"|'[^']*')`),
 		replacement: `$1=<redacted>`,
@@ -42,11 +56,45 @@ var secretRedactionRules = []redactionRule{
 	},
 }
 
+// RedactedMarker stands in for every secret RedactText removes.
+const RedactedMarker = "<redacted>"
+
+// pemHeader opens a PEM block such as a private key or certificate.
+var pemHeader = regexp.MustCompile(`-----BEGIN ([A-Z0-9 ]+)-----`)
+
 // RedactText scrubs common secret/token/password patterns from free-form text.
 func RedactText(input string) string {
-	redacted := input
+	redacted := redactPEMBlocks(input)
 	for _, rule := range secretRedactionRules {
 		redacted = rule.pattern.ReplaceAllString(redacted, rule.replacement)
 	}
 	return redacted
 }
+
+// redactPEMBlocks replaces the body of each PEM block, keeping its header
+// and footer. A private key cut off before its footer, as by a tail read,
+// loses everything after the header.
+func redactPEMBlocks(input string) string {
+	var out strings.Builder
+	rest := input
+	for {
+		loc := pemHeader.FindStringSubmatchIndex(rest)
+		if loc == nil {
+			out.WriteString(rest)
+			return out.String()
+		}
+		label := rest[loc[2]:loc[3]]
+		out.WriteString(rest[:loc[1]])
+		rest = rest[loc[1]:]
+		footer := "-----END " + label + "-----"
+		if end := strings.Index(rest, footer); end >= 0 {
+			out.WriteString(RedactedMarker + footer)
+			rest = rest[end+len(footer):]
+			continue
+		}
+		if strings.Contains(label, "PRIVATE KEY") {
+			out.WriteString(RedactedMarker)
+			return out.String()
+		}
+	}
+}
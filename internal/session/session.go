@@ -0,0 +1,263 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ashwch/ew/internal/safety"
+	"github.com/ashwch/ew/internal/state"
+)
+
+const journalFileName = "sessions.jsonl"
+
+// The state backend trims the journal back to maxEntries once it has grown
+// well past that, so appends stay cheap and the journal stays small.
+const (
+	maxEntries     = 200
+	maxFieldLength = 4096
+)
+
+const TranscriptVersion = 1
+
+const (
+	DecisionSuggested   = "suggested"
+	DecisionExecuted    = "executed"
+	DecisionFailed      = "failed"
+	DecisionNotExecuted = "not_executed"
+	DecisionNone        = "no_suggestion"
+	DecisionInterrupted = "interrupted"
+)
+
+// Privacy levels for the journal, as journal.privacy takes them.
+const (
+	PrivacyFull         = "full"
+	PrivacyRedacted     = "redacted"
+	PrivacyCommandsOnly = "commands-only"
+	PrivacyOff          = "off"
+)
+
+var privacy atomic.Value
+
+// SetPrivacy sets what Record keeps of each interaction. It is the
+// journal.privacy setting, or PrivacyOff for --no-record; an unknown level
+// means PrivacyRedacted.
+func SetPrivacy(level string) {
+	privacy.Store(level)
+}
+
+// Privacy is the level set by SetPrivacy. Other stores that keep what ew
+// ran, like the undo journal and the response cache, follow it too, so
+// --no-record leaves nothing behind anywhere.
+func Privacy() string {
+	switch level, _ := privacy.Load().(string); level {
+	case PrivacyFull, PrivacyCommandsOnly, PrivacyOff:
+		return level
+	}
+	return PrivacyRedacted
+}
+
+// Interaction is one ew answer: what was asked, what ew proposed, and what
+// happened to the proposal.
+type Interaction struct {
+	Timestamp string `json:"timestamp"`
+	Query     string `json:"query,omitempty"`
+	Intent    string `json:"intent"`
+	Command   string `json:"command,omitempty"`
+	Source    string `json:"source,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	Decision  string `json:"decision"`
+	// Provider, Model, and LatencyMS are set when an AI provider was
+	// consulted; Model is the concrete model that answered and LatencyMS
+	// the total time spent waiting on it.
+	Provider  string `json:"provider,omitempty"`
+	Model     string `json:"model,omitempty"`
+	LatencyMS int64  `json:"latency_ms,omitempty"`
+	// Confidence is what the provider said of its own answer.
+	Confidence float64 `json:"confidence,omitempty"`
+	// SessionID is the shell session (EW_SESSION_ID) ew was run from, and
+	// Failure the failed command a fix was asked for.
+	SessionID string `json:"session_id,omitempty"`
+	Failure   string `json:"failure,omitempty"`
+}
+
+type Transcript struct {
+	Version      int           `json:"version"`
+	ExportedAt   string        `json:"exported_at"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Record appends an interaction to the local session journal, keeping as
+// much of it as the privacy level allows. Secrets are redacted unless the
+// level is PrivacyFull.
+func Record(in Interaction) error {
+	level := Privacy()
+	if level == PrivacyOff {
+		return nil
+	}
+	if in.Timestamp == "" {
+		in.Timestamp = time.Now().UTC().Format(time.RFC3339)
+	}
+	clean := scrub
+	if level == PrivacyFull {
+		clean = clip
+	}
+	in.Query = clean(in.Query)
+	in.Command = clean(in.Command)
+	in.Reason = clean(in.Reason)
+	in.Failure = clean(in.Failure)
+	in.Source = strings.TrimSpace(in.Source)
+	if in.Query == "" && in.Command == "" {
+		return nil
+	}
+	if level == PrivacyCommandsOnly {
+		in.Query, in.Reason, in.Failure = "", "", ""
+		if in.Command == "" {
+			return nil
+		}
+	}
+	if in.Command == "" {
+		in.Decision = DecisionNone
+	}
+
+	encoded, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("could not serialize interaction: %w", err)
+	}
+	backend, err := state.Current()
+	if err != nil {
+		return err
+	}
+	if err := backend.Append(journalFileName, encoded, maxEntries); err != nil {
+		return fmt.Errorf("could not write interaction: %w", err)
+	}
+	return nil
+}
+
+// Recent returns up to limit interactions, oldest first.
+func Recent(limit int) ([]Interaction, error) {
+	backend, err := state.Current()
+	if err != nil {
+		return nil, err
+	}
+	records, err := backend.Records(journalFileName)
+	if err != nil {
+		return nil, fmt.Errorf("could not read session journal: %w", err)
+	}
+	items := make([]Interaction, 0, len(records))
+	for _, record := range records {
+		var item Interaction
+		if err := json.Unmarshal(record, &item); err != nil {
+			continue
+		}
+		items = append(items, item)
+	}
+	if len(items) == 0 {
+		return nil, nil
+	}
+	if limit > 0 && len(items) > limit {
+		items = items[len(items)-limit:]
+	}
+	return items, nil
+}
+
+// Export returns the last limit interactions as a transcript. Secrets are
+// redacted even when the journal keeps them, since transcripts are made to
+// be shared.
+func Export(limit int) (Transcript, error) {
+	items, err := Recent(limit)
+	if err != nil {
+		return Transcript{}, err
+	}
+	if items == nil {
+		items = []Interaction{}
+	}
+	for i := range items {
+		scrubInteraction(&items[i])
+	}
+	return Transcript{
+		Version:      TranscriptVersion,
+		ExportedAt:   time.Now().UTC().Format(time.RFC3339),
+		Interactions: items,
+	}, nil
+}
+
+// LoadTranscript reads a JSON transcript produced by Export. Content is
+// redacted again on load since the file may have been edited by hand.
+func LoadTranscript(path string) (Transcript, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return Transcript{}, fmt.Errorf("could not read transcript: %w", err)
+	}
+	var transcript Transcript
+	if err := json.Unmarshal(bytes, &transcript); err != nil {
+		return Transcript{}, fmt.Errorf("could not parse transcript (only JSON exports can be replayed): %w", err)
+	}
+	if transcript.Version > TranscriptVersion {
+		return Transcript{}, fmt.Errorf("transcript version %d is newer than this ew supports", transcript.Version)
+	}
+	for i := range transcript.Interactions {
+		scrubInteraction(&transcript.Interactions[i])
+	}
+	return transcript, nil
+}
+
+// Markdown renders a transcript for bug reports and docs.
+func Markdown(transcript Transcript) string {
+	var b strings.Builder
+	b.WriteString("# ew session transcript\n\n")
+	if transcript.ExportedAt != "" {
+		fmt.Fprintf(&b, "Exported at %s. Secrets are redacted.\n", transcript.ExportedAt)
+	}
+	if len(transcript.Interactions) == 0 {
+		b.WriteString("\nNo interactions recorded yet.\n")
+		return b.String()
+	}
+	for i, item := range transcript.Interactions {
+		fmt.Fprintf(&b, "\n## %d. %s\n\n", i+1, describeQuery(item))
+		fmt.Fprintf(&b, "- intent: %s\n", item.Intent)
+		fmt.Fprintf(&b, "- decision: %s\n", item.Decision)
+		if item.Source != "" {
+			fmt.Fprintf(&b, "- source: %s\n", item.Source)
+		}
+		if item.Timestamp != "" {
+			fmt.Fprintf(&b, "- at: %s\n", item.Timestamp)
+		}
+		if item.Reason != "" {
+			fmt.Fprintf(&b, "- reason: %s\n", item.Reason)
+		}
+		if item.Command != "" {
+			fmt.Fprintf(&b, "\n```sh\n%s\n```\n", item.Command)
+		}
+	}
+	return b.String()
+}
+
+func describeQuery(item Interaction) string {
+	if item.Query == "" {
+		return "(no query)"
+	}
+	return item.Query
+}
+
+func scrubInteraction(item *Interaction) {
+	item.Query = scrub(item.Query)
+	item.Command = scrub(item.Command)
+	item.Reason = scrub(item.Reason)
+	item.Failure = scrub(item.Failure)
+}
+
+func scrub(value string) string {
+	return clip(safety.RedactText(strings.TrimSpace(value)))
+}
+
+func clip(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) > maxFieldLength {
+		value = value[:maxFieldLength]
+	}
+	return value
+}
@@ -0,0 +1,154 @@
+package session
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func useTempState(t *testing.T) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", "")
+}
+
+func TestRecordRedactsAndExportKeepsLastN(t *testing.T) {
+	useTempState(t)
+
+	for _, query := range []string{"first", "second", "third"} {
+		if err := Record(Interaction{Query: query, Intent: "find", Command: "echo " + query, Decision: DecisionSuggested}); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	}
+	if err := Record(Interaction{Query: "login", Intent: "run", Command: "deploy --token: abc123", Decision: DecisionExecuted}); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+
+	transcript, err := Export(2)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if len(transcript.Interactions) != 2 {
+		t.Fatalf("expected 2 interactions, got %d", len(transcript.Interactions))
+	}
+	if transcript.Interactions[0].Query != "third" {
+		t.Fatalf("expected oldest-first order, got %q", transcript.Interactions[0].Query)
+	}
+	if strings.Contains(transcript.Interactions[1].Command, "abc123") {
+		t.Fatalf("expected secret to be redacted, got %q", transcript.Interactions[1].Command)
+	}
+}
+
+func TestRecordMarksQueriesWithoutCommand(t *testing.T) {
+	useTempState(t)
+	if err := Record(Interaction{Query: "nothing matched", Intent: "find", Decision: DecisionSuggested}); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	items, err := Recent(0)
+	if err != nil {
+		t.Fatalf("Recent failed: %v", err)
+	}
+	if len(items) != 1 || items[0].Decision != DecisionNone {
+		t.Fatalf("expected a no_suggestion entry, got %+v", items)
+	}
+}
+
+func TestRecordHonoursPrivacyLevel(t *testing.T) {
+	t.Cleanup(func() { SetPrivacy(PrivacyRedacted) })
+	in := Interaction{Query: "login with token abc123", Intent: "fix", Command: "deploy --token abc123", Reason: "needs a token", Failure: "deploy --token abc123 --bad", Decision: DecisionExecuted}
+
+	cases := map[string]func(t *testing.T, items []Interaction){
+		PrivacyFull: func(t *testing.T, items []Interaction) {
+			if len(items) != 1 || items[0].Command != in.Command || items[0].Query != in.Query {
+				t.Fatalf("expected every field verbatim, got %+v", items)
+			}
+		},
+		PrivacyRedacted: func(t *testing.T, items []Interaction) {
+			if len(items) != 1 || strings.Contains(items[0].Command+items[0].Query+items[0].Failure, "abc123") || items[0].Reason == "" {
+				t.Fatalf("expected every field with secrets redacted, got %+v", items)
+			}
+		},
+		PrivacyCommandsOnly: func(t *testing.T, items []Interaction) {
+			if len(items) != 1 || items[0].Query != "" || items[0].Reason != "" || items[0].Failure != "" {
+				t.Fatalf("expected only the command and its outcome, got %+v", items)
+			}
+			if strings.Contains(items[0].Command, "abc123") || items[0].Decision != DecisionExecuted {
+				t.Fatalf("expected a redacted command with its decision, got %+v", items[0])
+			}
+		},
+		PrivacyOff: func(t *testing.T, items []Interaction) {
+			if len(items) != 0 {
+				t.Fatalf("expected nothing recorded, got %+v", items)
+			}
+		},
+	}
+	for level, check := range cases {
+		t.Run(level, func(t *testing.T) {
+			useTempState(t)
+			SetPrivacy(level)
+			if err := Record(in); err != nil {
+				t.Fatalf("Record failed: %v", err)
+			}
+			items, err := Recent(0)
+			if err != nil {
+				t.Fatalf("Recent failed: %v", err)
+			}
+			check(t, items)
+		})
+	}
+
+	useTempState(t)
+	SetPrivacy(PrivacyFull)
+	if err := Record(in); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	transcript, err := Export(0)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if len(transcript.Interactions) != 1 || strings.Contains(transcript.Interactions[0].Command, "abc123") {
+		t.Fatalf("expected exports to be redacted even from a full journal, got %+v", transcript.Interactions)
+	}
+}
+
+func TestLoadTranscriptRoundTripsExport(t *testing.T) {
+	transcript := Transcript{
+		Version: TranscriptVersion,
+		Interactions: []Interaction{
+			{Query: "find port 8000", Intent: "find", Command: "lsof -i :8000", Decision: DecisionExecuted},
+		},
+	}
+	encoded, err := json.Marshal(transcript)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "session.json")
+	if err := os.WriteFile(path, encoded, 0o600); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	loaded, err := LoadTranscript(path)
+	if err != nil {
+		t.Fatalf("LoadTranscript failed: %v", err)
+	}
+	if len(loaded.Interactions) != 1 || loaded.Interactions[0].Command != "lsof -i :8000" {
+		t.Fatalf("unexpected transcript: %+v", loaded)
+	}
+
+	markdown := Markdown(loaded)
+	if !strings.Contains(markdown, "## 1. find port 8000") || !strings.Contains(markdown, "lsof -i :8000") {
+		t.Fatalf("expected markdown to include query and command, got %q", markdown)
+	}
+}
+
+func TestLoadTranscriptRejectsMarkdown(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.md")
+	if err := os.WriteFile(path, []byte("# ew session transcript\n"), 0o600); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if _, err := LoadTranscript(path); err == nil {
+		t.Fatalf("expected markdown transcript to be rejected")
+	}
+}
@@ -0,0 +1,164 @@
+package state
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// filesTrimFactor lets a log grow to this many times its keep count before
+// Append rewrites it, so appends stay cheap.
+const filesTrimFactor = 2
+
+// Files keeps each document and log as its own file in dir, private to the
+// user. It is the default backend and matches the layout older versions
+// wrote.
+type Files struct {
+	dir string
+}
+
+func NewFiles(dir string) *Files {
+	return &Files{dir: dir}
+}
+
+func (f *Files) Location(name string) string {
+	return filepath.Join(f.dir, name)
+}
+
+func (f *Files) Read(name string) ([]byte, error) {
+	payload, err := os.ReadFile(f.Location(name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", name, err)
+	}
+	return payload, nil
+}
+
+// Write replaces name with payload via a temp file and rename.
+func (f *Files) Write(name string, payload []byte) error {
+	if err := f.ensureDir(); err != nil {
+		return err
+	}
+	path := f.Location(name)
+	tempFile, err := os.CreateTemp(f.dir, ".ew-"+name+"-*")
+	if err != nil {
+		return fmt.Errorf("could not create temp file for %s: %w", name, err)
+	}
+	tempPath := tempFile.Name()
+	cleanup := func() {
+		_ = os.Remove(tempPath)
+	}
+	if _, err := tempFile.Write(payload); err != nil {
+		_ = tempFile.Close()
+		cleanup()
+		return fmt.Errorf("could not write temp file for %s: %w", name, err)
+	}
+	if err := tempFile.Chmod(0o600); err != nil {
+		_ = tempFile.Close()
+		cleanup()
+		return fmt.Errorf("could not secure temp file for %s: %w", name, err)
+	}
+	if err := tempFile.Close(); err != nil {
+		cleanup()
+		return fmt.Errorf("could not close temp file for %s: %w", name, err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		cleanup()
+		return fmt.Errorf("could not atomically replace %s: %w", name, err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		return fmt.Errorf("could not secure %s: %w", name, err)
+	}
+	return nil
+}
+
+func (f *Files) Append(name string, record []byte, keep int) error {
+	if err := f.ensureDir(); err != nil {
+		return err
+	}
+	path := f.Location(name)
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("could not open %s: %w", name, err)
+	}
+	line := append(bytes.TrimSpace(record), '\n')
+	if _, err := file.Write(line); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("could not append to %s: %w", name, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("could not close %s: %w", name, err)
+	}
+	if keep <= 0 {
+		return nil
+	}
+	// Counting lines means reading the file, so only do it once the file is
+	// big enough that trimming is likely to be needed.
+	info, err := os.Stat(path)
+	if err != nil || info.Size() < int64(keep*filesTrimFactor*256) {
+		return nil
+	}
+	records, err := f.Records(name)
+	if err != nil || len(records) <= keep*filesTrimFactor {
+		return err
+	}
+	return f.Write(name, joinRecords(records[len(records)-keep:]))
+}
+
+func (f *Files) Records(name string) ([][]byte, error) {
+	file, err := os.Open(f.Location(name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", name, err)
+	}
+	defer file.Close()
+
+	var records [][]byte
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		records = append(records, append([]byte(nil), line...))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not scan %s: %w", name, err)
+	}
+	return records, nil
+}
+
+// Replace rewrites the log in one atomic write.
+func (f *Files) Replace(name string, records [][]byte) error {
+	return f.Write(name, joinRecords(records))
+}
+
+func (f *Files) Close() error {
+	return nil
+}
+
+func (f *Files) ensureDir() error {
+	if err := os.MkdirAll(f.dir, 0o700); err != nil {
+		return fmt.Errorf("could not create state dir: %w", err)
+	}
+	if err := os.Chmod(f.dir, 0o700); err != nil {
+		return fmt.Errorf("could not secure state dir permissions: %w", err)
+	}
+	return nil
+}
+
+func joinRecords(records [][]byte) []byte {
+	var b bytes.Buffer
+	for _, record := range records {
+		b.Write(record)
+		b.WriteByte('\n')
+	}
+	return b.Bytes()
+}
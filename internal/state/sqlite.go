@@ -0,0 +1,235 @@
+package state
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+const sqliteFileName = "state.db"
+
+// sqliteDriver is the database/sql driver name modernc.org/sqlite registers.
+// The driver is only linked into builds made with -tags sqlite, which keeps
+// the default binary small and free of cgo.
+const sqliteDriver = "sqlite"
+
+var sqliteSchema = []string{
+	`PRAGMA journal_mode = WAL`,
+	`PRAGMA busy_timeout = 5000`,
+	`CREATE TABLE IF NOT EXISTS documents (name TEXT PRIMARY KEY, payload BLOB NOT NULL)`,
+	`CREATE TABLE IF NOT EXISTS records (id INTEGER PRIMARY KEY AUTOINCREMENT, log TEXT NOT NULL, payload BLOB NOT NULL)`,
+	`CREATE INDEX IF NOT EXISTS records_log ON records (log, id)`,
+	// imports remembers which names were copied over from the files backend.
+	`CREATE TABLE IF NOT EXISTS imports (name TEXT PRIMARY KEY)`,
+}
+
+// SQLite keeps every document and log in a single state.db, for people
+// whose memory and journals have grown large. The first time a name is used
+// its existing file, if any, is imported so switching backends loses
+// nothing.
+type SQLite struct {
+	db     *sql.DB
+	path   string
+	legacy *Files
+}
+
+// SQLiteAvailable reports whether this build includes the SQLite driver.
+func SQLiteAvailable() bool {
+	return slices.Contains(sql.Drivers(), sqliteDriver)
+}
+
+func OpenSQLite(dir string) (*SQLite, error) {
+	if !SQLiteAvailable() {
+		return nil, errors.New("this ew build has no SQLite support; rebuild with `-tags sqlite` or set state.backend = \"files\"")
+	}
+	legacy := NewFiles(dir)
+	if err := legacy.ensureDir(); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, sqliteFileName)
+	// Create the file up front so it is private before SQLite writes to it.
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("could not create %s: %w", sqliteFileName, err)
+	}
+	_ = file.Close()
+
+	db, err := sql.Open(sqliteDriver, path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s: %w", sqliteFileName, err)
+	}
+	for _, stmt := range sqliteSchema {
+		if _, err := db.Exec(stmt); err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("could not prepare %s: %w", sqliteFileName, err)
+		}
+	}
+	return &SQLite{db: db, path: path, legacy: legacy}, nil
+}
+
+func (s *SQLite) Location(name string) string {
+	return s.path + "#" + name
+}
+
+func (s *SQLite) Read(name string) ([]byte, error) {
+	if err := s.importDocument(name); err != nil {
+		return nil, err
+	}
+	var payload []byte
+	err := s.db.QueryRow(`SELECT payload FROM documents WHERE name = ?`, name).Scan(&payload)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", name, err)
+	}
+	return payload, nil
+}
+
+func (s *SQLite) Write(name string, payload []byte) error {
+	if err := s.markImported(s.db, name); err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(`INSERT INTO documents (name, payload) VALUES (?, ?) ON CONFLICT(name) DO UPDATE SET payload = excluded.payload`, name, payload); err != nil {
+		return fmt.Errorf("could not write %s: %w", name, err)
+	}
+	return nil
+}
+
+func (s *SQLite) Append(name string, record []byte, keep int) error {
+	if err := s.importLog(name); err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(`INSERT INTO records (log, payload) VALUES (?, ?)`, name, record); err != nil {
+		return fmt.Errorf("could not append to %s: %w", name, err)
+	}
+	if keep <= 0 {
+		return nil
+	}
+	_, err := s.db.Exec(`DELETE FROM records WHERE log = ? AND id <= (SELECT id FROM records WHERE log = ? ORDER BY id DESC LIMIT 1 OFFSET ?)`, name, name, keep)
+	if err != nil {
+		return fmt.Errorf("could not trim %s: %w", name, err)
+	}
+	return nil
+}
+
+func (s *SQLite) Records(name string) ([][]byte, error) {
+	if err := s.importLog(name); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(`SELECT payload FROM records WHERE log = ? ORDER BY id`, name)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", name, err)
+	}
+	defer rows.Close()
+	var records [][]byte
+	for rows.Next() {
+		var payload []byte
+		if err := rows.Scan(&payload); err != nil {
+			return nil, fmt.Errorf("could not read %s: %w", name, err)
+		}
+		records = append(records, payload)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", name, err)
+	}
+	return records, nil
+}
+
+func (s *SQLite) Replace(name string, records [][]byte) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("could not replace %s: %w", name, err)
+	}
+	defer tx.Rollback()
+	// A replaced log starts over, so whatever file it had is not imported.
+	if err := s.markImported(tx, name); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM records WHERE log = ?`, name); err != nil {
+		return fmt.Errorf("could not replace %s: %w", name, err)
+	}
+	for _, record := range records {
+		if _, err := tx.Exec(`INSERT INTO records (log, payload) VALUES (?, ?)`, name, record); err != nil {
+			return fmt.Errorf("could not replace %s: %w", name, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not replace %s: %w", name, err)
+	}
+	return nil
+}
+
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
+
+type sqlExecer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func (s *SQLite) markImported(db sqlExecer, name string) error {
+	if _, err := db.Exec(`INSERT OR IGNORE INTO imports (name) VALUES (?)`, name); err != nil {
+		return fmt.Errorf("could not record import of %s: %w", name, err)
+	}
+	return nil
+}
+
+func (s *SQLite) importDocument(name string) error {
+	return s.importOnce(name, func(tx *sql.Tx) error {
+		payload, err := s.legacy.Read(name)
+		if err != nil || payload == nil {
+			return err
+		}
+		_, err = tx.Exec(`INSERT OR IGNORE INTO documents (name, payload) VALUES (?, ?)`, name, payload)
+		return err
+	})
+}
+
+func (s *SQLite) importLog(name string) error {
+	return s.importOnce(name, func(tx *sql.Tx) error {
+		records, err := s.legacy.Records(name)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			if _, err := tx.Exec(`INSERT INTO records (log, payload) VALUES (?, ?)`, name, record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// importOnce copies name over from the files backend the first time it is
+// used. Claiming the name in imports inside the same transaction keeps two
+// ew processes from importing it twice.
+func (s *SQLite) importOnce(name string, copyLegacy func(tx *sql.Tx) error) error {
+	var found int
+	err := s.db.QueryRow(`SELECT 1 FROM imports WHERE name = ?`, name).Scan(&found)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("could not check import of %s: %w", name, err)
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("could not import %s: %w", name, err)
+	}
+	defer tx.Rollback()
+	result, err := tx.Exec(`INSERT OR IGNORE INTO imports (name) VALUES (?)`, name)
+	if err != nil {
+		return fmt.Errorf("could not import %s: %w", name, err)
+	}
+	if claimed, err := result.RowsAffected(); err != nil || claimed == 0 {
+		return err
+	}
+	if err := copyLegacy(tx); err != nil {
+		return fmt.Errorf("could not import %s: %w", name, err)
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,7 @@
+//go:build sqlite
+
+package state
+
+// Registers the pure-Go SQLite driver used by the sqlite backend. Build with
+// `go build -tags sqlite ./cmd/ew`.
+import _ "modernc.org/sqlite"
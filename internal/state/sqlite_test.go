@@ -0,0 +1,68 @@
+//go:build sqlite
+
+package state
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteImportsFilesAndTrimsLogs(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "state")
+	files := NewFiles(dir)
+	if err := files.Write("memory.json", []byte("from files")); err != nil {
+		t.Fatal(err)
+	}
+	if err := files.Append("sessions.jsonl", []byte("old"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := OpenSQLite(dir)
+	if err != nil {
+		t.Fatalf("OpenSQLite failed: %v", err)
+	}
+	defer db.Close()
+	if payload, err := db.Read("memory.json"); err != nil || string(payload) != "from files" {
+		t.Fatalf("expected the files document imported, got %q (%v)", payload, err)
+	}
+	if err := db.Write("memory.json", []byte("from sqlite")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if payload, _ := db.Read("memory.json"); string(payload) != "from sqlite" {
+		t.Fatalf("expected the new document, got %q", payload)
+	}
+	if payload, _ := files.Read("memory.json"); string(payload) != "from files" {
+		t.Fatalf("expected the files backend left alone, got %q", payload)
+	}
+
+	for _, record := range []string{"a", "b", "c"} {
+		if err := db.Append("sessions.jsonl", []byte(record), 2); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+	records, err := db.Records("sessions.jsonl")
+	if err != nil {
+		t.Fatalf("Records failed: %v", err)
+	}
+	if len(records) != 2 || string(records[0]) != "b" || string(records[1]) != "c" {
+		t.Fatalf("expected the newest two records after the import, got %q", records)
+	}
+	if err := db.Replace("sessions.jsonl", [][]byte{[]byte("x")}); err != nil {
+		t.Fatalf("Replace failed: %v", err)
+	}
+	if records, _ := db.Records("sessions.jsonl"); len(records) != 1 || string(records[0]) != "x" {
+		t.Fatalf("expected the replaced log, got %q", records)
+	}
+	if err := db.Replace("events.jsonl", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := files.Append("events.jsonl", []byte("late"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if records, _ := db.Records("events.jsonl"); len(records) != 0 {
+		t.Fatalf("expected a replaced log not to import its file, got %q", records)
+	}
+	if payload, _ := db.Read("missing.json"); payload != nil {
+		t.Fatalf("expected a missing document to read as nil, got %q", payload)
+	}
+}
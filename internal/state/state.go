@@ -0,0 +1,78 @@
+package state
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ashwch/ew/internal/appdirs"
+)
+
+const (
+	BackendFiles  = "files"
+	BackendSQLite = "sqlite"
+)
+
+// Backend stores ew's local state: documents that are read and replaced
+// whole (memory.json, rejections.json) and append-only logs of JSON records
+// (sessions.jsonl). Names are the file names the files backend uses.
+type Backend interface {
+	// Read returns the document stored under name, or nil when there is none.
+	Read(name string) ([]byte, error)
+	// Write atomically replaces the document stored under name.
+	Write(name string, payload []byte) error
+	// Append adds record to the log called name and trims the log back to
+	// its newest keep records once it has grown well past that.
+	Append(name string, record []byte, keep int) error
+	// Records returns every record in the log called name, oldest first.
+	Records(name string) ([][]byte, error)
+	// Replace swaps the whole log called name for records.
+	Replace(name string, records [][]byte) error
+	// Location says where name is stored, for messages and diagnostics.
+	Location(name string) string
+	Close() error
+}
+
+var (
+	currentMu sync.Mutex
+	current   Backend
+)
+
+// Current returns the backend selected with Use, or the files backend in the
+// state directory when none was selected.
+func Current() (Backend, error) {
+	currentMu.Lock()
+	defer currentMu.Unlock()
+	if current != nil {
+		return current, nil
+	}
+	dir, err := appdirs.StateDir()
+	if err != nil {
+		return nil, err
+	}
+	return NewFiles(dir), nil
+}
+
+// Use makes backend the one Current returns; nil restores the default.
+func Use(backend Backend) {
+	currentMu.Lock()
+	defer currentMu.Unlock()
+	current = backend
+}
+
+// Open builds the backend named by the state.backend setting in the state
+// directory.
+func Open(kind string) (Backend, error) {
+	dir, err := appdirs.StateDir()
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(strings.TrimSpace(kind)) {
+	case "", BackendFiles:
+		return NewFiles(dir), nil
+	case BackendSQLite:
+		return OpenSQLite(dir)
+	default:
+		return nil, fmt.Errorf("unknown state backend %q (use files or sqlite)", kind)
+	}
+}
@@ -0,0 +1,125 @@
+package state
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFilesDocumentsRoundTripPrivately(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "state")
+	files := NewFiles(dir)
+
+	if payload, err := files.Read("memory.json"); err != nil || payload != nil {
+		t.Fatalf("expected missing document to read as nil, got %q (%v)", payload, err)
+	}
+	if err := files.Write("memory.json", []byte(`{"entries":[]}`)); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	payload, err := files.Read("memory.json")
+	if err != nil || string(payload) != `{"entries":[]}` {
+		t.Fatalf("unexpected document %q (%v)", payload, err)
+	}
+	info, err := os.Stat(files.Location("memory.json"))
+	if err != nil {
+		t.Fatalf("stat failed: %v", err)
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		t.Fatalf("expected private document, got %o", info.Mode().Perm())
+	}
+}
+
+func TestFilesAppendTrimsToNewestRecords(t *testing.T) {
+	files := NewFiles(t.TempDir())
+	const keep = 5
+	for i := 0; i < 200; i++ {
+		record := fmt.Sprintf(`{"n":%d,"pad":"%0300d"}`, i, 0)
+		if err := files.Append("sessions.jsonl", []byte(record), keep); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+	records, err := files.Records("sessions.jsonl")
+	if err != nil {
+		t.Fatalf("Records failed: %v", err)
+	}
+	if len(records) < keep || len(records) > keep*filesTrimFactor {
+		t.Fatalf("expected the log to stay between %d and %d records, got %d", keep, keep*filesTrimFactor, len(records))
+	}
+	last := fmt.Sprintf(`{"n":199,"pad":"%0300d"}`, 0)
+	if string(records[len(records)-1]) != last {
+		t.Fatalf("expected newest record last, got %s", records[len(records)-1])
+	}
+
+	if err := files.Replace("sessions.jsonl", [][]byte{[]byte("a"), []byte("b")}); err != nil {
+		t.Fatalf("Replace failed: %v", err)
+	}
+	records, _ = files.Records("sessions.jsonl")
+	if len(records) != 2 || string(records[0]) != "a" || string(records[1]) != "b" {
+		t.Fatalf("expected the replaced records, got %q", records)
+	}
+}
+
+func TestVolatileReadsBaseAndKeepsWritesInMemory(t *testing.T) {
+	dir := t.TempDir()
+	files := NewFiles(dir)
+	if err := files.Write("memory.json", []byte("saved")); err != nil {
+		t.Fatal(err)
+	}
+	if err := files.Append("sessions.jsonl", []byte("old"), 10); err != nil {
+		t.Fatal(err)
+	}
+
+	volatile := NewVolatile(files)
+	if payload, _ := volatile.Read("memory.json"); string(payload) != "saved" {
+		t.Fatalf("expected the saved document, got %q", payload)
+	}
+	if err := volatile.Write("memory.json", []byte("changed")); err != nil {
+		t.Fatal(err)
+	}
+	if err := volatile.Append("sessions.jsonl", []byte("new"), 10); err != nil {
+		t.Fatal(err)
+	}
+	if payload, _ := volatile.Read("memory.json"); string(payload) != "changed" {
+		t.Fatalf("expected the in-memory document, got %q", payload)
+	}
+	records, _ := volatile.Records("sessions.jsonl")
+	if len(records) != 2 || string(records[0]) != "old" || string(records[1]) != "new" {
+		t.Fatalf("expected base records then new ones, got %q", records)
+	}
+	if payload, _ := files.Read("memory.json"); string(payload) != "saved" {
+		t.Fatalf("expected the base left untouched, got %q", payload)
+	}
+	if err := volatile.Replace("sessions.jsonl", [][]byte{[]byte("only")}); err != nil {
+		t.Fatal(err)
+	}
+	records, _ = volatile.Records("sessions.jsonl")
+	if len(records) != 1 || string(records[0]) != "only" {
+		t.Fatalf("expected the replaced log to hide the base records, got %q", records)
+	}
+	if records, _ := files.Records("sessions.jsonl"); len(records) != 1 || string(records[0]) != "old" {
+		t.Fatalf("expected the base log left untouched, got %q", records)
+	}
+}
+
+func TestOpenRejectsUnknownBackendAndMissingSQLiteDriver(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, ".local", "state"))
+
+	if _, err := Open("postgres"); err == nil {
+		t.Fatalf("expected unknown backend to be rejected")
+	}
+	backend, err := Open("files")
+	if err != nil {
+		t.Fatalf("Open files failed: %v", err)
+	}
+	if _, ok := backend.(*Files); !ok {
+		t.Fatalf("expected files backend, got %T", backend)
+	}
+	if !SQLiteAvailable() {
+		if _, err := Open(BackendSQLite); err == nil {
+			t.Fatalf("expected sqlite to fail without the driver")
+		}
+	}
+}
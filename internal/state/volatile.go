@@ -0,0 +1,92 @@
+package state
+
+import "sync"
+
+// Volatile keeps every write in memory on top of a backend it only reads.
+// It stands in when the state directory cannot be written, so ew still
+// sees what was saved before and what it changed during this run, and
+// nothing fails or warns on each save.
+type Volatile struct {
+	base Backend
+	mu   sync.Mutex
+	docs map[string][]byte
+	logs map[string][][]byte
+	// replaced names the logs whose base records were swapped out.
+	replaced map[string]bool
+}
+
+// NewVolatile wraps base; a nil base starts empty. Errors reading base are
+// treated as an empty store.
+func NewVolatile(base Backend) *Volatile {
+	return &Volatile{base: base, docs: map[string][]byte{}, logs: map[string][][]byte{}, replaced: map[string]bool{}}
+}
+
+func (v *Volatile) Read(name string) ([]byte, error) {
+	v.mu.Lock()
+	payload, ok := v.docs[name]
+	v.mu.Unlock()
+	if ok {
+		return append([]byte(nil), payload...), nil
+	}
+	if v.base == nil {
+		return nil, nil
+	}
+	payload, err := v.base.Read(name)
+	if err != nil {
+		return nil, nil
+	}
+	return payload, nil
+}
+
+func (v *Volatile) Write(name string, payload []byte) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.docs[name] = append([]byte(nil), payload...)
+	return nil
+}
+
+func (v *Volatile) Append(name string, record []byte, keep int) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.logs[name] = append(v.logs[name], append([]byte(nil), record...))
+	return nil
+}
+
+func (v *Volatile) Records(name string) ([][]byte, error) {
+	v.mu.Lock()
+	replaced := v.replaced[name]
+	v.mu.Unlock()
+	var records [][]byte
+	if v.base != nil && !replaced {
+		records, _ = v.base.Records(name)
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return append(records, v.logs[name]...), nil
+}
+
+func (v *Volatile) Replace(name string, records [][]byte) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	kept := make([][]byte, 0, len(records))
+	for _, record := range records {
+		kept = append(kept, append([]byte(nil), record...))
+	}
+	v.logs[name] = kept
+	v.replaced[name] = true
+	return nil
+}
+
+func (v *Volatile) Location(name string) string {
+	if v.base == nil {
+		return name + " (in memory)"
+	}
+	return v.base.Location(name) + " (in memory)"
+}
+
+func (v *Volatile) Close() error {
+	if v.base == nil {
+		return nil
+	}
+	return v.base.Close()
+}
@@ -12,7 +12,8 @@ import (
 	"strings"
 	"time"
 
-	"github.com/ashwch/ew/internal/appdirs"
+	"github.com/ashwch/ew/internal/multiplexer"
+	"github.com/ashwch/ew/internal/state"
 )
 
 const (
@@ -39,21 +40,24 @@ type Profile struct {
 	Locale          string   `json:"locale,omitempty"`
 	ConfigFiles     []string `json:"config_files,omitempty"`
 	Tools           []string `json:"tools,omitempty"`
+	Multiplexers    []string `json:"multiplexers,omitempty"`
 	GitGlobalIgnore string   `json:"git_global_ignore,omitempty"`
 	UserNote        string   `json:"user_note,omitempty"`
 }
 
-func Ensure(opts Options) (Profile, Status, error) {
+// Ensure loads the saved profile, capturing a new one when it is missing or
+// stale. A cancelled ctx stops the capture and nothing is saved.
+func Ensure(ctx context.Context, opts Options) (Profile, Status, error) {
 	if opts.RefreshHours <= 0 {
 		opts.RefreshHours = 24 * 7
 	}
 
-	path, err := appdirs.StateFilePath(profileFileName)
+	backend, err := state.Current()
 	if err != nil {
 		return Profile{}, Status{}, err
 	}
 
-	current, exists, err := loadPath(path)
+	current, exists, err := load(backend)
 	if err == nil && exists && !current.IsStale(opts.RefreshHours) {
 		current.normalize()
 		return current, Status{}, nil
@@ -66,11 +70,14 @@ func Ensure(opts Options) (Profile, Status, error) {
 		return Profile{}, Status{}, nil
 	}
 
-	captured := Capture()
+	captured, captureErr := Capture(ctx)
+	if captureErr != nil {
+		return Profile{}, Status{}, captureErr
+	}
 	if exists && strings.TrimSpace(current.UserNote) != "" {
 		captured.UserNote = strings.TrimSpace(current.UserNote)
 	}
-	if saveErr := savePath(path, captured); saveErr != nil {
+	if saveErr := save(backend, captured); saveErr != nil {
 		if exists && err == nil {
 			return current, Status{}, nil
 		}
@@ -88,14 +95,16 @@ func Ensure(opts Options) (Profile, Status, error) {
 }
 
 func Save(profile Profile) error {
-	path, err := appdirs.StateFilePath(profileFileName)
+	backend, err := state.Current()
 	if err != nil {
 		return err
 	}
-	return savePath(path, profile)
+	return save(backend, profile)
 }
 
-func Capture() Profile {
+// Capture probes the machine: PATH lookups and one git config read. It
+// returns ctx's error if ctx is cancelled part way.
+func Capture(ctx context.Context) (Profile, error) {
 	profile := Profile{
 		Version:    schemaVersion,
 		CapturedAt: time.Now().UTC().Format(time.RFC3339),
@@ -105,10 +114,14 @@ func Capture() Profile {
 	profile.Shell = detectShell()
 	profile.Locale = detectLocale()
 	profile.ConfigFiles = detectConfigFiles()
-	profile.Tools = detectTools()
-	profile.GitGlobalIgnore = detectGitGlobalIgnore()
+	profile.Tools = detectTools(ctx)
+	profile.Multiplexers = multiplexer.Installed()
+	profile.GitGlobalIgnore = detectGitGlobalIgnore(ctx)
+	if err := ctx.Err(); err != nil {
+		return Profile{}, err
+	}
 	profile.normalize()
-	return profile
+	return profile, nil
 }
 
 func (p Profile) IsStale(refreshHours int) bool {
@@ -156,6 +169,9 @@ func (p Profile) PromptContext(maxItems int) string {
 	if len(p.Tools) > 0 {
 		lines = append(lines, "tools="+strings.Join(trimList(p.Tools, maxItems), ", "))
 	}
+	if len(p.Multiplexers) > 0 {
+		lines = append(lines, "multiplexers="+strings.Join(p.Multiplexers, ", "))
+	}
 	if strings.TrimSpace(p.GitGlobalIgnore) != "" {
 		lines = append(lines, "git_global_ignore="+strings.TrimSpace(p.GitGlobalIgnore))
 	}
@@ -165,6 +181,33 @@ func (p Profile) PromptContext(maxItems int) string {
 	return strings.TrimSpace(strings.Join(lines, "\n"))
 }
 
+// Share picks the optional parts of a profile that leave the machine.
+type Share struct {
+	Tools       bool
+	ConfigFiles bool
+	GitIgnore   bool
+	UserNote    bool
+}
+
+// Shared returns p without the parts share keeps local, ready for
+// PromptContext. Tools covers the multiplexers as well.
+func (p Profile) Shared(share Share) Profile {
+	if !share.Tools {
+		p.Tools = nil
+		p.Multiplexers = nil
+	}
+	if !share.ConfigFiles {
+		p.ConfigFiles = nil
+	}
+	if !share.GitIgnore {
+		p.GitGlobalIgnore = ""
+	}
+	if !share.UserNote {
+		p.UserNote = ""
+	}
+	return p
+}
+
 func (p Profile) HumanSummary(maxItems int) string {
 	context := p.PromptContext(maxItems)
 	if context == "" {
@@ -237,15 +280,18 @@ func detectConfigFiles() []string {
 	return dedupeStrings(found)
 }
 
-func detectTools() []string {
+func detectTools(ctx context.Context) []string {
 	candidates := []string{
 		"git", "gh", "aws", "docker", "kubectl", "terraform", "terragrunt",
 		"uv", "python3", "python", "node", "npm", "pnpm", "yarn",
 		"go", "rustc", "cargo", "brew", "jq", "rg", "fzf",
-		"claude", "codex",
+		"claude", "codex", "trash", "trash-put", "gio",
 	}
 	installed := make([]string, 0, len(candidates))
 	for _, candidate := range candidates {
+		if ctx.Err() != nil {
+			break
+		}
 		if _, err := exec.LookPath(candidate); err == nil {
 			installed = append(installed, candidate)
 		}
@@ -254,12 +300,12 @@ func detectTools() []string {
 	return dedupeStrings(installed)
 }
 
-func detectGitGlobalIgnore() string {
+func detectGitGlobalIgnore(ctx context.Context) string {
 	if _, err := exec.LookPath("git"); err != nil {
 		return ""
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 800*time.Millisecond)
+	ctx, cancel := context.WithTimeout(ctx, 800*time.Millisecond)
 	defer cancel()
 
 	cmd := exec.CommandContext(ctx, "git", "config", "--global", "core.excludesFile")
@@ -279,14 +325,14 @@ func detectGitGlobalIgnore() string {
 	return homeRelative(value, home)
 }
 
-func loadPath(path string) (Profile, bool, error) {
-	bytes, err := os.ReadFile(path)
-	if os.IsNotExist(err) {
-		return Profile{}, false, nil
-	}
+func load(backend state.Backend) (Profile, bool, error) {
+	bytes, err := backend.Read(profileFileName)
 	if err != nil {
 		return Profile{}, false, fmt.Errorf("could not read system profile: %w", err)
 	}
+	if bytes == nil {
+		return Profile{}, false, nil
+	}
 	var profile Profile
 	if err := json.Unmarshal(bytes, &profile); err != nil {
 		return Profile{}, true, fmt.Errorf("could not parse system profile: %w", err)
@@ -295,42 +341,14 @@ func loadPath(path string) (Profile, bool, error) {
 	return profile, true, nil
 }
 
-func savePath(path string, profile Profile) error {
+func save(backend state.Backend, profile Profile) error {
 	profile.normalize()
 	payload, err := json.MarshalIndent(profile, "", "  ")
 	if err != nil {
 		return fmt.Errorf("could not encode system profile: %w", err)
 	}
-	if _, err := appdirs.EnsureStateDir(); err != nil {
-		return err
-	}
-	dir := filepath.Dir(path)
-	tempFile, err := os.CreateTemp(dir, ".ew-system-profile-*.json")
-	if err != nil {
-		return fmt.Errorf("could not create temp profile file: %w", err)
-	}
-	tempPath := tempFile.Name()
-	cleanup := func() { _ = os.Remove(tempPath) }
-	if _, err := tempFile.Write(payload); err != nil {
-		_ = tempFile.Close()
-		cleanup()
-		return fmt.Errorf("could not write temp profile file: %w", err)
-	}
-	if err := tempFile.Chmod(0o600); err != nil {
-		_ = tempFile.Close()
-		cleanup()
-		return fmt.Errorf("could not secure temp profile file: %w", err)
-	}
-	if err := tempFile.Close(); err != nil {
-		cleanup()
-		return fmt.Errorf("could not close temp profile file: %w", err)
-	}
-	if err := os.Rename(tempPath, path); err != nil {
-		cleanup()
-		return fmt.Errorf("could not atomically replace profile file: %w", err)
-	}
-	if err := os.Chmod(path, 0o600); err != nil {
-		return fmt.Errorf("could not secure profile file permissions: %w", err)
+	if err := backend.Write(profileFileName, payload); err != nil {
+		return fmt.Errorf("could not save system profile: %w", err)
 	}
 	return nil
 }
@@ -351,6 +369,7 @@ func (p *Profile) normalize() {
 	p.UserNote = strings.TrimSpace(p.UserNote)
 	p.ConfigFiles = normalizeStringList(p.ConfigFiles)
 	p.Tools = normalizeStringList(p.Tools)
+	p.Multiplexers = normalizeStringList(p.Multiplexers)
 }
 
 func normalizeStringList(values []string) []string {
@@ -1,6 +1,8 @@
 package systemprofile
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -17,7 +19,7 @@ func TestEnsureCreatesProfileOnFirstRun(t *testing.T) {
 	t.Setenv("HOME", home)
 	t.Setenv("XDG_STATE_HOME", stateBase)
 
-	profile, status, err := Ensure(Options{AutoTrain: true, RefreshHours: 168})
+	profile, status, err := Ensure(context.Background(), Options{AutoTrain: true, RefreshHours: 168})
 	if err != nil {
 		t.Fatalf("ensure failed: %v", err)
 	}
@@ -54,7 +56,7 @@ func TestSaveAndEnsureRoundTrip(t *testing.T) {
 		t.Fatalf("save failed: %v", err)
 	}
 
-	profile, status, err := Ensure(Options{AutoTrain: false, RefreshHours: 168})
+	profile, status, err := Ensure(context.Background(), Options{AutoTrain: false, RefreshHours: 168})
 	if err != nil {
 		t.Fatalf("ensure failed: %v", err)
 	}
@@ -92,6 +94,26 @@ func TestPromptContextIncludesNoteAndTools(t *testing.T) {
 	}
 }
 
+func TestSharedKeepsUnsharedPartsLocal(t *testing.T) {
+	profile := Profile{
+		OS:              "linux",
+		Arch:            "amd64",
+		Shell:           "bash",
+		ConfigFiles:     []string{"~/.bashrc"},
+		Tools:           []string{"git", "rg"},
+		Multiplexers:    []string{"tmux"},
+		GitGlobalIgnore: "~/.config/git/ignore",
+		UserNote:        "work laptop",
+	}
+	context := profile.Shared(Share{ConfigFiles: true}).PromptContext(8)
+	if context != "os=linux arch=amd64 shell=bash\nconfig_files=~/.bashrc" {
+		t.Fatalf("expected only the base line and config files, got %q", context)
+	}
+	if all := profile.Shared(Share{Tools: true, ConfigFiles: true, GitIgnore: true, UserNote: true}); !strings.Contains(all.PromptContext(8), "multiplexers=tmux") || all.UserNote != "work laptop" {
+		t.Fatalf("expected everything kept when all parts are shared, got %+v", all)
+	}
+}
+
 func TestSaveUsesPrivatePermissions(t *testing.T) {
 	if runtime.GOOS == "windows" {
 		t.Skip("permission bits are not portable on windows")
@@ -102,7 +124,10 @@ func TestSaveUsesPrivatePermissions(t *testing.T) {
 	t.Setenv("HOME", home)
 	t.Setenv("XDG_STATE_HOME", stateBase)
 
-	profile := Capture()
+	profile, err := Capture(context.Background())
+	if err != nil {
+		t.Fatalf("capture failed: %v", err)
+	}
 	if err := Save(profile); err != nil {
 		t.Fatalf("save failed: %v", err)
 	}
@@ -129,3 +154,22 @@ func TestIsStale(t *testing.T) {
 		t.Fatalf("expected profile to be stale")
 	}
 }
+
+func TestEnsureDoesNotSaveWhenCancelled(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, ".local", "state"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := Ensure(ctx, Options{AutoTrain: true, RefreshHours: 168}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	path, err := appdirs.StateFilePath(profileFileName)
+	if err != nil {
+		t.Fatalf("state path failed: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected no profile to be saved, got %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+// Package target parses execution target specs. It imports nothing else
+// from ew, so config can validate execution.target and runtime can build a
+// backend from the same parse.
+package target
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kinds of execution target.
+const (
+	Local   = "local"
+	SSH     = "ssh"
+	Docker  = "docker"
+	Kubectl = "kubectl"
+)
+
+// Spec is a parsed execution target.
+type Spec struct {
+	Kind string
+	// Host is the ssh destination, [user@]host.
+	Host string
+	// Namespace and Pod name a kubectl target.
+	Namespace string
+	Pod       string
+	// Container is the docker container, or the kubectl container in Pod.
+	Container string
+}
+
+// Parse reads an execution target spec:
+//
+//	local                          run in the user's shell (default)
+//	ssh:[user@]host                run over ssh
+//	docker:container               docker exec into a running container
+//	kubectl:[namespace/]pod[/container]
+func Parse(spec string) (Spec, error) {
+	trimmed := strings.TrimSpace(spec)
+	if trimmed == "" || strings.EqualFold(trimmed, Local) {
+		return Spec{Kind: Local}, nil
+	}
+	kind, rest, ok := strings.Cut(trimmed, ":")
+	rest = strings.TrimSpace(rest)
+	if !ok || rest == "" || strings.ContainsAny(rest, " \t\n") {
+		return Spec{}, fmt.Errorf("target must be local, ssh:<host>, docker:<container>, or kubectl:[namespace/]pod[/container]")
+	}
+	switch strings.ToLower(kind) {
+	case SSH:
+		if strings.HasPrefix(rest, "-") {
+			return Spec{}, fmt.Errorf("ssh target host cannot start with '-'")
+		}
+		return Spec{Kind: SSH, Host: rest}, nil
+	case Docker:
+		if strings.HasPrefix(rest, "-") {
+			return Spec{}, fmt.Errorf("docker target container cannot start with '-'")
+		}
+		return Spec{Kind: Docker, Container: rest}, nil
+	case Kubectl:
+		parts := strings.Split(rest, "/")
+		for _, part := range parts {
+			if part == "" || strings.HasPrefix(part, "-") {
+				return Spec{}, fmt.Errorf("kubectl target must look like [namespace/]pod[/container]")
+			}
+		}
+		switch len(parts) {
+		case 1:
+			return Spec{Kind: Kubectl, Pod: parts[0]}, nil
+		case 2:
+			return Spec{Kind: Kubectl, Namespace: parts[0], Pod: parts[1]}, nil
+		case 3:
+			return Spec{Kind: Kubectl, Namespace: parts[0], Pod: parts[1], Container: parts[2]}, nil
+		default:
+			return Spec{}, fmt.Errorf("kubectl target must look like [namespace/]pod[/container]")
+		}
+	default:
+		return Spec{}, fmt.Errorf("unknown target kind %q (use local, ssh, docker, or kubectl)", kind)
+	}
+}
+
+// String is the canonical spec, e.g. "local" or "docker:web".
+func (s Spec) String() string {
+	switch s.Kind {
+	case SSH:
+		return SSH + ":" + s.Host
+	case Docker:
+		return Docker + ":" + s.Container
+	case Kubectl:
+		parts := []string{}
+		if s.Namespace != "" {
+			parts = append(parts, s.Namespace)
+		}
+		parts = append(parts, s.Pod)
+		if s.Container != "" {
+			parts = append(parts, s.Container)
+		}
+		return Kubectl + ":" + strings.Join(parts, "/")
+	default:
+		return Local
+	}
+}
@@ -0,0 +1,31 @@
+package target
+
+import "testing"
+
+func TestParseCanonicalizesSpecs(t *testing.T) {
+	cases := map[string]string{
+		"":                       "local",
+		" LOCAL ":                "local",
+		"ssh:deploy@prod":        "ssh:deploy@prod",
+		"Docker:web":             "docker:web",
+		"KUBECTL:api-0":          "kubectl:api-0",
+		"kubectl:prod/api-0/app": "kubectl:prod/api-0/app",
+	}
+	for spec, want := range cases {
+		parsed, err := Parse(spec)
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", spec, err)
+		}
+		if got := parsed.String(); got != want {
+			t.Fatalf("expected %q to read as %q, got %q", spec, want, got)
+		}
+	}
+}
+
+func TestParseRejectsMalformedSpecs(t *testing.T) {
+	for _, spec := range []string{"ssh:", "ssh:-oProxyCommand=x", "docker:a b", "kubectl:a//b", "kubectl:a/b/c/d", "podman:web", "prod"} {
+		if _, err := Parse(spec); err == nil {
+			t.Fatalf("expected %q to be rejected", spec)
+		}
+	}
+}
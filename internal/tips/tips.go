@@ -0,0 +1,189 @@
+// Package tips picks the occasional one-line hint ew prints after an
+// answer, based on how ew has been used so far: hooks that were never
+// loaded, memory that was never taught, confirmations that are always
+// declined. At most one tip is shown a day, and each can be dismissed for
+// good.
+package tips
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ashwch/ew/internal/session"
+	"github.com/ashwch/ew/internal/state"
+)
+
+const fileName = "tips.json"
+
+// Interval is the least time between two tips.
+const Interval = 24 * time.Hour
+
+// Tip IDs, as --dismiss-tip takes them. DismissAll turns every tip off.
+const (
+	IDHooks    = "hooks"
+	IDMemory   = "memory"
+	IDConfirm  = "confirm"
+	DismissAll = "all"
+)
+
+// IDs lists every tip, in the order they are considered.
+var IDs = []string{IDHooks, IDMemory, IDConfirm}
+
+const (
+	// memoryMinInteractions is how many answers ew gives before suggesting
+	// it be taught, so the tip does not greet a brand-new user.
+	memoryMinInteractions = 10
+	// declineStreak is how many offers to run a command in a row must be
+	// turned down before ew suggests suggest mode.
+	declineStreak = 5
+)
+
+// Tip is one hint.
+type Tip struct {
+	ID   string `json:"id"`
+	Text string `json:"text"`
+}
+
+// Signals is what the tips are chosen from.
+type Signals struct {
+	// Interactions are recent journal entries, oldest first.
+	Interactions []session.Interaction
+	// HooksLoaded is set when the shell hooks run in this shell.
+	HooksLoaded bool
+}
+
+// State is what ew remembers about tips between runs.
+type State struct {
+	LastShown string   `json:"last_shown,omitempty"`
+	Dismissed []string `json:"dismissed,omitempty"`
+}
+
+// Pick returns the first tip that applies and has not been dismissed, or
+// false when none does or one was already shown within Interval of now.
+func Pick(signals Signals, st State, now time.Time) (Tip, bool) {
+	if slices.Contains(st.Dismissed, DismissAll) {
+		return Tip{}, false
+	}
+	if last, err := time.Parse(time.RFC3339, st.LastShown); err == nil && now.Sub(last) < Interval {
+		return Tip{}, false
+	}
+	for _, id := range IDs {
+		if slices.Contains(st.Dismissed, id) || !applies(id, signals) {
+			continue
+		}
+		return Tip{ID: id, Text: text(id)}, true
+	}
+	return Tip{}, false
+}
+
+func applies(id string, signals Signals) bool {
+	switch id {
+	case IDHooks:
+		return !signals.HooksLoaded
+	case IDMemory:
+		if len(signals.Interactions) < memoryMinInteractions {
+			return false
+		}
+		for _, item := range signals.Interactions {
+			if item.Source == "memory" {
+				return false
+			}
+		}
+		return true
+	case IDConfirm:
+		declined := 0
+		for i := len(signals.Interactions) - 1; i >= 0 && declined < declineStreak; i-- {
+			switch signals.Interactions[i].Decision {
+			case session.DecisionNotExecuted:
+				declined++
+			case session.DecisionExecuted, session.DecisionFailed:
+				return false
+			}
+		}
+		return declined >= declineStreak
+	}
+	return false
+}
+
+func text(id string) string {
+	switch id {
+	case IDHooks:
+		return `ew can fix the command that just failed once the shell hooks are loaded: add eval "$(ew --setup-hooks)" to your shell rc`
+	case IDMemory:
+		return "teach ew your own phrasing: ew remember deploy staging means make deploy ENV=staging"
+	case IDConfirm:
+		return fmt.Sprintf("you declined the last %d commands ew offered to run; ew --mode suggest --save just prints them", declineStreak)
+	}
+	return ""
+}
+
+// CheckID returns id in canonical form, or an error naming the valid IDs.
+func CheckID(id string) (string, error) {
+	id = strings.ToLower(strings.TrimSpace(id))
+	if id != DismissAll && !slices.Contains(IDs, id) {
+		return "", fmt.Errorf("unknown tip %q: want one of %s, or %s", id, strings.Join(IDs, ", "), DismissAll)
+	}
+	return id, nil
+}
+
+// Dismiss hides the tip id, or every tip for DismissAll.
+func (s *State) Dismiss(id string) error {
+	id, err := CheckID(id)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(s.Dismissed, id) {
+		s.Dismissed = append(s.Dismissed, id)
+	}
+	return nil
+}
+
+// Disabled reports whether EW_TIPS turns tips off regardless of the
+// config.
+func Disabled() bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("EW_TIPS"))) {
+	case "0", "off", "false", "no":
+		return true
+	}
+	return false
+}
+
+// Load reads the tips state; a missing document is an empty state.
+func Load() (State, error) {
+	backend, err := state.Current()
+	if err != nil {
+		return State{}, err
+	}
+	payload, err := backend.Read(fileName)
+	if err != nil {
+		return State{}, fmt.Errorf("could not read tips state: %w", err)
+	}
+	var st State
+	if payload == nil {
+		return st, nil
+	}
+	if err := json.Unmarshal(payload, &st); err != nil {
+		return State{}, fmt.Errorf("could not parse tips state: %w", err)
+	}
+	return st, nil
+}
+
+// Save replaces the tips state.
+func Save(st State) error {
+	backend, err := state.Current()
+	if err != nil {
+		return err
+	}
+	payload, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode tips state: %w", err)
+	}
+	if err := backend.Write(fileName, payload); err != nil {
+		return fmt.Errorf("could not save tips state: %w", err)
+	}
+	return nil
+}
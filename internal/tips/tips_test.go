@@ -0,0 +1,85 @@
+package tips
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ashwch/ew/internal/session"
+)
+
+func interactions(n int, decision, source string) []session.Interaction {
+	items := make([]session.Interaction, n)
+	for i := range items {
+		items[i] = session.Interaction{Intent: "find", Decision: decision, Source: source}
+	}
+	return items
+}
+
+func TestPickFollowsUsageSignals(t *testing.T) {
+	now := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name    string
+		signals Signals
+		want    string
+	}{
+		{"hooks missing", Signals{Interactions: interactions(1, session.DecisionSuggested, "history")}, IDHooks},
+		{"new user", Signals{HooksLoaded: true, Interactions: interactions(3, session.DecisionSuggested, "history")}, ""},
+		{"never used memory", Signals{HooksLoaded: true, Interactions: interactions(12, session.DecisionSuggested, "history")}, IDMemory},
+		{"uses memory", Signals{HooksLoaded: true, Interactions: append(interactions(11, session.DecisionSuggested, "history"), session.Interaction{Source: "memory"})}, ""},
+		{"always declines", Signals{HooksLoaded: true, Interactions: interactions(5, session.DecisionNotExecuted, "memory")}, IDConfirm},
+		{"ran one lately", Signals{HooksLoaded: true, Interactions: append(interactions(5, session.DecisionNotExecuted, "memory"), session.Interaction{Decision: session.DecisionExecuted, Source: "memory"})}, ""},
+	}
+	for _, tc := range cases {
+		tip, ok := Pick(tc.signals, State{}, now)
+		if tip.ID != tc.want || ok != (tc.want != "") {
+			t.Fatalf("%s: got %q %v, want %q", tc.name, tip.ID, ok, tc.want)
+		}
+	}
+}
+
+func TestPickShowsAtMostOneTipADayAndHonoursDismissals(t *testing.T) {
+	now := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	signals := Signals{Interactions: interactions(12, session.DecisionSuggested, "history")}
+
+	st := State{LastShown: now.Add(-2 * time.Hour).Format(time.RFC3339)}
+	if _, ok := Pick(signals, st, now); ok {
+		t.Fatalf("expected no tip within a day of the last one")
+	}
+	st.LastShown = now.Add(-25 * time.Hour).Format(time.RFC3339)
+	if err := st.Dismiss(" Hooks "); err != nil {
+		t.Fatalf("Dismiss failed: %v", err)
+	}
+	if tip, ok := Pick(signals, st, now); !ok || tip.ID != IDMemory {
+		t.Fatalf("expected the next tip after dismissing hooks, got %q %v", tip.ID, ok)
+	}
+	if err := st.Dismiss(DismissAll); err != nil {
+		t.Fatalf("Dismiss failed: %v", err)
+	}
+	if _, ok := Pick(signals, st, now); ok {
+		t.Fatalf("expected no tips after dismissing all")
+	}
+	if err := st.Dismiss("nonsense"); err == nil {
+		t.Fatalf("expected an unknown tip to be rejected")
+	}
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, ".local", "state"))
+
+	st, err := Load()
+	if err != nil || st.LastShown != "" || len(st.Dismissed) != 0 {
+		t.Fatalf("expected an empty state, got %+v %v", st, err)
+	}
+	st.LastShown = "2026-03-02T09:00:00Z"
+	st.Dismissed = []string{IDConfirm}
+	if err := Save(st); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := Load()
+	if err != nil || loaded.LastShown != st.LastShown || len(loaded.Dismissed) != 1 {
+		t.Fatalf("unexpected state after reload %+v %v", loaded, err)
+	}
+}
@@ -0,0 +1,251 @@
+package tldr
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ashwch/ew/internal/state"
+)
+
+// pagesFileName is the parsed pages' name in the state backend, and
+// legacyCacheDirName where older versions unpacked the markdown pages.
+const (
+	pagesFileName      = "tldr_pages.json"
+	legacyCacheDirName = "tldr"
+	pagesVersion       = 1
+)
+
+// Example is one "- description:" / "`command`" pair from a tldr page.
+// Placeholders keep tldr's {{name}} syntax; Render turns them into <name>.
+type Example struct {
+	Page        string `json:"page"`
+	Platform    string `json:"platform"`
+	Description string `json:"description"`
+	Command     string `json:"command"`
+}
+
+type Page struct {
+	Name     string    `json:"name"`
+	Platform string    `json:"platform"`
+	Summary  string    `json:"summary,omitempty"`
+	Examples []Example `json:"examples"`
+}
+
+// pageStore is the document Install saves: every page of every platform.
+type pageStore struct {
+	Version int    `json:"version"`
+	Pages   []Page `json:"pages"`
+}
+
+// Match is a scored example for a find query.
+type Match struct {
+	Example
+	Score float64 `json:"score"`
+}
+
+var placeholderPattern = regexp.MustCompile(`\{\{(.*?)\}\}`)
+
+// Location says where the installed pages are kept.
+func Location() (string, error) {
+	backend, err := state.Current()
+	if err != nil {
+		return "", err
+	}
+	return backend.Location(pagesFileName), nil
+}
+
+// Platform maps a GOOS value to the tldr pages directory for it.
+func Platform(goos string) string {
+	switch goos {
+	case "darwin":
+		return "osx"
+	case "linux", "windows", "freebsd", "openbsd", "netbsd", "android":
+		return goos
+	case "solaris", "illumos":
+		return "sunos"
+	default:
+		return "common"
+	}
+}
+
+// ParsePage reads a tldr markdown page.
+func ParsePage(name string, platform string, content string) Page {
+	page := Page{Name: name, Platform: platform}
+	description := ""
+	summary := []string{}
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "# "):
+			page.Name = strings.TrimSpace(strings.TrimPrefix(line, "# "))
+		case strings.HasPrefix(line, "> "):
+			text := strings.TrimSpace(strings.TrimPrefix(line, "> "))
+			if !strings.HasPrefix(text, "More information") {
+				summary = append(summary, text)
+			}
+		case strings.HasPrefix(line, "- "):
+			description = strings.TrimSuffix(strings.TrimSpace(strings.TrimPrefix(line, "- ")), ":")
+		case strings.HasPrefix(line, "`") && strings.HasSuffix(line, "`") && len(line) > 1 && description != "":
+			page.Examples = append(page.Examples, Example{
+				Page:        page.Name,
+				Platform:    platform,
+				Description: description,
+				Command:     strings.Trim(line, "`"),
+			})
+			description = ""
+		}
+	}
+	page.Summary = strings.Join(summary, " ")
+	return page
+}
+
+// Render replaces {{placeholder}} with <placeholder>, which a shell refuses
+// to run as is, so an unfilled example cannot execute by accident.
+func Render(command string) string {
+	return placeholderPattern.ReplaceAllString(command, "<$1>")
+}
+
+// Placeholders lists the {{...}} names in command, in order.
+func Placeholders(command string) []string {
+	found := placeholderPattern.FindAllStringSubmatch(command, -1)
+	names := make([]string, 0, len(found))
+	for _, match := range found {
+		names = append(names, match[1])
+	}
+	return names
+}
+
+// LoadPages reads the installed pages for platform plus common. A platform
+// page replaces the common page of the same name, as in the tldr client
+// spec. Nothing installed is no pages, not an error.
+func LoadPages(platform string) ([]Page, error) {
+	backend, err := state.Current()
+	if err != nil {
+		return nil, err
+	}
+	payload, err := backend.Read(pagesFileName)
+	if err != nil || payload == nil {
+		return nil, err
+	}
+	var store pageStore
+	if err := json.Unmarshal(payload, &store); err != nil {
+		return nil, fmt.Errorf("could not parse tldr pages: %w", err)
+	}
+	byName := map[string]Page{}
+	for _, page := range store.Pages {
+		if page.Platform != "common" && (page.Platform != platform || platform == "") {
+			continue
+		}
+		if _, ok := byName[page.Name]; ok && page.Platform == "common" {
+			continue
+		}
+		byName[page.Name] = page
+	}
+	pages := make([]Page, 0, len(byName))
+	for _, page := range byName {
+		pages = append(pages, page)
+	}
+	sort.Slice(pages, func(i, j int) bool { return pages[i].Name < pages[j].Name })
+	return pages, nil
+}
+
+// Search scores every example against query. Naming the page's command
+// counts most, then words shared with the example's description, then with
+// the page summary; examples that share nothing with the query are dropped.
+func Search(pages []Page, query string, limit int) []Match {
+	tokens := queryTokens(query)
+	if len(tokens) == 0 {
+		return nil
+	}
+	matches := []Match{}
+	for _, page := range pages {
+		nameHit := 0.0
+		name := stem(strings.ToLower(page.Name))
+		for _, token := range tokens {
+			if token == name {
+				nameHit = 10
+			}
+		}
+		summary := wordSet(page.Summary)
+		for _, example := range page.Examples {
+			description := wordSet(example.Description)
+			overlap, summaryOverlap := 0.0, 0.0
+			for _, token := range tokens {
+				if description[token] {
+					overlap++
+				} else if summary[token] {
+					summaryOverlap++
+				}
+			}
+			if overlap == 0 && nameHit == 0 {
+				continue
+			}
+			score := nameHit + overlap*3 + summaryOverlap
+			if example.Platform != "common" {
+				score += 0.5
+			}
+			matches = append(matches, Match{Example: example, Score: score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score == matches[j].Score {
+			return matches[i].Page < matches[j].Page
+		}
+		return matches[i].Score > matches[j].Score
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+var stopwords = map[string]bool{
+	"a": true, "an": true, "the": true, "to": true, "of": true, "in": true, "on": true, "for": true,
+	"and": true, "or": true, "with": true, "how": true, "do": true, "i": true, "my": true, "me": true,
+	"example": true, "examples": true, "command": true, "commands": true, "use": true, "using": true,
+}
+
+func queryTokens(query string) []string {
+	tokens := []string{}
+	for word := range wordSet(query) {
+		if !stopwords[word] {
+			tokens = append(tokens, word)
+		}
+	}
+	sort.Strings(tokens)
+	return tokens
+}
+
+// mnemonicBrackets strips tldr's option mnemonics: "E[x]tract" -> "Extract".
+var mnemonicBrackets = strings.NewReplacer("[", "", "]", "")
+
+// wordSet lowercases text into words, folding simple plurals and -ing/-ed
+// forms so "extracting" meets "Extract".
+func wordSet(text string) map[string]bool {
+	set := map[string]bool{}
+	text = mnemonicBrackets.Replace(strings.ToLower(text))
+	for _, word := range strings.FieldsFunc(text, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '.')
+	}) {
+		word = strings.Trim(word, ".-")
+		if word == "" {
+			continue
+		}
+		set[stem(word)] = true
+	}
+	return set
+}
+
+func stem(word string) string {
+	for _, suffix := range []string{"ing", "ed", "es", "s"} {
+		if len(word) > len(suffix)+2 && strings.HasSuffix(word, suffix) {
+			return strings.TrimSuffix(word, suffix)
+		}
+	}
+	return word
+}
@@ -0,0 +1,167 @@
+package tldr
+
+import (
+	"archive/zip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ashwch/ew/internal/appdirs"
+)
+
+const tarPage = `# tar
+
+> Archiving utility.
+> More information: <https://www.gnu.org/software/tar>.
+
+- [c]reate an archive and write it to a [f]ile:
+
+` + "`tar cf {{path/to/target.tar}} {{path/to/file1 path/to/file2 ...}}`" + `
+
+- E[x]tract a (compressed) archive [f]ile into the current directory [v]erbosely:
+
+` + "`tar xvf {{path/to/source.tar[.gz|.bz2|.xz]}}`" + `
+`
+
+func TestParsePageReadsExamplesAndSummary(t *testing.T) {
+	page := ParsePage("tar", "common", tarPage)
+	if page.Name != "tar" || page.Summary != "Archiving utility." {
+		t.Fatalf("unexpected page header %+v", page)
+	}
+	if len(page.Examples) != 2 {
+		t.Fatalf("expected 2 examples, got %+v", page.Examples)
+	}
+	if page.Examples[1].Command != "tar xvf {{path/to/source.tar[.gz|.bz2|.xz]}}" {
+		t.Fatalf("unexpected command %q", page.Examples[1].Command)
+	}
+	if got := Render(page.Examples[0].Command); got != "tar cf <path/to/target.tar> <path/to/file1 path/to/file2 ...>" {
+		t.Fatalf("unexpected render %q", got)
+	}
+	if got := Placeholders(page.Examples[0].Command); len(got) != 2 || got[0] != "path/to/target.tar" {
+		t.Fatalf("unexpected placeholders %v", got)
+	}
+}
+
+func TestSearchPrefersNamedPageAndPlatformExamples(t *testing.T) {
+	pages := []Page{
+		ParsePage("tar", "common", tarPage),
+		ParsePage("unzip", "common", "# unzip\n\n> Extract files from ZIP archives.\n\n- Extract all files:\n\n`unzip {{path/to/archive.zip}}`\n"),
+		ParsePage("ip", "linux", "# ip\n\n> Show network interfaces.\n\n- List interfaces with detailed info:\n\n`ip address`\n"),
+		ParsePage("ifconfig", "common", "# ifconfig\n\n> Network interface configurator.\n\n- List interfaces with detailed info:\n\n`ifconfig -a`\n"),
+	}
+	matches := Search(pages, "tar extract examples", 3)
+	if len(matches) == 0 || matches[0].Page != "tar" || matches[0].Command != "tar xvf {{path/to/source.tar[.gz|.bz2|.xz]}}" {
+		t.Fatalf("expected tar extract example first, got %+v", matches)
+	}
+	matches = Search(pages, "list network interfaces", 2)
+	if len(matches) != 2 || matches[0].Page != "ip" {
+		t.Fatalf("expected the linux page to win the tie, got %+v", matches)
+	}
+	if got := Search(pages, "the of a", 3); len(got) != 0 {
+		t.Fatalf("expected stopword-only query to match nothing, got %+v", got)
+	}
+}
+
+func useTempState(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, ".local", "state"))
+	dir, err := appdirs.StateDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestLoadPagesLetsPlatformOverrideCommon(t *testing.T) {
+	useTempState(t)
+	installArchive(t, map[string]string{
+		"common/sed.md": "# sed\n\n- Replace text:\n\n`sed 's/a/b/' {{file}}`\n",
+		"osx/sed.md":    "# sed\n\n- Replace text in place:\n\n`sed -i '' 's/a/b/' {{file}}`\n",
+		"linux/apt.md":  "# apt\n\n- Install a package:\n\n`apt install {{package}}`\n",
+	})
+
+	pages, err := LoadPages("osx")
+	if err != nil {
+		t.Fatalf("LoadPages failed: %v", err)
+	}
+	if len(pages) != 1 || pages[0].Platform != "osx" {
+		t.Fatalf("expected only the osx sed page, got %+v", pages)
+	}
+	pages, _ = LoadPages("linux")
+	if len(pages) != 2 || pages[0].Name != "apt" || pages[1].Platform != "common" {
+		t.Fatalf("expected apt and the common sed page, got %+v", pages)
+	}
+}
+
+func TestUpdateInstallsPagesFromArchive(t *testing.T) {
+	stateDir := useTempState(t)
+	legacy := filepath.Join(stateDir, legacyCacheDirName, "common", "old.md")
+	if err := os.MkdirAll(filepath.Dir(legacy), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(legacy, []byte("# old\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	archive := writeArchive(t, map[string]string{
+		"common/tar.md":          tarPage,
+		"pages/linux/apt.md":     "# apt\n",
+		"../escape.md":           "# nope\n",
+		"common/nested/deep.md":  "# nope\n",
+		"LICENSE.md":             "license",
+		"common/.hidden-page.md": "# nope\n",
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, archive)
+	}))
+	defer server.Close()
+
+	result, err := Update(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if result.Pages != 2 || result.Location != filepath.Join(stateDir, pagesFileName) {
+		t.Fatalf("expected 2 pages in the state backend, got %+v", result)
+	}
+	pages, err := LoadPages("linux")
+	if err != nil || len(pages) != 2 || pages[0].Name != "apt" || pages[1].Name != "tar" {
+		t.Fatalf("expected apt and tar installed, got %+v (%v)", pages, err)
+	}
+	if _, err := os.Stat(filepath.Join(stateDir, legacyCacheDirName)); !os.IsNotExist(err) {
+		t.Fatalf("expected the old unpacked pages to be removed, got %v", err)
+	}
+}
+
+func installArchive(t *testing.T, files map[string]string) {
+	t.Helper()
+	if _, err := Install(writeArchive(t, files)); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+}
+
+func writeArchive(t *testing.T, files map[string]string) string {
+	t.Helper()
+	archive := filepath.Join(t.TempDir(), "pages.zip")
+	file, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(file)
+	for name, body := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write([]byte(body))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	_ = file.Close()
+	return archive
+}
@@ -0,0 +1,135 @@
+package tldr
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/ashwch/ew/internal/appdirs"
+	"github.com/ashwch/ew/internal/state"
+)
+
+// DefaultArchiveURL is the English pages archive published with every
+// tldr-pages release.
+const DefaultArchiveURL = "https://github.com/tldr-pages/tldr/releases/latest/download/tldr-pages.en.zip"
+
+const maxArchiveBytes = 64 << 20
+
+// UpdateResult says what Update installed and where.
+type UpdateResult struct {
+	Location string
+	Pages    int
+}
+
+// Update downloads the pages archive from url and replaces the installed
+// pages with its markdown pages. The old pages stay in place until the new
+// ones are all parsed.
+func Update(ctx context.Context, url string) (UpdateResult, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return UpdateResult{}, fmt.Errorf("could not build tldr request: %w", err)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return UpdateResult{}, fmt.Errorf("could not download tldr pages: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return UpdateResult{}, fmt.Errorf("could not download tldr pages: %s", response.Status)
+	}
+
+	archive, err := os.CreateTemp("", "ew-tldr-*.zip")
+	if err != nil {
+		return UpdateResult{}, fmt.Errorf("could not create temp tldr archive: %w", err)
+	}
+	defer os.Remove(archive.Name())
+	written, err := io.Copy(archive, io.LimitReader(response.Body, maxArchiveBytes+1))
+	closeErr := archive.Close()
+	if err != nil {
+		return UpdateResult{}, fmt.Errorf("could not download tldr pages: %w", err)
+	}
+	if closeErr != nil {
+		return UpdateResult{}, fmt.Errorf("could not write temp tldr archive: %w", closeErr)
+	}
+	if written > maxArchiveBytes {
+		return UpdateResult{}, fmt.Errorf("tldr archive is larger than %d MB", maxArchiveBytes>>20)
+	}
+	return Install(archive.Name())
+}
+
+// Install parses the pages in a tldr-pages zip and saves them to the state
+// backend, replacing what was there.
+func Install(archivePath string) (UpdateResult, error) {
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return UpdateResult{}, fmt.Errorf("could not open tldr archive: %w", err)
+	}
+	defer reader.Close()
+
+	store := pageStore{Version: pagesVersion}
+	for _, file := range reader.File {
+		platform, name, ok := pagePath(file.Name)
+		if !ok {
+			continue
+		}
+		content, err := readPage(file)
+		if err != nil {
+			return UpdateResult{}, err
+		}
+		store.Pages = append(store.Pages, ParsePage(strings.TrimSuffix(name, ".md"), platform, content))
+	}
+	if len(store.Pages) == 0 {
+		return UpdateResult{}, fmt.Errorf("tldr archive has no pages")
+	}
+
+	payload, err := json.Marshal(store)
+	if err != nil {
+		return UpdateResult{}, fmt.Errorf("could not encode tldr pages: %w", err)
+	}
+	backend, err := state.Current()
+	if err != nil {
+		return UpdateResult{}, err
+	}
+	if err := backend.Write(pagesFileName, payload); err != nil {
+		return UpdateResult{}, fmt.Errorf("could not save tldr pages: %w", err)
+	}
+	// Pages unpacked by older versions are never read again.
+	if legacy, err := appdirs.StateFilePath(legacyCacheDirName); err == nil {
+		_ = os.RemoveAll(legacy)
+	}
+	return UpdateResult{Location: backend.Location(pagesFileName), Pages: len(store.Pages)}, nil
+}
+
+// pagePath accepts "<platform>/<name>.md", optionally under a pages/ prefix,
+// and rejects nested, hidden, or escaping paths.
+func pagePath(name string) (string, string, bool) {
+	parts := strings.Split(path.Clean(strings.TrimPrefix(name, "pages/")), "/")
+	if len(parts) != 2 || !strings.HasSuffix(parts[1], ".md") {
+		return "", "", false
+	}
+	for _, part := range parts {
+		if part == "" || part == "." || part == ".." || strings.HasPrefix(part, ".") {
+			return "", "", false
+		}
+	}
+	return parts[0], parts[1], true
+}
+
+func readPage(file *zip.File) (string, error) {
+	source, err := file.Open()
+	if err != nil {
+		return "", fmt.Errorf("could not read %s from tldr archive: %w", file.Name, err)
+	}
+	defer source.Close()
+	content, err := io.ReadAll(io.LimitReader(source, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("could not read %s from tldr archive: %w", file.Name, err)
+	}
+	return string(content), nil
+}
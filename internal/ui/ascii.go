@@ -0,0 +1,69 @@
+package ui
+
+import (
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+var asciiOnly atomic.Bool
+
+// SetASCIIOnly switches everything this package renders to 7-bit ASCII.
+// Interactive backends draw box and block characters, so ASCII mode also
+// resolves every backend to plain.
+func SetASCIIOnly(enabled bool) {
+	asciiOnly.Store(enabled)
+}
+
+// ASCIIOnly reports whether ASCII mode is on.
+func ASCIIOnly() bool {
+	return asciiOnly.Load()
+}
+
+// DumbTerminal reports TERM=dumb, which cannot draw anything but ASCII and
+// ignores cursor control.
+func DumbTerminal() bool {
+	return strings.EqualFold(strings.TrimSpace(os.Getenv("TERM")), "dumb")
+}
+
+var asciiReplacements = map[rune]string{
+	'‘': "'", '’': "'", '‚': "'", '‛': "'",
+	'“': `"`, '”': `"`, '„': `"`, '«': `"`, '»': `"`,
+	'‐': "-", '‑': "-", '‒': "-", '–': "-", '—': "--", '−': "-",
+	'…': "...", '•': "*", '·': "*", ' ': " ", ' ': " ", ' ': " ",
+	'→': "->", '←': "<-", '⇒': "=>", '↔': "<->",
+	'✓': "ok", '✔': "ok", '✗': "x", '✘': "x", '⚠': "!",
+	'×': "x", '≤': "<=", '≥': ">=", '≠': "!=",
+}
+
+// ASCII returns text as-is outside ASCII mode. In ASCII mode typographic
+// quotes, dashes, arrows, and check marks become their ASCII spelling, and
+// any other non-ASCII character becomes "?".
+func ASCII(text string) string {
+	if !ASCIIOnly() || isASCII(text) {
+		return text
+	}
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r < 0x80:
+			b.WriteRune(r)
+		case asciiReplacements[r] != "":
+			b.WriteString(asciiReplacements[r])
+		case r == '️' || r == '‍':
+			// Emoji presentation selectors and joiners have no glyph.
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+func isASCII(text string) bool {
+	for i := 0; i < len(text); i++ {
+		if text[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,44 @@
+package ui
+
+import "testing"
+
+func useASCIIOnly(t *testing.T) {
+	t.Helper()
+	SetASCIIOnly(true)
+	t.Cleanup(func() { SetASCIIOnly(false) })
+}
+
+func TestASCIITransliteratesOnlyInASCIIMode(t *testing.T) {
+	text := "“rm” → trash — safe ✓ 🚀"
+	if got := ASCII(text); got != text {
+		t.Fatalf("expected text unchanged outside ASCII mode, got %q", got)
+	}
+	useASCIIOnly(t)
+	if got, want := ASCII(text), `"rm" -> trash -- safe ok ?`; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestASCIIOnlyForcesPlainBackendAndSparkline(t *testing.T) {
+	useASCIIOnly(t)
+	if got := NormalizeBackend(BackendBubbleTea); got != BackendPlain {
+		t.Fatalf("expected plain backend in ASCII mode, got %q", got)
+	}
+	if IsInteractiveBackend(BackendAuto) {
+		t.Fatalf("expected no interactive backend in ASCII mode")
+	}
+	if got := Sparkline([]int64{0, 4, 8}); got != "_=@" {
+		t.Fatalf("unexpected ASCII sparkline %q", got)
+	}
+}
+
+func TestDumbTerminal(t *testing.T) {
+	t.Setenv("TERM", "dumb")
+	if !DumbTerminal() {
+		t.Fatalf("expected TERM=dumb to be detected")
+	}
+	t.Setenv("TERM", "xterm-256color")
+	if DumbTerminal() {
+		t.Fatalf("expected xterm not to be dumb")
+	}
+}
@@ -11,6 +11,9 @@ const (
 )
 
 func NormalizeBackend(backend string) string {
+	if ASCIIOnly() || !TUIBuilt {
+		return BackendPlain
+	}
 	switch strings.ToLower(strings.TrimSpace(backend)) {
 	case BackendAuto, "":
 		return BackendAuto
@@ -37,6 +40,9 @@ func IsInteractiveBackend(backend string) bool {
 }
 
 func backendCandidates(backend string) []string {
+	if ASCIIOnly() || !TUIBuilt {
+		return []string{BackendPlain}
+	}
 	switch NormalizeBackend(backend) {
 	case BackendBubbleTea:
 		return []string{BackendBubbleTea, BackendHuh, BackendTView}
@@ -0,0 +1,30 @@
+package ui
+
+import "github.com/ashwch/ew/internal/memory"
+
+// PickCandidates lets the person tick which bootstrap candidates to learn;
+// all start ticked. ok is false when no interactive backend ran; picked is
+// nil when the person cancelled.
+func PickCandidates(backend string, candidates []memory.Candidate) ([]memory.Candidate, bool, error) {
+	if len(candidates) == 0 {
+		return nil, true, nil
+	}
+	var firstErr error
+	for _, candidate := range backendCandidates(backend) {
+		if candidate == BackendPlain {
+			continue
+		}
+		picked, err := pickCandidatesWithHuh(candidates)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		return picked, true, nil
+	}
+	if firstErr != nil {
+		return nil, false, firstErr
+	}
+	return nil, false, nil
+}
@@ -0,0 +1,37 @@
+//go:build !ew_minimal
+
+package ui
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ashwch/ew/internal/memory"
+	"github.com/charmbracelet/huh"
+)
+
+func pickCandidatesWithHuh(candidates []memory.Candidate) ([]memory.Candidate, error) {
+	options := make([]huh.Option[int], 0, len(candidates))
+	chosen := make([]int, 0, len(candidates))
+	for idx, candidate := range candidates {
+		label := fmt.Sprintf("%s  ->  %s  (%d runs)", candidate.Query, candidate.Command, candidate.Runs)
+		options = append(options, huh.NewOption(label, idx).Selected(true))
+		chosen = append(chosen, idx)
+	}
+	field := huh.NewMultiSelect[int]().
+		Title("Learn these from your shell history?").
+		Description("space toggles, enter saves the ticked ones").
+		Options(options...).
+		Value(&chosen)
+	if err := huh.NewForm(huh.NewGroup(field)).WithTheme(huh.ThemeCharm()).Run(); err != nil {
+		if errors.Is(err, huh.ErrUserAborted) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	picked := make([]memory.Candidate, 0, len(chosen))
+	for _, idx := range chosen {
+		picked = append(picked, candidates[idx])
+	}
+	return picked, nil
+}
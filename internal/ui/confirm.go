@@ -1,29 +1,56 @@
 package ui
 
 import (
-	"errors"
-	"fmt"
 	"strings"
-
-	tea "github.com/charmbracelet/bubbletea"
-	"github.com/charmbracelet/huh"
-	"github.com/rivo/tview"
 )
 
-func ConfirmExecution(backend string, command string, risk string) (bool, bool, error) {
+// RiskReason is one reason a command got its risk level, such as "deletes
+// ./build". Tokens are the words of the command it is about; the
+// confirmation highlights them.
+type RiskReason struct {
+	Why    string   `json:"why"`
+	Tokens []string `json:"tokens,omitempty"`
+}
+
+// confirmation is what ConfirmExecution shows: the command, why it was
+// suggested, its risk label and the reasons for it, and extra lines such as
+// a plan summary.
+type confirmation struct {
+	command     string
+	explanation []string
+	risk        string
+	reasons     []RiskReason
+	details     []string
+}
+
+// ConfirmDecision is the answer to ConfirmExecution.
+type ConfirmDecision struct {
+	Approved bool
+	// Edited is the command the user rewrote it to with e in bubbletea; it
+	// is never approved, so the caller checks and confirms it again.
+	Edited string
+}
+
+// ConfirmExecution asks whether to run command. explanation is why it was
+// suggested, already laid out in lines by the caller, and is shown under
+// the command. The risky words of command named by reasons are
+// highlighted, and each reason is listed under the risk. details are extra
+// lines shown after them, such as a plan summary.
+func ConfirmExecution(backend string, command string, explanation []string, risk string, reasons []RiskReason, details []string) (ConfirmDecision, bool, error) {
+	c := confirmation{command: strings.TrimSpace(command), explanation: explanation, risk: strings.TrimSpace(risk), reasons: reasons, details: details}
 	var firstErr error
 	for _, candidate := range backendCandidates(backend) {
 		var (
-			approved bool
+			decision ConfirmDecision
 			err      error
 		)
 		switch candidate {
 		case BackendBubbleTea:
-			approved, err = confirmWithBubbleTea(command, risk)
+			decision, err = confirmWithBubbleTea(c)
 		case BackendHuh:
-			approved, err = confirmWithHuh(command, risk)
+			decision.Approved, err = confirmWithHuh(c)
 		case BackendTView:
-			approved, err = confirmWithTView(command, risk)
+			decision.Approved, err = confirmWithTView(c)
 		case BackendPlain:
 			continue
 		default:
@@ -35,103 +62,78 @@ func ConfirmExecution(backend string, command string, risk string) (bool, bool,
 			}
 			continue
 		}
-		return approved, true, nil
+		return decision, true, nil
 	}
 	if firstErr != nil {
-		return false, false, firstErr
+		return ConfirmDecision{}, false, firstErr
 	}
-	return false, false, nil
+	return ConfirmDecision{}, false, nil
 }
 
-type bubbleConfirmModel struct {
-	command  string
-	risk     string
-	approved bool
-	done     bool
-}
-
-func (m bubbleConfirmModel) Init() tea.Cmd { return nil }
-
-func (m bubbleConfirmModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	switch k := msg.(type) {
-	case tea.KeyMsg:
-		switch strings.ToLower(k.String()) {
-		case "y":
-			m.approved = true
-			m.done = true
-			return m, tea.Quit
-		case "n", "esc", "ctrl+c", "enter":
-			m.approved = false
-			m.done = true
-			return m, tea.Quit
-		}
+// body renders the confirmation with mark applied to the risky words of the
+// command and plain to all other text, so a backend can add its own color
+// and escaping.
+func (c confirmation) body(mark, plain func(string) string) string {
+	var tokens []string
+	for _, reason := range c.reasons {
+		tokens = append(tokens, reason.Tokens...)
 	}
-	return m, nil
-}
-
-func (m bubbleConfirmModel) View() string {
-	return fmt.Sprintf(
-		"Run this command?\n\n%s\n\nrisk: %s\n\n[y] run  [n] cancel",
-		m.command,
-		strings.TrimSpace(m.risk),
-	)
-}
-
-func confirmWithBubbleTea(command string, risk string) (bool, error) {
-	model := bubbleConfirmModel{command: strings.TrimSpace(command), risk: strings.TrimSpace(risk)}
-	final, err := tea.NewProgram(model, tea.WithAltScreen()).Run()
-	if err != nil {
-		return false, err
+	body := highlightTokens(c.command, tokens, mark, plain)
+	if len(c.explanation) > 0 {
+		body += "\n" + plain(strings.Join(c.explanation, "\n"))
 	}
-	out, ok := final.(bubbleConfirmModel)
-	if !ok {
-		return false, nil
+	body += "\n\n" + plain("risk: "+c.risk)
+	for _, reason := range c.reasons {
+		body += "\n" + plain("  why: "+reason.Why)
 	}
-	if !out.done {
-		return false, nil
+	if len(c.details) > 0 {
+		body += "\n\n" + plain(strings.Join(c.details, "\n"))
 	}
-	return out.approved, nil
+	return body
 }
 
-func confirmWithHuh(command string, risk string) (bool, error) {
-	approved := false
-	prompt := huh.NewConfirm().
-		Title("Run this command?").
-		Description(fmt.Sprintf("%s\nrisk: %s", strings.TrimSpace(command), strings.TrimSpace(risk))).
-		Affirmative("Run").
-		Negative("Cancel").
-		Value(&approved).
-		WithTheme(huh.ThemeCharm())
-	err := prompt.Run()
-	if err != nil {
-		if errors.Is(err, huh.ErrUserAborted) {
-			return false, nil
+// highlightTokens returns command with mark applied to each place a token
+// appears as a whole word and plain applied to the text in between. A
+// token is a whole word when it is set off by the start or end, a space, a
+// quote, =, or a shell operator, so "rm" is found in "rm -rf" but not in
+// "perform".
+func highlightTokens(command string, tokens []string, mark, plain func(string) string) string {
+	marked := make([]bool, len(command))
+	for _, token := range tokens {
+		if token == "" {
+			continue
+		}
+		for start := 0; start < len(command); {
+			idx := strings.Index(command[start:], token)
+			if idx < 0 {
+				break
+			}
+			idx += start
+			end := idx + len(token)
+			if (idx == 0 || isTokenBoundary(command[idx-1])) && (end == len(command) || isTokenBoundary(command[end])) {
+				for pos := idx; pos < end; pos++ {
+					marked[pos] = true
+				}
+			}
+			start = end
 		}
-		return false, err
 	}
-	return approved, nil
+	var out strings.Builder
+	for start := 0; start < len(command); {
+		end := start
+		for end < len(command) && marked[end] == marked[start] {
+			end++
+		}
+		if marked[start] {
+			out.WriteString(mark(command[start:end]))
+		} else {
+			out.WriteString(plain(command[start:end]))
+		}
+		start = end
+	}
+	return out.String()
 }
 
-func confirmWithTView(command string, risk string) (bool, error) {
-	app := tview.NewApplication()
-	approved := false
-	done := false
-
-	text := fmt.Sprintf("Run this command?\n\n%s\n\nrisk: %s", strings.TrimSpace(command), strings.TrimSpace(risk))
-	modal := tview.NewModal().
-		SetText(text).
-		AddButtons([]string{"Run", "Cancel"}).
-		SetDoneFunc(func(_ int, label string) {
-			done = true
-			approved = strings.EqualFold(strings.TrimSpace(label), "run")
-			app.Stop()
-		})
-
-	if err := app.SetRoot(modal, true).Run(); err != nil {
-		return false, err
-	}
-	if !done {
-		return false, nil
-	}
-	return approved, nil
+func isTokenBoundary(b byte) bool {
+	return strings.IndexByte(" \t\n'\"=;|&()<>", b) >= 0
 }
@@ -0,0 +1,47 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConfirmationBodyShowsTheReasonAndMarksRiskyWords(t *testing.T) {
+	mark := func(text string) string { return "<" + text + ">" }
+	c := confirmation{
+		command:     "rm -rf ./build && perform --force-with-lease",
+		explanation: []string{"reason: clears the build output"},
+		risk:        "high",
+		reasons: []RiskReason{
+			{Why: `high-risk pattern "rm -rf"`, Tokens: []string{"rm", "-rf"}},
+			{Why: "deletes ./build", Tokens: []string{"./build"}},
+			{Why: "overrides a safeguard: --force", Tokens: []string{"--force"}},
+		},
+		details: []string{"side effects:", "  deletes: ./build"},
+	}
+	body := c.body(mark, func(text string) string { return text })
+	want := strings.Join([]string{
+		"<rm> <-rf> <./build> && perform --force-with-lease",
+		"reason: clears the build output",
+		"",
+		"risk: high",
+		`  why: high-risk pattern "rm -rf"`,
+		"  why: deletes ./build",
+		"  why: overrides a safeguard: --force",
+		"",
+		"side effects:",
+		"  deletes: ./build",
+	}, "\n")
+	if body != want {
+		t.Fatalf("unexpected body:\n%s\nwant:\n%s", body, want)
+	}
+}
+
+func TestHighlightTokensEscapesTheRest(t *testing.T) {
+	got := highlightTokens(`ssh prod-db "[ -f x ] && reboot"`, []string{"prod-db", "reboot"},
+		func(text string) string { return "*" + text + "*" },
+		func(text string) string { return strings.ReplaceAll(text, "[", "[[") },
+	)
+	if want := `ssh *prod-db* "[[ -f x ] && *reboot*"`; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
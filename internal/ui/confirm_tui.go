@@ -0,0 +1,172 @@
+//go:build !ew_minimal
+
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/rivo/tview"
+)
+
+// riskyStyle marks the words of a command a risk reason is about.
+var riskyStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("9"))
+
+func markRisky(text string) string { return riskyStyle.Render(text) }
+
+func unstyled(text string) string { return text }
+
+// markRiskyTView is markRisky in tview's color tags.
+func markRiskyTView(text string) string { return "[red::b]" + tview.Escape(text) + "[-::-]" }
+
+// newCommandEditor is the one-line editor e opens, prefilled with command
+// and the cursor at its end.
+func newCommandEditor(command string) textinput.Model {
+	editor := textinput.New()
+	editor.Prompt = "edit> "
+	editor.CharLimit = 4096
+	editor.SetValue(command)
+	editor.CursorEnd()
+	return editor
+}
+
+type bubbleConfirmModel struct {
+	body     string
+	command  string
+	approved bool
+	done     bool
+	// editing is set while e has the command open in editor; edited is the
+	// command it was saved as.
+	editing bool
+	editor  textinput.Model
+	edited  string
+}
+
+func (m bubbleConfirmModel) Init() tea.Cmd { return nil }
+
+func (m bubbleConfirmModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	k, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	if m.editing {
+		return m.updateEditing(k)
+	}
+	switch strings.ToLower(k.String()) {
+	case "y":
+		m.approved = true
+		m.done = true
+		return m, tea.Quit
+	case "e":
+		m.editing = true
+		m.editor = newCommandEditor(m.command)
+		return m, m.editor.Focus()
+	case "n", "esc", "ctrl+c", "enter":
+		m.approved = false
+		m.done = true
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// updateEditing saves the edit on enter and drops it on esc. A saved edit
+// is not approved: the caller checks and confirms the new command again.
+func (m bubbleConfirmModel) updateEditing(k tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch k.String() {
+	case "ctrl+c":
+		m.done = true
+		return m, tea.Quit
+	case "esc":
+		m.editing = false
+		m.editor.Blur()
+		return m, nil
+	case "enter":
+		edited := strings.TrimSpace(m.editor.Value())
+		if edited == "" {
+			return m, nil
+		}
+		if edited == m.command {
+			// Nothing changed, so the question stands as it was.
+			m.editing = false
+			m.editor.Blur()
+			return m, nil
+		}
+		m.edited = edited
+		m.done = true
+		return m, tea.Quit
+	}
+	var cmd tea.Cmd
+	m.editor, cmd = m.editor.Update(k)
+	return m, cmd
+}
+
+func (m bubbleConfirmModel) View() string {
+	if m.editing {
+		return fmt.Sprintf("Edit the command, then check it again\n\n%s\n\n[enter] check  [esc] back", m.editor.View())
+	}
+	return fmt.Sprintf("Run this command?\n\n%s\n\n[y] run  [e] edit  [n] cancel", m.body)
+}
+
+func confirmWithBubbleTea(c confirmation) (ConfirmDecision, error) {
+	model := bubbleConfirmModel{body: c.body(markRisky, unstyled), command: c.command}
+	final, err := tea.NewProgram(model, tea.WithAltScreen()).Run()
+	if err != nil {
+		return ConfirmDecision{}, err
+	}
+	out, ok := final.(bubbleConfirmModel)
+	if !ok {
+		return ConfirmDecision{}, nil
+	}
+	if !out.done {
+		return ConfirmDecision{}, nil
+	}
+	return ConfirmDecision{Approved: out.approved, Edited: out.edited}, nil
+}
+
+func confirmWithHuh(c confirmation) (bool, error) {
+	approved := false
+	prompt := huh.NewConfirm().
+		Title("Run this command?").
+		Description(c.body(markRisky, unstyled)).
+		Affirmative("Run").
+		Negative("Cancel").
+		Value(&approved).
+		WithTheme(huh.ThemeCharm())
+	err := prompt.Run()
+	if err != nil {
+		if errors.Is(err, huh.ErrUserAborted) {
+			return false, nil
+		}
+		return false, err
+	}
+	return approved, nil
+}
+
+func confirmWithTView(c confirmation) (bool, error) {
+	app := tview.NewApplication()
+	approved := false
+	done := false
+
+	text := "Run this command?\n\n" + c.body(markRiskyTView, tview.Escape)
+	modal := tview.NewModal().
+		SetText(text).
+		AddButtons([]string{"Run", "Cancel"}).
+		SetDoneFunc(func(_ int, label string) {
+			done = true
+			approved = strings.EqualFold(strings.TrimSpace(label), "run")
+			app.Stop()
+		})
+
+	if err := app.SetRoot(modal, true).Run(); err != nil {
+		return false, err
+	}
+	if !done {
+		return false, nil
+	}
+	return approved, nil
+}
@@ -0,0 +1,33 @@
+//go:build !ew_minimal
+
+package ui
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestBubbleConfirmEditLeavesTheRewriteUnapproved(t *testing.T) {
+	var model tea.Model = bubbleConfirmModel{command: "rm -rf ./build"}
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("e")})
+	for range len("./build") {
+		model, _ = model.Update(tea.KeyMsg{Type: tea.KeyBackspace})
+	}
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("./build/cache")})
+	// y while editing is text, not an answer.
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyBackspace})
+	model, cmd := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	out := model.(bubbleConfirmModel)
+	if cmd == nil || !out.done || out.approved || out.edited != "rm -rf ./build/cache" {
+		t.Fatalf("expected an unapproved rewrite, got approved=%v edited=%q", out.approved, out.edited)
+	}
+
+	model = bubbleConfirmModel{command: "ls"}
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("e")})
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if out := model.(bubbleConfirmModel); out.done || out.editing {
+		t.Fatalf("expected an unchanged edit to return to the question")
+	}
+}
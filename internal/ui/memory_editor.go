@@ -0,0 +1,27 @@
+package ui
+
+import "github.com/ashwch/ew/internal/memory"
+
+// EditMemory opens the interactive memory manager on a copy of store. It
+// returns the edited store and saved=true only when the user saved; used is
+// false when no interactive backend could render.
+func EditMemory(backend string, store memory.Store) (memory.Store, bool, bool, error) {
+	var firstErr error
+	for _, candidate := range backendCandidates(backend) {
+		if candidate != BackendBubbleTea {
+			continue
+		}
+		edited, saved, err := editMemoryWithBubbleTea(store)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if !saved {
+			return store, false, true, nil
+		}
+		return edited, true, true, nil
+	}
+	return store, false, false, firstErr
+}
@@ -0,0 +1,370 @@
+//go:build !ew_minimal
+
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ashwch/ew/internal/memory"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const memoryEditorDefaultRows = 12
+
+type memoryEditField string
+
+const (
+	memoryEditNone    memoryEditField = ""
+	memoryEditQuery   memoryEditField = "query"
+	memoryEditCommand memoryEditField = "command"
+)
+
+type memoryEditorModel struct {
+	store     memory.Store
+	filter    string
+	searching bool
+	cursor    int
+	offset    int
+	rows      int
+	selected  map[string]bool
+	editing   memoryEditField
+	editKey   string
+	input     textinput.Model
+	dirty     bool
+	confirmQ  bool
+	saved     bool
+	status    string
+}
+
+func editMemoryWithBubbleTea(store memory.Store) (memory.Store, bool, error) {
+	final, err := tea.NewProgram(newMemoryEditorModel(store), tea.WithAltScreen()).Run()
+	if err != nil {
+		return store, false, err
+	}
+	result, ok := final.(memoryEditorModel)
+	if !ok || !result.saved {
+		return store, false, nil
+	}
+	return result.store, true, nil
+}
+
+func newMemoryEditorModel(store memory.Store) memoryEditorModel {
+	entries := make([]memory.Entry, len(store.Entries))
+	copy(entries, store.Entries)
+	input := textinput.New()
+	input.Prompt = "> "
+	input.CharLimit = 4096
+	return memoryEditorModel{
+		store:    memory.Store{Entries: entries},
+		rows:     memoryEditorDefaultRows,
+		selected: map[string]bool{},
+		input:    input,
+	}
+}
+
+func (m memoryEditorModel) Init() tea.Cmd { return nil }
+
+func (m memoryEditorModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		// Title, hints, status and card padding take roughly ten lines.
+		if rows := msg.Height - 10; rows > 3 {
+			m.rows = rows
+		}
+		return m, nil
+	case tea.KeyMsg:
+		if m.editing != memoryEditNone {
+			return m.updateEditing(msg)
+		}
+		if m.searching {
+			return m.updateSearch(msg), nil
+		}
+		return m.updateList(msg)
+	}
+	return m, nil
+}
+
+func (m memoryEditorModel) updateList(k tea.KeyMsg) (tea.Model, tea.Cmd) {
+	key := k.String()
+	if key != "q" {
+		m.confirmQ = false
+	}
+	m.status = ""
+	visible := m.visible()
+	switch key {
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case "down", "j":
+		if m.cursor < len(visible)-1 {
+			m.cursor++
+		}
+	case "pgup":
+		m.cursor -= m.rows
+	case "pgdown":
+		m.cursor += m.rows
+	case "home":
+		m.cursor = 0
+	case "end":
+		m.cursor = len(visible) - 1
+	case "/":
+		m.searching = true
+	case " ", "x":
+		if entry, ok := m.current(); ok {
+			if m.selected[entry.Key()] {
+				delete(m.selected, entry.Key())
+			} else {
+				m.selected[entry.Key()] = true
+			}
+		}
+	case "a":
+		allSelected := len(visible) > 0
+		for _, idx := range visible {
+			if !m.selected[m.store.Entries[idx].Key()] {
+				allSelected = false
+				break
+			}
+		}
+		for _, idx := range visible {
+			if allSelected {
+				delete(m.selected, m.store.Entries[idx].Key())
+			} else {
+				m.selected[m.store.Entries[idx].Key()] = true
+			}
+		}
+	case "+", "=":
+		m.applyToTargets("promoted", func(entry memory.Entry) {
+			_ = m.store.Promote(entry.Query, entry.Command)
+		})
+	case "-":
+		m.applyToTargets("demoted", func(entry memory.Entry) {
+			_ = m.store.Demote(entry.Query, entry.Command)
+		})
+	case "d", "delete":
+		m.applyToTargets("deleted", func(entry memory.Entry) {
+			m.store.Delete(entry.Query, entry.Command)
+		})
+	case "e", "c":
+		entry, ok := m.current()
+		if !ok {
+			break
+		}
+		m.editing = memoryEditQuery
+		m.input.SetValue(entry.Query)
+		if key == "c" {
+			m.editing = memoryEditCommand
+			m.input.SetValue(entry.Command)
+		}
+		m.editKey = entry.Key()
+		m.input.CursorEnd()
+		return m, m.input.Focus()
+	case "s", "ctrl+s":
+		m.saved = true
+		return m, tea.Quit
+	case "q", "esc", "ctrl+c":
+		if m.dirty && !m.confirmQ && key != "ctrl+c" {
+			m.confirmQ = true
+			m.status = "unsaved changes: press q again to discard, s to save"
+			break
+		}
+		return m, tea.Quit
+	}
+	m.clampCursor()
+	return m, nil
+}
+
+func (m memoryEditorModel) updateSearch(k tea.KeyMsg) memoryEditorModel {
+	switch k.Type {
+	case tea.KeyEnter:
+		m.searching = false
+	case tea.KeyEsc:
+		m.searching = false
+		m.filter = ""
+	case tea.KeyBackspace:
+		if runes := []rune(m.filter); len(runes) > 0 {
+			m.filter = string(runes[:len(runes)-1])
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		m.filter += string(k.Runes)
+	}
+	m.cursor = 0
+	m.offset = 0
+	return m
+}
+
+func (m memoryEditorModel) updateEditing(k tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch k.Type {
+	case tea.KeyEsc:
+		m.editing = memoryEditNone
+		m.input.Blur()
+		return m, nil
+	case tea.KeyEnter:
+		m.commitEdit()
+		m.input.Blur()
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(k)
+	return m, cmd
+}
+
+func (m *memoryEditorModel) commitEdit() {
+	field := m.editing
+	m.editing = memoryEditNone
+	for _, entry := range m.store.Entries {
+		if entry.Key() != m.editKey {
+			continue
+		}
+		query, command := entry.Query, entry.Command
+		if field == memoryEditQuery {
+			query = m.input.Value()
+		} else {
+			command = m.input.Value()
+		}
+		if err := m.store.Edit(entry.Query, entry.Command, query, command); err != nil {
+			m.status = fmt.Sprintf("not changed: %v", err)
+			return
+		}
+		if m.selected[m.editKey] {
+			delete(m.selected, m.editKey)
+			m.selected[memory.Entry{Query: query, Command: command}.Key()] = true
+		}
+		m.dirty = true
+		m.status = "edited " + string(field)
+		return
+	}
+}
+
+// applyToTargets runs action on the selected entries, or on the entry under
+// the cursor when nothing is selected.
+func (m *memoryEditorModel) applyToTargets(verb string, action func(memory.Entry)) {
+	targets := []memory.Entry{}
+	for _, entry := range m.store.Entries {
+		if m.selected[entry.Key()] {
+			targets = append(targets, entry)
+		}
+	}
+	if len(targets) == 0 {
+		if entry, ok := m.current(); ok {
+			targets = append(targets, entry)
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+	for _, entry := range targets {
+		action(entry)
+	}
+	if verb == "deleted" {
+		for _, entry := range targets {
+			delete(m.selected, entry.Key())
+		}
+	}
+	m.dirty = true
+	m.status = fmt.Sprintf("%s %d entr%s", verb, len(targets), pluralY(len(targets)))
+}
+
+func (m memoryEditorModel) visible() []int {
+	needle := strings.ToLower(strings.TrimSpace(m.filter))
+	out := make([]int, 0, len(m.store.Entries))
+	for idx, entry := range m.store.Entries {
+		if needle == "" ||
+			strings.Contains(strings.ToLower(entry.Query), needle) ||
+			strings.Contains(strings.ToLower(entry.Command), needle) {
+			out = append(out, idx)
+		}
+	}
+	return out
+}
+
+func (m memoryEditorModel) current() (memory.Entry, bool) {
+	visible := m.visible()
+	if m.cursor < 0 || m.cursor >= len(visible) {
+		return memory.Entry{}, false
+	}
+	return m.store.Entries[visible[m.cursor]], true
+}
+
+func (m *memoryEditorModel) clampCursor() {
+	total := len(m.visible())
+	if m.cursor >= total {
+		m.cursor = total - 1
+	}
+	if m.cursor < 0 {
+		m.cursor = 0
+	}
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+m.rows {
+		m.offset = m.cursor - m.rows + 1
+	}
+}
+
+func (m memoryEditorModel) View() string {
+	visible := m.visible()
+	header := fmt.Sprintf("%d entries", len(m.store.Entries))
+	if m.filter != "" || m.searching {
+		header = fmt.Sprintf("%d of %d entries match %q", len(visible), len(m.store.Entries), m.filter)
+	}
+	if count := len(m.selected); count > 0 {
+		header += fmt.Sprintf(", %d selected", count)
+	}
+	lines := []string{
+		onboardingTitleStyle.Render("ew memory"),
+		onboardingHintStyle.Render(header),
+		"",
+	}
+	if len(visible) == 0 {
+		lines = append(lines, onboardingSubtleStyle.Render("No memory entries."))
+	} else {
+		lines = append(lines, onboardingSubtleStyle.Render(fmt.Sprintf("%6s%5s %4s  %s", "", "score", "uses", "query  ->  command")))
+	}
+	end := m.offset + m.rows
+	if end > len(visible) {
+		end = len(visible)
+	}
+	for row := m.offset; row < end; row++ {
+		entry := m.store.Entries[visible[row]]
+		pointer := "  "
+		if row == m.cursor {
+			pointer = onboardingMarkStyle.Render("> ")
+		}
+		mark := "[ ]"
+		if m.selected[entry.Key()] {
+			mark = "[x]"
+		}
+		line := fmt.Sprintf("%s%s %5.1f %4d  %s  ->  %s", pointer, mark, entry.Score, entry.Uses, entry.Query, entry.Command)
+		if row == m.cursor {
+			line = onboardingSummaryStyle.Render(line)
+		}
+		lines = append(lines, line)
+	}
+
+	lines = append(lines, "")
+	switch {
+	case m.editing != memoryEditNone:
+		lines = append(lines, onboardingSectionStyle.Render("edit "+string(m.editing)), m.input.View())
+		lines = append(lines, onboardingHintStyle.Render("[enter] apply  [esc] cancel"))
+	case m.searching:
+		lines = append(lines, "/"+m.filter)
+		lines = append(lines, onboardingHintStyle.Render("[enter] keep filter  [esc] clear"))
+	default:
+		lines = append(lines, onboardingHintStyle.Render("[/] search  [space] select  [a] select all  [e] edit query  [c] edit command"))
+		lines = append(lines, onboardingHintStyle.Render("[+] promote  [-] demote  [d] delete  [s] save  [q] quit"))
+	}
+	if m.status != "" {
+		lines = append(lines, onboardingBodyStyle.Render(m.status))
+	}
+	return onboardingCardStyle.Render(strings.Join(lines, "\n"))
+}
+
+func pluralY(count int) string {
+	if count == 1 {
+		return "y"
+	}
+	return "ies"
+}
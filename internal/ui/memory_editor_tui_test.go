@@ -0,0 +1,87 @@
+//go:build !ew_minimal
+
+package ui
+
+import (
+	"testing"
+
+	"github.com/ashwch/ew/internal/memory"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func memoryEditorKeys(t *testing.T, m memoryEditorModel, keys ...string) memoryEditorModel {
+	t.Helper()
+	for _, key := range keys {
+		var msg tea.KeyMsg
+		switch key {
+		case "enter":
+			msg = tea.KeyMsg{Type: tea.KeyEnter}
+		case "esc":
+			msg = tea.KeyMsg{Type: tea.KeyEsc}
+		case "backspace":
+			msg = tea.KeyMsg{Type: tea.KeyBackspace}
+		case " ":
+			msg = tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(" ")}
+		default:
+			msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+		}
+		next, _ := m.Update(msg)
+		m = next.(memoryEditorModel)
+	}
+	return m
+}
+
+func testMemoryStore(t *testing.T) memory.Store {
+	t.Helper()
+	store := memory.Store{}
+	for _, pair := range [][2]string{
+		{"push branch", "git push origin HEAD"},
+		{"list pods", "kubectl get pods"},
+		{"clear aws vault", "aws sso logout"},
+	} {
+		if err := store.Remember(pair[0], pair[1]); err != nil {
+			t.Fatalf("remember failed: %v", err)
+		}
+	}
+	return store
+}
+
+func TestMemoryEditorSearchAndBulkDelete(t *testing.T) {
+	m := newMemoryEditorModel(testMemoryStore(t))
+	m = memoryEditorKeys(t, m, "/", "p", "enter")
+	if got := len(m.visible()); got != 2 {
+		t.Fatalf("expected filter to match two entries, got %d", got)
+	}
+	m = memoryEditorKeys(t, m, "a", "d")
+	if len(m.store.Entries) != 1 || m.store.Entries[0].Command != "aws sso logout" {
+		t.Fatalf("expected selected entries to be deleted, got %+v", m.store.Entries)
+	}
+	if !m.dirty || len(m.selected) != 0 {
+		t.Fatalf("expected dirty editor with cleared selection")
+	}
+}
+
+func TestMemoryEditorInlineCommandEdit(t *testing.T) {
+	m := newMemoryEditorModel(testMemoryStore(t))
+	m = memoryEditorKeys(t, m, "/", "l", "i", "s", "t", "enter", "c", " ", "-", "A", "enter")
+	entry, ok := m.current()
+	if !ok || entry.Command != "kubectl get pods -A" {
+		t.Fatalf("expected edited command, got %+v", entry)
+	}
+}
+
+func TestMemoryEditorQuitAsksBeforeDiscarding(t *testing.T) {
+	m := newMemoryEditorModel(testMemoryStore(t))
+	m = memoryEditorKeys(t, m, "d")
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")})
+	m = next.(memoryEditorModel)
+	if cmd != nil || !m.confirmQ {
+		t.Fatalf("expected first q to warn about unsaved changes")
+	}
+	if _, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")}); cmd == nil {
+		t.Fatalf("expected second q to quit")
+	}
+	if m.saved {
+		t.Fatalf("expected discard to leave saved unset")
+	}
+}
@@ -1,3 +1,5 @@
+//go:build !ew_minimal
+
 package ui
 
 import (
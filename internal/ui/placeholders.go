@@ -0,0 +1,35 @@
+package ui
+
+// Placeholder is one <name> in a cheat command. Hint says where values come
+// from, e.g. the navi variable command; it is only displayed.
+type Placeholder struct {
+	Name string
+	Hint string
+}
+
+// FillPlaceholders asks for a value for each placeholder in one form. ok is
+// false when no interactive backend ran; values is nil when the person
+// cancelled.
+func FillPlaceholders(backend string, command string, placeholders []Placeholder) (map[string]string, bool, error) {
+	if len(placeholders) == 0 {
+		return map[string]string{}, true, nil
+	}
+	var firstErr error
+	for _, candidate := range backendCandidates(backend) {
+		if candidate != BackendHuh {
+			continue
+		}
+		values, err := fillPlaceholdersWithHuh(command, placeholders)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		return values, true, nil
+	}
+	if firstErr != nil {
+		return nil, false, firstErr
+	}
+	return nil, false, nil
+}
@@ -0,0 +1,43 @@
+//go:build !ew_minimal
+
+package ui
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+)
+
+func fillPlaceholdersWithHuh(command string, placeholders []Placeholder) (map[string]string, error) {
+	answers := make([]string, len(placeholders))
+	fields := make([]huh.Field, 0, len(placeholders)+1)
+	fields = append(fields, huh.NewNote().Title("Fill in the command").Description(strings.TrimSpace(command)))
+	for idx, placeholder := range placeholders {
+		input := huh.NewInput().
+			Title(placeholder.Name).
+			Value(&answers[idx]).
+			Validate(func(value string) error {
+				if strings.TrimSpace(value) == "" {
+					return errors.New("a value is required")
+				}
+				return nil
+			})
+		if hint := strings.TrimSpace(placeholder.Hint); hint != "" {
+			input = input.Description("values from: " + hint)
+		}
+		fields = append(fields, input)
+	}
+	err := huh.NewForm(huh.NewGroup(fields...)).WithTheme(huh.ThemeCharm()).Run()
+	if err != nil {
+		if errors.Is(err, huh.ErrUserAborted) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	values := make(map[string]string, len(placeholders))
+	for idx, placeholder := range placeholders {
+		values[placeholder.Name] = strings.TrimSpace(answers[idx])
+	}
+	return values, nil
+}
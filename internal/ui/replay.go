@@ -0,0 +1,82 @@
+package ui
+
+import "strings"
+
+// ReplayStep is one page of a pager: a recorded interaction for
+// ReplaySession or a dashboard section for ShowDashboard.
+type ReplayStep struct {
+	Title string
+	Lines []string
+}
+
+// ReplaySession pages through recorded interactions. It returns used=false
+// when no interactive backend could render, so callers can print instead.
+func ReplaySession(backend string, steps []ReplayStep) (bool, error) {
+	return runPager(backend, "ew session replay", steps)
+}
+
+// ShowDashboard pages through usage dashboard sections, one per page.
+func ShowDashboard(backend string, sections []ReplayStep) (bool, error) {
+	return runPager(backend, "ew top", sections)
+}
+
+// ShowExplanation pages through an explained command: its part-by-part
+// breakdown first, then anything else worth knowing about it.
+func ShowExplanation(backend string, pages []ReplayStep) (bool, error) {
+	return runPager(backend, "ew explain", pages)
+}
+
+func runPager(backend string, title string, steps []ReplayStep) (bool, error) {
+	if len(steps) == 0 {
+		return false, nil
+	}
+
+	var firstErr error
+	for _, candidate := range backendCandidates(backend) {
+		if candidate != BackendBubbleTea {
+			continue
+		}
+		if err := pagerWithBubbleTea(title, steps); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		return true, nil
+	}
+	if firstErr != nil {
+		return false, firstErr
+	}
+	return false, nil
+}
+
+var (
+	sparklineLevels      = []rune("▁▂▃▄▅▆▇█")
+	asciiSparklineLevels = []rune("_.-=+*#@")
+)
+
+// Sparkline draws values as a one-line bar chart scaled to their maximum.
+func Sparkline(values []int64) string {
+	sparklineLevels := sparklineLevels
+	if ASCIIOnly() {
+		sparklineLevels = asciiSparklineLevels
+	}
+	var maxValue int64
+	for _, value := range values {
+		if value > maxValue {
+			maxValue = value
+		}
+	}
+	if maxValue == 0 {
+		return strings.Repeat(string(sparklineLevels[0]), len(values))
+	}
+	var b strings.Builder
+	for _, value := range values {
+		level := int(value * int64(len(sparklineLevels)-1) / maxValue)
+		if level < 0 {
+			level = 0
+		}
+		b.WriteRune(sparklineLevels[level])
+	}
+	return b.String()
+}
@@ -0,0 +1,12 @@
+package ui
+
+import "testing"
+
+func TestSparklineScalesToMaximum(t *testing.T) {
+	if got := Sparkline([]int64{0, 4, 8}); got != "▁▄█" {
+		t.Fatalf("unexpected sparkline %q", got)
+	}
+	if got := Sparkline([]int64{0, 0}); got != "▁▁" {
+		t.Fatalf("expected flat sparkline for zeros, got %q", got)
+	}
+}
@@ -0,0 +1,63 @@
+//go:build !ew_minimal
+
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type replayModel struct {
+	title string
+	steps []ReplayStep
+	index int
+}
+
+func pagerWithBubbleTea(title string, steps []ReplayStep) error {
+	_, err := tea.NewProgram(replayModel{title: title, steps: steps}, tea.WithAltScreen()).Run()
+	return err
+}
+
+func (m replayModel) Init() tea.Cmd { return nil }
+
+func (m replayModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	k, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	switch strings.ToLower(k.String()) {
+	case "right", "l", "n", " ", "enter":
+		if m.index < len(m.steps)-1 {
+			m.index++
+		}
+	case "left", "h", "p":
+		if m.index > 0 {
+			m.index--
+		}
+	case "home":
+		m.index = 0
+	case "end":
+		m.index = len(m.steps) - 1
+	case "q", "esc", "ctrl+c":
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+func (m replayModel) View() string {
+	step := m.steps[m.index]
+	lines := []string{
+		onboardingTitleStyle.Render(m.title),
+		onboardingHintStyle.Render(fmt.Sprintf("page %d of %d", m.index+1, len(m.steps))),
+		"",
+		onboardingSectionStyle.Render(step.Title),
+	}
+	for _, line := range step.Lines {
+		lines = append(lines, onboardingSummaryStyle.Render(line))
+	}
+	lines = append(lines, "")
+	lines = append(lines, onboardingHintStyle.Render("[n/→] next  [p/←] previous  [q] quit"))
+	return onboardingCardStyle.Render(strings.Join(lines, "\n"))
+}
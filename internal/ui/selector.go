@@ -1,34 +1,176 @@
 package ui
 
 import (
-	"errors"
+	"cmp"
+	"context"
 	"fmt"
+	"math"
+	"slices"
 	"strings"
 
 	"github.com/ashwch/ew/internal/history"
-	"github.com/charmbracelet/bubbles/list"
-	tea "github.com/charmbracelet/bubbletea"
-	"github.com/charmbracelet/huh"
-	"github.com/rivo/tview"
 )
 
 type Selection struct {
 	Command string
 	Reason  string
 	Source  string
+	// Alternative is the original command when Command is a safer rewrite;
+	// the picker lists it directly below the recommendation.
+	Alternative string
+	// Rerank notes which history match a provider's pick displaced, such
+	// as "AI promoted #3 over #1"; the picker can then show the history
+	// order too.
+	Rerank string
+	// Provisional marks a local pick shown while a provider is still
+	// ranking; the picker labels it "[for now]".
+	Provisional bool
+	// EditedFrom is the listed command when the user rewrote it with e in
+	// the bubbletea picker; Command is then the rewrite.
+	EditedFrom string
+}
+
+// Revision is a provider's answer for a picker that opened on a
+// provisional recommendation. The bubbletea picker swaps it in when it
+// arrives; other pickers wait for it before they open.
+type Revision struct {
+	done      chan struct{}
+	selection Selection
+	ok        bool
+}
+
+func NewRevision() *Revision {
+	return &Revision{done: make(chan struct{})}
+}
+
+// Resolve delivers the answer. ok false means the provider had nothing
+// better, and the provisional pick becomes the recommendation. Only the
+// first call counts.
+func (r *Revision) Resolve(selection Selection, ok bool) {
+	select {
+	case <-r.done:
+		return
+	default:
+	}
+	r.selection, r.ok = selection, ok
+	close(r.done)
+}
+
+// Wait blocks until Resolve is called.
+func (r *Revision) Wait() (Selection, bool) {
+	<-r.done
+	return r.selection, r.ok
+}
+
+// Resolved reports whether Resolve has been called.
+func (r *Revision) Resolved() bool {
+	select {
+	case <-r.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// revise is the recommendation once revision is resolved: its answer, or
+// suggested no longer provisional.
+func revise(suggested Selection, revision *Revision) Selection {
+	if selection, ok := revision.Wait(); ok {
+		return selection
+	}
+	suggested.Provisional = false
+	return suggested
+}
+
+// LiveUpdates reports whether the picker for backend can show a
+// provisional recommendation and swap in a Revision while it is open.
+func LiveUpdates(backend string) bool {
+	candidates := backendCandidates(backend)
+	return len(candidates) > 0 && candidates[0] == BackendBubbleTea
 }
 
 type selectorOption struct {
 	Label     string
 	Selection Selection
+	// Rank is the option's place in the history ranking, from 1; 0 when it
+	// is not a history match.
+	Rank int
+}
+
+// moreCommand is the picker value of the "show more" entry; it can never be
+// a real command.
+const moreCommand = "\x00more"
+
+var moreOption = selectorOption{
+	Label:     "[more] show more history matches",
+	Selection: Selection{Command: moreCommand},
 }
 
+// CompareFunc briefly says how candidate differs from the recommended
+// command and which one fits the query better.
+type CompareFunc func(ctx context.Context, candidate string) (string, error)
+
+// compareLines is the room the bubbletea picker keeps below the list for a
+// comparison, and reasonLines the room for the highlighted option's reason.
+const (
+	compareLines = 4
+	reasonLines  = 2
+)
+
 func SelectSuggestedCommand(backend string, query string, suggested Selection, matches []history.Match) (Selection, bool, error) {
-	options := buildSelectionOptions(suggested, matches)
-	if len(options) < 2 {
-		return Selection{}, false, nil
+	return SelectSuggestedCommandPaged(backend, query, suggested, matches, nil, nil, nil)
+}
+
+// SelectSuggestedCommandPaged is SelectSuggestedCommand with a "show more"
+// entry (and the m key in bubbletea) that appends the history matches more
+// returns. The entry goes away once more returns nothing. With compare set,
+// the c key in bubbletea shows how the highlighted candidate differs from
+// the recommendation, and e edits the highlighted command before it is
+// picked; see Selection.EditedFrom. With revision set, suggested is provisional until the
+// revision is resolved; see Revision.
+func SelectSuggestedCommandPaged(backend string, query string, suggested Selection, matches []history.Match, more func() []history.Match, compare CompareFunc, revision *Revision) (Selection, bool, error) {
+	if revision != nil && !LiveUpdates(backend) {
+		suggested, revision = revise(suggested, revision), nil
+	}
+	for {
+		if revision != nil && revision.Resolved() {
+			suggested, revision = revise(suggested, revision), nil
+		}
+		options := buildSelectionOptions(suggested, matches)
+		if len(options) < 2 {
+			return Selection{}, false, nil
+		}
+		if more != nil {
+			options = append(options, moreOption)
+		}
+		rebuild := func(revised Selection) []selectorOption {
+			options := buildSelectionOptions(revised, matches)
+			if more != nil {
+				options = append(options, moreOption)
+			}
+			return options
+		}
+		selected, used, err := runSelector(backend, query, options, strings.TrimSpace(suggested.Command), compare, liveRevision{revision: revision, rebuild: rebuild})
+		if err != nil || !used || selected.Command != moreCommand {
+			return selected, used, err
+		}
+		next := more()
+		if len(next) == 0 {
+			more = nil
+			continue
+		}
+		matches = append(append([]history.Match{}, matches...), next...)
 	}
+}
+
+// liveRevision lets an open bubbletea picker rebuild its options around a
+// revised recommendation.
+type liveRevision struct {
+	revision *Revision
+	rebuild  func(Selection) []selectorOption
+}
 
+func runSelector(backend string, query string, options []selectorOption, recommended string, compare CompareFunc, live liveRevision) (Selection, bool, error) {
 	var firstErr error
 	for _, candidate := range backendCandidates(backend) {
 		var (
@@ -38,7 +180,7 @@ func SelectSuggestedCommand(backend string, query string, suggested Selection, m
 		)
 		switch candidate {
 		case BackendBubbleTea:
-			selected, used, err = selectWithBubbleTea(query, options)
+			selected, used, err = selectWithBubbleTea(query, options, recommended, compare, live)
 		case BackendHuh:
 			selected, used, err = selectWithHuh(query, options)
 		case BackendTView:
@@ -68,7 +210,14 @@ func buildSelectionOptions(suggested Selection, matches []history.Match) []selec
 	options := make([]selectorOption, 0, len(matches)+1)
 	seen := map[string]struct{}{}
 
-	add := func(sel Selection, labelPrefix string) {
+	ranks := map[string]int{}
+	for idx, match := range matches {
+		key := strings.ToLower(strings.TrimSpace(match.Command))
+		if _, ok := ranks[key]; !ok {
+			ranks[key] = idx + 1
+		}
+	}
+	add := func(sel Selection, labelPrefix, labelSuffix string) {
 		command := strings.TrimSpace(sel.Command)
 		if command == "" {
 			return
@@ -79,238 +228,68 @@ func buildSelectionOptions(suggested Selection, matches []history.Match) []selec
 		}
 		seen[key] = struct{}{}
 		options = append(options, selectorOption{
-			Label:     labelPrefix + command,
+			Label:     labelPrefix + command + labelSuffix,
 			Selection: sel,
+			Rank:      ranks[key],
 		})
 	}
 
 	if strings.TrimSpace(suggested.Command) != "" {
-		add(suggested, "[recommended] ")
+		suffix := ""
+		if suggested.Rerank != "" {
+			suffix = "  (" + suggested.Rerank + ")"
+		}
+		prefix := "[recommended] "
+		if suggested.Provisional {
+			prefix, suffix = "[for now] ", "  (still ranking...)"
+		}
+		add(suggested, prefix, suffix)
+		if strings.TrimSpace(suggested.Alternative) != "" {
+			add(Selection{
+				Command: suggested.Alternative,
+				Reason:  "original command without the safer rewrite",
+				Source:  suggested.Source,
+			}, "[original] ", "")
+		}
 	}
 
 	for _, match := range matches {
+		reason := fmt.Sprintf("history match score %.2f", match.Score)
+		if breakdown := match.Breakdown(); breakdown != "" {
+			reason += " (" + breakdown + ")"
+		}
 		add(Selection{
 			Command: match.Command,
-			Reason:  fmt.Sprintf("history match score %.2f", match.Score),
+			Reason:  reason,
 			Source:  match.Source,
-		}, "[history] ")
+		}, "[history] ", "")
 	}
 
 	return options
 }
 
-func selectWithHuh(query string, options []selectorOption) (Selection, bool, error) {
-	huhOptions := make([]huh.Option[string], 0, len(options))
-	lookup := map[string]Selection{}
-	for _, option := range options {
-		command := strings.TrimSpace(option.Selection.Command)
-		huhOptions = append(huhOptions, huh.NewOption(option.Label, command))
-		lookup[strings.ToLower(command)] = option.Selection
-	}
-
-	initial := huhOptions[0].Value
-	choice := initial
-
-	prompt := huh.NewSelect[string]().
-		Title("ew command picker").
-		Description(fmt.Sprintf("Choose command for: %q", strings.TrimSpace(query))).
-		Options(huhOptions...).
-		Filtering(true).
-		Height(huhSelectHeight(len(huhOptions))).
-		Value(&choice).
-		WithTheme(huh.ThemeCharm())
-
-	err := prompt.Run()
-	if err != nil {
-		if errors.Is(err, huh.ErrUserAborted) {
-			return Selection{}, true, nil
-		}
-		return Selection{}, false, err
-	}
-	selected, ok := lookup[strings.ToLower(strings.TrimSpace(choice))]
-	if !ok {
-		return Selection{}, true, nil
-	}
-	return selected, true, nil
-}
-
-type bubbleSelectorItem struct {
-	label   string
-	command string
-}
-
-func (i bubbleSelectorItem) Title() string       { return i.label }
-func (i bubbleSelectorItem) Description() string { return "" }
-func (i bubbleSelectorItem) FilterValue() string { return i.label + " " + i.command }
-
-type bubbleSelectorModel struct {
-	list      list.Model
-	selection string
-	cancelled bool
-	options   int
-}
-
-func (m bubbleSelectorModel) Init() tea.Cmd { return nil }
-
-func (m bubbleSelectorModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	switch k := msg.(type) {
-	case tea.WindowSizeMsg:
-		width, height := bubblePickerSize(k.Width, k.Height, m.options)
-		m.list.SetSize(width, height)
-		return m, nil
-	case tea.KeyMsg:
-		switch k.String() {
-		case "q", "esc", "ctrl+c":
-			m.cancelled = true
-			return m, tea.Quit
-		case "enter":
-			if item, ok := m.list.SelectedItem().(bubbleSelectorItem); ok {
-				m.selection = item.command
-			}
-			return m, tea.Quit
-		}
-	}
-	var cmd tea.Cmd
-	m.list, cmd = m.list.Update(msg)
-	return m, cmd
-}
-
-func (m bubbleSelectorModel) View() string {
-	return m.list.View()
-}
-
-func selectWithBubbleTea(query string, options []selectorOption) (Selection, bool, error) {
-	items := make([]list.Item, 0, len(options))
-	lookup := map[string]Selection{}
-	for _, option := range options {
-		command := strings.TrimSpace(option.Selection.Command)
-		lookup[strings.ToLower(command)] = option.Selection
-		items = append(items, bubbleSelectorItem{
-			label:   option.Label,
-			command: command,
-		})
-	}
-
-	delegate := list.NewDefaultDelegate()
-	delegate.ShowDescription = false
-	delegate.SetSpacing(0)
-
-	initialWidth, initialHeight := bubblePickerSize(80, 24, len(items))
-	picker := list.New(items, delegate, initialWidth, initialHeight)
-	picker.Title = fmt.Sprintf("ew command picker: %s", strings.TrimSpace(query))
-	picker.SetShowHelp(false)
-	picker.SetFilteringEnabled(true)
-
-	model := bubbleSelectorModel{list: picker, options: len(items)}
-	final, err := tea.NewProgram(model, tea.WithAltScreen()).Run()
-	if err != nil {
-		return Selection{}, false, err
-	}
-	out, ok := final.(bubbleSelectorModel)
-	if !ok {
-		return Selection{}, true, nil
-	}
-	if out.cancelled {
-		return Selection{}, true, nil
-	}
-
-	selection := strings.ToLower(strings.TrimSpace(out.selection))
-	if selection == "" {
-		return Selection{}, true, nil
-	}
-	selected, ok := lookup[selection]
-	if !ok {
-		return Selection{}, true, nil
-	}
-	return selected, true, nil
-}
-
-func selectWithTView(query string, options []selectorOption) (Selection, bool, error) {
-	app := tview.NewApplication()
-	listView := tview.NewList()
-	listView.SetBorder(true)
-	listView.SetTitle(fmt.Sprintf("ew command picker: %s", strings.TrimSpace(query)))
-	listView.ShowSecondaryText(false)
-
-	selected := Selection{}
-	used := false
-	for _, option := range options {
-		current := option
-		listView.AddItem(current.Label, "", 0, func() {
-			selected = current.Selection
-			used = true
-			app.Stop()
-		})
-	}
-	listView.SetDoneFunc(func() {
-		app.Stop()
+// historyOrder puts options back in the history ranking, for when a
+// provider promoted a lower match. Options outside the history follow, and
+// it is nil when the order would not change.
+func historyOrder(options []selectorOption) []selectorOption {
+	ordered := slices.Clone(options)
+	slices.SortStableFunc(ordered, func(a, b selectorOption) int {
+		return cmp.Compare(historyOrderKey(a), historyOrderKey(b))
 	})
-
-	if err := app.SetRoot(listView, true).SetFocus(listView).Run(); err != nil {
-		return Selection{}, false, err
-	}
-	if !used {
-		return Selection{}, true, nil
-	}
-	return selected, true, nil
-}
-
-func minInt(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
-}
-
-func clampInt(v, minV, maxV int) int {
-	if v < minV {
-		return minV
-	}
-	if v > maxV {
-		return maxV
-	}
-	return v
-}
-
-func bubblePickerSize(termWidth, termHeight, optionCount int) (int, int) {
-	if termWidth <= 0 {
-		termWidth = 80
-	}
-	if termHeight <= 0 {
-		termHeight = 24
-	}
-	if optionCount < 1 {
-		optionCount = 1
-	}
-
-	maxWidth := termWidth
-	minWidth := 32
-	if maxWidth < minWidth {
-		minWidth = maxWidth
-	}
-	width := clampInt(termWidth-4, minWidth, maxWidth)
-
-	visibleItems := clampInt(optionCount, 3, 12)
-	desiredHeight := visibleItems + 6
-
-	maxHeight := termHeight - 2
-	if maxHeight <= 0 {
-		maxHeight = termHeight
-	}
-	if maxHeight <= 0 {
-		maxHeight = 1
-	}
-	minHeight := 8
-	if maxHeight < minHeight {
-		minHeight = maxHeight
+	for idx := range ordered {
+		if ordered[idx].Selection.Command != options[idx].Selection.Command {
+			return ordered
+		}
 	}
-	height := clampInt(desiredHeight, minHeight, maxHeight)
-	return width, height
+	return nil
 }
 
-func huhSelectHeight(optionCount int) int {
-	if optionCount < 1 {
-		optionCount = 1
+func historyOrderKey(option selectorOption) int {
+	switch {
+	case option.Selection.Command == moreCommand:
+		return math.MaxInt
+	case option.Rank == 0:
+		return math.MaxInt - 1
 	}
-	return clampInt(optionCount+1, 4, 10)
+	return option.Rank
 }
@@ -1,38 +1,76 @@
 package ui
 
-import "testing"
+import (
+	"strings"
+	"testing"
 
-func TestBubblePickerSizeStandardTerminal(t *testing.T) {
-	width, height := bubblePickerSize(90, 30, 3)
-	if width != 86 {
-		t.Fatalf("expected width 86, got %d", width)
+	"github.com/ashwch/ew/internal/history"
+)
+
+func TestBuildSelectionOptionsListsOriginalAfterRecommendation(t *testing.T) {
+	options := buildSelectionOptions(Selection{
+		Command:     "trash build",
+		Source:      "claude",
+		Alternative: "rm -rf build",
+	}, nil)
+	if len(options) != 2 {
+		t.Fatalf("expected recommended and original options, got %d", len(options))
+	}
+	if options[0].Selection.Command != "trash build" {
+		t.Fatalf("expected safer rewrite first, got %q", options[0].Selection.Command)
 	}
-	if height != 9 {
-		t.Fatalf("expected height 9, got %d", height)
+	if options[1].Selection.Command != "rm -rf build" || options[1].Selection.Alternative != "" {
+		t.Fatalf("expected raw command as plain alternative, got %+v", options[1].Selection)
 	}
 }
 
-func TestBubblePickerSizeTinyTerminalStillFits(t *testing.T) {
-	width, height := bubblePickerSize(20, 5, 25)
-	if width > 20 {
-		t.Fatalf("expected width to fit terminal, got %d", width)
+func TestHistoryOrderPutsAPromotedMatchBackInItsPlace(t *testing.T) {
+	options := buildSelectionOptions(Selection{
+		Command: "git log --graph",
+		Source:  "claude",
+		Rerank:  "AI promoted #3 over #1",
+	}, []history.Match{
+		{Command: "git log"},
+		{Command: "git log --oneline"},
+		{Command: "git log --graph"},
+	})
+	if len(options) != 3 || options[0].Label != "[recommended] git log --graph  (AI promoted #3 over #1)" || options[0].Rank != 3 {
+		t.Fatalf("expected the recommendation to carry its note and rank, got %+v", options)
 	}
-	if height > 5 {
-		t.Fatalf("expected height to fit terminal, got %d", height)
+	ordered := historyOrder(options)
+	got := []string{}
+	for _, option := range ordered {
+		got = append(got, option.Selection.Command)
 	}
-	if width <= 0 || height <= 0 {
-		t.Fatalf("expected positive dimensions, got width=%d height=%d", width, height)
+	if strings.Join(got, ",") != "git log,git log --oneline,git log --graph" {
+		t.Fatalf("expected the history order, got %v", got)
+	}
+	if historyOrder(ordered) != nil {
+		t.Fatalf("expected no second order when nothing was promoted")
 	}
 }
 
-func TestHuhSelectHeightBounds(t *testing.T) {
-	if got := huhSelectHeight(0); got != 4 {
-		t.Fatalf("expected minimum huh height 4, got %d", got)
+func TestRevisionResolvesOnce(t *testing.T) {
+	revision := NewRevision()
+	if revision.Resolved() {
+		t.Fatalf("expected a new revision to be pending")
 	}
-	if got := huhSelectHeight(3); got != 4 {
-		t.Fatalf("expected huh height 4 for small lists, got %d", got)
+	revision.Resolve(Selection{Command: "git log --graph"}, true)
+	revision.Resolve(Selection{Command: "ls"}, true)
+	if selection, ok := revision.Wait(); !ok || selection.Command != "git log --graph" || !revision.Resolved() {
+		t.Fatalf("expected the first answer to stick, got %+v %v", selection, ok)
 	}
-	if got := huhSelectHeight(20); got != 10 {
-		t.Fatalf("expected max huh height 10, got %d", got)
+
+	failed := NewRevision()
+	failed.Resolve(Selection{}, false)
+	if got := revise(Selection{Command: "git log", Provisional: true}, failed); got.Command != "git log" || got.Provisional {
+		t.Fatalf("expected the provisional pick to become the recommendation, got %+v", got)
+	}
+}
+
+func TestBuildSelectionOptionsMarksProvisionalPicks(t *testing.T) {
+	options := buildSelectionOptions(Selection{Command: "git log", Provisional: true}, []history.Match{{Command: "git log --graph"}})
+	if !strings.HasPrefix(options[0].Label, "[for now] git log") || !strings.Contains(options[0].Label, "still ranking") {
+		t.Fatalf("expected a provisional label, got %q", options[0].Label)
 	}
 }
@@ -0,0 +1,468 @@
+//go:build !ew_minimal
+
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/rivo/tview"
+)
+
+func selectWithHuh(query string, options []selectorOption) (Selection, bool, error) {
+	huhOptions := make([]huh.Option[string], 0, len(options))
+	lookup := map[string]Selection{}
+	for _, option := range options {
+		command := strings.TrimSpace(option.Selection.Command)
+		huhOptions = append(huhOptions, huh.NewOption(option.Label, command))
+		lookup[strings.ToLower(command)] = option.Selection
+	}
+
+	initial := huhOptions[0].Value
+	choice := initial
+
+	prompt := huh.NewSelect[string]().
+		Title("ew command picker").
+		Description(fmt.Sprintf("Choose command for: %q", strings.TrimSpace(query))).
+		Options(huhOptions...).
+		Filtering(true).
+		Height(huhSelectHeight(len(huhOptions))).
+		Value(&choice).
+		WithTheme(huh.ThemeCharm())
+
+	err := prompt.Run()
+	if err != nil {
+		if errors.Is(err, huh.ErrUserAborted) {
+			return Selection{}, true, nil
+		}
+		return Selection{}, false, err
+	}
+	selected, ok := lookup[strings.ToLower(strings.TrimSpace(choice))]
+	if !ok {
+		return Selection{}, true, nil
+	}
+	return selected, true, nil
+}
+
+type bubbleSelectorItem struct {
+	label   string
+	command string
+}
+
+func (i bubbleSelectorItem) Title() string       { return i.label }
+func (i bubbleSelectorItem) Description() string { return "" }
+func (i bubbleSelectorItem) FilterValue() string { return i.label + " " + i.command }
+
+type bubbleSelectorModel struct {
+	list      list.Model
+	selection string
+	cancelled bool
+	options   int
+	hasMore   bool
+	// ranked and historyOrder hold the list both ways round when a
+	// provider promoted a lower history match; o switches between them.
+	ranked         []list.Item
+	historyOrder   []list.Item
+	inHistoryOrder bool
+
+	ctx         context.Context
+	compare     CompareFunc
+	recommended string
+	width       int
+	// comparisons holds each compared candidate's answer; an empty entry
+	// means the provider is still thinking.
+	comparisons map[string]string
+
+	lookup map[string]Selection
+	title  string
+	// live swaps in the provider's recommendation when it arrives.
+	live liveRevision
+
+	// editing is set while e has the highlighted command open in editor;
+	// editedFrom is that command once the edit is picked.
+	editing    bool
+	editor     textinput.Model
+	editedFrom string
+}
+
+// revisionMsg says the live revision has been resolved.
+type revisionMsg struct{}
+
+// compareResultMsg carries a finished comparison back into the picker.
+type compareResultMsg struct {
+	command string
+	text    string
+}
+
+func (m bubbleSelectorModel) Init() tea.Cmd {
+	if m.live.revision == nil {
+		return nil
+	}
+	revision := m.live.revision
+	return func() tea.Msg {
+		revision.Wait()
+		return revisionMsg{}
+	}
+}
+
+// setOptions shows options, keeping the highlighted row, and works out the
+// title, the lookup, and the history order again.
+func (m *bubbleSelectorModel) setOptions(options []selectorOption) tea.Cmd {
+	m.ranked = bubbleSelectorItems(options)
+	m.historyOrder = nil
+	if len(options) > 0 && options[0].Selection.Rerank != "" {
+		if ordered := historyOrder(options); ordered != nil {
+			m.historyOrder = bubbleSelectorItems(ordered)
+		}
+	}
+	m.inHistoryOrder = false
+	m.options = len(options)
+	m.hasMore = false
+	m.lookup = map[string]Selection{}
+	for _, option := range options {
+		m.hasMore = m.hasMore || option.Selection.Command == moreCommand
+		m.lookup[strings.ToLower(strings.TrimSpace(option.Selection.Command))] = option.Selection
+	}
+	if len(options) > 0 {
+		m.recommended = strings.TrimSpace(options[0].Selection.Command)
+	}
+	title := m.title
+	if m.hasMore {
+		title += "  (m: more)"
+	}
+	if m.compare != nil {
+		title += "  (c: compare)"
+	}
+	if m.historyOrder != nil {
+		title += "  (o: history order)"
+	}
+	title += "  (e: edit)"
+	m.list.Title = title
+	cursor := m.list.Index()
+	cmd := m.list.SetItems(m.ranked)
+	m.list.Select(min(cursor, len(m.ranked)-1))
+	return cmd
+}
+
+func (m bubbleSelectorModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch k := msg.(type) {
+	case tea.WindowSizeMsg:
+		width, height := bubblePickerSize(k.Width, k.Height, m.options)
+		if height > reasonLines+3 {
+			height -= reasonLines
+		}
+		if m.compare != nil && height > compareLines+3 {
+			height -= compareLines
+		}
+		m.list.SetSize(width, height)
+		m.width = width
+		return m, nil
+	case compareResultMsg:
+		m.comparisons[k.command] = k.text
+		return m, nil
+	case revisionMsg:
+		if len(m.ranked) == 0 {
+			return m, nil
+		}
+		first := m.lookup[strings.ToLower(m.recommended)]
+		return m, m.setOptions(m.live.rebuild(revise(first, m.live.revision)))
+	case tea.KeyMsg:
+		if m.editing {
+			return m.updateEditing(k)
+		}
+		switch k.String() {
+		case "q", "esc", "ctrl+c":
+			m.cancelled = true
+			return m, tea.Quit
+		case "enter":
+			if item, ok := m.list.SelectedItem().(bubbleSelectorItem); ok {
+				m.selection = item.command
+			}
+			return m, tea.Quit
+		case "m":
+			if m.hasMore && m.list.FilterState() != list.Filtering {
+				m.selection = moreCommand
+				return m, tea.Quit
+			}
+		case "c":
+			if m.compare != nil && m.list.FilterState() != list.Filtering {
+				return m.startComparison()
+			}
+		case "e":
+			item, ok := m.list.SelectedItem().(bubbleSelectorItem)
+			if ok && item.command != moreCommand && m.list.FilterState() != list.Filtering {
+				m.editing = true
+				m.editedFrom = item.command
+				m.editor = newCommandEditor(item.command)
+				return m, m.editor.Focus()
+			}
+		case "o":
+			if m.historyOrder != nil && m.list.FilterState() != list.Filtering {
+				m.inHistoryOrder = !m.inHistoryOrder
+				items := m.ranked
+				if m.inHistoryOrder {
+					items = m.historyOrder
+				}
+				cmd := m.list.SetItems(items)
+				m.list.Select(0)
+				return m, cmd
+			}
+		}
+	}
+	var cmd tea.Cmd
+	m.list, cmd = m.list.Update(msg)
+	return m, cmd
+}
+
+// updateEditing picks the edited command on enter and goes back to the
+// list on esc. An unchanged command is picked as it was listed.
+func (m bubbleSelectorModel) updateEditing(k tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch k.String() {
+	case "ctrl+c":
+		m.cancelled = true
+		return m, tea.Quit
+	case "esc":
+		m.editing = false
+		m.editedFrom = ""
+		m.editor.Blur()
+		return m, nil
+	case "enter":
+		edited := strings.TrimSpace(m.editor.Value())
+		if edited == "" {
+			return m, nil
+		}
+		if edited == m.editedFrom {
+			m.editedFrom = ""
+		}
+		m.selection = edited
+		return m, tea.Quit
+	}
+	var cmd tea.Cmd
+	m.editor, cmd = m.editor.Update(k)
+	return m, cmd
+}
+
+// startComparison asks compare about the highlighted candidate, once per
+// candidate; the answer arrives as a compareResultMsg.
+func (m bubbleSelectorModel) startComparison() (tea.Model, tea.Cmd) {
+	item, ok := m.list.SelectedItem().(bubbleSelectorItem)
+	if !ok || item.command == moreCommand || strings.EqualFold(item.command, m.recommended) {
+		return m, nil
+	}
+	if _, asked := m.comparisons[item.command]; asked {
+		return m, nil
+	}
+	m.comparisons[item.command] = ""
+	ctx, compare, command := m.ctx, m.compare, item.command
+	return m, func() tea.Msg {
+		text, err := compare(ctx, command)
+		if err != nil {
+			text = fmt.Sprintf("could not compare: %v", err)
+		}
+		if strings.TrimSpace(text) == "" {
+			text = "no comparison came back"
+		}
+		return compareResultMsg{command: command, text: strings.TrimSpace(text)}
+	}
+}
+
+func (m bubbleSelectorModel) View() string {
+	view := m.list.View()
+	if m.editing {
+		return view + "\n" + m.editor.View() + "\n[enter] use  [esc] back"
+	}
+	item, ok := m.list.SelectedItem().(bubbleSelectorItem)
+	if !ok {
+		return view
+	}
+	if reason := strings.TrimSpace(m.lookup[strings.ToLower(item.command)].Reason); reason != "" {
+		view += "\n" + m.below("reason: "+reason, reasonLines)
+	}
+	if m.compare == nil {
+		return view
+	}
+	text, asked := m.comparisons[item.command]
+	switch {
+	case !asked:
+		return view
+	case text == "":
+		text = "comparing with the recommendation..."
+	}
+	return view + "\n" + m.below(text, compareLines)
+}
+
+// below wraps text to the picker's width for the room under the list,
+// cutting it off with "..." after limit lines.
+func (m bubbleSelectorModel) below(text string, limit int) string {
+	width := m.width
+	if width <= 0 {
+		width = 80
+	}
+	lines := strings.Split(lipgloss.NewStyle().Width(width-2).Render(ASCII(text)), "\n")
+	if len(lines) > limit {
+		lines = append(lines[:limit-1], strings.TrimRight(lines[limit-1], " ")+"...")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func bubbleSelectorItems(options []selectorOption) []list.Item {
+	items := make([]list.Item, 0, len(options))
+	for _, option := range options {
+		items = append(items, bubbleSelectorItem{
+			label:   option.Label,
+			command: strings.TrimSpace(option.Selection.Command),
+		})
+	}
+	return items
+}
+
+func selectWithBubbleTea(query string, options []selectorOption, recommended string, compare CompareFunc, live liveRevision) (Selection, bool, error) {
+	delegate := list.NewDefaultDelegate()
+	delegate.ShowDescription = false
+	delegate.SetSpacing(0)
+
+	initialWidth, initialHeight := bubblePickerSize(80, 24, len(options))
+	picker := list.New(nil, delegate, initialWidth, initialHeight)
+	picker.SetShowHelp(false)
+	picker.SetFilteringEnabled(true)
+
+	// A comparison still running when the picker closes is abandoned.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	model := bubbleSelectorModel{
+		list:        picker,
+		ctx:         ctx,
+		compare:     compare,
+		comparisons: map[string]string{},
+		title:       fmt.Sprintf("ew command picker: %s", strings.TrimSpace(query)),
+		live:        live,
+	}
+	model.setOptions(options)
+	model.recommended = recommended
+	final, err := tea.NewProgram(model, tea.WithAltScreen()).Run()
+	if err != nil {
+		return Selection{}, false, err
+	}
+	out, ok := final.(bubbleSelectorModel)
+	if !ok {
+		return Selection{}, true, nil
+	}
+	if out.cancelled {
+		return Selection{}, true, nil
+	}
+	return out.selected(), true, nil
+}
+
+// selected is the picked Selection, or the zero Selection when nothing was
+// picked. An edited command keeps the listed one's reason and source.
+func (m bubbleSelectorModel) selected() Selection {
+	picked := strings.TrimSpace(m.selection)
+	if picked == "" {
+		return Selection{}
+	}
+	if m.editedFrom != "" {
+		selected := m.lookup[strings.ToLower(m.editedFrom)]
+		selected.Command = picked
+		selected.EditedFrom = m.editedFrom
+		selected.Alternative = ""
+		return selected
+	}
+	return m.lookup[strings.ToLower(picked)]
+}
+
+func selectWithTView(query string, options []selectorOption) (Selection, bool, error) {
+	app := tview.NewApplication()
+	listView := tview.NewList()
+	listView.SetBorder(true)
+	listView.SetTitle(fmt.Sprintf("ew command picker: %s", strings.TrimSpace(query)))
+	listView.ShowSecondaryText(false)
+
+	selected := Selection{}
+	used := false
+	for _, option := range options {
+		current := option
+		listView.AddItem(current.Label, "", 0, func() {
+			selected = current.Selection
+			used = true
+			app.Stop()
+		})
+	}
+	listView.SetDoneFunc(func() {
+		app.Stop()
+	})
+
+	if err := app.SetRoot(listView, true).SetFocus(listView).Run(); err != nil {
+		return Selection{}, false, err
+	}
+	if !used {
+		return Selection{}, true, nil
+	}
+	return selected, true, nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func clampInt(v, minV, maxV int) int {
+	if v < minV {
+		return minV
+	}
+	if v > maxV {
+		return maxV
+	}
+	return v
+}
+
+func bubblePickerSize(termWidth, termHeight, optionCount int) (int, int) {
+	if termWidth <= 0 {
+		termWidth = 80
+	}
+	if termHeight <= 0 {
+		termHeight = 24
+	}
+	if optionCount < 1 {
+		optionCount = 1
+	}
+
+	maxWidth := termWidth
+	minWidth := 32
+	if maxWidth < minWidth {
+		minWidth = maxWidth
+	}
+	width := clampInt(termWidth-4, minWidth, maxWidth)
+
+	visibleItems := clampInt(optionCount, 3, 12)
+	desiredHeight := visibleItems + 6
+
+	maxHeight := termHeight - 2
+	if maxHeight <= 0 {
+		maxHeight = termHeight
+	}
+	if maxHeight <= 0 {
+		maxHeight = 1
+	}
+	minHeight := 8
+	if maxHeight < minHeight {
+		minHeight = maxHeight
+	}
+	height := clampInt(desiredHeight, minHeight, maxHeight)
+	return width, height
+}
+
+func huhSelectHeight(optionCount int) int {
+	if optionCount < 1 {
+		optionCount = 1
+	}
+	return clampInt(optionCount+1, 4, 10)
+}
@@ -0,0 +1,205 @@
+//go:build !ew_minimal
+
+package ui
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ashwch/ew/internal/history"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestBubblePickerSizeStandardTerminal(t *testing.T) {
+	width, height := bubblePickerSize(90, 30, 3)
+	if width != 86 {
+		t.Fatalf("expected width 86, got %d", width)
+	}
+	if height != 9 {
+		t.Fatalf("expected height 9, got %d", height)
+	}
+}
+
+func TestBubblePickerSizeTinyTerminalStillFits(t *testing.T) {
+	width, height := bubblePickerSize(20, 5, 25)
+	if width > 20 {
+		t.Fatalf("expected width to fit terminal, got %d", width)
+	}
+	if height > 5 {
+		t.Fatalf("expected height to fit terminal, got %d", height)
+	}
+	if width <= 0 || height <= 0 {
+		t.Fatalf("expected positive dimensions, got width=%d height=%d", width, height)
+	}
+}
+
+func TestHuhSelectHeightBounds(t *testing.T) {
+	if got := huhSelectHeight(0); got != 4 {
+		t.Fatalf("expected minimum huh height 4, got %d", got)
+	}
+	if got := huhSelectHeight(3); got != 4 {
+		t.Fatalf("expected huh height 4 for small lists, got %d", got)
+	}
+	if got := huhSelectHeight(20); got != 10 {
+		t.Fatalf("expected max huh height 10, got %d", got)
+	}
+}
+
+func TestBubbleSelectorMoreKeyOnlyWhenPaging(t *testing.T) {
+	items := []list.Item{bubbleSelectorItem{label: "[history] ls", command: "ls"}}
+	press := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("m")}
+
+	paged := bubbleSelectorModel{list: list.New(items, list.NewDefaultDelegate(), 40, 10), hasMore: true}
+	next, _ := paged.Update(press)
+	if got := next.(bubbleSelectorModel).selection; got != moreCommand {
+		t.Fatalf("expected m to ask for more matches, got %q", got)
+	}
+
+	plain := bubbleSelectorModel{list: list.New(items, list.NewDefaultDelegate(), 40, 10)}
+	next, _ = plain.Update(press)
+	if got := next.(bubbleSelectorModel).selection; got != "" {
+		t.Fatalf("expected m to do nothing without paging, got %q", got)
+	}
+}
+
+func TestBubbleSelectorComparesHighlightedCandidate(t *testing.T) {
+	items := []list.Item{
+		bubbleSelectorItem{label: "[recommended] git log --oneline", command: "git log --oneline"},
+		bubbleSelectorItem{label: "[history] git log --graph", command: "git log --graph"},
+	}
+	var asked []string
+	compare := func(_ context.Context, candidate string) (string, error) {
+		asked = append(asked, candidate)
+		return "--graph draws the branch structure; the recommendation fits a flat list better.", nil
+	}
+	model := bubbleSelectorModel{
+		list:        list.New(items, list.NewDefaultDelegate(), 60, 10),
+		ctx:         context.Background(),
+		compare:     compare,
+		recommended: "git log --oneline",
+		comparisons: map[string]string{},
+	}
+	press := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("c")}
+
+	next, cmd := model.Update(press)
+	if cmd != nil {
+		t.Fatalf("expected no comparison of the recommendation with itself")
+	}
+	model = next.(bubbleSelectorModel)
+	model.list.Select(1)
+	next, cmd = model.Update(press)
+	if cmd == nil {
+		t.Fatalf("expected c to start a comparison")
+	}
+	model = next.(bubbleSelectorModel)
+	if !strings.Contains(model.View(), "comparing") {
+		t.Fatalf("expected a pending note, got %q", model.View())
+	}
+	next, _ = model.Update(cmd())
+	model = next.(bubbleSelectorModel)
+	if !strings.Contains(model.View(), "draws the branch structure") {
+		t.Fatalf("expected the comparison under the list, got %q", model.View())
+	}
+	if _, cmd = model.Update(press); cmd != nil || len(asked) != 1 {
+		t.Fatalf("expected one provider call per candidate, got %v", asked)
+	}
+}
+
+func TestBubbleSelectorSwitchesToHistoryOrder(t *testing.T) {
+	ranked := []list.Item{
+		bubbleSelectorItem{label: "[recommended] git log --graph", command: "git log --graph"},
+		bubbleSelectorItem{label: "[history] git log", command: "git log"},
+	}
+	inOrder := []list.Item{ranked[1], ranked[0]}
+	model := bubbleSelectorModel{list: list.New(ranked, list.NewDefaultDelegate(), 60, 10), ranked: ranked, historyOrder: inOrder}
+	press := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("o")}
+
+	next, _ := model.Update(press)
+	model = next.(bubbleSelectorModel)
+	if item := model.list.SelectedItem().(bubbleSelectorItem); item.command != "git log" {
+		t.Fatalf("expected history #1 highlighted, got %q", item.command)
+	}
+	next, _ = model.Update(press)
+	model = next.(bubbleSelectorModel)
+	if item := model.list.SelectedItem().(bubbleSelectorItem); item.command != "git log --graph" {
+		t.Fatalf("expected o to switch back, got %q", item.command)
+	}
+}
+
+func TestBubbleSelectorSwapsInTheRevision(t *testing.T) {
+	matches := []history.Match{{Command: "git log"}, {Command: "git log --graph"}}
+	revision := NewRevision()
+	rebuild := func(suggested Selection) []selectorOption {
+		return buildSelectionOptions(suggested, matches)
+	}
+	model := bubbleSelectorModel{
+		list:        list.New(nil, list.NewDefaultDelegate(), 60, 10),
+		comparisons: map[string]string{},
+		live:        liveRevision{revision: revision, rebuild: rebuild},
+	}
+	model.setOptions(rebuild(Selection{Command: "git log", Provisional: true}))
+	if model.Init() == nil {
+		t.Fatalf("expected the picker to wait for the revision")
+	}
+
+	revision.Resolve(Selection{Command: "git log --graph", Source: "claude", Rerank: "AI promoted #2 over #1"}, true)
+	updated, _ := model.Update(revisionMsg{})
+	model = updated.(bubbleSelectorModel)
+	first := model.list.Items()[0].(bubbleSelectorItem)
+	if !strings.HasPrefix(first.label, "[recommended] git log --graph") || model.recommended != "git log --graph" {
+		t.Fatalf("expected the provider's pick on top, got %q", first.label)
+	}
+	if model.lookup["git log --graph"].Source != "claude" || model.historyOrder == nil {
+		t.Fatalf("expected the lookup and history order rebuilt, got %+v", model.lookup)
+	}
+}
+
+func TestBubbleSelectorEditsTheHighlightedCommand(t *testing.T) {
+	model := bubbleSelectorModel{
+		list:        list.New(nil, list.NewDefaultDelegate(), 60, 10),
+		comparisons: map[string]string{},
+	}
+	model.setOptions(buildSelectionOptions(Selection{Command: "git log", Reason: "recent", Source: "claude"}, []history.Match{{Command: "git log --graph"}}))
+	next, cmd := model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("e")})
+	model = next.(bubbleSelectorModel)
+	if !model.editing || cmd == nil || model.editor.Value() != "git log" {
+		t.Fatalf("expected e to open the highlighted command for editing, got %q", model.editor.Value())
+	}
+	next, _ = model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(" -5")})
+	next, _ = next.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	model = next.(bubbleSelectorModel)
+	got := model.selected()
+	want := Selection{Command: "git log -5", Reason: "recent", Source: "claude", EditedFrom: "git log"}
+	if got != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+
+	// esc goes back to the list, and an unchanged command is no edit.
+	next, _ = next.(bubbleSelectorModel).Update(tea.KeyMsg{Type: tea.KeyEsc})
+	model = next.(bubbleSelectorModel)
+	model.selection, model.editing = "", false
+	next, _ = model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("e")})
+	next, _ = next.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if model = next.(bubbleSelectorModel); model.editing || model.editedFrom != "" {
+		t.Fatalf("expected esc to leave the editor")
+	}
+	next, _ = model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("e")})
+	next, _ = next.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if got := next.(bubbleSelectorModel).selected(); got.Command != "git log" || got.EditedFrom != "" {
+		t.Fatalf("expected the listed command unedited, got %+v", got)
+	}
+}
+
+func TestBubbleSelectorShowsTheHighlightedReason(t *testing.T) {
+	model := bubbleSelectorModel{list: list.New(nil, list.NewDefaultDelegate(), 60, 10), comparisons: map[string]string{}}
+	model.setOptions(buildSelectionOptions(Selection{Command: "git log --oneline", Reason: "one line per commit", Source: "claude"}, []history.Match{{Command: "git log --graph", Score: 0.5}}))
+	if view := model.View(); !strings.Contains(view, "reason: one line per commit") {
+		t.Fatalf("expected the recommendation's reason under the list, got %q", view)
+	}
+	model.list.Select(1)
+	if view := model.View(); !strings.Contains(view, "reason: history match score 0.50") {
+		t.Fatalf("expected the history match's reason once it is highlighted, got %q", view)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !ew_minimal
+
+package ui
+
+import "github.com/charmbracelet/lipgloss"
+
+// TUIBuilt reports whether this build has the full-screen backends. Builds
+// made with -tags ew_minimal leave out bubbletea, huh, tview, and lipgloss,
+// and every picker and prompt falls back to plain text.
+const TUIBuilt = true
+
+var codeStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("6"))
+
+// HighlightCode renders text as inline code for the terminal.
+func HighlightCode(text string) string {
+	return codeStyle.Render(text)
+}
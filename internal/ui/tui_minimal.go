@@ -0,0 +1,55 @@
+//go:build ew_minimal
+
+package ui
+
+import (
+	"errors"
+
+	"github.com/ashwch/ew/internal/memory"
+)
+
+// TUIBuilt reports whether this build has the full-screen backends. This
+// one was made with -tags ew_minimal: backendCandidates only ever offers
+// BackendPlain, so the functions below are never reached.
+const TUIBuilt = false
+
+var errNoTUI = errors.New("ew was built without interactive backends (ew_minimal)")
+
+// HighlightCode renders text as bold cyan inline code.
+func HighlightCode(text string) string {
+	return "\x1b[1;36m" + text + "\x1b[0m"
+}
+
+func confirmWithBubbleTea(confirmation) (ConfirmDecision, error) { return ConfirmDecision{}, errNoTUI }
+func confirmWithHuh(confirmation) (bool, error)                  { return false, errNoTUI }
+func confirmWithTView(confirmation) (bool, error)                { return false, errNoTUI }
+
+func selectWithBubbleTea(string, []selectorOption, string, CompareFunc, liveRevision) (Selection, bool, error) {
+	return Selection{}, false, errNoTUI
+}
+
+func selectWithHuh(string, []selectorOption) (Selection, bool, error) {
+	return Selection{}, false, errNoTUI
+}
+
+func selectWithTView(string, []selectorOption) (Selection, bool, error) {
+	return Selection{}, false, errNoTUI
+}
+
+func editMemoryWithBubbleTea(store memory.Store) (memory.Store, bool, error) {
+	return store, false, errNoTUI
+}
+
+func systemProfileOnboardingWithBubbleTea(string, string) (SystemProfileDecision, error) {
+	return SystemProfileDecision{}, errNoTUI
+}
+
+func fillPlaceholdersWithHuh(string, []Placeholder) (map[string]string, error) {
+	return nil, errNoTUI
+}
+
+func pickCandidatesWithHuh([]memory.Candidate) ([]memory.Candidate, error) {
+	return nil, errNoTUI
+}
+
+func pagerWithBubbleTea(string, []ReplayStep) error { return errNoTUI }
@@ -0,0 +1,19 @@
+//go:build ew_minimal
+
+package ui
+
+import "testing"
+
+func TestMinimalBuildOnlyOffersPlainBackend(t *testing.T) {
+	for _, backend := range []string{BackendAuto, BackendBubbleTea, BackendHuh, BackendTView, BackendPlain} {
+		if got := NormalizeBackend(backend); got != BackendPlain {
+			t.Fatalf("expected %s to normalize to plain, got %s", backend, got)
+		}
+		if got := backendCandidates(backend); len(got) != 1 || got[0] != BackendPlain {
+			t.Fatalf("expected only the plain backend for %s, got %v", backend, got)
+		}
+		if IsInteractiveBackend(backend) {
+			t.Fatalf("expected %s not to be interactive", backend)
+		}
+	}
+}
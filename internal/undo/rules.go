@@ -0,0 +1,367 @@
+package undo
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// toggles pairs subcommands that reverse each other, per tool.
+var toggles = map[string]map[string]string{
+	"systemctl": {"start": "stop", "stop": "start", "enable": "disable", "disable": "enable", "mask": "unmask", "unmask": "mask"},
+	"docker":    {"start": "stop", "stop": "start", "pause": "unpause", "unpause": "pause"},
+	"brew":      {"install": "uninstall", "uninstall": "install", "link": "unlink", "unlink": "link", "pin": "unpin", "unpin": "pin"},
+	"npm":       {"install": "uninstall", "i": "uninstall", "uninstall": "install"},
+	"pnpm":      {"add": "remove", "remove": "add"},
+	"yarn":      {"add": "remove", "remove": "add"},
+	"uv":        {"add": "remove", "remove": "add"},
+	"cargo":     {"install": "uninstall", "uninstall": "install"},
+	"apt":       {"install": "remove"},
+	"apt-get":   {"install": "remove"},
+}
+
+// State is what an inverse depends on beyond the command itself: HEAD for
+// the git commands that move it, what sat at the path ln wrote, and the
+// directories mkdir had to create. Capture reads it just before a command
+// runs, so the inverse restores exactly that, and again after, so Check can
+// refuse the undo once the world has moved on.
+type State struct {
+	Head string `json:"head,omitempty"`
+	// LinkTarget is where the symlink at ln's link path points, and
+	// Occupied is set when something other than a symlink sat there.
+	LinkTarget string `json:"link_target,omitempty"`
+	Occupied   bool   `json:"occupied,omitempty"`
+	// Missing lists the directories mkdir would create, deepest first.
+	Missing []string `json:"missing,omitempty"`
+}
+
+// Capture reads the State that command's inverse depends on, in cwd.
+func Capture(command, cwd string) State {
+	fields, _, ok := commandFields(command)
+	if !ok {
+		return State{}
+	}
+	tool, args := fields[0], fields[1:]
+	var st State
+	switch tool {
+	case "git":
+		if len(args) > 0 && (args[0] == "commit" || args[0] == "merge" || args[0] == "pull") {
+			st.Head = gitHead(cwd)
+		}
+	case "ln":
+		if paths, _ := splitFlags(args); len(paths) == 2 && plainPath(paths[1]) {
+			link := resolve(cwd, paths[1])
+			if info, err := os.Lstat(link); err == nil {
+				if info.Mode()&os.ModeSymlink != 0 {
+					st.LinkTarget, _ = os.Readlink(link)
+				} else {
+					st.Occupied = true
+				}
+			}
+		}
+	case "mkdir":
+		paths, flags := splitFlags(args)
+		parents := slices.Contains(flags, "-p")
+		seen := map[string]bool{}
+		for _, path := range paths {
+			if !plainPath(path) {
+				return st
+			}
+			for dir := filepath.Clean(path); dir != "." && dir != "/" && !seen[dir] && !exists(resolve(cwd, dir)); dir = filepath.Dir(dir) {
+				seen[dir] = true
+				st.Missing = append(st.Missing, dir)
+				if !parents {
+					break
+				}
+			}
+		}
+		// Children go before their parents, so rmdir empties the tree from
+		// the bottom.
+		sort.SliceStable(st.Missing, func(i, j int) bool {
+			return strings.Count(st.Missing[i], "/") > strings.Count(st.Missing[j], "/")
+		})
+	}
+	return st
+}
+
+// Check reports why entry can no longer be undone by its rule: HEAD moved
+// on after a commit, merge, or pull, or the link ln made was changed. It
+// returns nil for entries recorded without a state.
+func Check(entry Entry) error {
+	if entry.After == nil {
+		return nil
+	}
+	now := Capture(entry.Command, entry.CWD)
+	if entry.After.Head != "" && now.Head != entry.After.Head {
+		return fmt.Errorf("HEAD has moved since %s ran (was %s, now %s); undo it by hand", entry.Command, shortSHA(entry.After.Head), shortSHA(now.Head))
+	}
+	if entry.After.LinkTarget != "" && (now.LinkTarget != entry.After.LinkTarget || now.Occupied) {
+		return fmt.Errorf("the link %s made has changed since; undo it by hand", entry.Command)
+	}
+	return nil
+}
+
+// Inverse returns the command that reverses command, run in cwd from the
+// State before, when a built-in rule knows one: git stash is undone by git
+// stash pop, mkdir by rmdir, npm install by npm uninstall. Commands that
+// chain, pipe, redirect, or expand are left to the provider, since the rule
+// would only see part of what ran.
+func Inverse(command, cwd string, before State) (string, bool) {
+	fields, prefix, ok := commandFields(command)
+	if !ok {
+		return "", false
+	}
+	inverse := inverseFields(fields, cwd, before)
+	if inverse == "" {
+		return "", false
+	}
+	return prefix + inverse, true
+}
+
+// commandFields splits command into words when a rule could cover it. A
+// leading sudo is cut off and returned as the prefix the inverse needs.
+func commandFields(command string) ([]string, string, bool) {
+	command = strings.TrimSpace(command)
+	if command == "" || strings.ContainsAny(command, "|;&<>`$\\\n") {
+		return nil, "", false
+	}
+	fields, ok := splitWords(command)
+	if !ok || len(fields) == 0 {
+		return nil, "", false
+	}
+	prefix := ""
+	if fields[0] == "sudo" {
+		prefix = "sudo "
+		fields = fields[1:]
+	}
+	return fields, prefix, len(fields) > 0
+}
+
+func inverseFields(fields []string, cwd string, before State) string {
+	tool, args := fields[0], fields[1:]
+	switch tool {
+	case "git":
+		return gitInverse(args, cwd, before)
+	case "mkdir":
+		paths, flags := splitFlags(args)
+		if len(paths) == 0 || len(flags) > 0 && !onlyFlags(flags, "-p", "-v") || len(before.Missing) == 0 {
+			return ""
+		}
+		return "rmdir " + strings.Join(before.Missing, " ")
+	case "mv":
+		paths, flags := splitFlags(args)
+		if len(paths) != 2 || len(flags) > 0 {
+			return ""
+		}
+		src, dst := paths[0], paths[1]
+		if strings.ContainsAny(src+dst, "'\"") {
+			return ""
+		}
+		// The rule runs after the move: when dst/src exists, mv moved src
+		// into the directory dst rather than renaming it.
+		if moved := filepath.Join(dst, filepath.Base(src)); exists(resolve(cwd, moved)) {
+			dst = moved
+		}
+		return "mv " + dst + " " + src
+	case "ln":
+		paths, flags := splitFlags(args)
+		if len(paths) != 2 || !onlyFlags(flags, "-s", "-sf", "-fs", "-f", "-n", "-sfn", "-snf") || !plainPath(paths[1]) || before.Occupied {
+			return ""
+		}
+		if before.LinkTarget != "" {
+			if !plainPath(before.LinkTarget) {
+				return ""
+			}
+			// ln -f replaced a link; point it back where it went.
+			return "ln -sfn " + before.LinkTarget + " " + paths[1]
+		}
+		return "rm " + paths[1]
+	case "chmod":
+		if len(args) < 2 || len(args[0]) < 2 {
+			return ""
+		}
+		mode := args[0]
+		at := strings.IndexAny(mode, "+-")
+		if at < 0 || mode[at+1:] == "" || strings.Trim(mode[at+1:], "rwxXst") != "" {
+			return ""
+		}
+		flipped := "-"
+		if mode[at] == '-' {
+			flipped = "+"
+		}
+		return "chmod " + mode[:at] + flipped + mode[at+1:] + " " + strings.Join(args[1:], " ")
+	case "pip", "pip3":
+		if len(args) < 2 || args[0] != "install" {
+			return ""
+		}
+		packages, flags := splitFlags(args[1:])
+		if len(packages) == 0 || len(flags) > 0 {
+			return ""
+		}
+		return tool + " uninstall -y " + strings.Join(packages, " ")
+	}
+	if pairs, ok := toggles[tool]; ok && len(args) >= 2 {
+		opposite, ok := pairs[args[0]]
+		targets, flags := splitFlags(args[1:])
+		if !ok || len(targets) == 0 || len(flags) > 0 {
+			return ""
+		}
+		return tool + " " + opposite + " " + strings.Join(targets, " ")
+	}
+	return ""
+}
+
+func gitInverse(args []string, cwd string, before State) string {
+	if len(args) == 0 {
+		return ""
+	}
+	sub, rest := args[0], args[1:]
+	names, flags := splitFlags(rest)
+	switch sub {
+	case "stash":
+		if len(rest) == 0 || rest[0] == "push" || rest[0] == "save" || rest[0] == "-u" {
+			return "git stash pop"
+		}
+	case "add":
+		if len(names) > 0 && len(flags) == 0 {
+			return "git restore --staged " + strings.Join(names, " ")
+		}
+		if len(names) == 0 && onlyFlags(flags, "-A", "--all", "-u", "--update") {
+			return "git reset"
+		}
+	case "commit":
+		// The first commit has no HEAD to go back to.
+		if slices.Contains(flags, "--amend") || before.Head == "" {
+			return ""
+		}
+		return "git reset --soft " + before.Head
+	case "checkout", "switch":
+		if len(rest) == 2 && (rest[0] == "-b" || rest[0] == "-c") {
+			return "git " + sub + " - && git branch -D " + rest[1]
+		}
+	case "branch":
+		if len(names) == 1 && len(flags) == 0 {
+			return "git branch -d " + names[0]
+		}
+	case "tag":
+		if len(names) == 1 && len(flags) == 0 {
+			return "git tag -d " + names[0]
+		}
+	case "merge", "pull":
+		// ORIG_HEAD can be left over from an older merge, and an
+		// up-to-date pull moved nothing.
+		if before.Head == "" || gitHead(cwd) == before.Head {
+			return ""
+		}
+		return "git reset --merge " + before.Head
+	case "rm":
+		if len(names) > 0 && onlyFlags(flags, "--cached") && len(flags) == 1 {
+			return "git add " + strings.Join(names, " ")
+		}
+	}
+	return ""
+}
+
+// splitWords splits command on spaces outside quotes. Each word keeps its
+// quotes, so it can be pasted into the inverse as it was typed. An unclosed
+// quote is not split.
+func splitWords(command string) ([]string, bool) {
+	var (
+		words []string
+		word  strings.Builder
+		quote rune
+	)
+	for _, r := range command {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == ' ' || r == '\t':
+			if word.Len() > 0 {
+				words = append(words, word.String())
+				word.Reset()
+			}
+			continue
+		}
+		word.WriteRune(r)
+	}
+	if quote != 0 {
+		return nil, false
+	}
+	if word.Len() > 0 {
+		words = append(words, word.String())
+	}
+	return words, true
+}
+
+// splitFlags separates the words that start with a dash from the rest.
+func splitFlags(args []string) (words, flags []string) {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			flags = append(flags, arg)
+			continue
+		}
+		words = append(words, arg)
+	}
+	return words, flags
+}
+
+func onlyFlags(flags []string, allowed ...string) bool {
+	for _, flag := range flags {
+		ok := false
+		for _, want := range allowed {
+			if flag == want {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// plainPath reports whether path can go into an inverse as it is: no
+// quotes or spaces to carry over.
+func plainPath(path string) bool {
+	return path != "" && !strings.ContainsAny(path, "'\" \t")
+}
+
+// gitHead is the commit HEAD points at in dir, or "" outside a repository
+// or before the first commit.
+func gitHead(dir string) string {
+	cmd := exec.Command("git", "rev-parse", "--verify", "--quiet", "HEAD")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func shortSHA(sha string) string {
+	if sha == "" {
+		return "nothing"
+	}
+	return sha[:min(len(sha), 12)]
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+func resolve(cwd, path string) string {
+	if filepath.IsAbs(path) || cwd == "" {
+		return path
+	}
+	return filepath.Join(cwd, path)
+}
@@ -0,0 +1,120 @@
+// Package undo remembers the commands ew ran that changed something, each
+// with the command that reverses it when a rule knows one, so "ew undo" can
+// roll back the latest of them.
+package undo
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ashwch/ew/internal/state"
+)
+
+const fileName = "undo.jsonl"
+
+// maxEntries is how many executions the log keeps; only the newest one
+// that was not undone yet is ever offered.
+const maxEntries = 50
+
+// Entry is one command ew ran.
+type Entry struct {
+	ID        string `json:"id"`
+	Timestamp string `json:"timestamp"`
+	Command   string `json:"command"`
+	// Inverse reverses Command. It is empty when no rule knows how, and
+	// the provider is asked when the entry is undone.
+	Inverse string `json:"inverse,omitempty"`
+	// After is the State the command left behind, for Check. It is only
+	// kept with a rule's inverse.
+	After     *State `json:"after,omitempty"`
+	CWD       string `json:"cwd,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+	// Undoes is set on the marker appended once an entry was rolled back,
+	// and names that entry.
+	Undoes string `json:"undoes,omitempty"`
+}
+
+// Record appends an executed command to the log.
+func Record(entry Entry) error {
+	entry.Command = strings.TrimSpace(entry.Command)
+	if entry.Command == "" {
+		return nil
+	}
+	now := time.Now().UTC()
+	if entry.Timestamp == "" {
+		entry.Timestamp = now.Format(time.RFC3339)
+	}
+	if entry.ID == "" {
+		entry.ID = strconv.FormatInt(now.UnixNano(), 36)
+	}
+	entry.Undoes = ""
+	return appendEntry(entry)
+}
+
+// MarkUndone records that the entry with id was rolled back, so Latest
+// moves on to the one before it.
+func MarkUndone(id string) error {
+	if strings.TrimSpace(id) == "" {
+		return nil
+	}
+	return appendEntry(Entry{Timestamp: time.Now().UTC().Format(time.RFC3339), Undoes: id})
+}
+
+// Latest returns the newest entry that was not undone, or nil when there is
+// none.
+func Latest() (*Entry, error) {
+	entries, err := load()
+	if err != nil {
+		return nil, err
+	}
+	undone := map[string]bool{}
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if entry.Undoes != "" {
+			undone[entry.Undoes] = true
+			continue
+		}
+		if !undone[entry.ID] {
+			return &entry, nil
+		}
+	}
+	return nil, nil
+}
+
+func load() ([]Entry, error) {
+	backend, err := state.Current()
+	if err != nil {
+		return nil, err
+	}
+	records, err := backend.Records(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("could not read undo log: %w", err)
+	}
+	entries := make([]Entry, 0, len(records))
+	for _, record := range records {
+		var entry Entry
+		if err := json.Unmarshal(record, &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func appendEntry(entry Entry) error {
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("could not encode undo entry: %w", err)
+	}
+	backend, err := state.Current()
+	if err != nil {
+		return err
+	}
+	if err := backend.Append(fileName, encoded, maxEntries); err != nil {
+		return fmt.Errorf("could not write undo log: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package undo
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestInverseRules(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "archive", "notes.txt"), 0o755); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+
+	for _, tc := range []struct {
+		command string
+		before  State
+		want    string
+	}{
+		{"git stash", State{}, "git stash pop"},
+		{"git stash push -u", State{}, "git stash pop"},
+		{"git add main.go README.md", State{}, "git restore --staged main.go README.md"},
+		{"git add -A", State{}, "git reset"},
+		{`git commit -m "fix the build"`, State{Head: "4f1c2a"}, "git reset --soft 4f1c2a"},
+		{"git checkout -b feature/x", State{}, "git checkout - && git branch -D feature/x"},
+		{"git tag v1.2.0", State{}, "git tag -d v1.2.0"},
+		{"git pull", State{Head: "4f1c2a"}, "git reset --merge 4f1c2a"},
+		{"mkdir -p build/out", State{Missing: []string{"build/out", "build"}}, "rmdir build/out build"},
+		{"mkdir build", State{Missing: []string{"build"}}, "rmdir build"},
+		{"mv old.txt new.txt", State{}, "mv new.txt old.txt"},
+		{"mv notes.txt archive", State{}, "mv archive/notes.txt notes.txt"},
+		{"ln -s /opt/tool bin/tool", State{}, "rm bin/tool"},
+		{"ln -sf /opt/tool-2 bin/tool", State{LinkTarget: "/opt/tool-1"}, "ln -sfn /opt/tool-1 bin/tool"},
+		{"chmod +x deploy.sh", State{}, "chmod -x deploy.sh"},
+		{"sudo systemctl start nginx", State{}, "sudo systemctl stop nginx"},
+		{"docker stop web", State{}, "docker start web"},
+		{"brew install jq", State{}, "brew uninstall jq"},
+		{"npm install left-pad", State{}, "npm uninstall left-pad"},
+		{"pip install requests", State{}, "pip uninstall -y requests"},
+	} {
+		got, ok := Inverse(tc.command, dir, tc.before)
+		if !ok || got != tc.want {
+			t.Fatalf("Inverse(%q) = %q, %v; want %q", tc.command, got, ok, tc.want)
+		}
+	}
+
+	for _, tc := range []struct {
+		command string
+		before  State
+	}{
+		{"git commit --amend --no-edit", State{Head: "4f1c2a"}},
+		{"git commit -m first", State{}},
+		{"git pull", State{}},
+		{"git add -p", State{}},
+		{"git push", State{}},
+		{"rm -rf build", State{}},
+		{"chmod -R +x bin", State{}},
+		{"chmod 755 deploy.sh", State{}},
+		{"npm install", State{}},
+		{"mkdir a && cd a", State{}},
+		{"mkdir -p build", State{}},
+		{"ln -sf /opt/tool bin", State{Occupied: true}},
+		{"mv $SRC dst", State{}},
+		{`git commit -m "unclosed`, State{Head: "4f1c2a"}},
+	} {
+		if got, ok := Inverse(tc.command, dir, tc.before); ok {
+			t.Fatalf("expected no rule for %q, got %q", tc.command, got)
+		}
+	}
+}
+
+func TestCaptureRecordsWhatTheInverseRestores(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "a"), 0o755); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+	if got := Capture("mkdir -p a/b/c a/d", dir).Missing; !slices.Equal(got, []string{"a/b/c", "a/b", "a/d"}) {
+		t.Fatalf("expected the missing dirs deepest first, got %q", got)
+	}
+	if got := Capture("mkdir x/y", dir).Missing; !slices.Equal(got, []string{"x/y"}) {
+		t.Fatalf("expected only the named dir without -p, got %q", got)
+	}
+
+	link := filepath.Join(dir, "current")
+	if err := os.Symlink("release-1", link); err != nil {
+		t.Fatalf("symlink failed: %v", err)
+	}
+	if got := Capture("ln -sfn release-2 current", dir); got.LinkTarget != "release-1" || got.Occupied {
+		t.Fatalf("expected the old link target, got %+v", got)
+	}
+	if got := Capture("ln -sf release-2 a", dir); !got.Occupied {
+		t.Fatalf("expected a directory at the link path to count as occupied, got %+v", got)
+	}
+
+	entry := Entry{Command: "ln -sfn release-2 current", CWD: dir, After: &State{LinkTarget: "release-1"}}
+	if err := Check(entry); err != nil {
+		t.Fatalf("expected an unchanged link to pass, got %v", err)
+	}
+	entry.After.LinkTarget = "release-2"
+	if err := Check(entry); err == nil {
+		t.Fatalf("expected a changed link to refuse the undo")
+	}
+}
+
+func TestCheckRefusesOnceHeadMoved(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	commit := func(message string) {
+		cmd := exec.Command("git", "-c", "user.name=ew", "-c", "user.email=ew@example.com", "commit", "--allow-empty", "-qm", message)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git commit failed: %v %s", err, out)
+		}
+	}
+	init := exec.Command("git", "init", "-q")
+	init.Dir = dir
+	if out, err := init.CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v %s", err, out)
+	}
+	if st := Capture("git commit -m first", dir); st.Head != "" {
+		t.Fatalf("expected no HEAD before the first commit, got %q", st.Head)
+	}
+	commit("first")
+	before := Capture("git commit -m second", dir)
+	commit("second")
+	inverse, ok := Inverse("git commit -m second", dir, before)
+	if !ok || inverse != "git reset --soft "+before.Head {
+		t.Fatalf("expected a reset to the pre-commit HEAD, got %q %v", inverse, ok)
+	}
+
+	after := Capture("git commit -m second", dir)
+	entry := Entry{Command: "git commit -m second", CWD: dir, Inverse: inverse, After: &after}
+	if err := Check(entry); err != nil {
+		t.Fatalf("expected the undo allowed while HEAD is unchanged, got %v", err)
+	}
+	commit("third")
+	if err := Check(entry); err == nil || !strings.Contains(err.Error(), "HEAD has moved") {
+		t.Fatalf("expected the undo refused after another commit, got %v", err)
+	}
+}
+
+func TestLatestSkipsUndoneEntries(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, ".local", "state"))
+
+	if entry, err := Latest(); err != nil || entry != nil {
+		t.Fatalf("expected nothing to undo, got %+v %v", entry, err)
+	}
+	if err := Record(Entry{ID: "a", Command: "git stash", Inverse: "git stash pop"}); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	if err := Record(Entry{ID: "b", Command: "mkdir out", Inverse: "rmdir out"}); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	entry, err := Latest()
+	if err != nil || entry == nil || entry.ID != "b" || entry.Timestamp == "" {
+		t.Fatalf("expected the newest entry, got %+v %v", entry, err)
+	}
+	if err := MarkUndone("b"); err != nil {
+		t.Fatalf("MarkUndone failed: %v", err)
+	}
+	if entry, err = Latest(); err != nil || entry == nil || entry.Command != "git stash" {
+		t.Fatalf("expected the entry before the undone one, got %+v %v", entry, err)
+	}
+	if err := MarkUndone("a"); err != nil {
+		t.Fatalf("MarkUndone failed: %v", err)
+	}
+	if entry, err = Latest(); err != nil || entry != nil {
+		t.Fatalf("expected nothing left to undo, got %+v %v", entry, err)
+	}
+}
@@ -0,0 +1,183 @@
+package usage
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ashwch/ew/internal/hook"
+	"github.com/ashwch/ew/internal/session"
+)
+
+// calibrationBandEdges split stated confidence into bands: below 0.5,
+// 0.5-0.7, 0.7-0.85, 0.85-0.95, and 0.95 and up.
+var calibrationBandEdges = []float64{0.5, 0.7, 0.85, 0.95}
+
+const (
+	// calibrationPrior is how many answers' worth of weight the stated
+	// confidence keeps in a band, so a handful of outcomes only nudges it.
+	calibrationPrior = 5
+	// ranLaterWindow is how long after a suggestion a captured command
+	// still counts as the user running it.
+	ranLaterWindow = 30 * time.Minute
+)
+
+// CalibrationBand is how one provider's answers in a band of stated
+// confidence turned out. Accepted answers were run, by ew or by the user
+// in a hooked shell; Succeeded ones ran with exit 0.
+type CalibrationBand struct {
+	Low        float64 `json:"low"`
+	High       float64 `json:"high"`
+	Answers    int     `json:"answers"`
+	Accepted   int     `json:"accepted"`
+	Succeeded  int     `json:"succeeded"`
+	Stated     float64 `json:"stated"`
+	Calibrated float64 `json:"calibrated"`
+}
+
+// ProviderCalibration is one provider's bands, lowest first. Bands without
+// answers are left out.
+type ProviderCalibration struct {
+	Provider string            `json:"provider"`
+	Answers  int               `json:"answers"`
+	Bands    []CalibrationBand `json:"bands"`
+}
+
+// Calibration maps what providers say about their confidence to how often
+// their answers actually worked for this user.
+type Calibration struct {
+	Providers []ProviderCalibration `json:"providers"`
+}
+
+// Calibrate builds the calibration from the session journal. An answer's
+// outcome is what ew recorded when it ran or was declined; an answer that
+// was only suggested counts as accepted when the hook events show the user
+// running it soon after, and as passed over otherwise. Without hook events
+// suggestions have no outcome and are skipped.
+func Calibrate(interactions []session.Interaction, events []hook.Event) Calibration {
+	ran := ranCommands(events)
+	byProvider := map[string][]CalibrationBand{}
+	for _, item := range interactions {
+		if item.Provider == "" || item.Confidence <= 0 || strings.TrimSpace(item.Command) == "" {
+			continue
+		}
+		accepted, succeeded, known := interactionOutcome(item, ran, len(events) > 0)
+		if !known {
+			continue
+		}
+		bands, ok := byProvider[item.Provider]
+		if !ok {
+			bands = newCalibrationBands()
+			byProvider[item.Provider] = bands
+		}
+		band := &bands[calibrationBand(item.Confidence)]
+		band.Answers++
+		band.Stated += item.Confidence
+		if accepted {
+			band.Accepted++
+		}
+		if succeeded {
+			band.Succeeded++
+		}
+	}
+
+	out := Calibration{Providers: []ProviderCalibration{}}
+	for name, bands := range byProvider {
+		provider := ProviderCalibration{Provider: name, Bands: []CalibrationBand{}}
+		for _, band := range bands {
+			if band.Answers == 0 {
+				continue
+			}
+			band.Stated /= float64(band.Answers)
+			band.Calibrated = (float64(band.Succeeded) + calibrationPrior*band.Stated) / float64(band.Answers+calibrationPrior)
+			provider.Answers += band.Answers
+			provider.Bands = append(provider.Bands, band)
+		}
+		out.Providers = append(out.Providers, provider)
+	}
+	sort.Slice(out.Providers, func(i, j int) bool { return out.Providers[i].Provider < out.Providers[j].Provider })
+	return out
+}
+
+// Adjust turns a provider's stated confidence into the calibrated one. A
+// provider or band with no recorded answers keeps its stated confidence.
+func (c Calibration) Adjust(provider string, confidence float64) float64 {
+	for _, p := range c.Providers {
+		if p.Provider != provider {
+			continue
+		}
+		low := newCalibrationBands()[calibrationBand(confidence)].Low
+		for _, band := range p.Bands {
+			if band.Low == low {
+				return band.Calibrated
+			}
+		}
+	}
+	return confidence
+}
+
+func newCalibrationBands() []CalibrationBand {
+	bands := make([]CalibrationBand, 0, len(calibrationBandEdges)+1)
+	low := 0.0
+	for _, edge := range calibrationBandEdges {
+		bands = append(bands, CalibrationBand{Low: low, High: edge})
+		low = edge
+	}
+	return append(bands, CalibrationBand{Low: low, High: 1})
+}
+
+func calibrationBand(confidence float64) int {
+	for idx, edge := range calibrationBandEdges {
+		if confidence < edge {
+			return idx
+		}
+	}
+	return len(calibrationBandEdges)
+}
+
+// interactionOutcome says whether an answer was accepted and whether it
+// worked, and whether either is known at all.
+func interactionOutcome(item session.Interaction, ran map[string][]hook.Event, hooked bool) (accepted, succeeded, known bool) {
+	switch item.Decision {
+	case session.DecisionExecuted:
+		return true, true, true
+	case session.DecisionFailed:
+		return true, false, true
+	case session.DecisionNotExecuted:
+		return false, false, true
+	case session.DecisionSuggested:
+		if !hooked {
+			return false, false, false
+		}
+		at, err := time.Parse(time.RFC3339, item.Timestamp)
+		if err != nil {
+			return false, false, false
+		}
+		for _, ev := range ran[commandKey(item.Command)] {
+			when, err := time.Parse(time.RFC3339, ev.Timestamp)
+			if err != nil || when.Before(at) || when.Sub(at) > ranLaterWindow {
+				continue
+			}
+			if item.SessionID != "" && ev.SessionID != "" && ev.SessionID != item.SessionID {
+				continue
+			}
+			return true, ev.ExitCode == 0, true
+		}
+		return false, false, true
+	}
+	return false, false, false
+}
+
+func ranCommands(events []hook.Event) map[string][]hook.Event {
+	ran := make(map[string][]hook.Event, len(events))
+	for _, ev := range events {
+		if key := commandKey(ev.Command); key != "" {
+			ran[key] = append(ran[key], ev)
+		}
+	}
+	return ran
+}
+
+func commandKey(command string) string {
+	return strings.Join(strings.Fields(command), " ")
+}
@@ -0,0 +1,55 @@
+package usage
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ashwch/ew/internal/hook"
+	"github.com/ashwch/ew/internal/session"
+)
+
+func TestCalibrateScoresAnswersByWhatHappenedToThem(t *testing.T) {
+	answer := func(decision, command, at string) session.Interaction {
+		return session.Interaction{Provider: "codex", Confidence: 0.9, Decision: decision, Command: command, Timestamp: at, SessionID: "s1"}
+	}
+	interactions := []session.Interaction{
+		answer(session.DecisionExecuted, "make", "2026-03-10T09:00:00Z"),
+		answer(session.DecisionFailed, "make test", "2026-03-10T09:01:00Z"),
+		answer(session.DecisionNotExecuted, "rm -rf build", "2026-03-10T09:02:00Z"),
+		answer(session.DecisionSuggested, "git pull", "2026-03-10T09:03:00Z"),
+		answer(session.DecisionSuggested, "git push", "2026-03-10T09:04:00Z"),
+		{Provider: "claude", Confidence: 0.6, Decision: session.DecisionExecuted, Command: "ls", Timestamp: "2026-03-10T09:05:00Z"},
+		{Decision: session.DecisionExecuted, Command: "ls", Timestamp: "2026-03-10T09:06:00Z"},
+	}
+	events := []hook.Event{
+		{Command: "git  pull", ExitCode: 0, SessionID: "s1", Timestamp: "2026-03-10T09:05:00Z"},
+		{Command: "git push", ExitCode: 0, SessionID: "s2", Timestamp: "2026-03-10T09:05:00Z"},
+	}
+
+	calibration := Calibrate(interactions, events)
+	if len(calibration.Providers) != 2 || calibration.Providers[0].Provider != "claude" {
+		t.Fatalf("expected one entry per provider, sorted, got %+v", calibration.Providers)
+	}
+	codex := calibration.Providers[1]
+	if len(codex.Bands) != 1 {
+		t.Fatalf("expected only the band with answers, got %+v", codex.Bands)
+	}
+	band := codex.Bands[0]
+	if band.Low != 0.85 || band.Answers != 5 || band.Accepted != 3 || band.Succeeded != 2 {
+		t.Fatalf("expected 5 answers, 3 run (one by the user), 2 worked, got %+v", band)
+	}
+	want := (2 + calibrationPrior*0.9) / (5 + calibrationPrior)
+	if math.Abs(band.Calibrated-want) > 1e-9 {
+		t.Fatalf("expected calibrated %.3f, got %.3f", want, band.Calibrated)
+	}
+	if got := calibration.Adjust("codex", 0.88); got != band.Calibrated {
+		t.Fatalf("expected the band's calibrated confidence, got %.3f", got)
+	}
+	if calibration.Adjust("codex", 0.3) != 0.3 || calibration.Adjust("other", 0.9) != 0.9 {
+		t.Fatalf("expected bands and providers without answers to keep the stated confidence")
+	}
+
+	if got := Calibrate(interactions[3:5], nil); len(got.Providers) != 0 {
+		t.Fatalf("expected suggestions to have no outcome without hook events, got %+v", got)
+	}
+}
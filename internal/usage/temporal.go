@@ -0,0 +1,89 @@
+package usage
+
+import (
+	"strings"
+	"time"
+
+	"github.com/ashwch/ew/internal/hook"
+)
+
+// Temporal boosts are capped so habit only reorders close candidates and
+// never outweighs a clearly better text match.
+const (
+	MaxHourBoost       = 3.0
+	MaxWeekdayBoost    = 2.0
+	temporalMinRuns    = 4
+	temporalHourWindow = 1
+	// A command whose share of runs beats uniform by this much gets the
+	// full boost.
+	temporalFullLift = 0.5
+)
+
+type temporalCounts struct {
+	total    int
+	hours    [24]int
+	weekdays [7]int
+}
+
+// TemporalProfile records when each captured command usually succeeds.
+type TemporalProfile struct {
+	commands map[string]*temporalCounts
+}
+
+// NewTemporalProfile learns hour-of-day and weekday habits from successful
+// hook events, read in loc.
+func NewTemporalProfile(events []hook.Event, loc *time.Location) TemporalProfile {
+	profile := TemporalProfile{commands: map[string]*temporalCounts{}}
+	for _, ev := range events {
+		if ev.ExitCode != 0 {
+			continue
+		}
+		command := temporalKey(ev.Command)
+		at, err := time.Parse(time.RFC3339, strings.TrimSpace(ev.Timestamp))
+		if command == "" || err != nil {
+			continue
+		}
+		at = at.In(loc)
+		counts, ok := profile.commands[command]
+		if !ok {
+			counts = &temporalCounts{}
+			profile.commands[command] = counts
+		}
+		counts.total++
+		counts.hours[at.Hour()]++
+		counts.weekdays[int(at.Weekday())]++
+	}
+	return profile
+}
+
+// Boost returns the hour-of-day and weekday contributions for command at
+// now. Both are zero until the command has enough runs to show a habit.
+func (p TemporalProfile) Boost(command string, now time.Time) (float64, float64) {
+	counts, ok := p.commands[temporalKey(command)]
+	if !ok || counts.total < temporalMinRuns {
+		return 0, 0
+	}
+	nearHour := 0
+	for offset := -temporalHourWindow; offset <= temporalHourWindow; offset++ {
+		nearHour += counts.hours[(now.Hour()+offset+24)%24]
+	}
+	hourShare := float64(nearHour) / float64(counts.total)
+	hourUniform := float64(2*temporalHourWindow+1) / 24
+	weekdayShare := float64(counts.weekdays[int(now.Weekday())]) / float64(counts.total)
+	return liftBoost(hourShare, hourUniform, MaxHourBoost), liftBoost(weekdayShare, 1.0/7, MaxWeekdayBoost)
+}
+
+func liftBoost(share, uniform, max float64) float64 {
+	lift := share - uniform
+	if lift <= 0 {
+		return 0
+	}
+	if lift >= temporalFullLift {
+		return max
+	}
+	return max * lift / temporalFullLift
+}
+
+func temporalKey(command string) string {
+	return strings.Join(strings.Fields(command), " ")
+}
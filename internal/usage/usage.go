@@ -0,0 +1,336 @@
+package usage
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ashwch/ew/internal/hook"
+	"github.com/ashwch/ew/internal/memory"
+	"github.com/ashwch/ew/internal/router"
+	"github.com/ashwch/ew/internal/session"
+)
+
+// FixWindowDays is how far back the fix success series reaches.
+const FixWindowDays = 14
+
+type CommandCount struct {
+	Command  string `json:"command"`
+	Count    int    `json:"count"`
+	LastUsed string `json:"last_used,omitempty"`
+}
+
+type MemoryUse struct {
+	Query   string  `json:"query"`
+	Command string  `json:"command"`
+	Uses    int     `json:"uses"`
+	Score   float64 `json:"score"`
+}
+
+type FixDay struct {
+	Day       string `json:"day"`
+	Attempts  int    `json:"attempts"`
+	Successes int    `json:"successes"`
+}
+
+type LatencySummary struct {
+	Samples  int     `json:"samples"`
+	MedianMS int64   `json:"median_ms"`
+	P90MS    int64   `json:"p90_ms"`
+	P95MS    int64   `json:"p95_ms"`
+	P99MS    int64   `json:"p99_ms"`
+	RecentMS []int64 `json:"recent_ms"`
+}
+
+// Rate is how many of Total went one way, such as fixes that were run out
+// of fixes suggested.
+type Rate struct {
+	Count int     `json:"count"`
+	Total int     `json:"total"`
+	Rate  float64 `json:"rate"`
+}
+
+// Report is the read-only usage summary behind `ew --top` and `ew stats`.
+type Report struct {
+	GeneratedAt     string         `json:"generated_at"`
+	TopCommands     []CommandCount `json:"top_commands"`
+	TopFailures     []CommandCount `json:"top_failures"`
+	TopSuggestions  []CommandCount `json:"top_suggestions"`
+	TopMemory       []MemoryUse    `json:"top_memory"`
+	MemoryHits      Rate           `json:"memory_hits"`
+	FixSuccess      []FixDay       `json:"fix_success"`
+	FixAttempts     int            `json:"fix_attempts"`
+	FixSuccessRate  float64        `json:"fix_success_rate"`
+	FixAcceptance   Rate           `json:"fix_acceptance"`
+	ProviderLatency LatencySummary `json:"provider_latency"`
+	Calibration     Calibration    `json:"calibration"`
+}
+
+// Sources are the local stores a report is built from.
+type Sources struct {
+	Events       []hook.Event
+	Memory       memory.Store
+	Interactions []session.Interaction
+}
+
+// Build summarizes sources as of now, keeping up to limit rows per list.
+func Build(src Sources, now time.Time, limit int) Report {
+	if limit <= 0 {
+		limit = 10
+	}
+	// 128 and up are signals, such as Ctrl-C, not failures.
+	failed := func(ev hook.Event) bool { return ev.ExitCode != 0 && ev.ExitCode < 128 }
+	report := Report{
+		GeneratedAt:    now.UTC().Format(time.RFC3339),
+		TopCommands:    FrequentCommands(src.Events, limit, nil),
+		TopFailures:    FrequentCommands(src.Events, limit, failed),
+		TopSuggestions: usedSuggestions(src.Interactions, limit),
+		TopMemory:      topMemory(src.Memory, limit),
+		MemoryHits:     memoryHits(src.Interactions),
+		FixSuccess:     fixSeries(src.Interactions, now),
+		FixAcceptance:  fixAcceptance(src.Interactions),
+	}
+	successes := 0
+	for _, day := range report.FixSuccess {
+		report.FixAttempts += day.Attempts
+		successes += day.Successes
+	}
+	if report.FixAttempts > 0 {
+		report.FixSuccessRate = float64(successes) / float64(report.FixAttempts)
+	}
+	report.ProviderLatency = latencySummary(src.Interactions, 30)
+	report.Calibration = Calibrate(src.Interactions, src.Events)
+	return report
+}
+
+// FrequentCommands counts captured commands, most frequent first; ties go to
+// the most recently used. keep filters events when non-nil.
+func FrequentCommands(events []hook.Event, limit int, keep func(hook.Event) bool) []CommandCount {
+	counts := map[string]*CommandCount{}
+	order := []string{}
+	for _, ev := range events {
+		command := strings.Join(strings.Fields(ev.Command), " ")
+		if command == "" || (keep != nil && !keep(ev)) {
+			continue
+		}
+		entry, ok := counts[command]
+		if !ok {
+			entry = &CommandCount{Command: command}
+			counts[command] = entry
+			order = append(order, command)
+		}
+		entry.Count++
+		if ev.Timestamp > entry.LastUsed {
+			entry.LastUsed = ev.Timestamp
+		}
+	}
+	out := make([]CommandCount, 0, len(order))
+	for _, command := range order {
+		out = append(out, *counts[command])
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Count == out[j].Count {
+			return out[i].LastUsed > out[j].LastUsed
+		}
+		return out[i].Count > out[j].Count
+	})
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
+
+func topMemory(store memory.Store, limit int) []MemoryUse {
+	out := make([]MemoryUse, 0, len(store.Entries))
+	for _, entry := range store.Entries {
+		out = append(out, MemoryUse{Query: entry.Query, Command: entry.Command, Uses: entry.Uses, Score: entry.Score})
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Uses == out[j].Uses {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].Uses > out[j].Uses
+	})
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
+
+// fixSeries buckets executed fix suggestions by UTC day over the last
+// FixWindowDays, oldest first. Fixes that were only suggested have no
+// outcome and are not counted.
+func fixSeries(interactions []session.Interaction, now time.Time) []FixDay {
+	today := now.UTC().Truncate(24 * time.Hour)
+	days := make([]FixDay, FixWindowDays)
+	index := map[string]int{}
+	for i := range days {
+		day := today.AddDate(0, 0, i-FixWindowDays+1).Format("2006-01-02")
+		days[i].Day = day
+		index[day] = i
+	}
+	for _, item := range interactions {
+		if item.Intent != string(router.IntentFix) {
+			continue
+		}
+		if item.Decision != session.DecisionExecuted && item.Decision != session.DecisionFailed {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, item.Timestamp)
+		if err != nil {
+			continue
+		}
+		idx, ok := index[at.UTC().Format("2006-01-02")]
+		if !ok {
+			continue
+		}
+		days[idx].Attempts++
+		if item.Decision == session.DecisionExecuted {
+			days[idx].Successes++
+		}
+	}
+	return days
+}
+
+func latencySummary(interactions []session.Interaction, recent int) LatencySummary {
+	samples := []int64{}
+	for _, item := range interactions {
+		if item.LatencyMS > 0 {
+			samples = append(samples, item.LatencyMS)
+		}
+	}
+	summary := LatencySummary{Samples: len(samples), RecentMS: []int64{}}
+	if len(samples) == 0 {
+		return summary
+	}
+	if len(samples) > recent {
+		summary.RecentMS = append(summary.RecentMS, samples[len(samples)-recent:]...)
+	} else {
+		summary.RecentMS = append(summary.RecentMS, samples...)
+	}
+	sorted := append([]int64(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	summary.MedianMS = sorted[len(sorted)/2]
+	summary.P90MS = sorted[(len(sorted)*90)/100]
+	summary.P95MS = sorted[(len(sorted)*95)/100]
+	summary.P99MS = sorted[(len(sorted)*99)/100]
+	return summary
+}
+
+// usedSuggestions counts the commands ew suggested that were then run,
+// whether or not they worked, most run first.
+func usedSuggestions(interactions []session.Interaction, limit int) []CommandCount {
+	events := []hook.Event{}
+	for _, item := range interactions {
+		if ranSuggestion(item) {
+			events = append(events, hook.Event{Command: item.Command, Timestamp: item.Timestamp})
+		}
+	}
+	return FrequentCommands(events, limit, nil)
+}
+
+// fixAcceptance is how many suggested fixes were run. Interrupted requests
+// and fixes ew had no answer for are not counted.
+func fixAcceptance(interactions []session.Interaction) Rate {
+	var rate Rate
+	for _, item := range interactions {
+		if item.Intent != string(router.IntentFix) || item.Command == "" {
+			continue
+		}
+		if item.Decision == session.DecisionNone || item.Decision == session.DecisionInterrupted {
+			continue
+		}
+		rate.Total++
+		if ranSuggestion(item) {
+			rate.Count++
+		}
+	}
+	return rate.settle()
+}
+
+// memoryHits is how many find and run answers came from memory rather than
+// history or a provider.
+func memoryHits(interactions []session.Interaction) Rate {
+	var rate Rate
+	for _, item := range interactions {
+		if item.Intent != string(router.IntentFind) && item.Intent != string(router.IntentRun) {
+			continue
+		}
+		if item.Command == "" || item.Decision == session.DecisionNone {
+			continue
+		}
+		rate.Total++
+		if item.Source == "memory" {
+			rate.Count++
+		}
+	}
+	return rate.settle()
+}
+
+func ranSuggestion(item session.Interaction) bool {
+	return item.Command != "" && (item.Decision == session.DecisionExecuted || item.Decision == session.DecisionFailed)
+}
+
+func (r Rate) settle() Rate {
+	if r.Total > 0 {
+		r.Rate = float64(r.Count) / float64(r.Total)
+	}
+	return r
+}
+
+// Scopes reported by FrequentHere, narrowest first.
+const (
+	ScopeDirectoryHour = "this directory around this time of day"
+	ScopeDirectory     = "this directory"
+	ScopeEverywhere    = "all directories"
+)
+
+// frequentHereMinimum is how many distinct commands a scope needs before
+// FrequentHere stops widening it.
+const frequentHereMinimum = 3
+
+// FrequentHere returns the most reused successful commands for cwd near the
+// hour of now, widening to the whole directory and then to every directory
+// when a narrower scope has too little data. It also returns the scope used.
+func FrequentHere(events []hook.Event, cwd string, now time.Time, limit int) ([]CommandCount, string) {
+	succeeded := func(ev hook.Event) bool { return ev.ExitCode == 0 }
+	inDir := func(ev hook.Event) bool { return succeeded(ev) && cwd != "" && ev.CWD == cwd }
+	nearHour := func(ev hook.Event) bool {
+		if !inDir(ev) {
+			return false
+		}
+		at, err := time.Parse(time.RFC3339, ev.Timestamp)
+		if err != nil {
+			return false
+		}
+		return hourDistance(at.In(now.Location()).Hour(), now.Hour()) <= 2
+	}
+
+	scopes := []struct {
+		name string
+		keep func(hook.Event) bool
+	}{
+		{ScopeDirectoryHour, nearHour},
+		{ScopeDirectory, inDir},
+		{ScopeEverywhere, succeeded},
+	}
+	var last []CommandCount
+	for _, scope := range scopes {
+		last = FrequentCommands(events, limit, scope.keep)
+		if len(last) >= frequentHereMinimum || scope.name == ScopeEverywhere {
+			return last, scope.name
+		}
+	}
+	return last, ScopeEverywhere
+}
+
+func hourDistance(a, b int) int {
+	d := a - b
+	if d < 0 {
+		d = -d
+	}
+	if d > 12 {
+		d = 24 - d
+	}
+	return d
+}
@@ -0,0 +1,151 @@
+package usage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ashwch/ew/internal/hook"
+	"github.com/ashwch/ew/internal/memory"
+	"github.com/ashwch/ew/internal/session"
+)
+
+func TestBuildSummarizesStores(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	store := memory.Store{}
+	_ = store.Remember("push branch", "git push origin HEAD")
+	_ = store.Learn("push branch", "git push origin HEAD", true)
+	_ = store.Remember("list pods", "kubectl get pods")
+
+	report := Build(Sources{
+		Events: []hook.Event{
+			{Command: "git status", Timestamp: "2026-03-10T10:00:00Z"},
+			{Command: "make test", Timestamp: "2026-03-10T10:01:00Z"},
+			{Command: "git  status", Timestamp: "2026-03-10T10:02:00Z"},
+		},
+		Memory: store,
+		Interactions: []session.Interaction{
+			{Intent: "fix", Decision: session.DecisionExecuted, Timestamp: "2026-03-10T09:00:00Z", LatencyMS: 900},
+			{Intent: "fix", Decision: session.DecisionFailed, Timestamp: "2026-03-09T09:00:00Z", LatencyMS: 1200},
+			{Intent: "fix", Decision: session.DecisionSuggested, Timestamp: "2026-03-09T09:00:00Z"},
+			{Intent: "fix", Decision: session.DecisionExecuted, Timestamp: "2026-01-01T09:00:00Z"},
+			{Intent: "find", Decision: session.DecisionExecuted, Timestamp: "2026-03-10T09:00:00Z", LatencyMS: 300},
+		},
+	}, now, 5)
+
+	if len(report.TopCommands) != 2 || report.TopCommands[0].Command != "git status" || report.TopCommands[0].Count != 2 {
+		t.Fatalf("unexpected top commands: %+v", report.TopCommands)
+	}
+	if report.TopMemory[0].Command != "git push origin HEAD" || report.TopMemory[0].Uses != 2 {
+		t.Fatalf("unexpected top memory: %+v", report.TopMemory)
+	}
+	if len(report.FixSuccess) != FixWindowDays || report.FixSuccess[FixWindowDays-1].Day != "2026-03-10" {
+		t.Fatalf("expected %d daily buckets ending today, got %+v", FixWindowDays, report.FixSuccess)
+	}
+	if report.FixAttempts != 2 || report.FixSuccessRate != 0.5 {
+		t.Fatalf("expected 1 of 2 executed fixes to succeed, got %d attempts rate %v", report.FixAttempts, report.FixSuccessRate)
+	}
+	if report.ProviderLatency.Samples != 3 || report.ProviderLatency.MedianMS != 900 || report.ProviderLatency.P95MS != 1200 {
+		t.Fatalf("unexpected latency summary: %+v", report.ProviderLatency)
+	}
+}
+
+func TestBuildRatesSuggestionsAndFailures(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	report := Build(Sources{
+		Events: []hook.Event{
+			{Command: "make test", ExitCode: 2, Timestamp: "2026-03-10T10:00:00Z"},
+			{Command: "make test", ExitCode: 2, Timestamp: "2026-03-10T10:05:00Z"},
+			{Command: "sleep 100", ExitCode: 130, Timestamp: "2026-03-10T10:06:00Z"},
+			{Command: "ls", Timestamp: "2026-03-10T10:07:00Z"},
+		},
+		Interactions: []session.Interaction{
+			{Intent: "fix", Command: "make test -j1", Decision: session.DecisionExecuted},
+			{Intent: "fix", Command: "make deps", Decision: session.DecisionNotExecuted},
+			{Intent: "fix", Command: "make deps", Decision: session.DecisionSuggested},
+			{Intent: "fix", Decision: session.DecisionNone},
+			{Intent: "find", Command: "kubectl get pods", Source: "memory", Decision: session.DecisionExecuted, Timestamp: "2026-03-10T09:00:00Z"},
+			{Intent: "find", Command: "kubectl get pods", Source: "memory", Decision: session.DecisionFailed, Timestamp: "2026-03-10T09:30:00Z"},
+			{Intent: "find", Command: "git log", Source: "claude", Decision: session.DecisionSuggested},
+			{Intent: "run", Command: "git pull", Source: "history", Decision: session.DecisionExecuted},
+		},
+	}, now, 5)
+
+	if len(report.TopFailures) != 1 || report.TopFailures[0].Command != "make test" || report.TopFailures[0].Count != 2 {
+		t.Fatalf("expected only real failures, got %+v", report.TopFailures)
+	}
+	if report.FixAcceptance.Count != 1 || report.FixAcceptance.Total != 3 {
+		t.Fatalf("expected 1 of 3 suggested fixes run, got %+v", report.FixAcceptance)
+	}
+	if report.MemoryHits.Count != 2 || report.MemoryHits.Total != 4 || report.MemoryHits.Rate != 0.5 {
+		t.Fatalf("expected half the answers from memory, got %+v", report.MemoryHits)
+	}
+	if len(report.TopSuggestions) != 3 || report.TopSuggestions[0].Command != "kubectl get pods" || report.TopSuggestions[0].Count != 2 {
+		t.Fatalf("unexpected used suggestions: %+v", report.TopSuggestions)
+	}
+}
+
+func TestFrequentCommandsFilters(t *testing.T) {
+	events := []hook.Event{
+		{Command: "npm test", CWD: "/a"},
+		{Command: "npm test", CWD: "/b"},
+		{Command: "ls", CWD: "/a"},
+	}
+	got := FrequentCommands(events, 0, func(ev hook.Event) bool { return ev.CWD == "/a" })
+	if len(got) != 2 || got[0].Count != 1 {
+		t.Fatalf("expected filtered counts, got %+v", got)
+	}
+}
+
+func TestFrequentHereWidensScopeWhenDataIsThin(t *testing.T) {
+	now := time.Date(2026, 3, 10, 15, 0, 0, 0, time.UTC)
+	at := func(hour int) string {
+		return time.Date(2026, 3, 9, hour, 0, 0, 0, time.UTC).Format(time.RFC3339)
+	}
+	events := []hook.Event{
+		{Command: "make deploy", CWD: "/repo", Timestamp: at(14)},
+		{Command: "make deploy", CWD: "/repo", Timestamp: at(16)},
+		{Command: "make test", CWD: "/repo", Timestamp: at(15)},
+		{Command: "git pull", CWD: "/repo", Timestamp: at(15)},
+		{Command: "make brokn", CWD: "/repo", ExitCode: 127, Timestamp: at(15)},
+		{Command: "make backup", CWD: "/repo", Timestamp: at(3)},
+	}
+
+	got, scope := FrequentHere(events, "/repo", now, 5)
+	if scope != ScopeDirectoryHour || len(got) != 3 || got[0].Command != "make deploy" {
+		t.Fatalf("expected hour-scoped successful commands, got %q %+v", scope, got)
+	}
+
+	got, scope = FrequentHere(events, "/other", now, 5)
+	if scope != ScopeEverywhere || len(got) != 4 {
+		t.Fatalf("expected fallback to all directories, got %q %+v", scope, got)
+	}
+}
+
+func TestTemporalProfileBoostsHabitualTimes(t *testing.T) {
+	var events []hook.Event
+	// Backups every Friday afternoon for four weeks; status checks at random.
+	for week := 0; week < 4; week++ {
+		friday := time.Date(2026, 2, 6+7*week, 15, 0, 0, 0, time.UTC)
+		events = append(events, hook.Event{Command: "./backup.sh", Timestamp: friday.Format(time.RFC3339)})
+	}
+	for day := 0; day < 7; day++ {
+		at := time.Date(2026, 2, 1+day, 3*day, 0, 0, 0, time.UTC)
+		events = append(events, hook.Event{Command: "git status", Timestamp: at.Format(time.RFC3339)})
+	}
+	profile := NewTemporalProfile(events, time.UTC)
+
+	fridayAfternoon := time.Date(2026, 3, 6, 16, 0, 0, 0, time.UTC)
+	hour, weekday := profile.Boost("./backup.sh", fridayAfternoon)
+	if hour != MaxHourBoost || weekday != MaxWeekdayBoost {
+		t.Fatalf("expected full boosts for a strict habit, got hour=%v weekday=%v", hour, weekday)
+	}
+	if hour, weekday := profile.Boost("./backup.sh", fridayAfternoon.Add(-48*time.Hour-8*time.Hour)); hour != 0 || weekday != 0 {
+		t.Fatalf("expected no boost away from the habit, got hour=%v weekday=%v", hour, weekday)
+	}
+	if hour, weekday := profile.Boost("git status", fridayAfternoon); hour+weekday >= 1 {
+		t.Fatalf("expected little boost for evenly spread commands, got hour=%v weekday=%v", hour, weekday)
+	}
+	if hour, weekday := profile.Boost("unknown", fridayAfternoon); hour != 0 || weekday != 0 {
+		t.Fatalf("expected zero boost for unseen commands")
+	}
+}
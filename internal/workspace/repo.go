@@ -0,0 +1,142 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Repo is the git repository a directory belongs to, read from the .git
+// entries on disk without running git. With `git worktree add` one
+// repository has several checkouts: Root is the main one and Worktree the
+// one holding the directory, which differ only in a linked worktree.
+type Repo struct {
+	Root     string `json:"root"`
+	Worktree string `json:"worktree"`
+	// Branch is the branch checked out in Worktree, empty when HEAD is
+	// detached.
+	Branch string `json:"branch,omitempty"`
+	Linked bool   `json:"linked,omitempty"`
+	// Worktrees lists every checkout of the repository, Root first.
+	Worktrees []string `json:"worktrees,omitempty"`
+}
+
+// DetectRepo walks up from dir to the nearest .git entry. A .git directory
+// is a main checkout; a .git file pointing into another repository's
+// .git/worktrees is a linked worktree of that repository.
+func DetectRepo(dir string) (Repo, bool) {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		return Repo{}, false
+	}
+	current, err := filepath.Abs(dir)
+	if err != nil {
+		return Repo{}, false
+	}
+	for {
+		dotGit := filepath.Join(current, ".git")
+		if info, err := os.Stat(dotGit); err == nil {
+			if info.IsDir() {
+				return describeRepo(current, dotGit, dotGit), true
+			}
+			gitDir, ok := readGitFile(dotGit)
+			if !ok {
+				return Repo{}, false
+			}
+			commonDir := gitDir
+			if common, ok := readPathFile(filepath.Join(gitDir, "commondir"), gitDir); ok {
+				commonDir = common
+			}
+			return describeRepo(current, gitDir, commonDir), true
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return Repo{}, false
+		}
+		current = parent
+	}
+}
+
+// Contains reports whether path is inside one of the repository's
+// checkouts.
+func (r Repo) Contains(path string) bool {
+	path = filepath.Clean(strings.TrimSpace(path))
+	for _, worktree := range r.Worktrees {
+		if path == worktree || strings.HasPrefix(path, worktree+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+func describeRepo(worktree, gitDir, commonDir string) Repo {
+	repo := Repo{Worktree: worktree, Linked: filepath.Clean(gitDir) != filepath.Clean(commonDir)}
+	// A bare repository has no main checkout; its linked worktrees are
+	// then grouped under the repository directory itself.
+	repo.Root = commonDir
+	if filepath.Base(commonDir) == ".git" {
+		repo.Root = filepath.Dir(commonDir)
+	}
+	if !repo.Linked {
+		repo.Root = worktree
+	}
+	if head, err := os.ReadFile(filepath.Join(gitDir, "HEAD")); err == nil {
+		if ref, ok := strings.CutPrefix(strings.TrimSpace(string(head)), "ref: refs/heads/"); ok {
+			repo.Branch = ref
+		}
+	}
+
+	others := []string{}
+	entries, _ := os.ReadDir(filepath.Join(commonDir, "worktrees"))
+	for _, entry := range entries {
+		gitFile, ok := readPathFile(filepath.Join(commonDir, "worktrees", entry.Name(), "gitdir"), "")
+		if !ok {
+			continue
+		}
+		path := filepath.Dir(gitFile)
+		if path != repo.Root {
+			others = append(others, path)
+		}
+	}
+	sort.Strings(others)
+	repo.Worktrees = append([]string{repo.Root}, others...)
+	if !repo.Contains(worktree) {
+		repo.Worktrees = append(repo.Worktrees, worktree)
+	}
+	return repo
+}
+
+// readGitFile reads the "gitdir: <path>" line of a .git file.
+func readGitFile(path string) (string, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	target, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+	if !ok {
+		return "", false
+	}
+	return resolveFrom(filepath.Dir(path), strings.TrimSpace(target)), true
+}
+
+// readPathFile reads a file holding one path, relative to base when it is
+// not absolute.
+func readPathFile(path, base string) (string, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	target := strings.TrimSpace(string(data))
+	if target == "" {
+		return "", false
+	}
+	return resolveFrom(base, target), true
+}
+
+func resolveFrom(base, target string) string {
+	if filepath.IsAbs(target) || base == "" {
+		return filepath.Clean(target)
+	}
+	return filepath.Clean(filepath.Join(base, target))
+}
@@ -0,0 +1,75 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// fakeWorktreeRepo lays out a repository at <root>/app with a linked
+// worktree at <root>/app-feature, the way `git worktree add` does.
+func fakeWorktreeRepo(t *testing.T) (string, string) {
+	t.Helper()
+	root := t.TempDir()
+	main := filepath.Join(root, "app")
+	linked := filepath.Join(root, "app-feature")
+	admin := filepath.Join(main, ".git", "worktrees", "app-feature")
+	for _, dir := range []string{filepath.Join(main, "src"), admin, filepath.Join(linked, "src")} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{
+		filepath.Join(main, ".git", "HEAD"): "ref: refs/heads/main\n",
+		filepath.Join(admin, "HEAD"):        "ref: refs/heads/feature/login\n",
+		filepath.Join(admin, "commondir"):   "../..\n",
+		filepath.Join(admin, "gitdir"):      filepath.Join(linked, ".git") + "\n",
+		filepath.Join(linked, ".git"):       "gitdir: " + admin + "\n",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return main, linked
+}
+
+func TestDetectRepoTellsMainCheckoutFromLinkedWorktree(t *testing.T) {
+	main, linked := fakeWorktreeRepo(t)
+
+	repo, ok := DetectRepo(filepath.Join(linked, "src"))
+	if !ok {
+		t.Fatalf("expected a repository")
+	}
+	want := Repo{Root: main, Worktree: linked, Branch: "feature/login", Linked: true, Worktrees: []string{main, linked}}
+	if !reflect.DeepEqual(repo, want) {
+		t.Fatalf("got %+v\nwant %+v", repo, want)
+	}
+
+	repo, ok = DetectRepo(filepath.Join(main, "src"))
+	want = Repo{Root: main, Worktree: main, Branch: "main", Worktrees: []string{main, linked}}
+	if !ok || !reflect.DeepEqual(repo, want) {
+		t.Fatalf("got %+v\nwant %+v", repo, want)
+	}
+	if !repo.Contains(filepath.Join(linked, "src")) || repo.Contains(main+"-other") {
+		t.Fatalf("expected Contains to cover every checkout and nothing else")
+	}
+}
+
+func TestDetectRepoOutsideRepositoryAndDetachedHead(t *testing.T) {
+	if _, ok := DetectRepo(t.TempDir()); ok {
+		t.Fatalf("expected no repository in an empty dir")
+	}
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".git", "HEAD"), []byte("4b825dc642cb6eb9a060e54bf8d69288fbee4904\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	repo, ok := DetectRepo(dir)
+	if !ok || repo.Branch != "" || repo.Linked {
+		t.Fatalf("expected a detached main checkout, got %+v", repo)
+	}
+}
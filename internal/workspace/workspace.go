@@ -0,0 +1,210 @@
+package workspace
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ashwch/ew/internal/state"
+)
+
+const (
+	ConfigFileName = ".ew.toml"
+	PackDirName    = ".ew"
+	trustFileName  = "workspace_trust.json"
+)
+
+const (
+	TrustTrusted   = "trusted"
+	TrustUntrusted = "untrusted"
+	TrustUndecided = "undecided"
+	// TrustChanged is a trusted project whose settings changed after it
+	// was trusted; they are ignored until the user trusts them again.
+	TrustChanged = "changed"
+	// TrustSkipped is a project the user put off deciding about; ew asks
+	// again once SkipFor has passed.
+	TrustSkipped = "skipped"
+)
+
+// SkipFor is how long "skip for now" holds before ew asks again.
+const SkipFor = 24 * time.Hour
+
+// Project is a directory that ships its own ew settings: a .ew.toml file
+// and/or a .ew/ directory holding packs such as locales.
+type Project struct {
+	Root       string `json:"root"`
+	ConfigPath string `json:"config_path,omitempty"`
+	PackDir    string `json:"pack_dir,omitempty"`
+}
+
+// Status reports what ew decided to do with a discovered project.
+type Status struct {
+	Project
+	Trust   string `json:"trust"`
+	Applied bool   `json:"applied"`
+	Note    string `json:"note,omitempty"`
+}
+
+type TrustEntry struct {
+	Trusted bool `json:"trusted"`
+	Skipped bool `json:"skipped,omitempty"`
+	// Hash is the Fingerprint of the project's settings when the user
+	// decided.
+	Hash      string `json:"hash,omitempty"`
+	DecidedAt string `json:"decided_at"`
+}
+
+type TrustStore struct {
+	Workspaces map[string]TrustEntry `json:"workspaces"`
+}
+
+// Discover walks up from startDir and returns the nearest project that has
+// a .ew.toml file or a .ew/ pack directory. The home directory itself is
+// never treated as a project.
+func Discover(startDir string) (Project, bool) {
+	dir, err := filepath.Abs(strings.TrimSpace(startDir))
+	if err != nil || strings.TrimSpace(startDir) == "" {
+		return Project{}, false
+	}
+	home, _ := os.UserHomeDir()
+	for {
+		if home != "" && dir == filepath.Clean(home) {
+			return Project{}, false
+		}
+		project := Project{Root: dir}
+		if info, err := os.Stat(filepath.Join(dir, ConfigFileName)); err == nil && info.Mode().IsRegular() {
+			project.ConfigPath = filepath.Join(dir, ConfigFileName)
+		}
+		if info, err := os.Stat(filepath.Join(dir, PackDirName)); err == nil && info.IsDir() {
+			project.PackDir = filepath.Join(dir, PackDirName)
+		}
+		if project.ConfigPath != "" || project.PackDir != "" {
+			return project, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return Project{}, false
+		}
+		dir = parent
+	}
+}
+
+// LoadTrust reads the workspace decisions from the state backend.
+func LoadTrust() (TrustStore, error) {
+	backend, err := state.Current()
+	if err != nil {
+		return TrustStore{}, err
+	}
+	bytes, err := backend.Read(trustFileName)
+	if err != nil {
+		return TrustStore{}, fmt.Errorf("could not read workspace trust store: %w", err)
+	}
+	store := TrustStore{Workspaces: map[string]TrustEntry{}}
+	if bytes == nil {
+		return store, nil
+	}
+	if err := json.Unmarshal(bytes, &store); err != nil {
+		return TrustStore{}, fmt.Errorf("could not parse workspace trust store: %w", err)
+	}
+	if store.Workspaces == nil {
+		store.Workspaces = map[string]TrustEntry{}
+	}
+	return store, nil
+}
+
+// SaveTrust replaces the workspace decisions in the state backend.
+func SaveTrust(store TrustStore) error {
+	payload, err := json.MarshalIndent(store, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode workspace trust store: %w", err)
+	}
+	backend, err := state.Current()
+	if err != nil {
+		return err
+	}
+	if err := backend.Write(trustFileName, payload); err != nil {
+		return fmt.Errorf("could not save workspace trust store: %w", err)
+	}
+	return nil
+}
+
+// Fingerprint hashes a project's settings: its .ew.toml and every file
+// under .ew/, by path and content. Trust holds only while it is unchanged.
+func Fingerprint(project Project) (string, error) {
+	sum := sha256.New()
+	add := func(path string) error {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("could not read %s: %w", path, err)
+		}
+		rel, _ := filepath.Rel(project.Root, path)
+		fmt.Fprintf(sum, "%s\x00%d\x00", filepath.ToSlash(rel), len(content))
+		sum.Write(content)
+		return nil
+	}
+	if project.ConfigPath != "" {
+		if err := add(project.ConfigPath); err != nil {
+			return "", err
+		}
+	}
+	if project.PackDir != "" {
+		// WalkDir visits entries in lexical order, so the hash is stable.
+		err := filepath.WalkDir(project.PackDir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !entry.Type().IsRegular() {
+				return nil
+			}
+			return add(path)
+		})
+		if err != nil {
+			return "", fmt.Errorf("could not read %s: %w", project.PackDir, err)
+		}
+	}
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+// Lookup returns the stored trust state for root, whose settings have the
+// Fingerprint hash: trusted, changed when they differ from the ones the
+// user trusted, untrusted, skipped until SkipFor has passed, or undecided
+// when the user has never been asked.
+func (s TrustStore) Lookup(root, hash string) string {
+	entry, ok := s.Workspaces[filepath.Clean(root)]
+	switch {
+	case !ok:
+		return TrustUndecided
+	case entry.Skipped:
+		decided, err := time.Parse(time.RFC3339, entry.DecidedAt)
+		if err != nil || time.Since(decided) >= SkipFor {
+			return TrustUndecided
+		}
+		return TrustSkipped
+	case !entry.Trusted:
+		return TrustUntrusted
+	case entry.Hash != hash:
+		return TrustChanged
+	}
+	return TrustTrusted
+}
+
+// Decide records the user's answer for root, whose settings have the
+// Fingerprint hash. trust is TrustTrusted, TrustUntrusted, or
+// TrustSkipped.
+func (s *TrustStore) Decide(root, hash, trust string) {
+	if s.Workspaces == nil {
+		s.Workspaces = map[string]TrustEntry{}
+	}
+	s.Workspaces[filepath.Clean(root)] = TrustEntry{
+		Trusted:   trust == TrustTrusted,
+		Skipped:   trust == TrustSkipped,
+		Hash:      hash,
+		DecidedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+}
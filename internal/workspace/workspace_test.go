@@ -0,0 +1,118 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDiscoverFindsNearestProjectConfig(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, ConfigFileName), []byte("mode = \"suggest\"\n"), 0o600); err != nil {
+		t.Fatalf("write project config failed: %v", err)
+	}
+	nested := filepath.Join(root, "src", "pkg")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+
+	project, ok := Discover(nested)
+	if !ok {
+		t.Fatalf("expected project to be discovered from nested dir")
+	}
+	if project.Root != root {
+		t.Fatalf("expected root %q, got %q", root, project.Root)
+	}
+	if project.ConfigPath != filepath.Join(root, ConfigFileName) {
+		t.Fatalf("unexpected config path %q", project.ConfigPath)
+	}
+}
+
+func TestDiscoverSkipsHomeDirectory(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.Mkdir(filepath.Join(home, PackDirName), 0o755); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+	if project, ok := Discover(home); ok {
+		t.Fatalf("expected home directory to be ignored, got %+v", project)
+	}
+}
+
+func TestTrustDecisionRoundTrip(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", "")
+
+	store, err := LoadTrust()
+	if err != nil {
+		t.Fatalf("LoadTrust failed: %v", err)
+	}
+	if got := store.Lookup("/repo/a", "h1"); got != TrustUndecided {
+		t.Fatalf("expected undecided for unknown workspace, got %q", got)
+	}
+	store.Decide("/repo/a", "h1", TrustTrusted)
+	store.Decide("/repo/b/", "h2", TrustUntrusted)
+	store.Decide("/repo/c", "h3", TrustSkipped)
+	if err := SaveTrust(store); err != nil {
+		t.Fatalf("SaveTrust failed: %v", err)
+	}
+
+	reloaded, err := LoadTrust()
+	if err != nil {
+		t.Fatalf("LoadTrust reload failed: %v", err)
+	}
+	if got := reloaded.Lookup("/repo/a", "h1"); got != TrustTrusted {
+		t.Fatalf("expected trusted, got %q", got)
+	}
+	if got := reloaded.Lookup("/repo/a", "edited"); got != TrustChanged {
+		t.Fatalf("expected changed settings to lose trust, got %q", got)
+	}
+	if got := reloaded.Lookup("/repo/b", "h2"); got != TrustUntrusted {
+		t.Fatalf("expected untrusted, got %q", got)
+	}
+	if got := reloaded.Lookup("/repo/c", "h3"); got != TrustSkipped {
+		t.Fatalf("expected the skip to be remembered, got %q", got)
+	}
+
+	entry := reloaded.Workspaces["/repo/c"]
+	entry.DecidedAt = time.Now().Add(-SkipFor).UTC().Format(time.RFC3339)
+	reloaded.Workspaces["/repo/c"] = entry
+	if got := reloaded.Lookup("/repo/c", "h3"); got != TrustUndecided {
+		t.Fatalf("expected an old skip to ask again, got %q", got)
+	}
+}
+
+func TestFingerprintCoversConfigAndPacks(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, ConfigFileName), []byte("mode = \"suggest\"\n"), 0o600); err != nil {
+		t.Fatalf("write project config failed: %v", err)
+	}
+	locales := filepath.Join(root, PackDirName, "locales")
+	if err := os.MkdirAll(locales, 0o755); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(locales, "es.json"), []byte("{}"), 0o600); err != nil {
+		t.Fatalf("write pack failed: %v", err)
+	}
+	project, ok := Discover(root)
+	if !ok {
+		t.Fatalf("expected project to be discovered")
+	}
+
+	first, err := Fingerprint(project)
+	if err != nil {
+		t.Fatalf("Fingerprint failed: %v", err)
+	}
+	if again, _ := Fingerprint(project); again != first {
+		t.Fatalf("expected a stable fingerprint, got %q then %q", first, again)
+	}
+	if err := os.WriteFile(filepath.Join(locales, "es.json"), []byte(`{"flags":{}}`), 0o600); err != nil {
+		t.Fatalf("rewrite pack failed: %v", err)
+	}
+	if edited, _ := Fingerprint(project); edited == first {
+		t.Fatalf("expected an edited pack to change the fingerprint")
+	}
+}